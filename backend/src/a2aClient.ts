@@ -244,7 +244,7 @@ export class A2AClient {
   async listTasks(): Promise<Task[] | null> {
     console.log(`[A2AClient] Sending JSON-RPC request for 'tasks/list' to ${this.agentUrl}`);
     // Use the sendRequest helper to make a JSON-RPC call
-    const response = await this.sendRequest('tasks/list'); // No parameters needed for list
+    const response = await this.sendRequest('tasks/list', { view: 'full' }); // Complete tasks, not summaries
 
     if (response.error) {
       console.error(`[A2AClient] Error in listTasks response:`, response.error);
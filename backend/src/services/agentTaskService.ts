@@ -21,6 +21,7 @@ export class AgentTaskService {
     const requestBody = {
       jsonrpc: "2.0",
       method: "tasks/list",
+      params: { view: "full" }, // The UI reads messages and artifacts from the list
       id: requestId,
     };
 
@@ -1,7 +1,10 @@
 package a2a
 
 import (
+	"context"
+	"net/http"
 	"sync"
+	"time"
 
 	"ka/llm"
 	"ka/tools" // Import the tools package
@@ -9,24 +12,106 @@ import (
 
 type TaskExecutor struct {
 	LLMClient                     llm.LLMClient // Exported LLMClient
-	TaskStore                     TaskStore      // Exported TaskStore
+	DefaultProvider               string        // Provider of LLMClient; tasks choosing it use LLMClient
+	ClientFactory                 ClientFactory // Creates clients for tasks choosing another provider; nil refuses them
+	TaskStore                     TaskStore      // Exported TaskStore; changes made through it are reported to subscribers
 	AvailableTools                map[string]tools.Tool // Map of available tools
 	SystemMessage                 string // Added SystemMessage field
+	Dispatcher                    ToolDispatcher // Runs tool calls; nil uses a DefaultToolDispatcher built from the fields below
+	ToolPolicy                    *ToolPolicy
+	ToolAudit                     bool // Persist an audit artifact for every tool call
+	ToolQuotas                    ToolQuotas // Per-tool usage limits of every task; tasks can override them per tool
+	ToolOutputFilters             ToolOutputFilters // Per-tool filters reducing tool output before the LLM sees it
+	Signer                        *RequestSigner // Signs requests to other agents and webhooks; nil sends them unsigned
+	AgentTransport                http.RoundTripper // Carries requests to other agents, e.g. with the egress policy; nil uses http.DefaultTransport
+	FileTransport                 http.RoundTripper // Carries downloads of http(s) file parts into prompts, e.g. with the egress policy; nil uses http.DefaultTransport
+	Deliveries                    *Deliverer // Sends webhooks and integration results, dead-lettering those that fail
+	Audit                         *AuditLog  // Security events such as refused webhook deliveries, see admin/auditLog
+	Guardrails                    *Guardrails // Declarative rules checked before LLM calls, tool calls and completion
+	ReadOnly                      ReadOnlyMode // Refuses writes and side-effect tools while enabled
+	SSE                           SSESettings  // Stream options of tasks/sendSubscribe, see admin/sse
+	HeartbeatInterval             time.Duration // How often running tasks record a heartbeat; 0 disables
+	DefaultTimeoutSeconds         int // Execution timeout of tasks whose mode and request set none; 0 disables
+	MaxContinuations              int // Continuations of completions cut off by the token limit, for tasks whose mode and request set none
+	TaskWorkspaceRoot             string // Directory holding a workspace per task, named by task ID; removed by cascading deletes
+	TrashRetention                time.Duration // How long deleted tasks stay restorable in the trash; 0 deletes them right away
+	Modes                         *ModeRegistry // Mode presets selectable per task
+	Projects                      *ProjectRegistry // Nil disables project scoping
+	DefaultMode                   string        // Mode recorded on tasks that do not choose one
+	InlineArtifactBytes           int // Artifacts up to this size are inlined in the final SSE event; 0 leaves artifacts out
+	OutputProcessing              *OutputProcessing // Applied to the final answer; nil stores it unchanged
+	SubTaskPolicy                 *SubTaskPolicy // Default for parents without their own policy; nil leaves failed sub-tasks alone
+	SmokeSuite                    *SmokeSuite // Run against system prompt changes before they apply; nil applies them unchecked
+	ToolPruneTopK                 int // Tool definitions kept in the system prompt of new tasks, by relevance to the request; 0 keeps all
+	ResubscribeGrace              time.Duration // How long a streamed task outlives its last subscriber, awaiting tasks/resubscribe; 0 cancels it right away
+	Affinity                      *AffinityRing // Instances sharing the task store; only tasks this instance owns are taken over. Nil owns all
+	CheckpointEvery               int // Checkpoint running tasks every this many iterations and on shutdown, and resume unfinished tool calls from checkpoints; 0 disables
+	ContextStrategy               ContextStrategy // Shortens prompts over ContextWindow tokens; nil leaves that to the LLM client
+	ContextWindow                 int             // Token budget of a prompt for ContextStrategy
+	ContextKeepRecent             int             // Latest messages ContextStrategy keeps intact; DefaultContextKeepRecent when 0
 	mu                            sync.Mutex
-	resumeChannels                map[string]chan struct{}
+	activeRuns                    map[string]bool // Tasks with a running execution goroutine; waiting tasks are parked and absent
+	stepSignals                   map[string]chan struct{} // Debug tasks paused in STEP_WAIT, closed by StepTask
+	eventQueue                    eventQueue // Events delivered before a task waited for them
+	events                        *taskEvents // Lifecycle event subscribers, see Subscribe
+	streams                       map[string]*taskStream // Event streams of tasks started with tasks/sendSubscribe, see tasks/resubscribe
+	clients                       map[string]llm.LLMClient // Clients of other providers by provider and model, see clientFor
+	lifetime                      context.Context // Done once Shutdown started; runs are cancelled with it
+	stop                          context.CancelFunc
 	pushNotificationRegistrations map[string]string // Map taskID to notification URL
+	eventLog                      TaskEventLog      // Of the store, nil if it keeps none
 }
 
 // NewTaskExecutor creates a new TaskExecutor.
 // It now accepts the map of available tools and the system message.
 func NewTaskExecutor(client llm.LLMClient, store TaskStore, availableTools map[string]tools.Tool, systemMessage string) *TaskExecutor { // Updated signature
-	return &TaskExecutor{
-		LLMClient:                     client,         // Assign to exported field
-		TaskStore:                     store,          // Assign to exported field
+	events := &taskEvents{}
+	lifetime, stop := context.WithCancel(context.Background())
+	te := &TaskExecutor{
+		Deliveries:                    NewDeliverer(store),
+		Audit:                         &AuditLog{},
+		LLMClient:                     client,                                          // Assign to exported field
+		TaskStore:                     &observedTaskStore{TaskStore: store, events: events}, // Reports lifecycle events
 		AvailableTools:                availableTools, // Store the map of available tools
 		SystemMessage:                 systemMessage,  // Assign the system message
+		Modes:                         NewModeRegistry(),
+		ResubscribeGrace:              DefaultResubscribeGrace,
 		mu:                            sync.Mutex{},
-		resumeChannels:                make(map[string]chan struct{}),
+		activeRuns:                    make(map[string]bool),
+		stepSignals:                   make(map[string]chan struct{}),
+		events:                        events,
+		lifetime:                      lifetime,
+		stop:                          stop,
 		pushNotificationRegistrations: make(map[string]string), // Initialize the map
 	}
+	if eventLog, ok := store.(TaskEventLog); ok {
+		te.eventLog = eventLog
+		events.subscribe(te.recordTaskEvent)
+	}
+	return te
+}
+
+// toolDispatcher returns the injected Dispatcher, or the default one.
+func (te *TaskExecutor) toolDispatcher() ToolDispatcher {
+	var dispatcher ToolDispatcher = te.NewDefaultToolDispatcher()
+	if te.Dispatcher != nil {
+		dispatcher = te.Dispatcher
+	}
+	return &observedDispatcher{next: dispatcher, events: te.events}
+}
+
+// NewDefaultToolDispatcher creates a DefaultToolDispatcher bound to the
+// executor's tools and policy, e.g. for a custom Dispatcher to wrap.
+func (te *TaskExecutor) NewDefaultToolDispatcher() *DefaultToolDispatcher {
+	dispatcher := NewToolDispatcher(te.TaskStore, te.AvailableTools)
+	dispatcher.policy = te.ToolPolicy
+	dispatcher.audit = te.ToolAudit
+	dispatcher.modes = te.Modes
+	dispatcher.readOnly = &te.ReadOnly
+	dispatcher.quotas = te.ToolQuotas
+	dispatcher.outputFilters = te.ToolOutputFilters
+	dispatcher.guardrails = te.Guardrails
+	dispatcher.workspaceRoot = te.TaskWorkspaceRoot
+	dispatcher.projects = te.Projects
+	return dispatcher
 }
@@ -0,0 +1,113 @@
+package a2a
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// affinityReplicas is the number of points each instance has on the ring;
+// more points spread the keys more evenly.
+const affinityReplicas = 64
+
+// maxAffinityDepth bounds the walk from a sub-task to its root task.
+const maxAffinityDepth = 32
+
+// AffinityRing assigns the tasks of a deployment with several instances on
+// one task store to instances by consistent hashing. Tasks of the same
+// session, or else of the same project workspace, or else of the same root
+// task, hash to the same instance, so its workspace files and warm caches are
+// reused. An instance only takes over stored tasks it owns: on startup
+// (Reconcile, ResumeInterrupted) and when restarting stalled tasks. Adding or
+// removing an instance moves only the keys of its neighbours on the ring.
+type AffinityRing struct {
+	Self   string // Name of this instance
+	points []uint32
+	owners map[uint32]string
+}
+
+// NewAffinityRing builds the ring of the named instances, which must include
+// self. Every instance must be given the same names, in any order.
+func NewAffinityRing(self string, instances []string) (*AffinityRing, error) {
+	r := &AffinityRing{Self: self, owners: make(map[uint32]string)}
+	names := append([]string(nil), instances...)
+	sort.Strings(names) // Hash collisions go to the same instance everywhere
+	found := false
+	for i, instance := range names {
+		if instance == "" || (i > 0 && names[i-1] == instance) {
+			return nil, fmt.Errorf("instance names must be unique and not empty, got %q", instance)
+		}
+		found = found || instance == self
+		for replica := 0; replica < affinityReplicas; replica++ {
+			point := affinityHash(instance + "#" + strconv.Itoa(replica))
+			if _, taken := r.owners[point]; !taken {
+				r.owners[point] = instance
+				r.points = append(r.points, point)
+			}
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("instance %q is not in the list of instances %v", self, instances)
+	}
+	sort.Slice(r.points, func(i, j int) bool { return r.points[i] < r.points[j] })
+	return r, nil
+}
+
+// Owner returns the instance a key is assigned to.
+func (r *AffinityRing) Owner(key string) string {
+	hash := affinityHash(key)
+	i := sort.Search(len(r.points), func(i int) bool { return r.points[i] >= hash })
+	if i == len(r.points) {
+		i = 0
+	}
+	return r.owners[r.points[i]]
+}
+
+func affinityHash(key string) uint32 {
+	sum := sha256.Sum256([]byte(key))
+	return binary.BigEndian.Uint32(sum[:4])
+}
+
+// ownsTask reports whether this instance may take over a stored task. Without
+// a ring the instance owns every task.
+func (te *TaskExecutor) ownsTask(task *Task) bool {
+	if te.Affinity == nil {
+		return true
+	}
+	return te.Affinity.Owner(affinityKey(te.TaskStore, task)) == te.Affinity.Self
+}
+
+// affinityKey returns what tasks that should run on the same instance share:
+// the session or project of the task or its closest ancestor having one, else
+// its root task.
+func affinityKey(store TaskStore, task *Task) string {
+	for i := 0; ; i++ {
+		if task.SessionID != "" {
+			return "session:" + task.SessionID
+		}
+		if task.Project != "" {
+			return "project:" + task.Project
+		}
+		if task.ParentTaskID == "" || i == maxAffinityDepth {
+			return "task:" + task.ID
+		}
+		parent, err := store.GetTask(task.ParentTaskID)
+		if err != nil {
+			return "task:" + task.ID
+		}
+		task = parent
+	}
+}
+
+// inheritSession copies the session of a parent task to a new sub-task.
+func inheritSession(store TaskStore, taskID string, parent *Task) {
+	if parent.SessionID == "" {
+		return
+	}
+	store.UpdateTask(taskID, func(task *Task) error {
+		task.SessionID = parent.SessionID
+		return nil
+	})
+}
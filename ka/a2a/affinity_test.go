@@ -0,0 +1,85 @@
+package a2a
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestAffinityRingAssignsKeysConsistently(t *testing.T) {
+	a, err := NewAffinityRing("a", []string{"a", "b", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, _ := NewAffinityRing("c", []string{"c", "b", "a"})
+	counts := map[string]int{}
+	for i := 0; i < 3000; i++ {
+		key := fmt.Sprintf("session:%d", i)
+		if a.Owner(key) != c.Owner(key) {
+			t.Fatalf("instances disagree on the owner of %s", key)
+		}
+		counts[a.Owner(key)]++
+	}
+	for _, instance := range []string{"a", "b", "c"} {
+		if counts[instance] < 500 {
+			t.Errorf("expected keys to spread over the instances, got %v", counts)
+		}
+	}
+
+	// Removing an instance only moves its own keys
+	ab, _ := NewAffinityRing("a", []string{"a", "b"})
+	for i := 0; i < 3000; i++ {
+		key := fmt.Sprintf("session:%d", i)
+		if owner := a.Owner(key); owner != "c" && ab.Owner(key) != owner {
+			t.Fatalf("key %s moved from %s to %s", key, owner, ab.Owner(key))
+		}
+	}
+
+	for _, instances := range [][]string{{"b"}, {"a", "a"}, {"a", ""}} {
+		if _, err := NewAffinityRing("a", instances); err == nil {
+			t.Errorf("expected instances %q to be refused", instances)
+		}
+	}
+}
+
+func TestReconcileLeavesTasksOfOtherInstances(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	input := []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "hi"}}}}
+	te := NewTaskExecutor(&scriptedLLMClient{replies: []string{"Done."}}, store, nil, "")
+	te.Affinity, _ = NewAffinityRing("a", []string{"a", "b"})
+
+	var mine, theirs string
+	for i := 0; mine == "" || theirs == ""; i++ {
+		session := fmt.Sprintf("s%d", i)
+		task, _ := store.CreateTask(session, "", input, "")
+		store.UpdateTask(task.ID, func(t *Task) error {
+			t.State = TaskStateWorking
+			t.SessionID = session
+			return nil
+		})
+		if te.Affinity.Owner("session:"+session) == "a" && mine == "" {
+			mine = task.ID
+		} else if te.Affinity.Owner("session:"+session) == "b" && theirs == "" {
+			theirs = task.ID
+		} else {
+			store.DeleteTask(task.ID)
+		}
+	}
+	// A sub-task without a session of its own runs where its root task does
+	child, _ := store.CreateTask("child", "", input, theirs)
+	store.SetState(child.ID, TaskStateWorking)
+
+	report, err := te.Reconcile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Relaunched) != 1 || report.Relaunched[0] != mine {
+		t.Errorf("expected only the task of this instance to be relaunched, got %v", report.Relaunched)
+	}
+	if len(report.OtherInstance) != 2 {
+		t.Errorf("expected the task of the other instance and its sub-task to be left alone, got %v", report.OtherInstance)
+	}
+	waitForState(t, store, mine, TaskStateCompleted)
+	if task, _ := taskSnapshot(store, theirs); task.State != TaskStateWorking {
+		t.Errorf("expected the task of the other instance to stay WORKING, got %s", task.State)
+	}
+}
@@ -0,0 +1,135 @@
+package a2a
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	_ "image/gif" // Register decoders for image dimensions
+	_ "image/jpeg"
+	_ "image/png"
+	"path"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Renderers suggested by RenderHints.
+const (
+	RendererCode     = "code"
+	RendererMarkdown = "markdown"
+	RendererText     = "text"
+	RendererTable    = "table"
+	RendererChart    = "chart"
+	RendererJSON     = "json"
+	RendererImage    = "image"
+	RendererBinary   = "binary"
+)
+
+// RenderHints tell a UI how to display an artifact without sniffing its bytes.
+type RenderHints struct {
+	Renderer  string   `json:"renderer"`
+	Language  string   `json:"language,omitempty"`  // Syntax highlighting language of code
+	Lines     int      `json:"lines,omitempty"`     // Line count of text
+	Columns   []string `json:"columns,omitempty"`   // Column names of tabular data
+	Rows      int      `json:"rows,omitempty"`      // Row count of tabular data
+	ChartSpec string   `json:"chartSpec,omitempty"` // Chart specification format, e.g. vega-lite
+	Width     int      `json:"width,omitempty"`     // Image dimensions in pixels
+	Height    int      `json:"height,omitempty"`
+}
+
+// codeLanguages maps file extensions to highlighting languages.
+var codeLanguages = map[string]string{
+	".go": "go", ".py": "python", ".js": "javascript", ".jsx": "javascript", ".ts": "typescript",
+	".tsx": "typescript", ".rs": "rust", ".java": "java", ".c": "c", ".h": "c", ".cpp": "cpp",
+	".cs": "csharp", ".rb": "ruby", ".php": "php", ".sh": "bash", ".sql": "sql", ".html": "html",
+	".css": "css", ".yaml": "yaml", ".yml": "yaml", ".toml": "toml", ".xml": "xml", ".kt": "kotlin",
+	".swift": "swift", ".diff": "diff", ".patch": "diff",
+}
+
+// computeRenderHints derives the rendering hints of an artifact from its
+// type, file name and content.
+func computeRenderHints(artifact Artifact) *RenderHints {
+	ext := strings.ToLower(path.Ext(artifact.Filename))
+	mimeType := strings.ToLower(artifact.Type)
+	data := artifact.Data
+
+	if strings.HasPrefix(mimeType, "image/") || ext == ".png" || ext == ".jpg" || ext == ".jpeg" || ext == ".gif" || ext == ".svg" {
+		hints := &RenderHints{Renderer: RendererImage}
+		if config, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+			hints.Width, hints.Height = config.Width, config.Height
+		}
+		return hints
+	}
+	if !utf8.Valid(data) {
+		return &RenderHints{Renderer: RendererBinary}
+	}
+	lines := bytes.Count(data, []byte("\n"))
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		lines++
+	}
+
+	switch {
+	case ext == ".json" || strings.Contains(mimeType, "json"):
+		return jsonRenderHints(data, lines)
+	case ext == ".csv" || ext == ".tsv" || mimeType == "text/csv" || mimeType == "text/tab-separated-values":
+		return csvRenderHints(data, ext == ".tsv" || mimeType == "text/tab-separated-values")
+	case ext == ".md" || ext == ".markdown" || mimeType == "text/markdown":
+		return &RenderHints{Renderer: RendererMarkdown, Lines: lines}
+	}
+	if language, ok := codeLanguages[ext]; ok {
+		return &RenderHints{Renderer: RendererCode, Language: language, Lines: lines}
+	}
+	return &RenderHints{Renderer: RendererText, Lines: lines}
+}
+
+// jsonRenderHints recognises chart specifications and arrays of records.
+func jsonRenderHints(data []byte, lines int) *RenderHints {
+	var value interface{}
+	if json.Unmarshal(data, &value) != nil {
+		return &RenderHints{Renderer: RendererCode, Language: "json", Lines: lines}
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if schema, _ := v["$schema"].(string); strings.Contains(schema, "vega-lite") {
+			return &RenderHints{Renderer: RendererChart, ChartSpec: "vega-lite"}
+		} else if strings.Contains(schema, "vega") {
+			return &RenderHints{Renderer: RendererChart, ChartSpec: "vega"}
+		}
+	case []interface{}:
+		columns := map[string]bool{}
+		for _, row := range v {
+			record, ok := row.(map[string]interface{})
+			if !ok {
+				return &RenderHints{Renderer: RendererJSON, Lines: lines}
+			}
+			for key := range record {
+				columns[key] = true
+			}
+		}
+		if len(v) > 0 {
+			hints := &RenderHints{Renderer: RendererTable, Rows: len(v)}
+			for key := range columns {
+				hints.Columns = append(hints.Columns, key)
+			}
+			sort.Strings(hints.Columns)
+			return hints
+		}
+	}
+	return &RenderHints{Renderer: RendererJSON, Lines: lines}
+}
+
+func csvRenderHints(data []byte, tabs bool) *RenderHints {
+	separator := ","
+	if tabs {
+		separator = "\t"
+	}
+	rows := strings.Split(strings.TrimRight(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n"), "\n")
+	hints := &RenderHints{Renderer: RendererTable}
+	if len(rows) > 0 && rows[0] != "" {
+		for _, column := range strings.Split(rows[0], separator) {
+			hints.Columns = append(hints.Columns, strings.Trim(strings.TrimSpace(column), `"`))
+		}
+		hints.Rows = len(rows) - 1
+	}
+	return hints
+}
@@ -0,0 +1,61 @@
+package a2a
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"reflect"
+	"testing"
+)
+
+func TestComputeRenderHints(t *testing.T) {
+	var pngData bytes.Buffer
+	if err := png.Encode(&pngData, image.NewRGBA(image.Rect(0, 0, 32, 16))); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name     string
+		artifact Artifact
+		want     RenderHints
+	}{
+		{"code", Artifact{Filename: "main.go", Data: []byte("package main\n\nfunc main() {}\n")}, RenderHints{Renderer: RendererCode, Language: "go", Lines: 3}},
+		{"markdown", Artifact{Type: "text/markdown", Data: []byte("# Title\ntext")}, RenderHints{Renderer: RendererMarkdown, Lines: 2}},
+		{"csv", Artifact{Filename: "data.csv", Data: []byte("name,age\nann,3\nbob,4\n")}, RenderHints{Renderer: RendererTable, Columns: []string{"name", "age"}, Rows: 2}},
+		{"json records", Artifact{Filename: "rows.json", Data: []byte(`[{"b":1,"a":2},{"a":3}]`)}, RenderHints{Renderer: RendererTable, Columns: []string{"a", "b"}, Rows: 2}},
+		{"vega-lite", Artifact{Type: "application/json", Data: []byte(`{"$schema":"https://vega.github.io/schema/vega-lite/v5.json","mark":"bar"}`)}, RenderHints{Renderer: RendererChart, ChartSpec: "vega-lite"}},
+		{"json", Artifact{Filename: "config.json", Data: []byte(`{"a":1}`)}, RenderHints{Renderer: RendererJSON, Lines: 1}},
+		{"image", Artifact{Type: "image/png", Data: pngData.Bytes()}, RenderHints{Renderer: RendererImage, Width: 32, Height: 16}},
+		{"binary", Artifact{Filename: "blob.bin", Data: []byte{0xff, 0xfe, 0x00}}, RenderHints{Renderer: RendererBinary}},
+		{"text", Artifact{Filename: "notes", Data: []byte("one\ntwo")}, RenderHints{Renderer: RendererText, Lines: 2}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := computeRenderHints(tc.artifact)
+			if !reflect.DeepEqual(*got, tc.want) {
+				t.Errorf("hints = %+v, want %+v", *got, tc.want)
+			}
+		})
+	}
+}
+
+func TestAddArtifactComputesHints(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	task, _ := store.CreateTask("hints", "", nil, "")
+	if err := store.AddArtifact(task.ID, Artifact{ID: "a1", Filename: "script.py", Data: []byte("print(1)\n")}); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ := store.GetTask(task.ID)
+	hints := stored.Artifacts["a1"].Hints
+	if hints == nil || hints.Renderer != RendererCode || hints.Language != "python" {
+		t.Fatalf("expected python code hints, got %+v", hints)
+	}
+
+	// Hints supplied by the caller are kept.
+	custom := &RenderHints{Renderer: RendererChart, ChartSpec: "vega"}
+	store.AddArtifact(task.ID, Artifact{ID: "a2", Filename: "x.txt", Hints: custom})
+	stored, _ = store.GetTask(task.ID)
+	if stored.Artifacts["a2"].Hints.Renderer != RendererChart {
+		t.Errorf("caller hints were replaced: %+v", stored.Artifacts["a2"].Hints)
+	}
+}
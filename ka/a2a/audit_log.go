@@ -0,0 +1,109 @@
+package a2a
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// auditLogRecent bounds the events an AuditLog keeps in memory for admin/auditLog.
+const auditLogRecent = 500
+
+// AuditEvent is a security-relevant event, such as an inbound request whose
+// signature could not be verified or that replayed an earlier one.
+type AuditEvent struct {
+	Time   time.Time `json:"time"`
+	Source string    `json:"source"`           // e.g. "github" or "signature"
+	Reason string    `json:"reason"`           // Why the request was refused
+	Remote string    `json:"remote,omitempty"` // Remote address of the request
+	Path   string    `json:"path,omitempty"`
+	KeyID  string    `json:"keyId,omitempty"` // Signing key the request claimed
+}
+
+// AuditLog records security events: it logs them, appends them as JSON lines
+// to a file when one is configured and keeps the latest for admin/auditLog.
+type AuditLog struct {
+	mu     sync.Mutex
+	file   *os.File // Nil without -audit-log
+	recent []AuditEvent
+}
+
+// NewAuditLog returns an audit log appending to path; an empty path keeps
+// the events in memory only.
+func NewAuditLog(path string) (*AuditLog, error) {
+	a := &AuditLog{}
+	if path == "" {
+		return a, nil
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log %s: %w", path, err)
+	}
+	a.file = file
+	return a, nil
+}
+
+// Record adds an event, stamping the time if it has none.
+func (a *AuditLog) Record(event AuditEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	log.Printf("[Audit] %s: %s (remote %s, path %s)", event.Source, event.Reason, event.Remote, event.Path)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.recent = append(a.recent, event)
+	if len(a.recent) > auditLogRecent {
+		a.recent = a.recent[len(a.recent)-auditLogRecent:]
+	}
+	if a.file != nil {
+		line, _ := json.Marshal(event)
+		if _, err := a.file.Write(append(line, '\n')); err != nil {
+			log.Printf("[Audit] Failed to write to the audit log: %v", err)
+		}
+	}
+}
+
+// RecordRequest records a refused inbound request.
+func (a *AuditLog) RecordRequest(r *http.Request, source, reason string) {
+	a.Record(AuditEvent{Source: source, Reason: reason, Remote: r.RemoteAddr, Path: r.URL.Path, KeyID: r.Header.Get(HeaderSignatureKeyID)})
+}
+
+// Recent returns the latest events of a source, or of all sources with an
+// empty source, newest last.
+func (a *AuditLog) Recent(source string, limit int) []AuditEvent {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	events := []AuditEvent{}
+	for _, event := range a.recent {
+		if source == "" || event.Source == source {
+			events = append(events, event)
+		}
+	}
+	if limit > 0 && len(events) > limit {
+		events = events[len(events)-limit:]
+	}
+	return events
+}
+
+// AuditLogParams defines the parameters of "admin/auditLog".
+type AuditLogParams struct {
+	Source string `json:"source,omitempty"`
+	Limit  int    `json:"limit,omitempty"` // 0 returns every kept event
+}
+
+// AdminAuditLogHandler handles "admin/auditLog", which lists the latest
+// security events, such as refused webhook deliveries.
+func AdminAuditLogHandler(audit *AuditLog) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params AuditLogParams
+		rpcReq, ok := decodeJSONRPCRequest(w, r, &params)
+		if !ok {
+			return
+		}
+		sendJSONRPCResponse(w, rpcReq.ID, map[string]interface{}{"events": audit.Recent(params.Source, params.Limit)}, nil)
+	}
+}
@@ -0,0 +1,80 @@
+package a2a
+
+import (
+	"net/http"
+)
+
+// AgentCapabilities describes what this agent instance actually supports.
+// It is built from the runtime configuration and published in the agent card.
+type AgentCapabilities struct {
+	Streaming              bool     `json:"streaming"`
+	PushNotifications      bool     `json:"pushNotifications"`
+	StateTransitionHistory bool     `json:"stateTransitionHistory"`
+	WebSocket              bool     `json:"websocket"`
+	AuthModes              []string `json:"authModes"`
+	MaxMessageBytes        int64    `json:"maxMessageBytes,omitempty"` // 0 means no limit
+	InputModes             []string `json:"inputModes"`
+	OutputModes            []string `json:"outputModes"`
+	Methods                []string `json:"methods"` // Supported JSON-RPC methods
+}
+
+// ClientCapabilities is what a client declares it can handle in "agent/negotiate".
+type ClientCapabilities struct {
+	Streaming         bool     `json:"streaming"`
+	PushNotifications bool     `json:"pushNotifications"`
+	WebSocket         bool     `json:"websocket"`
+	AcceptOutputModes []string `json:"acceptOutputModes,omitempty"` // Empty accepts all
+	AuthModes         []string `json:"authModes,omitempty"`         // Empty accepts all
+}
+
+// NegotiatedCapabilities is the subset both sides support.
+type NegotiatedCapabilities struct {
+	Streaming         bool     `json:"streaming"`
+	PushNotifications bool     `json:"pushNotifications"`
+	WebSocket         bool     `json:"websocket"`
+	OutputModes       []string `json:"outputModes"`
+	AuthModes         []string `json:"authModes"`
+	MaxMessageBytes   int64    `json:"maxMessageBytes,omitempty"`
+}
+
+// Negotiate returns the capabilities usable between this agent and the client.
+func (c AgentCapabilities) Negotiate(client ClientCapabilities) NegotiatedCapabilities {
+	return NegotiatedCapabilities{
+		Streaming:         c.Streaming && client.Streaming,
+		PushNotifications: c.PushNotifications && client.PushNotifications,
+		WebSocket:         c.WebSocket && client.WebSocket,
+		OutputModes:       intersectModes(c.OutputModes, client.AcceptOutputModes),
+		AuthModes:         intersectModes(c.AuthModes, client.AuthModes),
+		MaxMessageBytes:   c.MaxMessageBytes,
+	}
+}
+
+// intersectModes keeps the server modes the client accepts; an empty client list accepts all.
+func intersectModes(server, client []string) []string {
+	if len(client) == 0 {
+		return server
+	}
+	accepted := make(map[string]bool, len(client))
+	for _, mode := range client {
+		accepted[mode] = true
+	}
+	result := []string{}
+	for _, mode := range server {
+		if accepted[mode] {
+			result = append(result, mode)
+		}
+	}
+	return result
+}
+
+// AgentNegotiateHandler handles the "agent/negotiate" JSON-RPC method.
+func AgentNegotiateHandler(capabilities AgentCapabilities) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params ClientCapabilities
+		rpcReq, ok := decodeJSONRPCRequest(w, r, &params)
+		if !ok {
+			return
+		}
+		sendJSONRPCResponse(w, rpcReq.ID, capabilities.Negotiate(params), nil)
+	}
+}
@@ -0,0 +1,29 @@
+package a2a
+
+import (
+	"testing"
+)
+
+func TestNegotiate_StreamingRequiresBothSides(t *testing.T) {
+	server := AgentCapabilities{Streaming: true, PushNotifications: false}
+	negotiated := server.Negotiate(ClientCapabilities{Streaming: true, PushNotifications: true})
+	if !negotiated.Streaming {
+		t.Errorf("Expected streaming to be negotiated")
+	}
+	if negotiated.PushNotifications {
+		t.Errorf("Expected push notifications to be off when the agent does not support them")
+	}
+}
+
+func TestNegotiate_OutputModesIntersection(t *testing.T) {
+	server := AgentCapabilities{OutputModes: []string{"text", "file"}}
+	negotiated := server.Negotiate(ClientCapabilities{AcceptOutputModes: []string{"file", "image"}})
+	if len(negotiated.OutputModes) != 1 || negotiated.OutputModes[0] != "file" {
+		t.Errorf("Expected only 'file' output mode, got %v", negotiated.OutputModes)
+	}
+
+	all := server.Negotiate(ClientCapabilities{})
+	if len(all.OutputModes) != 2 {
+		t.Errorf("Expected all output modes when client lists none, got %v", all.OutputModes)
+	}
+}
@@ -0,0 +1,273 @@
+package a2a
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Chat export formats accepted by ParseChatExport.
+const (
+	ChatFormatChatGPT = "chatgpt" // conversations.json of a ChatGPT data export
+	ChatFormatClaude  = "claude"  // conversations.json of a Claude data export
+	ChatFormatOpenAI  = "openai"  // {"messages": [{"role", "content"}]} as used by chat completion APIs
+)
+
+// ImportedConversation is a conversation read from a chat export.
+type ImportedConversation struct {
+	Title     string
+	CreatedAt time.Time
+	Messages  []Message
+}
+
+// ParseChatExport reads the conversations of an export. data may hold a
+// single conversation or an array of them; an empty format detects it.
+func ParseChatExport(data []byte, format string) ([]ImportedConversation, error) {
+	var raw []json.RawMessage
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "[") {
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("failed to parse chat export: %w", err)
+		}
+	} else {
+		raw = []json.RawMessage{data}
+	}
+
+	conversations := make([]ImportedConversation, 0, len(raw))
+	for i, item := range raw {
+		itemFormat := format
+		if itemFormat == "" {
+			itemFormat = detectChatFormat(item)
+		}
+		var conv ImportedConversation
+		var err error
+		switch itemFormat {
+		case ChatFormatChatGPT:
+			conv, err = parseChatGPTConversation(item)
+		case ChatFormatClaude:
+			conv, err = parseClaudeConversation(item)
+		case ChatFormatOpenAI:
+			conv, err = parseOpenAIConversation(item)
+		default:
+			err = fmt.Errorf("unknown chat export format '%s' (expected %s, %s or %s)", itemFormat, ChatFormatChatGPT, ChatFormatClaude, ChatFormatOpenAI)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("conversation %d: %w", i, err)
+		}
+		if len(conv.Messages) == 0 {
+			continue // Nothing to continue from
+		}
+		conversations = append(conversations, conv)
+	}
+	if len(conversations) == 0 {
+		return nil, fmt.Errorf("chat export contains no messages")
+	}
+	return conversations, nil
+}
+
+// detectChatFormat guesses the format of a conversation from its keys.
+func detectChatFormat(data json.RawMessage) string {
+	var keys map[string]json.RawMessage
+	if json.Unmarshal(data, &keys) != nil {
+		return ""
+	}
+	switch {
+	case keys["mapping"] != nil:
+		return ChatFormatChatGPT
+	case keys["chat_messages"] != nil:
+		return ChatFormatClaude
+	case keys["messages"] != nil:
+		return ChatFormatOpenAI
+	}
+	return ""
+}
+
+func textMessage(role MessageRole, text string) Message {
+	return Message{Role: role, Parts: []Part{TextPart{Type: "text", Text: text}}}
+}
+
+// parseChatGPTConversation follows the message tree of a ChatGPT export from
+// its current node back to the root, which yields the visible branch.
+func parseChatGPTConversation(data json.RawMessage) (ImportedConversation, error) {
+	var conv struct {
+		Title       string  `json:"title"`
+		CreateTime  float64 `json:"create_time"`
+		CurrentNode string  `json:"current_node"`
+		Mapping     map[string]struct {
+			Parent  string `json:"parent"`
+			Message *struct {
+				Author struct {
+					Role string `json:"role"`
+				} `json:"author"`
+				Content struct {
+					Parts []interface{} `json:"parts"`
+				} `json:"content"`
+			} `json:"message"`
+		} `json:"mapping"`
+	}
+	if err := json.Unmarshal(data, &conv); err != nil {
+		return ImportedConversation{}, fmt.Errorf("invalid ChatGPT conversation: %w", err)
+	}
+	result := ImportedConversation{Title: conv.Title, CreatedAt: time.Unix(int64(conv.CreateTime), 0).UTC()}
+	var branch []Message
+	seen := map[string]bool{}
+	for id := conv.CurrentNode; id != "" && !seen[id]; id = conv.Mapping[id].Parent {
+		seen[id] = true
+		node := conv.Mapping[id]
+		if node.Message == nil {
+			continue
+		}
+		var texts []string
+		for _, part := range node.Message.Content.Parts {
+			if text, ok := part.(string); ok && strings.TrimSpace(text) != "" {
+				texts = append(texts, text) // Images and other attachments are not imported
+			}
+		}
+		role := MessageRole(node.Message.Author.Role)
+		if len(texts) == 0 || (role != RoleUser && role != RoleAssistant) {
+			continue // Hidden system and tool messages stay behind
+		}
+		branch = append(branch, textMessage(role, strings.Join(texts, "\n\n")))
+	}
+	for i := len(branch) - 1; i >= 0; i-- {
+		result.Messages = append(result.Messages, branch[i])
+	}
+	return result, nil
+}
+
+func parseClaudeConversation(data json.RawMessage) (ImportedConversation, error) {
+	var conv struct {
+		Name         string    `json:"name"`
+		CreatedAt    time.Time `json:"created_at"`
+		ChatMessages []struct {
+			Sender    string    `json:"sender"`
+			Text      string    `json:"text"`
+			CreatedAt time.Time `json:"created_at"`
+			Content   []struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"content"`
+		} `json:"chat_messages"`
+	}
+	if err := json.Unmarshal(data, &conv); err != nil {
+		return ImportedConversation{}, fmt.Errorf("invalid Claude conversation: %w", err)
+	}
+	sort.SliceStable(conv.ChatMessages, func(i, j int) bool {
+		return conv.ChatMessages[i].CreatedAt.Before(conv.ChatMessages[j].CreatedAt)
+	})
+	result := ImportedConversation{Title: conv.Name, CreatedAt: conv.CreatedAt}
+	for _, msg := range conv.ChatMessages {
+		role := RoleAssistant
+		if msg.Sender == "human" {
+			role = RoleUser
+		}
+		text := msg.Text
+		if text == "" {
+			var texts []string
+			for _, block := range msg.Content {
+				if block.Type == "text" && block.Text != "" {
+					texts = append(texts, block.Text)
+				}
+			}
+			text = strings.Join(texts, "\n\n")
+		}
+		if strings.TrimSpace(text) != "" {
+			result.Messages = append(result.Messages, textMessage(role, text))
+		}
+	}
+	return result, nil
+}
+
+func parseOpenAIConversation(data json.RawMessage) (ImportedConversation, error) {
+	var conv struct {
+		Title    string `json:"title"`
+		Messages []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	if err := json.Unmarshal(data, &conv); err != nil {
+		return ImportedConversation{}, fmt.Errorf("invalid chat completion conversation: %w", err)
+	}
+	result := ImportedConversation{Title: conv.Title}
+	for _, msg := range conv.Messages {
+		role := MessageRole(msg.Role)
+		if (role == RoleUser || role == RoleAssistant) && strings.TrimSpace(msg.Content) != "" {
+			result.Messages = append(result.Messages, textMessage(role, msg.Content))
+		}
+	}
+	return result, nil
+}
+
+// ImportChatParams defines the parameters of "tasks/importChat".
+type ImportChatParams struct {
+	Format  string          `json:"format,omitempty"` // chatgpt, claude or openai; detected when empty
+	Data    json.RawMessage `json:"data"`             // The export: one conversation or an array of them
+	Mode    string          `json:"mode,omitempty"`
+	Project string          `json:"project,omitempty"`
+	Labels  []string        `json:"labels,omitempty"`
+}
+
+// ImportedChatTask describes a task created from an imported conversation.
+type ImportedChatTask struct {
+	ID       string `json:"id"`
+	Title    string `json:"title"`
+	Messages int    `json:"messages"`
+}
+
+// TasksImportChatHandler handles "tasks/importChat". Every conversation of
+// the export becomes a COMPLETED task; tasks/addMessage continues it here.
+func TasksImportChatHandler(taskExecutor *TaskExecutor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params ImportChatParams
+		rpcReq, ok := decodeJSONRPCRequest(w, r, &params)
+		if !ok {
+			return
+		}
+		if len(params.Data) == 0 {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: "Invalid Params: missing data"})
+			return
+		}
+		conversations, err := ParseChatExport(params.Data, params.Format)
+		if err != nil {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: fmt.Sprintf("Invalid Params: %v", err)})
+			return
+		}
+		systemPrompt, modeName, projectName, rpcErr := taskExecutor.resolveTaskPrompt(r.Context(), params.Mode, params.Project, params.Labels)
+		if rpcErr != nil {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, rpcErr)
+			return
+		}
+
+		imported := make([]ImportedChatTask, 0, len(conversations))
+		for _, conv := range conversations {
+			title := conv.Title
+			if title == "" {
+				title = "Imported conversation"
+			}
+			task, err := taskExecutor.TaskStore.CreateTask(title, systemPrompt, conv.Messages, "")
+			if err == nil {
+				task, err = taskExecutor.TaskStore.UpdateTask(task.ID, func(t *Task) error {
+					t.State = TaskStateCompleted
+					t.Mode, t.Project, t.Labels = modeName, projectName, params.Labels
+					if !conv.CreatedAt.IsZero() && conv.CreatedAt.Unix() > 0 {
+						t.CreatedAt = conv.CreatedAt
+						t.CreatedAtUnixMs = conv.CreatedAt.UnixNano() / int64(time.Millisecond)
+					}
+					return nil
+				})
+			}
+			if err != nil {
+				log.Printf("[ImportChat %v] Failed to create task for conversation %q: %v", rpcReq.ID, title, err)
+				sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32000, Message: "Internal Server Error: Failed to create task", Data: err.Error()})
+				return
+			}
+			imported = append(imported, ImportedChatTask{ID: task.ID, Title: title, Messages: len(conv.Messages)})
+		}
+		log.Printf("[ImportChat %v] Imported %d conversation(s).", rpcReq.ID, len(imported))
+		sendJSONRPCResponse(w, rpcReq.ID, map[string]interface{}{"tasks": imported}, nil)
+	}
+}
@@ -0,0 +1,86 @@
+package a2a
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+const chatGPTExport = `[{
+	"title": "Plan a trip",
+	"create_time": 1700000000,
+	"current_node": "c",
+	"mapping": {
+		"root": {"parent": "", "message": null},
+		"s": {"parent": "root", "message": {"author": {"role": "system"}, "content": {"parts": [""]}}},
+		"a": {"parent": "s", "message": {"author": {"role": "user"}, "content": {"parts": ["Where should I go?"]}}},
+		"b": {"parent": "a", "message": {"author": {"role": "assistant"}, "content": {"parts": ["Try Lisbon."]}}},
+		"x": {"parent": "a", "message": {"author": {"role": "assistant"}, "content": {"parts": ["Abandoned branch"]}}},
+		"c": {"parent": "b", "message": {"author": {"role": "user"}, "content": {"parts": ["Why?"]}}}
+	}
+}]`
+
+const claudeExport = `{
+	"name": "Refactor",
+	"created_at": "2024-05-01T10:00:00Z",
+	"chat_messages": [
+		{"sender": "assistant", "created_at": "2024-05-01T10:00:05Z", "content": [{"type": "text", "text": "Sure."}]},
+		{"sender": "human", "created_at": "2024-05-01T10:00:01Z", "text": "Refactor this"}
+	]
+}`
+
+func messageTexts(messages []Message) []string {
+	var texts []string
+	for _, m := range messages {
+		texts = append(texts, string(m.Role)+":"+m.Parts[0].(TextPart).Text)
+	}
+	return texts
+}
+
+func TestParseChatExport(t *testing.T) {
+	convs, err := ParseChatExport([]byte(chatGPTExport), "")
+	if err != nil || len(convs) != 1 {
+		t.Fatalf("ParseChatExport(chatgpt): %v, %d conversations", err, len(convs))
+	}
+	got := messageTexts(convs[0].Messages)
+	want := []string{"user:Where should I go?", "assistant:Try Lisbon.", "user:Why?"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Errorf("chatgpt messages = %v, want %v", got, want)
+	}
+
+	convs, err = ParseChatExport([]byte(claudeExport), "")
+	if err != nil || len(convs) != 1 {
+		t.Fatalf("ParseChatExport(claude): %v", err)
+	}
+	if got := messageTexts(convs[0].Messages); len(got) != 2 || got[0] != "user:Refactor this" || got[1] != "assistant:Sure." {
+		t.Errorf("claude messages = %v", got)
+	}
+
+	if _, err := ParseChatExport([]byte(`{"foo": 1}`), ""); err == nil {
+		t.Errorf("expected an unrecognised export to be rejected")
+	}
+}
+
+func TestTasksImportChat(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	te := NewTaskExecutor(&staticLLMClient{reply: "ok"}, store, nil, "Agent prompt")
+
+	resp := callRPC(t, TasksImportChatHandler(te), "tasks/importChat", `{"data": `+chatGPTExport+`}`)
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %+v", resp.Error)
+	}
+	var result struct {
+		Tasks []ImportedChatTask `json:"tasks"`
+	}
+	raw, _ := json.Marshal(resp.Result)
+	json.Unmarshal(raw, &result)
+	if len(result.Tasks) != 1 || result.Tasks[0].Messages != 3 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	task, err := store.GetTask(result.Tasks[0].ID)
+	if err != nil {
+		t.Fatalf("GetTask: %v", err)
+	}
+	if task.Name != "Plan a trip" || task.State != TaskStateCompleted || task.SystemPrompt != "Agent prompt" || len(task.Messages) != 3 {
+		t.Errorf("unexpected imported task %+v", task)
+	}
+}
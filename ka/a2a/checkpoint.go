@@ -0,0 +1,179 @@
+package a2a
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Checkpoint reasons.
+const (
+	CheckpointInterval = "interval" // Saved every CheckpointEvery iterations
+	CheckpointShutdown = "shutdown" // Saved when a shutdown stopped the run
+)
+
+// TaskCheckpoint is the execution state of a task beyond its stored
+// messages: the iteration count and the results of tool calls that finished
+// before a shutdown stopped the run mid-iteration. It lets an agent resuming
+// the task, e.g. another instance on the same store after a preemptible node
+// was reclaimed, continue without repeating those tool calls.
+type TaskCheckpoint struct {
+	Iteration    int       `json:"iteration"`              // Iterations completed across the runs of the task
+	MessageCount int       `json:"message_count"`          // Messages stored when saved; tool results answer the last one
+	ToolResults  []Message `json:"tool_results,omitempty"` // Results of the tool calls of the last assistant message finished so far
+	Reason       string    `json:"reason"`                 // interval or shutdown
+	SavedAt      time.Time `json:"saved_at"`
+}
+
+// runCheckpoints counts the iterations of one run and saves checkpoints.
+// It is nil when checkpoints are disabled.
+type runCheckpoints struct {
+	te        *TaskExecutor
+	taskID    string
+	iteration int
+}
+
+// startCheckpoints continues the iteration count of the task's checkpoint.
+func (te *TaskExecutor) startCheckpoints(taskID string) *runCheckpoints {
+	if te.CheckpointEvery <= 0 {
+		return nil
+	}
+	c := &runCheckpoints{te: te, taskID: taskID}
+	if task, err := te.TaskStore.GetTask(taskID); err == nil && task.Checkpoint != nil {
+		c.iteration = task.Checkpoint.Iteration
+		log.Printf("[Task %s] Resuming from the %s checkpoint of %s at iteration %d.", taskID, task.Checkpoint.Reason, task.Checkpoint.SavedAt.Format(time.RFC3339), c.iteration)
+	}
+	return c
+}
+
+// iterationDone counts a finished iteration and saves a checkpoint every
+// CheckpointEvery iterations.
+func (c *runCheckpoints) iterationDone() {
+	if c == nil {
+		return
+	}
+	c.iteration++
+	if c.iteration%c.te.CheckpointEvery == 0 {
+		c.save(CheckpointInterval)
+	}
+}
+
+// saveIfShutDown saves a checkpoint when a shutdown stopped the run, keeping
+// the tool results saved during the interrupted iteration.
+func (c *runCheckpoints) saveIfShutDown(ctx context.Context) {
+	if c != nil && errors.Is(context.Cause(ctx), errShutdown) {
+		c.save(CheckpointShutdown)
+	}
+}
+
+func (c *runCheckpoints) save(reason string) {
+	_, err := c.te.TaskStore.UpdateTask(c.taskID, func(t *Task) error {
+		checkpoint := &TaskCheckpoint{Iteration: c.iteration, MessageCount: len(t.Messages), Reason: reason, SavedAt: time.Now().UTC()}
+		if t.Checkpoint != nil && t.Checkpoint.MessageCount == len(t.Messages) {
+			checkpoint.ToolResults = t.Checkpoint.ToolResults
+		}
+		t.Checkpoint = checkpoint
+		return nil
+	})
+	if err != nil {
+		log.Printf("[Task %s] Failed to save checkpoint: %v", c.taskID, err)
+	}
+}
+
+// checkpointToolResults saves the results of the tool calls that finished
+// before a shutdown stopped the run, so the resumed run dispatches only the
+// rest. It reports whether the run was shut down; the result of the call
+// dispatched during the shutdown is dropped, as the call did not finish.
+func (te *TaskExecutor) checkpointToolResults(ctx context.Context, taskID string, results []Message) bool {
+	if te.CheckpointEvery <= 0 || !errors.Is(context.Cause(ctx), errShutdown) {
+		return false
+	}
+	_, err := te.TaskStore.UpdateTask(taskID, func(t *Task) error {
+		checkpoint := &TaskCheckpoint{Reason: CheckpointShutdown}
+		if t.Checkpoint != nil {
+			*checkpoint = *t.Checkpoint
+		}
+		checkpoint.MessageCount = len(t.Messages)
+		checkpoint.ToolResults = results
+		checkpoint.SavedAt = time.Now().UTC()
+		t.Checkpoint = checkpoint
+		return nil
+	})
+	if err != nil {
+		log.Printf("[Task %s] Failed to checkpoint tool results: %v", taskID, err)
+	} else {
+		log.Printf("[Task %s] Shutdown during tool calls. Checkpointed %d finished tool result(s).", taskID, len(results))
+	}
+	return true
+}
+
+// resumeToolCalls finishes the tool calls of an assistant message that a
+// previous run left without results, instead of calling the LLM again: the
+// results saved in the checkpoint are used and only the remaining calls are
+// dispatched. It reports whether it handled the iteration, and then whether
+// the loop continues. Only runs with checkpoints enabled resume this way.
+func (te *TaskExecutor) resumeToolCalls(ctx context.Context, task *Task) (handled, continueLoop bool, err error) {
+	if te.CheckpointEvery <= 0 || task.Debug || len(task.Messages) == 0 {
+		return false, false, nil
+	}
+	last := task.Messages[len(task.Messages)-1]
+	if last.Role != RoleAssistant || len(last.ParsedToolCalls) == 0 {
+		return false, false, nil
+	}
+	for _, toolCall := range last.ParsedToolCalls {
+		if toolCall.Function.Name == "ask_followup_question" {
+			return false, false, nil // Parked for input, answered by the next user message
+		}
+	}
+	saved := make(map[string]Message)
+	var toolResults []Message
+	if task.Checkpoint != nil && task.Checkpoint.MessageCount == len(task.Messages) {
+		for _, result := range task.Checkpoint.ToolResults {
+			saved[result.ToolCallID] = result
+		}
+	}
+
+	log.Printf("[Task %s] Resuming %d tool call(s) of the last assistant message, %d finished before the checkpoint.", task.ID, len(last.ParsedToolCalls), len(saved))
+	toolDispatcher := te.toolDispatcher()
+	toolStart := time.Now()
+	for _, toolCall := range last.ParsedToolCalls {
+		if result, ok := saved[toolCall.ID]; ok {
+			toolResults = append(toolResults, result)
+			continue
+		}
+		toolResultMsg, dispatchErr := toolDispatcher.DispatchToolCall(ctx, task.ID, toolCall)
+		if te.checkpointToolResults(ctx, task.ID, toolResults) {
+			return true, false, nil
+		}
+		if dispatchErr != nil {
+			log.Printf("[Task %s] Error dispatching tool call %s (%s): %v", task.ID, toolCall.ID, toolCall.Function.Name, dispatchErr)
+		}
+		toolResults = append(toolResults, toolResultMsg)
+	}
+	te.addExecutionTime(task.ID, 0, time.Since(toolStart))
+	for _, resMsg := range toolResults {
+		te.createRequestedSubTask(fmt.Sprintf("[Task %s]", task.ID), task, resMsg)
+	}
+
+	_, err = te.TaskStore.UpdateTask(task.ID, func(t *Task) error {
+		t.Messages = append(t.Messages, toolResults...)
+		if t.Checkpoint != nil {
+			t.Checkpoint.ToolResults = nil
+		}
+		setTaskError(t, nil)
+		return nil
+	})
+	if err != nil {
+		te.TaskStore.SetState(task.ID, TaskStateFailed)
+		return true, false, err
+	}
+	if te.hasPendingApproval(task.ID) {
+		return true, false, te.TaskStore.SetState(task.ID, TaskStateInputRequired)
+	}
+	if te.parkForEvent(task.ID, last.ParsedToolCalls) {
+		return true, false, nil
+	}
+	return true, true, nil
+}
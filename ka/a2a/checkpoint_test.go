@@ -0,0 +1,91 @@
+package a2a
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ka/tools"
+)
+
+// countingTool counts its calls; while block is set, it runs until its
+// context is cancelled.
+type countingTool struct {
+	name    string
+	calls   atomic.Int32
+	block   atomic.Bool
+	started chan struct{}
+}
+
+func (c *countingTool) GetName() string          { return c.name }
+func (c *countingTool) GetDescription() string   { return "" }
+func (c *countingTool) GetXMLDefinition() string { return "" }
+func (c *countingTool) Execute(ctx context.Context, callDetails tools.FunctionCall) (string, error) {
+	c.calls.Add(1)
+	if c.block.Load() {
+		close(c.started)
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+	return c.name + " done", nil
+}
+
+func TestCheckpointResumesUnfinishedToolCalls(t *testing.T) {
+	store, err := NewFileTaskStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &scriptedLLMClient{replies: []string{`<tool id="note">{"text": "a"}</tool><tool id="deploy">{}</tool>`, "Done."}}
+	note := &countingTool{name: "note"}
+	deploy := &countingTool{name: "deploy", started: make(chan struct{})}
+	deploy.block.Store(true)
+	toolMap := map[string]tools.Tool{"note": note, "deploy": deploy}
+	te := NewTaskExecutor(client, store, toolMap, "")
+	te.CheckpointEvery = 1
+
+	task, _ := store.CreateTask("spot", "", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "ship it"}}}}, "")
+	go te.ExecuteTask(context.Background(), task)
+	<-deploy.started
+
+	// The node is reclaimed while deploy runs; note has finished
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	te.Shutdown(ctx)
+	interrupted, _ := store.GetTask(task.ID)
+	if interrupted.State != TaskStateInterrupted {
+		t.Fatalf("expected the task to be interrupted, got %s", interrupted.State)
+	}
+	checkpoint := interrupted.Checkpoint
+	if checkpoint == nil || checkpoint.Reason != CheckpointShutdown || len(checkpoint.ToolResults) != 1 || checkpoint.ToolResults[0].ToolCallID != "note-0" {
+		t.Fatalf("expected a shutdown checkpoint with the result of note, got %+v", checkpoint)
+	}
+
+	// Another instance on the same store resumes without calling the LLM or note again
+	deploy.block.Store(false)
+	restarted := NewTaskExecutor(client, store, toolMap, "")
+	restarted.CheckpointEvery = 1
+	if resumed, err := restarted.ResumeInterrupted(); err != nil || len(resumed) != 1 {
+		t.Fatalf("expected the task to be resumed, got %v, %v", resumed, err)
+	}
+	waitForState(t, store, task.ID, TaskStateCompleted)
+	for deadline := time.Now().Add(5 * time.Second); restarted.IsRunning(task.ID) && time.Now().Before(deadline); {
+		time.Sleep(5 * time.Millisecond) // The checkpoint of the final iteration is saved after the completion
+	}
+	if note.calls.Load() != 1 || deploy.calls.Load() != 2 || client.calls.Load() != 2 {
+		t.Errorf("expected only deploy to run again, got note=%d deploy=%d llm=%d", note.calls.Load(), deploy.calls.Load(), client.calls.Load())
+	}
+	completed, _ := store.GetTask(task.ID)
+	var toolResults []string
+	for _, message := range completed.Messages {
+		if message.ToolCallID != "" {
+			toolResults = append(toolResults, message.ToolCallID)
+		}
+	}
+	if len(toolResults) != 2 || toolResults[0] != "note-0" || toolResults[1] != "deploy-1" {
+		t.Errorf("expected one result per tool call in order, got %v", toolResults)
+	}
+	if completed.Checkpoint == nil || completed.Checkpoint.Iteration != 2 || len(completed.Checkpoint.ToolResults) != 0 {
+		t.Errorf("expected the checkpoint to count the resumed and final iterations, got %+v", completed.Checkpoint)
+	}
+}
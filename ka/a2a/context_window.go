@@ -0,0 +1,266 @@
+package a2a
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"ka/llm"
+)
+
+// Context strategies, selected with -context-strategy.
+const (
+	ContextStrategyTruncate      = "truncate"       // Drop the oldest turns
+	ContextStrategySlidingWindow = "sliding_window" // Keep only the most recent turns
+	ContextStrategySummarize     = "summarize"      // Replace the oldest turns with an LLM summary
+)
+
+// DefaultContextKeepRecent is how many of the latest messages a context
+// strategy never drops or summarizes.
+const DefaultContextKeepRecent = 6
+
+// messageTokenOverhead approximates the tokens of the role and separators
+// the provider adds to each message.
+const messageTokenOverhead = 4
+
+const historySummaryPrompt = "You summarize the earlier part of an agent's conversation so the agent can continue its task without it. Keep every fact, decision, file path, command, identifier and tool result the agent may still need, and what is left to do. Leave out pleasantries and repetition. Answer with the summary only."
+
+// HistorySummary is the summary that replaces the oldest turns of a task in
+// its prompt, kept so later iterations summarize only what was added since.
+type HistorySummary struct {
+	Text      string    `json:"text"`
+	Messages  int       `json:"messages"` // Prompt messages after the head that it covers
+	Hash      string    `json:"hash"`     // Of the covered messages, to detect a changed history
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ContextFit is the prompt of an iteration to fit into the context window.
+type ContextFit struct {
+	TaskID     string
+	Messages   []llm.Message // Starts with the system prompt
+	Budget     int           // Tokens the prompt may use
+	KeepRecent int           // Latest messages that stay intact
+	Client     llm.LLMClient // Counts tokens and writes summaries
+
+	// Summary is the summary of an earlier iteration. Strategies that
+	// summarize replace it, and add the tokens of their LLM calls.
+	Summary          *HistorySummary
+	PromptTokens     int
+	CompletionTokens int
+}
+
+// ContextStrategy shortens the prompt of a task iteration that does not fit
+// into the context window. The system prompt, the first user message, which
+// states the task, and the KeepRecent latest messages are always kept, so
+// recent tool results are never lost.
+type ContextStrategy interface {
+	Fit(ctx context.Context, fit *ContextFit) ([]llm.Message, error)
+}
+
+// NewContextStrategy returns the strategy of the name; keepRecent is the
+// window of the sliding window strategy.
+func NewContextStrategy(name string, keepRecent int) (ContextStrategy, error) {
+	switch name {
+	case ContextStrategyTruncate:
+		return TruncateStrategy{}, nil
+	case ContextStrategySlidingWindow:
+		return SlidingWindowStrategy{Messages: keepRecent}, nil
+	case ContextStrategySummarize:
+		return SummarizeStrategy{}, nil
+	default:
+		return nil, fmt.Errorf("unknown context strategy '%s' (use %s, %s or %s)", name, ContextStrategyTruncate, ContextStrategySlidingWindow, ContextStrategySummarize)
+	}
+}
+
+// messageTokens counts the tokens of messages.
+func (f *ContextFit) messageTokens(messages []llm.Message) int {
+	total := 0
+	for _, message := range messages {
+		n, _ := llm.CountTokens(f.Client, message.Content)
+		total += n + messageTokenOverhead
+	}
+	return total
+}
+
+// split returns the protected head (system messages and the first user
+// message), the middle that may be shortened and the protected tail of
+// recent messages. The tail does not start with a tool result, so results
+// stay with the assistant message that called the tool.
+func (f *ContextFit) split() (head, middle, tail []llm.Message) {
+	messages := f.Messages
+	headEnd := 0
+	for headEnd < len(messages) && messages[headEnd].Role == string(RoleSystem) {
+		headEnd++
+	}
+	if headEnd < len(messages) && messages[headEnd].Role == string(RoleUser) {
+		headEnd++
+	}
+	keep := f.KeepRecent
+	if keep <= 0 {
+		keep = DefaultContextKeepRecent
+	}
+	tailStart := max(len(messages)-keep, headEnd)
+	for tailStart > headEnd && messages[tailStart].Role == string(RoleTool) {
+		tailStart--
+	}
+	return messages[:headEnd], messages[headEnd:tailStart], messages[tailStart:]
+}
+
+// TruncateStrategy drops the oldest turns until the prompt fits.
+type TruncateStrategy struct{}
+
+func (TruncateStrategy) Fit(ctx context.Context, fit *ContextFit) ([]llm.Message, error) {
+	head, middle, tail := fit.split()
+	return truncateMiddle(fit, head, middle, tail), nil
+}
+
+// truncateMiddle drops messages from the start of middle until the prompt
+// fits, keeping tool results with their calls.
+func truncateMiddle(fit *ContextFit, head, middle, tail []llm.Message) []llm.Message {
+	fixed := fit.messageTokens(head) + fit.messageTokens(tail)
+	tokens := fixed + fit.messageTokens(middle)
+	for len(middle) > 0 && tokens > fit.Budget {
+		tokens -= fit.messageTokens(middle[:1])
+		middle = middle[1:]
+		for len(middle) > 0 && middle[0].Role == string(RoleTool) {
+			tokens -= fit.messageTokens(middle[:1])
+			middle = middle[1:]
+		}
+	}
+	if tokens > fit.Budget {
+		log.Printf("[Task %s] The protected messages alone use %d tokens of the context budget of %d.", fit.TaskID, tokens, fit.Budget)
+	}
+	return joinMessages(head, middle, tail)
+}
+
+// SlidingWindowStrategy keeps only the first user message and the latest
+// Messages messages, also when the prompt would fit, and truncates those
+// further if it still does not.
+type SlidingWindowStrategy struct {
+	Messages int
+}
+
+func (s SlidingWindowStrategy) Fit(ctx context.Context, fit *ContextFit) ([]llm.Message, error) {
+	if s.Messages > 0 {
+		fit.KeepRecent = s.Messages
+	}
+	head, _, tail := fit.split()
+	if fit.messageTokens(head)+fit.messageTokens(tail) <= fit.Budget {
+		return joinMessages(head, nil, tail), nil
+	}
+	// Drop the oldest messages of the window instead
+	fit.KeepRecent = 1
+	head, middle, last := fit.split()
+	return truncateMiddle(fit, head, middle, last), nil
+}
+
+// SummarizeStrategy replaces the oldest turns with a summary written by the
+// LLM. The summary is kept with the task and extended with the turns that
+// dropped out of the recent messages since, when the prompt no longer fits.
+type SummarizeStrategy struct{}
+
+func (SummarizeStrategy) Fit(ctx context.Context, fit *ContextFit) ([]llm.Message, error) {
+	if fit.messageTokens(fit.Messages) <= fit.Budget {
+		return fit.Messages, nil
+	}
+	head, middle, tail := fit.split()
+	if len(middle) == 0 {
+		return fit.Messages, nil
+	}
+
+	// Reuse the summary if the history it covers is unchanged
+	summary := fit.Summary
+	if summary != nil && (summary.Messages > len(middle) || summary.Hash != hashMessages(middle[:summary.Messages])) {
+		summary = nil
+	}
+	covered := 0
+	if summary != nil {
+		covered = summary.Messages
+		prompt := joinMessages(head, append([]llm.Message{summaryMessage(summary.Text)}, middle[covered:]...), tail)
+		if fit.messageTokens(prompt) <= fit.Budget || covered == len(middle) {
+			return prompt, nil
+		}
+	}
+
+	var transcript strings.Builder
+	if summary != nil {
+		transcript.WriteString("Summary of the conversation so far:\n" + summary.Text + "\n\nThe conversation continued:\n")
+	}
+	for _, message := range middle[covered:] {
+		fmt.Fprintf(&transcript, "\n[%s]\n%s\n", message.Role, message.Content)
+	}
+	request := []llm.Message{
+		{Role: string(RoleSystem), Content: historySummaryPrompt},
+		{Role: string(RoleUser), Content: transcript.String()},
+	}
+	text, promptTokens, completionTokens, err := fit.Client.Chat(ctx, request, false, io.Discard)
+	fit.PromptTokens += promptTokens
+	fit.CompletionTokens += completionTokens
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize the conversation history: %w", err)
+	}
+	fit.Summary = &HistorySummary{Text: strings.TrimSpace(text), Messages: len(middle), Hash: hashMessages(middle), UpdatedAt: time.Now().UTC()}
+	log.Printf("[Task %s] Summarized %d earlier messages of the conversation.", fit.TaskID, len(middle))
+
+	prompt := joinMessages(head, []llm.Message{summaryMessage(fit.Summary.Text)}, tail)
+	if fit.messageTokens(prompt) > fit.Budget {
+		log.Printf("[Task %s] The prompt exceeds the context budget of %d tokens even with the summary.", fit.TaskID, fit.Budget)
+	}
+	return prompt, nil
+}
+
+func summaryMessage(text string) llm.Message {
+	return llm.Message{Role: string(RoleUser), Content: "Summary of the earlier conversation, which was shortened to fit the context window:\n\n" + text}
+}
+
+func hashMessages(messages []llm.Message) string {
+	h := sha256.New()
+	for _, message := range messages {
+		fmt.Fprintf(h, "%s\x00%s\x00", message.Role, message.Content)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func joinMessages(parts ...[]llm.Message) []llm.Message {
+	var joined []llm.Message
+	for _, part := range parts {
+		joined = append(joined, part...)
+	}
+	return joined
+}
+
+// fitContext applies the executor's context strategy to the prompt of an
+// iteration, saving a new history summary and counting the tokens spent on
+// it. On an error the prompt is sent as it is. It returns the error of a task
+// that the summary took over its token budget.
+func (te *TaskExecutor) fitContext(ctx context.Context, task *Task, client llm.LLMClient, messages []llm.Message) ([]llm.Message, *ErrorDetail) {
+	if te.ContextStrategy == nil || te.ContextWindow <= 0 {
+		return messages, nil
+	}
+	fit := &ContextFit{TaskID: task.ID, Messages: messages, Budget: te.ContextWindow, KeepRecent: te.ContextKeepRecent, Client: client, Summary: task.HistorySummary}
+	fitted, err := te.ContextStrategy.Fit(ctx, fit)
+	if fit.PromptTokens > 0 || fit.CompletionTokens > 0 {
+		if exceeded := te.addTokenUsage(task.ID, fit.PromptTokens, fit.CompletionTokens); exceeded != nil {
+			return nil, exceeded
+		}
+	}
+	if err != nil {
+		log.Printf("[Task %s] Context strategy failed, sending the whole prompt: %v", task.ID, err)
+		return messages, nil
+	}
+	if fit.Summary != task.HistorySummary {
+		te.TaskStore.UpdateTask(task.ID, func(t *Task) error {
+			t.HistorySummary = fit.Summary
+			return nil
+		})
+	}
+	if len(fitted) != len(messages) {
+		log.Printf("[Task %s] Fitted the prompt into %d tokens: %d of %d messages.", task.ID, te.ContextWindow, len(fitted), len(messages))
+	}
+	return fitted, nil
+}
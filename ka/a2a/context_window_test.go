@@ -0,0 +1,109 @@
+package a2a
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"ka/llm"
+)
+
+// longHistory returns a prompt of a task that called a tool n times.
+func longHistory(n int) []llm.Message {
+	filler := strings.Repeat("x", 400)
+	messages := []llm.Message{{Role: "system", Content: "You are an agent."}, {Role: "user", Content: "Do the task."}}
+	for i := 0; i < n; i++ {
+		messages = append(messages,
+			llm.Message{Role: "assistant", Content: fmt.Sprintf("call %d %s", i, filler)},
+			llm.Message{Role: "tool", Content: fmt.Sprintf("result %d %s", i, filler)})
+	}
+	return messages
+}
+
+func contents(messages []llm.Message) []string {
+	var heads []string
+	for _, message := range messages {
+		heads = append(heads, strings.SplitN(message.Content, " x", 2)[0])
+	}
+	return heads
+}
+
+func TestTruncateStrategyKeepsTaskAndRecentToolResults(t *testing.T) {
+	client := &scriptedLLMClient{replies: []string{"unused"}}
+	fit := &ContextFit{Messages: longHistory(6), KeepRecent: 3, Client: client}
+	fit.Budget = fit.messageTokens(fit.Messages[:2]) + fit.messageTokens(fit.Messages[2:4])*5/2
+
+	fitted, err := TruncateStrategy{}.Fit(context.Background(), fit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The tail of 3 starts at a tool result, so it takes its call along
+	want := "[You are an agent. Do the task. call 4 result 4 call 5 result 5]"
+	if got := fmt.Sprint(contents(fitted)); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if client.calls.Load() != 0 {
+		t.Errorf("expected truncation not to call the LLM")
+	}
+}
+
+func TestSlidingWindowStrategyKeepsOnlyLatestMessages(t *testing.T) {
+	fit := &ContextFit{Messages: longHistory(3), Budget: 1 << 20, Client: &scriptedLLMClient{}}
+	fitted, _ := SlidingWindowStrategy{Messages: 2}.Fit(context.Background(), fit)
+	want := "[You are an agent. Do the task. call 2 result 2]"
+	if got := fmt.Sprint(contents(fitted)); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+}
+
+func TestSummarizeStrategyReusesSummary(t *testing.T) {
+	client := &tokenReportingClient{scriptedLLMClient{replies: []string{"Called the tool 4 times."}}}
+	fit := &ContextFit{TaskID: "t", Messages: longHistory(6), KeepRecent: 4, Client: client}
+	fit.Budget = fit.messageTokens(fit.Messages[:2]) + fit.messageTokens(fit.Messages[2:4])*3
+
+	fitted, err := SummarizeStrategy{}.Fit(context.Background(), fit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fitted) != 7 || !strings.Contains(fitted[2].Content, "Called the tool 4 times.") || !strings.HasPrefix(fitted[3].Content, "call 4") {
+		t.Fatalf("expected the first 4 calls to be summarized, got %v", contents(fitted))
+	}
+	if fit.Summary == nil || fit.Summary.Messages != 8 || fit.PromptTokens != 12 || client.calls.Load() != 1 {
+		t.Fatalf("expected a summary of 8 messages made with one call, got %+v after %d calls", fit.Summary, client.calls.Load())
+	}
+
+	// One more tool call: the summary still fits with the turn that left the tail
+	next := &ContextFit{TaskID: "t", Messages: longHistory(7), KeepRecent: 4, Client: client, Budget: fit.Budget + fit.messageTokens(fit.Messages[2:4]), Summary: fit.Summary}
+	fitted, _ = SummarizeStrategy{}.Fit(context.Background(), next)
+	if client.calls.Load() != 1 || len(fitted) != 9 || !strings.HasPrefix(fitted[3].Content, "call 4") {
+		t.Errorf("expected the summary to be reused, got %v after %d calls", contents(fitted), client.calls.Load())
+	}
+
+	// A changed history is summarized again
+	changed := longHistory(7)
+	changed[2].Content = "rewritten"
+	next = &ContextFit{TaskID: "t", Messages: changed, KeepRecent: 4, Client: client, Budget: fit.Budget, Summary: fit.Summary}
+	SummarizeStrategy{}.Fit(context.Background(), next)
+	if client.calls.Load() != 2 || next.Summary.Messages != 10 {
+		t.Errorf("expected a new summary of the changed history, got %+v after %d calls", next.Summary, client.calls.Load())
+	}
+}
+
+func TestFitContextStoresSummaryOnTask(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	client := &tokenReportingClient{scriptedLLMClient{replies: []string{"Summary."}}}
+	te := NewTaskExecutor(client, store, nil, "")
+	te.ContextStrategy = SummarizeStrategy{}
+	te.ContextWindow = 300
+	task, _ := store.CreateTask("long", "", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "go"}}}}, "")
+
+	messages, exceeded := te.fitContext(context.Background(), task, client, longHistory(6))
+	if exceeded != nil || len(messages) >= len(longHistory(6)) {
+		t.Fatalf("expected the prompt to be shortened, got %d messages, %v", len(messages), exceeded)
+	}
+	task, _ = store.GetTask(task.ID)
+	if task.HistorySummary == nil || task.HistorySummary.Text != "Summary." || task.PromptTokens != 12 || task.CompletionTokens != 3 {
+		t.Errorf("expected the summary and its tokens to be stored, got %+v, %d+%d tokens", task.HistorySummary, task.PromptTokens, task.CompletionTokens)
+	}
+}
@@ -0,0 +1,257 @@
+package a2a
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// Limits of the prompt rendering of a DataPart. Larger data is cut and
+// stored in full as an artifact the model is pointed to.
+const (
+	maxDataPartRows      = 20   // Table rows or object keys
+	maxDataPartCellChars = 80   // Characters per table cell or value
+	maxDataPartBytes     = 4000 // Size of the whole rendering
+)
+
+// renderedDataPart is the prompt text of a DataPart.
+type renderedDataPart struct {
+	text      string
+	truncated bool // Rows, keys or cells were left out
+}
+
+// renderDataPart converts JSON or CSV data into a compact markdown table, a
+// key-value list or, failing both, compact JSON.
+func renderDataPart(p DataPart) renderedDataPart {
+	value := dataPartValue(p)
+	var r renderedDataPart
+	switch v := value.(type) {
+	case [][]string:
+		r = renderRows(v[0], v[1:])
+	case []interface{}:
+		if columns, rows, ok := recordsToRows(v); ok {
+			r = renderRows(columns, rows)
+		} else {
+			r = renderList(v)
+		}
+	case map[string]interface{}:
+		r = renderKeyValues(v)
+	default:
+		r.text = dataCell(v)
+	}
+	if len(r.text) > maxDataPartBytes {
+		r.text = truncateOutput(r.text, maxDataPartBytes)
+		r.truncated = true
+	}
+	return r
+}
+
+// dataPartValue decodes string data of JSON and CSV parts. CSV becomes a
+// [][]string with the header row first.
+func dataPartValue(p DataPart) interface{} {
+	text, ok := p.Data.(string)
+	if !ok {
+		return p.Data
+	}
+	switch {
+	case strings.Contains(p.MimeType, "csv"):
+		records, err := csv.NewReader(strings.NewReader(text)).ReadAll()
+		if err == nil && len(records) > 0 {
+			return records
+		}
+	case strings.Contains(p.MimeType, "json"):
+		var value interface{}
+		if json.Unmarshal([]byte(text), &value) == nil {
+			return value
+		}
+	}
+	return text
+}
+
+// recordsToRows converts an array of objects into table rows, with the
+// union of the keys as sorted columns.
+func recordsToRows(records []interface{}) ([]string, [][]string, bool) {
+	keys := map[string]bool{}
+	for _, record := range records {
+		object, ok := record.(map[string]interface{})
+		if !ok {
+			return nil, nil, false
+		}
+		for key := range object {
+			keys[key] = true
+		}
+	}
+	if len(records) == 0 || len(keys) == 0 {
+		return nil, nil, false
+	}
+	columns := make([]string, 0, len(keys))
+	for key := range keys {
+		columns = append(columns, key)
+	}
+	sort.Strings(columns)
+	rows := make([][]string, len(records))
+	for i, record := range records {
+		object := record.(map[string]interface{})
+		for _, column := range columns {
+			cell := ""
+			if value, ok := object[column]; ok {
+				cell = dataCell(value)
+			}
+			rows[i] = append(rows[i], cell)
+		}
+	}
+	return columns, rows, true
+}
+
+func renderRows(columns []string, rows [][]string) renderedDataPart {
+	var r renderedDataPart
+	escape := func(cells []string) string {
+		escaped := make([]string, len(cells))
+		for i, cell := range cells {
+			if len(cell) > maxDataPartCellChars {
+				cell = truncateCell(cell)
+				r.truncated = true
+			}
+			escaped[i] = strings.ReplaceAll(strings.ReplaceAll(cell, "|", `\|`), "\n", " ")
+		}
+		return "| " + strings.Join(escaped, " | ") + " |"
+	}
+	lines := []string{escape(columns), "|" + strings.Repeat(" --- |", len(columns))}
+	for i, row := range rows {
+		if i == maxDataPartRows {
+			lines = append(lines, fmt.Sprintf("(%d more rows)", len(rows)-i))
+			r.truncated = true
+			break
+		}
+		lines = append(lines, escape(row))
+	}
+	r.text = strings.Join(lines, "\n")
+	return r
+}
+
+func renderKeyValues(object map[string]interface{}) renderedDataPart {
+	var r renderedDataPart
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var lines []string
+	for i, key := range keys {
+		if i == maxDataPartRows {
+			lines = append(lines, fmt.Sprintf("(%d more keys)", len(keys)-i))
+			r.truncated = true
+			break
+		}
+		value := dataCell(object[key])
+		if len(value) > maxDataPartCellChars {
+			value = truncateCell(value)
+			r.truncated = true
+		}
+		lines = append(lines, fmt.Sprintf("- %s: %s", key, value))
+	}
+	r.text = strings.Join(lines, "\n")
+	return r
+}
+
+func renderList(items []interface{}) renderedDataPart {
+	var r renderedDataPart
+	var lines []string
+	for i, item := range items {
+		if i == maxDataPartRows {
+			lines = append(lines, fmt.Sprintf("(%d more items)", len(items)-i))
+			r.truncated = true
+			break
+		}
+		value := dataCell(item)
+		if len(value) > maxDataPartCellChars {
+			value = truncateCell(value)
+			r.truncated = true
+		}
+		lines = append(lines, "- "+value)
+	}
+	r.text = strings.Join(lines, "\n")
+	return r
+}
+
+// dataCell formats a value compactly: strings as they are, everything else as JSON.
+func dataCell(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	out, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(out)
+}
+
+func truncateCell(cell string) string {
+	cut := maxDataPartCellChars
+	for cut > 0 && !isRuneStart(cell[cut]) {
+		cut--
+	}
+	return cell[:cut] + "…"
+}
+
+// dataPartArtifactID is the ID of the artifact holding the full data of
+// part j of message i.
+func dataPartArtifactID(messageIndex, partIndex int) string {
+	return fmt.Sprintf("data-%d-%d", messageIndex, partIndex)
+}
+
+// dataPartPromptText renders a DataPart for the prompt, pointing to the
+// artifact with the full data when the rendering was cut.
+func dataPartPromptText(p DataPart, messageIndex, partIndex int) string {
+	header := fmt.Sprintf("[Data: %s]", p.MimeType)
+	if p.Data == nil {
+		return header
+	}
+	r := renderDataPart(p)
+	if r.truncated {
+		return fmt.Sprintf("%s\n%s\n[Data truncated. The full data is in artifact %s.]", header, r.text, dataPartArtifactID(messageIndex, partIndex))
+	}
+	return header + "\n" + r.text
+}
+
+// spillDataParts stores the full data of DataParts too large for the prompt
+// as artifacts, once per part.
+func spillDataParts(store TaskStore, task *Task) {
+	for i, msg := range task.Messages {
+		for j, part := range msg.Parts {
+			p, ok := part.(DataPart)
+			if !ok {
+				continue
+			}
+			id := dataPartArtifactID(i, j)
+			if _, exists := task.Artifacts[id]; exists || !renderDataPart(p).truncated {
+				continue
+			}
+			data, ok := p.Data.(string)
+			raw := []byte(data)
+			if !ok {
+				raw, _ = json.MarshalIndent(p.Data, "", "  ")
+			}
+			mimeType := p.MimeType
+			if mimeType == "" {
+				mimeType = "application/json"
+			}
+			if err := store.AddArtifact(task.ID, Artifact{ID: id, Type: mimeType, Filename: id + dataPartExtension(mimeType), Data: raw}); err != nil {
+				log.Printf("[Task %s] Failed to store data part %d of message %d as an artifact: %v", task.ID, j, i, err)
+			}
+		}
+	}
+}
+
+func dataPartExtension(mimeType string) string {
+	switch {
+	case strings.Contains(mimeType, "csv"):
+		return ".csv"
+	case strings.Contains(mimeType, "json"):
+		return ".json"
+	}
+	return ".txt"
+}
@@ -0,0 +1,76 @@
+package a2a
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestRenderDataPart(t *testing.T) {
+	cases := []struct {
+		name string
+		part DataPart
+		want string
+	}{
+		{
+			"records",
+			DataPart{MimeType: "application/json", Data: []interface{}{
+				map[string]interface{}{"name": "a|b", "size": 1.0},
+				map[string]interface{}{"name": "c"},
+			}},
+			"| name | size |\n| --- | --- |\n| a\\|b | 1 |\n| c |  |",
+		},
+		{
+			"csv string",
+			DataPart{MimeType: "text/csv", Data: "city,pop\nOslo,700000\n"},
+			"| city | pop |\n| --- | --- |\n| Oslo | 700000 |",
+		},
+		{
+			"object",
+			DataPart{MimeType: "application/json", Data: `{"b": [1, 2], "a": "x"}`},
+			"- a: x\n- b: [1,2]",
+		},
+		{
+			"scalars",
+			DataPart{MimeType: "application/json", Data: []interface{}{"x", 2.0}},
+			"- x\n- 2",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := renderDataPart(tc.part)
+			if r.text != tc.want || r.truncated {
+				t.Errorf("got %q (truncated %v), want %q", r.text, r.truncated, tc.want)
+			}
+		})
+	}
+}
+
+func TestLargeDataPartsSpillToArtifacts(t *testing.T) {
+	var rows []interface{}
+	for i := 0; i < 50; i++ {
+		rows = append(rows, map[string]interface{}{"id": fmt.Sprint(i)})
+	}
+	part := DataPart{Type: "data", MimeType: "application/json", Data: rows}
+
+	text := dataPartPromptText(part, 0, 1)
+	if !strings.Contains(text, "(30 more rows)") || !strings.Contains(text, "artifact data-0-1") {
+		t.Errorf("expected a cut table pointing to the artifact, got %q", text)
+	}
+
+	store := NewInMemoryTaskStore()
+	task, _ := store.CreateTask("t", "", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "Sum:"}, part}}}, "")
+	spillDataParts(store, task)
+	task, _ = store.GetTask(task.ID)
+	artifact, ok := task.Artifacts["data-0-1"]
+	if !ok || !strings.Contains(string(artifact.Data), `"id": "49"`) {
+		t.Fatalf("expected the full data in artifact data-0-1, got %+v", task.Artifacts)
+	}
+
+	// Small parts are not spilled.
+	small, _ := store.CreateTask("s", "", []Message{{Role: RoleUser, Parts: []Part{DataPart{Type: "data", MimeType: "application/json", Data: rows[:2]}}}}, "")
+	spillDataParts(store, small)
+	if small, _ = store.GetTask(small.ID); len(small.Artifacts) != 0 {
+		t.Errorf("expected no artifacts, got %v", small.Artifacts)
+	}
+}
@@ -0,0 +1,403 @@
+package a2a
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Defaults of outbound deliveries.
+const (
+	DefaultDeliveryAttempts = 3
+	DefaultDeliveryBackoff  = 2 * time.Second
+	deliveryTimeout         = 30 * time.Second
+)
+
+// errDeadLetterNotFound is returned for dead letter IDs not in the queue.
+var errDeadLetterNotFound = errors.New("dead letter not found")
+
+// Delivery is an outbound notification: a JSON POST to a webhook or an
+// integration such as GitHub.
+type Delivery struct {
+	Kind    string            `json:"kind"` // e.g. "stall_alert" or "github_comment"; selects the authorizer
+	URL     string            `json:"url"`
+	Body    json.RawMessage   `json:"body"`
+	Headers map[string]string `json:"headers,omitempty"` // Without credentials; the authorizer of the kind adds them on every attempt
+	Signed  bool              `json:"signed,omitempty"`  // Signed with the agent's RequestSigner
+	TaskID  string            `json:"taskId,omitempty"`
+}
+
+// DeadLetter is a delivery that failed on every attempt, kept with the
+// reason until it is re-driven or dropped.
+type DeadLetter struct {
+	ID        string    `json:"id"`
+	Delivery  Delivery  `json:"delivery"`
+	Attempts  int       `json:"attempts"`
+	Error     string    `json:"error"` // Of the last attempt
+	CreatedAt time.Time `json:"createdAt"`
+	LastTryAt time.Time `json:"lastTryAt"`
+	Redrives  int       `json:"redrives,omitempty"`
+}
+
+// DeadLetterStore is implemented by task stores that persist dead letters,
+// so undelivered notifications survive restarts.
+type DeadLetterStore interface {
+	SaveDeadLetter(letter *DeadLetter) error
+	DeleteDeadLetter(id string) error
+	ListDeadLetters() ([]*DeadLetter, error)
+}
+
+// DeadLetterStats are the metrics of a dead-letter queue, reported by /health.
+type DeadLetterStats struct {
+	Pending      int       `json:"pending"`
+	DeadLettered int64     `json:"deadLettered"` // Deliveries that exhausted their attempts since the start
+	Redriven     int64     `json:"redriven"`     // Dead letters delivered by a re-drive since the start
+	LastAt       time.Time `json:"lastAt,omitempty"`
+}
+
+// DeadLetterQueue holds undeliverable notifications, persisted in the task
+// store when it is a DeadLetterStore.
+type DeadLetterQueue struct {
+	store   DeadLetterStore // Nil keeps the letters in memory only
+	mu      sync.Mutex
+	letters map[string]*DeadLetter
+	stats   DeadLetterStats
+}
+
+// NewDeadLetterQueue creates a queue persisting to store if it supports it,
+// loading the letters kept there.
+func NewDeadLetterQueue(store TaskStore) *DeadLetterQueue {
+	q := &DeadLetterQueue{letters: make(map[string]*DeadLetter)}
+	if dls, ok := store.(DeadLetterStore); ok {
+		q.store = dls
+		letters, err := dls.ListDeadLetters()
+		if err != nil {
+			log.Printf("[DeadLetters] Failed to load dead letters: %v", err)
+		}
+		for _, letter := range letters {
+			q.letters[letter.ID] = letter
+		}
+		if len(letters) > 0 {
+			log.Printf("[DeadLetters] %d undelivered notification(s) in the dead-letter queue.", len(letters))
+		}
+	}
+	return q
+}
+
+// add parks a delivery that failed attempts times.
+func (q *DeadLetterQueue) add(delivery Delivery, attempts int, deliveryErr error) *DeadLetter {
+	now := time.Now().UTC()
+	letter := &DeadLetter{ID: uuid.New().String(), Delivery: delivery, Attempts: attempts, Error: deliveryErr.Error(), CreatedAt: now, LastTryAt: now}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.letters[letter.ID] = letter
+	q.stats.DeadLettered++
+	q.stats.LastAt = now
+	if q.store != nil {
+		if err := q.store.SaveDeadLetter(letter); err != nil {
+			log.Printf("[DeadLetters] Failed to persist dead letter %s: %v", letter.ID, err)
+		}
+	}
+	log.Printf("[DeadLetters] WARNING: %s delivery to %s failed %d time(s) and was dead-lettered as %s: %v", delivery.Kind, delivery.URL, attempts, letter.ID, deliveryErr)
+	return letter
+}
+
+// List returns the letters of a kind, or all with an empty kind, oldest first.
+func (q *DeadLetterQueue) List(kind string) []DeadLetter {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	letters := []DeadLetter{}
+	for _, letter := range q.letters {
+		if kind == "" || letter.Delivery.Kind == kind {
+			letters = append(letters, *letter)
+		}
+	}
+	sort.Slice(letters, func(i, j int) bool { return letters[i].CreatedAt.Before(letters[j].CreatedAt) })
+	return letters
+}
+
+// Drop removes a letter without delivering it.
+func (q *DeadLetterQueue) Drop(id string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.letters[id]; !ok {
+		return errDeadLetterNotFound
+	}
+	delete(q.letters, id)
+	if q.store != nil {
+		return q.store.DeleteDeadLetter(id)
+	}
+	return nil
+}
+
+// Stats returns the metrics of the queue.
+func (q *DeadLetterQueue) Stats() DeadLetterStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	stats := q.stats
+	stats.Pending = len(q.letters)
+	return stats
+}
+
+// Deliverer sends deliveries with retries and parks those that exhaust them
+// in its dead-letter queue.
+type Deliverer struct {
+	Client      *http.Client
+	Signer      *RequestSigner // Signs deliveries marked Signed; nil sends them unsigned
+	MaxAttempts int
+	Backoff     time.Duration // Before the second attempt, doubled for each further one
+	DeadLetters *DeadLetterQueue
+
+	mu          sync.Mutex
+	authorizers map[string]func(*http.Request)
+}
+
+// NewDeliverer creates a deliverer dead-lettering into the queue of store.
+func NewDeliverer(store TaskStore) *Deliverer {
+	return &Deliverer{
+		Client:      &http.Client{Timeout: deliveryTimeout},
+		MaxAttempts: DefaultDeliveryAttempts,
+		Backoff:     DefaultDeliveryBackoff,
+		DeadLetters: NewDeadLetterQueue(store),
+	}
+}
+
+// Authorize registers fn to add the credentials of a kind of delivery to
+// each attempt, so credentials are never stored with dead letters.
+func (d *Deliverer) Authorize(kind string, fn func(*http.Request)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.authorizers == nil {
+		d.authorizers = make(map[string]func(*http.Request))
+	}
+	d.authorizers[kind] = fn
+}
+
+// Deliver sends a delivery, retrying failures with backoff. Deliveries that
+// fail every attempt, or are rejected with a client error, are dead-lettered
+// and the last error is returned.
+func (d *Deliverer) Deliver(ctx context.Context, delivery Delivery) error {
+	attempts := max(d.MaxAttempts, 1)
+	backoff := d.Backoff
+	var err error
+	attempt := 0
+	for attempt < attempts {
+		attempt++
+		if err = d.send(ctx, delivery); err == nil {
+			return nil
+		}
+		var statusErr *deliveryStatusError
+		if errors.As(err, &statusErr) && !statusErr.retryable() || attempt == attempts {
+			break
+		}
+		log.Printf("[Delivery] %s delivery to %s failed (attempt %d of %d), retrying in %s: %v", delivery.Kind, delivery.URL, attempt, attempts, backoff, err)
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			attempt = attempts
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+	if d.DeadLetters != nil {
+		d.DeadLetters.add(delivery, attempt, err)
+	}
+	return err
+}
+
+// Redrive sends a dead letter once more. It leaves the queue when delivered;
+// otherwise it stays with the new error.
+func (d *Deliverer) Redrive(ctx context.Context, id string) error {
+	q := d.DeadLetters
+	q.mu.Lock()
+	letter, ok := q.letters[id]
+	var delivery Delivery
+	if ok {
+		delivery = letter.Delivery
+	}
+	q.mu.Unlock()
+	if !ok {
+		return errDeadLetterNotFound
+	}
+
+	sendErr := d.send(ctx, delivery)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if _, ok := q.letters[id]; !ok {
+		return sendErr // Dropped meanwhile
+	}
+	if sendErr == nil {
+		delete(q.letters, id)
+		q.stats.Redriven++
+		if q.store != nil {
+			if err := q.store.DeleteDeadLetter(id); err != nil {
+				log.Printf("[DeadLetters] Failed to delete re-driven dead letter %s: %v", id, err)
+			}
+		}
+		log.Printf("[DeadLetters] Re-drove %s delivery %s to %s.", delivery.Kind, id, delivery.URL)
+		return nil
+	}
+	letter.Attempts++
+	letter.Redrives++
+	letter.Error = sendErr.Error()
+	letter.LastTryAt = time.Now().UTC()
+	if q.store != nil {
+		if err := q.store.SaveDeadLetter(letter); err != nil {
+			log.Printf("[DeadLetters] Failed to persist dead letter %s: %v", id, err)
+		}
+	}
+	return sendErr
+}
+
+// deliveryStatusError is a delivery answered with a non-2xx status.
+type deliveryStatusError struct {
+	status int
+	body   string
+}
+
+func (e *deliveryStatusError) Error() string {
+	if e.body == "" {
+		return fmt.Sprintf("receiver returned status %d", e.status)
+	}
+	return fmt.Sprintf("receiver returned status %d: %s", e.status, e.body)
+}
+
+// retryable reports whether the receiver may accept the delivery later.
+func (e *deliveryStatusError) retryable() bool {
+	return e.status >= 500 || e.status == http.StatusTooManyRequests || e.status == http.StatusRequestTimeout
+}
+
+// send makes one delivery attempt.
+func (d *Deliverer) send(ctx context.Context, delivery Delivery) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range delivery.Headers {
+		req.Header.Set(name, value)
+	}
+	d.mu.Lock()
+	authorize := d.authorizers[delivery.Kind]
+	d.mu.Unlock()
+	if authorize != nil {
+		authorize(req)
+	}
+	if delivery.Signed {
+		d.Signer.Sign(req, delivery.Body)
+	}
+	client := d.Client
+	if client == nil {
+		client = &http.Client{Timeout: deliveryTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &deliveryStatusError{status: resp.StatusCode, body: string(bytes.TrimSpace(body))}
+	}
+	return nil
+}
+
+// DeadLettersParams defines the parameters of "admin/deadLetters".
+type DeadLettersParams struct {
+	Kind string `json:"kind,omitempty"` // Only letters of this kind
+}
+
+// AdminDeadLettersHandler handles "admin/deadLetters", which lists the
+// undelivered notifications with their payload and failure reason.
+func AdminDeadLettersHandler(deliverer *Deliverer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params DeadLettersParams
+		rpcReq, ok := decodeJSONRPCRequest(w, r, &params)
+		if !ok {
+			return
+		}
+		sendJSONRPCResponse(w, rpcReq.ID, map[string]interface{}{
+			"deadLetters": deliverer.DeadLetters.List(params.Kind),
+			"stats":       deliverer.DeadLetters.Stats(),
+		}, nil)
+	}
+}
+
+// DeadLetterActionParams defines the parameters of "admin/redriveDeadLetters"
+// and "admin/dropDeadLetters".
+type DeadLetterActionParams struct {
+	IDs  []string `json:"ids,omitempty"`
+	All  bool     `json:"all,omitempty"`  // Every letter, or every letter of Kind
+	Kind string   `json:"kind,omitempty"` // With All
+}
+
+// DeadLetterResult is the outcome for one letter.
+type DeadLetterResult struct {
+	ID    string `json:"id"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+func (p DeadLetterActionParams) ids(q *DeadLetterQueue) ([]string, error) {
+	if p.All == (len(p.IDs) > 0) {
+		return nil, fmt.Errorf("pass either ids or all")
+	}
+	if !p.All {
+		return p.IDs, nil
+	}
+	var ids []string
+	for _, letter := range q.List(p.Kind) {
+		ids = append(ids, letter.ID)
+	}
+	return ids, nil
+}
+
+// AdminRedriveDeadLettersHandler handles "admin/redriveDeadLetters", which
+// sends dead letters once more. Delivered letters leave the queue.
+func AdminRedriveDeadLettersHandler(deliverer *Deliverer) http.HandlerFunc {
+	return deadLetterAction(func(ctx context.Context, id string) error {
+		return deliverer.Redrive(ctx, id)
+	}, deliverer)
+}
+
+// AdminDropDeadLettersHandler handles "admin/dropDeadLetters", which removes
+// dead letters without delivering them.
+func AdminDropDeadLettersHandler(deliverer *Deliverer) http.HandlerFunc {
+	return deadLetterAction(func(ctx context.Context, id string) error {
+		return deliverer.DeadLetters.Drop(id)
+	}, deliverer)
+}
+
+func deadLetterAction(action func(ctx context.Context, id string) error, deliverer *Deliverer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params DeadLetterActionParams
+		rpcReq, ok := decodeJSONRPCRequest(w, r, &params)
+		if !ok {
+			return
+		}
+		ids, err := params.ids(deliverer.DeadLetters)
+		if err != nil {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: "Invalid Params: " + err.Error()})
+			return
+		}
+		results := []DeadLetterResult{}
+		for _, id := range ids {
+			result := DeadLetterResult{ID: id, OK: true}
+			if err := action(r.Context(), id); err != nil {
+				result.OK, result.Error = false, err.Error()
+			}
+			results = append(results, result)
+		}
+		sendJSONRPCResponse(w, rpcReq.ID, map[string]interface{}{"results": results, "stats": deliverer.DeadLetters.Stats()}, nil)
+	}
+}
@@ -0,0 +1,126 @@
+package a2a
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDelivererDeadLettersAndRedrives(t *testing.T) {
+	var healthy atomic.Bool
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusBadGateway)
+			io.WriteString(w, "upstream down")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	store, err := NewFileTaskStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	deliverer := NewDeliverer(store)
+	deliverer.MaxAttempts, deliverer.Backoff = 2, time.Millisecond
+	deliverer.Authorize("test", func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret") })
+
+	err = deliverer.Deliver(context.Background(), Delivery{Kind: "test", URL: server.URL, Body: []byte(`{"a":1}`), TaskID: "t1"})
+	if err == nil || !strings.Contains(err.Error(), "502: upstream down") || requests.Load() != 2 {
+		t.Fatalf("expected two failed attempts, got %v after %d requests", err, requests.Load())
+	}
+
+	// The letter survives a restart, without the credentials
+	reloaded := NewDeliverer(store)
+	reloaded.Authorize("test", func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret") })
+	letters := reloaded.DeadLetters.List("")
+	if len(letters) != 1 || letters[0].Attempts != 2 || letters[0].Delivery.TaskID != "t1" || string(letters[0].Delivery.Body) != `{"a":1}` {
+		t.Fatalf("expected the persisted dead letter, got %+v", letters)
+	}
+	if len(letters[0].Delivery.Headers) != 0 {
+		t.Errorf("expected no credentials in the dead letter, got %v", letters[0].Delivery.Headers)
+	}
+
+	if err := reloaded.Redrive(context.Background(), letters[0].ID); err == nil {
+		t.Fatal("expected the re-drive to fail while the receiver is down")
+	}
+	if letter := reloaded.DeadLetters.List("")[0]; letter.Attempts != 3 || letter.Redrives != 1 {
+		t.Errorf("expected the failed re-drive to be recorded, got %+v", letter)
+	}
+	healthy.Store(true)
+	if err := reloaded.Redrive(context.Background(), letters[0].ID); err != nil {
+		t.Fatalf("expected the re-drive to succeed: %v", err)
+	}
+	if stats := reloaded.DeadLetters.Stats(); stats.Pending != 0 || stats.Redriven != 1 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if letters, _ := store.ListDeadLetters(); len(letters) != 0 {
+		t.Errorf("expected the re-driven letter to be deleted from the store, got %+v", letters)
+	}
+}
+
+func TestDelivererDoesNotRetryClientErrors(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}))
+	defer server.Close()
+
+	deliverer := NewDeliverer(NewInMemoryTaskStore())
+	deliverer.Backoff = time.Millisecond
+	if err := deliverer.Deliver(context.Background(), Delivery{Kind: "test", URL: server.URL, Body: []byte(`{}`)}); err == nil {
+		t.Fatal("expected the delivery to fail")
+	}
+	if requests.Load() != 1 || deliverer.DeadLetters.Stats().DeadLettered != 1 {
+		t.Errorf("expected one attempt and a dead letter, got %d requests, %+v", requests.Load(), deliverer.DeadLetters.Stats())
+	}
+}
+
+func TestAdminDeadLetterHandlers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	deliverer := NewDeliverer(NewInMemoryTaskStore())
+	deliverer.MaxAttempts = 1
+	deliverer.Deliver(context.Background(), Delivery{Kind: "stall_alert", URL: server.URL, Body: []byte(`{}`)})
+	deliverer.Deliver(context.Background(), Delivery{Kind: "github_comment", URL: server.URL, Body: []byte(`{}`)})
+
+	resp := callRPC(t, AdminDeadLettersHandler(deliverer), "admin/deadLetters", `{"kind": "stall_alert"}`)
+	result := resp.Result.(map[string]interface{})
+	if resp.Error != nil || len(result["deadLetters"].([]interface{})) != 1 {
+		t.Fatalf("expected one stall alert letter, got %+v", resp)
+	}
+
+	resp = callRPC(t, AdminRedriveDeadLettersHandler(deliverer), "admin/redriveDeadLetters", `{}`)
+	if resp.Error == nil || resp.Error.Code != -32602 {
+		t.Errorf("expected ids or all to be required, got %+v", resp.Error)
+	}
+	resp = callRPC(t, AdminRedriveDeadLettersHandler(deliverer), "admin/redriveDeadLetters", `{"all": true}`)
+	results := resp.Result.(map[string]interface{})["results"].([]interface{})
+	if len(results) != 2 || results[0].(map[string]interface{})["ok"] != false {
+		t.Errorf("expected both re-drives to fail, got %+v", results)
+	}
+
+	resp = callRPC(t, AdminDropDeadLettersHandler(deliverer), "admin/dropDeadLetters", `{"all": true, "kind": "github_comment"}`)
+	if results := resp.Result.(map[string]interface{})["results"].([]interface{}); len(results) != 1 || results[0].(map[string]interface{})["ok"] != true {
+		t.Errorf("expected the comment to be dropped, got %+v", resp.Result)
+	}
+	if letters := deliverer.DeadLetters.List(""); len(letters) != 1 || letters[0].Delivery.Kind != "stall_alert" {
+		t.Errorf("expected only the stall alert to remain, got %+v", letters)
+	}
+}
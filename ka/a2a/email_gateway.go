@@ -0,0 +1,499 @@
+package a2a
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// EmailTaskTemplate sets the options of tasks created from emails.
+type EmailTaskTemplate struct {
+	Mode           string   `json:"mode,omitempty"`
+	Project        string   `json:"project,omitempty"`
+	Labels         []string `json:"labels,omitempty"`
+	TimeoutSeconds int      `json:"timeoutSeconds,omitempty"`
+	Instructions   string   `json:"instructions,omitempty"` // Put before the email body in the user message
+}
+
+// EmailGatewayConfig configures the inbound email gateway.
+type EmailGatewayConfig struct {
+	Listen          string            `json:"listen"`                    // SMTP address mail is accepted on, e.g. ":2525"
+	Hostname        string            `json:"hostname,omitempty"`        // Name in the SMTP greeting; defaults to the host name
+	AllowedSenders  []string          `json:"allowedSenders,omitempty"`  // Addresses or "@domain", checked for the envelope sender and the reply address; empty accepts every sender
+	MaxMessageBytes int64             `json:"maxMessageBytes,omitempty"` // Defaults to 10 MB
+	Template        EmailTaskTemplate `json:"template"`
+	SMTPServer      string            `json:"smtpServer,omitempty"` // host:port replies are sent through; empty sends no replies
+	SMTPUsername    string            `json:"smtpUsername,omitempty"`
+	SMTPPassword    string            `json:"smtpPassword,omitempty"`
+	From            string            `json:"from,omitempty"` // Sender address of replies
+}
+
+const (
+	defaultEmailMaxBytes     = 10 << 20
+	maxInlineAttachmentBytes = 8 << 10 // Text attachments up to this size are also put into the prompt
+	emailCommandTimeout      = 5 * time.Minute
+)
+
+// LoadEmailGatewayConfig reads the gateway configuration from a JSON object,
+// given inline or as a file path.
+func LoadEmailGatewayConfig(config string) (EmailGatewayConfig, error) {
+	data := []byte(config)
+	if !strings.HasPrefix(strings.TrimSpace(config), "{") {
+		fileData, err := os.ReadFile(config)
+		if err != nil {
+			return EmailGatewayConfig{}, fmt.Errorf("failed to read email gateway file %s: %w", config, err)
+		}
+		data = fileData
+	}
+	var cfg EmailGatewayConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return EmailGatewayConfig{}, fmt.Errorf("failed to parse email gateway configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+// EmailGateway accepts emails over SMTP and turns each into a task: the
+// subject becomes the task name, the body the user message and attachments
+// artifacts. When the task finishes, the result is mailed back to the sender.
+type EmailGateway struct {
+	Config   EmailGatewayConfig
+	Executor *TaskExecutor
+
+	sendMail func(addr string, auth smtp.Auth, from string, to []string, msg []byte) error
+	listener net.Listener
+}
+
+// NewEmailGateway validates the configuration and returns a gateway that is
+// not listening yet.
+func NewEmailGateway(cfg EmailGatewayConfig, te *TaskExecutor) (*EmailGateway, error) {
+	if cfg.Listen == "" {
+		return nil, fmt.Errorf("email gateway needs a listen address")
+	}
+	if cfg.SMTPServer != "" && cfg.From == "" {
+		return nil, fmt.Errorf("email gateway needs a from address to send replies")
+	}
+	if cfg.MaxMessageBytes <= 0 {
+		cfg.MaxMessageBytes = defaultEmailMaxBytes
+	}
+	if cfg.Hostname == "" {
+		cfg.Hostname, _ = os.Hostname()
+	}
+	if _, ok := te.Modes.Get(cfg.Template.Mode); cfg.Template.Mode != "" && !ok {
+		return nil, fmt.Errorf("unknown mode '%s' in email task template", cfg.Template.Mode)
+	}
+	if len(cfg.AllowedSenders) == 0 {
+		log.Printf("[EmailGateway] Warning: no allowedSenders configured; mail from every sender creates tasks")
+	}
+	return &EmailGateway{Config: cfg, Executor: te, sendMail: smtp.SendMail}, nil
+}
+
+// ListenAndServe listens on the configured address and serves SMTP sessions
+// until Close is called.
+func (g *EmailGateway) ListenAndServe() error {
+	listener, err := net.Listen("tcp", g.Config.Listen)
+	if err != nil {
+		return fmt.Errorf("email gateway failed to listen on %s: %w", g.Config.Listen, err)
+	}
+	return g.Serve(listener)
+}
+
+// Serve accepts SMTP sessions on listener.
+func (g *EmailGateway) Serve(listener net.Listener) error {
+	g.listener = listener
+	log.Printf("[EmailGateway] Accepting mail on %s", listener.Addr())
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				continue
+			}
+			return err
+		}
+		go g.serveConn(conn)
+	}
+}
+
+// Close stops accepting mail. Tasks of received emails keep running.
+func (g *EmailGateway) Close() error {
+	if g.listener == nil {
+		return nil
+	}
+	return g.listener.Close()
+}
+
+// serveConn runs one SMTP session. Only what a mail relay needs to deliver
+// a message is supported: no TLS and no authentication, so the gateway
+// belongs behind a relay or on a trusted network.
+func (g *EmailGateway) serveConn(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	reply := func(format string, args ...interface{}) bool {
+		conn.SetDeadline(time.Now().Add(emailCommandTimeout))
+		return tp.PrintfLine(format, args...) == nil
+	}
+	if !reply("220 %s ESMTP ka email gateway", g.Config.Hostname) {
+		return
+	}
+	var from string
+	var recipients []string
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "HELO":
+			reply("250 %s", g.Config.Hostname)
+		case "EHLO":
+			reply("250-%s", g.Config.Hostname)
+			reply("250-SIZE %d", g.Config.MaxMessageBytes)
+			reply("250 8BITMIME")
+		case "MAIL":
+			addr, ok := smtpPath(arg, "FROM:")
+			if !ok {
+				reply("501 5.5.4 Syntax: MAIL FROM:<address>")
+				continue
+			}
+			if !g.senderAllowed(addr) {
+				log.Printf("[EmailGateway] Rejected mail from %s: sender not allowed", addr)
+				reply("550 5.7.1 Sender not allowed")
+				continue
+			}
+			from, recipients = addr, nil
+			reply("250 2.1.0 OK")
+		case "RCPT":
+			addr, ok := smtpPath(arg, "TO:")
+			if !ok {
+				reply("501 5.5.4 Syntax: RCPT TO:<address>")
+				continue
+			}
+			if from == "" {
+				reply("503 5.5.1 MAIL first")
+				continue
+			}
+			recipients = append(recipients, addr)
+			reply("250 2.1.5 OK")
+		case "DATA":
+			if len(recipients) == 0 {
+				reply("503 5.5.1 RCPT first")
+				continue
+			}
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			dot := tp.DotReader()
+			data, err := io.ReadAll(io.LimitReader(dot, g.Config.MaxMessageBytes+1))
+			io.Copy(io.Discard, dot)
+			switch {
+			case err != nil:
+				return
+			case int64(len(data)) > g.Config.MaxMessageBytes:
+				reply("552 5.3.4 Message too big")
+			default:
+				email, err := parseEmail(data, from)
+				switch {
+				case err != nil:
+					log.Printf("[EmailGateway] Failed to parse mail from %s: %v", from, err)
+					reply("554 5.6.0 Malformed message")
+				case !g.senderAllowed(email.From):
+					// The envelope sender is not authenticated; the result goes to
+					// the reply address, so it must be allowed as well
+					log.Printf("[EmailGateway] Rejected mail from %s: reply address %s not allowed", from, email.From)
+					reply("550 5.7.1 Reply address not allowed")
+				default:
+					go g.process(email)
+					reply("250 2.0.0 OK: queued as a task")
+				}
+			}
+			from, recipients = "", nil
+		case "RSET":
+			from, recipients = "", nil
+			reply("250 2.0.0 OK")
+		case "NOOP":
+			reply("250 2.0.0 OK")
+		case "QUIT":
+			reply("221 2.0.0 Bye")
+			return
+		default:
+			reply("502 5.5.2 Command not implemented")
+		}
+	}
+}
+
+// smtpPath extracts the address from a MAIL FROM or RCPT TO argument.
+func smtpPath(arg, prefix string) (string, bool) {
+	if len(arg) < len(prefix) || !strings.EqualFold(arg[:len(prefix)], prefix) {
+		return "", false
+	}
+	path := strings.TrimSpace(arg[len(prefix):])
+	start, end := strings.Index(path, "<"), strings.Index(path, ">")
+	if start != 0 || end < start {
+		return "", false
+	}
+	return path[1:end], true
+}
+
+// senderAllowed checks an address against AllowedSenders.
+func (g *EmailGateway) senderAllowed(addr string) bool {
+	if len(g.Config.AllowedSenders) == 0 {
+		return true
+	}
+	addr = strings.ToLower(addr)
+	for _, allowed := range g.Config.AllowedSenders {
+		allowed = strings.ToLower(allowed)
+		if addr == allowed || (strings.HasPrefix(allowed, "@") && strings.HasSuffix(addr, allowed)) {
+			return true
+		}
+	}
+	return false
+}
+
+// inboundEmail is the part of a received email a task is created from.
+type inboundEmail struct {
+	From        string // Address replies go to
+	Subject     string
+	MessageID   string
+	AutoReply   bool // Sent by an automated system; never answered, to avoid mail loops
+	Body        string
+	Attachments []emailAttachment
+}
+
+type emailAttachment struct {
+	Filename string
+	MimeType string
+	Data     []byte
+}
+
+var htmlTagPattern = regexp.MustCompile(`(?s)<[^>]*>`)
+
+// parseEmail parses a raw RFC 5322 message. envelopeFrom is used when the
+// message has no usable From or Reply-To header.
+func parseEmail(data []byte, envelopeFrom string) (*inboundEmail, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	decoder := new(mime.WordDecoder)
+	subject, err := decoder.DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		subject = msg.Header.Get("Subject")
+	}
+	email := &inboundEmail{From: envelopeFrom, Subject: strings.TrimSpace(subject), MessageID: msg.Header.Get("Message-Id")}
+	for _, header := range []string{"Reply-To", "From"} {
+		if addr, err := mail.ParseAddress(msg.Header.Get(header)); err == nil {
+			email.From = addr.Address
+			break
+		}
+	}
+	if auto := strings.ToLower(msg.Header.Get("Auto-Submitted")); auto != "" && auto != "no" {
+		email.AutoReply = true
+	}
+
+	var html string
+	err = walkEmailPart(textproto.MIMEHeader(msg.Header), msg.Body, email, &html)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(email.Body) == "" && html != "" {
+		email.Body = strings.TrimSpace(htmlTagPattern.ReplaceAllString(html, ""))
+	}
+	return email, nil
+}
+
+// walkEmailPart collects the text body and attachments of a MIME part and its children.
+func walkEmailPart(header textproto.MIMEHeader, body io.Reader, email *inboundEmail, html *string) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := walkEmailPart(part.Header, part, email, html); err != nil {
+				return err
+			}
+		}
+	}
+
+	switch strings.ToLower(header.Get("Content-Transfer-Encoding")) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, &lineJoiner{r: body})
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+
+	disposition, dispParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	filename := dispParams["filename"]
+	if filename == "" {
+		filename = params["name"]
+	}
+	switch {
+	case disposition == "attachment" || filename != "":
+		if filename == "" {
+			filename = fmt.Sprintf("attachment-%d", len(email.Attachments)+1)
+		}
+		email.Attachments = append(email.Attachments, emailAttachment{Filename: filename, MimeType: mediaType, Data: content})
+	case mediaType == "text/plain" && email.Body == "":
+		email.Body = strings.TrimSpace(string(content))
+	case mediaType == "text/html" && *html == "":
+		*html = string(content)
+	}
+	return nil
+}
+
+// lineJoiner drops line breaks, which base64 bodies of emails contain.
+type lineJoiner struct{ r io.Reader }
+
+func (l *lineJoiner) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	out := 0
+	for _, b := range p[:n] {
+		if b != '\r' && b != '\n' {
+			p[out] = b
+			out++
+		}
+	}
+	return out, err
+}
+
+// process creates and runs the task of an email and replies with its result.
+func (g *EmailGateway) process(email *inboundEmail) {
+	te := g.Executor
+	tmpl := g.Config.Template
+	name := email.Subject
+	if name == "" {
+		name = "Email from " + email.From
+	}
+
+	text := email.Body
+	if tmpl.Instructions != "" {
+		text = tmpl.Instructions + "\n\n" + text
+	}
+	var artifacts []Artifact
+	for i, attachment := range email.Attachments {
+		artifact := Artifact{ID: fmt.Sprintf("email-attachment-%d", i+1), Type: attachment.MimeType, Filename: attachment.Filename, Data: attachment.Data}
+		artifacts = append(artifacts, artifact)
+		text += fmt.Sprintf("\n\n[Attachment %s (%s, %d bytes), stored as artifact %s]", attachment.Filename, attachment.MimeType, len(attachment.Data), artifact.ID)
+		if strings.HasPrefix(attachment.MimeType, "text/") && len(attachment.Data) <= maxInlineAttachmentBytes && utf8.Valid(attachment.Data) {
+			text += "\n" + string(attachment.Data) + "\n[/Attachment]"
+		}
+	}
+
+	params := SendTaskParams{
+		Message:        Message{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: text}}, Timestamp: time.Now()},
+		Mode:           tmpl.Mode,
+		Project:        tmpl.Project,
+		Labels:         tmpl.Labels,
+		TimeoutSeconds: tmpl.TimeoutSeconds,
+	}
+	task, rpcErr := te.createTask(context.Background(), name, params)
+	if rpcErr != nil {
+		log.Printf("[EmailGateway] Failed to create a task for mail from %s: %s", email.From, rpcErr.Message)
+		g.reply(email, "", "The email could not be turned into a task: "+rpcErr.Message)
+		return
+	}
+	for _, artifact := range artifacts {
+		if err := te.TaskStore.AddArtifact(task.ID, artifact); err != nil {
+			log.Printf("[EmailGateway] Failed to store attachment %s of task %s: %v", artifact.Filename, task.ID, err)
+		}
+	}
+	log.Printf("[EmailGateway] Created task %s from mail of %s (%q).", task.ID, email.From, name)
+
+	te.ExecuteTask(context.Background(), task)
+	final, err := te.TaskStore.GetTask(task.ID)
+	if err != nil {
+		log.Printf("[EmailGateway] Failed to load task %s after its run: %v", task.ID, err)
+		return
+	}
+	g.reply(email, final.ID, taskResultText(final))
+}
+
+// taskResultText is the answer of a task after its run, or why it has none,
+// as reported back to the system the task came from.
+func taskResultText(task *Task) string {
+	answer := ""
+	for i := len(task.Messages) - 1; i >= 0; i-- {
+		if task.Messages[i].Role == RoleAssistant {
+			answer = stripToolXML(messageText(task.Messages[i]))
+			break
+		}
+	}
+	switch task.State {
+	case TaskStateCompleted:
+		return answer
+	case TaskStateInputRequired:
+		return answer + "\n\nThe task is waiting for your input. Answer it through the agent's API (tasks/input)."
+	default:
+		text := fmt.Sprintf("The task ended in state %s.", task.State)
+		if task.ErrorDetail != nil {
+			text += " " + task.ErrorDetail.Message
+			if task.ErrorDetail.Hint != "" {
+				text += "\n" + task.ErrorDetail.Hint
+			}
+		}
+		return text
+	}
+}
+
+// reply mails body back to the sender of email. Nothing is sent without an
+// SMTP server or to automated senders.
+func (g *EmailGateway) reply(email *inboundEmail, taskID, body string) {
+	if g.Config.SMTPServer == "" || email.AutoReply || email.From == "" {
+		return
+	}
+	subject := email.Subject
+	if !strings.HasPrefix(strings.ToLower(subject), "re:") {
+		subject = "Re: " + subject
+	}
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", g.Config.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", email.From)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&msg, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	if email.MessageID != "" {
+		fmt.Fprintf(&msg, "In-Reply-To: %s\r\nReferences: %s\r\n", email.MessageID, email.MessageID)
+	}
+	if taskID != "" {
+		fmt.Fprintf(&msg, "X-Ka-Task-Id: %s\r\n", taskID)
+	}
+	msg.WriteString("Auto-Submitted: auto-replied\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: 8bit\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	msg.WriteString("\r\n")
+
+	var auth smtp.Auth
+	if g.Config.SMTPUsername != "" {
+		host, _, _ := net.SplitHostPort(g.Config.SMTPServer)
+		auth = smtp.PlainAuth("", g.Config.SMTPUsername, g.Config.SMTPPassword, host)
+	}
+	if err := g.sendMail(g.Config.SMTPServer, auth, g.Config.From, []string{email.From}, msg.Bytes()); err != nil {
+		log.Printf("[EmailGateway] Failed to send the reply for task %s to %s: %v", taskID, email.From, err)
+		return
+	}
+	log.Printf("[EmailGateway] Replied to %s with the result of task %s.", email.From, taskID)
+}
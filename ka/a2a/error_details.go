@@ -0,0 +1,127 @@
+package a2a
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"syscall"
+
+	"ka/llm"
+)
+
+// Error codes of ErrorDetail.
+const (
+	ErrorCodeLLMTimeout      = "llm_timeout"
+	ErrorCodeLLMUnreachable  = "llm_unreachable"
+	ErrorCodeLLMRejected     = "llm_rejected"
+	ErrorCodeLLMFailed       = "llm_failed"
+	ErrorCodeCanceled        = "canceled"
+	ErrorCodeNoPromptContent = "no_prompt_content"
+	ErrorCodeTaskTimeout     = "task_timeout"
+	ErrorCodeStalled         = "stalled"
+	ErrorCodeInterrupted     = "interrupted"
+	ErrorCodeAlreadyRunning  = "already_running"
+	ErrorCodeInternal        = "internal"
+)
+
+// ErrorDetail describes why a task failed in a form a frontend can act on.
+type ErrorDetail struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Hint      string `json:"hint,omitempty"` // What the user can do about it
+	Retryable bool   `json:"retryable"`      // Sending the task again may succeed
+	Tool      string `json:"tool,omitempty"`
+	Provider  string `json:"provider,omitempty"`
+}
+
+var llmStatusPattern = regexp.MustCompile(`returned status (\d{3})`)
+
+// llmErrorDetail classifies an error returned by an LLM client.
+func llmErrorDetail(err error, client llm.LLMClient) *ErrorDetail {
+	detail := &ErrorDetail{Code: ErrorCodeLLMFailed, Message: err.Error(), Provider: providerName(client), Retryable: llm.IsRetryable(err)}
+	var timeoutErr *llm.TimeoutError
+	switch {
+	case errors.Is(err, context.Canceled):
+		detail.Code = ErrorCodeCanceled
+		detail.Hint = "The request was canceled before the model answered."
+	case errors.As(err, &timeoutErr) || errors.Is(err, context.DeadlineExceeded):
+		detail.Code = ErrorCodeLLMTimeout
+		detail.Hint = "The model did not answer in time. Retry, or raise the LLM timeouts if the model is slow to start."
+		detail.Retryable = true
+	case errors.Is(err, syscall.ECONNREFUSED):
+		detail.Code = ErrorCodeLLMUnreachable
+		detail.Hint = "Check that the LLM backend is running and reachable at the configured URL."
+		detail.Retryable = true
+	default:
+		if m := llmStatusPattern.FindStringSubmatch(err.Error()); m != nil {
+			status, _ := strconv.Atoi(m[1])
+			detail.Code = ErrorCodeLLMRejected
+			switch {
+			case status == 401 || status == 403:
+				detail.Hint = "The provider rejected the credentials. Check the API key."
+			case status == 404:
+				detail.Hint = "The provider does not know the model. Check the model name."
+			case status == 429 || status >= 500:
+				detail.Hint = "The provider is overloaded or failing. Retry later."
+				detail.Retryable = true
+			default:
+				detail.Hint = "The provider rejected the request. The conversation may exceed the model's context."
+			}
+		}
+	}
+	return detail
+}
+
+// providerName names the provider behind an LLM client, if known.
+func providerName(client llm.LLMClient) string {
+	switch client.(type) {
+	case *llm.LMStudioClient:
+		return "lmstudio"
+	case *llm.GoogleClient:
+		return "google"
+	case *llm.OllamaClient:
+		return "ollama"
+	}
+	return ""
+}
+
+// noPromptContentDetail is the failure of a task without usable message content.
+func noPromptContentDetail(err error) *ErrorDetail {
+	message := "could not extract suitable content from messages for LLM"
+	if err != nil {
+		message = err.Error()
+	}
+	return &ErrorDetail{
+		Code:    ErrorCodeNoPromptContent,
+		Message: message,
+		Hint:    "Send a message with text, file or data content.",
+	}
+}
+
+// internalErrorDetail is a failure of the agent itself, e.g. of the task store.
+func internalErrorDetail(format string, args ...interface{}) *ErrorDetail {
+	return &ErrorDetail{
+		Code:      ErrorCodeInternal,
+		Message:   fmt.Sprintf(format, args...),
+		Hint:      "This is an agent error. Check the agent logs; resending the task may help.",
+		Retryable: true,
+	}
+}
+
+// setTaskError records a failure on the task; a nil detail clears it.
+func setTaskError(task *Task, detail *ErrorDetail) {
+	task.ErrorDetail = detail
+	task.Error = ""
+	if detail != nil {
+		task.Error = detail.Message
+	}
+}
+
+// failedStateEvent returns the data of the SSE state event of a failed task.
+func failedStateEvent(detail *ErrorDetail) string {
+	data, _ := json.Marshal(map[string]interface{}{"status": string(TaskStateFailed), "error": detail.Message, "errorDetail": detail})
+	return string(data)
+}
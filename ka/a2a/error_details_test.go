@@ -0,0 +1,60 @@
+package a2a
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"ka/llm"
+)
+
+func TestLLMErrorDetail(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		code      string
+		retryable bool
+	}{
+		{"timeout", &llm.TimeoutError{Phase: llm.TimeoutPhaseFirstToken}, ErrorCodeLLMTimeout, true},
+		{"canceled", fmt.Errorf("stream: %w", context.Canceled), ErrorCodeCanceled, false},
+		{"unauthorized", errors.New("LLM API returned status 401: bad key"), ErrorCodeLLMRejected, false},
+		{"overloaded", errors.New("Google API returned status 503"), ErrorCodeLLMRejected, true},
+		{"other", errors.New("boom"), ErrorCodeLLMFailed, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detail := llmErrorDetail(tt.err, nil)
+			if detail.Code != tt.code || detail.Retryable != tt.retryable {
+				t.Errorf("got code %s retryable %v, want %s %v", detail.Code, detail.Retryable, tt.code, tt.retryable)
+			}
+			if detail.Message != tt.err.Error() {
+				t.Errorf("expected the error text as message, got %q", detail.Message)
+			}
+		})
+	}
+}
+
+type erroringLLMClient struct{ err error }
+
+func (c *erroringLLMClient) Chat(ctx context.Context, messages []llm.Message, stream bool, out io.Writer) (string, int, int, error) {
+	return "", 0, 0, c.err
+}
+
+func TestFailedTaskCarriesErrorDetail(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	te := NewTaskExecutor(&erroringLLMClient{err: errors.New("LLM API returned status 404: no such model")}, store, nil, "")
+
+	task, _ := store.CreateTask("failing", "", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "hi"}}}}, "")
+	te.ExecuteTask(context.Background(), task)
+	waitForState(t, store, task.ID, TaskStateFailed)
+
+	failed, _ := store.GetTask(task.ID)
+	if failed.ErrorDetail == nil || failed.ErrorDetail.Code != ErrorCodeLLMRejected || failed.ErrorDetail.Hint == "" {
+		t.Fatalf("expected an llm_rejected detail with a hint, got %+v", failed.ErrorDetail)
+	}
+	if failed.Error != failed.ErrorDetail.Message {
+		t.Errorf("expected Error %q to match the detail message %q", failed.Error, failed.ErrorDetail.Message)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"net/url"
 	"strings"
 	"sync/atomic"
+	"time"
 
 	"ka/llm" // Import the llm package
 )
@@ -52,8 +53,16 @@ func isValidPartURI(uri string) (u *url.URL, ok bool) {
 	}
 }
 
-func downloadHTTPContent(uri string, maxSize int64) ([]byte, error) {
-	resp, err := http.Get(uri)
+// fileDownloadTimeout bounds the download of a file part into a prompt.
+const fileDownloadTimeout = 30 * time.Second
+
+// fileClient returns the client downloading file parts, through FileTransport.
+func (te *TaskExecutor) fileClient() *http.Client {
+	return &http.Client{Transport: te.FileTransport, Timeout: fileDownloadTimeout}
+}
+
+func downloadHTTPContent(client *http.Client, uri string, maxSize int64) ([]byte, error) {
+	resp, err := client.Get(uri)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch URI %s: %w", uri, err)
 	}
@@ -105,7 +114,8 @@ func decodeDataURI(uri string) ([]byte, error) {
 // buildPromptFromInput constructs the LLM messages slice from task input messages,
 // including the agent's system message.
 // It returns a slice of llm.Message, a boolean indicating if any relevant content was found, and an error.
-func buildPromptFromInput(taskID string, inputMessages []Message, agentSystemMessage string) ([]llm.Message, bool, error) {
+// files downloads http(s) file parts into the prompt; with nil they are only referenced.
+func buildPromptFromInput(files *http.Client, taskID string, inputMessages []Message, agentSystemMessage string) ([]llm.Message, bool, error) {
 	llmMessages := make([]llm.Message, 0, len(inputMessages)+1)
 	contentFound := false
 
@@ -132,12 +142,12 @@ func buildPromptFromInput(taskID string, inputMessages []Message, agentSystemMes
 	}
 
 	// Process all messages
-	for _, msg := range inputMessages {
+	for i, msg := range inputMessages {
 		if !isRelevantRole(msg.Role) {
 			continue
 		}
 
-		content, msgContentFound := buildMessageContent(taskID, msg)
+		content, msgContentFound := buildMessageContent(files, taskID, i, msg)
 		if msgContentFound && content != "" {
 			llmMessages = append(llmMessages, llm.Message{
 				Role:    string(msg.Role),
@@ -169,24 +179,26 @@ func isRelevantRole(role MessageRole) bool {
 	return role == RoleUser || role == RoleAssistant || role == RoleTool
 }
 
-// buildMessageContent processes a single message and builds its content string
-func buildMessageContent(taskID string, msg Message) (string, bool) {
+// buildMessageContent processes a single message and builds its content string.
+// messageIndex is the position of the message in the task, used to refer to
+// the artifacts of spilled data parts.
+func buildMessageContent(files *http.Client, taskID string, messageIndex int, msg Message) (string, bool) {
 	var messageContentBuilder strings.Builder
 	contentFound := false
 
-	for _, part := range msg.Parts {
+	for partIndex, part := range msg.Parts {
 		switch p := part.(type) {
 		case TextPart:
 			messageContentBuilder.WriteString(p.Text)
 			contentFound = true
 		case FilePart:
-			contentFound = processFilePart(taskID, p, &messageContentBuilder) || contentFound
+			contentFound = processFilePart(files, taskID, p, &messageContentBuilder) || contentFound
 		case DataPart:
-			messageContentBuilder.WriteString(fmt.Sprintf("[Data: %s]", p.MimeType))
+			messageContentBuilder.WriteString(dataPartPromptText(p, messageIndex, partIndex))
 			contentFound = true
 		default:
-			log.Printf("[Task %s] Warning: Unrecognized part type %T", taskID, p)
-			messageContentBuilder.WriteString(fmt.Sprintf("[Unknown Part Type: %T]", p))
+			messageContentBuilder.WriteString(customPartPromptText(p))
+			contentFound = true
 		}
 	}
 
@@ -194,7 +206,7 @@ func buildMessageContent(taskID string, msg Message) (string, bool) {
 }
 
 // processFilePart handles the processing of file parts with different URI schemes
-func processFilePart(taskID string, part FilePart, builder *strings.Builder) bool {
+func processFilePart(files *http.Client, taskID string, part FilePart, builder *strings.Builder) bool {
 	parsedURI, ok := isValidPartURI(part.URI)
 	if !ok {
 		log.Printf("[Task %s] Warning: Invalid or unsupported URI scheme in FilePart: %s", taskID, part.URI)
@@ -204,7 +216,11 @@ func processFilePart(taskID string, part FilePart, builder *strings.Builder) boo
 
 	switch parsedURI.Scheme {
 	case "http", "https":
-		return processHTTPFilePart(taskID, part, builder)
+		if files == nil {
+			builder.WriteString(fmt.Sprintf("[File: %s (%s)]", part.URI, part.MimeType))
+			return true
+		}
+		return processHTTPFilePart(files, taskID, part, builder)
 	case "file":
 		builder.WriteString(fmt.Sprintf("[File: %s (%s)]", part.URI, part.MimeType))
 		return true
@@ -219,9 +235,9 @@ func processFilePart(taskID string, part FilePart, builder *strings.Builder) boo
 }
 
 // processHTTPFilePart handles HTTP/HTTPS file parts
-func processHTTPFilePart(taskID string, part FilePart, builder *strings.Builder) bool {
+func processHTTPFilePart(files *http.Client, taskID string, part FilePart, builder *strings.Builder) bool {
 	const maxDownloadSize = 1024 * 1024 // 1MB limit
-	content, downloadErr := downloadHTTPContent(part.URI, maxDownloadSize)
+	content, downloadErr := downloadHTTPContent(files, part.URI, maxDownloadSize)
 	if downloadErr != nil {
 		log.Printf("[Task %s] Error downloading FilePart content from %s: %v", taskID, part.URI, downloadErr)
 		builder.WriteString(fmt.Sprintf("[File Download Error: %s (%s) - %v]", part.URI, part.MimeType, downloadErr))
@@ -97,7 +97,7 @@ func TestBuildPromptFromInput(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotMessages, gotContentFound, err := buildPromptFromInput(tt.taskID, tt.inputMessages, tt.agentSystemMessage)
+			gotMessages, gotContentFound, err := buildPromptFromInput(nil, tt.taskID, tt.inputMessages, tt.agentSystemMessage)
 
 			// Check error
 			if (err != nil) != tt.wantErr {
@@ -170,7 +170,7 @@ func TestProcessFilePart(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			var builder strings.Builder
-			gotOK := processFilePart(tt.taskID, tt.part, &builder)
+			gotOK := processFilePart(server.Client(), tt.taskID, tt.part, &builder)
 			gotText := builder.String()
 
 			if gotOK != tt.wantOK {
@@ -227,3 +227,27 @@ func TestHasUserMessage(t *testing.T) {
 		})
 	}
 }
+
+func TestFilePartsAreDownloadedThroughTheFileTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "internal secret")
+	}))
+	defer server.Close()
+	egress, err := llm.LoadEgressPolicy(`{"denyCidrs": ["127.0.0.0/8"]}`)
+	if err != nil {
+		t.Fatalf("LoadEgressPolicy: %v", err)
+	}
+	te := &TaskExecutor{FileTransport: egress.Wrap(http.DefaultTransport.(*http.Transport).Clone())}
+
+	var builder strings.Builder
+	processFilePart(te.fileClient(), "task", FilePart{URI: server.URL, MimeType: "text/plain"}, &builder)
+	if text := builder.String(); strings.Contains(text, "internal secret") || !strings.Contains(text, "File Download Error") {
+		t.Errorf("Expected the egress policy to refuse the download, got %q", text)
+	}
+
+	builder.Reset()
+	processFilePart(nil, "task", FilePart{URI: server.URL, MimeType: "text/plain"}, &builder)
+	if text := builder.String(); text != "[File: "+server.URL+" (text/plain)]" {
+		t.Errorf("Expected a file part to be referenced without a client, got %q", text)
+	}
+}
@@ -3,9 +3,9 @@ package a2a
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"strings"
 	"time" // Added time import
@@ -23,13 +23,18 @@ func HandleLLMExecution(
 	taskStore TaskStore, // Use local TaskStore
 	messages []llm.Message,
 	sseWriter *SSEWriter,
-	toolDispatcher *ToolDispatcher, // Add ToolDispatcher
+	toolDispatcher ToolDispatcher, // Add ToolDispatcher
 ) (fullResultString string, inputTokens, completionTokens int, requiresInput bool, assistantMessageSavedByHandler bool, err error) {
 
 	assistantMessageSavedByHandler = false // Initialize
 	var fullOutputBuffer bytes.Buffer
-	signaller := newFirstWriteSignaller(&fullOutputBuffer)
+	var out io.Writer = &fullOutputBuffer
+	if sink := outputSinkFrom(ctx); sink != nil {
+		out = sink // Streamed into an artifact instead of kept in memory
+	}
+	signaller := newFirstWriteSignaller(out)
 	stateUpdateCompleted := make(chan bool, 1)
+	chatReturned := make(chan struct{}) // Unblocks the signaller when the LLM fails without writing anything
 
 	// Goroutine to signal first write
 	go func() {
@@ -41,11 +46,14 @@ func HandleLLMExecution(
 		case <-ctx.Done():
 			log.Printf("[Task %s] Context cancelled before first write detected.", taskID)
 			stateUpdateCompleted <- false
+		case <-chatReturned:
+			stateUpdateCompleted <- false
 		}
 	}()
 
-	// Call LLM (Always Streaming Now)
-	fullResultString, inputTokens, completionTokens, llmErr := llmClient.Chat(ctx, messages, true, signaller)
+	// Call LLM (Always Streaming Now), continuing answers cut off by the token limit
+	fullResultString, inputTokens, completionTokens, llmErr := llm.ChatWithContinuation(ctx, llmClient, messages, true, signaller)
+	close(chatReturned)
 
 	// Ensure the first write signal goroutine has finished before proceeding
 	<-stateUpdateCompleted
@@ -62,8 +70,10 @@ func HandleLLMExecution(
 			log.Printf("[Task %s] LLM stream failed: %v. Input Tokens: %d", taskID, llmErr, inputTokens)
 		}
 
+		detail := llmErrorDetail(llmErr, llmClient)
+		detail.Message = errMsg
 		_, updateErr := taskStore.UpdateTask(taskID, func(task *Task) error { // Use local Task
-			task.Error = errMsg
+			setTaskError(task, detail)
 			return nil
 		})
 		if updateErr != nil {
@@ -90,6 +100,8 @@ func HandleLLMExecution(
 		RawToolCallsXML: rawToolCallsXML,                                        // Store the full response for parsing
 		Parts:           []Part{TextPart{Type: "text", Text: fullResultString}}, // Use local Part, TextPart
 		Timestamp:       time.Now().UTC(),                                       // Added timestamp
+		Truncated:       llm.ResponseInfoFrom(ctx).Truncated(),                  // Set when the caller asked for the ResponseInfo
+		Provenance:      messageProvenance(llm.ResponseInfoFrom(ctx)),
 	}
 
 	// Parse the XML tool calls from the RawToolCallsXML field
@@ -104,7 +116,7 @@ func HandleLLMExecution(
 	_, updateErr := taskStore.UpdateTask(taskID, func(task *Task) error { // Use local Task
 		// Append the assistant message with the parsed tool calls
 		task.Messages = append(task.Messages, assistantMessage) // Use Messages field
-		task.Error = ""                                         // Clear any previous error
+		setTaskError(task, nil)                                 // Clear any previous error
 		return nil
 	})
 	if updateErr != nil {
@@ -138,6 +150,9 @@ func HandleLLMExecution(
 	} else {
 		// If no tool calls and no input required, the task is truly completed
 		log.Printf("[Task %s] No tool calls or input required. Setting state to COMPLETED.", taskID)
+		if task, err := taskStore.GetTask(taskID); err == nil && task.OutputContract != nil {
+			finalState = TaskStateWorking // Completed by the executor once the output contract is met
+		}
 	}
 
 	// Update the task state to the determined final state
@@ -160,13 +175,19 @@ func handleLLMExecutionStream(
 	taskStore TaskStore, // Use local TaskStore
 	messages []llm.Message,
 	sseWriter *SSEWriter,
-	toolDispatcher *ToolDispatcher, // Add ToolDispatcher
+	toolDispatcher ToolDispatcher, // Add ToolDispatcher
 ) (fullResultString string, inputTokens, completionTokens int, requiresInput bool, assistantMessageSavedByHandler bool, err error) {
 
 	assistantMessageSavedByHandler = false // This handler does not save the message itself
 	log.Printf("[Task %s Stream] Sending prompt to LLM for streaming...\n", taskID)
 	// The sseWriter will receive the raw stream, including any XML block.
-	fullResultString, inputTokens, completionTokens, llmErr := llmClient.Chat(ctx, messages, true, sseWriter)
+	codeBlocks := newCodeBlockTracker(sseWriter, taskID)
+	var out io.Writer = codeBlocks
+	if sink := outputSinkFrom(ctx); sink != nil {
+		out = io.MultiWriter(codeBlocks, sink)
+	}
+	fullResultString, inputTokens, completionTokens, llmErr := llm.ChatWithContinuation(ctx, llmClient, messages, true, out)
+	codeBlocks.Close()
 
 	if llmErr != nil {
 		fmt.Printf("[Task %s Stream] LLM Error. Input Tokens: %d\n", taskID, inputTokens)
@@ -179,12 +200,13 @@ func handleLLMExecutionStream(
 			log.Printf("[Task %s Stream] LLM stream failed: %v\n", taskID, llmErr)
 		}
 
-		taskStore.UpdateTask(taskID, func(task *Task) error { task.Error = errMsg; return nil }) // Use local Task
+		detail := llmErrorDetail(llmErr, llmClient)
+		detail.Message = errMsg
+		taskStore.UpdateTask(taskID, func(task *Task) error { setTaskError(task, detail); return nil }) // Use local Task
 		setStateErr := taskStore.SetState(taskID, finalState)
 
 		if setStateErr == nil && finalState == TaskStateFailed { // Use local TaskStateFailed
-			failedStateData, _ := json.Marshal(map[string]interface{}{"status": string(TaskStateFailed), "error": errMsg}) // Use local TaskStateFailed
-			sseWriter.SendEvent("state", string(failedStateData))
+			sseWriter.SendEvent("state", failedStateEvent(detail))
 		} else if setStateErr != nil {
 			log.Printf("[Task %s Stream] Failed to set final task state to %s after LLM error: %v\n", taskID, finalState, setStateErr)
 		}
@@ -205,3 +227,17 @@ func handleLLMExecutionStream(
 
 	return fullResultString, inputTokens, completionTokens, requiresInput, false, nil // assistantMessageSavedByHandler is false
 }
+
+// messageProvenance returns the provenance recorded in info, or nil.
+func messageProvenance(info *llm.ResponseInfo) *MessageProvenance {
+	if info == nil {
+		return nil
+	}
+	return &MessageProvenance{
+		Provider:    info.Provider,
+		Model:       info.Model,
+		Temperature: info.Temperature,
+		MaxTokens:   info.MaxTokens,
+		LatencyMs:   info.Latency.Milliseconds(),
+	}
+}
@@ -0,0 +1,69 @@
+package a2a
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"ka/llm"
+)
+
+func TestTaskMaxOutputTokensMarksTruncatedAnswer(t *testing.T) {
+	var mu sync.Mutex
+	var maxTokens []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req llm.Request
+		json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		maxTokens = append(maxTokens, req.MaxTokens)
+		mu.Unlock()
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"Partial\"}}]}\n\n")
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{},\"finish_reason\":\"length\"}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	store := NewInMemoryTaskStore()
+	te := NewTaskExecutor(&llm.LMStudioClient{APIURL: server.URL, Model: "test", MaxContextLength: 1000}, store, nil, "")
+	te.MaxContinuations = 1
+	msg := Message{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "write an essay"}}}
+	task, rpcErr := te.createTask(context.Background(), "essay", SendTaskParams{Message: msg, MaxOutputTokens: 8})
+	if rpcErr != nil {
+		t.Fatalf("createTask: %v", rpcErr)
+	}
+
+	te.ExecuteTask(context.Background(), task)
+	waitForState(t, store, task.ID, TaskStateCompleted)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(maxTokens) != 2 || maxTokens[0] != 8 || maxTokens[1] != 8 {
+		t.Errorf("expected a request and one continuation limited to 8 tokens, got %v", maxTokens)
+	}
+	done, _ := store.GetTask(task.ID)
+	last := done.Messages[len(done.Messages)-1]
+	if last.Role != RoleAssistant || !last.Truncated {
+		t.Fatalf("expected a truncated assistant answer, got %+v", last)
+	}
+	if text := last.Parts[0].(TextPart).Text; text != "PartialPartial" {
+		t.Errorf("expected the pieces to be stitched, got %q", text)
+	}
+	if p := last.Provenance; p == nil || p.Provider != "lmstudio" || p.Model != "test" || p.MaxTokens != 8 || p.Temperature == nil || *p.Temperature != 0.6 {
+		t.Errorf("expected the provider, model and sampling parameters on the answer, got %+v", p)
+	}
+}
+
+func TestCreateTaskRejectsNegativeTokenLimits(t *testing.T) {
+	te := NewTaskExecutor(&staticLLMClient{reply: "ok"}, NewInMemoryTaskStore(), nil, "")
+	msg := Message{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "hi"}}}
+	if _, rpcErr := te.createTask(context.Background(), "negative", SendTaskParams{Message: msg, MaxOutputTokens: -1}); rpcErr == nil {
+		t.Errorf("expected a negative maxOutputTokens to be rejected")
+	}
+	if _, rpcErr := te.createTask(context.Background(), "negative", SendTaskParams{Message: msg, MaxContinuations: -1}); rpcErr == nil {
+		t.Errorf("expected a negative maxContinuations to be rejected")
+	}
+}
@@ -3,7 +3,9 @@ package a2a
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"ka/llm"
 	"ka/tools" // Added import for tools package
 	"log"
 	"strings"
@@ -13,8 +15,29 @@ import (
 // ExecuteTask runs a task to completion, handling LLM calls and tool execution.
 // It does NOT stream output.
 func (te *TaskExecutor) ExecuteTask(ctx context.Context, t *Task) {
+	if !te.claimRun(t.ID) {
+		log.Printf("[Task %s] Execution already running. Ignoring duplicate start.", t.ID)
+		return
+	}
+	defer te.handleSubTaskEnd(t.ID) // Runs after the claim is released, so a retry can start
+	defer te.releaseRun(t.ID)
+	te.runTask(ctx, t)
+}
+
+// runTask is the execution loop of ExecuteTask. The caller must hold the task's run claim.
+func (te *TaskExecutor) runTask(ctx context.Context, t *Task) {
 	log.Printf("[Task %s] Starting execution.", t.ID)
 	defer log.Printf("[Task %s] Execution finished.", t.ID)
+	defer te.startHeartbeat(t.ID)()
+	ctx, stopShutdown := te.withShutdown(ctx)
+	defer stopShutdown()
+	defer te.interruptIfShutDown(ctx, t.ID, nil)
+	checkpoints := te.startCheckpoints(t.ID)
+	defer checkpoints.saveIfShutDown(ctx)
+	ctx, cancel := te.withTaskDeadline(ctx, t.ID)
+	ctx = llm.WithPriority(ctx, t.Priority)
+	defer cancel()
+	defer te.failIfTimedOut(ctx, t.ID)
 
 	// Ensure task state is Working
 	if err := te.TaskStore.SetState(t.ID, TaskStateWorking); err != nil {
@@ -23,15 +46,16 @@ func (te *TaskExecutor) ExecuteTask(ctx context.Context, t *Task) {
 		te.TaskStore.SetState(t.ID, TaskStateFailed)
 		return // Stop execution if initial state cannot be set
 	}
-
-	// Get or create the resume channel for this task
-	resumeCh := te.getOrCreateResumeChannel(t.ID)
-	defer te.deleteResumeChannel(t.ID) // Clean up the channel when execution finishes
+	te.recordRunStart(t.ID)
+	defer te.recordRunEnd(t.ID)
 
 	// Main task execution loop
 	for {
 		select {
 		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return // Failed with a timeout error by the deferred check
+			}
 			log.Printf("[Task %s] Context cancelled. Stopping execution.", t.ID)
 			te.TaskStore.SetState(t.ID, TaskStateCanceled)
 			return // Exit the goroutine
@@ -40,7 +64,10 @@ func (te *TaskExecutor) ExecuteTask(ctx context.Context, t *Task) {
 		}
 
 		// Process one iteration of the task logic
-		continueLoop, err := te.processTaskIteration(ctx, t, resumeCh)
+		continueLoop, err := te.processTaskIteration(ctx, t)
+		if err == nil && ctx.Err() == nil {
+			checkpoints.iterationDone()
+		}
 		if err != nil {
 			log.Printf("[Task %s] Iteration error: %v. Stopping execution.", t.ID, err)
 			// State should already be Failed if processTaskIteration returned an error
@@ -61,26 +88,51 @@ func (te *TaskExecutor) ExecuteTaskStream(ctx context.Context, t *Task, sseWrite
 	log.Printf("[Task %s Stream] Starting execution.", t.ID)
 	defer log.Printf("[Task %s Stream] Execution finished.", t.ID)
 
+	if !te.claimRun(t.ID) {
+		log.Printf("[Task %s Stream] Execution already running. Ignoring duplicate start.", t.ID)
+		sseWriter.SendEvent("state", failedStateEvent(&ErrorDetail{Code: ErrorCodeAlreadyRunning, Message: "Task is already running", Hint: "Wait for the running execution to finish or cancel the task."}))
+		return
+	}
+	defer te.handleSubTaskEnd(t.ID)
+	defer te.releaseRun(t.ID)
+	defer te.startHeartbeat(t.ID)()
+	defer te.forwardSubTaskStatus(ctx, t.ID, sseWriter)()
+	ctx, stopShutdown := te.withShutdown(ctx)
+	defer stopShutdown()
+	defer te.interruptIfShutDown(ctx, t.ID, sseWriter)
+	checkpoints := te.startCheckpoints(t.ID)
+	defer checkpoints.saveIfShutDown(ctx)
+	ctx, cancel := te.withTaskDeadline(ctx, t.ID)
+	ctx = llm.WithPriority(ctx, t.Priority)
+	defer cancel()
+	defer func() {
+		if te.failIfTimedOut(ctx, t.ID) {
+			if task, err := te.TaskStore.GetTask(t.ID); err == nil && task.ErrorDetail != nil {
+				sseWriter.SendEvent("state", failedStateEvent(task.ErrorDetail))
+			}
+		}
+	}()
+
 	// Ensure task state is Working and send SSE update
 	if err := te.TaskStore.SetState(t.ID, TaskStateWorking); err != nil {
 		log.Printf("[Task %s Stream] Failed to set state to Working: %v", t.ID, err)
 		// Attempt to set state to Failed and send SSE update
 		te.TaskStore.SetState(t.ID, TaskStateFailed)
-		failedStateData, _ := json.Marshal(map[string]interface{}{"status": string(TaskStateFailed), "error": fmt.Sprintf("Failed to set initial state: %v", err)})
-		sseWriter.SendEvent("state", string(failedStateData))
+		sseWriter.SendEvent("state", failedStateEvent(internalErrorDetail("Failed to set initial state: %v", err)))
 		return // Stop execution
 	}
+	te.recordRunStart(t.ID)
+	defer te.recordRunEnd(t.ID)
 	workingStateData, _ := json.Marshal(map[string]string{"status": string(TaskStateWorking)})
 	sseWriter.SendEvent("state", string(workingStateData))
 
-	// Get or create the resume channel for this task
-	resumeCh := te.getOrCreateResumeChannel(t.ID)
-	defer te.deleteResumeChannel(t.ID) // Clean up the channel when execution finishes
-
 	// Main task execution loop
 	for {
 		select {
 		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return // Failed with a timeout error by the deferred check
+			}
 			log.Printf("[Task %s Stream] Context cancelled. Stopping execution.", t.ID)
 			te.TaskStore.SetState(t.ID, TaskStateCanceled)
 			// Optionally send a final SSE event for cancellation
@@ -90,7 +142,10 @@ func (te *TaskExecutor) ExecuteTaskStream(ctx context.Context, t *Task, sseWrite
 		}
 
 		// Process one iteration of the task logic (streaming version)
-		continueLoop, err := te.processTaskStreamIteration(ctx, t, sseWriter, resumeCh)
+		continueLoop, err := te.processTaskStreamIteration(ctx, t, sseWriter)
+		if err == nil && ctx.Err() == nil {
+			checkpoints.iterationDone()
+		}
 		if err != nil {
 			log.Printf("[Task %s Stream] Iteration error: %v. Stopping execution.", t.ID, err)
 			// Error logging and state/SSE updates are handled within processTaskStreamIteration
@@ -169,7 +224,8 @@ func (te *TaskExecutor) AddTaskMessageAndProcess(taskID string, message Message)
 	// The UpdateTask function handles updating the timestamp and saving to the store.
 	_, err = te.TaskStore.UpdateTask(taskID, func(t *Task) error { // Corrected call to UpdateTask
 		t.Messages = append(t.Messages, message) // Append message inside the update function
-		t.State = newState // Update state inside the update function
+		t.State = newState                       // Update state inside the update function
+		t.InputRequest = nil                     // Any pending question is answered by the new message
 		// UpdateTask itself handles updating UpdatedAt and UpdatedAtUnixMs
 		return nil
 	})
@@ -180,90 +236,90 @@ func (te *TaskExecutor) AddTaskMessageAndProcess(taskID string, message Message)
 	}
 	log.Printf("[Task %s] Task updated in store after adding user message.", taskID)
 
-
-	// 5. Signal the executor to re-process the task
-	// If the task was waiting for input, signal its resume channel.
-	if originalState == TaskStateInputRequired {
-		resumeCh, ok := te.resumeChannels[taskID]
-		if ok {
-			select {
-			case resumeCh <- struct{}{}:
-				log.Printf("[Task %s] Signaled resume channel.", taskID)
-			default:
-				log.Printf("[Task %s] Resume channel was not ready to receive signal. Task might not have been waiting for input.", taskID)
-				// This might happen if the state was just changed from InputRequired by the executor loop
-				// right before we tried to signal.
-			}
-		} else {
-			log.Printf("[Task %s] No resume channel found for task in InputRequired state. Executor loop should pick it up.", taskID)
+	// 5. Relaunch execution from the store. A parked task (input-required, completed
+	// or failed) has no running goroutine; a task that is still running picks up the
+	// new message in its next iteration.
+	if newState == TaskStateWorking {
+		if task, err := te.TaskStore.GetTask(taskID); err == nil && te.launchRunLocked(task) {
+			log.Printf("[Task %s] Relaunched execution after adding user message.", taskID)
+		} else if err == nil {
+			log.Printf("[Task %s] Execution already running. It will pick up the new message.", taskID)
 		}
-	} else if newState == TaskStateWorking {
-		// If the task is now in the working state (and wasn't waiting for input),
-		// the main executor loop should pick it up.
-		// For immediate processing/interruption, a dedicated channel for tasks needing attention
-		// could be added to TaskExecutor and monitored by the main loop.
-		// For now, we rely on the executor's polling/looping mechanism.
-		log.Printf("[Task %s] Task state is now 'working'. Executor loop should pick it up.", taskID)
-		// TODO: Implement a more immediate signaling mechanism if needed for responsiveness.
 	}
 
-
 	log.Printf("[Task %s] AddTaskMessageAndProcess completed successfully.", taskID)
 	return nil // Success
 }
 
-
-// ResumeTask signals a task that is waiting for input to resume execution.
+// ResumeTask relaunches a parked task that is waiting for input. The task is
+// loaded from the store, so tasks parked before a restart can be resumed too.
 func (te *TaskExecutor) ResumeTask(taskID string) error {
 	te.mu.Lock()
 	defer te.mu.Unlock()
 
-	resumeCh, ok := te.resumeChannels[taskID]
-	if !ok {
-		return fmt.Errorf("no active task found with ID %s waiting for input", taskID)
+	task, err := te.TaskStore.GetTask(taskID)
+	if err != nil {
+		return fmt.Errorf("error retrieving task %s: %w", taskID, err)
 	}
-
-	// Signal the task to resume
-	select {
-	case resumeCh <- struct{}{}:
-		log.Printf("[Task %s] Signaled to resume.", taskID)
-		return nil
-	default:
-		// This case should ideally not be hit if the task is correctly waiting on the channel
-		return fmt.Errorf("task %s is not currently waiting for input", taskID)
+	if task.State != TaskStateInputRequired {
+		return fmt.Errorf("task %s is not waiting for input (state: %s)", taskID, task.State)
 	}
+	if !te.launchRunLocked(task) {
+		return fmt.Errorf("task %s is already running", taskID)
+	}
+	log.Printf("[Task %s] Resumed from parked state.", taskID)
+	return nil
 }
 
-// getOrCreateResumeChannel gets the resume channel for a task, creating it if it doesn't exist.
-func (te *TaskExecutor) getOrCreateResumeChannel(taskID string) chan struct{} {
+// claimRun marks a task as having an active execution. It returns false if one is already running.
+func (te *TaskExecutor) claimRun(taskID string) bool {
 	te.mu.Lock()
 	defer te.mu.Unlock()
+	return te.claimRunLocked(taskID)
+}
 
-	ch, ok := te.resumeChannels[taskID]
-	if !ok {
-		ch = make(chan struct{}, 1) // Use a buffered channel of size 1
-		te.resumeChannels[taskID] = ch
-		log.Printf("[Task %s] Created resume channel.", taskID)
+func (te *TaskExecutor) claimRunLocked(taskID string) bool {
+	if te.activeRuns[taskID] {
+		return false
 	}
-	return ch
+	te.activeRuns[taskID] = true
+	return true
+}
+
+// releaseRun clears the active execution mark of a task.
+func (te *TaskExecutor) releaseRun(taskID string) {
+	te.mu.Lock()
+	defer te.mu.Unlock()
+	delete(te.activeRuns, taskID)
 }
 
-// deleteResumeChannel removes the resume channel for a task.
-func (te *TaskExecutor) deleteResumeChannel(taskID string) {
+// IsRunning reports whether a goroutine is currently executing the task.
+// Parked tasks are not running.
+func (te *TaskExecutor) IsRunning(taskID string) bool {
 	te.mu.Lock()
 	defer te.mu.Unlock()
+	return te.activeRuns[taskID]
+}
 
-	if ch, ok := te.resumeChannels[taskID]; ok {
-		close(ch) // Close the channel
-		delete(te.resumeChannels, taskID)
-		log.Printf("[Task %s] Deleted resume channel.", taskID)
+// launchRunLocked starts a background execution of the task unless one is
+// already active. The run is claimed before the goroutine starts so concurrent
+// resumes cannot launch it twice. te.mu must be held.
+func (te *TaskExecutor) launchRunLocked(task *Task) bool {
+	if !te.claimRunLocked(task.ID) {
+		return false
 	}
+	go func() {
+		defer te.handleSubTaskEnd(task.ID)
+		defer te.releaseRun(task.ID)
+		te.runTask(context.Background(), task)
+	}()
+	return true
 }
 
 // processTaskIteration handles a single iteration of the main loop for ExecuteTask.
 // It returns true if the loop should continue (due to INPUT_REQUIRED or tool calls), false otherwise.
 // It also returns any error encountered during the iteration that should stop the process.
-func (te *TaskExecutor) processTaskIteration(ctx context.Context, t *Task, resumeCh chan struct{}) (continueLoop bool, err error) {
+func (te *TaskExecutor) processTaskIteration(ctx context.Context, t *Task) (continueLoop bool, err error) {
 	currentTask, err := te.TaskStore.GetTask(t.ID)
 	if err != nil {
 		log.Printf("[Task %s] Error getting task from store during execution: %v", t.ID, err)
@@ -273,12 +329,17 @@ func (te *TaskExecutor) processTaskIteration(ctx context.Context, t *Task, resum
 		log.Printf("[Task %s] Task was cancelled externally. Stopping.", t.ID)
 		return false, nil // Not an error, but stop processing
 	}
+	if resumed, continueLoop, err := te.resumeToolCalls(te.modeContext(ctx, currentTask), currentTask); resumed {
+		return continueLoop, err
+	}
 
 	// Build messages for the LLM using the helper function, using the task's SystemPrompt and all messages
-	llmMessages, contentFound, extractErr := buildPromptFromInput(t.ID, currentTask.Messages, currentTask.SystemPrompt) // Use currentTask.Messages
+	ctx = te.modeContext(ctx, currentTask)
+	spillDataParts(te.TaskStore, currentTask)
+	llmMessages, contentFound, extractErr := buildPromptFromInput(te.fileClient(), t.ID, currentTask.Messages, currentTask.SystemPrompt) // Use currentTask.Messages
 	if extractErr != nil {
 		te.TaskStore.UpdateTask(t.ID, func(task *Task) error {
-			task.Error = extractErr.Error()
+			setTaskError(task, noPromptContentDetail(extractErr))
 			return nil
 		})
 		te.TaskStore.SetState(t.ID, TaskStateFailed)
@@ -288,13 +349,18 @@ func (te *TaskExecutor) processTaskIteration(ctx context.Context, t *Task, resum
 	if !contentFound {
 		errMsg := "could not extract suitable content from messages for LLM"
 		te.TaskStore.UpdateTask(t.ID, func(task *Task) error {
-			task.Error = errMsg
+			setTaskError(task, noPromptContentDetail(nil))
 			return nil
 		})
 		te.TaskStore.SetState(t.ID, TaskStateFailed)
 		fmt.Printf("[Task %s] Failed: %s\n", t.ID, errMsg)
 		return false, fmt.Errorf(errMsg) // Stop processing
 	}
+	llmMessages = appendToolFailureNote(llmMessages, currentTask.ToolFailures)
+	ctx, blocked := te.checkLLMGuardrails(ctx, currentTask)
+	if blocked != nil {
+		return false, nil
+	}
 
 	// Log the messages being sent to the LLM
 	logMessages := ""
@@ -312,13 +378,45 @@ func (te *TaskExecutor) processTaskIteration(ctx context.Context, t *Task, resum
 	// Call the extracted LLM execution handler
 	// Pass nil for sseWriter as this is the non-streaming path
 	// Pass the toolDispatcher
-	fullResultString, _, _, requiresInput, assistantMessageSaved, llmErr := HandleLLMExecution(ctx, t.ID, te.LLMClient, te.TaskStore, llmMessages, nil, NewToolDispatcher(te.TaskStore, te.AvailableTools))
+	llmClient, err := te.clientFor(currentTask)
+	if err != nil {
+		te.TaskStore.UpdateTask(t.ID, func(task *Task) error { setTaskError(task, clientErrorDetail(currentTask, err)); return nil })
+		te.TaskStore.SetState(t.ID, TaskStateFailed)
+		log.Printf("[Task %s] Failed: %v", t.ID, err)
+		return false, err
+	}
+	release, duplicate := te.guardIteration(currentTask, llmMessages)
+	if duplicate {
+		return false, nil
+	}
+	defer release()
+	llmMessages, exceeded := te.fitContext(ctx, currentTask, llmClient, llmMessages)
+	if exceeded != nil {
+		return false, errors.New(exceeded.Message)
+	}
+	responseInfo := &llm.ResponseInfo{}
+	ctx = llm.WithResponseInfo(ctx, responseInfo)
+	ctx, outputSink := te.outputArtifactSink(ctx, currentTask)
+	defer outputSink.finish()
+	llmStart := time.Now()
+	fullResultString, inputTokens, outputTokens, requiresInput, assistantMessageSaved, llmErr := HandleLLMExecution(ctx, t.ID, llmClient, te.TaskStore, llmMessages, nil, te.toolDispatcher())
+	te.addExecutionTime(t.ID, time.Since(llmStart), 0)
+	te.emitLLMCall(currentTask, responseInfo, inputTokens, outputTokens, time.Since(llmStart), llmErr)
+	outputSink.Close() // The output is complete; errors are reported by finish
+
+	exceeded = te.addTokenUsage(t.ID, inputTokens, outputTokens)
 
 	// Handle LLM error returned by the handler
 	if llmErr != nil {
 		// Error logging and state setting is handled within handleLLMExecution
 		return false, llmErr // Stop processing on LLM error
 	}
+	if exceeded != nil {
+		return false, errors.New(exceeded.Message)
+	}
+	if currentTask.Debug {
+		te.recordTrace(t.ID, llmMessages, fullResultString)
+	}
 
 	// After LLM execution, check the *latest* task state and output for tool calls
 	updatedTask, err := te.TaskStore.GetTask(t.ID)
@@ -358,78 +456,44 @@ func (te *TaskExecutor) processTaskIteration(ctx context.Context, t *Task, resum
 			te.TaskStore.SetState(t.ID, TaskStateFailed) // Attempt to set failed state
 			return false, setStateErr                    // Stop processing
 		}
-		fmt.Printf("[Task %s] State set to InputRequired. Waiting for resume signal...\n", t.ID)
-
-		// Wait for the resume signal or context cancellation
-		select {
-		case <-resumeCh:
-			fmt.Printf("[Task %s] Resume signal received. Continuing loop.\n", t.ID)
-			// Set state back to Working before the next iteration
-			if err := te.TaskStore.SetState(t.ID, TaskStateWorking); err != nil {
-				log.Printf("[Task %s] Failed to set state back to Working after resume: %v", t.ID, err)
-				return false, err // Stop processing if we can't reset state
-			}
-			return true, nil // Continue the loop
-		case <-ctx.Done():
-			fmt.Printf("[Task %s] Context cancelled while waiting for input. Exiting.\n", t.ID)
-			te.TaskStore.SetState(t.ID, TaskStateCanceled) // Set final state
-			return false, ctx.Err()                        // Stop processing due to cancellation
-		}
+		recordInputRequest(te.TaskStore, t.ID, lastAssistantMessage.ParsedToolCalls)
+		// Park the task: the state is persisted and ResumeTask relaunches execution from the store.
+		log.Printf("[Task %s] State set to InputRequired. Parking until input arrives.", t.ID)
+		return false, nil
 
 	} else if lastAssistantMessage != nil && len(lastAssistantMessage.ParsedToolCalls) > 0 {
 		// If other tool calls were detected (and ask_followup_question was NOT)
 		log.Printf("[Task %s] Detected %d other tool calls in LLM response.", t.ID, len(lastAssistantMessage.ParsedToolCalls))
+		if currentTask.Debug && !te.waitForStep(ctx, t.ID, lastAssistantMessage.ParsedToolCalls, nil) {
+			return false, nil
+		}
 
 		// Pass the map of available tools to the dispatcher
-		toolDispatcher := NewToolDispatcher(te.TaskStore, te.AvailableTools) // Pass te.AvailableTools
+		toolDispatcher := te.toolDispatcher()
 
 		toolResults := []Message{}
+		toolStart := time.Now()
 		for _, toolCall := range lastAssistantMessage.ParsedToolCalls {
 			toolResultMsg, dispatchErr := toolDispatcher.DispatchToolCall(ctx, t.ID, toolCall)
+			if te.checkpointToolResults(ctx, t.ID, toolResults) {
+				return false, nil
+			}
 			if dispatchErr != nil {
 				log.Printf("[Task %s] Error dispatching tool call %s (%s): %v", t.ID, toolCall.ID, toolCall.Function.Name, dispatchErr)
 			}
 			toolResults = append(toolResults, toolResultMsg)
 		}
+		te.addExecutionTime(t.ID, 0, time.Since(toolStart))
 
 		// Process tool results for any special sentinel values (e.g., new task requests)
-		processedToolResults := []Message{}
 		for _, resMsg := range toolResults {
-			if len(resMsg.Parts) > 0 {
-				if textPart, ok := resMsg.Parts[0].(TextPart); ok {
-					if strings.HasPrefix(textPart.Text, tools.AddTaskSentinelPrefix) {
-						jsonData := strings.TrimPrefix(textPart.Text, tools.AddTaskSentinelPrefix)
-						var newTaskData tools.NewTaskRequestData
-						if err := json.Unmarshal([]byte(jsonData), &newTaskData); err != nil {
-							log.Printf("[Task %s] Error unmarshalling new task request data: %v. Raw: %s", t.ID, err, jsonData)
-							resMsg.Parts[0] = TextPart{Type: "text", Text: fmt.Sprintf("Error processing add_task tool: failed to parse request data: %v", err)}
-						} else {
-							log.Printf("[Task %s] Received new task request: Name='%s', Parent='%s'", t.ID, newTaskData.Name, newTaskData.ParentTaskID)
-							initialUserMessage := Message{
-								Role: RoleUser,
-								Parts: []Part{TextPart{Type: "text", Text: newTaskData.Description}},
-								Timestamp: time.Now().UTC(),
-							}
-							newTask, err := te.TaskStore.CreateTask(newTaskData.Name, newTaskData.SystemPrompt, []Message{initialUserMessage}, newTaskData.ParentTaskID)
-							if err != nil {
-								log.Printf("[Task %s] Error creating new sub-task via add_task tool: %v", t.ID, err)
-								resMsg.Parts[0] = TextPart{Type: "text", Text: fmt.Sprintf("Error creating new task via add_task tool: %v", err)}
-							} else {
-								log.Printf("[Task %s] Successfully created new sub-task %s (Parent: %s) via add_task tool.", t.ID, newTask.ID, newTask.ParentTaskID)
-								resMsg.Parts[0] = TextPart{Type: "text", Text: fmt.Sprintf("New task %s created successfully.", newTask.ID)}
-							}
-						}
-					}
-				}
-			}
-			processedToolResults = append(processedToolResults, resMsg)
+			te.createRequestedSubTask(fmt.Sprintf("[Task %s]", t.ID), currentTask, resMsg)
 		}
-		toolResults = processedToolResults // Use the processed results
 
 		// Append tool results to the task's messages for the next LLM iteration
 		_, updateErr := te.TaskStore.UpdateTask(t.ID, func(task *Task) error {
 			task.Messages = append(task.Messages, toolResults...) // Append all tool result messages to Messages
-			task.Error = ""                                       // Clear any previous error
+			setTaskError(task, nil)                               // Clear any previous error
 			return nil
 		})
 		if updateErr != nil {
@@ -438,6 +502,17 @@ func (te *TaskExecutor) processTaskIteration(ctx context.Context, t *Task, resum
 			return false, updateErr                      // Stop processing
 		}
 
+		if te.hasPendingApproval(t.ID) {
+			log.Printf("[Task %s] Tool calls wait for approval. Parking until tasks/approve.", t.ID)
+			if err := te.TaskStore.SetState(t.ID, TaskStateInputRequired); err != nil {
+				return false, err
+			}
+			return false, nil
+		}
+		if te.parkForEvent(t.ID, lastAssistantMessage.ParsedToolCalls) {
+			return false, nil
+		}
+
 		log.Printf("[Task %s] Appended %d tool result messages to messages. Continuing loop.", t.ID, len(toolResults))
 		// Set state back to Working before the next iteration
 		if err := te.TaskStore.SetState(t.ID, TaskStateWorking); err != nil {
@@ -450,10 +525,10 @@ func (te *TaskExecutor) processTaskIteration(ctx context.Context, t *Task, resum
 		// Process the result based on whether input is required (if no tool calls were made, but [INPUT_REQUIRED] was present)
 		log.Printf("[Task %s] Input Required detected in full response (no tool calls).", t.ID)
 		if !assistantMessageSaved { // Only add if HandleLLMExecution didn't already
-			outputMessage := Message{Role: RoleAssistant, Parts: []Part{TextPart{Type: "text", Text: fullResultString}}}
+			outputMessage := Message{Role: RoleAssistant, Parts: []Part{TextPart{Type: "text", Text: fullResultString}}, Truncated: responseInfo.Truncated(), Provenance: messageProvenance(responseInfo)}
 			_, updateErr := te.TaskStore.UpdateTask(t.ID, func(task *Task) error {
 				task.Messages = append(task.Messages, outputMessage) // Append to Messages
-				task.Error = ""                                      // Clear any previous error
+				setTaskError(task, nil)                              // Clear any previous error
 				return nil
 			})
 			if updateErr != nil {
@@ -468,44 +543,39 @@ func (te *TaskExecutor) processTaskIteration(ctx context.Context, t *Task, resum
 			te.TaskStore.SetState(t.ID, TaskStateFailed) // Attempt to set failed state
 			return false, setStateErr                    // Stop processing
 		}
-		fmt.Printf("[Task %s] State set to InputRequired. Waiting for resume signal...\n", t.ID)
-
-		// Wait for the resume signal or context cancellation
-		select {
-		case <-resumeCh:
-			fmt.Printf("[Task %s] Resume signal received. Continuing loop.\n", t.ID)
-			// Set state back to Working before the next iteration
-			if err := te.TaskStore.SetState(t.ID, TaskStateWorking); err != nil {
-				log.Printf("[Task %s] Failed to set state back to Working after resume: %v", t.ID, err)
-				return false, err // Stop processing if we can't reset state
-			}
-			return true, nil // Continue the loop
-		case <-ctx.Done():
-			fmt.Printf("[Task %s] Context cancelled while waiting for input. Exiting.\n", t.ID)
-			te.TaskStore.SetState(t.ID, TaskStateCanceled) // Set final state
-			return false, ctx.Err()                        // Stop processing due to cancellation
-		}
+		// Park the task: the state is persisted and ResumeTask relaunches execution from the store.
+		log.Printf("[Task %s] State set to InputRequired. Parking until input arrives.", t.ID)
+		return false, nil
 
 	} else {
 		// Task Completed Successfully (No Input Required and No Tool Calls)
 		log.Printf("[Task %s] Task completed normally (no input required, no tool calls).", t.ID)
+		if te.checkCompletionGuardrails(t.ID, fullResultString) != nil {
+			return false, nil
+		}
+		fullResultString = te.processFinalOutput(t.ID, fullResultString, assistantMessageSaved)
 		if !assistantMessageSaved { // Only add if HandleLLMExecution didn't already
-			outputMessage := Message{Role: RoleAssistant, Parts: []Part{TextPart{Type: "text", Text: fullResultString}}}
+			outputMessage := Message{Role: RoleAssistant, Parts: []Part{TextPart{Type: "text", Text: fullResultString}}, Truncated: responseInfo.Truncated(), Provenance: messageProvenance(responseInfo)}
 			// Update task messages
 			_, updateErr := te.TaskStore.UpdateTask(t.ID, func(task *Task) error {
 				task.Messages = append(task.Messages, outputMessage) // Append to Messages
-				task.Error = ""                                      // Clear any previous error
+				setTaskError(task, nil)                              // Clear any previous error
 				return nil
 			})
 			if updateErr != nil {
 				log.Printf("[Task %s] Failed to update task with completed output: %v", t.ID, updateErr)
 			}
 		}
+		if corrected, failed := te.checkOutputContract(t.ID); corrected || failed != nil {
+			return corrected, nil
+		}
 
 		// Add artifact
-		artifactErr := te.TaskStore.AddArtifact(t.ID, Artifact{Type: "text/plain", Filename: "llm_response.txt", Data: []byte(fullResultString)})
-		if artifactErr != nil {
-			fmt.Printf("[Task %s] Warning: Failed to save result as artifact: %v\n", t.ID, artifactErr)
+		if outputSink == nil { // Otherwise the output artifact holds the response
+			artifactErr := te.TaskStore.AddArtifact(t.ID, Artifact{Type: "text/plain", Filename: "llm_response.txt", Data: []byte(fullResultString)})
+			if artifactErr != nil {
+				fmt.Printf("[Task %s] Warning: Failed to save result as artifact: %v\n", t.ID, artifactErr)
+			}
 		}
 
 		// State should have been set to COMPLETED by the first-write goroutine in handleLLMExecution.
@@ -527,12 +597,11 @@ func (te *TaskExecutor) processTaskIteration(ctx context.Context, t *Task, resum
 // processTaskStreamIteration handles a single iteration of the main loop for ExecuteTaskStream.
 // It returns true if the loop should continue (due to INPUT_REQUIRED or tool calls), false otherwise.
 // It also returns any error encountered during the iteration that should stop the process.
-func (te *TaskExecutor) processTaskStreamIteration(ctx context.Context, t *Task, sseWriter *SSEWriter, resumeCh chan struct{}) (continueLoop bool, err error) {
+func (te *TaskExecutor) processTaskStreamIteration(ctx context.Context, t *Task, sseWriter *SSEWriter) (continueLoop bool, err error) {
 	currentTask, err := te.TaskStore.GetTask(t.ID)
 	if err != nil {
 		log.Printf("[Task %s Stream] Error getting task from store: %v", t.ID, err)
-		failedStateData, _ := json.Marshal(map[string]interface{}{"status": string(TaskStateFailed), "error": fmt.Sprintf("Failed to get task: %v", err)})
-		sseWriter.SendEvent("state", string(failedStateData))
+		sseWriter.SendEvent("state", failedStateEvent(internalErrorDetail("Failed to get task: %v", err)))
 		return false, err
 	}
 	if currentTask.State == TaskStateCanceled {
@@ -542,24 +611,32 @@ func (te *TaskExecutor) processTaskStreamIteration(ctx context.Context, t *Task,
 	}
 
 	// Build messages for the LLM using the helper function, using the task's SystemPrompt and all messages
-	llmMessages, contentFound, extractErr := buildPromptFromInput(t.ID, currentTask.Messages, currentTask.SystemPrompt) // Use currentTask.Messages
+	ctx = te.modeContext(ctx, currentTask)
+	spillDataParts(te.TaskStore, currentTask)
+	llmMessages, contentFound, extractErr := buildPromptFromInput(te.fileClient(), t.ID, currentTask.Messages, currentTask.SystemPrompt) // Use currentTask.Messages
 	if extractErr != nil {
-		te.TaskStore.UpdateTask(t.ID, func(task *Task) error { task.Error = extractErr.Error(); return nil })
+		detail := noPromptContentDetail(extractErr)
+		te.TaskStore.UpdateTask(t.ID, func(task *Task) error { setTaskError(task, detail); return nil })
 		te.TaskStore.SetState(t.ID, TaskStateFailed)
 		fmt.Printf("[Task %s Stream] Failed during message building: %v\n", t.ID, extractErr)
-		failedStateData, _ := json.Marshal(map[string]interface{}{"status": string(TaskStateFailed), "error": extractErr.Error()})
-		sseWriter.SendEvent("state", string(failedStateData))
+		sseWriter.SendEvent("state", failedStateEvent(detail))
 		return false, extractErr // Stop processing
 	}
 	if !contentFound {
 		errMsg := "could not extract suitable content from messages for LLM"
-		te.TaskStore.UpdateTask(t.ID, func(task *Task) error { task.Error = errMsg; return nil })
+		detail := noPromptContentDetail(nil)
+		te.TaskStore.UpdateTask(t.ID, func(task *Task) error { setTaskError(task, detail); return nil })
 		te.TaskStore.SetState(t.ID, TaskStateFailed)
 		fmt.Printf("[Task %s Stream] Failed: %s\n", t.ID, errMsg)
-		failedStateData, _ := json.Marshal(map[string]interface{}{"status": string(TaskStateFailed), "error": errMsg})
-		sseWriter.SendEvent("state", string(failedStateData))
+		sseWriter.SendEvent("state", failedStateEvent(detail))
 		return false, fmt.Errorf(errMsg) // Stop processing
 	}
+	llmMessages = appendToolFailureNote(llmMessages, currentTask.ToolFailures)
+	ctx, blocked := te.checkLLMGuardrails(ctx, currentTask)
+	if blocked != nil {
+		sseWriter.SendEvent("state", failedStateEvent(blocked))
+		return false, nil
+	}
 
 	// Log the messages being sent to the LLM
 	logMessages := ""
@@ -576,20 +653,56 @@ func (te *TaskExecutor) processTaskStreamIteration(ctx context.Context, t *Task,
 
 	// Call the extracted LLM stream execution handler
 	// The NewToolDispatcher now correctly receives te.TaskStore and te.AvailableTools
-	fullResultString, _, _, requiresInput, assistantMessageSaved, llmErr := handleLLMExecutionStream(ctx, t.ID, te.LLMClient, te.TaskStore, llmMessages, sseWriter, NewToolDispatcher(te.TaskStore, te.AvailableTools))
+	llmClient, err := te.clientFor(currentTask)
+	if err != nil {
+		detail := clientErrorDetail(currentTask, err)
+		te.TaskStore.UpdateTask(t.ID, func(task *Task) error { setTaskError(task, detail); return nil })
+		te.TaskStore.SetState(t.ID, TaskStateFailed)
+		log.Printf("[Task %s Stream] Failed: %v", t.ID, err)
+		sseWriter.SendEvent("state", failedStateEvent(detail))
+		return false, err
+	}
+	release, duplicate := te.guardIteration(currentTask, llmMessages)
+	if duplicate {
+		sseWriter.SendEvent("state", failedStateEvent(&ErrorDetail{Code: ErrorCodeAlreadyRunning, Message: "Task is already running", Hint: "Another execution runs this iteration of the task; follow it with tasks/resubscribe or tasks/status."}))
+		return false, nil
+	}
+	defer release()
+	llmMessages, exceeded := te.fitContext(ctx, currentTask, llmClient, llmMessages)
+	if exceeded != nil {
+		sseWriter.SendEvent("state", failedStateEvent(exceeded))
+		return false, errors.New(exceeded.Message)
+	}
+	responseInfo := &llm.ResponseInfo{}
+	ctx = llm.WithResponseInfo(ctx, responseInfo)
+	ctx, outputSink := te.outputArtifactSink(ctx, currentTask)
+	defer outputSink.finish()
+	llmStart := time.Now()
+	fullResultString, inputTokens, outputTokens, requiresInput, assistantMessageSaved, llmErr := handleLLMExecutionStream(ctx, t.ID, llmClient, te.TaskStore, llmMessages, sseWriter, te.toolDispatcher())
+	te.addExecutionTime(t.ID, time.Since(llmStart), 0)
+	te.emitLLMCall(currentTask, responseInfo, inputTokens, outputTokens, time.Since(llmStart), llmErr)
+	outputSink.Close() // The output is complete; errors are reported by finish
+
+	exceeded = te.addTokenUsage(t.ID, inputTokens, outputTokens)
 
 	// Handle LLM error returned by the handler
 	if llmErr != nil {
 		// Error logging and state/SSE updates are handled within handleLLMExecutionStream
 		return false, llmErr // Stop processing
 	}
+	if exceeded != nil {
+		sseWriter.SendEvent("state", failedStateEvent(exceeded))
+		return false, errors.New(exceeded.Message)
+	}
+	if currentTask.Debug {
+		te.recordTrace(t.ID, llmMessages, fullResultString)
+	}
 
 	// After LLM execution, check the *latest* task state and output for tool calls
 	updatedTask, err := te.TaskStore.GetTask(t.ID)
 	if err != nil {
 		log.Printf("[Task %s Stream] Error getting task after LLM execution: %v", t.ID, err)
-		failedStateData, _ := json.Marshal(map[string]interface{}{"status": string(TaskStateFailed), "error": fmt.Sprintf("Failed to get task after LLM: %v", err)})
-		sseWriter.SendEvent("state", string(failedStateData))
+		sseWriter.SendEvent("state", failedStateEvent(internalErrorDetail("Failed to get task after LLM: %v", err)))
 		return false, err // Stop processing
 	}
 
@@ -617,112 +730,102 @@ func (te *TaskExecutor) processTaskStreamIteration(ctx context.Context, t *Task,
 		// If ask_followup_question was called, transition to InputRequired and wait
 		log.Printf("[Task %s Stream] Detected ask_followup_question tool call. Setting state to InputRequired.", t.ID)
 
+		inputRequest := recordInputRequest(te.TaskStore, t.ID, lastAssistantMessage.ParsedToolCalls)
 		setStateErr := te.TaskStore.SetState(t.ID, TaskStateInputRequired)
 		if setStateErr == nil {
-			inputRequiredStateData, _ := json.Marshal(map[string]string{"status": string(TaskStateInputRequired)})
+			inputRequiredStateData, _ := json.Marshal(map[string]interface{}{"status": string(TaskStateInputRequired), "inputRequest": inputRequest})
 			sseWriter.SendEvent("state", string(inputRequiredStateData))
 		} else {
 			log.Printf("[Task %s Stream] Failed to set task state to InputRequired: %v", t.ID, setStateErr)
-			failedStateData, _ := json.Marshal(map[string]interface{}{"status": string(TaskStateFailed), "error": "Failed to transition to input-required state"})
-			sseWriter.SendEvent("state", string(failedStateData))
-		}
-		fmt.Printf("[Task %s Stream] State set to InputRequired. Waiting for signal...\n", t.ID)
-
-		select {
-		case <-resumeCh:
-			fmt.Printf("[Task %s Stream] Resume signal received. Continuing loop.\n", t.ID)
-			workingStateData, _ := json.Marshal(map[string]string{"status": string(TaskStateWorking)})
-			sseWriter.SendEvent("state", string(workingStateData))
-			// Need to set state back to working in the store as well
-			if err := te.TaskStore.SetState(t.ID, TaskStateWorking); err != nil {
-				log.Printf("[Task %s Stream] Failed to set state back to Working after resume: %v", t.ID, err)
-				return false, err // Stop processing
-			}
-			return true, nil // Continue loop
-		case <-ctx.Done():
-			fmt.Printf("[Task %s Stream] Context cancelled while waiting for input. Exiting.\n", t.ID)
-			te.TaskStore.SetState(t.ID, TaskStateCanceled)
-			return false, ctx.Err() // Stop processing
+			sseWriter.SendEvent("state", failedStateEvent(internalErrorDetail("Failed to transition to input-required state")))
 		}
+		// Park the task and end the stream; the resumed run continues in the background.
+		log.Printf("[Task %s Stream] State set to InputRequired. Parking until input arrives.", t.ID)
+		return false, nil
 
 	} else if lastAssistantMessage != nil && len(lastAssistantMessage.ParsedToolCalls) > 0 {
 		// If other tool calls were detected (and ask_followup_question was NOT)
 		log.Printf("[Task %s Stream] Detected %d other tool calls in LLM response.", t.ID, len(lastAssistantMessage.ParsedToolCalls))
+		if currentTask.Debug && !te.waitForStep(ctx, t.ID, lastAssistantMessage.ParsedToolCalls, sseWriter) {
+			return false, nil
+		}
 
 		// Pass the map of available tools to the dispatcher
-		toolDispatcher := NewToolDispatcher(te.TaskStore, te.AvailableTools) // Pass te.AvailableTools
+		toolDispatcher := te.toolDispatcher()
+		dispatchCtx := withDispatchListeners(ctx, dispatchListeners{
+			onProgress: func(taskID string, progress tools.ToolProgress) {
+				progressData, _ := json.Marshal(map[string]interface{}{"taskId": taskID, "progress": progress})
+				sseWriter.SendEvent("progress", string(progressData))
+			},
+			onOutput: func(taskID string, output tools.ToolOutput) {
+				outputData, _ := json.Marshal(map[string]interface{}{"taskId": taskID, "output": output})
+				sseWriter.SendEvent("tool_output", string(outputData))
+			},
+		})
 
 		toolResults := []Message{}
+		toolStart := time.Now()
 		for _, toolCall := range lastAssistantMessage.ParsedToolCalls {
-			toolResultMsg, dispatchErr := toolDispatcher.DispatchToolCall(ctx, t.ID, toolCall)
+			toolResultMsg, dispatchErr := toolDispatcher.DispatchToolCall(dispatchCtx, t.ID, toolCall)
+			if te.checkpointToolResults(ctx, t.ID, toolResults) {
+				return false, nil
+			}
 			if dispatchErr != nil {
 				log.Printf("[Task %s Stream] Error dispatching tool call %s (%s): %v", t.ID, toolCall.ID, toolCall.Function.Name, dispatchErr)
 			}
 			toolResults = append(toolResults, toolResultMsg)
 		}
+		te.addExecutionTime(t.ID, 0, time.Since(toolStart))
 
 		// Process tool results for any special sentinel values (e.g., new task requests) - STREAMING VERSION
-		processedToolResults := []Message{}
 		for _, resMsg := range toolResults {
-			if len(resMsg.Parts) > 0 {
-				if textPart, ok := resMsg.Parts[0].(TextPart); ok {
-					if strings.HasPrefix(textPart.Text, tools.AddTaskSentinelPrefix) {
-						jsonData := strings.TrimPrefix(textPart.Text, tools.AddTaskSentinelPrefix)
-						var newTaskData tools.NewTaskRequestData
-						if err := json.Unmarshal([]byte(jsonData), &newTaskData); err != nil {
-							log.Printf("[Task %s Stream] Error unmarshalling new task request data: %v. Raw: %s", t.ID, err, jsonData)
-							resMsg.Parts[0] = TextPart{Type: "text", Text: fmt.Sprintf("Error processing add_task tool: failed to parse request data: %v", err)}
-						} else {
-							log.Printf("[Task %s Stream] Received new task request: Name='%s', Parent='%s'", t.ID, newTaskData.Name, newTaskData.ParentTaskID)
-							initialUserMessage := Message{
-								Role: RoleUser,
-								Parts: []Part{TextPart{Type: "text", Text: newTaskData.Description}},
-								Timestamp: time.Now().UTC(),
-							}
-							newTask, err := te.TaskStore.CreateTask(newTaskData.Name, newTaskData.SystemPrompt, []Message{initialUserMessage}, newTaskData.ParentTaskID)
-							if err != nil {
-								log.Printf("[Task %s Stream] Error creating new sub-task via add_task tool: %v", t.ID, err)
-								resMsg.Parts[0] = TextPart{Type: "text", Text: fmt.Sprintf("Error creating new task via add_task tool: %v", err)}
-							} else {
-								log.Printf("[Task %s Stream] Successfully created new sub-task %s (Parent: %s) via add_task tool.", t.ID, newTask.ID, newTask.ParentTaskID)
-								resMsg.Parts[0] = TextPart{Type: "text", Text: fmt.Sprintf("New task %s created successfully.", newTask.ID)}
-								newTaskCreationEventData, _ := json.Marshal(map[string]string{
-									"type":         "new_sub_task_created",
-									"parentTaskId": t.ID,
-									"newTaskId":    newTask.ID,
-									"newTaskName":  newTask.Name,
-								})
-								sseWriter.SendEvent("info", string(newTaskCreationEventData))
-							}
-						}
-					}
-				}
+			if newTask := te.createRequestedSubTask(fmt.Sprintf("[Task %s Stream]", t.ID), currentTask, resMsg); newTask != nil {
+				newTaskCreationEventData, _ := json.Marshal(map[string]string{
+					"type":         "new_sub_task_created",
+					"parentTaskId": t.ID,
+					"newTaskId":    newTask.ID,
+					"newTaskName":  newTask.Name,
+				})
+				sseWriter.SendEvent("info", string(newTaskCreationEventData))
 			}
-			processedToolResults = append(processedToolResults, resMsg)
 		}
-		toolResults = processedToolResults // Use the processed results
-
 
 		// Append tool results to the task's messages for the next LLM iteration
 		_, updateErr := te.TaskStore.UpdateTask(t.ID, func(task *Task) error {
 			task.Messages = append(task.Messages, toolResults...) // Append all tool result messages to Messages
-			task.Error = ""                                       // Clear any previous error
+			setTaskError(task, nil)                               // Clear any previous error
 			return nil
 		})
 		if updateErr != nil {
 			log.Printf("[Task %s Stream] Failed to update task with tool results: %v", t.ID, updateErr)
 			te.TaskStore.SetState(t.ID, TaskStateFailed) // Set state to failed if we can't update task
-			failedStateData, _ := json.Marshal(map[string]interface{}{"status": string(TaskStateFailed), "error": fmt.Sprintf("Failed to update task with tool results: %v", updateErr)})
-			sseWriter.SendEvent("state", string(failedStateData))
+			sseWriter.SendEvent("state", failedStateEvent(internalErrorDetail("Failed to update task with tool results: %v", updateErr)))
 			return false, updateErr // Stop processing
 		}
 
+		if te.hasPendingApproval(t.ID) {
+			log.Printf("[Task %s Stream] Tool calls wait for approval. Parking until tasks/approve.", t.ID)
+			if err := te.TaskStore.SetState(t.ID, TaskStateInputRequired); err != nil {
+				return false, err
+			}
+			task, _ := te.TaskStore.GetTask(t.ID)
+			approvalStateData, _ := json.Marshal(map[string]interface{}{"status": string(TaskStateInputRequired), "approvals": task.Approvals})
+			sseWriter.SendEvent("state", string(approvalStateData))
+			return false, nil
+		}
+		if te.parkForEvent(t.ID, lastAssistantMessage.ParsedToolCalls) {
+			if task, err := te.TaskStore.GetTask(t.ID); err == nil && task.State == TaskStateWaitingEvent {
+				waitingStateData, _ := json.Marshal(map[string]interface{}{"status": string(TaskStateWaitingEvent), "eventWait": task.EventWait})
+				sseWriter.SendEvent("state", string(waitingStateData))
+			}
+			return false, nil
+		}
+
 		log.Printf("[Task %s Stream] Appended %d tool result messages to input. Continuing loop.", t.ID, len(toolResults))
 		// Set state back to Working before the next iteration
 		if err := te.TaskStore.SetState(t.ID, TaskStateWorking); err != nil {
 			log.Printf("[Task %s Stream] Failed to set state back to Working after tool calls: %v", t.ID, err)
-			failedStateData, _ := json.Marshal(map[string]interface{}{"status": string(TaskStateFailed), "error": fmt.Sprintf("Failed to set state after tool calls: %v", err)})
-			sseWriter.SendEvent("state", string(failedStateData))
+			sseWriter.SendEvent("state", failedStateEvent(internalErrorDetail("Failed to set state after tool calls: %v", err)))
 			return false, err // Stop processing if we can't reset state
 		}
 		// Send a state update event to the client
@@ -735,10 +838,10 @@ func (te *TaskExecutor) processTaskStreamIteration(ctx context.Context, t *Task,
 		// Process the result based on whether input is required (if no tool calls were made, but [INPUT_REQUIRED] was present)
 		log.Printf("[Task %s Stream] Input Required detected in full response (no tool calls).", t.ID)
 		if !assistantMessageSaved { // Only add if handleLLMExecutionStream didn't already (it doesn't, but for consistency)
-			outputMessage := Message{Role: RoleAssistant, Parts: []Part{TextPart{Type: "text", Text: fullResultString}}}
+			outputMessage := Message{Role: RoleAssistant, Parts: []Part{TextPart{Type: "text", Text: fullResultString}}, Truncated: responseInfo.Truncated(), Provenance: messageProvenance(responseInfo)}
 			_, updateErr := te.TaskStore.UpdateTask(t.ID, func(task *Task) error {
 				task.Messages = append(task.Messages, outputMessage) // Append to Messages
-				task.Error = ""
+				setTaskError(task, nil)
 				return nil
 			})
 			if updateErr != nil {
@@ -752,62 +855,143 @@ func (te *TaskExecutor) processTaskStreamIteration(ctx context.Context, t *Task,
 			sseWriter.SendEvent("state", string(inputRequiredStateData))
 		} else {
 			log.Printf("[Task %s Stream] Failed to set task state to InputRequired: %v", t.ID, setStateErr)
-			failedStateData, _ := json.Marshal(map[string]interface{}{"status": string(TaskStateFailed), "error": "Failed to transition to input-required state"})
-			sseWriter.SendEvent("state", string(failedStateData))
-		}
-		fmt.Printf("[Task %s Stream] State set to InputRequired. Waiting for signal...\n", t.ID)
-
-		select {
-		case <-resumeCh:
-			fmt.Printf("[Task %s Stream] Resume signal received. Continuing loop.\n", t.ID)
-			workingStateData, _ := json.Marshal(map[string]string{"status": string(TaskStateWorking)})
-			sseWriter.SendEvent("state", string(workingStateData))
-			// Need to set state back to working in the store as well
-			if err := te.TaskStore.SetState(t.ID, TaskStateWorking); err != nil {
-				log.Printf("[Task %s Stream] Failed to set state back to Working after resume: %v", t.ID, err)
-				return false, err // Stop processing
-			}
-			return true, nil // Continue loop
-		case <-ctx.Done():
-			fmt.Printf("[Task %s Stream] Context cancelled while waiting for input. Exiting.\n", t.ID)
-			te.TaskStore.SetState(t.ID, TaskStateCanceled)
-			return false, ctx.Err() // Stop processing
+			sseWriter.SendEvent("state", failedStateEvent(internalErrorDetail("Failed to transition to input-required state")))
 		}
+		// Park the task and end the stream; the resumed run continues in the background.
+		log.Printf("[Task %s Stream] State set to InputRequired. Parking until input arrives.", t.ID)
+		return false, nil
 	} else {
 		// Task Completed Successfully (No Input Required and No Tool Calls)
 		log.Printf("[Task %s Stream] LLM streaming completed successfully.\n", t.ID)
+		if blocked := te.checkCompletionGuardrails(t.ID, fullResultString); blocked != nil {
+			sseWriter.SendEvent("state", failedStateEvent(blocked))
+			return false, nil
+		}
+		fullResultString = te.processFinalOutput(t.ID, fullResultString, assistantMessageSaved)
 
 		if !assistantMessageSaved { // Only add if handleLLMExecutionStream didn't already (it doesn't, but for consistency)
-			outputMessage := Message{Role: RoleAssistant, Parts: []Part{TextPart{Type: "text", Text: fullResultString}}}
+			outputMessage := Message{Role: RoleAssistant, Parts: []Part{TextPart{Type: "text", Text: fullResultString}}, Truncated: responseInfo.Truncated(), Provenance: messageProvenance(responseInfo)}
 			_, updateErr := te.TaskStore.UpdateTask(t.ID, func(task *Task) error {
 				task.Messages = append(task.Messages, outputMessage) // Append to Messages
-				task.Error = ""
+				setTaskError(task, nil)
 				return nil
 			})
 			if updateErr != nil {
 				log.Printf("[Task %s Stream] Failed to update task with completed output: %v\n", t.ID, updateErr)
 			}
 		}
+		if corrected, failed := te.checkOutputContract(t.ID); failed != nil {
+			sseWriter.SendEvent("state", failedStateEvent(failed))
+			return false, nil
+		} else if corrected {
+			workingStateData, _ := json.Marshal(map[string]string{"status": string(TaskStateWorking)})
+			sseWriter.SendEvent("state", string(workingStateData))
+			return true, nil
+		}
 
-		artifactErr := te.TaskStore.AddArtifact(t.ID, Artifact{Type: "text/plain", Filename: "llm_streamed_response.txt", Data: []byte(fullResultString)})
-		if artifactErr != nil {
-			fmt.Printf("[Task %s Stream] Warning: Failed to save streamed result as artifact: %v\n", t.ID, artifactErr)
+		if outputSink == nil { // Otherwise the output artifact holds the response
+			artifactErr := te.TaskStore.AddArtifact(t.ID, Artifact{Type: "text/plain", Filename: "llm_streamed_response.txt", Data: []byte(fullResultString)})
+			if artifactErr != nil {
+				fmt.Printf("[Task %s Stream] Warning: Failed to save streamed result as artifact: %v\n", t.ID, artifactErr)
+			}
 		}
 
 		setStateErr := te.TaskStore.SetState(t.ID, TaskStateCompleted)
 		if setStateErr == nil {
-			completedStateData, _ := json.Marshal(map[string]string{"status": string(TaskStateCompleted)})
+			completedState := map[string]interface{}{"status": string(TaskStateCompleted)}
+			if responseInfo.Truncated() {
+				completedState["truncated"] = true // The answer hit the output token limit
+			}
+			if te.InlineArtifactBytes > 0 {
+				if completedTask, err := te.TaskStore.GetTask(t.ID); err == nil {
+					completedState["artifacts"] = completionArtifacts(completedTask, te.InlineArtifactBytes)
+				}
+			}
+			completedStateData, _ := json.Marshal(completedState)
 			sseWriter.SendEvent("state", string(completedStateData))
 		} else {
 			log.Printf("[Task %s Stream] Failed to set task state to Completed: %v\n", t.ID, setStateErr)
-			failedStateData, _ := json.Marshal(map[string]interface{}{"status": string(TaskStateFailed), "error": "Failed to finalize task state"})
-			sseWriter.SendEvent("state", string(failedStateData))
+			sseWriter.SendEvent("state", failedStateEvent(internalErrorDetail("Failed to finalize task state")))
 		}
 		fmt.Printf("[Task %s Stream] Completed.\n", t.ID)
 		return false, nil // Stop the loop, task is complete
 	}
 }
 
+// createRequestedSubTask handles the add_task sentinel in a tool result: it
+// creates the requested sub-task, starts running it in the background and
+// replaces the sentinel in resMsg with the outcome. It returns the created
+// task, or nil. te.mu must not be held.
+func (te *TaskExecutor) createRequestedSubTask(logPrefix string, parent *Task, resMsg Message) *Task {
+	jsonData, replace := addTaskRequest(resMsg)
+	if replace == nil {
+		return nil
+	}
+	var newTaskData tools.NewTaskRequestData
+	if err := json.Unmarshal([]byte(jsonData), &newTaskData); err != nil {
+		log.Printf("%s Error unmarshalling new task request data: %v. Raw: %s", logPrefix, err, jsonData)
+		replace(fmt.Sprintf("Error processing add_task tool: failed to parse request data: %v", err))
+		return nil
+	}
+	log.Printf("%s Received new task request: Name='%s', Parent='%s'", logPrefix, newTaskData.Name, newTaskData.ParentTaskID)
+	initialUserMessage := Message{
+		Role:      RoleUser,
+		Parts:     []Part{TextPart{Type: "text", Text: newTaskData.Description}},
+		Timestamp: time.Now().UTC(),
+	}
+	newTask, err := te.TaskStore.CreateTask(newTaskData.Name, newTaskData.SystemPrompt, []Message{initialUserMessage}, newTaskData.ParentTaskID)
+	if err != nil {
+		log.Printf("%s Error creating new sub-task via add_task tool: %v", logPrefix, err)
+		replace(fmt.Sprintf("Error creating new task via add_task tool: %v", err))
+		return nil
+	}
+	log.Printf("%s Successfully created new sub-task %s (Parent: %s) via add_task tool.", logPrefix, newTask.ID, newTask.ParentTaskID)
+	inheritProject(te.TaskStore, newTask.ID, parent)
+	inheritSession(te.TaskStore, newTask.ID, parent)
+	inheritClient(te.TaskStore, newTask.ID, parent)
+	// Run it right away: its end reports the outcome to the parent and applies the sub-task policy.
+	te.mu.Lock()
+	if task, err := te.TaskStore.GetTask(newTask.ID); err == nil && te.launchRunLocked(task) {
+		log.Printf("%s Launched sub-task %s.", logPrefix, newTask.ID)
+	}
+	te.mu.Unlock()
+	replace(fmt.Sprintf("New task %s created successfully.", newTask.ID))
+	return newTask
+}
+
+// addTaskRequest returns the request data of the add_task sentinel in a tool
+// result, and a function replacing the sentinel with the given text. The
+// sentinel is the result field of the tool result JSON, or the whole text.
+// replace is nil if resMsg carries no sentinel.
+func addTaskRequest(resMsg Message) (jsonData string, replace func(text string)) {
+	if len(resMsg.Parts) == 0 {
+		return "", nil
+	}
+	textPart, ok := resMsg.Parts[0].(TextPart)
+	if !ok {
+		return "", nil
+	}
+	if strings.HasPrefix(textPart.Text, tools.AddTaskSentinelPrefix) {
+		return strings.TrimPrefix(textPart.Text, tools.AddTaskSentinelPrefix), func(text string) {
+			resMsg.Parts[0] = TextPart{Type: "text", Text: text}
+		}
+	}
+	var toolResult map[string]interface{}
+	if err := json.Unmarshal([]byte(textPart.Text), &toolResult); err != nil {
+		return "", nil
+	}
+	result, _ := toolResult["result"].(string)
+	if !strings.HasPrefix(result, tools.AddTaskSentinelPrefix) {
+		return "", nil
+	}
+	return strings.TrimPrefix(result, tools.AddTaskSentinelPrefix), func(text string) {
+		toolResult["result"] = text
+		if data, err := json.Marshal(toolResult); err == nil {
+			resMsg.Parts[0] = TextPart{Type: "text", Text: string(data)}
+		}
+	}
+}
+
 // extractToolCodeXML finds and extracts the content within <tool_code>...</tool_code> tags.
 func extractToolCodeXML(text string) string {
 	startTag := "<tool_code>"
@@ -0,0 +1,257 @@
+package a2a
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"ka/tools"
+)
+
+// EventWait describes the event a WAITING_EVENT task waits for.
+type EventWait struct {
+	Event       string    `json:"event"`
+	Description string    `json:"description,omitempty"`
+	ToolCallID  string    `json:"toolCallId,omitempty"` // The wait_for_event call the payload answers
+	Since       time.Time `json:"since"`
+}
+
+// QueuedEvent is an event delivered while no task waited for it. It is
+// handed to the next task that waits for its name. Queued events are kept in
+// memory only.
+type QueuedEvent struct {
+	Event      string          `json:"event"`
+	TaskID     string          `json:"taskId,omitempty"` // Only this task may consume the event
+	Payload    json.RawMessage `json:"payload,omitempty"`
+	ReceivedAt time.Time       `json:"receivedAt"`
+}
+
+// eventQueue holds undelivered events in arrival order.
+type eventQueue struct {
+	mu     sync.Mutex
+	events []QueuedEvent
+}
+
+func (q *eventQueue) push(event QueuedEvent) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.events = append(q.events, event)
+}
+
+// take removes and returns the oldest queued event for a task waiting on name.
+func (q *eventQueue) take(name, taskID string) (QueuedEvent, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for i, event := range q.events {
+		if event.Event == name && (event.TaskID == "" || event.TaskID == taskID) {
+			q.events = append(q.events[:i], q.events[i+1:]...)
+			return event, true
+		}
+	}
+	return QueuedEvent{}, false
+}
+
+// dropTask removes the events queued for a task.
+func (q *eventQueue) dropTask(taskID string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	kept := q.events[:0]
+	for _, event := range q.events {
+		if event.TaskID != taskID {
+			kept = append(kept, event)
+		}
+	}
+	q.events = kept
+}
+
+func (q *eventQueue) list() []QueuedEvent {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]QueuedEvent{}, q.events...)
+}
+
+// parkForEvent suspends the task in WAITING_EVENT if one of the tool calls
+// was a valid wait_for_event. An event already queued for it is delivered
+// right away. It reports whether the task was suspended.
+func (te *TaskExecutor) parkForEvent(taskID string, toolCalls []ToolCall) bool {
+	var wait *EventWait
+	for _, toolCall := range toolCalls {
+		if toolCall.Function.Name != "wait_for_event" {
+			continue
+		}
+		args, err := tools.ParseWaitForEventArgs(toolCall.Function.Content)
+		if err != nil {
+			continue // The tool reported the error to the model
+		}
+		wait = &EventWait{Event: args.Event, Description: args.Description, ToolCallID: toolCall.ID, Since: time.Now().UTC()}
+		break
+	}
+	if wait == nil {
+		return false
+	}
+	if _, err := te.TaskStore.UpdateTask(taskID, func(task *Task) error {
+		task.EventWait = wait
+		task.State = TaskStateWaitingEvent
+		return nil
+	}); err != nil {
+		log.Printf("[Task %s] Failed to suspend for event '%s': %v", taskID, wait.Event, err)
+		return false
+	}
+	log.Printf("[Task %s] Waiting for event '%s'.", taskID, wait.Event)
+
+	if queued, ok := te.eventQueue.take(wait.Event, taskID); ok {
+		if err := te.resumeWithEvent(taskID, queued); err != nil {
+			log.Printf("[Task %s] Failed to deliver queued event '%s': %v", taskID, queued.Event, err)
+			te.eventQueue.push(queued)
+		}
+	}
+	return true
+}
+
+// DeliverEvent hands an event to every task waiting for it, or to taskID
+// only when set. Without a waiting task the event is queued. It returns the
+// IDs of the resumed tasks.
+func (te *TaskExecutor) DeliverEvent(event QueuedEvent) ([]string, error) {
+	event.ReceivedAt = time.Now().UTC()
+	tasks, err := te.TaskStore.ListTasks()
+	if err != nil {
+		return nil, err
+	}
+	delivered := []string{}
+	for _, task := range tasks {
+		if task.State != TaskStateWaitingEvent || task.EventWait == nil || task.EventWait.Event != event.Event {
+			continue
+		}
+		if event.TaskID != "" && task.ID != event.TaskID {
+			continue
+		}
+		if err := te.resumeWithEvent(task.ID, event); err != nil {
+			log.Printf("[Task %s] Failed to deliver event '%s': %v", task.ID, event.Event, err)
+			continue
+		}
+		delivered = append(delivered, task.ID)
+	}
+	if len(delivered) == 0 {
+		te.eventQueue.push(event)
+	}
+	return delivered, nil
+}
+
+// resumeWithEvent appends the event payload as the result of the task's
+// wait_for_event call and relaunches it.
+func (te *TaskExecutor) resumeWithEvent(taskID string, event QueuedEvent) error {
+	errNotWaiting := fmt.Errorf("task %s is not waiting for event '%s'", taskID, event.Event)
+	var resumeErr error
+	_, err := te.TaskStore.UpdateTask(taskID, func(task *Task) error {
+		if task.State != TaskStateWaitingEvent || task.EventWait == nil || task.EventWait.Event != event.Event {
+			resumeErr = errNotWaiting
+			return nil
+		}
+		payload := "null"
+		if len(event.Payload) > 0 {
+			payload = string(event.Payload)
+		}
+		task.Messages = append(task.Messages, Message{
+			Role:       RoleTool,
+			ToolCallID: task.EventWait.ToolCallID,
+			Parts:      []Part{TextPart{Type: "text", Text: fmt.Sprintf("Event '%s' was delivered with payload: %s", event.Event, payload)}},
+			Timestamp:  time.Now().UTC(),
+		})
+		task.EventWait = nil
+		task.State = TaskStateWorking
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if resumeErr != nil {
+		return resumeErr
+	}
+	log.Printf("[Task %s] Event '%s' delivered. Resuming.", taskID, event.Event)
+	go te.relaunchWhenIdle(taskID)
+	return nil
+}
+
+// relaunchWhenIdle starts a run of the task once the run that suspended it
+// has returned.
+func (te *TaskExecutor) relaunchWhenIdle(taskID string) {
+	for deadline := time.Now().Add(30 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		te.mu.Lock()
+		task, err := te.TaskStore.GetTask(taskID)
+		if err != nil || task.State != TaskStateWorking {
+			te.mu.Unlock()
+			return
+		}
+		launched := te.launchRunLocked(task)
+		te.mu.Unlock()
+		if launched {
+			return
+		}
+	}
+	log.Printf("[Task %s] Could not relaunch after event delivery: a run is still active.", taskID)
+}
+
+// DeliverEventParams are the parameters of "events/deliver".
+type DeliverEventParams struct {
+	Event   string          `json:"event"`
+	TaskID  string          `json:"taskId,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// EventsDeliverHandler handles "events/deliver", posting a named event with a
+// payload to the tasks waiting for it.
+func EventsDeliverHandler(taskExecutor *TaskExecutor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params DeliverEventParams
+		rpcReq, ok := decodeJSONRPCRequest(w, r, &params)
+		if !ok {
+			return
+		}
+		if params.Event == "" {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: "Invalid Params: missing event name"})
+			return
+		}
+		if params.TaskID != "" && HiddenFromCaller(r.Context(), taskExecutor.TaskStore, params.TaskID) {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32001, Message: "Not Found: Task not found"})
+			return
+		}
+		delivered, err := taskExecutor.DeliverEvent(QueuedEvent{Event: params.Event, TaskID: params.TaskID, Payload: params.Payload})
+		if err != nil {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32000, Message: "Internal Server Error", Data: err.Error()})
+			return
+		}
+		log.Printf("[EventsDeliver %v] Event '%s' delivered to %d task(s).", rpcReq.ID, params.Event, len(delivered))
+		sendJSONRPCResponse(w, rpcReq.ID, map[string]interface{}{"event": params.Event, "delivered": delivered, "queued": len(delivered) == 0}, nil)
+	}
+}
+
+// EventsListHandler handles "events/list", returning the waiting tasks and
+// the queued events.
+func EventsListHandler(taskExecutor *TaskExecutor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rpcReq, ok := decodeJSONRPCRequest(w, r, nil)
+		if !ok {
+			return
+		}
+		tasks, err := taskExecutor.TaskStore.ListTasks()
+		if err != nil {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32000, Message: "Internal Server Error", Data: err.Error()})
+			return
+		}
+		type waitingTask struct {
+			TaskID string `json:"taskId"`
+			Name   string `json:"name,omitempty"`
+			EventWait
+		}
+		waiting := []waitingTask{}
+		for _, task := range tasks {
+			if task.State == TaskStateWaitingEvent && task.EventWait != nil {
+				waiting = append(waiting, waitingTask{TaskID: task.ID, Name: task.Name, EventWait: *task.EventWait})
+			}
+		}
+		sendJSONRPCResponse(w, rpcReq.ID, map[string]interface{}{"waiting": waiting, "queued": taskExecutor.eventQueue.list()}, nil)
+	}
+}
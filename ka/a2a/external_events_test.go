@@ -0,0 +1,62 @@
+package a2a
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"ka/tools"
+)
+
+func TestWaitForEventSuspendsUntilDelivery(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	client := &scriptedLLMClient{replies: []string{`<tool id="wait_for_event">{"event": "ci:build-7"}</tool>`, "Build passed."}}
+	te := NewTaskExecutor(client, store, map[string]tools.Tool{"wait_for_event": &tools.WaitForEventTool{}}, "")
+
+	task, _ := store.CreateTask("ci", "", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "wait for CI"}}}}, "")
+	te.ExecuteTask(context.Background(), task)
+
+	waiting, _ := store.GetTask(task.ID)
+	if waiting.State != TaskStateWaitingEvent || waiting.EventWait == nil || waiting.EventWait.Event != "ci:build-7" {
+		t.Fatalf("expected the task to wait for ci:build-7, got %s %+v", waiting.State, waiting.EventWait)
+	}
+
+	// Another event name does not resume the task and is queued.
+	delivered, _ := te.DeliverEvent(QueuedEvent{Event: "ci:build-8"})
+	if len(delivered) != 0 || len(te.eventQueue.list()) != 1 {
+		t.Fatalf("expected ci:build-8 to be queued, delivered to %v", delivered)
+	}
+
+	delivered, err := te.DeliverEvent(QueuedEvent{Event: "ci:build-7", Payload: json.RawMessage(`{"status":"passed"}`)})
+	if err != nil || len(delivered) != 1 || delivered[0] != task.ID {
+		t.Fatalf("DeliverEvent = %v, %v", delivered, err)
+	}
+	waitForState(t, store, task.ID, TaskStateCompleted)
+
+	done, _ := store.GetTask(task.ID)
+	found := false
+	for _, msg := range done.Messages {
+		if msg.Role == RoleTool && strings.Contains(messageText(msg), `{"status":"passed"}`) {
+			found = true
+		}
+	}
+	if !found || done.EventWait != nil {
+		t.Errorf("expected the payload as a tool result and the wait cleared, got %+v", done.Messages)
+	}
+}
+
+func TestQueuedEventIsDeliveredWhenTaskStartsWaiting(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	client := &scriptedLLMClient{replies: []string{`<tool id="wait_for_event">{"event": "ticket:42"}</tool>`, "Ticket resolved."}}
+	te := NewTaskExecutor(client, store, map[string]tools.Tool{"wait_for_event": &tools.WaitForEventTool{}}, "")
+
+	te.DeliverEvent(QueuedEvent{Event: "ticket:42", Payload: json.RawMessage(`"resolved"`)})
+	task, _ := store.CreateTask("ticket", "", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "wait"}}}}, "")
+	te.ExecuteTask(context.Background(), task)
+
+	waitForState(t, store, task.ID, TaskStateCompleted)
+	if len(te.eventQueue.list()) != 0 {
+		t.Errorf("expected the queued event to be consumed")
+	}
+}
@@ -2,6 +2,7 @@ package a2a
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log" // Import log package
 	"os"
@@ -18,6 +19,9 @@ var _ TaskStore = (*FileTaskStore)(nil)
 type FileTaskStore struct {
 	baseDir string
 	mu      sync.RWMutex
+
+	eventsMu  sync.Mutex       // Serializes appends to the event logs
+	eventSeqs map[string]int64 // Last Seq of each event log appended to since the start
 }
 
 func NewFileTaskStore(baseDir string) (*FileTaskStore, error) {
@@ -46,7 +50,7 @@ func (fts *FileTaskStore) saveTask(task *Task) error {
 		return fmt.Errorf("failed to marshal task %s: %w", task.ID, err)
 	}
 	log.Printf("[FileTaskStore saveTask %s] Writing task data to %s...", task.ID, filePath) // Added log
-	err = os.WriteFile(filePath, data, 0644)
+	err = writeFileAtomic(filePath, data, 0644)
 	if err != nil {
 		log.Printf("[FileTaskStore saveTask %s] Error writing task file %s: %v", task.ID, filePath, err) // Added log
 		return fmt.Errorf("failed to write task file %s: %w", filePath, err)
@@ -72,7 +76,7 @@ func (fts *FileTaskStore) loadTask(taskID string) (*Task, error) {
 	var task Task
 	err = json.Unmarshal(data, &task)
 	if err != nil {
-		return nil, fmt.Errorf("failed to unmarshal task %s from %s: %w", taskID, filePath, err)
+		return nil, fmt.Errorf("%w: failed to unmarshal task %s from %s: %v", ErrTaskCorrupt, taskID, filePath, err)
 	}
 
 	// Correct timestamps for messages if they are the zero value
@@ -193,6 +197,9 @@ func (fts *FileTaskStore) AddArtifact(taskID string, artifact Artifact) error {
 		}
 
 		artCopy := artifact
+		if artCopy.Hints == nil {
+			artCopy.Hints = computeRenderHints(artCopy)
+		}
 		task.Artifacts[artCopy.ID] = &artCopy
 		return nil
 	})
@@ -212,20 +219,95 @@ func (fts *FileTaskStore) GetArtifactData(taskID string, artifactID string) ([]b
 	if !ok {
 		return nil, nil, fmt.Errorf("artifact %s not found in task %s", artifactID, taskID)
 	}
+	if artifact.Streamed {
+		data, err := os.ReadFile(fts.artifactFilePath(taskID, artifactID))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read artifact %s of task %s: %w", artifactID, taskID, err)
+		}
+		return data, artifact, nil
+	}
 
 	return artifact.Data, artifact, nil
 }
 
+// artifactDir holds the data of the task's streamed artifacts.
+func (fts *FileTaskStore) artifactDir(taskID string) string {
+	return filepath.Join(fts.baseDir, taskID+".artifacts")
+}
+
+func (fts *FileTaskStore) artifactFilePath(taskID, artifactID string) string {
+	return filepath.Join(fts.artifactDir(taskID), artifactID)
+}
+
+// AppendArtifact appends a chunk to a streamed artifact, whose data lives in
+// its own file next to the task file so the task record stays small.
+func (fts *FileTaskStore) AppendArtifact(taskID string, meta Artifact, chunk []byte) error {
+	if meta.ID == "" || filepath.Base(meta.ID) != meta.ID || strings.HasPrefix(meta.ID, ".") {
+		return fmt.Errorf("invalid artifact ID %q", meta.ID)
+	}
+	fts.mu.Lock()
+	defer fts.mu.Unlock()
+
+	task, err := fts.loadTask(taskID)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(fts.artifactDir(taskID), 0755); err != nil {
+		return fmt.Errorf("failed to create artifact directory of task %s: %w", taskID, err)
+	}
+	file, err := os.OpenFile(fts.artifactFilePath(taskID, meta.ID), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open artifact %s of task %s: %w", meta.ID, taskID, err)
+	}
+	_, err = file.Write(chunk)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to append to artifact %s of task %s: %w", meta.ID, taskID, err)
+	}
+
+	if task.Artifacts == nil {
+		task.Artifacts = make(map[string]*Artifact)
+	}
+	artifact, ok := task.Artifacts[meta.ID]
+	if !ok {
+		artCopy := meta
+		artCopy.Data = nil
+		artCopy.Streamed = true
+		artCopy.Size = 0
+		artifact = &artCopy
+		task.Artifacts[meta.ID] = artifact
+	}
+	artifact.Size += int64(len(chunk))
+	if artifact.Hints == nil {
+		artifact.Hints = computeRenderHints(Artifact{Type: artifact.Type, Filename: artifact.Filename, Data: chunk})
+	}
+	task.UpdatedAt = time.Now().UTC()
+	return fts.saveTask(task)
+}
+
+// ListTasks loads all tasks. Files that cannot be decoded are moved to the
+// _corrupt directory so they do not break later listings.
 func (fts *FileTaskStore) ListTasks() ([]*Task, error) {
+	tasks, corrupt, err := fts.listTasks()
+	if len(corrupt) > 0 {
+		fts.quarantine(corrupt)
+	}
+	return tasks, err
+}
+
+func (fts *FileTaskStore) listTasks() ([]*Task, []string, error) {
 	fts.mu.RLock()
 	defer fts.mu.RUnlock()
 
 	files, err := os.ReadDir(fts.baseDir)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read task directory %s: %w", fts.baseDir, err)
+		return nil, nil, fmt.Errorf("failed to read task directory %s: %w", fts.baseDir, err)
 	}
 
 	var tasks []*Task
+	var corrupt []string
 	for _, file := range files {
 		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") { // Use strings.HasSuffix
 			continue
@@ -236,12 +318,52 @@ func (fts *FileTaskStore) ListTasks() ([]*Task, error) {
 		task, err := fts.loadTask(taskID)
 		if err != nil {
 			fmt.Printf("Warning: Failed to load task %s during ListTasks: %v\n", taskID, err)
+			if errors.Is(err, ErrTaskCorrupt) {
+				corrupt = append(corrupt, taskID)
+			}
 			continue
 		}
 		tasks = append(tasks, task)
 	}
 
-	return tasks, nil
+	return tasks, corrupt, nil
+}
+
+// WalkTasks implements TaskWalker, loading one task file at a time. Files
+// that cannot be decoded are quarantined like in ListTasks.
+func (fts *FileTaskStore) WalkTasks(fn func(*Task) error) error {
+	fts.mu.RLock()
+	files, err := os.ReadDir(fts.baseDir)
+	fts.mu.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to read task directory %s: %w", fts.baseDir, err)
+	}
+
+	var corrupt []string
+	defer func() {
+		if len(corrupt) > 0 {
+			fts.quarantine(corrupt)
+		}
+	}()
+	for _, file := range files {
+		if file.IsDir() || !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		taskID := strings.TrimSuffix(file.Name(), ".json")
+		task, err := fts.GetTask(taskID)
+		if err != nil {
+			if errors.Is(err, ErrTaskCorrupt) {
+				corrupt = append(corrupt, taskID)
+			} else if !errors.Is(err, ErrTaskNotFound) { // Deleted since the directory was read
+				fmt.Printf("Warning: Failed to load task %s during WalkTasks: %v\n", taskID, err)
+			}
+			continue
+		}
+		if err := fn(task); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (fts *FileTaskStore) DeleteTask(taskID string) error {
@@ -262,7 +384,148 @@ func (fts *FileTaskStore) DeleteTask(taskID string) error {
 	if err != nil {
 		return fmt.Errorf("failed to delete task file %s: %w", filePath, err)
 	}
+	if err := os.RemoveAll(fts.artifactDir(taskID)); err != nil {
+		log.Printf("[FileTaskStore] Failed to delete streamed artifacts of task %s: %v", taskID, err)
+	}
+	fts.eventsMu.Lock()
+	delete(fts.eventSeqs, taskID)
+	if err := os.Remove(fts.eventLogPath(taskID)); err != nil && !os.IsNotExist(err) {
+		log.Printf("[FileTaskStore] Failed to delete the event log of task %s: %v", taskID, err)
+	}
+	fts.eventsMu.Unlock()
 
 	fmt.Printf("[FileTaskStore] Deleted Task: %s\n", taskID)
 	return nil
 }
+
+// eventLogPath is the JSON lines file of a task's event log, next to the
+// task file.
+func (fts *FileTaskStore) eventLogPath(taskID string) string {
+	return filepath.Join(fts.baseDir, taskID+".events.jsonl")
+}
+
+// AppendTaskEvent implements TaskEventLog. The Seq of the first append to a
+// log after a restart continues from its line count.
+func (fts *FileTaskStore) AppendTaskEvent(taskID string, entry *TaskLogEntry) error {
+	fts.eventsMu.Lock()
+	defer fts.eventsMu.Unlock()
+	if _, err := os.Stat(fts.taskFilePath(taskID)); err != nil {
+		if os.IsNotExist(err) {
+			return ErrTaskNotFound
+		}
+		return fmt.Errorf("failed to check task file of %s: %w", taskID, err)
+	}
+	seq, ok := fts.eventSeqs[taskID]
+	if !ok {
+		data, err := os.ReadFile(fts.eventLogPath(taskID))
+		if err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to read the event log of task %s: %w", taskID, err)
+		}
+		seq = int64(strings.Count(string(data), "\n"))
+	}
+	entry.Seq = seq + 1
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event of task %s: %w", taskID, err)
+	}
+	file, err := os.OpenFile(fts.eventLogPath(taskID), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open the event log of task %s: %w", taskID, err)
+	}
+	_, err = file.Write(append(line, '\n'))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to append to the event log of task %s: %w", taskID, err)
+	}
+	if fts.eventSeqs == nil {
+		fts.eventSeqs = make(map[string]int64)
+	}
+	fts.eventSeqs[taskID] = entry.Seq
+	return nil
+}
+
+// ListTaskEvents implements TaskEventLog.
+func (fts *FileTaskStore) ListTaskEvents(taskID string, after int64, limit int) ([]TaskLogEntry, error) {
+	data, err := os.ReadFile(fts.eventLogPath(taskID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read the event log of task %s: %w", taskID, err)
+	}
+	var events []TaskLogEntry
+	lines := strings.Split(string(data), "\n")
+	for i := after; i < int64(len(lines)) && len(events) < limit; i++ {
+		if lines[i] == "" {
+			continue // The end of the file, or a line being appended
+		}
+		var entry TaskLogEntry
+		if err := json.Unmarshal([]byte(lines[i]), &entry); err != nil {
+			if i == int64(len(lines))-1 {
+				break // Partially written
+			}
+			return nil, fmt.Errorf("failed to decode event %d of task %s: %w", i+1, taskID, err)
+		}
+		events = append(events, entry)
+	}
+	return events, nil
+}
+
+// deadLetterDirName is the directory below the store holding dead letters.
+const deadLetterDirName = "_dead_letters"
+
+func (fts *FileTaskStore) deadLetterPath(id string) string {
+	return filepath.Join(fts.baseDir, deadLetterDirName, id+".json")
+}
+
+// SaveDeadLetter implements DeadLetterStore.
+func (fts *FileTaskStore) SaveDeadLetter(letter *DeadLetter) error {
+	data, err := json.Marshal(letter) // Not indented, which would reformat the payload
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead letter %s: %w", letter.ID, err)
+	}
+	path := fts.deadLetterPath(letter.ID)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create dead letter directory: %w", err)
+	}
+	return writeFileAtomic(path, data, 0600)
+}
+
+// DeleteDeadLetter implements DeadLetterStore.
+func (fts *FileTaskStore) DeleteDeadLetter(id string) error {
+	if err := os.Remove(fts.deadLetterPath(id)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete dead letter %s: %w", id, err)
+	}
+	return nil
+}
+
+// ListDeadLetters implements DeadLetterStore.
+func (fts *FileTaskStore) ListDeadLetters() ([]*DeadLetter, error) {
+	dir := filepath.Join(fts.baseDir, deadLetterDirName)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read dead letter directory %s: %w", dir, err)
+	}
+	var letters []*DeadLetter
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read dead letter %s: %w", entry.Name(), err)
+		}
+		var letter DeadLetter
+		if err := json.Unmarshal(data, &letter); err != nil {
+			log.Printf("[FileTaskStore] Skipping unreadable dead letter %s: %v", entry.Name(), err)
+			continue
+		}
+		letters = append(letters, &letter)
+	}
+	return letters, nil
+}
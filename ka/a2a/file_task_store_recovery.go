@@ -0,0 +1,307 @@
+package a2a
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrTaskCorrupt is returned when a stored task file cannot be decoded.
+var ErrTaskCorrupt = errors.New("task file is corrupt")
+
+// corruptDirName is the directory below the store where unreadable task files are moved.
+const corruptDirName = "_corrupt"
+
+// tempFileSuffix marks task files that are still being written.
+const tempFileSuffix = ".tmp"
+
+// QuarantinedTask describes a task file moved aside because it could not be read.
+type QuarantinedTask struct {
+	File          string    `json:"file"`
+	TaskID        string    `json:"task_id"`
+	Size          int64     `json:"size"`
+	QuarantinedAt time.Time `json:"quarantined_at"`
+}
+
+// RepairReport is the outcome of FileTaskStore.Repair.
+type RepairReport struct {
+	Quarantined   []string `json:"quarantined"`   // Files found corrupt in the store and moved aside
+	Restored      []string `json:"restored"`      // Task IDs recovered from quarantine
+	Unrecoverable []string `json:"unrecoverable"` // Quarantined files that could not be recovered
+	TempRemoved   []string `json:"temp_removed"`  // Leftover files of interrupted writes
+}
+
+// CorruptionReporter is implemented by task stores that quarantine unreadable tasks.
+type CorruptionReporter interface {
+	ListQuarantined() ([]QuarantinedTask, error)
+}
+
+func (fts *FileTaskStore) corruptDir() string {
+	return filepath.Join(fts.baseDir, corruptDirName)
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it
+// into place, so a crash never leaves a half-written file at path.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*"+tempFileSuffix)
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName) // No-op once the rename succeeded
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpName, perm); err != nil {
+		return err
+	}
+	return os.Rename(tmpName, path)
+}
+
+// quarantineLocked moves an unreadable task file into the _corrupt directory.
+// The caller must hold the write lock.
+func (fts *FileTaskStore) quarantineLocked(taskID string) (string, error) {
+	if err := os.MkdirAll(fts.corruptDir(), 0755); err != nil {
+		return "", fmt.Errorf("failed to create quarantine directory: %w", err)
+	}
+	name := fmt.Sprintf("%s.%s.json", taskID, time.Now().UTC().Format("20060102T150405.000000000"))
+	dest := filepath.Join(fts.corruptDir(), name)
+	if err := os.Rename(fts.taskFilePath(taskID), dest); err != nil {
+		return "", fmt.Errorf("failed to quarantine task file of %s: %w", taskID, err)
+	}
+	log.Printf("[FileTaskStore] Quarantined corrupt task file of %s as %s", taskID, dest)
+	return name, nil
+}
+
+// quarantine moves the files of the given tasks aside if they are still corrupt.
+func (fts *FileTaskStore) quarantine(taskIDs []string) []string {
+	fts.mu.Lock()
+	defer fts.mu.Unlock()
+	var moved []string
+	for _, taskID := range taskIDs {
+		if _, err := fts.loadTask(taskID); !errors.Is(err, ErrTaskCorrupt) {
+			continue // Rewritten or removed in the meantime
+		}
+		if name, err := fts.quarantineLocked(taskID); err != nil {
+			log.Printf("[FileTaskStore] %v", err)
+		} else {
+			moved = append(moved, name)
+		}
+	}
+	return moved
+}
+
+// ListQuarantined returns the task files in the quarantine directory.
+func (fts *FileTaskStore) ListQuarantined() ([]QuarantinedTask, error) {
+	fts.mu.RLock()
+	defer fts.mu.RUnlock()
+
+	entries, err := os.ReadDir(fts.corruptDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []QuarantinedTask{}, nil
+		}
+		return nil, fmt.Errorf("failed to read quarantine directory: %w", err)
+	}
+	result := []QuarantinedTask{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		result = append(result, QuarantinedTask{
+			File:          entry.Name(),
+			TaskID:        quarantinedTaskID(entry.Name()),
+			Size:          info.Size(),
+			QuarantinedAt: info.ModTime().UTC(),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].File < result[j].File })
+	return result, nil
+}
+
+// quarantinedTaskID strips the quarantine timestamp and extension from a file name.
+func quarantinedTaskID(name string) string {
+	name = strings.TrimSuffix(name, ".json")
+	if i := strings.LastIndex(name, "."); i > 0 {
+		return name[:i]
+	}
+	return name
+}
+
+// Repair removes leftovers of interrupted writes, quarantines corrupt task
+// files and tries to recover quarantined ones. A file is recovered when it
+// decodes as is or when it was truncated and closing the JSON after its last
+// complete value yields a valid task. Recovered tasks replace nothing: a task
+// that exists in the store again is left alone.
+func (fts *FileTaskStore) Repair() (*RepairReport, error) {
+	fts.mu.Lock()
+	defer fts.mu.Unlock()
+
+	report := &RepairReport{Quarantined: []string{}, Restored: []string{}, Unrecoverable: []string{}, TempRemoved: []string{}}
+	entries, err := os.ReadDir(fts.baseDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read task directory %s: %w", fts.baseDir, err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		switch {
+		case entry.IsDir():
+		case strings.HasSuffix(name, tempFileSuffix):
+			if err := os.Remove(filepath.Join(fts.baseDir, name)); err == nil {
+				report.TempRemoved = append(report.TempRemoved, name)
+			}
+		case strings.HasSuffix(name, ".json"):
+			taskID := strings.TrimSuffix(name, ".json")
+			if _, err := fts.loadTask(taskID); errors.Is(err, ErrTaskCorrupt) {
+				moved, err := fts.quarantineLocked(taskID)
+				if err != nil {
+					return nil, err
+				}
+				report.Quarantined = append(report.Quarantined, moved)
+			}
+		}
+	}
+
+	entries, err = os.ReadDir(fts.corruptDir())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read quarantine directory: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		path := filepath.Join(fts.corruptDir(), entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read quarantined file %s: %w", path, err)
+		}
+		task, ok := recoverTask(data)
+		if !ok {
+			report.Unrecoverable = append(report.Unrecoverable, entry.Name())
+			continue
+		}
+		if _, err := os.Stat(fts.taskFilePath(task.ID)); err == nil {
+			report.Unrecoverable = append(report.Unrecoverable, entry.Name())
+			log.Printf("[FileTaskStore] Not restoring %s: task %s exists in the store", entry.Name(), task.ID)
+			continue
+		}
+		if err := fts.saveTask(task); err != nil {
+			return nil, err
+		}
+		os.Remove(path)
+		report.Restored = append(report.Restored, task.ID)
+		log.Printf("[FileTaskStore] Restored task %s from %s", task.ID, entry.Name())
+	}
+	return report, nil
+}
+
+// recoverTask decodes a task file, closing truncated JSON if necessary.
+func recoverTask(data []byte) (*Task, bool) {
+	var task Task
+	if err := json.Unmarshal(data, &task); err == nil {
+		return &task, task.ID != ""
+	}
+	for _, candidate := range truncationCandidates(data) {
+		var task Task
+		if err := json.Unmarshal(candidate, &task); err == nil && task.ID != "" {
+			return &task, true
+		}
+	}
+	return nil, false
+}
+
+// maxRepairCandidates bounds the cut points tried on a truncated file.
+const maxRepairCandidates = 1000
+
+// truncationCandidates returns versions of truncated JSON cut after a complete
+// value and closed with the brackets still open there, latest cut first.
+func truncationCandidates(data []byte) [][]byte {
+	type cut struct {
+		end   int    // data[:end] is kept
+		stack string // Brackets open at the cut
+	}
+	var cuts []cut
+	var stack []byte
+	inString, escaped := false, false
+	for i, c := range data {
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[':
+			stack = append(stack, c)
+		case '}', ']':
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			cuts = append(cuts, cut{end: i + 1, stack: string(stack)})
+		case ',':
+			cuts = append(cuts, cut{end: i, stack: string(stack)})
+		}
+	}
+
+	var candidates [][]byte
+	for i := len(cuts) - 1; i >= 0 && len(candidates) < maxRepairCandidates; i-- {
+		candidate := append([]byte{}, data[:cuts[i].end]...)
+		for j := len(cuts[i].stack) - 1; j >= 0; j-- {
+			if cuts[i].stack[j] == '{' {
+				candidate = append(candidate, '}')
+			} else {
+				candidate = append(candidate, ']')
+			}
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates
+}
+
+// AdminCorruptTasksHandler handles "admin/corruptTasks", listing quarantined task files.
+func AdminCorruptTasksHandler(taskStore TaskStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rpcReq, ok := decodeJSONRPCRequest(w, r, nil)
+		if !ok {
+			return
+		}
+		reporter, ok := taskStore.(CorruptionReporter)
+		if !ok {
+			sendJSONRPCResponse(w, rpcReq.ID, map[string]interface{}{"quarantined": []QuarantinedTask{}}, nil)
+			return
+		}
+		quarantined, err := reporter.ListQuarantined()
+		if err != nil {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32000, Message: "Internal Server Error: Failed to list quarantined tasks", Data: err.Error()})
+			return
+		}
+		sendJSONRPCResponse(w, rpcReq.ID, map[string]interface{}{"quarantined": quarantined}, nil)
+	}
+}
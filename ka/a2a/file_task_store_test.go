@@ -357,3 +357,36 @@ func TestFileTaskStore_GetArtifactData(t *testing.T) {
 		t.Errorf("Expected ErrTaskNotFound for non-existent task ID, got %v", err)
 	}
 }
+
+func TestFileTaskStore_QuarantinesAndRepairsCorruptFiles(t *testing.T) {
+	tempDir := setupTestDir(t)
+	store, _ := NewFileTaskStore(tempDir)
+
+	good, _ := store.CreateTask("good", "", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "hi"}}}}, "")
+	truncated, _ := store.CreateTask("truncated", "", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "hello"}}}}, "")
+	data, _ := os.ReadFile(store.taskFilePath(truncated.ID))
+	os.WriteFile(store.taskFilePath(truncated.ID), data[:len(data)-40], 0644)
+	os.WriteFile(filepath.Join(tempDir, "garbage.json"), []byte("{not json"), 0644)
+	os.WriteFile(filepath.Join(tempDir, "x.json.123.tmp"), []byte("{"), 0644)
+
+	tasks, err := store.ListTasks()
+	if err != nil || len(tasks) != 1 || tasks[0].ID != good.ID {
+		t.Fatalf("Expected only the readable task, got %d tasks, err %v", len(tasks), err)
+	}
+	quarantined, _ := store.ListQuarantined()
+	if len(quarantined) != 2 {
+		t.Fatalf("Expected 2 quarantined files, got %+v", quarantined)
+	}
+
+	report, err := store.Repair()
+	if err != nil {
+		t.Fatalf("Repair: %v", err)
+	}
+	if len(report.Restored) != 1 || report.Restored[0] != truncated.ID || len(report.Unrecoverable) != 1 || len(report.TempRemoved) != 1 {
+		t.Errorf("Unexpected repair report %+v", report)
+	}
+	restored, err := store.GetTask(truncated.ID)
+	if err != nil || restored.Name != "truncated" {
+		t.Errorf("Expected the truncated task to be restored, got %+v, %v", restored, err)
+	}
+}
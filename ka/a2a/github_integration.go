@@ -0,0 +1,415 @@
+package a2a
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// GitHubWebhookPath is where GitHub delivers webhook events.
+const GitHubWebhookPath = "/integrations/github"
+
+const (
+	defaultGitHubAPIURL  = "https://api.github.com"
+	maxGitHubPayload     = 5 << 20
+	maxGitHubDiffBytes   = 60 << 10 // Longer diffs are truncated in the prompt
+	maxGitHubCommentSize = 60000    // GitHub rejects comments above 65536 characters
+
+	githubCommentDelivery = "github_comment" // Kind of the result comment deliveries
+)
+
+// defaultGitHubAssociations are the author associations a trigger accepts
+// unless it lists its own: people with write access to the repository.
+var defaultGitHubAssociations = []string{"OWNER", "MEMBER", "COLLABORATOR"}
+
+// githubAssociations are the values GitHub reports as author_association.
+var githubAssociations = []string{"OWNER", "MEMBER", "COLLABORATOR", "CONTRIBUTOR", "FIRST_TIME_CONTRIBUTOR", "FIRST_TIMER", "MANNEQUIN", "NONE"}
+
+// GitHubTrigger maps a webhook event to a task. The prompt carries text
+// written by GitHub users, so give the trigger a restricted mode, such as
+// researcher, unless only trusted authors can fire it.
+type GitHubTrigger struct {
+	Event       string   `json:"event"`                 // "issue_comment", "issues" or "pull_request"
+	Actions     []string `json:"actions,omitempty"`     // e.g. "opened", "synchronize"; empty matches every action
+	Command     string   `json:"command,omitempty"`     // Comment prefix such as "/agent review"; issue_comment only
+	Prompt      string   `json:"prompt"`                // text/template over GitHubEventContext
+	IncludeDiff bool     `json:"includeDiff,omitempty"` // Fetch the pull request diff into .Diff
+	Mode        string   `json:"mode,omitempty"`        // Mode of the task; prefer one without write or command tools
+	Labels      []string `json:"labels,omitempty"`
+	// AuthorAssociations lists the author_association values, such as
+	// "CONTRIBUTOR" or "NONE", whose comments (or issues and pull requests,
+	// for those events) fire the trigger. Defaults to OWNER, MEMBER and
+	// COLLABORATOR.
+	AuthorAssociations []string `json:"authorAssociations,omitempty"`
+
+	prompt *template.Template
+}
+
+// GitHubIntegrationConfig configures the GitHub webhook endpoint.
+type GitHubIntegrationConfig struct {
+	WebhookSecret string          `json:"webhookSecret"`    // Verifies X-Hub-Signature-256
+	Token         string          `json:"token,omitempty"`  // API token for diffs and result comments; without it no results are posted
+	APIURL        string          `json:"apiUrl,omitempty"` // Defaults to https://api.github.com
+	Project       string          `json:"project,omitempty"`
+	Triggers      []GitHubTrigger `json:"triggers"`
+}
+
+// GitHubEventContext is the data a trigger's prompt template is executed with.
+type GitHubEventContext struct {
+	Event         string
+	Action        string
+	Repo          string // owner/name
+	Number        int
+	Title         string
+	Body          string
+	URL           string
+	Author        string // Author of the issue or pull request
+	IsPullRequest bool
+	Comment       string // Text of the triggering comment
+	Commenter     string
+	Association   string // author_association of the commenter, or of the author without a comment
+	Args          string // Comment text after the command
+	Diff          string // Pull request diff, when the trigger includes it
+}
+
+// LoadGitHubIntegration reads the configuration from a JSON object, given
+// inline or as a file path.
+func LoadGitHubIntegration(config string, te *TaskExecutor) (*GitHubIntegration, error) {
+	data := []byte(config)
+	if !strings.HasPrefix(strings.TrimSpace(config), "{") {
+		fileData, err := os.ReadFile(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read GitHub integration file %s: %w", config, err)
+		}
+		data = fileData
+	}
+	var cfg GitHubIntegrationConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse GitHub integration: %w", err)
+	}
+	return NewGitHubIntegration(cfg, te)
+}
+
+// GitHubIntegration creates tasks from GitHub webhook events and posts their
+// results back as comments.
+type GitHubIntegration struct {
+	config   GitHubIntegrationConfig
+	executor *TaskExecutor
+	client   *http.Client
+	replay   *ReplayCache // IDs of processed deliveries
+}
+
+// NewGitHubIntegration validates the configuration and parses the prompt templates.
+func NewGitHubIntegration(cfg GitHubIntegrationConfig, te *TaskExecutor) (*GitHubIntegration, error) {
+	if cfg.WebhookSecret == "" {
+		return nil, fmt.Errorf("GitHub integration needs a webhook secret")
+	}
+	if len(cfg.Triggers) == 0 {
+		return nil, fmt.Errorf("GitHub integration needs at least one trigger")
+	}
+	if cfg.APIURL == "" {
+		cfg.APIURL = defaultGitHubAPIURL
+	}
+	cfg.APIURL = strings.TrimRight(cfg.APIURL, "/")
+	for i := range cfg.Triggers {
+		trigger := &cfg.Triggers[i]
+		switch trigger.Event {
+		case "issue_comment", "issues", "pull_request":
+		default:
+			return nil, fmt.Errorf("trigger %d: unsupported event '%s' (expected issue_comment, issues or pull_request)", i, trigger.Event)
+		}
+		if trigger.Command != "" && trigger.Event != "issue_comment" {
+			return nil, fmt.Errorf("trigger %d: command is only supported for issue_comment events", i)
+		}
+		if trigger.Prompt == "" {
+			return nil, fmt.Errorf("trigger %d: prompt is required", i)
+		}
+		prompt, err := template.New(fmt.Sprintf("trigger-%d", i)).Option("missingkey=error").Parse(trigger.Prompt)
+		if err != nil {
+			return nil, fmt.Errorf("trigger %d: invalid prompt template: %w", i, err)
+		}
+		trigger.prompt = prompt
+		if _, ok := te.Modes.Get(trigger.Mode); trigger.Mode != "" && !ok {
+			return nil, fmt.Errorf("trigger %d: unknown mode '%s'", i, trigger.Mode)
+		}
+		if len(trigger.AuthorAssociations) == 0 {
+			trigger.AuthorAssociations = defaultGitHubAssociations
+		}
+		for j, association := range trigger.AuthorAssociations {
+			trigger.AuthorAssociations[j] = strings.ToUpper(association)
+			if !containsString(githubAssociations, trigger.AuthorAssociations[j]) {
+				return nil, fmt.Errorf("trigger %d: unknown author association '%s' (expected one of %s)", i, association, strings.Join(githubAssociations, ", "))
+			}
+		}
+	}
+	gh := &GitHubIntegration{config: cfg, executor: te, client: &http.Client{Timeout: 30 * time.Second, Transport: te.Deliveries.Client.Transport}, replay: NewReplayCache(GitHubReplayWindow)}
+	te.Deliveries.Authorize(githubCommentDelivery, gh.authorize)
+	return gh, nil
+}
+
+// githubPayload holds the fields of issue, issue_comment and pull_request events the integration uses.
+type githubPayload struct {
+	Action     string `json:"action"`
+	Repository struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"`
+	Issue       *githubItem `json:"issue"`
+	PullRequest *githubItem `json:"pull_request"`
+	Comment     *struct {
+		Body              string     `json:"body"`
+		User              githubUser `json:"user"`
+		AuthorAssociation string     `json:"author_association"`
+	} `json:"comment"`
+	Sender githubUser `json:"sender"`
+}
+
+type githubItem struct {
+	Number            int             `json:"number"`
+	Title             string          `json:"title"`
+	Body              string          `json:"body"`
+	HTMLURL           string          `json:"html_url"`
+	User              githubUser      `json:"user"`
+	AuthorAssociation string          `json:"author_association"`
+	PullRequest       json.RawMessage `json:"pull_request"` // Set on issues that are pull requests
+}
+
+type githubUser struct {
+	Login string `json:"login"`
+	Type  string `json:"type"`
+}
+
+// verifyGitHubSignature checks the X-Hub-Signature-256 header of a delivery.
+func verifyGitHubSignature(secret string, body []byte, header string) bool {
+	signature, ok := strings.CutPrefix(header, "sha256=")
+	if !ok {
+		return false
+	}
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// GitHubWebhookHandler handles deliveries to GitHubWebhookPath. Deliveries
+// without a valid signature, and deliveries whose X-GitHub-Delivery ID was
+// already processed, are refused and audited; events no trigger matches are
+// acknowledged and ignored.
+func GitHubWebhookHandler(gh *GitHubIntegration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxGitHubPayload+1))
+		if err != nil || len(body) > maxGitHubPayload {
+			http.Error(w, "Bad Request: payload unreadable or too large", http.StatusBadRequest)
+			return
+		}
+		signature := r.Header.Get("X-Hub-Signature-256")
+		if !verifyGitHubSignature(gh.config.WebhookSecret, body, signature) {
+			log.Printf("[GitHub] Refused delivery %s: invalid signature", r.Header.Get("X-GitHub-Delivery"))
+			gh.executor.Audit.RecordRequest(r, "github", "invalid signature")
+			http.Error(w, "Unauthorized: invalid signature", http.StatusUnauthorized)
+			return
+		}
+		delivery := r.Header.Get("X-GitHub-Delivery")
+		if delivery == "" {
+			http.Error(w, "Bad Request: missing X-GitHub-Delivery", http.StatusBadRequest)
+			return
+		}
+		// A redelivery keeps the ID of the original delivery, so the ID is
+		// only remembered once the delivery was processed: redelivering one
+		// that failed goes through
+		if err := gh.replay.Check(delivery); err != nil {
+			log.Printf("[GitHub] Refused delivery %s: already processed", delivery)
+			gh.executor.Audit.RecordRequest(r, "github", "replayed delivery "+delivery)
+			http.Error(w, "Conflict: delivery already processed", http.StatusConflict)
+			return
+		}
+		processed := false
+		defer func() {
+			if !processed {
+				gh.replay.Forget(delivery)
+			}
+		}()
+		event := r.Header.Get("X-GitHub-Event")
+		if event == "ping" {
+			processed = true
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		var payload githubPayload
+		if err := json.Unmarshal(body, &payload); err != nil {
+			http.Error(w, "Bad Request: invalid payload", http.StatusBadRequest)
+			return
+		}
+		trigger, eventCtx, ok := gh.match(event, &payload)
+		if !ok {
+			processed = true
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if err := gh.executor.ReadOnly.CheckMethod("tasks/send"); err != nil {
+			http.Error(w, "Service Unavailable: "+err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+
+		if trigger.IncludeDiff && eventCtx.IsPullRequest {
+			diff, err := gh.fetchDiff(r.Context(), eventCtx.Repo, eventCtx.Number)
+			if err != nil {
+				log.Printf("[GitHub] Failed to fetch the diff of %s#%d: %v", eventCtx.Repo, eventCtx.Number, err)
+				diff = fmt.Sprintf("[The diff could not be fetched: %v]", err)
+			}
+			eventCtx.Diff = diff
+		}
+		var prompt strings.Builder
+		if err := trigger.prompt.Execute(&prompt, eventCtx); err != nil {
+			log.Printf("[GitHub] Failed to render the prompt for %s#%d: %v", eventCtx.Repo, eventCtx.Number, err)
+			http.Error(w, "Internal Server Error: prompt template failed", http.StatusInternalServerError)
+			return
+		}
+
+		params := SendTaskParams{
+			Message: Message{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: prompt.String()}}, Timestamp: time.Now()},
+			Mode:    trigger.Mode,
+			Project: gh.config.Project,
+			Labels:  trigger.Labels,
+		}
+		name := fmt.Sprintf("%s#%d: %s", eventCtx.Repo, eventCtx.Number, eventCtx.Title)
+		task, rpcErr := gh.executor.createTask(context.Background(), name, params)
+		if rpcErr != nil {
+			log.Printf("[GitHub] Failed to create a task for %s#%d: %s", eventCtx.Repo, eventCtx.Number, rpcErr.Message)
+			http.Error(w, rpcErr.Message, http.StatusInternalServerError)
+			return
+		}
+		log.Printf("[GitHub] Created task %s from %s event on %s#%d.", task.ID, event, eventCtx.Repo, eventCtx.Number)
+		processed = true
+		go gh.runAndReport(task, eventCtx)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]string{"taskId": task.ID})
+	}
+}
+
+// match returns the first trigger matching the event and the template data for it.
+func (gh *GitHubIntegration) match(event string, payload *githubPayload) (*GitHubTrigger, GitHubEventContext, bool) {
+	eventCtx := GitHubEventContext{Event: event, Action: payload.Action, Repo: payload.Repository.FullName}
+	item := payload.Issue
+	if event == "pull_request" {
+		item = payload.PullRequest
+		eventCtx.IsPullRequest = true
+	}
+	if item == nil {
+		return nil, eventCtx, false
+	}
+	eventCtx.Number, eventCtx.Title, eventCtx.Body, eventCtx.URL, eventCtx.Author = item.Number, item.Title, item.Body, item.HTMLURL, item.User.Login
+	eventCtx.Association = item.AuthorAssociation
+	if len(item.PullRequest) > 0 && string(item.PullRequest) != "null" {
+		eventCtx.IsPullRequest = true
+	}
+	if payload.Comment != nil {
+		if payload.Comment.User.Type == "Bot" {
+			return nil, eventCtx, false // Never react to bots, including the comments this integration posts
+		}
+		eventCtx.Comment, eventCtx.Commenter = payload.Comment.Body, payload.Comment.User.Login
+		eventCtx.Association = payload.Comment.AuthorAssociation
+	}
+
+	for i := range gh.config.Triggers {
+		trigger := &gh.config.Triggers[i]
+		if trigger.Event != event || (len(trigger.Actions) > 0 && !containsString(trigger.Actions, payload.Action)) {
+			continue
+		}
+		if !containsString(trigger.AuthorAssociations, eventCtx.Association) {
+			continue // Anyone can comment on a public repository
+		}
+		if trigger.Command != "" {
+			comment := strings.TrimSpace(eventCtx.Comment)
+			if comment != trigger.Command && !strings.HasPrefix(comment, trigger.Command+" ") && !strings.HasPrefix(comment, trigger.Command+"\n") {
+				continue
+			}
+			eventCtx.Args = strings.TrimSpace(strings.TrimPrefix(comment, trigger.Command))
+		}
+		return trigger, eventCtx, true
+	}
+	return nil, eventCtx, false
+}
+
+// fetchDiff downloads the diff of a pull request, truncated to maxGitHubDiffBytes.
+func (gh *GitHubIntegration) fetchDiff(ctx context.Context, repo string, number int) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/repos/%s/pulls/%d", gh.config.APIURL, repo, number), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github.v3.diff")
+	gh.authorize(req)
+	resp, err := gh.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GitHub API returned %s", resp.Status)
+	}
+	diff, err := io.ReadAll(io.LimitReader(resp.Body, maxGitHubDiffBytes+1))
+	if err != nil {
+		return "", err
+	}
+	return truncateOutput(string(diff), maxGitHubDiffBytes), nil
+}
+
+func (gh *GitHubIntegration) authorize(req *http.Request) {
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if gh.config.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+gh.config.Token)
+	}
+}
+
+// runAndReport runs the task and comments its result on the issue or pull request.
+func (gh *GitHubIntegration) runAndReport(task *Task, eventCtx GitHubEventContext) {
+	gh.executor.ExecuteTask(context.Background(), task)
+	if gh.config.Token == "" {
+		return
+	}
+	final, err := gh.executor.TaskStore.GetTask(task.ID)
+	if err != nil {
+		log.Printf("[GitHub] Failed to load task %s after its run: %v", task.ID, err)
+		return
+	}
+	comment := taskResultText(final)
+	if len(comment) > maxGitHubCommentSize {
+		comment = truncateOutput(comment, maxGitHubCommentSize)
+	}
+	comment += fmt.Sprintf("\n\n<sub>ka task `%s` (%s)</sub>", final.ID, final.State)
+	if err := gh.postComment(eventCtx.Repo, eventCtx.Number, comment); err != nil {
+		log.Printf("[GitHub] Failed to post the result of task %s to %s#%d: %v", task.ID, eventCtx.Repo, eventCtx.Number, err)
+		return
+	}
+	log.Printf("[GitHub] Posted the result of task %s to %s#%d.", task.ID, eventCtx.Repo, eventCtx.Number)
+}
+
+// postComment adds a comment to an issue or pull request. Comments GitHub
+// does not accept are dead-lettered by the executor's deliverer.
+func (gh *GitHubIntegration) postComment(repo string, number int, body string) error {
+	data, _ := json.Marshal(map[string]string{"body": body})
+	return gh.executor.Deliveries.Deliver(context.Background(), Delivery{
+		Kind:    githubCommentDelivery,
+		URL:     fmt.Sprintf("%s/repos/%s/issues/%d/comments", gh.config.APIURL, repo, number),
+		Body:    data,
+		Headers: map[string]string{"Accept": "application/vnd.github+json"},
+	})
+}
@@ -0,0 +1,205 @@
+package a2a
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ka/tools"
+)
+
+func signGitHubPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func deliverGitHubEvent(handler http.HandlerFunc, event, delivery, signature string, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, GitHubWebhookPath, strings.NewReader(string(body)))
+	req.Header.Set("X-GitHub-Event", event)
+	req.Header.Set("X-GitHub-Delivery", delivery)
+	req.Header.Set("X-Hub-Signature-256", signature)
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	return rec
+}
+
+func TestGitHubReviewCommandCreatesTaskAndComments(t *testing.T) {
+	comments := make(chan string, 1)
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer gh-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/repos/acme/app/pulls/7":
+			io.WriteString(w, "diff --git a/main.go b/main.go\n+fmt.Println(\"hi\")\n")
+		case r.Method == http.MethodPost && r.URL.Path == "/repos/acme/app/issues/7/comments":
+			var body map[string]string
+			json.NewDecoder(r.Body).Decode(&body)
+			comments <- body["body"]
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer api.Close()
+
+	store := NewInMemoryTaskStore()
+	te := NewTaskExecutor(&staticLLMClient{reply: "Looks good to me."}, store, map[string]tools.Tool{}, "")
+	gh, err := NewGitHubIntegration(GitHubIntegrationConfig{
+		WebhookSecret: "s3cret",
+		Token:         "gh-token",
+		APIURL:        api.URL,
+		Triggers: []GitHubTrigger{{
+			Event:       "issue_comment",
+			Actions:     []string{"created"},
+			Command:     "/agent review",
+			IncludeDiff: true,
+			Labels:      []string{"review"},
+			Prompt:      "Review {{.Repo}}#{{.Number}} ({{.Title}}). Focus: {{.Args}}\n{{.Diff}}",
+		}},
+	}, te)
+	if err != nil {
+		t.Fatalf("NewGitHubIntegration failed: %v", err)
+	}
+	handler := GitHubWebhookHandler(gh)
+
+	payload := []byte(`{"action":"created","repository":{"full_name":"acme/app"},
+		"issue":{"number":7,"title":"Add greeting","pull_request":{"url":"x"},"user":{"login":"bob"}},
+		"comment":{"body":"/agent review error handling","user":{"login":"ann","type":"User"},"author_association":"MEMBER"}}`)
+
+	if rec := deliverGitHubEvent(handler, "issue_comment", "d-0", "sha256=00", payload); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected a bad signature to be refused, got %d", rec.Code)
+	}
+
+	rec := deliverGitHubEvent(handler, "issue_comment", "d-1", signGitHubPayload("s3cret", payload), payload)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("expected 202, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp map[string]string
+	json.NewDecoder(rec.Body).Decode(&resp)
+	if replay := deliverGitHubEvent(handler, "issue_comment", "d-1", signGitHubPayload("s3cret", payload), payload); replay.Code != http.StatusConflict {
+		t.Errorf("expected a replayed delivery to be refused, got %d", replay.Code)
+	}
+	if events := te.Audit.Recent("github", 0); len(events) != 2 || events[0].Reason != "invalid signature" || !strings.HasPrefix(events[1].Reason, "replayed delivery") {
+		t.Errorf("expected the refused deliveries to be audited, got %+v", events)
+	}
+
+	select {
+	case comment := <-comments:
+		if !strings.Contains(comment, "Looks good to me.") || !strings.Contains(comment, resp["taskId"]) {
+			t.Errorf("unexpected comment %q", comment)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no result comment was posted")
+	}
+
+	task, err := store.GetTask(resp["taskId"])
+	if err != nil {
+		t.Fatalf("task not found: %v", err)
+	}
+	prompt := messageText(task.Messages[0])
+	if !strings.HasPrefix(prompt, "Review acme/app#7 (Add greeting). Focus: error handling\ndiff --git") {
+		t.Errorf("unexpected prompt %q", prompt)
+	}
+	if task.Name != "acme/app#7: Add greeting" || len(task.Labels) != 1 {
+		t.Errorf("unexpected task name/labels %q %v", task.Name, task.Labels)
+	}
+}
+
+func TestGitHubRedeliveryOfFailedDeliveryIsProcessed(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	te := NewTaskExecutor(&staticLLMClient{reply: "x"}, store, map[string]tools.Tool{}, "")
+	gh, err := NewGitHubIntegration(GitHubIntegrationConfig{
+		WebhookSecret: "s3cret",
+		Triggers:      []GitHubTrigger{{Event: "issue_comment", Command: "/agent", Prompt: "{{.Args}}"}},
+	}, te)
+	if err != nil {
+		t.Fatalf("NewGitHubIntegration failed: %v", err)
+	}
+	handler := GitHubWebhookHandler(gh)
+	payload := []byte(`{"action":"created","repository":{"full_name":"a/b"},"issue":{"number":1},"comment":{"body":"/agent fix","user":{"type":"User"},"author_association":"OWNER"}}`)
+	signature := signGitHubPayload("s3cret", payload)
+
+	te.ReadOnly.Set(true)
+	if rec := deliverGitHubEvent(handler, "issue_comment", "d-1", signature, payload); rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 while read-only, got %d", rec.Code)
+	}
+	te.ReadOnly.Set(false)
+	if rec := deliverGitHubEvent(handler, "issue_comment", "d-1", signature, payload); rec.Code != http.StatusAccepted {
+		t.Fatalf("expected the redelivery of a failed delivery to be processed, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := deliverGitHubEvent(handler, "issue_comment", "d-1", signature, payload); rec.Code != http.StatusConflict {
+		t.Errorf("expected the redelivery of a processed delivery to be refused, got %d", rec.Code)
+	}
+	if rec := deliverGitHubEvent(handler, "issue_comment", "d-2", signature, payload); rec.Code != http.StatusAccepted {
+		t.Errorf("expected a new delivery of the same payload to be processed, got %d", rec.Code)
+	}
+	if rec := deliverGitHubEvent(handler, "issue_comment", "", signature, payload); rec.Code != http.StatusBadRequest {
+		t.Errorf("expected a delivery without an ID to be refused, got %d", rec.Code)
+	}
+}
+
+func TestGitHubTriggerAuthorAssociations(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	te := NewTaskExecutor(&staticLLMClient{reply: "x"}, store, map[string]tools.Tool{}, "")
+	gh, err := NewGitHubIntegration(GitHubIntegrationConfig{
+		WebhookSecret: "s3cret",
+		Triggers:      []GitHubTrigger{{Event: "issues", Prompt: "{{.Title}}", Mode: "researcher", AuthorAssociations: []string{"contributor"}}},
+	}, te)
+	if err != nil {
+		t.Fatalf("NewGitHubIntegration failed: %v", err)
+	}
+	handler := GitHubWebhookHandler(gh)
+	for i, tc := range []struct {
+		association string
+		want        int
+	}{{"CONTRIBUTOR", http.StatusAccepted}, {"OWNER", http.StatusNoContent}, {"NONE", http.StatusNoContent}} {
+		body := []byte(`{"action":"opened","repository":{"full_name":"a/b"},"issue":{"number":1,"title":"t","author_association":"` + tc.association + `"}}`)
+		if rec := deliverGitHubEvent(handler, "issues", fmt.Sprintf("d-%d", i), signGitHubPayload("s3cret", body), body); rec.Code != tc.want {
+			t.Errorf("%s: expected %d, got %d", tc.association, tc.want, rec.Code)
+		}
+	}
+}
+
+func TestGitHubIgnoresUnmatchedEventsAndBots(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	te := NewTaskExecutor(&staticLLMClient{reply: "x"}, store, map[string]tools.Tool{}, "")
+	gh, err := NewGitHubIntegration(GitHubIntegrationConfig{
+		WebhookSecret: "s3cret",
+		Triggers:      []GitHubTrigger{{Event: "issue_comment", Command: "/agent", Prompt: "{{.Args}}"}},
+	}, te)
+	if err != nil {
+		t.Fatalf("NewGitHubIntegration failed: %v", err)
+	}
+	handler := GitHubWebhookHandler(gh)
+	for i, body := range []string{
+		`{"action":"created","repository":{"full_name":"a/b"},"issue":{"number":1},"comment":{"body":"/agentic stuff","user":{"type":"User"},"author_association":"OWNER"}}`,
+		`{"action":"created","repository":{"full_name":"a/b"},"issue":{"number":1},"comment":{"body":"/agent fix","user":{"type":"Bot"},"author_association":"OWNER"}}`,
+		`{"action":"created","repository":{"full_name":"a/b"},"issue":{"number":1},"comment":{"body":"/agent fix","user":{"type":"User"},"author_association":"NONE"}}`,
+		`{"action":"created","repository":{"full_name":"a/b"},"issue":{"number":1,"author_association":"OWNER"},"comment":{"body":"/agent fix","user":{"type":"User"},"author_association":"CONTRIBUTOR"}}`,
+	} {
+		if rec := deliverGitHubEvent(handler, "issue_comment", fmt.Sprintf("d-%d", i), signGitHubPayload("s3cret", []byte(body)), []byte(body)); rec.Code != http.StatusNoContent {
+			t.Errorf("expected 204 for %s, got %d", body, rec.Code)
+		}
+	}
+	if tasks, _ := store.ListTasks(); len(tasks) != 0 {
+		t.Errorf("expected no tasks, got %d", len(tasks))
+	}
+
+	if _, err := NewGitHubIntegration(GitHubIntegrationConfig{WebhookSecret: "s", Triggers: []GitHubTrigger{{Event: "push", Prompt: "x"}}}, te); err == nil {
+		t.Error("expected an unsupported event to be rejected")
+	}
+	if _, err := NewGitHubIntegration(GitHubIntegrationConfig{WebhookSecret: "s", Triggers: []GitHubTrigger{{Event: "issues", Prompt: "x", AuthorAssociations: []string{"anyone"}}}}, te); err == nil {
+		t.Error("expected an unknown author association to be rejected")
+	}
+}
@@ -0,0 +1,508 @@
+package a2a
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"ka/llm"
+)
+
+// GuardrailStage is a point of the task lifecycle where rules are evaluated.
+type GuardrailStage string
+
+const (
+	GuardrailBeforeLLM        GuardrailStage = "before_llm"
+	GuardrailBeforeTool       GuardrailStage = "before_tool"
+	GuardrailBeforeCompletion GuardrailStage = "before_completion"
+)
+
+// GuardrailAction is what a matching rule does.
+type GuardrailAction string
+
+const (
+	GuardrailBlock           GuardrailAction = "block"            // Any stage: refuse the LLM call, tool call or final output
+	GuardrailRequireApproval GuardrailAction = "require_approval" // before_tool: run the tool only after tasks/approve
+	GuardrailAnnotate        GuardrailAction = "annotate"         // Any stage: only record the match on the task
+	GuardrailDowngradeModel  GuardrailAction = "downgrade_model"  // before_llm: use Model for the call
+)
+
+// ErrorCodeGuardrailBlocked is the ErrorDetail code of tasks stopped by a block rule.
+const ErrorCodeGuardrailBlocked = "guardrail_blocked"
+
+// GuardrailCondition selects where a rule applies. All set fields must match.
+type GuardrailCondition struct {
+	Labels  []string `json:"labels,omitempty"`  // The task carries any of these labels
+	Project string   `json:"project,omitempty"` // The task belongs to this project
+	Mode    string   `json:"mode,omitempty"`    // The task runs in this mode
+	Tools   []string `json:"tools,omitempty"`   // Tool name patterns such as "execute_*"; before_tool only
+	Args    string   `json:"args,omitempty"`    // Regular expression over the tool arguments; before_tool only
+	Content string   `json:"content,omitempty"` // Regular expression over the latest user message (before_llm) or the final output (before_completion)
+}
+
+// GuardrailRule is one declarative rule.
+type GuardrailRule struct {
+	Name    string             `json:"name"`
+	Stage   GuardrailStage     `json:"stage"`
+	When    GuardrailCondition `json:"when"`
+	Action  GuardrailAction    `json:"action"`
+	Message string             `json:"message,omitempty"` // Reason shown to the model and the user
+	Model   string             `json:"model,omitempty"`   // Model of downgrade_model
+
+	args    *regexp.Regexp
+	content *regexp.Regexp
+}
+
+// Guardrails evaluates rules in their configured order. A nil *Guardrails has no rules.
+type Guardrails struct {
+	Rules []*GuardrailRule
+}
+
+// LoadGuardrails reads the rules from a JSON array, given inline or as a file path.
+func LoadGuardrails(config string) (*Guardrails, error) {
+	data := []byte(config)
+	if !strings.HasPrefix(strings.TrimSpace(config), "[") {
+		fileData, err := os.ReadFile(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read guardrails file %s: %w", config, err)
+		}
+		data = fileData
+	}
+	var rules []*GuardrailRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse guardrails: %w", err)
+	}
+	return NewGuardrails(rules)
+}
+
+// NewGuardrails validates the rules and compiles their patterns.
+func NewGuardrails(rules []*GuardrailRule) (*Guardrails, error) {
+	names := make(map[string]bool)
+	for _, rule := range rules {
+		if rule.Name == "" || names[rule.Name] {
+			return nil, fmt.Errorf("guardrail rules need unique names (got '%s')", rule.Name)
+		}
+		names[rule.Name] = true
+		switch rule.Stage {
+		case GuardrailBeforeLLM, GuardrailBeforeTool, GuardrailBeforeCompletion:
+		default:
+			return nil, fmt.Errorf("rule '%s': unknown stage '%s'", rule.Name, rule.Stage)
+		}
+		switch rule.Action {
+		case GuardrailBlock, GuardrailAnnotate:
+		case GuardrailRequireApproval:
+			if rule.Stage != GuardrailBeforeTool {
+				return nil, fmt.Errorf("rule '%s': require_approval only applies before_tool", rule.Name)
+			}
+		case GuardrailDowngradeModel:
+			if rule.Stage != GuardrailBeforeLLM || rule.Model == "" {
+				return nil, fmt.Errorf("rule '%s': downgrade_model applies before_llm and needs a model", rule.Name)
+			}
+		default:
+			return nil, fmt.Errorf("rule '%s': unknown action '%s'", rule.Name, rule.Action)
+		}
+		if (len(rule.When.Tools) > 0 || rule.When.Args != "") && rule.Stage != GuardrailBeforeTool {
+			return nil, fmt.Errorf("rule '%s': tool conditions only apply before_tool", rule.Name)
+		}
+		if rule.When.Content != "" && rule.Stage == GuardrailBeforeTool {
+			return nil, fmt.Errorf("rule '%s': content conditions do not apply before_tool; use args", rule.Name)
+		}
+		var err error
+		if rule.When.Args != "" {
+			if rule.args, err = regexp.Compile(rule.When.Args); err != nil {
+				return nil, fmt.Errorf("rule '%s': invalid args pattern: %w", rule.Name, err)
+			}
+		}
+		if rule.When.Content != "" {
+			if rule.content, err = regexp.Compile(rule.When.Content); err != nil {
+				return nil, fmt.Errorf("rule '%s': invalid content pattern: %w", rule.Name, err)
+			}
+		}
+	}
+	return &Guardrails{Rules: rules}, nil
+}
+
+// GuardrailInput is what the rules of a stage are evaluated against.
+type GuardrailInput struct {
+	Task     *Task
+	ToolCall *ToolCall // before_tool
+	Content  string    // before_llm and before_completion
+}
+
+// Evaluate returns the rules of the stage matching the input, in order.
+func (g *Guardrails) Evaluate(stage GuardrailStage, in GuardrailInput) []*GuardrailRule {
+	if g == nil {
+		return nil
+	}
+	var matched []*GuardrailRule
+	for _, rule := range g.Rules {
+		if rule.Stage == stage && rule.matches(in) {
+			matched = append(matched, rule)
+		}
+	}
+	return matched
+}
+
+func (r *GuardrailRule) matches(in GuardrailInput) bool {
+	when := r.When
+	if when.Project != "" && in.Task.Project != when.Project {
+		return false
+	}
+	if when.Mode != "" && in.Task.Mode != when.Mode {
+		return false
+	}
+	if len(when.Labels) > 0 && !hasAnyLabel(in.Task.Labels, when.Labels) {
+		return false
+	}
+	if len(when.Tools) > 0 {
+		if in.ToolCall == nil || !matchesAnyPattern(in.ToolCall.Function.Name, when.Tools) {
+			return false
+		}
+	}
+	if r.args != nil && (in.ToolCall == nil || !r.args.MatchString(in.ToolCall.Function.Content)) {
+		return false
+	}
+	if r.content != nil && !r.content.MatchString(in.Content) {
+		return false
+	}
+	return true
+}
+
+func hasAnyLabel(labels, wanted []string) bool {
+	for _, label := range labels {
+		for _, w := range wanted {
+			if label == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchesAnyPattern(name string, patterns []string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// GuardrailEvent records a rule that matched on a task.
+type GuardrailEvent struct {
+	Rule      string          `json:"rule"`
+	Stage     GuardrailStage  `json:"stage"`
+	Action    GuardrailAction `json:"action"`
+	Tool      string          `json:"tool,omitempty"`
+	Message   string          `json:"message,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// recordGuardrailEvents appends the matched rules to the task.
+func recordGuardrailEvents(store TaskStore, taskID string, stage GuardrailStage, rules []*GuardrailRule, tool string) {
+	if len(rules) == 0 {
+		return
+	}
+	_, err := store.UpdateTask(taskID, func(task *Task) error {
+		for _, rule := range rules {
+			task.GuardrailEvents = append(task.GuardrailEvents, GuardrailEvent{Rule: rule.Name, Stage: stage, Action: rule.Action, Tool: tool, Message: rule.Message, Timestamp: time.Now().UTC()})
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("[Task %s] Failed to record guardrail events: %v", taskID, err)
+	}
+}
+
+// firstRule returns the first rule with the action, or nil.
+func firstRule(rules []*GuardrailRule, action GuardrailAction) *GuardrailRule {
+	for _, rule := range rules {
+		if rule.Action == action {
+			return rule
+		}
+	}
+	return nil
+}
+
+// guardrailBlockedDetail describes a task stopped by a block rule.
+func guardrailBlockedDetail(rule *GuardrailRule, stage GuardrailStage) *ErrorDetail {
+	message := fmt.Sprintf("blocked by guardrail '%s' at %s", rule.Name, stage)
+	if rule.Message != "" {
+		message += ": " + rule.Message
+	}
+	return &ErrorDetail{Code: ErrorCodeGuardrailBlocked, Message: message, Hint: "Change the request so that it does not match the rule, or ask an operator to adjust the guardrails."}
+}
+
+// latestUserText returns the text of the last user message of a task.
+func latestUserText(task *Task) string {
+	for i := len(task.Messages) - 1; i >= 0; i-- {
+		if task.Messages[i].Role == RoleUser {
+			return messageText(task.Messages[i])
+		}
+	}
+	return ""
+}
+
+func messageText(msg Message) string {
+	var texts []string
+	for _, part := range msg.Parts {
+		if p, ok := part.(TextPart); ok {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// checkLLMGuardrails evaluates the before_llm rules of a task. It returns the
+// context for the LLM call, with the model of a downgrade rule applied, or
+// the error detail of a block rule.
+func (te *TaskExecutor) checkLLMGuardrails(ctx context.Context, task *Task) (context.Context, *ErrorDetail) {
+	rules := te.Guardrails.Evaluate(GuardrailBeforeLLM, GuardrailInput{Task: task, Content: latestUserText(task)})
+	recordGuardrailEvents(te.TaskStore, task.ID, GuardrailBeforeLLM, rules, "")
+	if rule := firstRule(rules, GuardrailBlock); rule != nil {
+		return ctx, te.failGuardrail(task.ID, rule, GuardrailBeforeLLM)
+	}
+	if rule := firstRule(rules, GuardrailDowngradeModel); rule != nil {
+		log.Printf("[Task %s] Guardrail '%s' downgrades the model to %s.", task.ID, rule.Name, rule.Model)
+		ctx = llm.WithModel(ctx, rule.Model)
+	}
+	return ctx, nil
+}
+
+// checkCompletionGuardrails evaluates the before_completion rules against
+// the final output. A block rule withholds the output and fails the task.
+func (te *TaskExecutor) checkCompletionGuardrails(taskID, output string) *ErrorDetail {
+	if te.Guardrails == nil {
+		return nil
+	}
+	task, err := te.TaskStore.GetTask(taskID)
+	if err != nil {
+		return nil
+	}
+	rules := te.Guardrails.Evaluate(GuardrailBeforeCompletion, GuardrailInput{Task: task, Content: output})
+	recordGuardrailEvents(te.TaskStore, taskID, GuardrailBeforeCompletion, rules, "")
+	rule := firstRule(rules, GuardrailBlock)
+	if rule == nil {
+		return nil
+	}
+	te.TaskStore.UpdateTask(taskID, func(task *Task) error {
+		for i := len(task.Messages) - 1; i >= 0; i-- {
+			if task.Messages[i].Role == RoleAssistant {
+				task.Messages[i].Parts = []Part{TextPart{Type: "text", Text: fmt.Sprintf("[Output withheld by guardrail '%s']", rule.Name)}}
+				break
+			}
+		}
+		return nil
+	})
+	return te.failGuardrail(taskID, rule, GuardrailBeforeCompletion)
+}
+
+func (te *TaskExecutor) failGuardrail(taskID string, rule *GuardrailRule, stage GuardrailStage) *ErrorDetail {
+	detail := guardrailBlockedDetail(rule, stage)
+	log.Printf("[Task %s] %s", taskID, detail.Message)
+	te.TaskStore.UpdateTask(taskID, func(task *Task) error {
+		setTaskError(task, detail)
+		task.State = TaskStateFailed
+		return nil
+	})
+	return detail
+}
+
+// ToolApproval is a tool call held back by a require_approval rule.
+type ToolApproval struct {
+	ID          string    `json:"id"`
+	Rule        string    `json:"rule"`
+	Tool        string    `json:"tool"`
+	Args        string    `json:"args,omitempty"`
+	Status      string    `json:"status"` // pending, approved, denied or used
+	Comment     string    `json:"comment,omitempty"`
+	RequestedAt time.Time `json:"requestedAt"`
+	DecidedAt   time.Time `json:"decidedAt,omitempty"`
+}
+
+// Statuses of ToolApproval.
+const (
+	ApprovalPending  = "pending"
+	ApprovalApproved = "approved"
+	ApprovalDenied   = "denied"
+	ApprovalUsed     = "used" // An approved call was executed
+)
+
+var errApprovalRequired = errors.New("tool call requires approval")
+
+// checkToolGuardrails evaluates the before_tool rules of a call. It returns
+// nil if the tool may run, consuming a matching approval if one is needed.
+func checkToolGuardrails(store TaskStore, guardrails *Guardrails, taskID string, toolCall ToolCall) error {
+	if guardrails == nil {
+		return nil
+	}
+	task, err := store.GetTask(taskID)
+	if err != nil {
+		return nil
+	}
+	rules := guardrails.Evaluate(GuardrailBeforeTool, GuardrailInput{Task: task, ToolCall: &toolCall})
+	recordGuardrailEvents(store, taskID, GuardrailBeforeTool, rules, toolCall.Function.Name)
+	if rule := firstRule(rules, GuardrailBlock); rule != nil {
+		message := fmt.Sprintf("tool '%s' is blocked by guardrail '%s'", toolCall.Function.Name, rule.Name)
+		if rule.Message != "" {
+			message += ": " + rule.Message
+		}
+		return errors.New(message)
+	}
+	rule := firstRule(rules, GuardrailRequireApproval)
+	if rule == nil {
+		return nil
+	}
+
+	var approvalErr error
+	_, err = store.UpdateTask(taskID, func(task *Task) error {
+		for i := range task.Approvals {
+			approval := &task.Approvals[i]
+			if approval.Tool != toolCall.Function.Name || approval.Args != toolCall.Function.Content {
+				continue
+			}
+			switch approval.Status {
+			case ApprovalApproved:
+				approval.Status = ApprovalUsed
+				return nil
+			case ApprovalPending:
+				approvalErr = fmt.Errorf("%w (guardrail '%s', approval %s is pending)", errApprovalRequired, rule.Name, approval.ID)
+				return nil
+			}
+		}
+		approval := ToolApproval{ID: uuid.New().String(), Rule: rule.Name, Tool: toolCall.Function.Name, Args: toolCall.Function.Content, Status: ApprovalPending, RequestedAt: time.Now().UTC()}
+		task.Approvals = append(task.Approvals, approval)
+		approvalErr = fmt.Errorf("%w (guardrail '%s', approval %s)", errApprovalRequired, rule.Name, approval.ID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %v", errApprovalRequired, err)
+	}
+	return approvalErr
+}
+
+// approvalMessage is the tool result of a call waiting for approval.
+func approvalMessage(err error) string {
+	return fmt.Sprintf("Error: %v. The task pauses until a user approves or denies the call; it then resumes and you may retry the exact same call if it was approved.", err)
+}
+
+// hasPendingApproval reports whether a tool call of the task waits for approval.
+func (te *TaskExecutor) hasPendingApproval(taskID string) bool {
+	task, err := te.TaskStore.GetTask(taskID)
+	if err != nil {
+		return false
+	}
+	for _, approval := range task.Approvals {
+		if approval.Status == ApprovalPending {
+			return true
+		}
+	}
+	return false
+}
+
+// ApproveParams are the parameters of "tasks/approve".
+type ApproveParams struct {
+	ID         string `json:"id"`         // Task ID
+	ApprovalID string `json:"approvalId"` // ID of the pending approval
+	Approve    bool   `json:"approve"`
+	Comment    string `json:"comment,omitempty"`
+}
+
+// DecideApproval approves or denies a pending tool call and resumes the task
+// with a message telling the model the decision.
+func (te *TaskExecutor) DecideApproval(params ApproveParams) (*ToolApproval, error) {
+	var decided ToolApproval
+	errNoApproval := fmt.Errorf("no pending approval %s", params.ApprovalID)
+	var decideErr error
+	_, err := te.TaskStore.UpdateTask(params.ID, func(task *Task) error {
+		for i := range task.Approvals {
+			approval := &task.Approvals[i]
+			if approval.ID != params.ApprovalID {
+				continue
+			}
+			if approval.Status != ApprovalPending {
+				decideErr = errNoApproval
+				return nil
+			}
+			approval.Status = ApprovalDenied
+			if params.Approve {
+				approval.Status = ApprovalApproved
+			}
+			approval.Comment = params.Comment
+			approval.DecidedAt = time.Now().UTC()
+			decided = *approval
+			return nil
+		}
+		decideErr = errNoApproval
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if decideErr != nil {
+		return nil, decideErr
+	}
+
+	text := fmt.Sprintf("The call of tool '%s' was approved. Retry the exact same call now.", decided.Tool)
+	if !params.Approve {
+		text = fmt.Sprintf("The call of tool '%s' was denied. Do not retry it; find another way or explain why the task cannot be done.", decided.Tool)
+	}
+	if params.Comment != "" {
+		text += " Comment: " + params.Comment
+	}
+	if te.hasPendingApproval(params.ID) {
+		return &decided, nil // Resume once every pending call is decided
+	}
+	message := Message{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: text}}, Timestamp: time.Now().UTC()}
+	if err := te.AddTaskMessageAndProcess(params.ID, message); err != nil {
+		return &decided, err
+	}
+	return &decided, nil
+}
+
+// TasksApproveHandler handles "tasks/approve", deciding a tool call held back
+// by a require_approval guardrail.
+func TasksApproveHandler(taskExecutor *TaskExecutor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params ApproveParams
+		rpcReq, ok := decodeJSONRPCRequest(w, r, &params)
+		if !ok {
+			return
+		}
+		if params.ID == "" || params.ApprovalID == "" {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: "Invalid Params: id and approvalId are required"})
+			return
+		}
+		if HiddenFromCaller(r.Context(), taskExecutor.TaskStore, params.ID) {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32001, Message: "Not Found: Task not found"})
+			return
+		}
+		if _, err := taskExecutor.TaskStore.GetTask(params.ID); err != nil {
+			if errors.Is(err, ErrTaskNotFound) {
+				sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32001, Message: "Not Found: Task not found"})
+			} else {
+				sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32000, Message: "Internal Server Error", Data: err.Error()})
+			}
+			return
+		}
+		approval, err := taskExecutor.DecideApproval(params)
+		if approval == nil {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32002, Message: fmt.Sprintf("Conflict: %v", err)})
+			return
+		}
+		if err != nil {
+			log.Printf("[TaskApprove %v] Decided approval %s but failed to resume task %s: %v", rpcReq.ID, approval.ID, params.ID, err)
+		}
+		sendJSONRPCResponse(w, rpcReq.ID, map[string]interface{}{"id": params.ID, "approval": approval}, nil)
+	}
+}
@@ -0,0 +1,118 @@
+package a2a
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"ka/tools"
+)
+
+func TestNewGuardrailsValidatesRules(t *testing.T) {
+	valid := `[
+		{"name": "no-rm", "stage": "before_tool", "when": {"tools": ["execute_*"], "args": "rm -rf"}, "action": "block"},
+		{"name": "cheap", "stage": "before_llm", "when": {"labels": ["triage"]}, "action": "downgrade_model", "model": "small"}
+	]`
+	if _, err := LoadGuardrails(valid); err != nil {
+		t.Fatalf("LoadGuardrails: %v", err)
+	}
+	for _, bad := range []string{
+		`[{"name": "a", "stage": "after", "action": "block"}]`,
+		`[{"name": "a", "stage": "before_llm", "action": "delete"}]`,
+		`[{"name": "a", "stage": "before_llm", "action": "require_approval"}]`,
+		`[{"name": "a", "stage": "before_tool", "action": "downgrade_model", "model": "x"}]`,
+		`[{"name": "a", "stage": "before_llm", "when": {"tools": ["x"]}, "action": "block"}]`,
+		`[{"name": "a", "stage": "before_completion", "when": {"content": "("}, "action": "block"}]`,
+		`[{"name": "a", "stage": "before_llm", "action": "block"}, {"name": "a", "stage": "before_llm", "action": "block"}]`,
+	} {
+		if _, err := LoadGuardrails(bad); err == nil {
+			t.Errorf("expected %s to be rejected", bad)
+		}
+	}
+}
+
+func TestGuardrailConditions(t *testing.T) {
+	g, _ := LoadGuardrails(`[
+		{"name": "prod-fetch", "stage": "before_tool", "when": {"project": "prod", "tools": ["fetch_*"], "args": "internal"}, "action": "annotate"}
+	]`)
+	call := &ToolCall{Function: tools.FunctionCall{Name: "fetch_url", Content: `{"url": "http://internal/"}`}}
+	if rules := g.Evaluate(GuardrailBeforeTool, GuardrailInput{Task: &Task{Project: "prod"}, ToolCall: call}); len(rules) != 1 {
+		t.Errorf("expected the rule to match, got %d", len(rules))
+	}
+	if rules := g.Evaluate(GuardrailBeforeTool, GuardrailInput{Task: &Task{Project: "dev"}, ToolCall: call}); len(rules) != 0 {
+		t.Error("expected another project not to match")
+	}
+	other := &ToolCall{Function: tools.FunctionCall{Name: "read_file", Content: `internal`}}
+	if rules := g.Evaluate(GuardrailBeforeTool, GuardrailInput{Task: &Task{Project: "prod"}, ToolCall: other}); len(rules) != 0 {
+		t.Error("expected another tool not to match")
+	}
+}
+
+func TestGuardrailBlocksBeforeLLM(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	te := NewTaskExecutor(&staticLLMClient{reply: "ok"}, store, nil, "")
+	te.Guardrails, _ = LoadGuardrails(`[{"name": "secrets", "stage": "before_llm", "when": {"content": "(?i)password"}, "action": "block", "message": "no credentials"}]`)
+
+	task, _ := store.CreateTask("t", "", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "my Password is 123"}}}}, "")
+	te.ExecuteTask(context.Background(), task)
+
+	task, _ = store.GetTask(task.ID)
+	if task.State != TaskStateFailed || task.ErrorDetail == nil || task.ErrorDetail.Code != ErrorCodeGuardrailBlocked {
+		t.Fatalf("expected a guardrail failure, got %s %+v", task.State, task.ErrorDetail)
+	}
+	if len(task.GuardrailEvents) != 1 || task.GuardrailEvents[0].Rule != "secrets" {
+		t.Errorf("expected the match to be recorded, got %+v", task.GuardrailEvents)
+	}
+}
+
+func TestGuardrailWithholdsCompletion(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	te := NewTaskExecutor(&staticLLMClient{reply: "The key is sk-12345"}, store, nil, "")
+	te.Guardrails, _ = LoadGuardrails(`[{"name": "leak", "stage": "before_completion", "when": {"content": "sk-[0-9]+"}, "action": "block"}]`)
+
+	task, _ := store.CreateTask("t", "", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "key?"}}}}, "")
+	te.ExecuteTask(context.Background(), task)
+
+	task, _ = store.GetTask(task.ID)
+	if task.State != TaskStateFailed {
+		t.Fatalf("expected FAILED, got %s", task.State)
+	}
+	for _, msg := range task.Messages {
+		if strings.Contains(messageText(msg), "sk-12345") {
+			t.Errorf("expected the output to be withheld, found %q", messageText(msg))
+		}
+	}
+}
+
+func TestGuardrailRequiresApprovalForToolCalls(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	call := `<tool id="fetch_url">{"url": "https://example.com"}</tool>`
+	client := &scriptedLLMClient{replies: []string{call, call, "Fetched."}}
+	te := NewTaskExecutor(client, store, map[string]tools.Tool{"fetch_url": &echoTool{output: "page"}}, "")
+	te.Guardrails, _ = LoadGuardrails(`[{"name": "web", "stage": "before_tool", "when": {"tools": ["fetch_url"]}, "action": "require_approval"}]`)
+
+	task, _ := store.CreateTask("t", "", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "fetch it"}}}}, "")
+	te.ExecuteTask(context.Background(), task)
+
+	parked, _ := store.GetTask(task.ID)
+	if parked.State != TaskStateInputRequired || len(parked.Approvals) != 1 || parked.Approvals[0].Status != ApprovalPending {
+		t.Fatalf("expected the task to wait for approval, got %s %+v", parked.State, parked.Approvals)
+	}
+
+	if _, err := te.DecideApproval(ApproveParams{ID: task.ID, ApprovalID: "unknown", Approve: true}); err == nil {
+		t.Error("expected an unknown approval to be rejected")
+	}
+	approval, err := te.DecideApproval(ApproveParams{ID: task.ID, ApprovalID: parked.Approvals[0].ID, Approve: true})
+	if err != nil || approval.Status != ApprovalApproved {
+		t.Fatalf("DecideApproval: %+v, %v", approval, err)
+	}
+	waitForState(t, store, task.ID, TaskStateCompleted)
+
+	done, _ := store.GetTask(task.ID)
+	if done.Approvals[0].Status != ApprovalUsed {
+		t.Errorf("expected the approval to be used by the retried call, got %s", done.Approvals[0].Status)
+	}
+	if usage := done.ToolUsage["fetch_url"]; usage.Calls != 1 {
+		t.Errorf("expected the tool to run once, got %+v", done.ToolUsage)
+	}
+}
@@ -0,0 +1,82 @@
+package a2a
+
+import (
+	"errors"
+	"log"
+	"net/http"
+)
+
+// TaskExportParams defines the parameters for "tasks/export".
+type TaskExportParams struct {
+	ID       string            `json:"id"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// TaskImportParams defines the parameters for "tasks/import".
+type TaskImportParams struct {
+	Bundle  *TaskBundle `json:"bundle"`
+	Project string      `json:"project,omitempty"` // Project of the imported tasks; defaults to the caller's
+}
+
+// TasksExportHandler handles the "tasks/export" JSON-RPC method.
+// It returns a TaskBundle with the task, its sub-tasks and their artifacts.
+func TasksExportHandler(taskStore TaskStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params TaskExportParams
+		rpcReq, ok := decodeJSONRPCRequest(w, r, &params)
+		if !ok {
+			return
+		}
+		if params.ID == "" {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: "Invalid Params: missing task ID"})
+			return
+		}
+
+		if HiddenFromCaller(r.Context(), taskStore, params.ID) {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32001, Message: "Not Found: Task not found"})
+			return
+		}
+		bundle, err := ExportTaskBundle(taskStore, params.ID, params.Metadata)
+		if err != nil {
+			if errors.Is(err, ErrTaskNotFound) {
+				sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32001, Message: "Not Found: Task not found"})
+				return
+			}
+			log.Printf("[TaskExport %v] Error exporting task %s: %v", rpcReq.ID, params.ID, err)
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32000, Message: "Internal Server Error: Failed to export task", Data: err.Error()})
+			return
+		}
+		sendJSONRPCResponse(w, rpcReq.ID, bundle, nil)
+	}
+}
+
+// TasksImportHandler handles the "tasks/import" JSON-RPC method.
+// It recreates the tasks of a bundle produced by tasks/export on this agent,
+// in the project of the caller.
+func TasksImportHandler(taskExecutor *TaskExecutor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params TaskImportParams
+		rpcReq, ok := decodeJSONRPCRequest(w, r, &params)
+		if !ok {
+			return
+		}
+		if params.Bundle == nil {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: "Invalid Params: missing bundle"})
+			return
+		}
+
+		projectName, err := taskExecutor.Projects.Resolve(r.Context(), params.Project)
+		if err != nil {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, projectError(err))
+			return
+		}
+
+		result, err := ImportTaskBundle(taskExecutor.TaskStore, params.Bundle, projectName)
+		if err != nil {
+			log.Printf("[TaskImport %v] Error importing bundle: %v", rpcReq.ID, err)
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: "Invalid Params: Failed to import bundle", Data: err.Error()})
+			return
+		}
+		sendJSONRPCResponse(w, rpcReq.ID, result, nil)
+	}
+}
@@ -0,0 +1,58 @@
+package a2a
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"ka/tools"
+)
+
+// TerminateCommandParams defines the parameters of "tasks/terminateCommand".
+type TerminateCommandParams struct {
+	ID         string `json:"id"`                   // Task ID
+	ToolCallID string `json:"toolCallId,omitempty"` // Empty terminates all follow-mode commands of the task
+}
+
+// TerminateCommandResult is returned by "tasks/terminateCommand".
+type TerminateCommandResult struct {
+	Terminated int `json:"terminated"`
+}
+
+// TasksTerminateCommandHandler handles "tasks/terminateCommand": it stops
+// commands the task runs in follow mode. The tool returns the output collected
+// so far to the model, noting that the user terminated it.
+func TasksTerminateCommandHandler(taskExecutor *TaskExecutor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params TerminateCommandParams
+		rpcReq, ok := decodeJSONRPCRequest(w, r, &params)
+		if !ok {
+			return
+		}
+		if params.ID == "" {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: "Invalid Params: missing task ID"})
+			return
+		}
+		if HiddenFromCaller(r.Context(), taskExecutor.TaskStore, params.ID) {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32001, Message: "Not Found: Task not found"})
+			return
+		}
+		if _, err := taskExecutor.TaskStore.GetTask(params.ID); err != nil {
+			if errors.Is(err, ErrTaskNotFound) {
+				sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32001, Message: "Not Found: Task not found"})
+			} else {
+				sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32000, Message: "Internal Server Error", Data: err.Error()})
+			}
+			return
+		}
+
+		terminated := tools.TerminateCommands(params.ID, params.ToolCallID)
+		if terminated == 0 {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32002, Message: fmt.Sprintf("Conflict: task %s has no running follow-mode command", params.ID)})
+			return
+		}
+		log.Printf("[TerminateCommand %v] Terminated %d command(s) of task %s.", rpcReq.ID, terminated, params.ID)
+		sendJSONRPCResponse(w, rpcReq.ID, TerminateCommandResult{Terminated: terminated}, nil)
+	}
+}
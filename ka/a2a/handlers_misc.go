@@ -62,6 +62,11 @@ func TasksArtifactHandler(taskStore TaskStore) http.HandlerFunc {
 			return
 		}
 
+		if HiddenFromCaller(r.Context(), taskStore, taskID) {
+			http.Error(w, "Not Found: Task not found", http.StatusNotFound)
+			return
+		}
+
 		data, artifact, err := taskStore.GetArtifactData(taskID, artifactID)
 		if err != nil {
 			log.Printf("[Artifact] Failed to retrieve artifact '%s' for task '%s': %v", artifactID, taskID, err)
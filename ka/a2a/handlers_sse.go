@@ -7,6 +7,7 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"sync"
 	"time"
 )
 
@@ -15,6 +16,11 @@ type SSEWriter struct {
 	w       http.ResponseWriter
 	flusher http.Flusher
 	ctx     context.Context
+	mu      sync.Mutex // Tool output and progress events are sent from other goroutines
+
+	eventBuffer int             // Events queued for the client by forwarders such as forwardSubTaskStatus
+	events      map[string]bool // Event types the subscriber selected; nil sends all
+	stream      *taskStream     // Set on the writer of a task run, which publishes to the stream's subscribers
 }
 
 // NewSSEWriter creates and initializes a new SSEWriter.
@@ -31,11 +37,28 @@ func NewSSEWriter(w http.ResponseWriter, ctx context.Context) (*SSEWriter, error
 	w.WriteHeader(http.StatusOK)
 	flusher.Flush()
 
-	return &SSEWriter{w: w, flusher: flusher, ctx: ctx}, nil
+	return &SSEWriter{w: w, flusher: flusher, ctx: ctx, eventBuffer: DefaultSSEEventBuffer}, nil
+}
+
+// wants reports whether the subscriber selected the event type.
+func (sw *SSEWriter) wants(event string) bool {
+	return sw.events == nil || event == rpcResponseEvent || sw.events[event]
 }
 
-// SendEvent sends a named event with data to the client.
+// SendEvent sends a named event with data to the client. Events the
+// subscriber did not select are dropped.
 func (sw *SSEWriter) SendEvent(event, data string) error {
+	if sw.stream != nil {
+		return sw.stream.publish(event, data)
+	}
+	if !sw.wants(event) {
+		return nil
+	}
+	return sw.writeFrame(0, event, data)
+}
+
+// writeFrame writes one event, with an id: field unless id is 0.
+func (sw *SSEWriter) writeFrame(id int64, event, data string) error {
 	select {
 	case <-sw.ctx.Done():
 		log.Println("[SSE] Client disconnected")
@@ -43,6 +66,11 @@ func (sw *SSEWriter) SendEvent(event, data string) error {
 	default:
 	}
 
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if id > 0 {
+		fmt.Fprintf(sw.w, "id: %d\n", id)
+	}
 	if event != "" {
 		fmt.Fprintf(sw.w, "event: %s\n", event)
 	}
@@ -55,6 +83,9 @@ func (sw *SSEWriter) SendEvent(event, data string) error {
 // Write implements the io.Writer interface for SSEWriter.
 // It marshals the byte slice into a JSON object {"chunk": "..."} and sends it as a "message" event.
 func (sw *SSEWriter) Write(p []byte) (int, error) {
+	if !sw.wants("message") {
+		return len(p), nil // Token deltas are the bulk of the stream; skip encoding them
+	}
 	jsonData, err := json.Marshal(map[string]string{"chunk": string(p)})
 	if err != nil {
 		log.Printf("[SSE] Error marshalling chunk: %v. Sending raw.", err)
@@ -69,18 +100,36 @@ func (sw *SSEWriter) Write(p []byte) (int, error) {
 	return len(p), nil
 }
 
+// SendRetry sends the retry: field, the delay before the client reconnects
+// after the stream breaks.
+func (sw *SSEWriter) SendRetry(delay time.Duration) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	fmt.Fprintf(sw.w, "retry: %d\n\n", delay.Milliseconds())
+	sw.flusher.Flush()
+}
+
 // KeepAlive sends periodic keepalive comments to prevent connection closure.
+// An interval of 0 sends none.
 func (sw *SSEWriter) KeepAlive(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
 	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 	for {
 		select {
 		case <-ticker.C:
-			if _, err := fmt.Fprintf(sw.w, ": keepalive\n\n"); err != nil {
+			sw.mu.Lock()
+			_, err := fmt.Fprintf(sw.w, ": keepalive\n\n")
+			if err == nil {
+				sw.flusher.Flush()
+			}
+			sw.mu.Unlock()
+			if err != nil {
 				log.Printf("[SSE] KeepAlive write error: %v", err)
 				return
 			}
-			sw.flusher.Flush()
 		case <-sw.ctx.Done():
 			log.Println("[SSE] KeepAlive stopping due to client disconnect.")
 			return
@@ -88,9 +137,23 @@ func (sw *SSEWriter) KeepAlive(interval time.Duration) {
 	}
 }
 
-// TasksSendSubscribeHandler handles POST /tasks/sendSubscribe requests.
+// rpcResponseEvent is the SSE event that carries the JSON-RPC response to a
+// tasks/sendSubscribe request. It is always the first frame of the stream, so
+// clients that correlate requests and responses by id get exactly one
+// response; the events after it are notifications about the task.
+const rpcResponseEvent = "rpc-response"
+
+// TasksSendSubscribeHandler handles tasks/sendSubscribe requests.
 // It creates a task, initializes an SSE connection, sends the initial task state,
 // and then delegates streaming updates to the TaskExecutor.
+//
+// The body is either a JSON-RPC request or, for older clients posting to
+// /tasks/sendSubscribe directly, the bare SendTaskParams. For JSON-RPC requests
+// errors before the stream starts are plain JSON-RPC error responses, and the
+// stream opens with an "rpc-response" event whose data is the response:
+//
+//	event: rpc-response
+//	data: {"jsonrpc":"2.0","id":1,"result":{"id":"<task id>","status":{"state":"submitted"}}}
 func TasksSendSubscribeHandler(taskExecutor *TaskExecutor) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodPost {
@@ -105,24 +168,50 @@ func TasksSendSubscribeHandler(taskExecutor *TaskExecutor) http.HandlerFunc {
 		}
 		defer r.Body.Close()
 
-		// NOTE: This handler assumes a direct HTTP POST, not JSON-RPC like the others were changed to.
-		// If this endpoint *should* be JSON-RPC, it needs the full JSONRPCRequest/Response handling.
-		// Assuming direct POST for now, matching the original structure but using the renamed type.
-		var params SendTaskParams // Use the renamed struct from handlers_task.go
+		var rpcReq JSONRPCRequest
+		if err := json.Unmarshal(body, &rpcReq); err != nil {
+			http.Error(w, "Bad Request: Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		isRPC := rpcReq.Jsonrpc != ""
+		if isRPC {
+			body = rpcReq.Params
+		}
 
-		// Unmarshal the body directly into the params struct (assuming non-JSON-RPC)
+		// reject answers a request that fails before the stream starts
+		reject := func(message string, status int) {
+			if !isRPC {
+				http.Error(w, message, status)
+				return
+			}
+			code := -32000
+			switch status {
+			case http.StatusBadRequest:
+				code = -32602
+			case http.StatusForbidden:
+				code = -32003
+			}
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: code, Message: message})
+		}
+
+		var params SendTaskParams
 		if err := json.Unmarshal(body, &params); err != nil {
-			http.Error(w, "Bad Request: Invalid JSON", http.StatusBadRequest)
+			reject("Bad Request: Invalid params", http.StatusBadRequest)
+			return
+		}
+		sseOpts, err := taskExecutor.SSE.Get().Override(r.URL.Query())
+		if err != nil {
+			reject("Bad Request: "+err.Error(), http.StatusBadRequest)
 			return
 		}
 
 		// Validate the Message field within the params
 		if params.Message.Role == "" {
-			http.Error(w, "Bad Request: Message has empty role", http.StatusBadRequest)
+			reject("Bad Request: Message has empty role", http.StatusBadRequest)
 			return
 		}
 		if len(params.Message.Parts) == 0 {
-			http.Error(w, "Bad Request: Message has empty parts array", http.StatusBadRequest)
+			reject("Bad Request: Message has empty parts array", http.StatusBadRequest)
 			return
 		}
 
@@ -130,41 +219,41 @@ func TasksSendSubscribeHandler(taskExecutor *TaskExecutor) http.HandlerFunc {
 		msg := params.Message // Use the single message
 		for j, part := range msg.Parts {
 			if part == nil {
-				http.Error(w, fmt.Sprintf("Bad Request: Message part %d is null", j), http.StatusBadRequest)
+				reject(fmt.Sprintf("Bad Request: Message part %d is null", j), http.StatusBadRequest)
 				return
 			}
 			// Check concrete part types and their fields
 			switch p := part.(type) {
 			case TextPart:
 				if p.Type == "" {
-					http.Error(w, fmt.Sprintf("Bad Request: TextPart %d has empty type", j), http.StatusBadRequest)
+					reject(fmt.Sprintf("Bad Request: TextPart %d has empty type", j), http.StatusBadRequest)
 					return
 				}
 				if p.Text == "" {
-					http.Error(w, fmt.Sprintf("Bad Request: TextPart %d has empty text", j), http.StatusBadRequest)
+					reject(fmt.Sprintf("Bad Request: TextPart %d has empty text", j), http.StatusBadRequest)
 					return
 				}
 			case FilePart:
 				if p.Type == "" {
-					http.Error(w, fmt.Sprintf("Bad Request: FilePart %d has empty type", j), http.StatusBadRequest)
+					reject(fmt.Sprintf("Bad Request: FilePart %d has empty type", j), http.StatusBadRequest)
 					return
 				}
 				if p.URI == "" {
-					http.Error(w, fmt.Sprintf("Bad Request: FilePart %d has empty URI", j), http.StatusBadRequest)
+					reject(fmt.Sprintf("Bad Request: FilePart %d has empty URI", j), http.StatusBadRequest)
 					return
 				}
 				if p.MimeType == "" {
-					http.Error(w, fmt.Sprintf("Bad Request: FilePart %d has empty mime_type", j), http.StatusBadRequest)
+					reject(fmt.Sprintf("Bad Request: FilePart %d has empty mime_type", j), http.StatusBadRequest)
 					return
 				}
 			case DataPart:
 				if p.Type == "" {
-					http.Error(w, fmt.Sprintf("Bad Request: DataPart %d has empty type", j), http.StatusBadRequest)
+					reject(fmt.Sprintf("Bad Request: DataPart %d has empty type", j), http.StatusBadRequest)
 					return
 				}
 				// Validate Data field (which is 'any')
 				if p.Data == nil {
-					http.Error(w, fmt.Sprintf("Bad Request: DataPart %d has null data", j), http.StatusBadRequest)
+					reject(fmt.Sprintf("Bad Request: DataPart %d has null data", j), http.StatusBadRequest)
 					return
 				}
 				// Check if the underlying data has content.
@@ -189,24 +278,25 @@ func TasksSendSubscribeHandler(taskExecutor *TaskExecutor) http.HandlerFunc {
 				default:
 					// If it's a different type, consider it an error for strict validation.
 					log.Printf("[TaskSendSubscribe] Warning: DataPart data field has unexpected type %T for part %d", dataVal, j)
-					http.Error(w, fmt.Sprintf("Bad Request: DataPart %d has unexpected data type %T", j, dataVal), http.StatusBadRequest)
+					reject(fmt.Sprintf("Bad Request: DataPart %d has unexpected data type %T", j, dataVal), http.StatusBadRequest)
 					return
 				}
 
 				if !hasContent {
-					http.Error(w, fmt.Sprintf("Bad Request: DataPart %d has empty data content", j), http.StatusBadRequest)
+					reject(fmt.Sprintf("Bad Request: DataPart %d has empty data content", j), http.StatusBadRequest)
 					return
 				}
 
 				if p.MimeType == "" {
-					http.Error(w, fmt.Sprintf("Bad Request: DataPart %d has empty mime_type", j), http.StatusBadRequest)
+					reject(fmt.Sprintf("Bad Request: DataPart %d has empty mime_type", j), http.StatusBadRequest)
 					return
 				}
 			default:
-				// This case should ideally not be hit if UnmarshalJSON for Message/Part works correctly,
-				// but added as a safeguard.
-				http.Error(w, fmt.Sprintf("Bad Request: part %d has unknown type", j), http.StatusBadRequest)
-				return
+				// Custom part types are decoded by their registered codec or kept as CustomPart
+				if part.GetType() == "" {
+					reject(fmt.Sprintf("Bad Request: part %d has unknown type", j), http.StatusBadRequest)
+					return
+				}
 			}
 		}
 
@@ -224,10 +314,23 @@ func TasksSendSubscribeHandler(taskExecutor *TaskExecutor) http.HandlerFunc {
 		// Create task using the single message, wrapped in a slice for CreateTask
 		// Pass the task name, system message from the TaskExecutor, and the input message
 		// For tasks created directly via API, parentTaskID is an empty string.
-		task, err := taskExecutor.TaskStore.CreateTask(taskName, taskExecutor.SystemMessage, []Message{params.Message}, "")
-		if err != nil {
-			log.Printf("[TaskSendSubscribe] Error creating task: %v", err)
-			http.Error(w, "Internal Server Error: Failed to create task", http.StatusInternalServerError)
+		task, rpcErr := taskExecutor.createTask(r.Context(), taskName, params)
+		if rpcErr != nil {
+			log.Printf("[TaskSendSubscribe] Error creating task: %s", rpcErr.Message)
+			if isRPC {
+				sendJSONRPCResponse(w, rpcReq.ID, nil, rpcErr)
+				return
+			}
+			status := http.StatusInternalServerError
+			switch rpcErr.Code {
+			case -32602:
+				status = http.StatusBadRequest
+			case -32003:
+				status = http.StatusForbidden
+			case -32005:
+				status = http.StatusServiceUnavailable
+			}
+			http.Error(w, rpcErr.Message, status)
 			return
 		}
 		taskID := task.ID
@@ -240,15 +343,41 @@ func TasksSendSubscribeHandler(taskExecutor *TaskExecutor) http.HandlerFunc {
 			return
 		}
 
-		go sseWriter.KeepAlive(20 * time.Second)
+		sseWriter.eventBuffer = sseOpts.EventBuffer
+		sseWriter.events = sseOpts.eventFilter()
+		if sseOpts.Retry > 0 {
+			sseWriter.SendRetry(sseOpts.Retry)
+		}
+		go sseWriter.KeepAlive(sseOpts.KeepAlive)
+
+		if isRPC {
+			response, _ := json.Marshal(JSONRPCResponse{
+				Jsonrpc: "2.0",
+				ID:      rpcReq.ID,
+				Result:  map[string]interface{}{"id": taskID, "status": TaskStatus{State: task.State, Timestamp: task.CreatedAt.Format(time.RFC3339)}},
+			})
+			sseWriter.SendEvent(rpcResponseEvent, string(response))
+		}
+
+		// The run outlives this connection by the resubscribe grace period, so
+		// a client whose connection dropped can reattach with tasks/resubscribe
+		runCtx, cancelRun := context.WithCancel(context.WithoutCancel(r.Context()))
+		stream := taskExecutor.openStream(taskID, cancelRun)
+		publisher := stream.publisher(runCtx, sseOpts.EventBuffer)
+		stream.attach(sseWriter, 0, nil)
 
 		initialStateData, _ := json.Marshal(map[string]string{"task_id": taskID, "status": string(TaskStateSubmitted)})
-		sseWriter.SendEvent("state", string(initialStateData)) // Send initial state
+		publisher.SendEvent("state", string(initialStateData)) // Send initial state
 
 		// Delegate the rest of the streaming to the executor
-		taskExecutor.ExecuteTaskStream(r.Context(), task, sseWriter) // Pass context, task, and writer
+		go func() {
+			defer cancelRun()
+			defer taskExecutor.closeStream(stream)
+			taskExecutor.ExecuteTaskStream(runCtx, task, publisher)
+		}()
 
-		log.Printf("[Task %s] sendSubscribe handler finished, streaming delegated to executor.\n", taskID)
-		// The response is kept open by ExecuteTaskStream
+		// The response is kept open until the run ends or the client disconnects
+		stream.follow(sseWriter)
+		log.Printf("[Task %s] sendSubscribe handler finished.\n", taskID)
 	}
 }
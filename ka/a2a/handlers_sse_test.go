@@ -0,0 +1,78 @@
+package a2a
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSendSubscribeOpensWithRPCResponse(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	te := NewTaskExecutor(&staticLLMClient{reply: "ok"}, store, nil, "")
+
+	body := `{"jsonrpc":"2.0","id":"req-7","method":"tasks/sendSubscribe","params":{"message":{"role":"user","parts":[{"type":"text","text":"hi"}]}}}`
+	rec := httptest.NewRecorder()
+	TasksSendSubscribeHandler(te)(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+
+	frames := strings.Split(rec.Body.String(), "\n\n")
+	event, data, _ := strings.Cut(frames[0], "\n")
+	if event != "event: "+rpcResponseEvent {
+		t.Fatalf("expected the stream to open with an rpc-response event, got %q", frames[0])
+	}
+	var response struct {
+		Jsonrpc string `json:"jsonrpc"`
+		ID      string `json:"id"`
+		Result  struct {
+			ID     string     `json:"id"`
+			Status TaskStatus `json:"status"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(data, "data: ")), &response); err != nil {
+		t.Fatalf("rpc-response data is not JSON: %v", err)
+	}
+	if response.Jsonrpc != "2.0" || response.ID != "req-7" {
+		t.Errorf("expected a JSON-RPC 2.0 response with id req-7, got %+v", response)
+	}
+	if response.Result.Status.State != TaskStateSubmitted {
+		t.Errorf("expected the submitted state, got %q", response.Result.Status.State)
+	}
+	if _, err := store.GetTask(response.Result.ID); err != nil {
+		t.Errorf("result id %q is not a task: %v", response.Result.ID, err)
+	}
+	if n := strings.Count(rec.Body.String(), "event: "+rpcResponseEvent); n != 1 {
+		t.Errorf("expected exactly one rpc-response event, got %d", n)
+	}
+}
+
+func TestSendSubscribeRejectsWithRPCError(t *testing.T) {
+	te := NewTaskExecutor(&staticLLMClient{reply: "ok"}, NewInMemoryTaskStore(), nil, "")
+
+	body := `{"jsonrpc":"2.0","id":3,"method":"tasks/sendSubscribe","params":{"message":{"role":"user","parts":[]}}}`
+	rec := httptest.NewRecorder()
+	TasksSendSubscribeHandler(te)(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("expected a JSON response, got %q", ct)
+	}
+	var response JSONRPCResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &response); err != nil {
+		t.Fatalf("response is not JSON: %v", err)
+	}
+	if response.Error == nil || response.Error.Code != -32602 || response.ID != float64(3) {
+		t.Errorf("expected an invalid params error for id 3, got %+v", response)
+	}
+}
+
+func TestSendSubscribeAcceptsBareParams(t *testing.T) {
+	te := NewTaskExecutor(&staticLLMClient{reply: "ok"}, NewInMemoryTaskStore(), nil, "")
+
+	body := `{"message":{"role":"user","parts":[{"type":"text","text":"hi"}]}}`
+	rec := httptest.NewRecorder()
+	TasksSendSubscribeHandler(te)(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+
+	if !strings.HasPrefix(rec.Body.String(), "id: 1\nevent: state\n") {
+		t.Errorf("expected bare requests to stream without an rpc-response, got %q", rec.Body.String())
+	}
+}
@@ -8,7 +8,10 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"strings"
 	"time"
+
+	"ka/tools"
 )
 
 // --- JSON-RPC Structures ---
@@ -49,6 +52,22 @@ type SendTaskParams struct {
 	PushNotification interface{} `json:"pushNotification,omitempty"`
 	HistoryLength    *int        `json:"historyLength,omitempty"`
 	Metadata         interface{} `json:"metadata,omitempty"`
+	SubTaskPolicy    *SubTaskPolicy `json:"subTaskPolicy,omitempty"` // What to do when sub-tasks of this task fail
+	Mode             string         `json:"mode,omitempty"`          // Mode preset (see "modes/list"); defaults to the agent's mode
+	Debug            bool           `json:"debug,omitempty"`         // Step through iterations with "tasks/step" and record the execution trace
+	Project          string         `json:"project,omitempty"`       // Defaults to the project of the caller's API key
+	Labels           []string       `json:"labels,omitempty"`
+	TimeoutSeconds   int            `json:"timeoutSeconds,omitempty"` // Overrides the timeout of the mode and the agent default
+	ToolQuotas       ToolQuotas     `json:"toolQuotas,omitempty"`     // Overrides the agent's quotas per tool
+	MaxOutputTokens  int            `json:"maxOutputTokens,omitempty"`  // Completion token limit per LLM call; overrides the mode
+	MaxContinuations int            `json:"maxContinuations,omitempty"` // How often a completion cut off by the limit is continued
+	OutputArtifact   bool           `json:"outputArtifact,omitempty"`   // Stream the output into artifacts instead of the messages, for very long generations
+	AllTools         bool           `json:"allTools,omitempty"`         // Keep every tool definition in the system prompt despite -prune-tools
+	Provider         string         `json:"provider,omitempty"`         // LLM provider to run the task with, e.g. "google"; defaults to the agent's
+	Model            string         `json:"model,omitempty"`            // Model to run the task with; overrides the mode
+	OutputContract   *OutputContract `json:"outputContract,omitempty"`  // Artifacts the task must produce before it may complete
+	MaxTokens        int             `json:"maxTokens,omitempty"`       // Budget of prompt and completion tokens over all LLM calls; the task fails when it uses more
+	Priority         int             `json:"priority,omitempty"`        // Order of the task's LLM requests in the provider queue; higher goes first
 	// SkillID string    `json:"skill_id,omitempty"` // Keep if needed
 	// Context string    `json:"context,omitempty"` // Keep if needed
 }
@@ -58,6 +77,7 @@ type SendTaskParams struct {
 type ProvideInputParams struct {
 	TaskID   string      `json:"id"`      // Aligning with a2aClient.ts TaskInputParams
 	Input    Message     `json:"message"` // Aligning with a2aClient.ts TaskInputParams
+	Answers  map[string]interface{} `json:"answers,omitempty"` // Structured answer to a question with fields
 	Metadata interface{} `json:"metadata,omitempty"`
 }
 
@@ -87,6 +107,36 @@ func sendJSONRPCResponse(w http.ResponseWriter, id interface{}, result interface
 	}
 }
 
+// decodeJSONRPCRequest reads the JSON-RPC envelope from the request body and
+// decodes its params into the given struct. On failure it writes the error
+// response itself and returns ok=false.
+func decodeJSONRPCRequest(w http.ResponseWriter, r *http.Request, params interface{}) (rpcReq JSONRPCRequest, ok bool) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, `{"jsonrpc": "2.0", "error": {"code": -32700, "message": "Parse error: Cannot read body"}, "id": null}`, http.StatusOK)
+		return rpcReq, false
+	}
+	defer r.Body.Close()
+
+	if err := json.Unmarshal(body, &rpcReq); err != nil {
+		http.Error(w, `{"jsonrpc": "2.0", "error": {"code": -32700, "message": "Parse error: Invalid JSON"}, "id": null}`, http.StatusOK)
+		return rpcReq, false
+	}
+
+	if rpcReq.Jsonrpc != "2.0" || rpcReq.Method == "" {
+		sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32600, Message: "Invalid Request"})
+		return rpcReq, false
+	}
+
+	if params != nil && len(rpcReq.Params) > 0 {
+		if err := json.Unmarshal(rpcReq.Params, params); err != nil {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: fmt.Sprintf("Invalid Params: %v", err)})
+			return rpcReq, false
+		}
+	}
+	return rpcReq, true
+}
+
 // --- JSON-RPC Method Handlers ---
 
 // TasksSendHandler handles the "tasks/send" JSON-RPC method.
@@ -153,17 +203,16 @@ func TasksSendHandler(taskExecutor *TaskExecutor) http.HandlerFunc {
 			}
 		}
 
-		// Pass the task name, current system prompt from the LLMClient, and the initial message
-		// CreateTask now expects []Message for initial messages
-		// For tasks created directly via API, parentTaskID is an empty string.
-		initialMessages := []Message{params.Message}
-		task, err := taskExecutor.TaskStore.CreateTask(taskName, taskExecutor.SystemMessage, initialMessages, "")
-		if err != nil {
-			log.Printf("[TaskSend %v] Error creating task: %v", rpcReq.ID, err)
-			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32000, Message: "Internal Server Error: Failed to create task", Data: err.Error()})
+		task, rpcErr := taskExecutor.createTask(r.Context(), taskName, params)
+		if rpcErr != nil {
+			log.Printf("[TaskSend %v] Error creating task: %s", rpcReq.ID, rpcErr.Message)
+			sendJSONRPCResponse(w, rpcReq.ID, nil, rpcErr)
 			return
 		}
 
+		// Read what the response needs before the run starts updating the task
+		state, createdAt := task.State, task.CreatedAt
+
 		// Start task execution asynchronously using a background context
 		// so it's not cancelled when the initial HTTP request closes.
 		go taskExecutor.ExecuteTask(context.Background(), task) // Use background context and pass task
@@ -189,8 +238,8 @@ func TasksSendHandler(taskExecutor *TaskExecutor) http.HandlerFunc {
 		a2aResponse := A2ATaskResponse{
 			ID: task.ID,
 			Status: A2ATaskStatus{
-				State:     task.State,                                // Use the state from the created task
-				Timestamp: task.CreatedAt.UTC().Format(time.RFC3339), // Use creation time for initial status
+				State:     state,                                // Use the state from the created task
+				Timestamp: createdAt.UTC().Format(time.RFC3339), // Use creation time for initial status
 			},
 			SessionID: params.SessionID, // Pass through session ID if provided
 		}
@@ -199,12 +248,153 @@ func TasksSendHandler(taskExecutor *TaskExecutor) http.HandlerFunc {
 	}
 }
 
-// TasksStatusHandler handles GET /tasks/status requests to retrieve the status of a specific task.
-// NOTE: This handler seems intended for standard HTTP GET, not JSON-RPC.
-// If it needs to be JSON-RPC, it should follow the pattern of TasksSendHandler.
-// Assuming it remains HTTP GET for now.
+// createTask creates a task from the tasks/send parameters, applying the
+// requested mode, project, labels and options.
+func (te *TaskExecutor) createTask(ctx context.Context, taskName string, params SendTaskParams) (*Task, *JSONRPCError) {
+	// Pass the task name, current system prompt from the LLMClient, and the initial message
+	// CreateTask now expects []Message for initial messages
+	// For tasks created directly via API, parentTaskID is an empty string.
+	systemPrompt, modeName, projectName, rpcErr := te.resolveTaskPrompt(ctx, params.Mode, params.Project, params.Labels)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+
+	if !params.AllTools {
+		systemPrompt = te.pruneToolDefinitions(systemPrompt, params.Message)
+	}
+
+	if params.TimeoutSeconds < 0 {
+		return nil, &JSONRPCError{Code: -32602, Message: "Invalid Params: timeoutSeconds must not be negative"}
+	}
+	if params.MaxOutputTokens < 0 || params.MaxContinuations < 0 || params.MaxTokens < 0 {
+		return nil, &JSONRPCError{Code: -32602, Message: "Invalid Params: maxOutputTokens, maxContinuations and maxTokens must not be negative"}
+	}
+	if params.OutputContract != nil {
+		if err := params.OutputContract.Validate(); err != nil {
+			return nil, &JSONRPCError{Code: -32602, Message: fmt.Sprintf("Invalid Params: %v", err)}
+		}
+	}
+	params.Provider = strings.ToLower(strings.TrimSpace(params.Provider))
+	if params.Provider != "" {
+		// Create the client now, so a provider the agent cannot use fails the request
+		if _, err := te.clientFor(&Task{Provider: params.Provider, Model: params.Model}); err != nil {
+			return nil, &JSONRPCError{Code: -32602, Message: fmt.Sprintf("Invalid Params: %v", err)}
+		}
+	}
+	if rpcErr := te.providerBusy(params.Provider); rpcErr != nil {
+		return nil, rpcErr
+	}
+	timeoutSeconds := params.TimeoutSeconds
+	if mode, ok := te.Modes.Get(modeName); ok && timeoutSeconds == 0 {
+		timeoutSeconds = mode.TimeoutSeconds
+	}
+	if timeoutSeconds == 0 {
+		timeoutSeconds = te.DefaultTimeoutSeconds
+	}
+
+	task, err := te.TaskStore.CreateTask(taskName, systemPrompt, []Message{params.Message}, "")
+	if err != nil {
+		return nil, &JSONRPCError{Code: -32000, Message: "Internal Server Error: Failed to create task", Data: err.Error()}
+	}
+	if params.SubTaskPolicy != nil || modeName != "" || params.Debug || projectName != "" || len(params.Labels) > 0 || timeoutSeconds > 0 || len(params.ToolQuotas) > 0 || params.MaxOutputTokens > 0 || params.MaxContinuations > 0 || params.OutputArtifact || params.Provider != "" || params.Model != "" || params.OutputContract != nil || params.MaxTokens > 0 || params.Priority != 0 || params.SessionID != nil {
+		task, err = te.TaskStore.UpdateTask(task.ID, func(t *Task) error {
+			t.SubTaskPolicy = params.SubTaskPolicy
+			t.Mode = modeName
+			t.Debug = params.Debug
+			t.Project = projectName
+			t.Labels = params.Labels
+			t.TimeoutSeconds = timeoutSeconds
+			t.ToolQuotas = params.ToolQuotas
+			t.MaxOutputTokens = params.MaxOutputTokens
+			t.MaxContinuations = params.MaxContinuations
+			t.OutputArtifact = params.OutputArtifact
+			t.Provider = params.Provider
+			t.Model = params.Model
+			t.OutputContract = params.OutputContract
+			t.MaxTokens = params.MaxTokens
+			t.Priority = params.Priority
+			if params.SessionID != nil {
+				t.SessionID = *params.SessionID
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, &JSONRPCError{Code: -32000, Message: "Internal Server Error: Failed to store task options", Data: err.Error()}
+		}
+	}
+	return task, nil
+}
+
+// resolveTaskPrompt picks the mode and project of a new task and composes
+// its system prompt from them.
+func (te *TaskExecutor) resolveTaskPrompt(ctx context.Context, modeName, projectName string, labels []string) (string, string, string, *JSONRPCError) {
+	systemPrompt := te.SystemMessage
+	if modeName == "" || modeName == te.DefaultMode {
+		modeName = te.DefaultMode
+	} else {
+		mode, found := te.Modes.Get(modeName)
+		if !found {
+			return "", "", "", &JSONRPCError{Code: -32602, Message: fmt.Sprintf("Invalid Params: unknown mode '%s'", modeName)}
+		}
+		systemPrompt, modeName = mode.SystemPrompt(te.AvailableTools), mode.Name
+	}
+	projectName, err := te.Projects.Resolve(ctx, projectName)
+	if err != nil {
+		return "", "", "", projectError(err)
+	}
+	if project, ok := te.Projects.Get(projectName); ok {
+		if err := project.CheckLabels(labels); err != nil {
+			return "", "", "", &JSONRPCError{Code: -32602, Message: fmt.Sprintf("Invalid Params: %v", err)}
+		}
+		systemPrompt = project.SystemPrompt(systemPrompt)
+	}
+	return systemPrompt, modeName, projectName, nil
+}
+
+// pruneToolDefinitions keeps only the ToolPruneTopK tool definitions of a
+// system prompt that are most relevant to the request.
+func (te *TaskExecutor) pruneToolDefinitions(systemPrompt string, request Message) string {
+	if te.ToolPruneTopK <= 0 {
+		return systemPrompt
+	}
+	pruned := tools.PruneToolDefinitions(systemPrompt, messageText(request), te.ToolPruneTopK)
+	if len(pruned.Omitted) > 0 {
+		log.Printf("[PruneTools] Kept the definitions of %v, left out %v (%d of %d prompt bytes).", pruned.Kept, pruned.Omitted, len(pruned.Prompt), len(systemPrompt))
+	}
+	return pruned.Prompt
+}
+
+// TasksStatusHandler handles GET /tasks/status?id= requests and the
+// "tasks/status" JSON-RPC method, returning a task with its remaining
+// budgets and timing. Other agents track delegated tasks with the latter.
 func TasksStatusHandler(taskStore TaskStore) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			var params TaskStatusParams
+			rpcReq, ok := decodeJSONRPCRequest(w, r, &params)
+			if !ok {
+				return
+			}
+			if params.ID == "" {
+				sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: "Invalid Params: missing task ID"})
+				return
+			}
+			task, err := taskStore.GetTask(params.ID)
+			if err == nil && HiddenFromCaller(r.Context(), taskStore, params.ID) {
+				err = ErrTaskNotFound
+			}
+			if err != nil {
+				if errors.Is(err, ErrTaskNotFound) {
+					sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32001, Message: "Not Found: Task not found"})
+				} else {
+					log.Printf("[TaskStatus %s] Error retrieving task: %v", params.ID, err)
+					sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32000, Message: "Internal Server Error", Data: err.Error()})
+				}
+				return
+			}
+			sendJSONRPCResponse(w, rpcReq.ID, taskStatus(task, time.UTC), nil)
+			return
+		}
 		if r.Method != http.MethodGet {
 			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
 			return
@@ -217,6 +407,9 @@ func TasksStatusHandler(taskStore TaskStore) http.HandlerFunc {
 		}
 
 		task, err := taskStore.GetTask(taskID)
+		if err == nil && HiddenFromCaller(r.Context(), taskStore, taskID) {
+			err = ErrTaskNotFound
+		}
 		if err != nil {
 			if errors.Is(err, ErrTaskNotFound) {
 				http.Error(w, "Not Found: Task not found", http.StatusNotFound)
@@ -227,11 +420,28 @@ func TasksStatusHandler(taskStore TaskStore) http.HandlerFunc {
 			return
 		}
 
+		loc, err := loadTimeZone(r.URL.Query().Get("tz"))
+		if err != nil {
+			http.Error(w, "Bad Request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(task)
+		json.NewEncoder(w).Encode(taskStatus(task, loc))
 	}
 }
 
+// taskStatus is the tasks/status view of a task.
+func taskStatus(task *Task, loc *time.Location) interface{} {
+	return struct {
+		*Task
+		RemainingSeconds *float64    `json:"remaining_seconds,omitempty"`
+		RemainingTokens  *int        `json:"remaining_tokens,omitempty"`
+		TotalTokens      int         `json:"total_tokens"`
+		Timing           *TaskTiming `json:"timing"`
+	}{task, task.RemainingSeconds(), task.RemainingTokens(), task.TotalTokens(), task.Timing(time.Now(), loc)}
+}
+
 // TasksInputHandler handles the "tasks/input" JSON-RPC method.
 func TasksInputHandler(taskExecutor *TaskExecutor) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -265,6 +475,9 @@ func TasksInputHandler(taskExecutor *TaskExecutor) http.HandlerFunc {
 
 		// 3. Business Logic
 		task, err := taskExecutor.TaskStore.GetTask(params.TaskID)
+		if err == nil && HiddenFromCaller(r.Context(), taskExecutor.TaskStore, params.TaskID) {
+			err = ErrTaskNotFound
+		}
 		if err != nil {
 			errCode := -32000 // Internal server error default
 			errMsg := "Internal Server Error"
@@ -280,16 +493,36 @@ func TasksInputHandler(taskExecutor *TaskExecutor) http.HandlerFunc {
 
 		if task.State != TaskStateInputRequired {
 			log.Printf("[TaskInput %v] Task %s is not in input-required state (current: %s)", rpcReq.ID, params.TaskID, task.State)
-			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32002, Message: "Conflict: Task is not waiting for input"})
+			var data interface{}
+			if task.ErrorDetail != nil {
+				data = task.ErrorDetail // Tells a client why a failed task stopped
+			}
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32002, Message: "Conflict: Task is not waiting for input", Data: data})
 			return
 		}
 
+		// Validate a structured answer against the fields of the pending question
+		input := params.Input
+		if form := task.InputRequest; form != nil && len(form.Fields) > 0 {
+			if problems := form.ValidateAnswers(params.Answers); len(problems) > 0 {
+				log.Printf("[TaskInput %v] Invalid answers for task %s: %v", rpcReq.ID, params.TaskID, problems)
+				sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: fmt.Sprintf("Invalid Params: answers do not match the question (fields: %s)", strings.Join(tools.SortedProblemFields(problems), ", ")), Data: problems})
+				return
+			}
+			input = inputAnswerMessage(form, input, params.Answers)
+		}
+
 		// Update task with new input
+		var updatedTask *Task
 		_, updateErr := taskExecutor.TaskStore.UpdateTask(params.TaskID, func(task *Task) error {
 			// Append the new message to the Messages array
-			task.Messages = append(task.Messages, params.Input) // Use Messages field
-			task.Error = "" // Clear previous error if any
-			return nil
+			task.Messages = append(task.Messages, input) // Use Messages field
+			setTaskError(task, nil) // Clear previous error if any
+			task.InputRequest = nil
+			// Copied while the store holds the task, the resumed run updates it concurrently
+			var err error
+			updatedTask, err = cloneTask(task)
+			return err
 		})
 		if updateErr != nil {
 			log.Printf("[TaskInput %v] Failed to update task %s with new input: %v", rpcReq.ID, params.TaskID, updateErr)
@@ -305,22 +538,21 @@ func TasksInputHandler(taskExecutor *TaskExecutor) http.HandlerFunc {
 			return
 		}
 
-		log.Printf("[TaskInput %v] Input received for task %s and task signaled to resume.", rpcReq.ID, params.TaskID)
+		log.Printf("[TaskInput %v] Input received for task %s and task relaunched.", rpcReq.ID, params.TaskID)
 
 		// 4. Send successful JSON-RPC Response
 		// A2A spec for tasks/input returns the updated Task object
-		updatedTask, _ := taskExecutor.TaskStore.GetTask(params.TaskID) // Fetch again to get latest state
-		sendJSONRPCResponse(w, rpcReq.ID, updatedTask, nil)             // Return updated task
+		updatedTask.State = TaskStateWorking
+		sendJSONRPCResponse(w, rpcReq.ID, updatedTask, nil) // Return updated task
 	}
 }
 
-// TaskDeleteParams defines the structure for parameters of "tasks/delete".
-type TaskDeleteParams struct {
-	ID string `json:"id"`
-}
-
-// TasksDeleteHandler handles the "tasks/delete" JSON-RPC method.
-func TasksDeleteHandler(taskStore TaskStore) http.HandlerFunc {
+// TasksDeleteHandler handles the "tasks/delete" JSON-RPC method. Plain deletes
+// return true; with cascade options or dryRun the result is a TaskDeleteReport.
+// While the executor keeps a trash, tasks are moved there unless the request
+// is permanent or the task already is in the trash.
+func TasksDeleteHandler(te *TaskExecutor) http.HandlerFunc {
+	taskStore, workspaceRoot := te.TaskStore, te.TaskWorkspaceRoot
 	return func(w http.ResponseWriter, r *http.Request) {
 		// 1. Decode the generic JSON-RPC Request
 		body, err := io.ReadAll(r.Body)
@@ -351,6 +583,41 @@ func TasksDeleteHandler(taskStore TaskStore) http.HandlerFunc {
 		log.Printf("[TaskDelete %v] Received request for task %s.", rpcReq.ID, params.ID)
 
 		// 3. Business Logic
+		if err := params.validate(); err != nil {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: fmt.Sprintf("Invalid Params: %v", err)})
+			return
+		}
+		if HiddenFromCaller(r.Context(), taskStore, params.ID) {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32001, Message: "Not Found: Task not found"})
+			return
+		}
+		trash := te.TrashRetention > 0 && !params.Permanent
+		if task, err := taskStore.GetTask(params.ID); err == nil && task.Trash != nil {
+			trash = false // Deleting from the trash is permanent
+		}
+		if trash || params.cascades() {
+			remove := DeleteTaskCascade
+			if trash {
+				remove = TrashTaskCascade
+			}
+			report, err := remove(taskStore, workspaceRoot, params)
+			if errors.Is(err, ErrTaskNotFound) {
+				// Like plain deletes, a missing task counts as deleted
+				report, err = &TaskDeleteReport{DryRun: params.DryRun, Deleted: []string{}}, nil
+			}
+			if err != nil {
+				log.Printf("[TaskDelete %v] Error deleting task %s: %v", rpcReq.ID, params.ID, err)
+				sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32000, Message: "Internal Server Error: Failed to delete task", Data: err.Error()})
+				return
+			}
+			log.Printf("[TaskDelete %v] Deleted %d tasks (trash: %t, dry run: %t).", rpcReq.ID, len(report.Deleted), trash, params.DryRun)
+			if !params.cascades() {
+				sendJSONRPCResponse(w, rpcReq.ID, true, nil)
+				return
+			}
+			sendJSONRPCResponse(w, rpcReq.ID, report, nil)
+			return
+		}
 		err = taskStore.DeleteTask(params.ID)
 		if err != nil {
 			if errors.Is(err, ErrTaskNotFound) {
@@ -372,7 +639,7 @@ func TasksDeleteHandler(taskStore TaskStore) http.HandlerFunc {
 }
 
 // TasksListHandler handles the "tasks/list" JSON-RPC method.
-func TasksListHandler(taskStore TaskStore) http.HandlerFunc {
+func TasksListHandler(taskStore TaskStore, projects *ProjectRegistry) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		// 1. Decode the generic JSON-RPC Request
 		body, err := io.ReadAll(r.Body)
@@ -393,28 +660,42 @@ func TasksListHandler(taskStore TaskStore) http.HandlerFunc {
 			return
 		}
 
-		// 2. No parameters expected for tasks/list, proceed to business logic
+		// 2. Parameters are optional: an empty filter lists all tasks
+		var filter TaskListFilter
+		if len(rpcReq.Params) > 0 && string(rpcReq.Params) != "null" {
+			if err := json.Unmarshal(rpcReq.Params, &filter); err != nil {
+				sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: fmt.Sprintf("Invalid Params: %v", err)})
+				return
+			}
+		}
+		project, err := projects.Resolve(r.Context(), filter.Project)
+		if err != nil {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, projectError(err))
+			return
+		}
+		filter.Project = project
+		if filter.View != "" && filter.View != TaskListViewSummary && filter.View != TaskListViewFull {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: fmt.Sprintf("Invalid Params: view must be '%s' or '%s'", TaskListViewSummary, TaskListViewFull)})
+			return
+		}
+		if _, err := loadTimeZone(filter.TimeZone); err != nil {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: "Invalid Params: " + err.Error()})
+			return
+		}
+		if filter.Limit < 0 {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: "Invalid Params: limit must not be negative"})
+			return
+		}
 		log.Printf("[TaskList %v] Received request.", rpcReq.ID) // Log entry
 
-		// 3. Business Logic
+		// 3. Stream the matching tasks into the response
 		logPrefix := fmt.Sprintf("[TaskList %v]", rpcReq.ID) // Use consistent prefix
-		log.Printf("%s Calling taskStore.ListTasks()...", logPrefix)
-		tasks, err := taskStore.ListTasks()
+		w.Header().Set("Content-Type", "application/json")
+		count, err := writeTaskList(r.Context(), w, rpcReq.ID, taskStore, filter)
 		if err != nil {
-			log.Printf("%s Error retrieving tasks from store: %v", logPrefix, err) // Log error from store
-			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32000, Message: "Internal Server Error: Failed to retrieve tasks", Data: err.Error()})
+			log.Printf("%s Error listing tasks: %v", logPrefix, err)
 			return
 		}
-		log.Printf("%s taskStore.ListTasks() returned %d tasks.", logPrefix, len(tasks)) // Log count after successful retrieval
-
-		if tasks == nil {
-			log.Printf("%s Task list was nil, ensuring empty array.", logPrefix)
-			tasks = []*Task{} // Ensure empty array, not null
-		}
-
-		// 4. Send successful JSON-RPC Response
-		log.Printf("%s Sending response with %d tasks.", logPrefix, len(tasks))
-		sendJSONRPCResponse(w, rpcReq.ID, tasks, nil)
-		log.Printf("%s Response sent.", logPrefix) // Log after sending
+		log.Printf("%s Sent %d tasks.", logPrefix, count)
 	}
 }
@@ -0,0 +1,115 @@
+package a2a
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"ka/tools"
+)
+
+const defaultToolExecuteTimeout = 60 * time.Second
+
+// ToolExecuteParams defines the parameters for the "tools/execute" method.
+// Arguments may be a JSON object (passed to the tool as its JSON content) or
+// a plain string (passed as-is, for tools that take raw content).
+type ToolExecuteParams struct {
+	Name           string            `json:"name"`
+	Arguments      json.RawMessage   `json:"arguments,omitempty"`
+	Attributes     map[string]string `json:"attributes,omitempty"`
+	TimeoutSeconds int               `json:"timeoutSeconds,omitempty"`
+}
+
+// ToolExecuteResult is returned by "tools/execute". Tool failures are reported
+// in Error rather than as a JSON-RPC error, mirroring tool result messages.
+type ToolExecuteResult struct {
+	ToolName   string `json:"tool_name"`
+	Result     string `json:"result"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// TasksToolsExecuteHandler handles the "tools/execute" JSON-RPC method, which
+// runs a single tool outside of any task.
+func TasksToolsExecuteHandler(taskExecutor *TaskExecutor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params ToolExecuteParams
+		rpcReq, ok := decodeJSONRPCRequest(w, r, &params)
+		if !ok {
+			return
+		}
+		if params.Name == "" {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: "Invalid Params: missing tool name"})
+			return
+		}
+
+		tool, exists := taskExecutor.AvailableTools[params.Name]
+		if !exists {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: "Invalid Params: unknown tool", Data: params.Name})
+			return
+		}
+		err := taskExecutor.ToolPolicy.CheckDirectCaller(r.Context())
+		if err == nil {
+			err = taskExecutor.ToolPolicy.CheckDirectExecution(params.Name)
+		}
+		if err == nil {
+			err = taskExecutor.ReadOnly.CheckTool(params.Name)
+		}
+		if err != nil {
+			log.Printf("[ToolsExecute %v] Rejected by policy: %v", rpcReq.ID, err)
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32003, Message: "Forbidden: tool not allowed", Data: err.Error()})
+			return
+		}
+
+		timeout := defaultToolExecuteTimeout
+		if params.TimeoutSeconds > 0 {
+			timeout = time.Duration(params.TimeoutSeconds) * time.Second
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		if taskExecutor.ReadOnly.Enabled() {
+			ctx = tools.WithReadOnly(ctx)
+		}
+
+		callDetails := tools.FunctionCall{
+			Name:       params.Name,
+			Attributes: make(map[string]string),
+			Content:    toolArgumentsContent(params.Arguments),
+		}
+		for key, value := range params.Attributes {
+			// Internal attributes are injected by the dispatcher and must not be spoofed.
+			if !strings.HasPrefix(key, "__") {
+				callDetails.Attributes[key] = value
+			}
+		}
+
+		log.Printf("[ToolsExecute %v] Executing tool %s directly.", rpcReq.ID, params.Name)
+		start := time.Now()
+		output, toolErr := tool.Execute(ctx, callDetails)
+		result := ToolExecuteResult{
+			ToolName:   params.Name,
+			Result:     output,
+			DurationMs: time.Since(start).Milliseconds(),
+		}
+		if toolErr != nil {
+			result.Result = ""
+			result.Error = toolErr.Error()
+		}
+		sendJSONRPCResponse(w, rpcReq.ID, result, nil)
+	}
+}
+
+// toolArgumentsContent converts the raw arguments into the tool content string.
+func toolArgumentsContent(raw json.RawMessage) string {
+	if len(raw) == 0 {
+		return "{}"
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s
+	}
+	return string(raw)
+}
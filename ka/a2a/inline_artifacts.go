@@ -0,0 +1,48 @@
+package a2a
+
+import (
+	"encoding/base64"
+	"net/url"
+	"sort"
+)
+
+// ArtifactRefPath is the HTTP path serving artifact data, see TasksArtifactHandler.
+const ArtifactRefPath = "/artifact"
+
+// CompletionArtifact describes an artifact in the final SSE event of a task.
+// Small artifacts carry their data; larger ones are referenced by URL.
+type CompletionArtifact struct {
+	ID       string       `json:"id"`
+	Filename string       `json:"filename,omitempty"`
+	MimeType string       `json:"mimeType"`
+	Size     int          `json:"size"`
+	Data     string       `json:"data,omitempty"` // Base64-encoded content of inlined artifacts
+	URL      string       `json:"url,omitempty"`  // Where to fetch artifacts that were not inlined
+	Hints    *RenderHints `json:"hints,omitempty"`
+}
+
+// completionArtifacts lists the task's artifacts, inlining those of at most
+// maxInlineBytes bytes.
+func completionArtifacts(task *Task, maxInlineBytes int) []CompletionArtifact {
+	ids := make([]string, 0, len(task.Artifacts))
+	for id := range task.Artifacts {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	result := make([]CompletionArtifact, 0, len(ids))
+	for _, id := range ids {
+		artifact := task.Artifacts[id]
+		entry := CompletionArtifact{ID: id, Filename: artifact.Filename, MimeType: artifact.Type, Size: artifact.Len(), Hints: artifact.Hints}
+		if entry.MimeType == "" {
+			entry.MimeType = "application/octet-stream"
+		}
+		if !artifact.Streamed && len(artifact.Data) <= maxInlineBytes {
+			entry.Data = base64.StdEncoding.EncodeToString(artifact.Data)
+		} else {
+			entry.URL = ArtifactRefPath + "?" + url.Values{"id": {task.ID}, "artifact_id": {id}}.Encode()
+		}
+		result = append(result, entry)
+	}
+	return result
+}
@@ -0,0 +1,27 @@
+package a2a
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestCompletionArtifacts_InlinesSmallAndLinksLarge(t *testing.T) {
+	task := &Task{ID: "task-1", Artifacts: map[string]*Artifact{
+		"a": {ID: "a", Type: "text/plain", Filename: "small.txt", Data: []byte("hi")},
+		"b": {ID: "b", Filename: "big.bin", Data: make([]byte, 100)},
+	}}
+
+	got := completionArtifacts(task, 10)
+	if len(got) != 2 {
+		t.Fatalf("Expected 2 artifacts, got %d", len(got))
+	}
+	if got[0].ID != "a" || got[0].URL != "" || got[0].MimeType != "text/plain" {
+		t.Errorf("Unexpected small artifact %+v", got[0])
+	}
+	if data, _ := base64.StdEncoding.DecodeString(got[0].Data); string(data) != "hi" {
+		t.Errorf("Expected inlined data 'hi', got %q", data)
+	}
+	if got[1].Data != "" || got[1].URL != "/artifact?artifact_id=b&id=task-1" || got[1].MimeType != "application/octet-stream" || got[1].Size != 100 {
+		t.Errorf("Unexpected large artifact %+v", got[1])
+	}
+}
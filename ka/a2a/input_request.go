@@ -0,0 +1,49 @@
+package a2a
+
+import (
+	"log"
+
+	"ka/tools"
+)
+
+// recordInputRequest stores the question asked by an ask_followup_question call
+// on the task, so tasks/input can validate the answer even after a restart.
+// It returns the stored form, or nil if the arguments could not be parsed.
+func recordInputRequest(store TaskStore, taskID string, toolCalls []ToolCall) *tools.InputForm {
+	var form *tools.InputForm
+	for _, toolCall := range toolCalls {
+		if toolCall.Function.Name != "ask_followup_question" {
+			continue
+		}
+		parsed, err := tools.ParseInputForm(toolCall.Function.Content)
+		if err != nil {
+			log.Printf("[Task %s] Could not parse ask_followup_question arguments: %v", taskID, err)
+			return nil
+		}
+		form = parsed
+		break
+	}
+	if form == nil {
+		return nil
+	}
+	if _, err := store.UpdateTask(taskID, func(task *Task) error {
+		task.InputRequest = form
+		return nil
+	}); err != nil {
+		log.Printf("[Task %s] Failed to store input request: %v", taskID, err)
+	}
+	return form
+}
+
+// inputAnswerMessage builds the user message for a structured answer: a text
+// summary the LLM can read plus the raw answers as a JSON data part.
+func inputAnswerMessage(form *tools.InputForm, input Message, answers map[string]interface{}) Message {
+	if input.Role == "" {
+		input.Role = RoleUser
+	}
+	input.Parts = append(input.Parts,
+		TextPart{Type: "text", Text: form.FormatAnswers(answers)},
+		DataPart{Type: "data", MimeType: "application/json", Data: answers},
+	)
+	return input
+}
@@ -0,0 +1,60 @@
+package a2a
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ka/tools"
+)
+
+func callTasksInput(t *testing.T, te *TaskExecutor, params string) JSONRPCResponse {
+	t.Helper()
+	body := `{"jsonrpc": "2.0", "id": 1, "method": "tasks/input", "params": ` + params + `}`
+	rec := httptest.NewRecorder()
+	TasksInputHandler(te)(rec, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+	var resp JSONRPCResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON-RPC response %q: %v", rec.Body.String(), err)
+	}
+	return resp
+}
+
+func TestTasksInputValidatesStructuredAnswers(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	te := NewTaskExecutor(&staticLLMClient{reply: "Thanks."}, store, nil, "")
+
+	task, _ := store.CreateTask("form", "", nil, "")
+	form := recordInputRequest(store, task.ID, []ToolCall{{Function: tools.FunctionCall{
+		Name:    "ask_followup_question",
+		Content: `{"question": "Where?", "fields": [{"name": "env", "type": "select", "options": ["dev", "prod"], "required": true}]}`,
+	}}})
+	if form == nil {
+		t.Fatalf("expected the input request to be recorded")
+	}
+	store.SetState(task.ID, TaskStateInputRequired)
+
+	resp := callTasksInput(t, te, `{"id": "`+task.ID+`", "message": {"role": "user", "parts": []}, "answers": {"env": "staging"}}`)
+	if resp.Error == nil || resp.Error.Code != -32602 {
+		t.Fatalf("expected an invalid params error, got %+v", resp)
+	}
+
+	resp = callTasksInput(t, te, `{"id": "`+task.ID+`", "message": {"role": "user", "parts": []}, "answers": {"env": "prod"}}`)
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %+v", resp.Error)
+	}
+	waitForState(t, store, task.ID, TaskStateCompleted)
+
+	updated, _ := store.GetTask(task.ID)
+	if updated.InputRequest != nil {
+		t.Errorf("expected the input request to be cleared after answering")
+	}
+	answer := updated.Messages[0]
+	if len(answer.Parts) != 2 {
+		t.Fatalf("expected a text and a data part, got %+v", answer.Parts)
+	}
+	if text, ok := answer.Parts[0].(TextPart); !ok || text.Text != "env: prod\n" {
+		t.Errorf("unexpected answer summary: %+v", answer.Parts[0])
+	}
+}
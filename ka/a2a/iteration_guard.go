@@ -0,0 +1,59 @@
+package a2a
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"ka/llm"
+)
+
+// iterationKey identifies an iteration of a task by the store it is kept
+// in, the number of assistant turns before it and the hash of its prompt.
+// Two executions with the same key would send the same LLM call and
+// dispatch the same tool calls.
+type iterationKey struct {
+	store     TaskStore
+	taskID    string
+	iteration int
+	hash      string
+}
+
+// inflightIterations holds the iterations being executed in this process,
+// across all executors, with the time they started.
+var (
+	inflightMu         sync.Mutex
+	inflightIterations = make(map[iterationKey]time.Time)
+)
+
+// guardIteration marks an iteration of a task as in flight until release is
+// called. It reports a duplicate when another execution, e.g. one started
+// twice by a duplicate send or by a second executor sharing the store, is
+// already executing the identical iteration; the caller then stops its
+// execution and leaves the task to the other one.
+func (te *TaskExecutor) guardIteration(task *Task, messages []llm.Message) (release func(), duplicate bool) {
+	iteration := 0
+	for _, message := range task.Messages {
+		if message.Role == RoleAssistant {
+			iteration++
+		}
+	}
+	store := te.TaskStore
+	if observed, ok := store.(*observedTaskStore); ok {
+		store = observed.TaskStore // Each executor observes the store on its own
+	}
+	key := iterationKey{store: store, taskID: task.ID, iteration: iteration, hash: hashMessages(messages)}
+
+	inflightMu.Lock()
+	defer inflightMu.Unlock()
+	if started, ok := inflightIterations[key]; ok {
+		log.Printf("[Task %s] Duplicate execution of iteration %d (prompt %s) detected; another execution started it %s ago. Cancelling this execution.", task.ID, iteration, key.hash[:12], time.Since(started).Round(time.Millisecond))
+		return func() {}, true
+	}
+	inflightIterations[key] = time.Now()
+	return func() {
+		inflightMu.Lock()
+		defer inflightMu.Unlock()
+		delete(inflightIterations, key)
+	}, false
+}
@@ -0,0 +1,61 @@
+package a2a
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ka/llm"
+)
+
+// heldLLMClient answers once release is closed, counting its calls.
+type heldLLMClient struct {
+	release chan struct{}
+	calls   atomic.Int32
+}
+
+func (c *heldLLMClient) Chat(ctx context.Context, messages []llm.Message, stream bool, out io.Writer) (string, int, int, error) {
+	c.calls.Add(1)
+	<-c.release
+	io.WriteString(out, "Done.")
+	return "Done.", 0, 0, nil
+}
+
+func TestDuplicateIterationIsCancelled(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	client := &heldLLMClient{release: make(chan struct{})}
+	// Two executors sharing the store run the same task, as after a lease bug
+	first := NewTaskExecutor(client, store, nil, "")
+	second := NewTaskExecutor(client, store, nil, "")
+	task, _ := store.CreateTask("twice", "", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "go"}}}}, "")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		first.ExecuteTask(context.Background(), task)
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for client.calls.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the first execution to call the LLM")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	second.ExecuteTask(context.Background(), task) // Returns without calling the LLM
+	if calls := client.calls.Load(); calls != 1 {
+		t.Fatalf("expected the duplicate iteration not to call the LLM, got %d calls", calls)
+	}
+	close(client.release)
+	<-done
+	waitForState(t, store, task.ID, TaskStateCompleted)
+
+	// The next iteration has another key and runs
+	release, duplicate := second.guardIteration(task, []llm.Message{{Role: "user", Content: "go"}})
+	defer release()
+	if duplicate {
+		t.Errorf("expected a finished iteration to be released")
+	}
+}
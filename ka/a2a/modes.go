@@ -0,0 +1,214 @@
+package a2a
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"ka/llm"
+	"ka/tools"
+)
+
+// Mode is a preset bundling the system prompt, tool selection, tool guardrails
+// and model parameters for a kind of work.
+type Mode struct {
+	Name           string                `json:"name"`
+	Description    string                `json:"description,omitempty"`
+	Instructions   string                `json:"instructions,omitempty"` // Added to the composed system prompt
+	Tools          []string              `json:"tools,omitempty"`        // Tools offered to the model; empty offers all
+	DeniedTools    []string              `json:"deniedTools,omitempty"`  // Never executed in this mode
+	Generation     llm.GenerationOptions `json:"generation,omitempty"`
+	TimeoutSeconds int                   `json:"timeoutSeconds,omitempty"` // Default execution timeout of tasks in this mode
+	BuiltIn        bool                  `json:"builtIn,omitempty"`
+}
+
+func floatPtr(v float64) *float64 { return &v }
+
+// builtInModes are always available and cannot be redefined.
+var builtInModes = []Mode{
+	{
+		Name:         "coder",
+		Description:  "Writes, changes and tests code in the workspace.",
+		Instructions: "You are working as a coder. Read the relevant code before changing it, keep changes small and focused, follow the conventions of the surrounding code and run the tests after changing code.",
+		Generation:   llm.GenerationOptions{Temperature: floatPtr(0.2)},
+	},
+	{
+		Name:         "researcher",
+		Description:  "Investigates and answers questions without changing anything.",
+		Instructions: "You are working as a researcher. Gather facts with read-only tools, cite the files and sources you used, and answer with a concise summary. Do not modify files or run commands.",
+		Tools:        []string{"list_files", "read_file", "read_log", "search_files", "find_symbol", "fetch_url", "get_current_time", "ask_followup_question", "mcp"},
+		DeniedTools:  []string{"write_to_file", "apply_diff", "execute_command"},
+		Generation:   llm.GenerationOptions{Temperature: floatPtr(0.5)},
+	},
+	{
+		Name:         "orchestrator",
+		Description:  "Breaks work into independent sub-tasks and coordinates them.",
+		Instructions: "You are working as an orchestrator. Split the task into independent sub-tasks with add_task, giving each one complete context, and do not do the work yourself.",
+		Tools:        []string{"add_task", "ask_followup_question", "list_files", "read_file", "get_current_time"},
+		DeniedTools:  []string{"write_to_file", "apply_diff", "execute_command"},
+		Generation:   llm.GenerationOptions{Temperature: floatPtr(0.3)},
+	},
+}
+
+// ModeRegistry holds the built-in and custom modes.
+type ModeRegistry struct {
+	mu    sync.RWMutex
+	modes map[string]Mode
+}
+
+// NewModeRegistry creates a registry with the built-in modes.
+func NewModeRegistry() *ModeRegistry {
+	r := &ModeRegistry{modes: make(map[string]Mode)}
+	for _, mode := range builtInModes {
+		mode.BuiltIn = true
+		r.modes[mode.Name] = mode
+	}
+	return r
+}
+
+// Get returns the mode with the given name. It is safe to call on a nil registry.
+func (r *ModeRegistry) Get(name string) (Mode, bool) {
+	if r == nil {
+		return Mode{}, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	mode, ok := r.modes[name]
+	return mode, ok
+}
+
+// List returns all modes sorted by name.
+func (r *ModeRegistry) List() []Mode {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	modes := make([]Mode, 0, len(r.modes))
+	for _, mode := range r.modes {
+		modes = append(modes, mode)
+	}
+	sort.Slice(modes, func(i, j int) bool { return modes[i].Name < modes[j].Name })
+	return modes
+}
+
+// Define adds or replaces a custom mode. Built-in modes cannot be replaced.
+func (r *ModeRegistry) Define(mode Mode) error {
+	mode.Name = strings.TrimSpace(mode.Name)
+	if mode.Name == "" {
+		return fmt.Errorf("mode name is required")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if existing, ok := r.modes[mode.Name]; ok && existing.BuiltIn {
+		return fmt.Errorf("mode '%s' is built in and cannot be redefined", mode.Name)
+	}
+	mode.BuiltIn = false
+	r.modes[mode.Name] = mode
+	return nil
+}
+
+// AllowsTool reports whether a tool may run in this mode.
+func (m Mode) AllowsTool(name string) bool {
+	for _, denied := range m.DeniedTools {
+		if denied == name {
+			return false
+		}
+	}
+	if len(m.Tools) == 0 {
+		return true
+	}
+	for _, allowed := range m.Tools {
+		if allowed == name {
+			return true
+		}
+	}
+	return false
+}
+
+// SystemPrompt composes the system prompt for the mode from the available tools.
+func (m Mode) SystemPrompt(availableTools map[string]tools.Tool) string {
+	var toolNames []string
+	for name := range availableTools {
+		if m.AllowsTool(name) {
+			toolNames = append(toolNames, name)
+		}
+	}
+	sort.Strings(toolNames)
+	prompt := tools.ComposeSystemPrompt(toolNames, []tools.McpServerConfig{}, availableTools)
+	if m.Instructions == "" {
+		return prompt
+	}
+	return prompt + "\n\nMODE: " + strings.ToUpper(m.Name) + "\n====\n" + m.Instructions
+}
+
+// ModesDefineParams defines the parameters of "modes/define".
+type ModesDefineParams struct {
+	Mode Mode `json:"mode"`
+}
+
+// ModesListHandler handles "modes/list".
+func ModesListHandler(registry *ModeRegistry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rpcReq, ok := decodeJSONRPCRequest(w, r, nil)
+		if !ok {
+			return
+		}
+		sendJSONRPCResponse(w, rpcReq.ID, map[string]interface{}{"modes": registry.List()}, nil)
+	}
+}
+
+// ModesDefineHandler handles "modes/define", which adds or replaces a custom mode.
+func ModesDefineHandler(registry *ModeRegistry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params ModesDefineParams
+		rpcReq, ok := decodeJSONRPCRequest(w, r, &params)
+		if !ok {
+			return
+		}
+		if err := registry.Define(params.Mode); err != nil {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: fmt.Sprintf("Invalid Params: %v", err)})
+			return
+		}
+		mode, _ := registry.Get(strings.TrimSpace(params.Mode.Name))
+		sendJSONRPCResponse(w, rpcReq.ID, mode, nil)
+	}
+}
+
+// checkToolMode returns an error if the task's mode does not allow the tool.
+func checkToolMode(store TaskStore, modes *ModeRegistry, taskID, toolName string) error {
+	if modes == nil {
+		return nil
+	}
+	task, err := store.GetTask(taskID)
+	if err != nil || task.Mode == "" {
+		return nil
+	}
+	mode, ok := modes.Get(task.Mode)
+	if !ok || mode.AllowsTool(toolName) {
+		return nil
+	}
+	return fmt.Errorf("tool '%s' is not allowed in mode '%s'", toolName, mode.Name)
+}
+
+// modeContext applies the model parameters of the task's mode to ctx, with
+// the task's own model and token limits taking precedence.
+func (te *TaskExecutor) modeContext(ctx context.Context, task *Task) context.Context {
+	mode, ok := te.Modes.Get(task.Mode)
+	if !ok && task.Model == "" && task.MaxOutputTokens == 0 && task.MaxContinuations == 0 && te.MaxContinuations == 0 {
+		return ctx
+	}
+	opts := mode.Generation
+	if task.Model != "" {
+		opts.Model = task.Model
+	}
+	if task.MaxOutputTokens > 0 {
+		opts.MaxTokens = task.MaxOutputTokens
+	}
+	if task.MaxContinuations > 0 {
+		opts.MaxContinuations = task.MaxContinuations
+	} else if opts.MaxContinuations == 0 {
+		opts.MaxContinuations = te.MaxContinuations
+	}
+	return llm.WithGenerationOptions(ctx, opts)
+}
@@ -0,0 +1,54 @@
+package a2a
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"ka/tools"
+)
+
+func TestModeRegistry_BuiltInsCannotBeRedefined(t *testing.T) {
+	registry := NewModeRegistry()
+	if err := registry.Define(Mode{Name: "coder"}); err == nil {
+		t.Errorf("Expected redefining a built-in mode to fail")
+	}
+	if err := registry.Define(Mode{Name: " reviewer ", Tools: []string{"read_file"}}); err != nil {
+		t.Fatalf("Define: %v", err)
+	}
+	mode, ok := registry.Get("reviewer")
+	if !ok || mode.BuiltIn {
+		t.Errorf("Expected a custom mode named reviewer, got %+v", mode)
+	}
+	if len(registry.List()) != len(builtInModes)+1 {
+		t.Errorf("Expected %d modes, got %d", len(builtInModes)+1, len(registry.List()))
+	}
+}
+
+func TestMode_SystemPromptOffersOnlyModeTools(t *testing.T) {
+	researcher, _ := NewModeRegistry().Get("researcher")
+	available := map[string]tools.Tool{"read_file": &tools.ReadFileTool{}, "execute_command": &tools.ExecuteCommandTool{}}
+	prompt := researcher.SystemPrompt(available)
+	if !strings.Contains(prompt, `## Tool "read_file"`) || strings.Contains(prompt, `## Tool "execute_command"`) {
+		t.Errorf("Expected only read_file in the researcher prompt")
+	}
+	if !strings.Contains(prompt, "MODE: RESEARCHER") {
+		t.Errorf("Expected the mode instructions in the prompt")
+	}
+}
+
+func TestDispatchToolCall_DeniedByTaskMode(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	task, _ := store.CreateTask("t", "", nil, "")
+	store.UpdateTask(task.ID, func(t *Task) error {
+		t.Mode = "researcher"
+		return nil
+	})
+	dispatcher := NewToolDispatcher(store, map[string]tools.Tool{"execute_command": &tools.ExecuteCommandTool{}})
+	dispatcher.modes = NewModeRegistry()
+
+	_, err := dispatcher.DispatchToolCall(context.Background(), task.ID, ToolCall{ID: "1", Function: tools.FunctionCall{Name: "execute_command", Content: `{"command": "echo hi"}`}})
+	if err == nil || !strings.Contains(err.Error(), "not allowed in mode 'researcher'") {
+		t.Errorf("Expected the researcher mode to deny execute_command, got %v", err)
+	}
+}
@@ -0,0 +1,173 @@
+package a2a
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	// outputArtifactChunk is how much streamed output is buffered before it
+	// is appended to the artifact.
+	outputArtifactChunk = 64 * 1024
+	// outputPreviewBytes is the start of the output kept in the message.
+	outputPreviewBytes = 2000
+)
+
+// ArtifactAppender is implemented by task stores that can grow an artifact
+// in chunks and keep its data out of the task record. The first append
+// creates the artifact from meta; later ones only add data.
+type ArtifactAppender interface {
+	AppendArtifact(taskID string, meta Artifact, chunk []byte) error
+}
+
+// appendArtifact appends to an artifact, rewriting it in stores that cannot
+// append.
+func appendArtifact(store TaskStore, taskID string, meta Artifact, chunk []byte) error {
+	if appender, ok := store.(ArtifactAppender); ok {
+		return appender.AppendArtifact(taskID, meta, chunk)
+	}
+	data, existing, err := store.GetArtifactData(taskID, meta.ID)
+	if err == nil {
+		meta = *existing
+	}
+	meta.Data = append(append([]byte(nil), data...), chunk...)
+	meta.Hints = nil // Recomputed for the grown data
+	return store.AddArtifact(taskID, meta)
+}
+
+// Len returns the size of the artifact's data, also for streamed artifacts
+// whose data is not loaded with the task.
+func (a *Artifact) Len() int {
+	if a.Streamed {
+		return int(a.Size)
+	}
+	return len(a.Data)
+}
+
+// outputArtifactWriter streams the LLM output of one iteration into an
+// artifact of the task, keeping only a preview in memory.
+type outputArtifactWriter struct {
+	store  TaskStore
+	taskID string
+	meta   Artifact
+
+	mu      sync.Mutex
+	pending []byte
+	preview []byte
+	written int
+	err     error
+}
+
+func newOutputArtifactWriter(store TaskStore, taskID, artifactID string) *outputArtifactWriter {
+	return &outputArtifactWriter{
+		store:  store,
+		taskID: taskID,
+		meta:   Artifact{ID: artifactID, Type: "text/plain", Filename: artifactID + ".txt"},
+	}
+}
+
+func (w *outputArtifactWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if room := outputPreviewBytes - len(w.preview); room > 0 {
+		w.preview = append(w.preview, p[:min(room, len(p))]...)
+	}
+	w.written += len(p)
+	w.pending = append(w.pending, p...)
+	if len(w.pending) >= outputArtifactChunk {
+		w.flushLocked()
+	}
+	return len(p), nil // A failing sink must not break the LLM stream; see Close
+}
+
+func (w *outputArtifactWriter) flushLocked() {
+	if len(w.pending) == 0 || w.err != nil {
+		return
+	}
+	if err := appendArtifact(w.store, w.taskID, w.meta, w.pending); err != nil {
+		w.err = err
+		log.Printf("[Task %s] Failed to append output to artifact %s: %v", w.taskID, w.meta.ID, err)
+	}
+	w.pending = w.pending[:0]
+}
+
+// Close appends the remaining output. It is safe on a nil receiver.
+func (w *outputArtifactWriter) Close() error {
+	if w == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.flushLocked()
+	return w.err
+}
+
+// Reference is the message text standing in for the output: the preview
+// and a link to the artifact holding all of it.
+func (w *outputArtifactWriter) Reference() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	preview := w.preview
+	for len(preview) > 0 && !utf8.Valid(preview) {
+		preview = preview[:len(preview)-1] // Cut before a rune split by the preview limit
+	}
+	if w.written <= len(preview) {
+		return fmt.Sprintf("%s\n\n[Output](artifact://%s)", preview, w.meta.ID)
+	}
+	return fmt.Sprintf("%s…\n\n[Full output, %d bytes](artifact://%s)", preview, w.written, w.meta.ID)
+}
+
+type outputSinkKey struct{}
+
+// withOutputSink makes the LLM handlers copy the output into sink.
+func withOutputSink(ctx context.Context, sink *outputArtifactWriter) context.Context {
+	return context.WithValue(ctx, outputSinkKey{}, sink)
+}
+
+// outputSinkFrom returns the sink of withOutputSink, or nil.
+func outputSinkFrom(ctx context.Context) *outputArtifactWriter {
+	sink, _ := ctx.Value(outputSinkKey{}).(*outputArtifactWriter)
+	return sink
+}
+
+// outputArtifactSink starts streaming the output of the next LLM call of a
+// task into a new artifact, if the task asked for it. Call finish once the
+// iteration stored its messages.
+func (te *TaskExecutor) outputArtifactSink(ctx context.Context, task *Task) (context.Context, *outputArtifactWriter) {
+	if !task.OutputArtifact {
+		return ctx, nil
+	}
+	sink := newOutputArtifactWriter(te.TaskStore, task.ID, fmt.Sprintf("output-%d", len(task.Messages)+1))
+	return withOutputSink(ctx, sink), sink
+}
+
+// finish appends the rest of the output and replaces the text of the last
+// assistant message with the reference to the artifact.
+func (w *outputArtifactWriter) finish() {
+	if w == nil {
+		return
+	}
+	if err := w.Close(); err != nil {
+		return // Keep the full text in the message rather than pointing to a broken artifact
+	}
+	if w.written == 0 {
+		return
+	}
+	reference := w.Reference()
+	_, err := w.store.UpdateTask(w.taskID, func(task *Task) error {
+		for i := len(task.Messages) - 1; i >= 0; i-- {
+			if task.Messages[i].Role == RoleAssistant {
+				task.Messages[i].Parts = []Part{TextPart{Type: "text", Text: reference}}
+				task.Messages[i].RawToolCallsXML = "" // Parsed already; the artifact keeps the raw output
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("[Task %s] Failed to replace the output with a reference to artifact %s: %v", w.taskID, w.meta.ID, err)
+	}
+}
@@ -0,0 +1,79 @@
+package a2a
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestFileStoreAppendsStreamedArtifacts(t *testing.T) {
+	store, err := NewFileTaskStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileTaskStore: %v", err)
+	}
+	task, _ := store.CreateTask("long", "", nil, "")
+	meta := Artifact{ID: "output-1", Type: "text/plain", Filename: "output-1.txt"}
+	for _, chunk := range []string{"first chunk, ", "second chunk"} {
+		if err := store.AppendArtifact(task.ID, meta, []byte(chunk)); err != nil {
+			t.Fatalf("AppendArtifact: %v", err)
+		}
+	}
+
+	data, artifact, err := store.GetArtifactData(task.ID, "output-1")
+	if err != nil {
+		t.Fatalf("GetArtifactData: %v", err)
+	}
+	if string(data) != "first chunk, second chunk" || artifact.Len() != len(data) {
+		t.Errorf("unexpected artifact %q (%d bytes recorded)", data, artifact.Len())
+	}
+	record, _ := os.ReadFile(store.taskFilePath(task.ID))
+	if strings.Contains(string(record), "chunk") {
+		t.Errorf("expected the data to stay out of the task record, got %s", record)
+	}
+	if err := store.AppendArtifact(task.ID, Artifact{ID: "../escape"}, []byte("x")); err == nil {
+		t.Error("expected an artifact ID with a path to be rejected")
+	}
+
+	if err := store.DeleteTask(task.ID); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	if _, err := os.Stat(store.artifactDir(task.ID)); !os.IsNotExist(err) {
+		t.Errorf("expected the artifact directory to be removed with the task, got %v", err)
+	}
+}
+
+func TestOutputArtifactKeepsPreviewInMessage(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	reply := strings.Repeat("All work and no play. ", 500)
+	te := NewTaskExecutor(&staticLLMClient{reply: reply}, store, nil, "")
+	task, rpcErr := te.createTask(context.Background(), "long", SendTaskParams{
+		Message:        Message{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "write a lot"}}},
+		OutputArtifact: true,
+	})
+	if rpcErr != nil {
+		t.Fatalf("createTask: %v", rpcErr)
+	}
+
+	te.ExecuteTask(context.Background(), task)
+	waitForState(t, store, task.ID, TaskStateCompleted)
+
+	done, _ := store.GetTask(task.ID)
+	data, _, err := store.GetArtifactData(task.ID, "output-2")
+	if err != nil {
+		t.Fatalf("expected the output artifact: %v", err)
+	}
+	if string(data) != reply {
+		t.Errorf("expected the artifact to hold the full output, got %d bytes", len(data))
+	}
+	last := done.Messages[len(done.Messages)-1]
+	text := last.Parts[0].(TextPart).Text
+	if len(text) > outputPreviewBytes+200 || !strings.Contains(text, "(artifact://output-2)") || !strings.HasPrefix(text, "All work") {
+		t.Errorf("expected a preview with a reference, got %d bytes: %q", len(text), text[len(text)-80:])
+	}
+	for _, artifact := range done.Artifacts {
+		if artifact.Filename == "llm_response.txt" {
+			t.Error("expected no second copy of the response")
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package a2a
+
+import (
+	"fmt"
+	"log"
+	"mime"
+	"path"
+	"strings"
+	"time"
+)
+
+// ErrorCodeOutputContract is the ErrorDetail code of tasks that finished
+// without the artifacts their output contract requires.
+const ErrorCodeOutputContract = "output_contract_unmet"
+
+// DefaultContractCorrections is how often a task missing required artifacts
+// is sent back to work when its contract sets no budget.
+const DefaultContractCorrections = 2
+
+// OutputContract declares the artifacts a task must produce before it may
+// complete, so automation pipelines always receive the outputs they expect.
+// A task finishing without them is told what is missing and continues, until
+// its correction budget runs out and it fails.
+type OutputContract struct {
+	Artifacts      []ExpectedArtifact `json:"artifacts"`
+	MaxCorrections int                `json:"maxCorrections,omitempty"` // Corrective messages before the task fails; 0 uses DefaultContractCorrections
+}
+
+// ExpectedArtifact is one entry of an output contract. It is met by an
+// artifact matching both its name and type, where set.
+type ExpectedArtifact struct {
+	Name string `json:"name,omitempty"` // File name or glob, e.g. "report.md" or "*.csv"
+	Type string `json:"type,omitempty"` // MIME type, e.g. "application/json", or a wildcard such as "image/*"
+}
+
+func (e ExpectedArtifact) String() string {
+	switch {
+	case e.Name != "" && e.Type != "":
+		return fmt.Sprintf("%s (%s)", e.Name, e.Type)
+	case e.Name != "":
+		return e.Name
+	default:
+		return "an artifact of type " + e.Type
+	}
+}
+
+// Validate checks the entries of the contract.
+func (c *OutputContract) Validate() error {
+	if len(c.Artifacts) == 0 {
+		return fmt.Errorf("outputContract lists no artifacts")
+	}
+	if c.MaxCorrections < 0 {
+		return fmt.Errorf("outputContract maxCorrections must not be negative")
+	}
+	for i, expected := range c.Artifacts {
+		if expected.Name == "" && expected.Type == "" {
+			return fmt.Errorf("outputContract artifact %d has neither a name nor a type", i)
+		}
+		if _, err := path.Match(expected.Name, ""); err != nil {
+			return fmt.Errorf("outputContract artifact %d has an invalid name pattern %q", i, expected.Name)
+		}
+	}
+	return nil
+}
+
+// matches reports whether an artifact meets the entry.
+func (e ExpectedArtifact) matches(artifact *Artifact) bool {
+	if e.Name != "" {
+		if ok, _ := path.Match(e.Name, artifact.Filename); !ok {
+			return false
+		}
+	}
+	if e.Type == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(artifact.Type)
+	if err != nil {
+		mediaType = artifact.Type
+	}
+	if prefix, ok := strings.CutSuffix(e.Type, "/*"); ok {
+		return strings.HasPrefix(mediaType, prefix+"/")
+	}
+	return strings.EqualFold(mediaType, e.Type)
+}
+
+// missing returns the entries no artifact of the task meets.
+func (c *OutputContract) missing(task *Task) []ExpectedArtifact {
+	var missing []ExpectedArtifact
+	for _, expected := range c.Artifacts {
+		met := false
+		for _, artifact := range task.Artifacts {
+			if expected.matches(artifact) {
+				met = true
+				break
+			}
+		}
+		if !met {
+			missing = append(missing, expected)
+		}
+	}
+	return missing
+}
+
+// checkOutputContract is called when a task would complete. If artifacts of
+// its contract are missing, it adds a corrective message listing them and
+// sets the task back to WORKING, returning true so the loop continues. Once
+// the correction budget is used up it fails the task instead and returns
+// the error.
+func (te *TaskExecutor) checkOutputContract(taskID string) (corrected bool, failed *ErrorDetail) {
+	task, err := te.TaskStore.GetTask(taskID)
+	if err != nil || task.OutputContract == nil {
+		return false, nil
+	}
+	missing := task.OutputContract.missing(task)
+	if len(missing) == 0 {
+		return false, nil
+	}
+	names := make([]string, len(missing))
+	for i, expected := range missing {
+		names[i] = expected.String()
+	}
+	budget := task.OutputContract.MaxCorrections
+	if budget == 0 {
+		budget = DefaultContractCorrections
+	}
+
+	if task.ContractCorrections >= budget {
+		detail := &ErrorDetail{
+			Code:    ErrorCodeOutputContract,
+			Message: fmt.Sprintf("the task finished without the required artifacts %s after %d corrections", strings.Join(names, ", "), task.ContractCorrections),
+			Hint:    "Check that the task can produce these artifacts, e.g. that the save_artifact tool is available to it, or relax its outputContract.",
+		}
+		log.Printf("[Task %s] %s", taskID, detail.Message)
+		te.TaskStore.UpdateTask(taskID, func(t *Task) error {
+			setTaskError(t, detail)
+			t.State = TaskStateFailed
+			return nil
+		})
+		return false, detail
+	}
+
+	log.Printf("[Task %s] Output contract not met, missing %s. Sending the task back to work (correction %d of %d).", taskID, strings.Join(names, ", "), task.ContractCorrections+1, budget)
+	correction := Message{
+		Role:      RoleUser,
+		Parts:     []Part{TextPart{Type: "text", Text: fmt.Sprintf("The task is not complete: it must produce these artifacts, which are missing: %s. Save each of them with the save_artifact tool, then finish.", strings.Join(names, ", "))}},
+		Timestamp: time.Now().UTC(),
+	}
+	_, err = te.TaskStore.UpdateTask(taskID, func(t *Task) error {
+		t.Messages = append(t.Messages, correction)
+		t.ContractCorrections++
+		t.State = TaskStateWorking
+		return nil
+	})
+	if err != nil {
+		log.Printf("[Task %s] Failed to add the output contract correction: %v", taskID, err)
+		return false, nil
+	}
+	return true, nil
+}
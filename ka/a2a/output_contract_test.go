@@ -0,0 +1,86 @@
+package a2a
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"ka/tools"
+)
+
+func TestOutputContractSendsTaskBackUntilArtifactsExist(t *testing.T) {
+	store, err := NewFileTaskStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &scriptedLLMClient{replies: []string{"Here is the report.", `<tool id="save_artifact" name="report.md"># Report</tool>`, "Saved the report."}}
+	te := NewTaskExecutor(client, store, map[string]tools.Tool{"save_artifact": &tools.SaveArtifactTool{}}, "")
+
+	task, _ := store.CreateTask("report", "", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "write a report"}}}}, "")
+	task, _ = store.UpdateTask(task.ID, func(t *Task) error {
+		t.OutputContract = &OutputContract{Artifacts: []ExpectedArtifact{{Name: "*.md", Type: "text/markdown"}}}
+		return nil
+	})
+	te.ExecuteTask(context.Background(), task)
+
+	completed, _ := store.GetTask(task.ID)
+	if completed.State != TaskStateCompleted || completed.ContractCorrections != 1 {
+		t.Fatalf("expected the task to complete after one correction, got %s after %d", completed.State, completed.ContractCorrections)
+	}
+	corrected := false
+	for _, message := range completed.Messages {
+		if message.Role == RoleUser && strings.Contains(messageText(message), "missing: *.md (text/markdown)") {
+			corrected = true
+		}
+	}
+	if !corrected {
+		t.Errorf("expected a corrective message naming the missing artifact")
+	}
+	found := false
+	for _, artifact := range completed.Artifacts {
+		found = found || (artifact.Filename == "report.md" && strings.HasPrefix(artifact.Type, "text/markdown"))
+	}
+	if !found {
+		t.Errorf("expected the saved report among the artifacts, got %v", completed.Artifacts)
+	}
+}
+
+func TestOutputContractFailsAfterBudget(t *testing.T) {
+	store, err := NewFileTaskStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &scriptedLLMClient{replies: []string{"Done."}}
+	te := NewTaskExecutor(client, store, nil, "")
+
+	task, _ := store.CreateTask("report", "", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "export the data"}}}}, "")
+	task, _ = store.UpdateTask(task.ID, func(t *Task) error {
+		t.OutputContract = &OutputContract{Artifacts: []ExpectedArtifact{{Name: "data.csv"}}, MaxCorrections: 1}
+		return nil
+	})
+	te.ExecuteTask(context.Background(), task)
+
+	failed, _ := store.GetTask(task.ID)
+	if failed.State != TaskStateFailed || failed.ErrorDetail == nil || failed.ErrorDetail.Code != ErrorCodeOutputContract {
+		t.Fatalf("expected the task to fail with %s, got %s %+v", ErrorCodeOutputContract, failed.State, failed.ErrorDetail)
+	}
+	if calls := client.calls.Load(); calls != 2 {
+		t.Errorf("expected one retry before failing, got %d LLM calls", calls)
+	}
+}
+
+func TestOutputContractValidate(t *testing.T) {
+	for _, contract := range []OutputContract{
+		{},
+		{Artifacts: []ExpectedArtifact{{}}},
+		{Artifacts: []ExpectedArtifact{{Name: "[x"}}},
+		{Artifacts: []ExpectedArtifact{{Type: "image/*"}}, MaxCorrections: -1},
+	} {
+		if err := contract.Validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", contract)
+		}
+	}
+	if err := (&OutputContract{Artifacts: []ExpectedArtifact{{Type: "image/*"}}}).Validate(); err != nil {
+		t.Errorf("expected a type-only entry to be valid, got %v", err)
+	}
+}
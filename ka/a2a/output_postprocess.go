@@ -0,0 +1,264 @@
+package a2a
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// OutputProcessing configures the post-processing applied to the final
+// assistant output before it is stored as a message and artifact.
+type OutputProcessing struct {
+	SanitizeMarkdown bool // Normalize line endings and blank lines, drop control characters and raw script blocks, close open code fences
+	LinkArtifacts    bool // Rewrite markdown links to local files into links to the task's artifacts
+	StripToolXML     bool // Remove <tool> and <tool_code> remnants the model left in its answer
+	MaxLength        int  // Truncate longer output with a notice; 0 disables
+	ProvenanceFooter bool // Append a footer listing the tool calls behind the answer, with links to their audit artifacts
+}
+
+// outputProcessingSteps are the step names accepted by ParseOutputProcessing.
+var outputProcessingSteps = []string{"sanitize", "link-artifacts", "strip-tool-xml", "provenance"}
+
+// ParseOutputProcessing builds the configuration from step names and a
+// maximum length. It returns nil when nothing is enabled.
+func ParseOutputProcessing(steps []string, maxLength int) (*OutputProcessing, error) {
+	if maxLength < 0 {
+		return nil, fmt.Errorf("maximum output length must not be negative")
+	}
+	p := &OutputProcessing{MaxLength: maxLength}
+	for _, step := range steps {
+		switch step {
+		case "sanitize":
+			p.SanitizeMarkdown = true
+		case "link-artifacts":
+			p.LinkArtifacts = true
+		case "strip-tool-xml":
+			p.StripToolXML = true
+		case "provenance":
+			p.ProvenanceFooter = true
+		default:
+			return nil, fmt.Errorf("unknown output post-processing step '%s' (expected one of %s)", step, strings.Join(outputProcessingSteps, ", "))
+		}
+	}
+	if *p == (OutputProcessing{}) {
+		return nil, nil
+	}
+	return p, nil
+}
+
+// Process applies the enabled steps to text. artifacts are the task's
+// artifacts, used to resolve local file links. A nil receiver returns text unchanged.
+func (p *OutputProcessing) Process(text string, artifacts map[string]*Artifact) string {
+	if p == nil {
+		return text
+	}
+	if p.StripToolXML {
+		text = stripToolXML(text)
+	}
+	if p.SanitizeMarkdown {
+		text = sanitizeMarkdown(text)
+	}
+	if p.LinkArtifacts {
+		text = linkArtifacts(text, artifacts)
+	}
+	if p.MaxLength > 0 {
+		text = truncateOutput(text, p.MaxLength)
+	}
+	return text
+}
+
+var (
+	toolBlockPattern    = regexp.MustCompile(`(?s)<(tool|tool_code)\b[^>]*>.*?</(tool|tool_code)>`)
+	strayToolTagPattern = regexp.MustCompile(`</?(tool|tool_code)\b[^>]*>`)
+	scriptBlockPattern  = regexp.MustCompile(`(?is)<(script|iframe|style)\b[^>]*>.*?</(script|iframe|style)>`)
+	blankLinesPattern   = regexp.MustCompile(`\n{3,}`)
+	markdownLinkPattern = regexp.MustCompile(`(!?)\[([^\]]*)\]\(([^)\s]+)\)`)
+)
+
+// stripToolXML removes tool call markup from text meant for the user.
+func stripToolXML(text string) string {
+	text = toolBlockPattern.ReplaceAllString(text, "")
+	text = strayToolTagPattern.ReplaceAllString(text, "")
+	return strings.TrimSpace(text)
+}
+
+// sanitizeMarkdown normalizes markdown so that it renders predictably.
+// Content inside code fences is left as it is apart from line endings.
+func sanitizeMarkdown(text string) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	text = strings.Map(func(r rune) rune {
+		if r < 0x20 && r != '\n' && r != '\t' {
+			return -1
+		}
+		return r
+	}, text)
+
+	var out []string
+	var prose []string
+	inFence := false
+	flushProse := func() {
+		if len(prose) == 0 {
+			return
+		}
+		block := strings.Join(prose, "\n")
+		block = scriptBlockPattern.ReplaceAllString(block, "")
+		block = blankLinesPattern.ReplaceAllString(block, "\n\n")
+		out = append(out, block)
+		prose = prose[:0]
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			if !inFence {
+				flushProse()
+			}
+			out = append(out, strings.TrimRight(line, " \t"))
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			out = append(out, line)
+			continue
+		}
+		prose = append(prose, strings.TrimRight(line, " \t"))
+	}
+	flushProse()
+	if inFence {
+		out = append(out, "```")
+	}
+	return strings.TrimSpace(strings.Join(out, "\n"))
+}
+
+// linkArtifacts rewrites markdown links to local files that were stored as
+// artifacts of the task into artifact:// links clients can resolve with tasks/artifact.
+func linkArtifacts(text string, artifacts map[string]*Artifact) string {
+	if len(artifacts) == 0 {
+		return text
+	}
+	byName := make(map[string]string)
+	ids := make([]string, 0, len(artifacts))
+	for id := range artifacts {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids) // Deterministic choice when several artifacts share a file name
+	for _, id := range ids {
+		if name := artifacts[id].Filename; name != "" {
+			if _, exists := byName[name]; !exists {
+				byName[name] = id
+			}
+		}
+	}
+	return markdownLinkPattern.ReplaceAllStringFunc(text, func(link string) string {
+		m := markdownLinkPattern.FindStringSubmatch(link)
+		target := strings.TrimPrefix(m[3], "file://")
+		if u, err := url.Parse(target); err != nil || u.Scheme != "" || strings.HasPrefix(target, "#") {
+			return link
+		}
+		id, ok := byName[target]
+		if !ok {
+			id, ok = byName[path.Base(target)]
+		}
+		if !ok {
+			return link
+		}
+		return fmt.Sprintf("%s[%s](artifact://%s)", m[1], m[2], id)
+	})
+}
+
+// processFinalOutput applies the executor's output post-processing to the
+// final answer of a task. When the assistant message was already stored,
+// its text is replaced with the processed one.
+func (te *TaskExecutor) processFinalOutput(taskID, text string, messageSaved bool) string {
+	if te.OutputProcessing == nil {
+		return text
+	}
+	task, err := te.TaskStore.GetTask(taskID)
+	if err != nil {
+		return text
+	}
+	processed := te.OutputProcessing.Process(text, task.Artifacts)
+	if te.OutputProcessing.ProvenanceFooter {
+		processed += provenanceFooter(task.Messages)
+	}
+	if processed == text || !messageSaved {
+		return processed
+	}
+	_, err = te.TaskStore.UpdateTask(taskID, func(task *Task) error {
+		for i := len(task.Messages) - 1; i >= 0; i-- {
+			if task.Messages[i].Role == RoleAssistant {
+				task.Messages[i].Parts = []Part{TextPart{Type: "text", Text: processed}}
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("[Task %s] Failed to store post-processed output: %v", taskID, err)
+	}
+	return processed
+}
+
+// provenanceFooter lists the tool calls made since the last user message,
+// so readers of the answer can see what evidence it is based on. Calls with
+// an audit record link to its artifact. It returns "" when no tool was called.
+func provenanceFooter(messages []Message) string {
+	start := 0
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == RoleUser {
+			start = i + 1
+			break
+		}
+	}
+	var entries []string
+	for _, msg := range messages[start:] {
+		if msg.Role != RoleTool {
+			continue
+		}
+		var result struct {
+			ToolName        string      `json:"tool_name"`
+			Error           interface{} `json:"error"`
+			AuditArtifactID string      `json:"audit_artifact_id"`
+		}
+		if json.Unmarshal([]byte(messageText(msg)), &result) != nil || result.ToolName == "" {
+			continue
+		}
+		var notes []string
+		if result.Error != nil {
+			notes = append(notes, "failed")
+		}
+		if result.AuditArtifactID != "" {
+			notes = append(notes, fmt.Sprintf("[audit](artifact://%s)", result.AuditArtifactID))
+		}
+		entry := "`" + result.ToolName + "`"
+		if len(notes) > 0 {
+			entry += " (" + strings.Join(notes, ", ") + ")"
+		}
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		return ""
+	}
+	return "\n\n---\nTool calls: " + strings.Join(entries, ", ")
+}
+
+// truncateOutput cuts text to at most maxLength bytes, on a rune boundary,
+// and appends a notice with the original length.
+func truncateOutput(text string, maxLength int) string {
+	if len(text) <= maxLength {
+		return text
+	}
+	cut := maxLength
+	for cut > 0 && !isRuneStart(text[cut]) {
+		cut--
+	}
+	return text[:cut] + fmt.Sprintf("\n\n[Output truncated: showing %d of %d bytes]", cut, len(text))
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
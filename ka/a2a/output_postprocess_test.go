@@ -0,0 +1,63 @@
+package a2a
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseOutputProcessing(t *testing.T) {
+	if p, err := ParseOutputProcessing(nil, 0); p != nil || err != nil {
+		t.Errorf("Expected no processing by default, got %+v, %v", p, err)
+	}
+	if _, err := ParseOutputProcessing([]string{"shout"}, 0); err == nil {
+		t.Errorf("Expected an unknown step to be rejected")
+	}
+	p, err := ParseOutputProcessing([]string{"sanitize", "strip-tool-xml"}, 100)
+	if err != nil || !p.SanitizeMarkdown || !p.StripToolXML || p.LinkArtifacts || p.MaxLength != 100 {
+		t.Errorf("Unexpected configuration %+v, %v", p, err)
+	}
+}
+
+func TestOutputProcessing_Process(t *testing.T) {
+	p := &OutputProcessing{SanitizeMarkdown: true, LinkArtifacts: true, StripToolXML: true}
+	artifacts := map[string]*Artifact{"a1": {ID: "a1", Filename: "report.md"}}
+	input := "Done.\r\n\r\n\r\n\r\nSee [the report](./out/report.md) and [docs](https://example.com/report.md).   \n" +
+		"<tool id=\"read_file\">x</tool><script>alert(1)</script>\n```go\nfunc main() {}   \nmain()\n"
+
+	got := p.Process(input, artifacts)
+	want := "Done.\n\nSee [the report](artifact://a1) and [docs](https://example.com/report.md).\n\n```go\nfunc main() {}   \nmain()\n```"
+	if got != want {
+		t.Errorf("Unexpected output:\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestOutputProcessing_TruncatesWithNotice(t *testing.T) {
+	p := &OutputProcessing{MaxLength: 5}
+	got := p.Process("héllo world", nil)
+	if !strings.HasPrefix(got, "héll\n\n[Output truncated: showing 5 of 12 bytes]") {
+		t.Errorf("Unexpected truncation %q", got)
+	}
+	if got := p.Process("short", nil); got != "short" {
+		t.Errorf("Expected short output unchanged, got %q", got)
+	}
+}
+
+func TestProvenanceFooter(t *testing.T) {
+	toolMsg := func(text string) Message {
+		return Message{Role: RoleTool, Parts: []Part{TextPart{Type: "text", Text: text}}}
+	}
+	messages := []Message{
+		{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "first"}}},
+		toolMsg(`{"tool_name":"list_files","result":"a","error":null}`),
+		{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "second"}}},
+		toolMsg(`{"tool_name":"read_file","result":"x","error":null,"audit_artifact_id":"tool-audit-1"}`),
+		toolMsg(`{"tool_name":"fetch_url","result":"","error":"timeout"}`),
+	}
+	want := "\n\n---\nTool calls: `read_file` ([audit](artifact://tool-audit-1)), `fetch_url` (failed)"
+	if got := provenanceFooter(messages); got != want {
+		t.Errorf("Unexpected footer:\n%q\nwant\n%q", got, want)
+	}
+	if got := provenanceFooter(messages[:3]); got != "" {
+		t.Errorf("Expected no footer without tool calls, got %q", got)
+	}
+}
@@ -0,0 +1,94 @@
+package a2a
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// PartCodec decodes and renders a custom part type registered with RegisterPartType.
+type PartCodec struct {
+	// Decode turns the raw JSON of a part into a Part. The returned part must
+	// marshal back to JSON with the same "type" so that it survives storage.
+	Decode func(raw json.RawMessage) (Part, error)
+	// Render returns the text the LLM sees for the part. Optional; without it
+	// the part is shown as compact JSON.
+	Render func(part Part) string
+}
+
+// builtinPartTypes cannot be overridden by registered codecs.
+var builtinPartTypes = map[string]bool{"text": true, "file": true, "data": true}
+
+var (
+	partRegistryMu sync.RWMutex
+	partRegistry   = make(map[string]PartCodec)
+)
+
+// RegisterPartType adds a codec for a custom part type such as "geo" or
+// "table". Parts of types without a codec are still kept as CustomPart.
+func RegisterPartType(partType string, codec PartCodec) error {
+	if partType == "" {
+		return fmt.Errorf("part type must not be empty")
+	}
+	if builtinPartTypes[partType] {
+		return fmt.Errorf("part type '%s' is built in", partType)
+	}
+	partRegistryMu.Lock()
+	defer partRegistryMu.Unlock()
+	partRegistry[partType] = codec
+	return nil
+}
+
+func lookupPartCodec(partType string) (PartCodec, bool) {
+	partRegistryMu.RLock()
+	defer partRegistryMu.RUnlock()
+	codec, ok := partRegistry[partType]
+	return codec, ok
+}
+
+// CustomPart holds a part of a type the agent has no built-in support for.
+// It marshals back to its original JSON, so unknown parts round-trip through
+// storage unchanged.
+type CustomPart struct {
+	Type string
+	Raw  json.RawMessage
+}
+
+func (cp CustomPart) GetType() string { return cp.Type }
+
+// MarshalJSON returns the part's original JSON.
+func (cp CustomPart) MarshalJSON() ([]byte, error) {
+	if len(cp.Raw) == 0 {
+		return json.Marshal(map[string]string{"type": cp.Type})
+	}
+	return cp.Raw, nil
+}
+
+// decodeCustomPart decodes a part of a non-built-in type with its registered
+// codec, falling back to a CustomPart when there is none.
+func decodeCustomPart(partType string, raw json.RawMessage) (Part, error) {
+	codec, ok := lookupPartCodec(partType)
+	if !ok || codec.Decode == nil {
+		return CustomPart{Type: partType, Raw: append(json.RawMessage(nil), raw...)}, nil
+	}
+	return codec.Decode(raw)
+}
+
+// maxCustomPartPromptBytes caps the JSON shown for custom parts without a renderer.
+const maxCustomPartPromptBytes = 2000
+
+// customPartPromptText returns the prompt text for a part of a non-built-in type.
+func customPartPromptText(part Part) string {
+	if codec, ok := lookupPartCodec(part.GetType()); ok && codec.Render != nil {
+		return codec.Render(part)
+	}
+	raw, err := json.Marshal(part)
+	if err != nil {
+		return fmt.Sprintf("[%s part]", part.GetType())
+	}
+	text := string(raw)
+	if len(text) > maxCustomPartPromptBytes {
+		text = truncateOutput(text, maxCustomPartPromptBytes)
+	}
+	return fmt.Sprintf("[%s part]: %s", part.GetType(), text)
+}
@@ -0,0 +1,85 @@
+package a2a
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type geoPart struct {
+	Type string  `json:"type"`
+	Lat  float64 `json:"lat"`
+	Lon  float64 `json:"lon"`
+}
+
+func (g geoPart) GetType() string { return "geo" }
+
+func TestCustomPartRoundTrip(t *testing.T) {
+	raw := `{"role":"user","parts":[{"type":"text","text":"hi"},{"type":"chart","spec":{"x":[1,2]}}],"timestamp":"0001-01-01T00:00:00Z","timestamp_unix_ms":0}`
+	var msg Message
+	if err := json.Unmarshal([]byte(raw), &msg); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if len(msg.Parts) != 2 {
+		t.Fatalf("expected the unknown part to be kept, got %d parts", len(msg.Parts))
+	}
+	custom, ok := msg.Parts[1].(CustomPart)
+	if !ok || custom.GetType() != "chart" {
+		t.Fatalf("expected CustomPart of type chart, got %#v", msg.Parts[1])
+	}
+	out, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if !strings.Contains(string(out), `{"type":"chart","spec":{"x":[1,2]}}`) {
+		t.Errorf("custom part not preserved: %s", out)
+	}
+
+	text, found := buildMessageContent(nil, "t1", 0, msg)
+	if !found || !strings.Contains(text, `[chart part]: {"type":"chart","spec":{"x":[1,2]}}`) {
+		t.Errorf("unexpected prompt text %q", text)
+	}
+}
+
+func TestRegisteredPartCodec(t *testing.T) {
+	err := RegisterPartType("geo", PartCodec{
+		Decode: func(raw json.RawMessage) (Part, error) {
+			var g geoPart
+			err := json.Unmarshal(raw, &g)
+			return g, err
+		},
+		Render: func(p Part) string {
+			g := p.(geoPart)
+			return fmt.Sprintf("Location: %.2f, %.2f", g.Lat, g.Lon)
+		},
+	})
+	if err != nil {
+		t.Fatalf("RegisterPartType failed: %v", err)
+	}
+	defer func() {
+		partRegistryMu.Lock()
+		delete(partRegistry, "geo")
+		partRegistryMu.Unlock()
+	}()
+
+	var msg Message
+	if err := json.Unmarshal([]byte(`{"role":"user","parts":[{"type":"geo","lat":59.91,"lon":10.75}]}`), &msg); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if g, ok := msg.Parts[0].(geoPart); !ok || g.Lat != 59.91 {
+		t.Fatalf("expected decoded geoPart, got %#v", msg.Parts[0])
+	}
+	if text, _ := buildMessageContent(nil, "t1", 0, msg); text != "Location: 59.91, 10.75" {
+		t.Errorf("unexpected prompt text %q", text)
+	}
+}
+
+func TestRegisterPartTypeRejectsBuiltins(t *testing.T) {
+	if err := RegisterPartType("text", PartCodec{}); err == nil {
+		t.Error("expected overriding a built-in part type to fail")
+	}
+	if err := RegisterPartType("", PartCodec{}); err == nil {
+		t.Error("expected an empty part type to fail")
+	}
+}
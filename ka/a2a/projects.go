@@ -0,0 +1,261 @@
+package a2a
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Project scopes tasks, their workspace and labels, so one agent can serve
+// several codebases or clients.
+type Project struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Workspace   string   `json:"workspace,omitempty"` // Directory the project's tasks work in
+	Labels      []string `json:"labels,omitempty"`    // Labels tasks may carry; empty allows any
+	APIKeys     []string `json:"apiKeys,omitempty"`   // Keys bound to this project
+}
+
+var (
+	errProjectRequired  = errors.New("a project is required")
+	errProjectUnknown   = errors.New("unknown project")
+	errProjectForbidden = errors.New("the API key is bound to another project")
+)
+
+// ProjectRegistry holds the configured projects. A nil or empty registry
+// disables scoping: tasks have no project and every task is listed.
+type ProjectRegistry struct {
+	projects map[string]Project
+	byAPIKey map[string]string
+}
+
+// LoadProjects reads the projects from a JSON array, given inline or as a file path.
+func LoadProjects(config string) (*ProjectRegistry, error) {
+	data := []byte(config)
+	if !strings.HasPrefix(strings.TrimSpace(config), "[") {
+		fileData, err := os.ReadFile(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read projects file %s: %w", config, err)
+		}
+		data = fileData
+	}
+	var projects []Project
+	if err := json.Unmarshal(data, &projects); err != nil {
+		return nil, fmt.Errorf("failed to parse projects: %w", err)
+	}
+	return NewProjectRegistry(projects)
+}
+
+// NewProjectRegistry validates the projects and indexes them by name and API key.
+func NewProjectRegistry(projects []Project) (*ProjectRegistry, error) {
+	r := &ProjectRegistry{projects: make(map[string]Project), byAPIKey: make(map[string]string)}
+	for _, project := range projects {
+		if project.Name == "" {
+			return nil, fmt.Errorf("project without a name")
+		}
+		if _, exists := r.projects[project.Name]; exists {
+			return nil, fmt.Errorf("duplicate project '%s'", project.Name)
+		}
+		for _, key := range project.APIKeys {
+			if other, bound := r.byAPIKey[key]; bound {
+				return nil, fmt.Errorf("API key of project '%s' is already bound to project '%s'", project.Name, other)
+			}
+			r.byAPIKey[key] = project.Name
+		}
+		r.projects[project.Name] = project
+	}
+	return r, nil
+}
+
+// Enabled reports whether any project is configured.
+func (r *ProjectRegistry) Enabled() bool {
+	return r != nil && len(r.projects) > 0
+}
+
+// Get returns the project with the given name.
+func (r *ProjectRegistry) Get(name string) (Project, bool) {
+	if r == nil {
+		return Project{}, false
+	}
+	project, ok := r.projects[name]
+	return project, ok
+}
+
+// ForAPIKey returns the project an API key is bound to, or "".
+func (r *ProjectRegistry) ForAPIKey(key string) string {
+	if r == nil {
+		return ""
+	}
+	return r.byAPIKey[key]
+}
+
+// APIKeys returns every key bound to a project.
+func (r *ProjectRegistry) APIKeys() []string {
+	if r == nil {
+		return nil
+	}
+	keys := make([]string, 0, len(r.byAPIKey))
+	for key := range r.byAPIKey {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// List returns the projects sorted by name, without their API keys.
+func (r *ProjectRegistry) List() []Project {
+	projects := []Project{}
+	if r == nil {
+		return projects
+	}
+	for _, project := range r.projects {
+		project.APIKeys = nil
+		projects = append(projects, project)
+	}
+	sort.Slice(projects, func(i, j int) bool { return projects[i].Name < projects[j].Name })
+	return projects
+}
+
+// Resolve picks the project of a request from the requested name and the
+// project bound to the caller's API key.
+func (r *ProjectRegistry) Resolve(ctx context.Context, requested string) (string, error) {
+	if !r.Enabled() {
+		return "", nil
+	}
+	bound := projectFromContext(ctx)
+	if bound != "" && requested != "" && requested != bound {
+		return "", fmt.Errorf("%w: requested '%s'", errProjectForbidden, requested)
+	}
+	name := requested
+	if name == "" {
+		name = bound
+	}
+	if name == "" {
+		return "", errProjectRequired
+	}
+	if _, ok := r.projects[name]; !ok {
+		return "", fmt.Errorf("%w '%s'", errProjectUnknown, name)
+	}
+	return name, nil
+}
+
+// CheckLabels returns an error if a label is not allowed in the project.
+func (p Project) CheckLabels(labels []string) error {
+	if len(p.Labels) == 0 {
+		return nil
+	}
+	allowed := make(map[string]bool, len(p.Labels))
+	for _, label := range p.Labels {
+		allowed[label] = true
+	}
+	for _, label := range labels {
+		if !allowed[label] {
+			return fmt.Errorf("label '%s' is not defined in project '%s'", label, p.Name)
+		}
+	}
+	return nil
+}
+
+// SystemPrompt appends the project context to a system prompt.
+func (p Project) SystemPrompt(base string) string {
+	if p.Workspace == "" {
+		return base
+	}
+	return base + "\n\nPROJECT: " + p.Name + "\n====\nWork in the project workspace " + p.Workspace + " and keep all file paths inside it."
+}
+
+// projectError converts a project resolution error to a JSON-RPC error.
+func projectError(err error) *JSONRPCError {
+	if errors.Is(err, errProjectForbidden) {
+		return &JSONRPCError{Code: -32003, Message: fmt.Sprintf("Forbidden: %v", err)}
+	}
+	return &JSONRPCError{Code: -32602, Message: fmt.Sprintf("Invalid Params: %v", err)}
+}
+
+type projectContextKey struct{}
+
+// WithProject returns a context carrying the project bound to the caller's credentials.
+func WithProject(ctx context.Context, project string) context.Context {
+	return context.WithValue(ctx, projectContextKey{}, project)
+}
+
+func projectFromContext(ctx context.Context) string {
+	project, _ := ctx.Value(projectContextKey{}).(string)
+	return project
+}
+
+// HiddenFromCaller reports whether a task belongs to another project than the
+// one the caller's API key is bound to. Handlers that load a task by ID answer
+// Not Found for such tasks, as if they did not exist.
+func HiddenFromCaller(ctx context.Context, store TaskStore, taskID string) bool {
+	bound := projectFromContext(ctx)
+	if bound == "" {
+		return false
+	}
+	task, err := store.GetTask(taskID)
+	return err == nil && task.Project != bound
+}
+
+// tasksOfCaller drops the tasks of other projects than the one the caller's
+// API key is bound to.
+func tasksOfCaller(ctx context.Context, tasks []*Task) []*Task {
+	bound := projectFromContext(ctx)
+	if bound == "" {
+		return tasks
+	}
+	visible := make([]*Task, 0, len(tasks))
+	for _, task := range tasks {
+		if task.Project == bound {
+			visible = append(visible, task)
+		}
+	}
+	return visible
+}
+
+// CheckAdminCaller returns an error if the JSON-RPC method is an admin method
+// and the caller's API key is bound to a project: admin methods act on the
+// whole agent. admin/readOnly checks its callers itself.
+func CheckAdminCaller(ctx context.Context, method string) error {
+	if strings.HasPrefix(method, "admin/") && method != "admin/readOnly" && projectFromContext(ctx) != "" {
+		return fmt.Errorf("method '%s' is not available to an API key bound to a project", method)
+	}
+	return nil
+}
+
+// ProjectsListHandler handles "projects/list". Callers bound to a project only see theirs.
+func ProjectsListHandler(registry *ProjectRegistry) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rpcReq, ok := decodeJSONRPCRequest(w, r, nil)
+		if !ok {
+			return
+		}
+		projects := registry.List()
+		if bound := projectFromContext(r.Context()); bound != "" {
+			visible := []Project{}
+			for _, project := range projects {
+				if project.Name == bound {
+					visible = append(visible, project)
+				}
+			}
+			projects = visible
+		}
+		sendJSONRPCResponse(w, rpcReq.ID, map[string]interface{}{"projects": projects}, nil)
+	}
+}
+
+// inheritProject copies the project and labels of a parent task to a new sub-task.
+func inheritProject(store TaskStore, taskID string, parent *Task) {
+	if parent.Project == "" && len(parent.Labels) == 0 {
+		return
+	}
+	store.UpdateTask(taskID, func(task *Task) error {
+		task.Project = parent.Project
+		task.Labels = append([]string(nil), parent.Labels...)
+		return nil
+	})
+}
@@ -0,0 +1,169 @@
+package a2a
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLoadProjectsAndResolve(t *testing.T) {
+	registry, err := LoadProjects(`[{"name": "web", "workspace": "/src/web", "labels": ["bug"], "apiKeys": ["k-web"]}, {"name": "api"}]`)
+	if err != nil {
+		t.Fatalf("LoadProjects: %v", err)
+	}
+	if _, err := NewProjectRegistry([]Project{{Name: "a", APIKeys: []string{"k"}}, {Name: "b", APIKeys: []string{"k"}}}); err == nil {
+		t.Errorf("Expected a key bound to two projects to be rejected")
+	}
+
+	bound := WithProject(context.Background(), registry.ForAPIKey("k-web"))
+	if name, err := registry.Resolve(bound, ""); err != nil || name != "web" {
+		t.Errorf("Expected the project to be inferred from the key, got %q, %v", name, err)
+	}
+	if _, err := registry.Resolve(bound, "api"); !errors.Is(err, errProjectForbidden) {
+		t.Errorf("Expected a bound key to be refused another project, got %v", err)
+	}
+	if _, err := registry.Resolve(context.Background(), ""); !errors.Is(err, errProjectRequired) {
+		t.Errorf("Expected a project to be required, got %v", err)
+	}
+	if _, err := registry.Resolve(context.Background(), "docs"); !errors.Is(err, errProjectUnknown) {
+		t.Errorf("Expected an unknown project to be rejected, got %v", err)
+	}
+	if name, err := (*ProjectRegistry)(nil).Resolve(context.Background(), ""); err != nil || name != "" {
+		t.Errorf("Expected no scoping without projects, got %q, %v", name, err)
+	}
+
+	web, _ := registry.Get("web")
+	if web.CheckLabels([]string{"feature"}) == nil || web.CheckLabels([]string{"bug"}) != nil {
+		t.Errorf("Expected only the project's labels to be allowed")
+	}
+}
+
+func TestTasksScopedToProject(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	te := NewTaskExecutor(&staticLLMClient{reply: "ok"}, store, nil, "Base prompt.")
+	te.Projects, _ = NewProjectRegistry([]Project{{Name: "web", Workspace: "/src/web", APIKeys: []string{"k-web"}}, {Name: "api"}})
+
+	message := Message{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "hi"}}}
+	webTask, rpcErr := te.createTask(WithProject(context.Background(), "web"), "w", SendTaskParams{Message: message, Labels: []string{"bug"}})
+	if rpcErr != nil {
+		t.Fatalf("createTask: %+v", rpcErr)
+	}
+	if webTask.Project != "web" || !strings.Contains(webTask.SystemPrompt, "/src/web") {
+		t.Errorf("Expected a web task working in its workspace, got %q / %q", webTask.Project, webTask.SystemPrompt)
+	}
+	if _, rpcErr := te.createTask(context.Background(), "x", SendTaskParams{Message: message}); rpcErr == nil || rpcErr.Code != -32602 {
+		t.Errorf("Expected a project to be required, got %+v", rpcErr)
+	}
+	te.createTask(context.Background(), "a", SendTaskParams{Message: message, Project: "api"})
+
+	body := `{"jsonrpc": "2.0", "id": 1, "method": "tasks/list", "params": {"label": "bug"}}`
+	req := httptest.NewRequest("POST", "/", strings.NewReader(body)).WithContext(WithProject(context.Background(), "web"))
+	rec := httptest.NewRecorder()
+	TasksListHandler(store, te.Projects)(rec, req)
+	var resp struct {
+		Result []*Task       `json:"result"`
+		Error  *JSONRPCError `json:"error"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp.Error != nil || len(resp.Result) != 1 || resp.Result[0].ID != webTask.ID {
+		t.Errorf("Expected only the web task, got %d tasks, error %+v", len(resp.Result), resp.Error)
+	}
+}
+
+func TestTaskHandlersHideTasksOfOtherProjects(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	te := NewTaskExecutor(&staticLLMClient{reply: "ok"}, store, nil, "Base prompt.")
+	te.Projects, _ = NewProjectRegistry([]Project{{Name: "web"}, {Name: "api"}})
+	message := Message{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "hi"}}}
+	apiTask, rpcErr := te.createTask(context.Background(), "a", SendTaskParams{Message: message, Project: "api"})
+	if rpcErr != nil {
+		t.Fatalf("createTask: %+v", rpcErr)
+	}
+
+	id := `{"id": "` + apiTask.ID + `"}`
+	for method, handler := range map[string]http.HandlerFunc{
+		"tasks/status":   TasksStatusHandler(store),
+		"tasks/export":   TasksExportHandler(store),
+		"tasks/delete":   TasksDeleteHandler(te),
+		"tasks/addNote":  TasksAddNoteHandler(store),
+		"tasks/update":   TasksUpdateHandler(store),
+		"tasks/thread":   TasksThreadHandler(te),
+		"tasks/restore":  TasksRestoreHandler(store),
+		"tasks/approve":  TasksApproveHandler(te),
+		"tasks/feedback": TasksFeedbackHandler(store),
+	} {
+		params := id
+		switch method {
+		case "tasks/addNote":
+			params = `{"id": "` + apiTask.ID + `", "comment": "x"}`
+		case "tasks/update":
+			params = `{"id": "` + apiTask.ID + `", "name": "renamed"}`
+		case "tasks/approve":
+			params = `{"id": "` + apiTask.ID + `", "approvalId": "x", "approve": true}`
+		case "tasks/feedback":
+			params = `{"id": "` + apiTask.ID + `", "rating": "up"}`
+		}
+		if resp := callRPC(t, asCaller(handler, "web"), method, params); resp.Error == nil || resp.Error.Code != -32001 {
+			t.Errorf("%s: expected a task of another project to be not found, got %+v", method, resp.Error)
+		}
+	}
+	if resp := callRPC(t, asCaller(TasksInputHandler(te), "web"), "tasks/input", `{"id": "`+apiTask.ID+`", "message": {"role": "user", "parts": [{"type": "text", "text": "x"}]}}`); resp.Error == nil || resp.Error.Code != -32001 {
+		t.Errorf("tasks/input: expected a task of another project to be not found, got %+v", resp.Error)
+	}
+	if task, _ := store.GetTask(apiTask.ID); task == nil || task.Name != apiTask.Name || len(task.Notes) != 0 {
+		t.Errorf("Expected the task of another project to be left alone, got %+v", task)
+	}
+	if resp := callRPC(t, asCaller(TasksStatusHandler(store), "api"), "tasks/status", id); resp.Error != nil {
+		t.Errorf("Expected the task to be found in its own project, got %+v", resp.Error)
+	}
+}
+
+func TestProjectBoundCallersSeeOnlyTheirProject(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	te := NewTaskExecutor(&staticLLMClient{reply: "ok"}, store, nil, "Base prompt.")
+	te.Projects, _ = NewProjectRegistry([]Project{{Name: "web"}, {Name: "api"}})
+	message := Message{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "hi"}}}
+	for _, project := range []string{"web", "api", "api"} {
+		if _, rpcErr := te.createTask(context.Background(), project, SendTaskParams{Message: message, Project: project}); rpcErr != nil {
+			t.Fatalf("createTask: %+v", rpcErr)
+		}
+	}
+
+	resp := callRPC(t, asCaller(TasksStatsHandler(store), "web"), "tasks/stats", `{}`)
+	byState, _ := resp.Result.(map[string]interface{})["by_state"].(map[string]interface{})
+	total := 0.0
+	for _, count := range byState {
+		total += count.(float64)
+	}
+	if total != 1 {
+		t.Errorf("Expected the stats of the caller's project only, got %+v", resp.Result)
+	}
+
+	bundle := `{"bundle": {"format_version": 1, "tasks": [{"id": "old", "name": "imported", "state": "completed"}]}}`
+	resp = callRPC(t, asCaller(TasksImportHandler(te), "web"), "tasks/import", bundle)
+	if resp.Error != nil {
+		t.Fatalf("tasks/import: %+v", resp.Error)
+	}
+	rootID, _ := resp.Result.(map[string]interface{})["root_task_id"].(string)
+	if task, err := store.GetTask(rootID); err != nil || task.Project != "web" {
+		t.Errorf("Expected the import to be put in the caller's project, got %+v / %v", task, err)
+	}
+	resp = callRPC(t, asCaller(TasksImportHandler(te), "web"), "tasks/import", `{"bundle": {"tasks": [{"id": "old"}]}, "project": "api"}`)
+	if resp.Error == nil || resp.Error.Code != -32003 {
+		t.Errorf("Expected an import into another project to be refused, got %+v", resp.Error)
+	}
+
+	if err := CheckAdminCaller(WithProject(context.Background(), "web"), "admin/purgeTrash"); err == nil {
+		t.Error("Expected admin methods to be refused to a project-bound caller")
+	}
+	if err := CheckAdminCaller(context.Background(), "admin/purgeTrash"); err != nil {
+		t.Errorf("Expected admin methods to be open to unbound callers, got %v", err)
+	}
+	if err := CheckAdminCaller(WithProject(context.Background(), "web"), "tasks/list"); err != nil {
+		t.Errorf("Expected other methods to be open to a project-bound caller, got %v", err)
+	}
+}
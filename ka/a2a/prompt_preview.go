@@ -0,0 +1,134 @@
+package a2a
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"ka/llm"
+	"ka/tools"
+)
+
+// PreviewPromptParams defines the parameters of "tasks/previewPrompt".
+type PreviewPromptParams struct {
+	TaskID       string   `json:"taskId,omitempty"`       // Preview the next prompt of an existing task
+	Message      *Message `json:"message,omitempty"`      // Input appended to the task's messages
+	SystemPrompt string   `json:"systemPrompt,omitempty"` // Replaces the composed system prompt
+	Tools        []string `json:"tools,omitempty"`        // Compose the system prompt from these tools only
+	Mode         string   `json:"mode,omitempty"`
+	Project      string   `json:"project,omitempty"`
+	Labels       []string `json:"labels,omitempty"`
+}
+
+// PromptPreview is the result of "tasks/previewPrompt".
+type PromptPreview struct {
+	Messages       []PreviewMessage `json:"messages"`
+	TotalTokens    int              `json:"totalTokens"`
+	TokensEstimate bool             `json:"tokensEstimate"` // Counts are estimates: the model's tokenizer is not available or only approximated
+	Mode           string           `json:"mode,omitempty"`
+	Project        string           `json:"project,omitempty"`
+}
+
+// PreviewMessage is one message of a previewed prompt with its token count.
+type PreviewMessage struct {
+	llm.Message
+	Tokens int `json:"tokens"`
+}
+
+// PreviewPrompt composes the messages the LLM would receive for the input,
+// without creating a task or calling the LLM.
+func (te *TaskExecutor) PreviewPrompt(params PreviewPromptParams, systemPrompt string) (*PromptPreview, error) {
+	var messages []Message
+	var failures []ToolFailure
+	if params.TaskID != "" {
+		task, err := te.TaskStore.GetTask(params.TaskID)
+		if err != nil {
+			return nil, err
+		}
+		systemPrompt, messages, failures = task.SystemPrompt, task.Messages, task.ToolFailures
+	}
+	if len(params.Tools) > 0 {
+		var unknown []string
+		for _, name := range params.Tools {
+			if _, ok := te.AvailableTools[name]; !ok {
+				unknown = append(unknown, name)
+			}
+		}
+		if len(unknown) > 0 {
+			sort.Strings(unknown)
+			return nil, fmt.Errorf("unknown tools: %v", unknown)
+		}
+		systemPrompt = tools.ComposeSystemPrompt(params.Tools, []tools.McpServerConfig{}, te.AvailableTools)
+	}
+	if params.SystemPrompt != "" {
+		systemPrompt = params.SystemPrompt
+	}
+	if params.Message != nil {
+		if params.TaskID == "" && len(params.Tools) == 0 && params.SystemPrompt == "" {
+			systemPrompt = te.pruneToolDefinitions(systemPrompt, *params.Message) // As createTask would
+		}
+		messages = append(append([]Message(nil), messages...), *params.Message)
+	}
+
+	llmMessages, contentFound, err := buildPromptFromInput(te.fileClient(), params.TaskID, messages, systemPrompt)
+	if err != nil {
+		return nil, err
+	}
+	if !contentFound {
+		return nil, errors.New("could not extract suitable content from messages for LLM")
+	}
+	llmMessages = appendToolFailureNote(llmMessages, failures)
+
+	preview := &PromptPreview{Messages: make([]PreviewMessage, 0, len(llmMessages))}
+	for _, message := range llmMessages {
+		tokens, exact := llm.CountTokens(te.LLMClient, message.Content)
+		preview.TokensEstimate = !exact
+		preview.TotalTokens += tokens
+		preview.Messages = append(preview.Messages, PreviewMessage{Message: message, Tokens: tokens})
+	}
+	return preview, nil
+}
+
+// TasksPreviewPromptHandler handles "tasks/previewPrompt", a dry run showing
+// the exact prompt and its token counts for an input.
+func TasksPreviewPromptHandler(taskExecutor *TaskExecutor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params PreviewPromptParams
+		rpcReq, ok := decodeJSONRPCRequest(w, r, &params)
+		if !ok {
+			return
+		}
+		if params.TaskID == "" && params.Message == nil {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: "Invalid Params: a message or a task ID is required"})
+			return
+		}
+
+		if params.TaskID != "" && HiddenFromCaller(r.Context(), taskExecutor.TaskStore, params.TaskID) {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32001, Message: "Not Found: Task not found"})
+			return
+		}
+
+		var systemPrompt, modeName, projectName string
+		if params.TaskID == "" {
+			var rpcErr *JSONRPCError
+			systemPrompt, modeName, projectName, rpcErr = taskExecutor.resolveTaskPrompt(r.Context(), params.Mode, params.Project, params.Labels)
+			if rpcErr != nil {
+				sendJSONRPCResponse(w, rpcReq.ID, nil, rpcErr)
+				return
+			}
+		}
+
+		preview, err := taskExecutor.PreviewPrompt(params, systemPrompt)
+		if err != nil {
+			if errors.Is(err, ErrTaskNotFound) {
+				sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32001, Message: "Not Found: Task not found"})
+			} else {
+				sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: fmt.Sprintf("Invalid Params: %v", err)})
+			}
+			return
+		}
+		preview.Mode, preview.Project = modeName, projectName
+		sendJSONRPCResponse(w, rpcReq.ID, preview, nil)
+	}
+}
@@ -0,0 +1,48 @@
+package a2a
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"ka/tools"
+)
+
+func TestTasksPreviewPrompt(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	te := NewTaskExecutor(&staticLLMClient{reply: "unused"}, store, map[string]tools.Tool{"read_file": &failingTool{}}, "Agent prompt")
+	handler := TasksPreviewPromptHandler(te)
+
+	resp := callRPC(t, handler, "tasks/previewPrompt", `{"message": {"role": "user", "parts": [{"type": "text", "text": "hello there"}]}}`)
+	if resp.Error != nil {
+		t.Fatalf("unexpected error: %+v", resp.Error)
+	}
+	var preview PromptPreview
+	raw, _ := json.Marshal(resp.Result)
+	json.Unmarshal(raw, &preview)
+	if len(preview.Messages) != 2 || preview.Messages[0].Content != "Agent prompt" || preview.Messages[1].Content != "hello there" {
+		t.Fatalf("unexpected messages %+v", preview.Messages)
+	}
+	if !preview.TokensEstimate || preview.TotalTokens != preview.Messages[0].Tokens+preview.Messages[1].Tokens || preview.TotalTokens == 0 {
+		t.Errorf("unexpected token counts %+v", preview)
+	}
+	if tasks, _ := store.ListTasks(); len(tasks) != 0 {
+		t.Errorf("expected no task to be created, got %d", len(tasks))
+	}
+
+	resp = callRPC(t, handler, "tasks/previewPrompt", `{"tools": ["read_file"], "message": {"role": "user", "parts": [{"type": "text", "text": "hi"}]}}`)
+	raw, _ = json.Marshal(resp.Result)
+	json.Unmarshal(raw, &preview)
+	if resp.Error != nil || !strings.Contains(preview.Messages[0].Content, "read_file") {
+		t.Errorf("expected a system prompt composed from read_file, got %+v / %+v", resp.Error, preview.Messages)
+	}
+
+	resp = callRPC(t, handler, "tasks/previewPrompt", `{"tools": ["nope"], "message": {"role": "user", "parts": [{"type": "text", "text": "hi"}]}}`)
+	if resp.Error == nil || resp.Error.Code != -32602 {
+		t.Errorf("expected unknown tools to be rejected, got %+v", resp.Error)
+	}
+	resp = callRPC(t, handler, "tasks/previewPrompt", `{"taskId": "missing"}`)
+	if resp.Error == nil || resp.Error.Code != -32001 {
+		t.Errorf("expected a missing task to be reported, got %+v", resp.Error)
+	}
+}
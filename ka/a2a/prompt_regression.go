@@ -0,0 +1,210 @@
+package a2a
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"ka/llm"
+	"ka/tools"
+)
+
+// SmokeCase is one input of a smoke suite with the patterns its answer must
+// and must not match.
+type SmokeCase struct {
+	Name   string   `json:"name"`
+	Input  string   `json:"input"`
+	Expect []string `json:"expect,omitempty"` // Regular expressions the answer must all match
+	Reject []string `json:"reject,omitempty"` // Regular expressions the answer must not match
+
+	expect []*regexp.Regexp
+	reject []*regexp.Regexp
+}
+
+// SmokeSuite is a small eval set run against a new system prompt before it
+// replaces the current one.
+type SmokeSuite struct {
+	Cases          []*SmokeCase `json:"cases"`
+	MaxDrop        float64      `json:"maxDrop,omitempty"`        // Score drop tolerated without force, as a fraction of the cases
+	TimeoutSeconds int          `json:"timeoutSeconds,omitempty"` // Per case; 0 waits for the LLM
+}
+
+// LoadSmokeSuite reads a suite from a JSON object, given inline or as a file path.
+func LoadSmokeSuite(config string) (*SmokeSuite, error) {
+	data := []byte(config)
+	if !strings.HasPrefix(strings.TrimSpace(config), "{") {
+		fileData, err := os.ReadFile(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read smoke suite file %s: %w", config, err)
+		}
+		data = fileData
+	}
+	var suite SmokeSuite
+	if err := json.Unmarshal(data, &suite); err != nil {
+		return nil, fmt.Errorf("failed to parse smoke suite: %w", err)
+	}
+	return &suite, suite.compile()
+}
+
+func (s *SmokeSuite) compile() error {
+	if len(s.Cases) == 0 {
+		return fmt.Errorf("smoke suite has no cases")
+	}
+	if s.MaxDrop < 0 || s.MaxDrop > 1 {
+		return fmt.Errorf("maxDrop must be between 0 and 1")
+	}
+	names := make(map[string]bool)
+	for _, c := range s.Cases {
+		if c.Name == "" || names[c.Name] {
+			return fmt.Errorf("smoke cases need unique names (got '%s')", c.Name)
+		}
+		names[c.Name] = true
+		if c.Input == "" || len(c.Expect)+len(c.Reject) == 0 {
+			return fmt.Errorf("case '%s': needs an input and expect or reject patterns", c.Name)
+		}
+		for _, pattern := range c.Expect {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("case '%s': invalid expect pattern: %w", c.Name, err)
+			}
+			c.expect = append(c.expect, re)
+		}
+		for _, pattern := range c.Reject {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("case '%s': invalid reject pattern: %w", c.Name, err)
+			}
+			c.reject = append(c.reject, re)
+		}
+	}
+	return nil
+}
+
+// check returns why an answer fails the case, or "" if it passes.
+func (c *SmokeCase) check(answer string) string {
+	for i, re := range c.expect {
+		if !re.MatchString(answer) {
+			return fmt.Sprintf("expected %q", c.Expect[i])
+		}
+	}
+	for i, re := range c.reject {
+		if re.MatchString(answer) {
+			return fmt.Sprintf("rejected %q", c.Reject[i])
+		}
+	}
+	return ""
+}
+
+// SmokeResult is the outcome of one case under one prompt.
+type SmokeResult struct {
+	Case    string `json:"case"`
+	Passed  bool   `json:"passed"`
+	Failure string `json:"failure,omitempty"`
+}
+
+// SmokeRun is the outcome of the suite under one prompt.
+type SmokeRun struct {
+	Score   float64       `json:"score"` // Fraction of passed cases
+	Results []SmokeResult `json:"results"`
+}
+
+// PromptRegressionReport compares the suite under the current and a
+// candidate system prompt.
+type PromptRegressionReport struct {
+	Current     SmokeRun `json:"current"`
+	Candidate   SmokeRun `json:"candidate"`
+	Delta       float64  `json:"delta"` // Candidate score minus current score
+	Regressed   bool     `json:"regressed"`
+	Regressions []string `json:"regressions,omitempty"` // Cases passing now and failing with the candidate
+}
+
+// runSmokeSuite asks the LLM every case under systemPrompt.
+func (te *TaskExecutor) runSmokeSuite(ctx context.Context, suite *SmokeSuite, systemPrompt string) SmokeRun {
+	run := SmokeRun{Results: make([]SmokeResult, 0, len(suite.Cases))}
+	passed := 0
+	for _, c := range suite.Cases {
+		caseCtx, cancel := ctx, context.CancelFunc(func() {})
+		if suite.TimeoutSeconds > 0 {
+			caseCtx, cancel = context.WithTimeout(ctx, time.Duration(suite.TimeoutSeconds)*time.Second)
+		}
+		messages := []llm.Message{{Role: string(RoleSystem), Content: systemPrompt}, {Role: string(RoleUser), Content: c.Input}}
+		answer, _, _, err := te.LLMClient.Chat(caseCtx, messages, false, io.Discard)
+		cancel()
+
+		result := SmokeResult{Case: c.Name}
+		if err != nil {
+			result.Failure = fmt.Sprintf("LLM call failed: %v", err)
+		} else {
+			result.Failure = c.check(answer)
+		}
+		result.Passed = result.Failure == ""
+		if result.Passed {
+			passed++
+		}
+		run.Results = append(run.Results, result)
+	}
+	run.Score = float64(passed) / float64(len(suite.Cases))
+	return run
+}
+
+// CheckPrompt runs the smoke suite under the current and the candidate
+// system prompt and reports the score delta.
+func (te *TaskExecutor) CheckPrompt(ctx context.Context, candidate string) *PromptRegressionReport {
+	report := &PromptRegressionReport{
+		Current:   te.runSmokeSuite(ctx, te.SmokeSuite, te.SystemMessage),
+		Candidate: te.runSmokeSuite(ctx, te.SmokeSuite, candidate),
+	}
+	report.Delta = report.Candidate.Score - report.Current.Score
+	report.Regressed = -report.Delta > te.SmokeSuite.MaxDrop+1e-9
+	for i, result := range report.Candidate.Results {
+		if !result.Passed && report.Current.Results[i].Passed {
+			report.Regressions = append(report.Regressions, result.Case)
+		}
+	}
+	log.Printf("[SmokeSuite] Candidate prompt scored %.2f against %.2f (delta %+.2f).", report.Candidate.Score, report.Current.Score, report.Delta)
+	return report
+}
+
+// CheckPromptParams defines the parameters of "admin/checkPrompt".
+type CheckPromptParams struct {
+	SystemPrompt string   `json:"systemPrompt,omitempty"`
+	Tools        []string `json:"tools,omitempty"` // Compose the candidate from these tools instead
+}
+
+// AdminCheckPromptHandler handles "admin/checkPrompt", which runs the smoke
+// suite against a candidate system prompt or tool set without applying it.
+func AdminCheckPromptHandler(taskExecutor *TaskExecutor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params CheckPromptParams
+		rpcReq, ok := decodeJSONRPCRequest(w, r, &params)
+		if !ok {
+			return
+		}
+		if taskExecutor.SmokeSuite == nil {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32000, Message: "No smoke suite configured; start the agent with -prompt-smoke-suite"})
+			return
+		}
+		candidate := params.SystemPrompt
+		if len(params.Tools) > 0 {
+			for _, name := range params.Tools {
+				if _, ok := taskExecutor.AvailableTools[name]; !ok {
+					sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: fmt.Sprintf("Invalid Params: unknown tool %s", name)})
+					return
+				}
+			}
+			candidate = tools.ComposeSystemPrompt(params.Tools, []tools.McpServerConfig{}, taskExecutor.AvailableTools)
+		}
+		if candidate == "" {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: "Invalid Params: systemPrompt or tools is required"})
+			return
+		}
+		sendJSONRPCResponse(w, rpcReq.ID, taskExecutor.CheckPrompt(r.Context(), candidate), nil)
+	}
+}
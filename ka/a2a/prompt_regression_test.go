@@ -0,0 +1,58 @@
+package a2a
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"ka/llm"
+)
+
+// promptEchoClient answers in French when the system prompt asks for it.
+type promptEchoClient struct{}
+
+func (promptEchoClient) Chat(ctx context.Context, messages []llm.Message, stream bool, out io.Writer) (string, int, int, error) {
+	if strings.Contains(messages[0].Content, "French") {
+		return "Bonjour", 0, 0, nil
+	}
+	return "Hello, " + messages[len(messages)-1].Content, 0, 0, nil
+}
+
+func TestCheckPromptReportsRegressions(t *testing.T) {
+	suite, err := LoadSmokeSuite(`{"cases": [
+		{"name": "greets", "input": "Say hello", "expect": ["(?i)hello"]},
+		{"name": "no-apology", "input": "Hi", "reject": ["(?i)sorry"]}
+	]}`)
+	if err != nil {
+		t.Fatalf("LoadSmokeSuite: %v", err)
+	}
+	te := NewTaskExecutor(promptEchoClient{}, NewInMemoryTaskStore(), nil, "You are helpful.")
+	te.SmokeSuite = suite
+
+	report := te.CheckPrompt(context.Background(), "Answer in French.")
+	if report.Current.Score != 1 || report.Candidate.Score != 0.5 || report.Delta != -0.5 {
+		t.Errorf("unexpected scores %+v", report)
+	}
+	if !report.Regressed || len(report.Regressions) != 1 || report.Regressions[0] != "greets" {
+		t.Errorf("expected the greeting case to regress, got %+v", report)
+	}
+
+	suite.MaxDrop = 0.5
+	if report := te.CheckPrompt(context.Background(), "Answer in French."); report.Regressed {
+		t.Errorf("expected a drop within maxDrop to pass, got %+v", report)
+	}
+}
+
+func TestLoadSmokeSuiteValidates(t *testing.T) {
+	for _, config := range []string{
+		`{"cases": []}`,
+		`{"cases": [{"name": "a", "input": "x"}]}`,
+		`{"cases": [{"name": "a", "input": "x", "expect": ["("]}]}`,
+		`{"cases": [{"name": "a", "input": "x", "expect": ["y"]}], "maxDrop": 2}`,
+	} {
+		if _, err := LoadSmokeSuite(config); err == nil {
+			t.Errorf("expected %s to be rejected", config)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package a2a
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync/atomic"
+)
+
+// sideEffectTools change the workspace, start new work or reach external
+// systems. They are refused while the agent is read-only.
+var sideEffectTools = map[string]bool{
+	"write_to_file":   true,
+	"apply_diff":      true,
+	"execute_command": true,
+	"add_task":        true,
+	"mcp":             true,
+	"cloud_object":    true,
+	"fetch_url":       true,
+	"delegate_task":   true,
+}
+
+// HasSideEffects reports whether a tool changes the workspace, starts new
+// work or reaches external systems.
+func HasSideEffects(toolName string) bool {
+	return sideEffectTools[toolName]
+}
+
+// writeMethods are the JSON-RPC methods refused while the agent is read-only.
+// Status, lists, artifacts, exports and streams of existing tasks stay available.
+var writeMethods = map[string]bool{
+	"tasks/send":                 true,
+	"tasks/sendSubscribe":        true,
+	"tasks/input":                true,
+	"tasks/addMessage":           true,
+	"tasks/delete":               true,
+	"tasks/restore":              true,
+	"admin/purgeTrash":           true,
+	"admin/redriveDeadLetters":   true,
+	"admin/dropDeadLetters":      true,
+	"tasks/import":               true,
+	"tasks/importChat":           true,
+	"tasks/pushNotification/set": true,
+	"tasks/step":                 true,
+	"tasks/approve":              true,
+	"events/deliver":             true,
+	"tasks/addNote":              true,
+	"tasks/update":               true,
+	"tasks/feedback":             true,
+	"modes/define":               true,
+}
+
+// ReadOnlyMode is the switch for maintenance windows and public read-only
+// deployments. The zero value is writable.
+type ReadOnlyMode struct {
+	enabled atomic.Bool
+}
+
+// Enabled reports whether the agent is read-only. It is safe on a nil receiver.
+func (m *ReadOnlyMode) Enabled() bool {
+	return m != nil && m.enabled.Load()
+}
+
+// Set switches read-only mode on or off.
+func (m *ReadOnlyMode) Set(enabled bool) {
+	m.enabled.Store(enabled)
+}
+
+// CheckMethod returns an error if the JSON-RPC method writes while read-only.
+func (m *ReadOnlyMode) CheckMethod(method string) error {
+	if m.Enabled() && writeMethods[method] {
+		return fmt.Errorf("method '%s' is disabled while the agent is read-only", method)
+	}
+	return nil
+}
+
+// CheckTool returns an error if the tool has side effects while read-only.
+func (m *ReadOnlyMode) CheckTool(toolName string) error {
+	if m.Enabled() && sideEffectTools[toolName] {
+		return fmt.Errorf("tool '%s' is disabled while the agent is read-only", toolName)
+	}
+	return nil
+}
+
+// ReadOnlyParams defines the parameters of "admin/readOnly". Without
+// Enabled the current setting is only reported.
+type ReadOnlyParams struct {
+	Enabled *bool `json:"enabled,omitempty"`
+}
+
+// AdminReadOnlyHandler handles "admin/readOnly", which reports or toggles read-only mode.
+// It is refused on agents without authentication, and only admin callers,
+// i.e. authenticated ones whose key is not bound to a project, may end
+// read-only mode. The method is not in writeMethods, so it can be ended at all.
+func AdminReadOnlyHandler(mode *ReadOnlyMode) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params ReadOnlyParams
+		rpcReq, ok := decodeJSONRPCRequest(w, r, &params)
+		if !ok {
+			return
+		}
+		if !authenticatedCaller(r.Context()) {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32003, Message: "Forbidden: admin/readOnly requires an authenticated caller"})
+			return
+		}
+		if params.Enabled != nil && !*params.Enabled && mode.Enabled() && projectFromContext(r.Context()) != "" {
+			log.Printf("[AdminReadOnly %v] Refused to end read-only mode for a project-bound caller.", rpcReq.ID)
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32003, Message: "Forbidden: only admin callers may end read-only mode"})
+			return
+		}
+		if params.Enabled != nil {
+			mode.Set(*params.Enabled)
+			log.Printf("[AdminReadOnly %v] Read-only mode set to %v.", rpcReq.ID, *params.Enabled)
+		}
+		sendJSONRPCResponse(w, rpcReq.ID, map[string]bool{"readOnly": mode.Enabled()}, nil)
+	}
+}
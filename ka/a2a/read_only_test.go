@@ -0,0 +1,65 @@
+package a2a
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"ka/tools"
+)
+
+func TestReadOnlyMode(t *testing.T) {
+	var mode ReadOnlyMode
+	if mode.CheckMethod("tasks/send") != nil || mode.CheckTool("write_to_file") != nil {
+		t.Errorf("Expected the zero value to be writable")
+	}
+
+	if resp := callRPC(t, AdminReadOnlyHandler(&mode), "admin/readOnly", `{"enabled": true}`); resp.Error == nil || mode.Enabled() {
+		t.Fatalf("Expected admin/readOnly to be refused without authentication")
+	}
+	resp := callRPC(t, asCaller(AdminReadOnlyHandler(&mode), ""), "admin/readOnly", `{"enabled": true}`)
+	if resp.Error != nil || !mode.Enabled() {
+		t.Fatalf("Expected admin/readOnly to enable read-only mode, got %+v", resp.Error)
+	}
+	if mode.CheckMethod("tasks/send") == nil || mode.CheckMethod("tasks/delete") == nil {
+		t.Errorf("Expected task creation and deletion to be refused")
+	}
+	if mode.CheckMethod("tasks/list") != nil || mode.CheckMethod("admin/readOnly") != nil {
+		t.Errorf("Expected reads and the toggle itself to stay available")
+	}
+	if mode.CheckTool("read_file") != nil || mode.CheckTool("execute_command") == nil || mode.CheckTool("delegate_task") == nil {
+		t.Errorf("Expected only side-effect tools to be refused")
+	}
+
+	if resp := callRPC(t, asCaller(AdminReadOnlyHandler(&mode), "public"), "admin/readOnly", `{"enabled": false}`); resp.Error == nil || !mode.Enabled() {
+		t.Errorf("Expected a project-bound caller not to end read-only mode")
+	}
+	if resp := callRPC(t, asCaller(AdminReadOnlyHandler(&mode), ""), "admin/readOnly", `{"enabled": false}`); resp.Error != nil || mode.Enabled() {
+		t.Errorf("Expected an admin caller to end read-only mode, got %+v", resp.Error)
+	}
+}
+
+// asCaller runs handler for an authenticated caller bound to project, or an
+// admin caller when project is empty.
+func asCaller(handler http.HandlerFunc, project string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := WithAuthenticatedCaller(r.Context())
+		if project != "" {
+			ctx = WithProject(ctx, project)
+		}
+		handler(w, r.WithContext(ctx))
+	}
+}
+
+func TestDispatchToolCall_RefusedWhileReadOnly(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	task, _ := store.CreateTask("t", "", nil, "")
+	te := NewTaskExecutor(nil, store, map[string]tools.Tool{"execute_command": &tools.ExecuteCommandTool{}}, "")
+	te.ReadOnly.Set(true)
+
+	_, err := te.NewDefaultToolDispatcher().DispatchToolCall(context.Background(), task.ID, ToolCall{ID: "1", Function: tools.FunctionCall{Name: "execute_command", Content: `{"command": "touch x"}`}})
+	if err == nil || !strings.Contains(err.Error(), "read-only") {
+		t.Errorf("Expected execute_command to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package a2a
+
+import (
+	"log"
+)
+
+// ReconcileReport lists the tasks a previous agent process left unfinished,
+// as found by Reconcile.
+type ReconcileReport struct {
+	Relaunched    []string `json:"relaunched"`    // WORKING tasks whose run was started again
+	AwaitingInput []string `json:"awaitingInput"` // INPUT_REQUIRED tasks, resumed by tasks/input
+	OtherInstance []string `json:"otherInstance"` // WORKING tasks left to the instance owning them, see AffinityRing
+}
+
+// Reconcile is the startup pass over the tasks of a persistent store. Tasks
+// stored as WORKING lost their run when the previous process exited without
+// a graceful shutdown, so nothing would ever finish them; their runs are
+// started again from the stored messages. Tasks waiting in INPUT_REQUIRED
+// need no run: tasks/input and tasks/approve relaunch them from the store,
+// so they are only reported. Tasks that already have a run in this process,
+// or that another instance owns, are left alone.
+func (te *TaskExecutor) Reconcile() (ReconcileReport, error) {
+	report := ReconcileReport{Relaunched: []string{}, AwaitingInput: []string{}, OtherInstance: []string{}}
+	tasks, err := te.TaskStore.ListTasks()
+	if err != nil {
+		return report, err
+	}
+	for _, task := range tasks {
+		switch task.State {
+		case TaskStateWorking:
+			if !te.ownsTask(task) {
+				report.OtherInstance = append(report.OtherInstance, task.ID)
+				continue
+			}
+			te.mu.Lock()
+			current, err := te.TaskStore.GetTask(task.ID)
+			launched := err == nil && current.State == TaskStateWorking && te.launchRunLocked(current)
+			te.mu.Unlock()
+			if launched {
+				log.Printf("[Task %s] Relaunched: it was WORKING when the previous agent process stopped.", task.ID)
+				report.Relaunched = append(report.Relaunched, task.ID)
+			}
+		case TaskStateInputRequired:
+			report.AwaitingInput = append(report.AwaitingInput, task.ID)
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,43 @@
+package a2a
+
+import (
+	"testing"
+)
+
+func TestReconcileRelaunchesOrphanedTasks(t *testing.T) {
+	store, err := NewFileTaskStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "hi"}}}}
+	orphaned, _ := store.CreateTask("orphaned", "", input, "")
+	store.SetState(orphaned.ID, TaskStateWorking)
+	waiting, _ := store.CreateTask("waiting", "", input, "")
+	store.SetState(waiting.ID, TaskStateInputRequired)
+	done, _ := store.CreateTask("done", "", input, "")
+	store.SetState(done.ID, TaskStateCompleted)
+
+	// A new executor on the store of a process that crashed
+	te := NewTaskExecutor(&scriptedLLMClient{replies: []string{"Done."}}, store, nil, "")
+	report, err := te.Reconcile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Relaunched) != 1 || report.Relaunched[0] != orphaned.ID {
+		t.Errorf("expected only the WORKING task to be relaunched, got %v", report.Relaunched)
+	}
+	if len(report.AwaitingInput) != 1 || report.AwaitingInput[0] != waiting.ID {
+		t.Errorf("expected the INPUT_REQUIRED task to be reported, got %v", report.AwaitingInput)
+	}
+	waitForState(t, store, orphaned.ID, TaskStateCompleted)
+
+	// The parked task resumes from the store once input arrives
+	if err := te.AddTaskMessageAndProcess(waiting.ID, Message{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "yes"}}}); err != nil {
+		t.Fatal(err)
+	}
+	waitForState(t, store, waiting.ID, TaskStateCompleted)
+
+	if report, _ := te.Reconcile(); len(report.Relaunched) != 0 {
+		t.Errorf("expected nothing left to relaunch, got %v", report.Relaunched)
+	}
+}
@@ -0,0 +1,68 @@
+package a2a
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// errReplayed is returned for inbound requests whose nonce was seen before.
+var errReplayed = errors.New("request replayed")
+
+// maxReplayEntries bounds the nonces a ReplayCache keeps; the oldest are
+// dropped first once it is full.
+const maxReplayEntries = 100000
+
+// GitHubReplayWindow is how long the IDs of processed GitHub deliveries are
+// remembered. GitHub signs no timestamp, so a delivery cannot expire.
+const GitHubReplayWindow = 24 * time.Hour
+
+// ReplayCache remembers the nonces of accepted requests for a time window,
+// so a captured request cannot be submitted again within it.
+type ReplayCache struct {
+	window time.Duration
+	now    func() time.Time
+
+	mu    sync.Mutex
+	seen  map[string]time.Time // Nonce to the time it was first seen
+	order []string             // Nonces by the time they were seen; the window is fixed, so oldest first is expiry order
+}
+
+// NewReplayCache returns a cache remembering nonces for window.
+func NewReplayCache(window time.Duration) *ReplayCache {
+	return &ReplayCache{window: window, now: time.Now, seen: make(map[string]time.Time)}
+}
+
+// Check records nonce and returns errReplayed if it was seen within the window.
+func (c *ReplayCache) Check(nonce string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	for len(c.order) > 0 && (len(c.order) >= maxReplayEntries || now.Sub(c.seen[c.order[0]]) > c.window) {
+		delete(c.seen, c.order[0])
+		c.order = c.order[1:]
+	}
+	if _, ok := c.seen[nonce]; ok {
+		return errReplayed
+	}
+	c.seen[nonce] = now
+	c.order = append(c.order, nonce)
+	return nil
+}
+
+// Forget drops nonce, recorded by Check for a request that then failed, so
+// the request may be submitted again.
+func (c *ReplayCache) Forget(nonce string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.seen[nonce]; !ok {
+		return
+	}
+	delete(c.seen, nonce)
+	for i, seen := range c.order {
+		if seen == nonce {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+}
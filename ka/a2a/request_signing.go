@@ -0,0 +1,298 @@
+package a2a
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Headers of signed requests.
+const (
+	HeaderAgentID            = "X-Agent-Id"
+	HeaderSignatureKeyID     = "X-Signature-Key-Id"
+	HeaderSignatureTimestamp = "X-Signature-Timestamp"
+	HeaderSignature          = "X-Signature"
+	HeaderSignatureNonce     = "X-Signature-Nonce"
+)
+
+// Signature algorithms.
+const (
+	SigningHMACSHA256 = "hmac-sha256"
+	SigningEd25519    = "ed25519"
+)
+
+// DefaultSignatureMaxSkew is how far the timestamp of a signed request may
+// be from the verifier's clock.
+const DefaultSignatureMaxSkew = 5 * time.Minute
+
+var errSignatureInvalid = errors.New("invalid request signature")
+
+// SigningKey is a key used to sign outbound or verify inbound requests. HMAC
+// keys need Secret; ed25519 keys need PublicKey to verify and PrivateKey to sign.
+type SigningKey struct {
+	ID         string `json:"id"`
+	Agent      string `json:"agent,omitempty"` // Agent identity the key belongs to
+	Algorithm  string `json:"algorithm"`
+	Secret     string `json:"secret,omitempty"`
+	PublicKey  string `json:"publicKey,omitempty"`  // Base64
+	PrivateKey string `json:"privateKey,omitempty"` // Base64; only for keys of this agent
+
+	publicKey  ed25519.PublicKey
+	privateKey ed25519.PrivateKey
+}
+
+// LoadSigningKeys reads signing keys from a JSON array, given inline or as a file path.
+func LoadSigningKeys(config string) (map[string]*SigningKey, error) {
+	data := []byte(config)
+	if !strings.HasPrefix(strings.TrimSpace(config), "[") {
+		fileData, err := os.ReadFile(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read signing keys file %s: %w", config, err)
+		}
+		data = fileData
+	}
+	var keys []*SigningKey
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, fmt.Errorf("failed to parse signing keys: %w", err)
+	}
+	byID := make(map[string]*SigningKey, len(keys))
+	for _, key := range keys {
+		if err := key.init(); err != nil {
+			return nil, err
+		}
+		if _, exists := byID[key.ID]; exists {
+			return nil, fmt.Errorf("duplicate signing key '%s'", key.ID)
+		}
+		byID[key.ID] = key
+	}
+	return byID, nil
+}
+
+func (k *SigningKey) init() error {
+	if k.ID == "" {
+		return fmt.Errorf("signing key without an id")
+	}
+	switch k.Algorithm {
+	case SigningHMACSHA256:
+		if k.Secret == "" {
+			return fmt.Errorf("signing key '%s' has no secret", k.ID)
+		}
+	case SigningEd25519:
+		if k.PrivateKey != "" {
+			raw, err := base64.StdEncoding.DecodeString(k.PrivateKey)
+			if err != nil || len(raw) != ed25519.PrivateKeySize {
+				return fmt.Errorf("signing key '%s' has an invalid private key", k.ID)
+			}
+			k.privateKey = ed25519.PrivateKey(raw)
+			k.publicKey = k.privateKey.Public().(ed25519.PublicKey)
+		}
+		if k.PublicKey != "" {
+			raw, err := base64.StdEncoding.DecodeString(k.PublicKey)
+			if err != nil || len(raw) != ed25519.PublicKeySize {
+				return fmt.Errorf("signing key '%s' has an invalid public key", k.ID)
+			}
+			k.publicKey = ed25519.PublicKey(raw)
+		}
+		if k.publicKey == nil {
+			return fmt.Errorf("signing key '%s' needs a public or private key", k.ID)
+		}
+	default:
+		return fmt.Errorf("signing key '%s' has unknown algorithm '%s' (expected %s or %s)", k.ID, k.Algorithm, SigningHMACSHA256, SigningEd25519)
+	}
+	return nil
+}
+
+// canSign reports whether the key holds the material to sign requests.
+func (k *SigningKey) canSign() bool {
+	return k.Algorithm == SigningHMACSHA256 || k.privateKey != nil
+}
+
+// signaturePayload is the string that is signed: method, path with query,
+// timestamp, the SHA-256 of the body and the nonce, one per line. The nonce
+// line is left out for peers that send none.
+func signaturePayload(method, path, timestamp, nonce string, body []byte) []byte {
+	sum := sha256.Sum256(body)
+	lines := []string{method, path, timestamp, hex.EncodeToString(sum[:])}
+	if nonce != "" {
+		lines = append(lines, nonce)
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
+
+func (k *SigningKey) sign(payload []byte) string {
+	if k.Algorithm == SigningHMACSHA256 {
+		mac := hmac.New(sha256.New, []byte(k.Secret))
+		mac.Write(payload)
+		return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+	return base64.StdEncoding.EncodeToString(ed25519.Sign(k.privateKey, payload))
+}
+
+func (k *SigningKey) verify(payload []byte, signature string) bool {
+	raw, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	if k.Algorithm == SigningHMACSHA256 {
+		mac := hmac.New(sha256.New, []byte(k.Secret))
+		mac.Write(payload)
+		return hmac.Equal(raw, mac.Sum(nil))
+	}
+	return ed25519.Verify(k.publicKey, payload, raw)
+}
+
+// RequestSigner signs the outbound requests of this agent (delegation to
+// other agents, webhooks) so peers can authenticate it without bearer tokens.
+type RequestSigner struct {
+	Agent string // Identity sent in X-Agent-Id
+	Key   *SigningKey
+	now   func() time.Time
+}
+
+// NewRequestSigner returns a signer for agent using the key with the given ID.
+func NewRequestSigner(agent string, keys map[string]*SigningKey, keyID string) (*RequestSigner, error) {
+	key, ok := keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key '%s'", keyID)
+	}
+	if !key.canSign() {
+		return nil, fmt.Errorf("signing key '%s' has no private key", keyID)
+	}
+	return &RequestSigner{Agent: agent, Key: key, now: time.Now}, nil
+}
+
+// Sign adds the signature headers to req for the given body. A nil signer
+// leaves the request unsigned.
+func (s *RequestSigner) Sign(req *http.Request, body []byte) {
+	if s == nil {
+		return
+	}
+	timestamp := strconv.FormatInt(s.now().Unix(), 10)
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	req.Header.Set(HeaderAgentID, s.Agent)
+	req.Header.Set(HeaderSignatureKeyID, s.Key.ID)
+	req.Header.Set(HeaderSignatureTimestamp, timestamp)
+	req.Header.Set(HeaderSignatureNonce, hex.EncodeToString(nonce))
+	req.Header.Set(HeaderSignature, s.Key.Algorithm+"="+s.Key.sign(signaturePayload(req.Method, req.URL.RequestURI(), timestamp, hex.EncodeToString(nonce), body)))
+}
+
+// Client returns an HTTP client signing every request it sends through
+// next, or http.DefaultTransport when next is nil.
+func (s *RequestSigner) Client(timeout time.Duration, next http.RoundTripper) *http.Client {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &http.Client{Timeout: timeout, Transport: &signingTransport{signer: s, next: next}}
+}
+
+type signingTransport struct {
+	signer *RequestSigner
+	next   http.RoundTripper
+}
+
+func (t *signingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, err
+		}
+		req.Body.Close()
+	}
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	t.signer.Sign(req, body)
+	return t.next.RoundTrip(req)
+}
+
+// SignatureVerifier authenticates inbound requests signed by known agents.
+type SignatureVerifier struct {
+	Keys    map[string]*SigningKey
+	MaxSkew time.Duration // 0 uses DefaultSignatureMaxSkew
+	Replay  *ReplayCache  // Refuses requests whose nonce, or signature without one, was seen before; nil accepts replays
+	now     func() time.Time
+}
+
+// NewSignatureVerifier returns a verifier accepting signatures of the keys,
+// each once: nonces are remembered as long as the timestamp of a request can
+// be accepted.
+func NewSignatureVerifier(keys map[string]*SigningKey) *SignatureVerifier {
+	return &SignatureVerifier{Keys: keys, Replay: NewReplayCache(2 * DefaultSignatureMaxSkew), now: time.Now}
+}
+
+// Signed reports whether the request carries a signature.
+func Signed(r *http.Request) bool {
+	return r.Header.Get(HeaderSignature) != ""
+}
+
+// Verify checks the signature of r over body and returns the identity of the
+// signing agent: the key's agent, or the key ID if the key names none.
+func (v *SignatureVerifier) Verify(r *http.Request, body []byte) (string, error) {
+	keyID := r.Header.Get(HeaderSignatureKeyID)
+	key, ok := v.Keys[keyID]
+	if !ok {
+		return "", fmt.Errorf("%w: unknown key '%s'", errSignatureInvalid, keyID)
+	}
+	timestamp := r.Header.Get(HeaderSignatureTimestamp)
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return "", fmt.Errorf("%w: missing or malformed timestamp", errSignatureInvalid)
+	}
+	maxSkew := v.MaxSkew
+	if maxSkew <= 0 {
+		maxSkew = DefaultSignatureMaxSkew
+	}
+	if skew := v.now().Sub(time.Unix(unix, 0)); skew > maxSkew || skew < -maxSkew {
+		return "", fmt.Errorf("%w: timestamp is %s off", errSignatureInvalid, skew.Round(time.Second))
+	}
+	algorithm, signature, _ := strings.Cut(r.Header.Get(HeaderSignature), "=")
+	nonce := r.Header.Get(HeaderSignatureNonce)
+	if algorithm != key.Algorithm || !key.verify(signaturePayload(r.Method, r.URL.RequestURI(), timestamp, nonce, body), signature) {
+		return "", fmt.Errorf("%w: signature does not match key '%s'", errSignatureInvalid, keyID)
+	}
+	agent := key.Agent
+	if agent == "" {
+		agent = keyID
+	}
+	if claimed := r.Header.Get(HeaderAgentID); claimed != "" && key.Agent != "" && claimed != key.Agent {
+		return "", fmt.Errorf("%w: key '%s' does not belong to agent '%s'", errSignatureInvalid, keyID, claimed)
+	}
+	if v.Replay != nil {
+		if nonce == "" {
+			nonce = signature // Peers without nonces cannot send the same request twice within the window
+		}
+		if err := v.Replay.Check(keyID + ":" + nonce); err != nil {
+			return "", fmt.Errorf("%w with key '%s'", err, keyID)
+		}
+	}
+	return agent, nil
+}
+
+type signingAgentKey struct{}
+
+// WithSigningAgent returns a context carrying the verified identity of the
+// agent that signed the request.
+func WithSigningAgent(ctx context.Context, agent string) context.Context {
+	return context.WithValue(ctx, signingAgentKey{}, agent)
+}
+
+// SigningAgentFromContext returns the verified identity of the calling agent, or "".
+func SigningAgentFromContext(ctx context.Context) string {
+	agent, _ := ctx.Value(signingAgentKey{}).(string)
+	return agent
+}
@@ -0,0 +1,173 @@
+package a2a
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testSigningKeys(t *testing.T) map[string]*SigningKey {
+	t.Helper()
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := LoadSigningKeys(`[
+		{"id": "shared", "algorithm": "hmac-sha256", "secret": "s3cret"},
+		{"id": "planner-1", "agent": "planner", "algorithm": "ed25519", "privateKey": "` + base64.StdEncoding.EncodeToString(private) + `"}
+	]`)
+	if err != nil {
+		t.Fatalf("LoadSigningKeys: %v", err)
+	}
+	return keys
+}
+
+func TestRequestSignatureRoundTrip(t *testing.T) {
+	keys := testSigningKeys(t)
+	verifier := NewSignatureVerifier(keys)
+	for keyID, wantAgent := range map[string]string{"shared": "shared", "planner-1": "planner"} {
+		signer, err := NewRequestSigner("planner", keys, keyID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body := []byte(`{"jsonrpc":"2.0","method":"tasks/send"}`)
+		req := httptest.NewRequest("POST", "/?x=1", nil)
+		signer.Sign(req, body)
+
+		agent, err := verifier.Verify(req, body)
+		if err != nil || agent != wantAgent {
+			t.Errorf("%s: Verify = %q, %v; want %q", keyID, agent, err, wantAgent)
+		}
+		if _, err := verifier.Verify(req, []byte(`{"tampered":true}`)); !errors.Is(err, errSignatureInvalid) {
+			t.Errorf("%s: expected a tampered body to be rejected, got %v", keyID, err)
+		}
+	}
+}
+
+func TestRequestSignatureRejections(t *testing.T) {
+	keys := testSigningKeys(t)
+	signer, _ := NewRequestSigner("planner", keys, "planner-1")
+	verifier := NewSignatureVerifier(keys)
+
+	stale := httptest.NewRequest("POST", "/", nil)
+	signer.now = func() time.Time { return time.Now().Add(-time.Hour) }
+	signer.Sign(stale, nil)
+	if _, err := verifier.Verify(stale, nil); err == nil || !strings.Contains(err.Error(), "timestamp") {
+		t.Errorf("expected a stale signature to be rejected, got %v", err)
+	}
+
+	impostor := httptest.NewRequest("POST", "/", nil)
+	signer.now = time.Now
+	signer.Sign(impostor, nil)
+	impostor.Header.Set(HeaderAgentID, "someone-else")
+	if _, err := verifier.Verify(impostor, nil); err == nil {
+		t.Error("expected a key used for another agent to be rejected")
+	}
+
+	unknown := httptest.NewRequest("POST", "/", nil)
+	signer.Sign(unknown, nil)
+	unknown.Header.Set(HeaderSignatureKeyID, "missing")
+	if _, err := verifier.Verify(unknown, nil); err == nil {
+		t.Error("expected an unknown key to be rejected")
+	}
+
+	if _, err := LoadSigningKeys(`[{"id": "k", "algorithm": "rsa"}]`); err == nil {
+		t.Error("expected an unknown algorithm to be rejected")
+	}
+	public := &SigningKey{ID: "peer", Algorithm: SigningEd25519, PublicKey: base64.StdEncoding.EncodeToString(keys["planner-1"].publicKey)}
+	if err := public.init(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewRequestSigner("x", map[string]*SigningKey{"peer": public}, "peer"); err == nil {
+		t.Error("expected a public-only key to be refused for signing")
+	}
+}
+
+func TestRequestSignatureReplayIsRejected(t *testing.T) {
+	keys := testSigningKeys(t)
+	signer, _ := NewRequestSigner("planner", keys, "shared")
+	verifier := NewSignatureVerifier(keys)
+	body := []byte(`{"jsonrpc":"2.0","method":"events/deliver"}`)
+
+	req := httptest.NewRequest("POST", "/", nil)
+	signer.Sign(req, body)
+	if _, err := verifier.Verify(req, body); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if _, err := verifier.Verify(req, body); !errors.Is(err, errReplayed) {
+		t.Errorf("expected the captured request to be refused, got %v", err)
+	}
+	again := httptest.NewRequest("POST", "/", nil)
+	signer.Sign(again, body)
+	if _, err := verifier.Verify(again, body); err != nil {
+		t.Errorf("expected the same request with a new nonce to pass, got %v", err)
+	}
+
+	// The nonce is signed, so a replay cannot just change it
+	forged := httptest.NewRequest("POST", "/", nil)
+	signer.Sign(forged, body)
+	forged.Header.Set(HeaderSignatureNonce, "00")
+	if _, err := verifier.Verify(forged, body); !errors.Is(err, errSignatureInvalid) {
+		t.Errorf("expected a changed nonce to break the signature, got %v", err)
+	}
+
+	// Peers without nonces are held to one use of each signature
+	legacy := httptest.NewRequest("POST", "/", nil)
+	signer.Sign(legacy, body)
+	legacy.Header.Del(HeaderSignatureNonce)
+	timestamp := legacy.Header.Get(HeaderSignatureTimestamp)
+	legacy.Header.Set(HeaderSignature, SigningHMACSHA256+"="+keys["shared"].sign(signaturePayload("POST", "/", timestamp, "", body)))
+	if _, err := verifier.Verify(legacy, body); err != nil {
+		t.Fatalf("expected a request without nonce to pass once: %v", err)
+	}
+	if _, err := verifier.Verify(legacy, body); !errors.Is(err, errReplayed) {
+		t.Errorf("expected its replay to be refused, got %v", err)
+	}
+}
+
+func TestReplayCacheExpiresNonces(t *testing.T) {
+	cache := NewReplayCache(time.Minute)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	if cache.Check("a") != nil || cache.Check("a") == nil {
+		t.Fatal("expected the second use of a nonce to be refused")
+	}
+	now = now.Add(2 * time.Minute)
+	if err := cache.Check("a"); err != nil {
+		t.Errorf("expected the nonce to be forgotten after the window, got %v", err)
+	}
+}
+
+func TestSigningClientAndWebhookAreVerified(t *testing.T) {
+	keys := testSigningKeys(t)
+	signer, _ := NewRequestSigner("planner", keys, "shared")
+	verifier := NewSignatureVerifier(keys)
+	verified := make(chan error, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_, err := verifier.Verify(r, body)
+		verified <- err
+	}))
+	defer server.Close()
+
+	resp, err := signer.Client(5*time.Second, nil).Post(server.URL+"/rpc", "application/json", strings.NewReader(`{"a":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if err := <-verified; err != nil {
+		t.Errorf("signed client request failed verification: %v", err)
+	}
+
+	WebhookAlert(server.URL, &Deliverer{Signer: signer, MaxAttempts: 1})(StallAlert{TaskID: "t1"})
+	if err := <-verified; err != nil {
+		t.Errorf("signed webhook failed verification: %v", err)
+	}
+}
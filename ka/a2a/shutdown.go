@@ -0,0 +1,152 @@
+package a2a
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+
+	"ka/tools"
+)
+
+// errShutdown is the cancellation cause of runs stopped by Shutdown.
+var errShutdown = errors.New("agent is shutting down")
+
+// withShutdown derives a run context that Shutdown cancels with errShutdown.
+func (te *TaskExecutor) withShutdown(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	if te.lifetime == nil {
+		return ctx, func() { cancel(nil) }
+	}
+	stop := context.AfterFunc(te.lifetime, func() { cancel(errShutdown) })
+	return ctx, func() {
+		stop()
+		cancel(nil)
+	}
+}
+
+// interruptedDetail is the error of a task whose run was stopped by a shutdown.
+func interruptedDetail() *ErrorDetail {
+	return &ErrorDetail{
+		Code:      ErrorCodeInterrupted,
+		Message:   "the agent shut down while the task was running",
+		Hint:      "The task resumes when the agent starts with -resume-interrupted, or when a message is added to it.",
+		Retryable: true,
+	}
+}
+
+// markInterrupted persists a task stopped by a shutdown as INTERRUPTED. Tasks
+// that finished or were parked in the meantime are left alone.
+func markInterrupted(store TaskStore, taskID string) bool {
+	interrupted := false
+	_, err := store.UpdateTask(taskID, func(t *Task) error {
+		switch t.State {
+		case TaskStateSubmitted, TaskStateWorking, TaskStateCanceled:
+			t.State = TaskStateInterrupted
+			setTaskError(t, interruptedDetail())
+			interrupted = true
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("[Task %s] Failed to mark task as interrupted: %v", taskID, err)
+		return false
+	}
+	return interrupted
+}
+
+// interruptIfShutDown marks the task INTERRUPTED when its run was stopped by
+// Shutdown and ends its stream, if any, with a final state event.
+func (te *TaskExecutor) interruptIfShutDown(ctx context.Context, taskID string, sseWriter *SSEWriter) {
+	if !errors.Is(context.Cause(ctx), errShutdown) || !markInterrupted(te.TaskStore, taskID) {
+		return
+	}
+	log.Printf("[Task %s] Interrupted by shutdown.", taskID)
+	if sseWriter != nil {
+		detail := interruptedDetail()
+		data, _ := json.Marshal(map[string]interface{}{"status": string(TaskStateInterrupted), "error": detail.Message, "errorDetail": detail})
+		sseWriter.SendEvent("state", string(data))
+	}
+}
+
+// Shutdown stops the running tasks: their runs are cancelled, the tasks are
+// persisted as INTERRUPTED and streamed runs send a final state event. It
+// waits for the runs to end until ctx is done; tasks whose run is still stuck
+// then, e.g. in a tool call, are marked INTERRUPTED in the store directly.
+// It returns the IDs of the tasks that were running.
+func (te *TaskExecutor) Shutdown(ctx context.Context) []string {
+	te.mu.Lock()
+	var running []string
+	for taskID := range te.activeRuns {
+		running = append(running, taskID)
+	}
+	te.mu.Unlock()
+	if te.stop != nil {
+		te.stop()
+	}
+	if len(running) > 0 {
+		log.Printf("[TaskExecutor] Shutting down: interrupting %d running task(s).", len(running))
+	}
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		te.mu.Lock()
+		remaining := len(te.activeRuns)
+		te.mu.Unlock()
+		if remaining == 0 {
+			return running
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			te.mu.Lock()
+			var stuck []string
+			for taskID := range te.activeRuns {
+				stuck = append(stuck, taskID)
+			}
+			te.mu.Unlock()
+			for _, taskID := range stuck {
+				tools.TerminateCommands(taskID, "")
+				if markInterrupted(te.TaskStore, taskID) {
+					log.Printf("[Task %s] Run did not stop in time. Marked as interrupted.", taskID)
+				}
+			}
+			return running
+		}
+	}
+}
+
+// ResumeInterrupted relaunches the tasks a previous shutdown interrupted and
+// returns their IDs. Tasks another instance owns are left to it.
+func (te *TaskExecutor) ResumeInterrupted() ([]string, error) {
+	tasks, err := te.TaskStore.ListTasks()
+	if err != nil {
+		return nil, err
+	}
+	var resumed []string
+	for _, task := range tasks {
+		if task.State != TaskStateInterrupted || !te.ownsTask(task) {
+			continue
+		}
+		task, err := te.TaskStore.UpdateTask(task.ID, func(t *Task) error {
+			if t.State == TaskStateInterrupted {
+				t.State = TaskStateWorking
+				setTaskError(t, nil)
+			}
+			return nil
+		})
+		if err != nil || task.State != TaskStateWorking {
+			continue
+		}
+		te.mu.Lock()
+		launched := te.launchRunLocked(task)
+		te.mu.Unlock()
+		if launched {
+			log.Printf("[Task %s] Resumed after interruption by a shutdown.", task.ID)
+			resumed = append(resumed, task.ID)
+		}
+	}
+	return resumed, nil
+}
@@ -0,0 +1,59 @@
+package a2a
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShutdownInterruptsAndResumesTasks(t *testing.T) {
+	store, err := NewFileTaskStore(t.TempDir()) // Returns copies, unlike the in-memory store, so the runs do not race with the test
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &gatedLLMClient{release: make(chan struct{})}
+	te := NewTaskExecutor(client, store, nil, "")
+
+	task, _ := store.CreateTask("long", "", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "hi"}}}}, "")
+	go te.ExecuteTask(context.Background(), task)
+
+	rec := &syncRecorder{ResponseRecorder: httptest.NewRecorder()}
+	subscribed := make(chan struct{})
+	go func() {
+		defer close(subscribed)
+		body := `{"jsonrpc":"2.0","id":1,"method":"tasks/sendSubscribe","params":{"message":{"role":"user","parts":[{"type":"text","text":"hi"}]}}}`
+		TasksSendSubscribeHandler(te)(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+	}()
+	waitForBody(t, rec, `"chunk":"first"`)
+	waitForState(t, store, task.ID, TaskStateWorking)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if running := te.Shutdown(ctx); len(running) != 2 {
+		t.Fatalf("expected both running tasks to be interrupted, got %v", running)
+	}
+	<-subscribed
+
+	interrupted, _ := taskSnapshot(store, task.ID)
+	if interrupted.State != TaskStateInterrupted || interrupted.ErrorDetail == nil || interrupted.ErrorDetail.Code != ErrorCodeInterrupted {
+		t.Errorf("expected the task to be persisted as interrupted, got %s %+v", interrupted.State, interrupted.ErrorDetail)
+	}
+	if body := rec.body(); !strings.Contains(body, `"status":"INTERRUPTED"`) {
+		t.Errorf("expected the stream to end with the interrupted state, got:\n%s", body)
+	}
+
+	// The next agent on the same store resumes them
+	close(client.release)
+	restarted := NewTaskExecutor(client, store, nil, "")
+	resumed, err := restarted.ResumeInterrupted()
+	if err != nil || len(resumed) != 2 {
+		t.Fatalf("expected both tasks to be resumed, got %v, %v", resumed, err)
+	}
+	waitForState(t, store, task.ID, TaskStateCompleted)
+	if completed, _ := taskSnapshot(store, task.ID); completed.ErrorDetail != nil {
+		t.Errorf("expected the interruption error to be cleared, got %+v", completed.ErrorDetail)
+	}
+}
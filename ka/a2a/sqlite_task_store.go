@@ -0,0 +1,538 @@
+package a2a
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	_ "modernc.org/sqlite"
+)
+
+var _ TaskStore = (*SQLiteTaskStore)(nil)
+
+// sqliteSchema keeps each task as a JSON document next to the columns that
+// are queried, so listings and paging use indices instead of decoding every
+// task. Streamed artifacts are stored as chunks outside the task document,
+// the event logs of tasks as rows per event and undelivered notifications as
+// dead letters.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS tasks (
+	id             TEXT PRIMARY KEY,
+	state          TEXT NOT NULL,
+	parent_task_id TEXT NOT NULL DEFAULT '',
+	created_at     INTEGER NOT NULL,
+	updated_at     INTEGER NOT NULL,
+	data           BLOB NOT NULL
+);
+CREATE INDEX IF NOT EXISTS tasks_state ON tasks (state);
+CREATE INDEX IF NOT EXISTS tasks_created_at ON tasks (created_at, id);
+CREATE INDEX IF NOT EXISTS tasks_parent_task_id ON tasks (parent_task_id);
+CREATE TABLE IF NOT EXISTS artifact_chunks (
+	task_id     TEXT NOT NULL,
+	artifact_id TEXT NOT NULL,
+	seq         INTEGER NOT NULL,
+	data        BLOB NOT NULL,
+	PRIMARY KEY (task_id, artifact_id, seq)
+);
+CREATE TABLE IF NOT EXISTS task_events (
+	task_id TEXT NOT NULL,
+	seq     INTEGER NOT NULL,
+	data    BLOB NOT NULL,
+	PRIMARY KEY (task_id, seq)
+);
+CREATE TABLE IF NOT EXISTS dead_letters (
+	id         TEXT PRIMARY KEY,
+	created_at INTEGER NOT NULL,
+	data       BLOB NOT NULL
+);`
+
+// SQLiteTaskStore keeps tasks in a single SQLite file. Unlike FileTaskStore
+// it does not rewrite a file per task for every update and answers listings
+// by state, parent and creation time from indices.
+type SQLiteTaskStore struct {
+	db         *sql.DB
+	mu         sync.Mutex // Serializes read-modify-write updates
+	migrations []string   // Schema changes applied when the database was opened
+}
+
+// NewSQLiteTaskStore opens or creates the database at path.
+func NewSQLiteTaskStore(path string) (*SQLiteTaskStore, error) {
+	// Pragmas in the DSN apply to every pooled connection, not only the first.
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	db, err := sql.Open("sqlite", path+separator+"_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open task database %s: %w", path, err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to configure task database %s: %w", path, err)
+	}
+	existing := make(map[string]bool)
+	rows, err := db.Query(`SELECT name FROM sqlite_master WHERE type = 'table'`)
+	if err == nil {
+		for rows.Next() {
+			var name string
+			if rows.Scan(&name) == nil {
+				existing[name] = true
+			}
+		}
+		rows.Close()
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create the schema of task database %s: %w", path, err)
+	}
+	store := &SQLiteTaskStore{db: db}
+	for _, table := range []string{"tasks", "artifact_chunks", "task_events", "dead_letters"} {
+		if !existing[table] {
+			store.migrations = append(store.migrations, "created table "+table)
+		}
+	}
+	return store, nil
+}
+
+// Migrations returns the schema changes applied when the database was
+// opened, e.g. the tables created in a new or older database.
+func (s *SQLiteTaskStore) Migrations() []string {
+	return s.migrations
+}
+
+// Close closes the database.
+func (s *SQLiteTaskStore) Close() error {
+	return s.db.Close()
+}
+
+// sqlQueryer is implemented by *sql.DB and *sql.Tx.
+type sqlQueryer interface {
+	QueryRow(query string, args ...any) *sql.Row
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+func loadSqliteTask(q sqlQueryer, taskID string) (*Task, error) {
+	var data []byte
+	err := q.QueryRow(`SELECT data FROM tasks WHERE id = ?`, taskID).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTaskNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read task %s: %w", taskID, err)
+	}
+	var task Task
+	if err := json.Unmarshal(data, &task); err != nil {
+		return nil, fmt.Errorf("%w: failed to unmarshal task %s: %v", ErrTaskCorrupt, taskID, err)
+	}
+	return &task, nil
+}
+
+func saveSqliteTask(q sqlQueryer, task *Task) error {
+	data, err := json.Marshal(task)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task %s: %w", task.ID, err)
+	}
+	_, err = q.Exec(`INSERT INTO tasks (id, state, parent_task_id, created_at, updated_at, data) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET state = excluded.state, parent_task_id = excluded.parent_task_id, updated_at = excluded.updated_at, data = excluded.data`,
+		task.ID, string(task.State), task.ParentTaskID, task.CreatedAt.UnixNano(), task.UpdatedAt.UnixNano(), data)
+	if err != nil {
+		return fmt.Errorf("failed to save task %s: %w", task.ID, err)
+	}
+	return nil
+}
+
+// CreateTask creates a new task with the given name, system prompt, input messages, and parent task ID.
+func (s *SQLiteTaskStore) CreateTask(name string, systemPrompt string, initialMessages []Message, parentTaskID string) (*Task, error) {
+	now := time.Now().UTC()
+	messages := make([]Message, len(initialMessages))
+	for i, msg := range initialMessages {
+		msg.Timestamp = now
+		msg.TimestampUnixMs = now.UnixMilli()
+		messages[i] = msg
+	}
+	task := &Task{
+		ID:              uuid.NewString(),
+		Name:            name,
+		State:           TaskStateSubmitted,
+		SystemPrompt:    systemPrompt,
+		Messages:        messages,
+		Artifacts:       make(map[string]*Artifact),
+		CreatedAt:       now,
+		CreatedAtUnixMs: now.UnixMilli(),
+		UpdatedAt:       now,
+		UpdatedAtUnixMs: now.UnixMilli(),
+		ParentTaskID:    parentTaskID,
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := saveSqliteTask(s.db, task); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+func (s *SQLiteTaskStore) GetTask(taskID string) (*Task, error) {
+	return loadSqliteTask(s.db, taskID)
+}
+
+// update runs fn on the task in one transaction and saves the result.
+func (s *SQLiteTaskStore) update(taskID string, fn func(tx *sql.Tx, task *Task) error) (*Task, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin the update of task %s: %w", taskID, err)
+	}
+	defer tx.Rollback()
+	task, err := loadSqliteTask(tx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(tx, task); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	task.UpdatedAt, task.UpdatedAtUnixMs = now, now.UnixMilli()
+	if err := saveSqliteTask(tx, task); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit the update of task %s: %w", taskID, err)
+	}
+	return task, nil
+}
+
+func (s *SQLiteTaskStore) UpdateTask(taskID string, updateFn func(*Task) error) (*Task, error) {
+	return s.update(taskID, func(_ *sql.Tx, task *Task) error {
+		if err := updateFn(task); err != nil {
+			return fmt.Errorf("update function failed for task %s: %w", taskID, err)
+		}
+		return nil
+	})
+}
+
+func (s *SQLiteTaskStore) SetState(taskID string, state TaskState) error {
+	_, err := s.UpdateTask(taskID, func(task *Task) error {
+		task.State = state
+		return nil
+	})
+	return err
+}
+
+func (s *SQLiteTaskStore) AddMessage(taskID string, message Message) error {
+	_, err := s.UpdateTask(taskID, func(task *Task) error {
+		now := time.Now().UTC()
+		message.Timestamp, message.TimestampUnixMs = now, now.UnixMilli()
+		task.Messages = append(task.Messages, message)
+		return nil
+	})
+	return err
+}
+
+func (s *SQLiteTaskStore) AddArtifact(taskID string, artifact Artifact) error {
+	if artifact.ID == "" {
+		artifact.ID = uuid.NewString()
+	}
+	_, err := s.update(taskID, func(tx *sql.Tx, task *Task) error {
+		if task.Artifacts == nil {
+			task.Artifacts = make(map[string]*Artifact)
+		}
+		if old, ok := task.Artifacts[artifact.ID]; ok && old.Streamed {
+			if _, err := tx.Exec(`DELETE FROM artifact_chunks WHERE task_id = ? AND artifact_id = ?`, taskID, artifact.ID); err != nil {
+				return fmt.Errorf("failed to replace artifact %s of task %s: %w", artifact.ID, taskID, err)
+			}
+		}
+		artCopy := artifact
+		if artCopy.Hints == nil {
+			artCopy.Hints = computeRenderHints(artCopy)
+		}
+		task.Artifacts[artCopy.ID] = &artCopy
+		return nil
+	})
+	return err
+}
+
+// AppendArtifact implements ArtifactAppender, storing the chunk as a row of
+// its own so the task document stays small.
+func (s *SQLiteTaskStore) AppendArtifact(taskID string, meta Artifact, chunk []byte) error {
+	if meta.ID == "" {
+		return fmt.Errorf("invalid artifact ID %q", meta.ID)
+	}
+	_, err := s.update(taskID, func(tx *sql.Tx, task *Task) error {
+		if task.Artifacts == nil {
+			task.Artifacts = make(map[string]*Artifact)
+		}
+		artifact, ok := task.Artifacts[meta.ID]
+		if !ok {
+			artCopy := meta
+			artCopy.Data, artCopy.Streamed, artCopy.Size = nil, true, 0
+			artifact = &artCopy
+			task.Artifacts[meta.ID] = artifact
+		}
+		_, err := tx.Exec(`INSERT INTO artifact_chunks (task_id, artifact_id, seq, data)
+			VALUES (?, ?, (SELECT COALESCE(MAX(seq), 0) + 1 FROM artifact_chunks WHERE task_id = ? AND artifact_id = ?), ?)`,
+			taskID, meta.ID, taskID, meta.ID, chunk)
+		if err != nil {
+			return fmt.Errorf("failed to append to artifact %s of task %s: %w", meta.ID, taskID, err)
+		}
+		artifact.Size += int64(len(chunk))
+		if artifact.Hints == nil {
+			artifact.Hints = computeRenderHints(Artifact{Type: artifact.Type, Filename: artifact.Filename, Data: chunk})
+		}
+		return nil
+	})
+	return err
+}
+
+func (s *SQLiteTaskStore) GetArtifactData(taskID string, artifactID string) ([]byte, *Artifact, error) {
+	task, err := s.GetTask(taskID)
+	if err != nil {
+		return nil, nil, err
+	}
+	artifact, ok := task.Artifacts[artifactID]
+	if !ok {
+		return nil, nil, fmt.Errorf("artifact %s not found in task %s", artifactID, taskID)
+	}
+	if !artifact.Streamed {
+		return artifact.Data, artifact, nil
+	}
+
+	rows, err := s.db.Query(`SELECT data FROM artifact_chunks WHERE task_id = ? AND artifact_id = ? ORDER BY seq`, taskID, artifactID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read artifact %s of task %s: %w", artifactID, taskID, err)
+	}
+	defer rows.Close()
+	data := make([]byte, 0, artifact.Size)
+	for rows.Next() {
+		var chunk []byte
+		if err := rows.Scan(&chunk); err != nil {
+			return nil, nil, fmt.Errorf("failed to read artifact %s of task %s: %w", artifactID, taskID, err)
+		}
+		data = append(data, chunk...)
+	}
+	return data, artifact, rows.Err()
+}
+
+func (s *SQLiteTaskStore) ListTasks() ([]*Task, error) {
+	var tasks []*Task
+	err := s.WalkTasks(func(task *Task) error {
+		tasks = append(tasks, task)
+		return nil
+	})
+	return tasks, err
+}
+
+// WalkTasks implements TaskWalker, newest first. Tasks are loaded a page at
+// a time and fn runs with no query open, so it may call back into the store,
+// even to delete the task it was given.
+func (s *SQLiteTaskStore) WalkTasks(fn func(*Task) error) error {
+	var after *sqliteCursor
+	for {
+		tasks, err := s.page(TaskPageQuery{Limit: taskPageBatch}, after)
+		if err != nil {
+			return err
+		}
+		for _, task := range tasks {
+			if err := fn(task); err != nil {
+				return err
+			}
+		}
+		if len(tasks) < taskPageBatch {
+			return nil
+		}
+		last := tasks[len(tasks)-1]
+		after = &sqliteCursor{createdAt: last.CreatedAt.UnixNano(), id: last.ID}
+	}
+}
+
+// sqliteCursor is the position after which a page starts.
+type sqliteCursor struct {
+	createdAt int64
+	id        string
+}
+
+// ListTasksPage implements TaskPager with an indexed query.
+func (s *SQLiteTaskStore) ListTasksPage(query TaskPageQuery) ([]*Task, error) {
+	var after *sqliteCursor
+	if query.After != "" {
+		after = &sqliteCursor{id: query.After}
+		err := s.db.QueryRow(`SELECT created_at FROM tasks WHERE id = ?`, query.After).Scan(&after.createdAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w: cursor task %s", ErrTaskNotFound, query.After)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve cursor task %s: %w", query.After, err)
+		}
+	}
+	return s.page(query, after)
+}
+
+func (s *SQLiteTaskStore) page(query TaskPageQuery, after *sqliteCursor) ([]*Task, error) {
+	var where []string
+	var args []any
+	if query.State != "" {
+		where = append(where, "state = ?")
+		args = append(args, string(query.State))
+	}
+	if query.ParentTaskID != nil {
+		where = append(where, "parent_task_id = ?")
+		args = append(args, *query.ParentTaskID)
+	}
+	if after != nil {
+		where = append(where, "(created_at < ? OR (created_at = ? AND id < ?))")
+		args = append(args, after.createdAt, after.createdAt, after.id)
+	}
+	statement := "SELECT id, data FROM tasks"
+	if len(where) > 0 {
+		statement += " WHERE " + strings.Join(where, " AND ")
+	}
+	statement += " ORDER BY created_at DESC, id DESC"
+	if query.Limit > 0 {
+		statement += " LIMIT ?"
+		args = append(args, query.Limit)
+	}
+
+	rows, err := s.db.Query(statement, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tasks: %w", err)
+	}
+	defer rows.Close()
+	var tasks []*Task
+	for rows.Next() {
+		var id string
+		var data []byte
+		if err := rows.Scan(&id, &data); err != nil {
+			return nil, fmt.Errorf("failed to list tasks: %w", err)
+		}
+		var task Task
+		if err := json.Unmarshal(data, &task); err != nil {
+			log.Printf("[SQLiteTaskStore] Skipping task %s that cannot be decoded: %v", id, err)
+			continue
+		}
+		tasks = append(tasks, &task)
+	}
+	return tasks, rows.Err()
+}
+
+func (s *SQLiteTaskStore) DeleteTask(taskID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin the deletion of task %s: %w", taskID, err)
+	}
+	defer tx.Rollback()
+	result, err := tx.Exec(`DELETE FROM tasks WHERE id = ?`, taskID)
+	if err != nil {
+		return fmt.Errorf("failed to delete task %s: %w", taskID, err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrTaskNotFound
+	}
+	if _, err := tx.Exec(`DELETE FROM artifact_chunks WHERE task_id = ?`, taskID); err != nil {
+		return fmt.Errorf("failed to delete the artifacts of task %s: %w", taskID, err)
+	}
+	if _, err := tx.Exec(`DELETE FROM task_events WHERE task_id = ?`, taskID); err != nil {
+		return fmt.Errorf("failed to delete the event log of task %s: %w", taskID, err)
+	}
+	return tx.Commit()
+}
+
+// AppendTaskEvent implements TaskEventLog.
+func (s *SQLiteTaskStore) AppendTaskEvent(taskID string, entry *TaskLogEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var exists int
+	if err := s.db.QueryRow(`SELECT COUNT(*) FROM tasks WHERE id = ?`, taskID).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to read task %s: %w", taskID, err)
+	}
+	if exists == 0 {
+		return ErrTaskNotFound
+	}
+	if err := s.db.QueryRow(`SELECT COALESCE(MAX(seq), 0) + 1 FROM task_events WHERE task_id = ?`, taskID).Scan(&entry.Seq); err != nil {
+		return fmt.Errorf("failed to read the event log of task %s: %w", taskID, err)
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event of task %s: %w", taskID, err)
+	}
+	if _, err := s.db.Exec(`INSERT INTO task_events (task_id, seq, data) VALUES (?, ?, ?)`, taskID, entry.Seq, data); err != nil {
+		return fmt.Errorf("failed to append to the event log of task %s: %w", taskID, err)
+	}
+	return nil
+}
+
+// ListTaskEvents implements TaskEventLog.
+func (s *SQLiteTaskStore) ListTaskEvents(taskID string, after int64, limit int) ([]TaskLogEntry, error) {
+	rows, err := s.db.Query(`SELECT data FROM task_events WHERE task_id = ? AND seq > ? ORDER BY seq LIMIT ?`, taskID, after, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the event log of task %s: %w", taskID, err)
+	}
+	defer rows.Close()
+	var events []TaskLogEntry
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var entry TaskLogEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return nil, fmt.Errorf("failed to decode event of task %s: %w", taskID, err)
+		}
+		events = append(events, entry)
+	}
+	return events, rows.Err()
+}
+
+// SaveDeadLetter implements DeadLetterStore.
+func (s *SQLiteTaskStore) SaveDeadLetter(letter *DeadLetter) error {
+	data, err := json.Marshal(letter)
+	if err != nil {
+		return fmt.Errorf("failed to marshal dead letter %s: %w", letter.ID, err)
+	}
+	_, err = s.db.Exec(`INSERT INTO dead_letters (id, created_at, data) VALUES (?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET data = excluded.data`, letter.ID, letter.CreatedAt.UnixNano(), data)
+	if err != nil {
+		return fmt.Errorf("failed to save dead letter %s: %w", letter.ID, err)
+	}
+	return nil
+}
+
+// DeleteDeadLetter implements DeadLetterStore.
+func (s *SQLiteTaskStore) DeleteDeadLetter(id string) error {
+	if _, err := s.db.Exec(`DELETE FROM dead_letters WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete dead letter %s: %w", id, err)
+	}
+	return nil
+}
+
+// ListDeadLetters implements DeadLetterStore.
+func (s *SQLiteTaskStore) ListDeadLetters() ([]*DeadLetter, error) {
+	rows, err := s.db.Query(`SELECT data FROM dead_letters ORDER BY created_at`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead letters: %w", err)
+	}
+	defer rows.Close()
+	var letters []*DeadLetter
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var letter DeadLetter
+		if err := json.Unmarshal(data, &letter); err != nil {
+			return nil, fmt.Errorf("failed to decode dead letter: %w", err)
+		}
+		letters = append(letters, &letter)
+	}
+	return letters, rows.Err()
+}
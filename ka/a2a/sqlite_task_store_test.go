@@ -0,0 +1,197 @@
+package a2a
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func newTestSQLiteStore(t *testing.T) *SQLiteTaskStore {
+	t.Helper()
+	store, err := NewSQLiteTaskStore(filepath.Join(t.TempDir(), "tasks.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteTaskStore: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestSQLiteTaskStoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.db")
+	store, err := NewSQLiteTaskStore(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteTaskStore: %v", err)
+	}
+	task, err := store.CreateTask("stored", "system", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "hi"}}}}, "parent")
+	if err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+	if err := store.SetState(task.ID, TaskStateWorking); err != nil {
+		t.Fatalf("SetState: %v", err)
+	}
+	if err := store.AddMessage(task.ID, Message{Role: RoleAssistant, Parts: []Part{TextPart{Type: "text", Text: "hello"}}}); err != nil {
+		t.Fatalf("AddMessage: %v", err)
+	}
+	if err := store.AddArtifact(task.ID, Artifact{ID: "plain", Type: "text/plain", Data: []byte("result")}); err != nil {
+		t.Fatalf("AddArtifact: %v", err)
+	}
+	for _, chunk := range []string{"line 1\n", "line 2\n"} {
+		if err := store.AppendArtifact(task.ID, Artifact{ID: "log", Type: "text/plain"}, []byte(chunk)); err != nil {
+			t.Fatalf("AppendArtifact: %v", err)
+		}
+	}
+	store.Close()
+
+	// Everything survives reopening the database
+	store, err = NewSQLiteTaskStore(path)
+	if err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	defer store.Close()
+	got, err := store.GetTask(task.ID)
+	if err != nil {
+		t.Fatalf("GetTask: %v", err)
+	}
+	if got.State != TaskStateWorking || got.ParentTaskID != "parent" || len(got.Messages) != 2 || got.Messages[1].Timestamp.IsZero() {
+		t.Errorf("unexpected task %+v", got)
+	}
+	if data, _, err := store.GetArtifactData(task.ID, "plain"); err != nil || string(data) != "result" {
+		t.Errorf("expected the plain artifact, got %q / %v", data, err)
+	}
+	data, artifact, err := store.GetArtifactData(task.ID, "log")
+	if err != nil || string(data) != "line 1\nline 2\n" || !artifact.Streamed || artifact.Size != int64(len(data)) {
+		t.Errorf("expected the streamed artifact, got %q / %+v / %v", data, artifact, err)
+	}
+
+	if err := store.DeleteTask(task.ID); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	if _, err := store.GetTask(task.ID); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("expected ErrTaskNotFound after deleting, got %v", err)
+	}
+	if err := store.DeleteTask(task.ID); !errors.Is(err, ErrTaskNotFound) {
+		t.Errorf("expected ErrTaskNotFound deleting twice, got %v", err)
+	}
+}
+
+func TestSQLiteTaskStoreWalkAllowsDeleting(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	for i := 0; i < taskPageBatch+5; i++ {
+		if _, err := store.CreateTask(fmt.Sprintf("task-%d", i), "", nil, ""); err != nil {
+			t.Fatalf("CreateTask: %v", err)
+		}
+	}
+	visited := 0
+	err := store.WalkTasks(func(task *Task) error {
+		visited++
+		return store.DeleteTask(task.ID)
+	})
+	if err != nil || visited != taskPageBatch+5 {
+		t.Fatalf("expected to visit and delete every task, visited %d / %v", visited, err)
+	}
+	if tasks, _ := store.ListTasks(); len(tasks) != 0 {
+		t.Errorf("expected no tasks left, got %d", len(tasks))
+	}
+}
+
+func TestSQLiteTaskStoreConfiguresEveryConnection(t *testing.T) {
+	store := newTestSQLiteStore(t)
+	ctx := context.Background()
+	// Hold the first connection so the pool has to open a second one
+	first, err := store.db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("Conn: %v", err)
+	}
+	defer first.Close()
+	second, err := store.db.Conn(ctx)
+	if err != nil {
+		t.Fatalf("Conn: %v", err)
+	}
+	defer second.Close()
+	for i, conn := range []interface {
+		QueryRowContext(context.Context, string, ...interface{}) *sql.Row
+	}{first, second} {
+		var timeout int
+		if err := conn.QueryRowContext(ctx, "PRAGMA busy_timeout").Scan(&timeout); err != nil {
+			t.Fatalf("connection %d: %v", i, err)
+		}
+		var mode string
+		if err := conn.QueryRowContext(ctx, "PRAGMA journal_mode").Scan(&mode); err != nil {
+			t.Fatalf("connection %d: %v", i, err)
+		}
+		if timeout != 5000 || mode != "wal" {
+			t.Errorf("connection %d: expected busy_timeout 5000 and wal, got %d and %s", i, timeout, mode)
+		}
+	}
+}
+
+func TestTasksListPages(t *testing.T) {
+	for name, store := range map[string]TaskStore{
+		"sqlite":   newTestSQLiteStore(t),
+		"fallback": NewInMemoryTaskStore(),
+	} {
+		t.Run(name, func(t *testing.T) {
+			parent, _ := store.CreateTask("parent", "", nil, "")
+			var completed []string
+			for i := 0; i < 5; i++ {
+				task, _ := store.CreateTask(fmt.Sprintf("child-%d", i), "", nil, parent.ID)
+				if i%2 == 0 {
+					store.SetState(task.ID, TaskStateCompleted)
+					completed = append([]string{task.ID}, completed...) // Newest first
+				}
+			}
+
+			var listed []string
+			cursor := ""
+			for page := 0; page < 3; page++ {
+				result, rpcErr := listTasks(t, store, fmt.Sprintf(`{"state": "COMPLETED", "limit": 2, "cursor": %q}`, cursor))
+				if rpcErr != nil {
+					t.Fatalf("page %d: %+v", page, rpcErr)
+				}
+				for _, summary := range result {
+					listed = append(listed, summary["id"].(string))
+				}
+				if len(result) < 2 {
+					break
+				}
+				cursor = listed[len(listed)-1]
+			}
+			if fmt.Sprint(listed) != fmt.Sprint(completed) {
+				t.Errorf("expected the completed tasks newest first %v, got %v", completed, listed)
+			}
+
+			result, _ := listTasks(t, store, `{"parentTaskId": "", "limit": 10}`)
+			if len(result) != 1 || result[0]["id"] != parent.ID {
+				t.Errorf("expected only the top-level task, got %v", result)
+			}
+			if _, rpcErr := listTasks(t, store, `{"limit": 2, "cursor": "missing"}`); rpcErr == nil {
+				t.Errorf("expected an unknown cursor to be an error")
+			}
+		})
+	}
+}
+
+func TestSQLiteTaskStoreReportsMigrations(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tasks.db")
+	store, err := NewSQLiteTaskStore(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteTaskStore: %v", err)
+	}
+	if migrations := store.Migrations(); len(migrations) != 4 || migrations[0] != "created table tasks" {
+		t.Errorf("expected a new database to create its tables, got %v", migrations)
+	}
+	store.db.Exec(`DROP TABLE dead_letters`)
+	store.Close()
+
+	reopened, err := NewSQLiteTaskStore(path)
+	if err != nil {
+		t.Fatalf("NewSQLiteTaskStore: %v", err)
+	}
+	defer reopened.Close()
+	if migrations := reopened.Migrations(); len(migrations) != 1 || migrations[0] != "created table dead_letters" {
+		t.Errorf("expected only the missing table to be created, got %v", migrations)
+	}
+}
@@ -0,0 +1,478 @@
+package a2a
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"ka/tools"
+)
+
+// Reconnection defaults of StreamClient.
+const (
+	DefaultStreamReconnects     = 5           // Reconnects in a row without a new event before the stream fails
+	DefaultStreamReconnectDelay = time.Second // Before reconnecting, unless the agent sent a retry: field
+)
+
+// SSEFrame is one event of a Server-Sent Events stream.
+type SSEFrame struct {
+	ID    string // Last event id of the stream, as set by the latest id: field
+	Event string // "message" when the frame has no event: field
+	Data  string // Lines of the data: fields, joined by newlines
+}
+
+// SSEDecoder reads the frames of a Server-Sent Events stream as the
+// EventSource specification describes them: comments are skipped, multi-line
+// data is joined, and the id: and retry: fields persist across frames.
+type SSEDecoder struct {
+	r           *bufio.Reader
+	lastEventID string
+	retry       time.Duration
+}
+
+// NewSSEDecoder returns a decoder reading frames from r.
+func NewSSEDecoder(r io.Reader) *SSEDecoder {
+	return &SSEDecoder{r: bufio.NewReaderSize(r, 64*1024)}
+}
+
+// Retry returns the reconnection delay the stream asked for, or 0.
+func (d *SSEDecoder) Retry() time.Duration {
+	return d.retry
+}
+
+// Next returns the next frame with data. It returns io.EOF when the stream
+// ended, and io.ErrUnexpectedEOF when it ended inside a frame.
+func (d *SSEDecoder) Next() (SSEFrame, error) {
+	var event string
+	var data []string
+	pending := false // A field of the current frame was read
+	for {
+		line, err := d.r.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			if err == io.EOF && pending {
+				err = io.ErrUnexpectedEOF
+			}
+			return SSEFrame{}, err
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+		if line == "" {
+			if data == nil {
+				event, pending = "", false // Frames without data are not dispatched
+				continue
+			}
+			if event == "" {
+				event = "message"
+			}
+			return SSEFrame{ID: d.lastEventID, Event: event, Data: strings.Join(data, "\n")}, nil
+		}
+		if strings.HasPrefix(line, ":") {
+			continue // Comment, e.g. a keepalive
+		}
+		pending = true
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			data = append(data, value)
+		case "id":
+			if !strings.Contains(value, "\x00") {
+				d.lastEventID = value
+			}
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms >= 0 {
+				d.retry = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+}
+
+// StreamEvent is a typed event of a task stream: *StateEvent, *DeltaEvent,
+// *ToolEvent, *ArtifactEvent, *CodeBlockEvent, *SubTaskStatus, *InfoEvent,
+// or *RawEvent for events this client does not know.
+type StreamEvent interface {
+	EventType() string
+}
+
+// StateEvent is the data of a state event, sent when the task changes state.
+type StateEvent struct {
+	TaskID           string               `json:"task_id,omitempty"` // Filled in by EventStream when the agent leaves it out
+	Status           TaskState            `json:"status"`
+	Error            string               `json:"error,omitempty"`
+	ErrorDetail      *ErrorDetail         `json:"errorDetail,omitempty"`
+	Truncated        bool                 `json:"truncated,omitempty"`        // The answer hit the output token limit
+	Artifacts        []CompletionArtifact `json:"artifacts,omitempty"`        // Of a completed task, with -inline-artifact-bytes
+	InputRequest     *tools.InputForm     `json:"inputRequest,omitempty"`     // Question of an INPUT_REQUIRED task
+	Approvals        []ToolApproval       `json:"approvals,omitempty"`        // Tool calls waiting for tasks/approve
+	EventWait        *EventWait           `json:"eventWait,omitempty"`        // Event a WAITING_EVENT task waits for
+	PendingToolCalls json.RawMessage      `json:"pendingToolCalls,omitempty"` // Tool calls a STEP_WAIT task will dispatch
+}
+
+func (*StateEvent) EventType() string { return "state" }
+
+// Final reports whether the run ended with this state, which also ends the
+// stream: the task finished, failed, or waits for input or an event.
+func (e *StateEvent) Final() bool {
+	switch TaskState(strings.ToUpper(string(e.Status))) {
+	case "", TaskStateSubmitted, TaskStateWorking, TaskStateStepWait, TaskStateStalled:
+		return false
+	}
+	return true
+}
+
+// DeltaEvent is the data of a message event: a chunk of the streamed
+// assistant output.
+type DeltaEvent struct {
+	Chunk string `json:"chunk"`
+}
+
+func (*DeltaEvent) EventType() string { return "message" }
+
+// ToolEvent is the data of a progress or tool_output event of a running
+// tool call; one of Progress and Output is set.
+type ToolEvent struct {
+	TaskID   string              `json:"taskId"`
+	Progress *tools.ToolProgress `json:"progress,omitempty"`
+	Output   *tools.ToolOutput   `json:"output,omitempty"`
+}
+
+func (e *ToolEvent) EventType() string {
+	if e.Output != nil {
+		return "tool_output"
+	}
+	return "progress"
+}
+
+// ArtifactEvent is an artifact listed by the final state event of a
+// completed task. EventStream returns one after that state event for each.
+type ArtifactEvent struct {
+	TaskID string `json:"taskId"`
+	CompletionArtifact
+}
+
+func (*ArtifactEvent) EventType() string { return "artifact" }
+
+// Content returns the data of an inlined artifact; ok is false for
+// artifacts that must be fetched from their URL.
+func (e *ArtifactEvent) Content() (data []byte, ok bool, err error) {
+	if e.URL != "" && e.Data == "" {
+		return nil, false, nil
+	}
+	data, err = base64.StdEncoding.DecodeString(e.Data)
+	return data, err == nil, err
+}
+
+// InfoEvent is the data of an info event, e.g. of a created sub-task.
+type InfoEvent struct {
+	Type         string `json:"type"`
+	ParentTaskID string `json:"parentTaskId,omitempty"`
+	NewTaskID    string `json:"newTaskId,omitempty"`
+	NewTaskName  string `json:"newTaskName,omitempty"`
+}
+
+func (*InfoEvent) EventType() string { return "info" }
+
+// RawEvent is an event of a type this client does not decode.
+type RawEvent struct {
+	Event string
+	Data  string
+}
+
+func (e *RawEvent) EventType() string { return e.Event }
+
+func (*CodeBlockEvent) EventType() string { return codeBlockEvent }
+
+func (*SubTaskStatus) EventType() string { return "sub_task_status" }
+
+// DecodeStreamEvent decodes the data of a task stream frame into its typed
+// event. Unknown events are returned as *RawEvent.
+func DecodeStreamEvent(frame SSEFrame) (StreamEvent, error) {
+	var event StreamEvent
+	switch frame.Event {
+	case "state":
+		event = &StateEvent{}
+	case "message":
+		event = &DeltaEvent{}
+	case "progress", "tool_output":
+		event = &ToolEvent{}
+	case codeBlockEvent:
+		event = &CodeBlockEvent{}
+	case "sub_task_status":
+		event = &SubTaskStatus{}
+	case "info":
+		event = &InfoEvent{}
+	default:
+		return &RawEvent{Event: frame.Event, Data: frame.Data}, nil
+	}
+	if err := json.Unmarshal([]byte(frame.Data), event); err != nil {
+		return nil, fmt.Errorf("malformed %s event: %w", frame.Event, err)
+	}
+	return event, nil
+}
+
+// StreamClient starts and follows the event streams of tasks on an agent.
+// Streams that break before the run ended are resumed with
+// tasks/resubscribe from the last event received, so no event is lost or
+// repeated while the agent still keeps it for replay.
+type StreamClient struct {
+	URL            string        // JSON-RPC endpoint of the agent
+	APIKey         string        // Sent as X-API-Key, if set
+	HTTPClient     *http.Client  // http.DefaultClient when nil
+	MaxReconnects  int           // DefaultStreamReconnects when 0; negative disables reconnecting
+	ReconnectDelay time.Duration // DefaultStreamReconnectDelay when 0
+
+	requestID atomic.Int64
+}
+
+// SendSubscribe creates a task with tasks/sendSubscribe and returns its
+// event stream. Errors of the agent are returned as *StreamRequestError.
+func (c *StreamClient) SendSubscribe(ctx context.Context, params SendTaskParams) (*EventStream, error) {
+	s := &EventStream{client: c, ctx: ctx}
+	if err := s.open("tasks/sendSubscribe", params); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Resubscribe reattaches to the stream of a task after the event with id
+// lastEventID; an empty id replays all events the agent kept.
+func (c *StreamClient) Resubscribe(ctx context.Context, taskID, lastEventID string) (*EventStream, error) {
+	s := &EventStream{client: c, ctx: ctx, TaskID: taskID, LastEventID: lastEventID}
+	if err := s.open("tasks/resubscribe", ResubscribeParams{ID: taskID, LastEventID: lastEventID}); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// StreamRequestError is a JSON-RPC error the agent answered a stream
+// request with.
+type StreamRequestError struct {
+	Method string
+	JSONRPCError
+}
+
+func (e *StreamRequestError) Error() string {
+	return fmt.Sprintf("%s failed: %s (code %d)", e.Method, e.Message, e.Code)
+}
+
+// EventStream is the event stream of a task. It is not safe for concurrent
+// use.
+type EventStream struct {
+	TaskID       string
+	LastEventID  string // Id of the last event received, to resume from
+	MissedEvents int64  // Events the agent no longer kept when the stream was resumed
+	Reconnects   int    // Times the stream was resumed
+
+	client   *StreamClient
+	failures int // Reconnects since the last new event
+	ctx      context.Context
+	body     io.ReadCloser
+	decoder  *SSEDecoder
+	retry    time.Duration // Latest retry: of the agent
+	pending  []StreamEvent // Artifact events of the final state
+	finished bool
+}
+
+// Next returns the next event of the task. It returns io.EOF after the
+// final state event, and an error once the stream cannot be resumed. A
+// malformed event is returned as an error too; the stream goes on with the
+// next one.
+func (s *EventStream) Next() (StreamEvent, error) {
+	for {
+		if len(s.pending) > 0 {
+			event := s.pending[0]
+			s.pending = s.pending[1:]
+			return event, nil
+		}
+		if s.finished {
+			return nil, io.EOF
+		}
+
+		frame, err := s.decoder.Next()
+		if err == nil {
+			if frame.ID != "" && frame.ID != s.LastEventID {
+				s.LastEventID, s.failures = frame.ID, 0
+			}
+			if frame.Event == rpcResponseEvent {
+				continue
+			}
+			event, err := DecodeStreamEvent(frame)
+			if err != nil {
+				return nil, err
+			}
+			if state, ok := event.(*StateEvent); ok {
+				if state.TaskID == "" {
+					state.TaskID = s.TaskID
+				}
+				if state.Final() {
+					s.finish()
+					for _, artifact := range state.Artifacts {
+						s.pending = append(s.pending, &ArtifactEvent{TaskID: state.TaskID, CompletionArtifact: artifact})
+					}
+				}
+			}
+			return event, nil
+		}
+
+		// The stream ended without a final state or broke
+		s.body.Close()
+		if ctxErr := s.ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if s.TaskID == "" {
+			return nil, fmt.Errorf("stream ended before the task was created: %w", err)
+		}
+		if retry := s.decoder.Retry(); retry > 0 {
+			s.retry = retry
+		}
+		if err := s.reconnect(err); err != nil {
+			s.finished = true
+			return nil, err
+		}
+	}
+}
+
+// Close closes the connection. The run continues on the agent for its
+// resubscribe grace period.
+func (s *EventStream) Close() error {
+	s.finished = true
+	if s.body == nil {
+		return nil
+	}
+	return s.body.Close()
+}
+
+func (s *EventStream) finish() {
+	s.finished = true
+	s.body.Close()
+}
+
+// reconnect resumes the stream with tasks/resubscribe after it broke with
+// cause. A stream that keeps breaking without new events, e.g. of a task
+// whose run is gone, fails after MaxReconnects attempts.
+func (s *EventStream) reconnect(cause error) error {
+	limit := s.client.MaxReconnects
+	if limit == 0 {
+		limit = DefaultStreamReconnects
+	}
+	for ; s.failures < limit; s.failures++ {
+		delay := s.retry
+		if delay == 0 {
+			delay = s.client.ReconnectDelay
+		}
+		if delay == 0 {
+			delay = DefaultStreamReconnectDelay
+		}
+		select {
+		case <-time.After(delay):
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		}
+		err := s.open("tasks/resubscribe", ResubscribeParams{ID: s.TaskID, LastEventID: s.LastEventID})
+		if err == nil {
+			s.failures++
+			s.Reconnects++
+			return nil
+		}
+		if _, rejected := err.(*StreamRequestError); rejected {
+			return err // E.g. the task was deleted; retrying does not help
+		}
+		cause = err
+	}
+	return fmt.Errorf("stream of task %s broke and could not be resumed: %w", s.TaskID, cause)
+}
+
+// open sends a stream request and reads its rpc-response event.
+func (s *EventStream) open(method string, params interface{}) error {
+	body, err := json.Marshal(JSONRPCRequest{Jsonrpc: "2.0", ID: s.client.requestID.Add(1), Method: method, Params: mustMarshal(params)})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(s.ctx, http.MethodPost, s.client.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid agent URL %s: %w", s.client.URL, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	if s.LastEventID != "" {
+		req.Header.Set("Last-Event-ID", s.LastEventID)
+	}
+	if s.client.APIKey != "" {
+		req.Header.Set("X-API-Key", s.client.APIKey)
+	}
+	httpClient := s.client.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach agent %s: %w", s.client.URL, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		return fmt.Errorf("agent %s returned %s: %s", s.client.URL, resp.Status, strings.TrimSpace(string(message)))
+	}
+
+	// Errors before the stream starts are plain JSON-RPC responses
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		defer resp.Body.Close()
+		var rpcResp struct {
+			Error *JSONRPCError `json:"error"`
+		}
+		if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&rpcResp); err != nil || rpcResp.Error == nil {
+			return fmt.Errorf("agent %s answered %s without an event stream", s.client.URL, method)
+		}
+		return &StreamRequestError{Method: method, JSONRPCError: *rpcResp.Error}
+	}
+
+	decoder := NewSSEDecoder(resp.Body)
+	frame, err := decoder.Next()
+	if err != nil || frame.Event != rpcResponseEvent {
+		resp.Body.Close()
+		return fmt.Errorf("agent %s did not open the %s stream with an %s event", s.client.URL, method, rpcResponseEvent)
+	}
+	var rpcResp struct {
+		Result struct {
+			ID           string `json:"id"`
+			MissedEvents int64  `json:"missedEvents"`
+		} `json:"result"`
+		Error *JSONRPCError `json:"error"`
+	}
+	if err := json.Unmarshal([]byte(frame.Data), &rpcResp); err != nil || rpcResp.Error != nil {
+		resp.Body.Close()
+		if rpcResp.Error != nil {
+			return &StreamRequestError{Method: method, JSONRPCError: *rpcResp.Error}
+		}
+		return fmt.Errorf("malformed %s event: %w", rpcResponseEvent, err)
+	}
+
+	if s.body != nil {
+		s.body.Close()
+	}
+	s.body, s.decoder = resp.Body, decoder
+	if rpcResp.Result.ID != "" {
+		s.TaskID = rpcResp.Result.ID
+	}
+	s.MissedEvents += rpcResp.Result.MissedEvents
+	if retry := decoder.Retry(); retry > 0 {
+		s.retry = retry
+	}
+	return nil
+}
+
+func mustMarshal(v interface{}) json.RawMessage {
+	data, _ := json.Marshal(v)
+	return data
+}
@@ -0,0 +1,146 @@
+package a2a
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSSEDecoderParsesFrames(t *testing.T) {
+	stream := "retry: 250\r\n\r\n: keepalive\n\nid: 7\nevent: state\ndata: {\"status\":\ndata: \"WORKING\"}\n\ndata: plain\n\nid: 8\nevent: message\ndata: {\"chunk\":\"x\"}"
+	decoder := NewSSEDecoder(strings.NewReader(stream))
+
+	frame, err := decoder.Next()
+	if err != nil || frame != (SSEFrame{ID: "7", Event: "state", Data: "{\"status\":\n\"WORKING\"}"}) {
+		t.Fatalf("expected the multi-line state frame, got %+v, %v", frame, err)
+	}
+	if decoder.Retry() != 250*time.Millisecond {
+		t.Errorf("expected the retry delay to be kept, got %s", decoder.Retry())
+	}
+	frame, _ = decoder.Next()
+	if frame != (SSEFrame{ID: "7", Event: "message", Data: "plain"}) {
+		t.Errorf("expected the id to persist and the event to default to message, got %+v", frame)
+	}
+	if _, err := decoder.Next(); err != io.ErrUnexpectedEOF {
+		t.Errorf("expected a frame cut off by the end of the stream to fail, got %v", err)
+	}
+}
+
+func TestDecodeStreamEventTypes(t *testing.T) {
+	for frame, want := range map[SSEFrame]string{
+		{Event: "state", Data: `{"status":"FAILED","error":"boom","errorDetail":{"code":"internal","message":"boom"}}`}: "state",
+		{Event: "message", Data: `{"chunk":"hi"}`}: "message",
+		{Event: "tool_output", Data: `{"taskId":"t","output":{"tool":"execute_command","stream":"stdout","data":"ok"}}`}: "tool_output",
+		{Event: "progress", Data: `{"taskId":"t","progress":{"tool":"x","kind":"progress","progress":1}}`}:               "progress",
+		{Event: "code_block", Data: `{"taskId":"t","action":"open","index":0,"language":"go","offset":3}`}:               "code_block",
+		{Event: "custom", Data: `anything`}: "custom",
+	} {
+		event, err := DecodeStreamEvent(frame)
+		if err != nil || event.EventType() != want {
+			t.Errorf("expected %s to decode as %s, got %#v, %v", frame.Data, want, event, err)
+		}
+	}
+	if _, err := DecodeStreamEvent(SSEFrame{Event: "state", Data: "{"}); err == nil {
+		t.Errorf("expected a malformed event to fail")
+	}
+}
+
+func TestEventStreamResumesAfterDisconnect(t *testing.T) {
+	llmClient := &gatedLLMClient{release: make(chan struct{})}
+	te := NewTaskExecutor(llmClient, NewInMemoryTaskStore(), nil, "")
+	te.InlineArtifactBytes = 1024
+
+	drop := make(chan struct{})
+	resumedFrom := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req JSONRPCRequest
+		json.Unmarshal(body, &req)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		switch req.Method {
+		case "tasks/sendSubscribe":
+			// The first connection drops on request
+			ctx, cancel := context.WithCancel(r.Context())
+			go func() {
+				select {
+				case <-drop:
+				case <-ctx.Done():
+				}
+				cancel()
+			}()
+			TasksSendSubscribeHandler(te)(w, r.WithContext(ctx))
+		case "tasks/resubscribe":
+			resumedFrom <- r.Header.Get("Last-Event-ID")
+			TasksResubscribeHandler(te)(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := &StreamClient{URL: server.URL, ReconnectDelay: time.Millisecond}
+	stream, err := client.SendSubscribe(context.Background(), SendTaskParams{Message: Message{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "hi"}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.Close()
+	if stream.TaskID == "" {
+		t.Fatalf("expected the task id from the rpc-response event")
+	}
+
+	var chunks []string
+	var final *StateEvent
+	var artifacts []*ArtifactEvent
+	for {
+		event, err := stream.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		switch event := event.(type) {
+		case *DeltaEvent:
+			chunks = append(chunks, event.Chunk)
+			if event.Chunk == "first" {
+				close(drop)
+				go func() {
+					<-resumedFrom
+					close(llmClient.release) // Only once the client resumed
+				}()
+			}
+		case *StateEvent:
+			final = event
+		case *ArtifactEvent:
+			artifacts = append(artifacts, event)
+		}
+	}
+
+	if strings.Join(chunks, ",") != "first,second" || stream.Reconnects != 1 {
+		t.Errorf("expected each chunk once over one reconnect, got %v after %d reconnects", chunks, stream.Reconnects)
+	}
+	if final == nil || final.Status != TaskStateCompleted || final.TaskID != stream.TaskID {
+		t.Fatalf("expected the task to complete, got %+v", final)
+	}
+	if len(artifacts) != 1 {
+		t.Fatalf("expected the response artifact after the final state, got %+v", artifacts)
+	}
+	if data, ok, err := artifacts[0].Content(); !ok || err != nil || string(data) != "firstsecond" {
+		t.Errorf("expected the inlined response, got %q, %v", data, err)
+	}
+}
+
+func TestEventStreamReportsRejectedRequests(t *testing.T) {
+	te := NewTaskExecutor(&scriptedLLMClient{replies: []string{"unused"}}, NewInMemoryTaskStore(), nil, "")
+	server := httptest.NewServer(TasksResubscribeHandler(te))
+	defer server.Close()
+
+	_, err := (&StreamClient{URL: server.URL}).Resubscribe(context.Background(), "missing", "3")
+	if rejected, ok := err.(*StreamRequestError); !ok || rejected.Code != -32001 {
+		t.Errorf("expected the JSON-RPC error of the agent, got %v", err)
+	}
+}
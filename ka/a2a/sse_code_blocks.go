@@ -0,0 +1,118 @@
+package a2a
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// codeBlockEvent is the SSE event marking fenced code blocks in the streamed
+// assistant output.
+const codeBlockEvent = "code_block"
+
+// maxFenceLine bounds the bytes of a line kept to recognise a fence; longer
+// lines are code, not fences.
+const maxFenceLine = 256
+
+// CodeBlockEvent is the data of a code_block event. Clients can highlight a
+// block incrementally from its open event instead of re-parsing the output.
+type CodeBlockEvent struct {
+	TaskID       string `json:"taskId"`
+	Action       string `json:"action"`                 // "open" or "close"
+	Index        int    `json:"index"`                  // Of the block in the streamed answer, from 0
+	Language     string `json:"language,omitempty"`     // Info string of the opening fence, e.g. "go"
+	Offset       int    `json:"offset"`                 // Bytes into the answer where the code starts (open) or the line of the closing fence starts (close)
+	Unterminated bool   `json:"unterminated,omitempty"` // The answer ended inside the block
+}
+
+// codeBlockTracker passes streamed assistant output on to the SSE writer and
+// sends a code_block event whenever a line opens or closes a fenced code
+// block (``` or ~~~, as in CommonMark).
+type codeBlockTracker struct {
+	sw     *SSEWriter
+	taskID string
+
+	line      []byte // Current line, up to maxFenceLine bytes
+	long      bool   // The current line exceeded maxFenceLine
+	offset    int    // Bytes of output seen
+	lineStart int    // Offset of the current line
+	fence     string // Fence of the open block; empty outside blocks
+	blocks    int
+}
+
+func newCodeBlockTracker(sw *SSEWriter, taskID string) *codeBlockTracker {
+	return &codeBlockTracker{sw: sw, taskID: taskID}
+}
+
+// Write implements io.Writer.
+func (t *codeBlockTracker) Write(p []byte) (int, error) {
+	if _, err := t.sw.Write(p); err != nil {
+		return 0, err
+	}
+	for _, b := range p {
+		t.offset++
+		if b != '\n' {
+			if len(t.line) < maxFenceLine {
+				t.line = append(t.line, b)
+			} else {
+				t.long = true
+			}
+			continue
+		}
+		t.endLine()
+	}
+	return len(p), nil
+}
+
+// Close checks the last line and closes a block the answer left open.
+func (t *codeBlockTracker) Close() {
+	if len(t.line) > 0 {
+		t.endLine()
+	}
+	if t.fence != "" {
+		t.send(CodeBlockEvent{Action: "close", Index: t.blocks - 1, Offset: t.offset, Unterminated: true})
+		t.fence = ""
+	}
+}
+
+func (t *codeBlockTracker) endLine() {
+	line, long, start := strings.TrimRight(string(t.line), "\r"), t.long, t.lineStart
+	t.line, t.long, t.lineStart = t.line[:0], false, t.offset
+	if long {
+		return
+	}
+
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 || len(trimmed) < 3 || (trimmed[0] != '`' && trimmed[0] != '~') {
+		return
+	}
+	run := len(trimmed) - len(strings.TrimLeft(trimmed, trimmed[:1]))
+	if run < 3 {
+		return
+	}
+	fence, info := trimmed[:run], strings.TrimSpace(trimmed[run:])
+
+	if t.fence == "" {
+		if fence[0] == '`' && strings.Contains(info, "`") {
+			return // Inline code, not a fence
+		}
+		language := ""
+		if fields := strings.Fields(info); len(fields) > 0 {
+			language = fields[0]
+		}
+		t.fence = fence
+		t.send(CodeBlockEvent{Action: "open", Index: t.blocks, Language: language, Offset: t.offset})
+		t.blocks++
+		return
+	}
+	// A closing fence uses the opening character, at least as many times, and nothing else
+	if fence[0] == t.fence[0] && len(fence) >= len(t.fence) && info == "" {
+		t.fence = ""
+		t.send(CodeBlockEvent{Action: "close", Index: t.blocks - 1, Offset: start})
+	}
+}
+
+func (t *codeBlockTracker) send(event CodeBlockEvent) {
+	event.TaskID = t.taskID
+	data, _ := json.Marshal(event)
+	t.sw.SendEvent(codeBlockEvent, string(data))
+}
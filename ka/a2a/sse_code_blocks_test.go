@@ -0,0 +1,79 @@
+package a2a
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// codeBlockEvents returns the code_block events written to rec.
+func codeBlockEvents(t *testing.T, body string) []CodeBlockEvent {
+	t.Helper()
+	var events []CodeBlockEvent
+	for _, frame := range strings.Split(body, "\n\n") {
+		if !strings.HasPrefix(frame, "event: code_block\n") {
+			continue
+		}
+		var event CodeBlockEvent
+		if err := json.Unmarshal([]byte(strings.TrimPrefix(frame, "event: code_block\ndata: ")), &event); err != nil {
+			t.Fatalf("invalid code_block event %q: %v", frame, err)
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestCodeBlockTrackerMarksFences(t *testing.T) {
+	rec := httptest.NewRecorder()
+	sw, err := NewSSEWriter(rec, context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tracker := newCodeBlockTracker(sw, "task-1")
+	output := "Run:\n```go\nfmt.Println(\"`x`\")\n```\nand ``inline``\n~~~~ sh extra\nls\n~~~\n~~~~\nThen ```py\nno\n```python\nprint(1)"
+	// Deltas split fences and lines at arbitrary points
+	for i := 0; i < len(output); i += 3 {
+		tracker.Write([]byte(output[i:min(i+3, len(output))]))
+	}
+	tracker.Close()
+
+	events := codeBlockEvents(t, rec.Body.String())
+	want := []CodeBlockEvent{
+		{TaskID: "task-1", Action: "open", Index: 0, Language: "go", Offset: strings.Index(output, "fmt")},
+		{TaskID: "task-1", Action: "close", Index: 0, Offset: strings.Index(output, "```\nand")},
+		{TaskID: "task-1", Action: "open", Index: 1, Language: "sh", Offset: strings.Index(output, "ls")},
+		{TaskID: "task-1", Action: "close", Index: 1, Offset: strings.Index(output, "~~~~\nThen")},
+		{TaskID: "task-1", Action: "open", Index: 2, Language: "python", Offset: strings.Index(output, "print")},
+		{TaskID: "task-1", Action: "close", Index: 2, Offset: len(output), Unterminated: true},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("expected %d events, got %+v", len(want), events)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Errorf("event %d: expected %+v, got %+v", i, want[i], events[i])
+		}
+	}
+	if !strings.Contains(rec.Body.String(), `event: message`) {
+		t.Errorf("expected the output to be streamed as message events too")
+	}
+}
+
+func TestCodeBlockEventsCanBeSelected(t *testing.T) {
+	opts, err := DefaultSSEOptions.Override(map[string][]string{"events": {"code_block"}})
+	if err != nil {
+		t.Fatalf("expected code_block to be a selectable event: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	sw, _ := NewSSEWriter(rec, context.Background())
+	sw.events = opts.eventFilter()
+	tracker := newCodeBlockTracker(sw, "task-1")
+	tracker.Write([]byte("```\ncode\n```\n"))
+	tracker.Close()
+
+	if strings.Contains(rec.Body.String(), "event: message") || len(codeBlockEvents(t, rec.Body.String())) != 2 {
+		t.Errorf("expected only the code_block events, got %q", rec.Body.String())
+	}
+}
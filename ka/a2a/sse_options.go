@@ -0,0 +1,183 @@
+package a2a
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults of the event streams of tasks/sendSubscribe.
+const (
+	DefaultSSEKeepAlive   = 20 * time.Second
+	DefaultSSEEventBuffer = 64
+	maxSSEEventBuffer     = 4096
+)
+
+// SSEEventTypes are the events of a task stream a subscriber can select.
+// The rpc-response event is always sent.
+var SSEEventTypes = []string{"state", "message", "progress", "tool_output", "info", "sub_task_status", codeBlockEvent}
+
+// SSEOptions configure an event stream.
+type SSEOptions struct {
+	KeepAlive   time.Duration // Interval of keepalive comments; 0 sends none
+	Retry       time.Duration // Reconnection delay sent as the retry: field; 0 leaves it to the client
+	EventBuffer int           // Events queued for a slow client before they are dropped
+	Events      []string      // Event types sent; empty sends all. Only selected per subscription
+}
+
+// DefaultSSEOptions are the options of agents that configure none.
+var DefaultSSEOptions = SSEOptions{KeepAlive: DefaultSSEKeepAlive, EventBuffer: DefaultSSEEventBuffer}
+
+// Validate checks the ranges of the options.
+func (o SSEOptions) Validate() error {
+	if o.KeepAlive < 0 {
+		return fmt.Errorf("keepalive must not be negative")
+	}
+	if o.Retry < 0 {
+		return fmt.Errorf("retry must not be negative")
+	}
+	if o.EventBuffer < 1 || o.EventBuffer > maxSSEEventBuffer {
+		return fmt.Errorf("event buffer must be between 1 and %d", maxSSEEventBuffer)
+	}
+	for _, event := range o.Events {
+		if !containsString(SSEEventTypes, event) {
+			return fmt.Errorf("unknown event type '%s', expected one of %s", event, strings.Join(SSEEventTypes, ", "))
+		}
+	}
+	return nil
+}
+
+// eventFilter returns the set of selected event types, or nil for all.
+func (o SSEOptions) eventFilter() map[string]bool {
+	if len(o.Events) == 0 {
+		return nil
+	}
+	filter := make(map[string]bool, len(o.Events))
+	for _, event := range o.Events {
+		filter[event] = true
+	}
+	return filter
+}
+
+// Override applies the options a subscriber passed as query parameters:
+// keepalive and retry as durations ("15s") or plain seconds and milliseconds
+// respectively, buffer as a number of events, and events as a comma-separated
+// list of event types, e.g. "state,progress" for coarse progress only.
+func (o SSEOptions) Override(query url.Values) (SSEOptions, error) {
+	if value := query.Get("keepalive"); value != "" {
+		d, err := parseSSEDuration(value, time.Second)
+		if err != nil {
+			return o, fmt.Errorf("invalid keepalive: %w", err)
+		}
+		o.KeepAlive = d
+	}
+	if value := query.Get("retry"); value != "" {
+		d, err := parseSSEDuration(value, time.Millisecond)
+		if err != nil {
+			return o, fmt.Errorf("invalid retry: %w", err)
+		}
+		o.Retry = d
+	}
+	if value := query.Get("buffer"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return o, fmt.Errorf("invalid buffer: %w", err)
+		}
+		o.EventBuffer = n
+	}
+	if value := query.Get("events"); value != "" {
+		o.Events = nil
+		for _, event := range strings.Split(value, ",") {
+			if event = strings.TrimSpace(event); event != "" && !containsString(o.Events, event) {
+				o.Events = append(o.Events, event)
+			}
+		}
+	}
+	return o, o.Validate()
+}
+
+// parseSSEDuration parses a Go duration, or a plain number in unit.
+func parseSSEDuration(value string, unit time.Duration) (time.Duration, error) {
+	if n, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(n * float64(unit)), nil
+	}
+	return time.ParseDuration(value)
+}
+
+// SSESettings holds the agent's stream options, which admin/sse changes at
+// runtime. The zero value serves DefaultSSEOptions.
+type SSESettings struct {
+	mu   sync.RWMutex
+	opts *SSEOptions
+}
+
+// Get returns the current options.
+func (s *SSESettings) Get() SSEOptions {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.opts == nil {
+		return DefaultSSEOptions
+	}
+	return *s.opts
+}
+
+// Set replaces the options. Streams already open keep theirs.
+func (s *SSESettings) Set(opts SSEOptions) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.opts = &opts
+	return nil
+}
+
+// SSEParams defines the parameters and result of "admin/sse". Fields left
+// out keep their current value.
+type SSEParams struct {
+	KeepAliveSeconds *float64 `json:"keepAliveSeconds,omitempty"`
+	RetryMs          *int64   `json:"retryMs,omitempty"`
+	EventBuffer      *int     `json:"eventBuffer,omitempty"`
+}
+
+func sseParamsOf(opts SSEOptions) SSEParams {
+	keepAlive := opts.KeepAlive.Seconds()
+	retry := opts.Retry.Milliseconds()
+	buffer := opts.EventBuffer
+	return SSEParams{KeepAliveSeconds: &keepAlive, RetryMs: &retry, EventBuffer: &buffer}
+}
+
+// AdminSSEHandler handles "admin/sse", which reports or changes the stream
+// options of new subscriptions.
+func AdminSSEHandler(settings *SSESettings) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params SSEParams
+		rpcReq, ok := decodeJSONRPCRequest(w, r, &params)
+		if !ok {
+			return
+		}
+		opts := settings.Get()
+		if params.KeepAliveSeconds != nil {
+			opts.KeepAlive = time.Duration(*params.KeepAliveSeconds * float64(time.Second))
+		}
+		if params.RetryMs != nil {
+			opts.Retry = time.Duration(*params.RetryMs) * time.Millisecond
+		}
+		if params.EventBuffer != nil {
+			opts.EventBuffer = *params.EventBuffer
+		}
+		if params != (SSEParams{}) {
+			if err := settings.Set(opts); err != nil {
+				sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: "Invalid Params: " + err.Error()})
+				return
+			}
+			log.Printf("[AdminSSE %v] Stream options set to keepalive %s, retry %s, event buffer %d.", rpcReq.ID, opts.KeepAlive, opts.Retry, opts.EventBuffer)
+		}
+		sendJSONRPCResponse(w, rpcReq.ID, sseParamsOf(settings.Get()), nil)
+	}
+}
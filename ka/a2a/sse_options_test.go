@@ -0,0 +1,104 @@
+package a2a
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSSEOptionsOverride(t *testing.T) {
+	opts, err := DefaultSSEOptions.Override(url.Values{"keepalive": {"5"}, "retry": {"2s"}, "buffer": {"8"}})
+	if err != nil {
+		t.Fatalf("Override: %v", err)
+	}
+	if opts.KeepAlive != 5*time.Second || opts.Retry != 2*time.Second || opts.EventBuffer != 8 {
+		t.Errorf("unexpected options %+v", opts)
+	}
+
+	opts, err = DefaultSSEOptions.Override(url.Values{"keepalive": {"0"}, "retry": {"1500"}})
+	if err != nil {
+		t.Fatalf("Override: %v", err)
+	}
+	if opts.KeepAlive != 0 || opts.Retry != 1500*time.Millisecond || opts.EventBuffer != DefaultSSEEventBuffer {
+		t.Errorf("expected keepalives off and a 1500ms retry, got %+v", opts)
+	}
+
+	opts, err = DefaultSSEOptions.Override(url.Values{"events": {"state, progress,state"}})
+	if err != nil {
+		t.Fatalf("Override: %v", err)
+	}
+	if filter := opts.eventFilter(); len(filter) != 2 || !filter["state"] || !filter["progress"] {
+		t.Errorf("expected a filter of state and progress, got %v", filter)
+	}
+
+	for _, query := range []url.Values{{"keepalive": {"soon"}}, {"retry": {"-1"}}, {"buffer": {"0"}}, {"events": {"state,tokens"}}} {
+		if _, err := DefaultSSEOptions.Override(query); err == nil {
+			t.Errorf("expected %v to be rejected", query)
+		}
+	}
+}
+
+func TestSendSubscribeAppliesStreamOptions(t *testing.T) {
+	te := NewTaskExecutor(&staticLLMClient{reply: "ok"}, NewInMemoryTaskStore(), nil, "")
+	if err := te.SSE.Set(SSEOptions{KeepAlive: time.Second, Retry: 4 * time.Second, EventBuffer: 16}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	body := `{"message":{"role":"user","parts":[{"type":"text","text":"hi"}]}}`
+	rec := httptest.NewRecorder()
+	TasksSendSubscribeHandler(te)(rec, httptest.NewRequest(http.MethodPost, "/?retry=2500", strings.NewReader(body)))
+	if !strings.HasPrefix(rec.Body.String(), "retry: 2500\n\n") {
+		t.Errorf("expected the stream to open with the subscriber's retry hint, got %q", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	TasksSendSubscribeHandler(te)(rec, httptest.NewRequest(http.MethodPost, "/?buffer=-3", strings.NewReader(body)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected an invalid buffer to be rejected, got %d", rec.Code)
+	}
+}
+
+func TestAdminSSEChangesOptions(t *testing.T) {
+	var settings SSESettings
+	call := func(params string) string {
+		body := `{"jsonrpc":"2.0","id":1,"method":"admin/sse","params":` + params + `}`
+		rec := httptest.NewRecorder()
+		AdminSSEHandler(&settings)(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+		return rec.Body.String()
+	}
+
+	if response := call(`{}`); !strings.Contains(response, `"keepAliveSeconds":20`) {
+		t.Errorf("expected the defaults to be reported, got %s", response)
+	}
+	call(`{"keepAliveSeconds":0,"retryMs":3000}`)
+	if got := settings.Get(); got.KeepAlive != 0 || got.Retry != 3*time.Second || got.EventBuffer != DefaultSSEEventBuffer {
+		t.Errorf("unexpected options after admin/sse: %+v", got)
+	}
+	if response := call(`{"eventBuffer":100000}`); !strings.Contains(response, "-32602") {
+		t.Errorf("expected an oversized buffer to be rejected, got %s", response)
+	}
+}
+
+func TestSendSubscribeFiltersEventTypes(t *testing.T) {
+	te := NewTaskExecutor(&staticLLMClient{reply: "a long answer"}, NewInMemoryTaskStore(), nil, "")
+	body := `{"jsonrpc":"2.0","id":1,"method":"tasks/sendSubscribe","params":{"message":{"role":"user","parts":[{"type":"text","text":"hi"}]}}}`
+
+	rec := httptest.NewRecorder()
+	TasksSendSubscribeHandler(te)(rec, httptest.NewRequest(http.MethodPost, "/?events=state", strings.NewReader(body)))
+	stream := rec.Body.String()
+	if strings.Contains(stream, "event: message") {
+		t.Errorf("expected no message events, got %q", stream)
+	}
+	if !strings.HasPrefix(stream, "event: rpc-response\n") || !strings.Contains(stream, "event: state") {
+		t.Errorf("expected the rpc-response and state events, got %q", stream)
+	}
+
+	rec = httptest.NewRecorder()
+	TasksSendSubscribeHandler(te)(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+	if !strings.Contains(rec.Body.String(), "event: message") {
+		t.Errorf("expected all events without a filter, got %q", rec.Body.String())
+	}
+}
@@ -0,0 +1,250 @@
+package a2a
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultResubscribeGrace is how long a streamed task keeps running after
+// its last subscriber disconnected, waiting for a tasks/resubscribe.
+const DefaultResubscribeGrace = 30 * time.Second
+
+// streamReplayEvents bounds the events kept per task for replay.
+const streamReplayEvents = 1024
+
+type streamEvent struct {
+	id          int64
+	event, data string
+}
+
+// taskStream fans the events of a streamed task run out to its subscribers:
+// the tasks/sendSubscribe connection that started it and any connections
+// reattached with tasks/resubscribe. Events are numbered, sent with an SSE
+// id: field, and the latest are kept for replay.
+type taskStream struct {
+	taskID string
+	grace  time.Duration
+	cancel context.CancelFunc // Cancels the run
+
+	mu          sync.Mutex
+	events      []streamEvent
+	lastID      int64
+	subscribers map[*SSEWriter]bool
+	graceTimer  *time.Timer
+	done        chan struct{} // Closed when the run ended
+}
+
+// openStream registers the stream of a task run. cancel stops the run once
+// no subscriber reattached within the grace period.
+func (te *TaskExecutor) openStream(taskID string, cancel context.CancelFunc) *taskStream {
+	stream := &taskStream{taskID: taskID, grace: te.ResubscribeGrace, cancel: cancel, subscribers: make(map[*SSEWriter]bool), done: make(chan struct{})}
+	te.mu.Lock()
+	defer te.mu.Unlock()
+	if te.streams == nil {
+		te.streams = make(map[string]*taskStream)
+	}
+	te.streams[taskID] = stream
+	return stream
+}
+
+// closeStream ends a stream when its run finished. It stays available for
+// the grace period, so a subscriber that dropped near the end can still
+// fetch the last events.
+func (te *TaskExecutor) closeStream(stream *taskStream) {
+	stream.mu.Lock()
+	close(stream.done)
+	if stream.graceTimer != nil {
+		stream.graceTimer.Stop()
+	}
+	stream.mu.Unlock()
+	time.AfterFunc(stream.grace, func() {
+		te.mu.Lock()
+		defer te.mu.Unlock()
+		if te.streams[stream.taskID] == stream {
+			delete(te.streams, stream.taskID)
+		}
+	})
+}
+
+// stream returns the stream of a task, or nil if it has none.
+func (te *TaskExecutor) stream(taskID string) *taskStream {
+	te.mu.Lock()
+	defer te.mu.Unlock()
+	return te.streams[taskID]
+}
+
+// publisher returns the writer the run sends its events to.
+func (s *taskStream) publisher(ctx context.Context, eventBuffer int) *SSEWriter {
+	return &SSEWriter{ctx: ctx, stream: s, eventBuffer: eventBuffer}
+}
+
+// publish numbers an event, keeps it for replay and sends it to the
+// subscribers that selected it. Subscribers that cannot be written to are
+// detached.
+func (s *taskStream) publish(event, data string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastID++
+	s.events = append(s.events, streamEvent{id: s.lastID, event: event, data: data})
+	if len(s.events) > streamReplayEvents {
+		s.events = s.events[len(s.events)-streamReplayEvents:]
+	}
+	for subscriber := range s.subscribers {
+		if !subscriber.wants(event) {
+			continue
+		}
+		if err := subscriber.writeFrame(s.lastID, event, data); err != nil {
+			s.detachLocked(subscriber)
+		}
+	}
+	return nil
+}
+
+// attach replays the events after lastEventID to a subscriber and adds it.
+// opened runs first, with the number of events after lastEventID that are
+// no longer kept.
+func (s *taskStream) attach(subscriber *SSEWriter, lastEventID int64, opened func(missed int64)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var missed int64
+	if len(s.events) > 0 && s.events[0].id > lastEventID+1 {
+		missed = s.events[0].id - lastEventID - 1
+	}
+	if opened != nil {
+		opened(missed)
+	}
+	for _, e := range s.events {
+		if e.id > lastEventID && subscriber.wants(e.event) {
+			subscriber.writeFrame(e.id, e.event, e.data)
+		}
+	}
+	s.subscribers[subscriber] = true
+	if s.graceTimer != nil {
+		s.graceTimer.Stop()
+		s.graceTimer = nil
+	}
+}
+
+// follow blocks until the run ended or the subscriber disconnected.
+func (s *taskStream) follow(subscriber *SSEWriter) {
+	select {
+	case <-s.done:
+	case <-subscriber.ctx.Done():
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.detachLocked(subscriber)
+}
+
+// detachLocked removes a subscriber. Without subscribers left, the run is
+// cancelled after the grace period unless one reattaches.
+func (s *taskStream) detachLocked(subscriber *SSEWriter) {
+	if !s.subscribers[subscriber] {
+		return
+	}
+	delete(s.subscribers, subscriber)
+	select {
+	case <-s.done:
+		return
+	default:
+	}
+	if len(s.subscribers) == 0 && s.graceTimer == nil {
+		log.Printf("[Task %s Stream] Last subscriber disconnected; cancelling in %s unless one resubscribes.", s.taskID, s.grace)
+		s.graceTimer = time.AfterFunc(s.grace, s.cancel)
+	}
+}
+
+// ResubscribeParams defines the parameters of "tasks/resubscribe".
+type ResubscribeParams struct {
+	ID          string `json:"id"`
+	LastEventID string `json:"lastEventId,omitempty"` // Id of the last event received; the Last-Event-ID header if empty
+}
+
+// TasksResubscribeHandler handles "tasks/resubscribe", which reattaches an
+// event stream to a task started with tasks/sendSubscribe after its
+// connection dropped. The stream opens with an "rpc-response" event, replays
+// the events after the last event id the client received, and continues with
+// the live events:
+//
+//	event: rpc-response
+//	data: {"jsonrpc":"2.0","id":1,"result":{"id":"<task id>","status":{"state":"working"},"missedEvents":0}}
+//
+// missedEvents counts events that were no longer kept for replay. Tasks
+// without a stream, e.g. finished ones, get their current state and the
+// stream ends.
+func TasksResubscribeHandler(taskExecutor *TaskExecutor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params ResubscribeParams
+		rpcReq, ok := decodeJSONRPCRequest(w, r, &params)
+		if !ok {
+			return
+		}
+		if params.ID == "" {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: "Invalid Params: id is required"})
+			return
+		}
+		lastEventID := params.LastEventID
+		if lastEventID == "" {
+			lastEventID = r.Header.Get("Last-Event-ID")
+		}
+		var after int64
+		if lastEventID != "" {
+			n, err := strconv.ParseInt(lastEventID, 10, 64)
+			if err != nil || n < 0 {
+				sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: "Invalid Params: lastEventId must be the id of an event of the stream"})
+				return
+			}
+			after = n
+		}
+		sseOpts, err := taskExecutor.SSE.Get().Override(r.URL.Query())
+		if err != nil {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: "Invalid Params: " + err.Error()})
+			return
+		}
+		task, err := taskExecutor.TaskStore.GetTask(params.ID)
+		if err == nil && HiddenFromCaller(r.Context(), taskExecutor.TaskStore, params.ID) {
+			err = ErrTaskNotFound
+		}
+		if err != nil {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32001, Message: "Task not found", Data: err.Error()})
+			return
+		}
+
+		sseWriter, err := NewSSEWriter(w, r.Context())
+		if err != nil {
+			log.Printf("[Task %s] Failed to initialize SSE for resubscribe: %v", task.ID, err)
+			return
+		}
+		sseWriter.eventBuffer = sseOpts.EventBuffer
+		sseWriter.events = sseOpts.eventFilter()
+		if sseOpts.Retry > 0 {
+			sseWriter.SendRetry(sseOpts.Retry)
+		}
+		sendResponse := func(missed int64) {
+			response, _ := json.Marshal(JSONRPCResponse{
+				Jsonrpc: "2.0",
+				ID:      rpcReq.ID,
+				Result:  map[string]interface{}{"id": task.ID, "status": TaskStatus{State: task.State, Timestamp: task.UpdatedAt.Format(time.RFC3339)}, "missedEvents": missed},
+			})
+			sseWriter.SendEvent(rpcResponseEvent, string(response))
+		}
+
+		stream := taskExecutor.stream(task.ID)
+		if stream == nil {
+			sendResponse(0)
+			stateData, _ := json.Marshal(map[string]string{"task_id": task.ID, "status": string(task.State)})
+			sseWriter.SendEvent("state", string(stateData))
+			log.Printf("[Task %s] Resubscribed to a task without stream; sent its state.", task.ID)
+			return
+		}
+		go sseWriter.KeepAlive(sseOpts.KeepAlive)
+		stream.attach(sseWriter, after, sendResponse)
+		log.Printf("[Task %s] Resubscribed after event %d.", task.ID, after)
+		stream.follow(sseWriter)
+	}
+}
@@ -0,0 +1,103 @@
+package a2a
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"ka/llm"
+)
+
+// gatedLLMClient streams "first", waits for release, then streams "second".
+type gatedLLMClient struct{ release chan struct{} }
+
+func (c *gatedLLMClient) Chat(ctx context.Context, messages []llm.Message, stream bool, out io.Writer) (string, int, int, error) {
+	io.WriteString(out, "first")
+	select {
+	case <-c.release:
+	case <-ctx.Done():
+		return "", 0, 0, ctx.Err()
+	}
+	io.WriteString(out, "second")
+	return "firstsecond", 0, 0, nil
+}
+
+func waitForBody(t *testing.T, rec *syncRecorder, want string) string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !strings.Contains(rec.body(), want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %q on the stream, got:\n%s", want, rec.body())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return rec.body()
+}
+
+func TestResubscribeReplaysAndContinues(t *testing.T) {
+	client := &gatedLLMClient{release: make(chan struct{})}
+	te := NewTaskExecutor(client, NewInMemoryTaskStore(), nil, "")
+
+	// The first connection drops after the first chunk
+	ctx, disconnect := context.WithCancel(context.Background())
+	first := &syncRecorder{ResponseRecorder: httptest.NewRecorder()}
+	subscribed := make(chan struct{})
+	go func() {
+		defer close(subscribed)
+		body := `{"jsonrpc":"2.0","id":1,"method":"tasks/sendSubscribe","params":{"message":{"role":"user","parts":[{"type":"text","text":"hi"}]}}}`
+		TasksSendSubscribeHandler(te)(first, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)).WithContext(ctx))
+	}()
+	stream := waitForBody(t, first, `"chunk":"first"`)
+	disconnect()
+	<-subscribed
+	taskID := regexp.MustCompile(`"task_id":"([^"]+)"`).FindStringSubmatch(stream)[1]
+	ids := regexp.MustCompile(`id: (\d+)\n`).FindAllStringSubmatch(stream, -1)
+	lastID := ids[len(ids)-1][1]
+
+	second := &syncRecorder{ResponseRecorder: httptest.NewRecorder()}
+	resubscribed := make(chan struct{})
+	go func() {
+		defer close(resubscribed)
+		body := `{"jsonrpc":"2.0","id":2,"method":"tasks/resubscribe","params":{"id":"` + taskID + `"}}`
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.Header.Set("Last-Event-ID", lastID)
+		TasksResubscribeHandler(te)(second, req)
+	}()
+	waitForBody(t, second, `"missedEvents":0`)
+	close(client.release)
+	<-resubscribed
+
+	body := second.body()
+	if !strings.HasPrefix(body, "event: rpc-response\n") {
+		t.Errorf("expected the stream to open with the rpc-response, got:\n%s", body)
+	}
+	if strings.Contains(body, `"chunk":"first"`) || !strings.Contains(body, `"chunk":"second"`) || !strings.Contains(body, "COMPLETED") {
+		t.Errorf("expected only the events after %s up to completion, got:\n%s", lastID, body)
+	}
+	waitForState(t, te.TaskStore, taskID, TaskStateCompleted)
+}
+
+func TestResubscribeWithoutStreamSendsState(t *testing.T) {
+	te := NewTaskExecutor(&staticLLMClient{reply: "ok"}, NewInMemoryTaskStore(), nil, "")
+	task, _ := te.TaskStore.CreateTask("done", "", nil, "")
+	te.TaskStore.SetState(task.ID, TaskStateCompleted)
+
+	rec := httptest.NewRecorder()
+	body := `{"jsonrpc":"2.0","id":3,"method":"tasks/resubscribe","params":{"id":"` + task.ID + `"}}`
+	TasksResubscribeHandler(te)(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+	if !strings.Contains(rec.Body.String(), `"status":"COMPLETED"`) {
+		t.Errorf("expected the final state, got:\n%s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	body = `{"jsonrpc":"2.0","id":4,"method":"tasks/resubscribe","params":{"id":"` + task.ID + `","lastEventId":"x"}}`
+	TasksResubscribeHandler(te)(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+	if !strings.Contains(rec.Body.String(), "-32602") {
+		t.Errorf("expected an invalid lastEventId to be rejected, got %s", rec.Body.String())
+	}
+}
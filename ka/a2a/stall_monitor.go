@@ -0,0 +1,148 @@
+package a2a
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+// startHeartbeat records a heartbeat on the task now and then every
+// te.HeartbeatInterval until the returned stop function is called.
+func (te *TaskExecutor) startHeartbeat(taskID string) (stop func()) {
+	if te.HeartbeatInterval <= 0 {
+		return func() {}
+	}
+	beat := func() {
+		_, err := te.TaskStore.UpdateTask(taskID, func(task *Task) error {
+			task.HeartbeatAt = time.Now().UTC()
+			return nil
+		})
+		if err != nil {
+			log.Printf("[Task %s] Failed to record heartbeat: %v", taskID, err)
+		}
+	}
+	beat()
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(te.HeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				beat()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// StallAlert is sent when a WORKING task stopped sending heartbeats.
+type StallAlert struct {
+	TaskID        string    `json:"taskId"`
+	Name          string    `json:"name,omitempty"`
+	LastHeartbeat time.Time `json:"lastHeartbeat"`
+	Restarted     bool      `json:"restarted"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// StallMonitor moves WORKING tasks without a recent heartbeat to STALLED,
+// which catches crashed executors and runs that hang. With an AffinityRing it
+// only checks the tasks its instance owns.
+type StallMonitor struct {
+	Executor    *TaskExecutor
+	Threshold   time.Duration    // Heartbeat age after which a task counts as stalled
+	Interval    time.Duration    // Time between checks
+	AutoRestart bool             // Start a new run for stalled tasks without a live one
+	MaxRestarts int              // Restarts per task before it is left STALLED
+	Alert       func(StallAlert) // Optional; called for every stalled task
+}
+
+// Run checks for stalled tasks every Interval until ctx is done.
+func (m *StallMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.Check()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Check runs one sweep over the tasks and returns the alerts it raised.
+func (m *StallMonitor) Check() []StallAlert {
+	te := m.Executor
+	tasks, err := te.TaskStore.ListTasks()
+	if err != nil {
+		log.Printf("[StallMonitor] Failed to list tasks: %v", err)
+		return nil
+	}
+	now := time.Now().UTC()
+	var alerts []StallAlert
+	for _, task := range tasks {
+		if task.State != TaskStateWorking || !te.ownsTask(task) {
+			continue
+		}
+		lastBeat := task.HeartbeatAt
+		if lastBeat.IsZero() {
+			lastBeat = task.UpdatedAt
+		}
+		if now.Sub(lastBeat) < m.Threshold {
+			continue
+		}
+
+		stalled, err := te.TaskStore.UpdateTask(task.ID, func(t *Task) error {
+			if t.State == TaskStateWorking {
+				t.State = TaskStateStalled
+				setTaskError(t, &ErrorDetail{
+					Code:      ErrorCodeStalled,
+					Message:   "no heartbeat since " + lastBeat.Format(time.RFC3339),
+					Hint:      "The run hung or the agent crashed. Send the task again to restart it.",
+					Retryable: true,
+				})
+			}
+			return nil
+		})
+		if err != nil || stalled.State != TaskStateStalled {
+			continue // Gone or moved on in the meantime
+		}
+		log.Printf("[StallMonitor] Task %s has no heartbeat since %s. Marked STALLED.", task.ID, lastBeat.Format(time.RFC3339))
+
+		alert := StallAlert{TaskID: task.ID, Name: task.Name, LastHeartbeat: lastBeat, Timestamp: now}
+		if m.AutoRestart && stalled.StallRestarts < m.MaxRestarts && !te.IsRunning(task.ID) {
+			restarted, err := te.TaskStore.UpdateTask(task.ID, func(t *Task) error {
+				t.StallRestarts++
+				setTaskError(t, nil)
+				return nil
+			})
+			if err == nil {
+				log.Printf("[StallMonitor] Restarting task %s (restart %d of %d).", task.ID, restarted.StallRestarts, m.MaxRestarts)
+				go te.ExecuteTask(context.Background(), restarted)
+				alert.Restarted = true
+			}
+		}
+		if m.Alert != nil {
+			m.Alert(alert)
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts
+}
+
+// WebhookAlert returns an alert function posting each alert as JSON to url
+// through deliverer, signed by its Signer. Alerts that cannot be delivered are
+// dead-lettered.
+func WebhookAlert(url string, deliverer *Deliverer) func(StallAlert) {
+	return func(alert StallAlert) {
+		body, _ := json.Marshal(map[string]interface{}{"event": "task_stalled", "alert": alert})
+		delivery := Delivery{Kind: "stall_alert", URL: url, Body: body, Signed: true, TaskID: alert.TaskID}
+		if err := deliverer.Deliver(context.Background(), delivery); err != nil {
+			log.Printf("[StallMonitor] Failed to send alert for task %s: %v", alert.TaskID, err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package a2a
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStallMonitorMarksAndRestartsStalledTasks(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	te := NewTaskExecutor(&staticLLMClient{reply: "Recovered."}, store, nil, "")
+
+	stale, _ := store.CreateTask("stale", "", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "hi"}}}}, "")
+	fresh, _ := store.CreateTask("fresh", "", nil, "")
+	store.UpdateTask(stale.ID, func(task *Task) error {
+		task.State = TaskStateWorking
+		task.HeartbeatAt = time.Now().Add(-time.Minute)
+		return nil
+	})
+	store.UpdateTask(fresh.ID, func(task *Task) error {
+		task.State = TaskStateWorking
+		task.HeartbeatAt = time.Now()
+		return nil
+	})
+
+	var alerted []StallAlert
+	monitor := &StallMonitor{Executor: te, Threshold: 30 * time.Second, Alert: func(a StallAlert) { alerted = append(alerted, a) }}
+	alerts := monitor.Check()
+	if len(alerts) != 1 || alerts[0].TaskID != stale.ID || alerts[0].Restarted || len(alerted) != 1 {
+		t.Fatalf("Expected one alert for the stale task, got %+v", alerts)
+	}
+	if task, _ := taskSnapshot(store, stale.ID); task.State != TaskStateStalled {
+		t.Errorf("Expected STALLED, got %s", task.State)
+	}
+	if task, _ := taskSnapshot(store, fresh.ID); task.State != TaskStateWorking {
+		t.Errorf("Expected the task with a recent heartbeat to stay WORKING, got %s", task.State)
+	}
+
+	// A stalled task left WORKING by a crashed process is restarted once.
+	store.UpdateTask(stale.ID, func(task *Task) error {
+		task.State = TaskStateWorking
+		task.HeartbeatAt = time.Now().Add(-time.Minute)
+		return nil
+	})
+	monitor.AutoRestart, monitor.MaxRestarts = true, 1
+	if alerts := monitor.Check(); len(alerts) != 1 || !alerts[0].Restarted {
+		t.Fatalf("Expected the stalled task to be restarted, got %+v", alerts)
+	}
+	waitForState(t, store, stale.ID, TaskStateCompleted)
+}
+
+func TestStartHeartbeatRecordsHeartbeats(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	te := NewTaskExecutor(nil, store, nil, "")
+	te.HeartbeatInterval = 10 * time.Millisecond
+	task, _ := store.CreateTask("t", "", nil, "")
+
+	stop := te.startHeartbeat(task.ID)
+	first, _ := taskSnapshot(store, task.ID)
+	firstBeat := first.HeartbeatAt
+	time.Sleep(50 * time.Millisecond)
+	stop()
+	later, _ := taskSnapshot(store, task.ID)
+	if firstBeat.IsZero() || !later.HeartbeatAt.After(firstBeat) {
+		t.Errorf("Expected heartbeats to be recorded, got %v then %v", firstBeat, later.HeartbeatAt)
+	}
+}
@@ -0,0 +1,126 @@
+package a2a
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"ka/llm"
+)
+
+// TraceEntry records one executor iteration of a task running in debug mode.
+type TraceEntry struct {
+	Iteration int           `json:"iteration"`
+	Prompt    []llm.Message `json:"prompt"`              // Messages sent to the LLM
+	Response  string        `json:"response"`            // Raw LLM response
+	ToolCalls []string      `json:"toolCalls,omitempty"` // Names of the tool calls parsed from the response
+	Timestamp time.Time     `json:"timestamp"`
+}
+
+// recordTrace appends an iteration to the execution trace of a debug task.
+func (te *TaskExecutor) recordTrace(taskID string, prompt []llm.Message, response string) {
+	_, err := te.TaskStore.UpdateTask(taskID, func(task *Task) error {
+		entry := TraceEntry{Iteration: len(task.Trace) + 1, Prompt: prompt, Response: response, Timestamp: time.Now().UTC()}
+		for i := len(task.Messages) - 1; i >= 0; i-- {
+			if task.Messages[i].Role == RoleAssistant {
+				for _, call := range task.Messages[i].ParsedToolCalls {
+					entry.ToolCalls = append(entry.ToolCalls, call.Function.Name)
+				}
+				break
+			}
+		}
+		task.Trace = append(task.Trace, entry)
+		return nil
+	})
+	if err != nil {
+		log.Printf("[Task %s] Failed to record execution trace: %v", taskID, err)
+	}
+}
+
+// waitForStep pauses a debug task in STEP_WAIT between the LLM call and the
+// dispatch of its tool calls, until StepTask advances it. It returns false if
+// the task should stop instead. sseWriter may be nil.
+func (te *TaskExecutor) waitForStep(ctx context.Context, taskID string, toolCalls []ToolCall, sseWriter *SSEWriter) bool {
+	te.mu.Lock()
+	step := make(chan struct{})
+	te.stepSignals[taskID] = step
+	te.mu.Unlock()
+	defer func() {
+		te.mu.Lock()
+		delete(te.stepSignals, taskID)
+		te.mu.Unlock()
+	}()
+
+	if err := te.TaskStore.SetState(taskID, TaskStateStepWait); err != nil {
+		log.Printf("[Task %s] Failed to set state to StepWait: %v", taskID, err)
+		return false
+	}
+	if sseWriter != nil {
+		stepWaitData, _ := json.Marshal(map[string]interface{}{"status": string(TaskStateStepWait), "pendingToolCalls": toolCalls})
+		sseWriter.SendEvent("state", string(stepWaitData))
+	}
+	log.Printf("[Task %s] Debug mode: waiting for tasks/step before dispatching %d tool call(s).", taskID, len(toolCalls))
+
+	select {
+	case <-step:
+	case <-ctx.Done():
+		log.Printf("[Task %s] Context cancelled while waiting for a step.", taskID)
+		return false
+	}
+	if err := te.TaskStore.SetState(taskID, TaskStateWorking); err != nil {
+		log.Printf("[Task %s] Failed to set state back to Working after step: %v", taskID, err)
+		return false
+	}
+	return true
+}
+
+// StepTask advances a debug task waiting in STEP_WAIT by one iteration.
+func (te *TaskExecutor) StepTask(taskID string) error {
+	te.mu.Lock()
+	defer te.mu.Unlock()
+	step, ok := te.stepSignals[taskID]
+	if !ok {
+		return fmt.Errorf("task %s is not waiting for a step", taskID)
+	}
+	delete(te.stepSignals, taskID) // A second step before the task pauses again is rejected
+	close(step)
+	return nil
+}
+
+// TasksStepHandler handles "tasks/step", which advances a task started with
+// debug enabled past its current pause.
+func TasksStepHandler(taskExecutor *TaskExecutor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params TaskStatusParams
+		rpcReq, ok := decodeJSONRPCRequest(w, r, &params)
+		if !ok {
+			return
+		}
+		if params.ID == "" {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: "Invalid Params: missing task ID"})
+			return
+		}
+		if HiddenFromCaller(r.Context(), taskExecutor.TaskStore, params.ID) {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32001, Message: "Not Found: Task not found"})
+			return
+		}
+		if _, err := taskExecutor.TaskStore.GetTask(params.ID); err != nil {
+			if errors.Is(err, ErrTaskNotFound) {
+				sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32001, Message: "Not Found: Task not found"})
+			} else {
+				sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32000, Message: "Internal Server Error", Data: err.Error()})
+			}
+			return
+		}
+		if err := taskExecutor.StepTask(params.ID); err != nil {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32002, Message: fmt.Sprintf("Conflict: %v", err)})
+			return
+		}
+		log.Printf("[TaskStep %v] Advanced task %s by one step.", rpcReq.ID, params.ID)
+		sendJSONRPCResponse(w, rpcReq.ID, map[string]interface{}{"id": params.ID, "state": TaskStateWorking}, nil)
+	}
+}
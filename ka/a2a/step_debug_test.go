@@ -0,0 +1,62 @@
+package a2a
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"testing"
+
+	"ka/llm"
+	"ka/tools"
+)
+
+// scriptedLLMClient returns its replies in order, repeating the last one.
+type scriptedLLMClient struct {
+	replies []string
+	calls   atomic.Int32
+}
+
+func (c *scriptedLLMClient) Chat(ctx context.Context, messages []llm.Message, stream bool, out io.Writer) (string, int, int, error) {
+	i := int(c.calls.Add(1)) - 1
+	if i >= len(c.replies) {
+		i = len(c.replies) - 1
+	}
+	io.WriteString(out, c.replies[i])
+	return c.replies[i], 0, 0, nil
+}
+
+func TestDebugTaskWaitsForStepBeforeDispatchingTools(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	client := &scriptedLLMClient{replies: []string{`<tool id="read_file">{"path": "missing.txt"}</tool>`, "Done."}}
+	te := NewTaskExecutor(client, store, map[string]tools.Tool{"read_file": &failingTool{}}, "")
+
+	task, _ := store.CreateTask("debug", "", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "read it"}}}}, "")
+	task, _ = store.UpdateTask(task.ID, func(t *Task) error {
+		t.Debug = true
+		return nil
+	})
+	if err := te.StepTask(task.ID); err == nil {
+		t.Errorf("Expected stepping a task that is not paused to fail")
+	}
+
+	go te.ExecuteTask(context.Background(), task)
+	waitForState(t, store, task.ID, TaskStateStepWait)
+
+	paused, _ := store.GetTask(task.ID)
+	if len(paused.ToolFailures) != 0 {
+		t.Errorf("Expected no tool dispatch before the step, got %d failures", len(paused.ToolFailures))
+	}
+	if len(paused.Trace) != 1 || paused.Trace[0].ToolCalls[0] != "read_file" || len(paused.Trace[0].Prompt) == 0 {
+		t.Fatalf("Unexpected trace %+v", paused.Trace)
+	}
+
+	if err := te.StepTask(task.ID); err != nil {
+		t.Fatalf("StepTask: %v", err)
+	}
+	waitForState(t, store, task.ID, TaskStateCompleted)
+
+	done, _ := store.GetTask(task.ID)
+	if len(done.ToolFailures) != 1 || len(done.Trace) != 2 || done.Trace[1].Response != "Done." {
+		t.Errorf("Expected the tool to run and a second traced iteration, got failures=%d trace=%d", len(done.ToolFailures), len(done.Trace))
+	}
+}
@@ -0,0 +1,75 @@
+package a2a
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+)
+
+// maxSubTaskDepth bounds the walk from a task up to its root, guarding
+// against parent cycles in corrupted stores.
+const maxSubTaskDepth = 32
+
+// SubTaskStatus is the data of a sub_task_status SSE event.
+type SubTaskStatus struct {
+	TaskID       string    `json:"task_id"`
+	ParentTaskID string    `json:"parent_task_id"` // Direct parent; differs from the streamed task for nested sub-tasks
+	Name         string    `json:"name"`
+	State        TaskState `json:"state"`
+}
+
+// forwardSubTaskStatus sends a sub_task_status event on the stream of
+// rootID whenever one of its sub-tasks, at any depth, is created or changes
+// state, until the returned function is called.
+func (te *TaskExecutor) forwardSubTaskStatus(ctx context.Context, rootID string, sseWriter *SSEWriter) (stop func()) {
+	if !sseWriter.wants("sub_task_status") {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	pending := make(chan TaskEvent, sseWriter.eventBuffer)
+	unsubscribe := te.Subscribe(func(event TaskEvent) {
+		if event.TaskID == rootID || (event.Type != TaskEventCreated && event.Type != TaskEventStateChanged) {
+			return
+		}
+		select {
+		case pending <- event:
+		default:
+			log.Printf("[Task %s Stream] Dropped sub-task status of task %s: the stream is behind.", rootID, event.TaskID)
+		}
+	})
+	go func() {
+		for {
+			select {
+			case event := <-pending:
+				task, err := te.TaskStore.GetTask(event.TaskID)
+				if err != nil || !te.isDescendant(task, rootID) {
+					continue
+				}
+				data, _ := json.Marshal(SubTaskStatus{TaskID: task.ID, ParentTaskID: task.ParentTaskID, Name: task.Name, State: event.State})
+				sseWriter.SendEvent("sub_task_status", string(data))
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return func() {
+		unsubscribe()
+		cancel()
+	}
+}
+
+// isDescendant reports whether task is a sub-task of ancestorID at any depth.
+func (te *TaskExecutor) isDescendant(task *Task, ancestorID string) bool {
+	parentID := task.ParentTaskID
+	for depth := 0; parentID != "" && depth < maxSubTaskDepth; depth++ {
+		if parentID == ancestorID {
+			return true
+		}
+		parent, err := te.TaskStore.GetTask(parentID)
+		if err != nil {
+			return false
+		}
+		parentID = parent.ParentTaskID
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package a2a
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncRecorder is an httptest.ResponseRecorder whose body can be read while
+// events are written from other goroutines.
+type syncRecorder struct {
+	*httptest.ResponseRecorder
+	mu sync.Mutex
+}
+
+func (r *syncRecorder) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.ResponseRecorder.Write(p)
+}
+
+func (r *syncRecorder) body() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.ResponseRecorder.Body.String()
+}
+
+func TestSubTaskStatusIsForwardedToParentStream(t *testing.T) {
+	te := NewTaskExecutor(&staticLLMClient{reply: "done"}, NewInMemoryTaskStore(), nil, "")
+	parent, _ := te.TaskStore.CreateTask("parent", "", nil, "")
+	other, _ := te.TaskStore.CreateTask("other", "", nil, "")
+
+	rec := &syncRecorder{ResponseRecorder: httptest.NewRecorder()}
+	sseWriter, err := NewSSEWriter(rec, context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stop := te.forwardSubTaskStatus(context.Background(), parent.ID, sseWriter)
+	defer stop()
+
+	child, _ := te.TaskStore.CreateTask("child", "", nil, parent.ID)
+	grandchild, _ := te.TaskStore.CreateTask("grandchild", "", nil, child.ID)
+	unrelated, _ := te.TaskStore.CreateTask("unrelated", "", nil, other.ID)
+	te.TaskStore.SetState(grandchild.ID, TaskStateCompleted)
+	te.TaskStore.SetState(unrelated.ID, TaskStateCompleted)
+	te.TaskStore.SetState(parent.ID, TaskStateWorking)
+
+	want := `{"task_id":"` + grandchild.ID + `","parent_task_id":"` + child.ID + `","name":"grandchild","state":"COMPLETED"}`
+	deadline := time.Now().Add(2 * time.Second)
+	for !strings.Contains(rec.body(), want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %s on the stream, got:\n%s", want, rec.body())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	body := rec.body()
+	if !strings.Contains(body, `"name":"child","state":"SUBMITTED"`) {
+		t.Errorf("expected the creation of the child, got:\n%s", body)
+	}
+	if strings.Contains(body, unrelated.ID) || strings.Count(body, "event: sub_task_status") != 3 {
+		t.Errorf("expected exactly the three events of the parent's sub-tasks, got:\n%s", body)
+	}
+}
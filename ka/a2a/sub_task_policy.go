@@ -0,0 +1,98 @@
+package a2a
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// SubTaskPolicy decides what happens when a sub-task created via add_task fails.
+// Without escalation the parent is still told of the failure, but not relaunched.
+// It is set on the parent task, or defaults to TaskExecutor.SubTaskPolicy.
+type SubTaskPolicy struct {
+	MaxAttempts    int  `json:"maxAttempts,omitempty"`    // Total runs of a failing sub-task, including the first (<= 1 disables retry)
+	BackoffSeconds int  `json:"backoffSeconds,omitempty"` // Delay before a retry, multiplied by the attempt number
+	Escalate       bool `json:"escalate,omitempty"`       // Relaunch the parent with a sub-task that failed for good
+}
+
+// handleSubTaskEnd runs after a run of a sub-task ended. A failed sub-task
+// is retried with backoff under the parent's policy, or its failure is
+// escalated to the parent once the attempts are exhausted; otherwise the
+// parent is told the outcome of a finished sub-task. Events queued for a
+// task that ended are dropped, as it waits for none any more. It must be
+// called without holding a run claim on the task, since a retry starts a
+// new run.
+func (te *TaskExecutor) handleSubTaskEnd(taskID string) {
+	task, err := te.TaskStore.GetTask(taskID)
+	if err != nil {
+		return
+	}
+	if taskEnded(task.State) {
+		te.eventQueue.dropTask(taskID)
+	}
+	if task.ParentTaskID == "" {
+		return
+	}
+	switch task.State {
+	case TaskStateCompleted, TaskStateCanceled:
+		te.reportSubTaskOutcome(task)
+		return
+	case TaskStateFailed:
+	default:
+		return // Parked, e.g. waiting for input or an event
+	}
+	parent, err := te.TaskStore.GetTask(task.ParentTaskID)
+	if err != nil {
+		log.Printf("[Task %s] Cannot load parent task %s to apply the sub-task policy: %v", taskID, task.ParentTaskID, err)
+		return
+	}
+	policy := parent.SubTaskPolicy
+	if policy == nil {
+		policy = te.SubTaskPolicy
+	}
+	if policy == nil {
+		te.reportSubTaskOutcome(task)
+		return
+	}
+
+	updated, err := te.TaskStore.UpdateTask(taskID, func(t *Task) error {
+		t.Attempts++
+		return nil
+	})
+	if err != nil {
+		log.Printf("[Task %s] Failed to record failed attempt: %v", taskID, err)
+		return
+	}
+	attempts := updated.Attempts
+
+	if attempts < policy.MaxAttempts {
+		delay := time.Duration(policy.BackoffSeconds*attempts) * time.Second
+		log.Printf("[Task %s] Sub-task failed (attempt %d of %d). Retrying in %s.", taskID, attempts, policy.MaxAttempts, delay)
+		time.AfterFunc(delay, func() {
+			retryTask, err := te.TaskStore.GetTask(taskID)
+			if err != nil || retryTask.State != TaskStateFailed {
+				return // Deleted or restarted by someone else in the meantime
+			}
+			te.ExecuteTask(context.Background(), retryTask)
+		})
+		return
+	}
+
+	if !policy.Escalate {
+		log.Printf("[Task %s] Sub-task failed after %d attempt(s). No escalation configured.", taskID, attempts)
+		te.reportSubTaskOutcome(task)
+		return
+	}
+	log.Printf("[Task %s] Sub-task failed after %d attempt(s). Escalating to parent %s.", taskID, attempts, parent.ID)
+	note := Message{
+		Role: RoleUser,
+		Parts: []Part{TextPart{Type: "text", Text: fmt.Sprintf(
+			"[Sub-task failed] Sub-task %s (%q) failed after %d attempt(s): %s\nAdjust your plan: retry it differently, work around it, or report the failure.",
+			task.ID, task.Name, attempts, task.Error)}},
+		Timestamp: time.Now().UTC(),
+	}
+	if err := te.AddTaskMessageAndProcess(parent.ID, note); err != nil {
+		log.Printf("[Task %s] Failed to escalate sub-task failure to parent %s: %v", taskID, parent.ID, err)
+	}
+}
@@ -0,0 +1,66 @@
+package a2a
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"ka/llm"
+)
+
+type failingLLMClient struct{ calls atomic.Int32 }
+
+func (c *failingLLMClient) Chat(ctx context.Context, messages []llm.Message, stream bool, out io.Writer) (string, int, int, error) {
+	c.calls.Add(1)
+	return "", 0, 0, errors.New("backend unavailable")
+}
+
+func TestSubTaskFailureIsRetriedThenEscalated(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	client := &failingLLMClient{}
+	te := NewTaskExecutor(client, store, nil, "")
+
+	parent, _ := store.CreateTask("parent", "", nil, "")
+	store.UpdateTask(parent.ID, func(t *Task) error {
+		t.State = TaskStateCompleted
+		t.SubTaskPolicy = &SubTaskPolicy{MaxAttempts: 2, Escalate: true}
+		return nil
+	})
+	child, _ := store.CreateTask("child", "", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "do it"}}}}, parent.ID)
+
+	te.ExecuteTask(context.Background(), child)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		p, _ := taskSnapshot(store, parent.ID)
+		for _, msg := range p.Messages {
+			if text, ok := msg.Parts[0].(TextPart); ok && strings.Contains(text.Text, "[Sub-task failed]") {
+				c, _ := taskSnapshot(store, child.ID)
+				if c.Attempts != 2 {
+					t.Errorf("Expected 2 recorded attempts, got %d", c.Attempts)
+				}
+				return
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Expected the parent to be notified of the failed sub-task")
+}
+
+func TestSubTaskFailureWithoutPolicyIsLeftAlone(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	te := NewTaskExecutor(&failingLLMClient{}, store, nil, "")
+
+	parent, _ := store.CreateTask("parent", "", nil, "")
+	child, _ := store.CreateTask("child", "", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "do it"}}}}, parent.ID)
+	te.ExecuteTask(context.Background(), child)
+
+	c, _ := taskSnapshot(store, child.ID)
+	if c.State != TaskStateFailed || c.Attempts != 0 {
+		t.Errorf("Expected a failed sub-task without recorded attempts, got %s/%d", c.State, c.Attempts)
+	}
+}
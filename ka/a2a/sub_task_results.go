@@ -0,0 +1,100 @@
+package a2a
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+// SubTaskEvent is the event delivered to a parent task when one of its
+// sub-tasks finishes. A parent waits for its sub-tasks with
+// wait_for_event("sub_task"), and resumes with the outcome as the result.
+const SubTaskEvent = "sub_task"
+
+// maxSubTaskResultBytes limits the sub-task result reported to the parent.
+const maxSubTaskResultBytes = 4000
+
+// SubTaskOutcome is the payload of a SubTaskEvent.
+type SubTaskOutcome struct {
+	TaskID string    `json:"taskId"`
+	Name   string    `json:"name,omitempty"`
+	State  TaskState `json:"state"`
+	Result string    `json:"result,omitempty"` // The sub-task's last answer, truncated
+	Error  string    `json:"error,omitempty"`
+}
+
+// subTaskOutcome returns the outcome of a finished sub-task.
+func subTaskOutcome(task *Task) SubTaskOutcome {
+	outcome := SubTaskOutcome{TaskID: task.ID, Name: task.Name, State: task.State, Error: task.Error}
+	for i := len(task.Messages) - 1; i >= 0; i-- {
+		if task.Messages[i].Role == RoleAssistant {
+			outcome.Result = truncateOutput(messageText(task.Messages[i]), maxSubTaskResultBytes)
+			break
+		}
+	}
+	return outcome
+}
+
+// text renders the outcome as the message the parent reads.
+func (o SubTaskOutcome) text() string {
+	switch o.State {
+	case TaskStateCompleted:
+		return fmt.Sprintf("[Sub-task completed] Sub-task %s (%q) completed with result:\n%s", o.TaskID, o.Name, o.Result)
+	case TaskStateFailed:
+		return fmt.Sprintf("[Sub-task failed] Sub-task %s (%q) failed: %s", o.TaskID, o.Name, o.Error)
+	default:
+		return fmt.Sprintf("[Sub-task %s] Sub-task %s (%q) ended in state %s.", o.State, o.TaskID, o.Name, o.State)
+	}
+}
+
+// reportSubTaskOutcome tells the parent of a finished sub-task how it ended.
+// A parent waiting for SubTaskEvent is resumed with the outcome as the
+// result of its wait. For a parent that still runs the event is queued, so
+// a later wait returns at once; a parent that ended, and waits for nothing
+// any more, gets the outcome appended to its history instead.
+func (te *TaskExecutor) reportSubTaskOutcome(task *Task) {
+	outcome := subTaskOutcome(task)
+	payload, err := json.Marshal(outcome)
+	if err != nil {
+		log.Printf("[Task %s] Failed to encode sub-task outcome: %v", task.ID, err)
+		return
+	}
+	event := QueuedEvent{Event: SubTaskEvent, TaskID: task.ParentTaskID, Payload: payload, ReceivedAt: time.Now().UTC()}
+	note := Message{
+		Role:      RoleUser,
+		Parts:     []Part{TextPart{Type: "text", Text: outcome.text()}},
+		Timestamp: time.Now().UTC(),
+	}
+	waiting, ended := false, false
+	if _, err := te.TaskStore.UpdateTask(task.ParentTaskID, func(parent *Task) error {
+		switch {
+		case parent.State == TaskStateWaitingEvent && parent.EventWait != nil && parent.EventWait.Event == SubTaskEvent:
+			waiting = true // The outcome becomes the result of the wait
+		case taskEnded(parent.State):
+			ended = true
+			parent.Messages = append(parent.Messages, note)
+		}
+		return nil
+	}); err != nil {
+		log.Printf("[Task %s] Failed to report sub-task outcome to parent %s: %v", task.ID, task.ParentTaskID, err)
+		return
+	}
+	if waiting {
+		if err := te.resumeWithEvent(task.ParentTaskID, event); err == nil {
+			log.Printf("[Task %s] Reported %s to waiting parent %s.", task.ID, task.State, task.ParentTaskID)
+			return
+		}
+	}
+	if ended {
+		log.Printf("[Task %s] Reported %s to finished parent %s.", task.ID, task.State, task.ParentTaskID)
+		return
+	}
+	te.eventQueue.push(event)
+	log.Printf("[Task %s] Queued %s for parent %s.", task.ID, task.State, task.ParentTaskID)
+}
+
+// taskEnded reports whether a task in state has ended its work.
+func taskEnded(state TaskState) bool {
+	return state == TaskStateCompleted || state == TaskStateFailed || state == TaskStateCanceled
+}
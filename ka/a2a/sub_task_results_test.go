@@ -0,0 +1,126 @@
+package a2a
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"ka/llm"
+	"ka/tools"
+)
+
+func TestWaitingParentResumesWithSubTaskOutcome(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	client := &scriptedLLMClient{replies: []string{`<tool id="wait_for_event">{"event": "sub_task"}</tool>`, "42 files.", "The child counted 42 files."}}
+	te := NewTaskExecutor(client, store, map[string]tools.Tool{"wait_for_event": &tools.WaitForEventTool{}}, "")
+
+	parent, _ := store.CreateTask("parent", "", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "delegate"}}}}, "")
+	te.ExecuteTask(context.Background(), parent)
+	waitForState(t, store, parent.ID, TaskStateWaitingEvent)
+
+	child, _ := store.CreateTask("count", "", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "count files"}}}}, parent.ID)
+	te.ExecuteTask(context.Background(), child)
+	waitForState(t, store, parent.ID, TaskStateCompleted)
+
+	done, _ := store.GetTask(parent.ID)
+	var result string
+	for _, msg := range done.Messages {
+		if msg.Role == RoleTool && strings.Contains(messageText(msg), child.ID) {
+			result = messageText(msg)
+		}
+	}
+	if !strings.Contains(result, `"state":"COMPLETED"`) || !strings.Contains(result, "42 files.") {
+		t.Errorf("expected the outcome as the result of the wait, got %q", result)
+	}
+}
+
+func TestFinishedSubTaskIsReportedToIdleParent(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	te := NewTaskExecutor(&failingLLMClient{}, store, nil, "")
+
+	parent, _ := store.CreateTask("parent", "", nil, "")
+	store.UpdateTask(parent.ID, func(t *Task) error {
+		t.State = TaskStateCompleted
+		return nil
+	})
+	child, _ := store.CreateTask("child", "", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "do it"}}}}, parent.ID)
+	te.ExecuteTask(context.Background(), child)
+
+	p, _ := store.GetTask(parent.ID)
+	if p.State != TaskStateCompleted || len(p.Messages) != 1 || !strings.HasPrefix(messageText(p.Messages[0]), "[Sub-task failed] Sub-task "+child.ID) {
+		t.Fatalf("expected the failure appended without relaunching the parent, got %s %+v", p.State, p.Messages)
+	}
+	if queued := te.eventQueue.list(); len(queued) != 0 {
+		t.Errorf("expected nothing queued for a finished parent, got %+v", queued)
+	}
+}
+
+func TestFinishedSubTaskIsQueuedForRunningParent(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	te := NewTaskExecutor(&failingLLMClient{}, store, nil, "")
+
+	parent, _ := store.CreateTask("parent", "", nil, "")
+	store.SetState(parent.ID, TaskStateWorking)
+	child, _ := store.CreateTask("child", "", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "do it"}}}}, parent.ID)
+	te.ExecuteTask(context.Background(), child)
+
+	if p, _ := store.GetTask(parent.ID); len(p.Messages) != 0 {
+		t.Errorf("expected the outcome only queued, not also appended, got %+v", p.Messages)
+	}
+	// A later wait for the sub-task returns at once
+	queued := te.eventQueue.list()
+	if len(queued) != 1 || queued[0].TaskID != parent.ID || !strings.Contains(string(queued[0].Payload), `"state":"FAILED"`) {
+		t.Fatalf("expected the outcome to be queued for the parent, got %+v", queued)
+	}
+
+	// Once the parent ends, nothing waits for the event any more
+	store.SetState(parent.ID, TaskStateCompleted)
+	te.handleSubTaskEnd(parent.ID)
+	if queued := te.eventQueue.list(); len(queued) != 0 {
+		t.Errorf("expected the events of the finished parent to be dropped, got %+v", queued)
+	}
+}
+
+// conversationLLMClient answers with the reply fn picks for the conversation,
+// for tests running several tasks at once.
+type conversationLLMClient func(messages []llm.Message) string
+
+func (fn conversationLLMClient) Chat(ctx context.Context, messages []llm.Message, stream bool, out io.Writer) (string, int, int, error) {
+	reply := fn(messages)
+	io.WriteString(out, reply)
+	return reply, 0, 0, nil
+}
+
+func TestAddTaskRunsSubTaskAndNotifiesParent(t *testing.T) {
+	client := conversationLLMClient(func(messages []llm.Message) string {
+		last := messages[len(messages)-1]
+		switch {
+		case messages[0].Content != "delegate":
+			return "42 files." // The sub-task
+		case strings.Contains(last.Content, `"state":"COMPLETED"`):
+			return "The child counted 42 files."
+		case strings.Contains(last.Content, `"tool_name":"add_task"`):
+			return `<tool id="wait_for_event">{"event": "sub_task"}</tool>`
+		default:
+			return `<tool id="add_task">{"name": "count", "description": "count files", "context": "Count the files."}</tool>`
+		}
+	})
+	store := NewInMemoryTaskStore()
+	te := NewTaskExecutor(client, store, map[string]tools.Tool{"add_task": &tools.AddTaskTool{}, "wait_for_event": &tools.WaitForEventTool{}}, "")
+
+	parent, _ := store.CreateTask("parent", "", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "delegate"}}}}, "")
+	te.ExecuteTask(context.Background(), parent)
+	waitForState(t, store, parent.ID, TaskStateCompleted)
+
+	done, _ := store.GetTask(parent.ID)
+	if answer := messageText(done.Messages[len(done.Messages)-1]); answer != "The child counted 42 files." {
+		t.Errorf("expected the parent to finish with the sub-task outcome, got %q", answer)
+	}
+	tasks, _ := store.ListTasks()
+	for _, task := range tasks {
+		if task.ParentTaskID == parent.ID && task.State != TaskStateCompleted {
+			t.Errorf("expected the sub-task to have run, got %s", task.State)
+		}
+	}
+}
@@ -19,10 +19,10 @@ var ErrTaskNotFound = errors.New("task not found")
 
 type TaskState string
 
-type TaskStatus struct { 
-	State TaskState `json:"state"` 
-	message *Message `json:"message,omitempty"`
-	Timestamp string `json:"timestamp"`
+type TaskStatus struct {
+	State     TaskState `json:"state"`
+	Message   *Message  `json:"message,omitempty"`
+	Timestamp string    `json:"timestamp"`
 }
 
 
@@ -33,6 +33,10 @@ const (
 	TaskStateCompleted     TaskState = "COMPLETED"      // Changed to uppercase
 	TaskStateFailed        TaskState = "FAILED"         // Changed to uppercase
 	TaskStateCanceled      TaskState = "CANCELED"       // Changed to uppercase
+	TaskStateStepWait      TaskState = "STEP_WAIT"      // Debug task paused before dispatching tool calls, see tasks/step
+	TaskStateStalled       TaskState = "STALLED"        // WORKING task whose executor stopped sending heartbeats
+	TaskStateWaitingEvent  TaskState = "WAITING_EVENT"  // Suspended by wait_for_event until events/deliver posts the event
+	TaskStateInterrupted   TaskState = "INTERRUPTED"    // Run stopped by a shutdown of the agent; resumed with -resume-interrupted
 )
 
 type MessageRole string
@@ -84,6 +88,18 @@ type Message struct {
 	ToolCallID string `json:"tool_call_id,omitempty"`
 	Timestamp  time.Time `json:"timestamp"` // Add timestamp to message
 	TimestampUnixMs int64 `json:"timestamp_unix_ms"` // Add Unix timestamp in milliseconds
+	Truncated  bool      `json:"truncated,omitempty"` // The answer was cut off by the max output token limit and is incomplete
+	Provenance *MessageProvenance `json:"provenance,omitempty"` // Which provider and model produced an assistant message
+}
+
+// MessageProvenance records how an assistant message was generated, so
+// tasks mixing providers or models stay auditable.
+type MessageProvenance struct {
+	Provider    string   `json:"provider,omitempty"`
+	Model       string   `json:"model,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	MaxTokens   int      `json:"max_tokens,omitempty"`
+	LatencyMs   int64    `json:"latency_ms"` // Of the LLM requests, including continuations
 }
 
 // ToolCall represents a single tool call parsed from the simplified XML structure.
@@ -139,6 +155,8 @@ func (m *Message) UnmarshalJSON(data []byte) error {
 	m.Role = tmp.Role
 	m.Parts = make([]Part, 0, len(tmp.Parts))
 	m.ToolCallID = tmp.ToolCallID
+	m.Timestamp, m.TimestampUnixMs = tmp.Timestamp, tmp.TimestampUnixMs
+	m.Truncated, m.Provenance = tmp.Truncated, tmp.Provenance
 	// RawToolCallsXML and ParsedToolCalls are not unmarshalled from the standard JSON message
 
 	for i, rawPart := range tmp.Parts {
@@ -170,8 +188,7 @@ func (m *Message) UnmarshalJSON(data []byte) error {
 			err = json.Unmarshal(rawPart, &dataPart)
 			part = dataPart
 		default:
-			log.Printf("Warning: Unrecognized part type '%s' for part %d. Raw: %s", partType, i, string(rawPart))
-			continue
+			part, err = decodeCustomPart(partType, rawPart)
 		}
 
 		if err != nil {
@@ -304,8 +321,11 @@ func (m *Message) ParseToolCallsFromXML() error {
 type Artifact struct {
 	ID       string `json:"id"`
 	Type     string `json:"type"`
-	Filename string `json:"filename,omitempty"`
-	Data     []byte `json:"data,omitempty"`
+	Filename string       `json:"filename,omitempty"`
+	Data     []byte       `json:"data,omitempty"`
+	Hints    *RenderHints `json:"hints,omitempty"` // Computed when the artifact is added
+	Streamed bool         `json:"streamed,omitempty"` // Data is appended in chunks and kept outside the task record
+	Size     int64        `json:"size,omitempty"`     // Bytes of a streamed artifact
 }
 
 type Task struct {
@@ -313,23 +333,66 @@ type Task struct {
 	Name         string               `json:"name,omitempty"` // Added Name field for task list display
 	State        TaskState            `json:"state"`
 	SystemPrompt string               `json:"system_prompt,omitempty"` // Added SystemPrompt field
+	SystemPromptHistory []SystemPromptVersion `json:"system_prompt_history,omitempty"` // Replaced system prompts, see tasks/update
 	Messages     []Message            `json:"messages,omitempty"`      // Replace Input/Output with a single Messages array
 	Error        string               `json:"error,omitempty"`
+	ErrorDetail  *ErrorDetail         `json:"error_detail,omitempty"` // Structured form of Error
 	CreatedAt    time.Time            `json:"created_at"`
 	CreatedAtUnixMs int64 `json:"created_at_unix_ms"` // Add Unix timestamp in milliseconds
 	UpdatedAt    time.Time            `json:"updated_at"`
 	UpdatedAtUnixMs int64 `json:"updated_at_unix_ms"` // Add Unix timestamp in milliseconds
 	Artifacts    map[string]*Artifact `json:"artifacts,omitempty"`
 	ParentTaskID string               `json:"parent_task_id,omitempty"` // Added ParentTaskID
+	RemoteTasks  []RemoteTaskLink     `json:"remote_tasks,omitempty"` // Tasks delegated to other agents, see tasks/thread
+	ToolFailures []ToolFailure        `json:"tool_failures,omitempty"`  // Failed tool calls, reported back to the LLM
+	InputRequest *tools.InputForm     `json:"input_request,omitempty"`  // Pending question while INPUT_REQUIRED
+	Mode         string               `json:"mode,omitempty"`           // Mode preset the task runs in
+	Provider     string               `json:"provider,omitempty"`       // LLM provider the task runs with; empty uses the agent's
+	Model        string               `json:"model,omitempty"`          // Model the task runs with; empty uses the mode's or the client's
+	SubTaskPolicy *SubTaskPolicy      `json:"sub_task_policy,omitempty"` // Retry/escalation for failing sub-tasks of this task
+	Attempts     int                  `json:"attempts,omitempty"`       // Failed runs so far, counted when a sub-task policy applies
+	Debug        bool                 `json:"debug,omitempty"`          // Pause in STEP_WAIT each iteration and record the trace
+	Trace        []TraceEntry         `json:"trace,omitempty"`          // Prompts and responses per iteration of a debug task
+	Notes        []TaskNote           `json:"notes,omitempty"`          // Human annotations, see tasks/addNote
+	Feedback     []Feedback           `json:"feedback,omitempty"`       // Thumbs up/down signals, see tasks/feedback
+	Project      string               `json:"project,omitempty"`        // Project the task belongs to; sub-tasks inherit it
+	SessionID    string               `json:"session_id,omitempty"`     // sessionId of tasks/send; sub-tasks inherit it, see AffinityRing
+	Labels       []string             `json:"labels,omitempty"`
+	HeartbeatAt  time.Time            `json:"heartbeat_at,omitempty"`   // Last sign of life from the executor running the task
+	StallRestarts int                 `json:"stall_restarts,omitempty"` // Automatic restarts after stalling
+	TimeoutSeconds int                `json:"timeout_seconds,omitempty"` // Execution timeout; 0 runs without one
+	ToolQuotas   ToolQuotas           `json:"tool_quotas,omitempty"`    // Overrides the agent's quotas per tool
+	ToolUsage    map[string]ToolUsage `json:"tool_usage,omitempty"`     // Calls and result bytes per tool
+	Deadline     time.Time            `json:"deadline,omitempty"`       // Set when the task first runs with a timeout
+	GuardrailEvents []GuardrailEvent  `json:"guardrail_events,omitempty"` // Guardrail rules that matched
+	Approvals    []ToolApproval       `json:"approvals,omitempty"`      // Tool calls held back for approval, see tasks/approve
+	EventWait    *EventWait           `json:"event_wait,omitempty"`     // Event awaited while WAITING_EVENT, see events/deliver
+	MaxOutputTokens  int              `json:"max_output_tokens,omitempty"` // Completion token limit per LLM call; overrides the mode
+	MaxContinuations int              `json:"max_continuations,omitempty"` // Continuations of completions cut off by the limit; overrides the mode and agent default
+	OutputArtifact bool               `json:"output_artifact,omitempty"` // Stream LLM output into artifacts, keeping a preview in the messages
+	Trash        *TaskTrash           `json:"trash,omitempty"`          // Set while the task is soft-deleted
+	StartedAt    time.Time            `json:"started_at,omitempty"`     // Start of the first run
+	FinishedAt   time.Time            `json:"finished_at,omitempty"`    // End of the latest run
+	LLMTimeMs    int64                `json:"llm_time_ms,omitempty"`    // Time spent waiting for LLM responses
+	ToolTimeMs   int64                `json:"tool_time_ms,omitempty"`   // Time spent executing tool calls
+	PromptTokens int                  `json:"prompt_tokens,omitempty"`  // Input tokens of all LLM calls
+	CompletionTokens int              `json:"completion_tokens,omitempty"` // Output tokens of all LLM calls
+	MaxTokens    int                  `json:"max_tokens,omitempty"`     // Token budget; the task fails when it uses more. 0 is unlimited
+	Priority     int                  `json:"priority,omitempty"`       // Order of its LLM requests in the provider queue, see llm.WithPriority
+	OutputContract *OutputContract    `json:"output_contract,omitempty"` // Artifacts required before the task may complete
+	ContractCorrections int           `json:"contract_corrections,omitempty"` // Corrective messages sent for missing artifacts
+	Checkpoint   *TaskCheckpoint      `json:"checkpoint,omitempty"`     // Execution state saved for resuming on another instance, see CheckpointEvery
+	HistorySummary *HistorySummary    `json:"history_summary,omitempty"` // Summary of the oldest turns, see SummarizeStrategy
 }
 
 type InMemoryTaskStore struct {
-	mu    sync.RWMutex
-	tasks map[string]*Task
+	mu     sync.RWMutex
+	tasks  map[string]*Task
+	events map[string][]TaskLogEntry
 }
 
 func NewInMemoryTaskStore() *InMemoryTaskStore {
-	return &InMemoryTaskStore{tasks: make(map[string]*Task)}
+	return &InMemoryTaskStore{tasks: make(map[string]*Task), events: make(map[string][]TaskLogEntry)}
 }
 
 // CreateTask creates a new task with the given name, system prompt, input messages, and parent task ID.
@@ -435,6 +498,9 @@ func (s *InMemoryTaskStore) AddArtifact(taskID string, artifact Artifact) error
 			task.Artifacts = make(map[string]*Artifact)
 		}
 		artCopy := artifact
+		if artCopy.Hints == nil {
+			artCopy.Hints = computeRenderHints(artCopy)
+		}
 		task.Artifacts[artCopy.ID] = &artCopy
 		fmt.Printf("[TaskStore] Added/Updated Artifact %s to Task %s (Type: %s, Size: %d bytes)\n", artCopy.ID, taskID, artCopy.Type, len(artCopy.Data))
 		return nil
@@ -461,7 +527,7 @@ func (s *InMemoryTaskStore) GetArtifactData(taskID string, artifactID string) ([
 
 func (s *InMemoryTaskStore) ListTasks() ([]*Task, error) {
 	s.mu.RLock()
-	defer s.mu.Unlock()
+	defer s.mu.RUnlock()
 
 	taskList := make([]*Task, 0, len(s.tasks))
 	for _, task := range s.tasks {
@@ -470,6 +536,18 @@ func (s *InMemoryTaskStore) ListTasks() ([]*Task, error) {
 	return taskList, nil
 }
 
+// WalkTasks implements TaskWalker. fn runs without the store lock held, so it
+// may call back into the store.
+func (s *InMemoryTaskStore) WalkTasks(fn func(*Task) error) error {
+	tasks, _ := s.ListTasks()
+	for _, task := range tasks {
+		if err := fn(task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *InMemoryTaskStore) DeleteTask(taskID string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -478,10 +556,38 @@ func (s *InMemoryTaskStore) DeleteTask(taskID string) error {
 		return ErrTaskNotFound
 	}
 	delete(s.tasks, taskID)
+	delete(s.events, taskID)
 	fmt.Printf("[TaskStore] Deleted Task: %s\n", taskID)
 	return nil
 }
 
+// AppendTaskEvent implements TaskEventLog.
+func (s *InMemoryTaskStore) AppendTaskEvent(taskID string, entry *TaskLogEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.tasks[taskID]; !ok {
+		return ErrTaskNotFound
+	}
+	entry.Seq = int64(len(s.events[taskID]) + 1)
+	s.events[taskID] = append(s.events[taskID], *entry)
+	return nil
+}
+
+// ListTaskEvents implements TaskEventLog.
+func (s *InMemoryTaskStore) ListTaskEvents(taskID string, after int64, limit int) ([]TaskLogEntry, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	events := s.events[taskID]
+	if after >= int64(len(events)) {
+		return nil, nil
+	}
+	events = events[after:]
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	return append([]TaskLogEntry(nil), events...), nil
+}
+
 type TaskStore interface {
 	CreateTask(name string, systemPrompt string, inputMessages []Message, parentTaskID string) (*Task, error) // Updated signature
 	GetTask(taskID string) (*Task, error)
@@ -0,0 +1,265 @@
+package a2a
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TaskBundleFormatVersion is bumped whenever the bundle layout changes in a
+// way older importers cannot read.
+const TaskBundleFormatVersion = 1
+
+// TaskBundle is a self-contained snapshot of a task and its sub-tasks,
+// including messages and artifact data, that can be moved between agents.
+type TaskBundle struct {
+	FormatVersion int               `json:"format_version"`
+	ExportedAt    time.Time         `json:"exported_at"`
+	RootTaskID    string            `json:"root_task_id"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	Tasks         []*Task           `json:"tasks"`
+}
+
+// ImportResult maps the IDs found in a bundle to the IDs assigned on import.
+type ImportResult struct {
+	RootTaskID  string            `json:"root_task_id"`
+	TaskIDs     map[string]string `json:"task_ids"`
+	ArtifactIDs map[string]string `json:"artifact_ids"`
+}
+
+// ExportTaskBundle collects the task with the given ID and all of its
+// descendants (parents first) into a bundle.
+func ExportTaskBundle(store TaskStore, taskID string, metadata map[string]string) (*TaskBundle, error) {
+	root, err := store.GetTask(taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	allTasks, err := store.ListTasks()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tasks for export: %w", err)
+	}
+	children := make(map[string][]*Task)
+	for _, t := range allTasks {
+		if t.ParentTaskID != "" {
+			children[t.ParentTaskID] = append(children[t.ParentTaskID], t)
+		}
+	}
+
+	bundle := &TaskBundle{
+		FormatVersion: TaskBundleFormatVersion,
+		ExportedAt:    time.Now().UTC(),
+		RootTaskID:    root.ID,
+		Metadata:      metadata,
+	}
+	seen := map[string]bool{}
+	queue := []*Task{root}
+	for len(queue) > 0 {
+		t := queue[0]
+		queue = queue[1:]
+		if seen[t.ID] {
+			continue
+		}
+		seen[t.ID] = true
+		exported, err := inlineStreamedArtifacts(store, t)
+		if err != nil {
+			return nil, err
+		}
+		bundle.Tasks = append(bundle.Tasks, exported)
+		queue = append(queue, children[t.ID]...)
+	}
+
+	log.Printf("[TaskBundle] Exported task %s with %d task(s).", taskID, len(bundle.Tasks))
+	return bundle, nil
+}
+
+// inlineStreamedArtifacts returns t with the data of its streamed artifacts,
+// which the store keeps outside the task record, copied into the artifacts.
+func inlineStreamedArtifacts(store TaskStore, t *Task) (*Task, error) {
+	streamed := false
+	for _, artifact := range t.Artifacts {
+		streamed = streamed || (artifact != nil && artifact.Streamed)
+	}
+	if !streamed {
+		return t, nil
+	}
+	exported := *t
+	exported.Artifacts = make(map[string]*Artifact, len(t.Artifacts))
+	for id, artifact := range t.Artifacts {
+		if artifact != nil && artifact.Streamed {
+			data, _, err := store.GetArtifactData(t.ID, id)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read artifact %s of task %s for export: %w", id, t.ID, err)
+			}
+			artCopy := *artifact
+			artCopy.Data, artCopy.Streamed, artCopy.Size = data, false, 0
+			artifact = &artCopy
+		}
+		exported.Artifacts[id] = artifact
+	}
+	return &exported, nil
+}
+
+// ImportTaskBundle recreates the tasks of a bundle in the store. Every task and
+// artifact gets a fresh ID; parent links and file part artifact references are
+// rewritten to the new IDs. Tasks that were still running at export time are
+// imported as FAILED because no executor is attached to them on this agent.
+// The tasks are put in project, when set. Either the whole bundle is imported
+// or, on error, none of it.
+func ImportTaskBundle(store TaskStore, bundle *TaskBundle, project string) (*ImportResult, error) {
+	if err := validateTaskBundle(bundle); err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{
+		TaskIDs:     make(map[string]string),
+		ArtifactIDs: make(map[string]string),
+	}
+	var created []string
+	fail := func(err error) (*ImportResult, error) {
+		for _, id := range created {
+			if delErr := store.DeleteTask(id); delErr != nil {
+				log.Printf("[TaskBundle] Failed to remove task %s of a failed import: %v", id, delErr)
+			}
+		}
+		return nil, err
+	}
+
+	for _, t := range bundle.Tasks {
+		task, err := store.CreateTask(t.Name, t.SystemPrompt, nil, "")
+		if err != nil {
+			return fail(fmt.Errorf("failed to create task for imported %s: %w", t.ID, err))
+		}
+		created = append(created, task.ID)
+		result.TaskIDs[t.ID] = task.ID
+		for oldID := range t.Artifacts {
+			result.ArtifactIDs[oldID] = uuid.NewString()
+		}
+	}
+
+	for _, t := range bundle.Tasks {
+		newID := result.TaskIDs[t.ID]
+		imported, err := cloneTask(t)
+		if err != nil {
+			return fail(fmt.Errorf("failed to copy imported task %s: %w", t.ID, err))
+		}
+		imported.ID = newID
+		if t.State == TaskStateWorking || t.State == TaskStateSubmitted {
+			imported.State = TaskStateFailed
+			imported.Error = fmt.Sprintf("imported while in state %s; send a new message to continue", t.State)
+			imported.ErrorDetail = nil
+		}
+		imported.ParentTaskID = result.TaskIDs[t.ParentTaskID]
+		if project != "" {
+			imported.Project = project
+		}
+		imported.Messages = remapMessages(imported.Messages, result)
+		artifacts := imported.Artifacts
+		imported.Artifacts = make(map[string]*Artifact, len(artifacts))
+		for oldID, artifact := range artifacts {
+			artifact.ID = result.ArtifactIDs[oldID]
+			imported.Artifacts[artifact.ID] = artifact
+		}
+		_, err = store.UpdateTask(newID, func(task *Task) error {
+			*task = *imported
+			return nil
+		})
+		if err != nil {
+			return fail(fmt.Errorf("failed to populate imported task %s: %w", newID, err))
+		}
+	}
+
+	rootID := bundle.RootTaskID
+	if rootID == "" {
+		rootID = bundle.Tasks[0].ID
+	}
+	result.RootTaskID = result.TaskIDs[rootID]
+	log.Printf("[TaskBundle] Imported %d task(s), root %s -> %s.", len(bundle.Tasks), rootID, result.RootTaskID)
+	return result, nil
+}
+
+// validateTaskBundle checks a bundle before anything of it is imported.
+func validateTaskBundle(bundle *TaskBundle) error {
+	if bundle == nil || len(bundle.Tasks) == 0 {
+		return fmt.Errorf("bundle contains no tasks")
+	}
+	if bundle.FormatVersion > TaskBundleFormatVersion {
+		return fmt.Errorf("unsupported bundle format version %d (max %d)", bundle.FormatVersion, TaskBundleFormatVersion)
+	}
+	taskIDs := make(map[string]bool, len(bundle.Tasks))
+	artifactIDs := make(map[string]bool)
+	for _, t := range bundle.Tasks {
+		if t == nil || t.ID == "" {
+			return fmt.Errorf("bundle contains a task without an ID")
+		}
+		if taskIDs[t.ID] {
+			return fmt.Errorf("bundle contains task %s more than once", t.ID)
+		}
+		taskIDs[t.ID] = true
+		for oldID, artifact := range t.Artifacts {
+			if artifact == nil {
+				return fmt.Errorf("task %s of the bundle contains an empty artifact %s", t.ID, oldID)
+			}
+			if artifactIDs[oldID] {
+				return fmt.Errorf("bundle contains artifact %s more than once", oldID)
+			}
+			artifactIDs[oldID] = true
+		}
+	}
+	if bundle.RootTaskID != "" && !taskIDs[bundle.RootTaskID] {
+		return fmt.Errorf("root task %s is not in the bundle", bundle.RootTaskID)
+	}
+	return nil
+}
+
+// cloneTask deep-copies every persisted field of a task.
+func cloneTask(t *Task) (*Task, error) {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return nil, err
+	}
+	var clone Task
+	if err := json.Unmarshal(data, &clone); err != nil {
+		return nil, err
+	}
+	return &clone, nil
+}
+
+// remapMessages copies messages, rewriting artifact references and URIs that
+// point at old task or artifact IDs.
+func remapMessages(messages []Message, result *ImportResult) []Message {
+	remapped := make([]Message, len(messages))
+	for i, msg := range messages {
+		parts := make([]Part, len(msg.Parts))
+		for j, part := range msg.Parts {
+			if fp, ok := part.(FilePart); ok {
+				if newID, ok := result.ArtifactIDs[fp.ArtifactID]; ok {
+					fp.ArtifactID = newID
+				}
+				fp.URI = remapIDsInString(fp.URI, result)
+				part = fp
+			}
+			parts[j] = part
+		}
+		msg.Parts = parts
+		remapped[i] = msg
+	}
+	return remapped
+}
+
+func remapIDsInString(s string, result *ImportResult) string {
+	if s == "" {
+		return s
+	}
+	for oldID, newID := range result.TaskIDs {
+		s = strings.ReplaceAll(s, oldID, newID)
+	}
+	for oldID, newID := range result.ArtifactIDs {
+		s = strings.ReplaceAll(s, oldID, newID)
+	}
+	return s
+}
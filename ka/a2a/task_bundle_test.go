@@ -0,0 +1,200 @@
+package a2a
+
+import (
+	"fmt"
+	"testing"
+
+	"ka/tools"
+)
+
+func TestExportTaskBundle_IncludesSubTasks(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	parent, _ := store.CreateTask("parent", "", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "Hi"}}}}, "")
+	store.CreateTask("child", "", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "Sub"}}}}, parent.ID)
+
+	bundle, err := ExportTaskBundle(store, parent.ID, nil)
+	if err != nil {
+		t.Fatalf("ExportTaskBundle failed: %v", err)
+	}
+	if len(bundle.Tasks) != 2 {
+		t.Fatalf("Expected 2 tasks in bundle, got %d", len(bundle.Tasks))
+	}
+	if bundle.Tasks[0].ID != parent.ID {
+		t.Errorf("Expected root task first, got %s", bundle.Tasks[0].ID)
+	}
+}
+
+func TestImportTaskBundle_RemapsArtifactReferences(t *testing.T) {
+	source := NewInMemoryTaskStore()
+	task, _ := source.CreateTask("source", "prompt", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "Hi"}}}}, "")
+	source.AddArtifact(task.ID, Artifact{ID: "art-1", Type: "text/plain", Data: []byte("data")})
+	source.AddMessage(task.ID, Message{Role: RoleAssistant, Parts: []Part{FilePart{Type: "file", MimeType: "text/plain", ArtifactID: "art-1"}}})
+	source.SetState(task.ID, TaskStateCompleted)
+
+	bundle, err := ExportTaskBundle(source, task.ID, nil)
+	if err != nil {
+		t.Fatalf("ExportTaskBundle failed: %v", err)
+	}
+
+	target := NewInMemoryTaskStore()
+	result, err := ImportTaskBundle(target, bundle, "")
+	if err != nil {
+		t.Fatalf("ImportTaskBundle failed: %v", err)
+	}
+
+	imported, err := target.GetTask(result.RootTaskID)
+	if err != nil {
+		t.Fatalf("Imported task not found: %v", err)
+	}
+	newArtifactID := result.ArtifactIDs["art-1"]
+	if _, ok := imported.Artifacts[newArtifactID]; !ok {
+		t.Errorf("Expected artifact %s in imported task", newArtifactID)
+	}
+	filePart, ok := imported.Messages[1].Parts[0].(FilePart)
+	if !ok || filePart.ArtifactID != newArtifactID {
+		t.Errorf("Expected file part to reference %s, got %+v", newArtifactID, imported.Messages[1].Parts[0])
+	}
+	if imported.State != TaskStateCompleted {
+		t.Errorf("Expected state %s, got %s", TaskStateCompleted, imported.State)
+	}
+}
+
+func TestImportTaskBundle_WorkingTaskBecomesFailed(t *testing.T) {
+	bundle := &TaskBundle{
+		FormatVersion: TaskBundleFormatVersion,
+		Tasks:         []*Task{{ID: "old", Name: "running", State: TaskStateWorking}},
+	}
+
+	store := NewInMemoryTaskStore()
+	result, err := ImportTaskBundle(store, bundle, "")
+	if err != nil {
+		t.Fatalf("ImportTaskBundle failed: %v", err)
+	}
+	imported, _ := store.GetTask(result.RootTaskID)
+	if imported.State != TaskStateFailed {
+		t.Errorf("Expected state %s, got %s", TaskStateFailed, imported.State)
+	}
+}
+
+func TestImportTaskBundle_RejectsInvalidBundlesBeforeCreatingTasks(t *testing.T) {
+	for name, bundle := range map[string]*TaskBundle{
+		"nil artifact":       {Tasks: []*Task{{ID: "a", Artifacts: map[string]*Artifact{"x": nil}}}},
+		"nil task":           {Tasks: []*Task{{ID: "a"}, nil}},
+		"duplicate task":     {Tasks: []*Task{{ID: "a"}, {ID: "a"}}},
+		"duplicate artifact": {Tasks: []*Task{{ID: "a", Artifacts: map[string]*Artifact{"x": {}}}, {ID: "b", Artifacts: map[string]*Artifact{"x": {}}}}},
+		"unknown root":       {RootTaskID: "b", Tasks: []*Task{{ID: "a"}}},
+	} {
+		store := NewInMemoryTaskStore()
+		if _, err := ImportTaskBundle(store, bundle, ""); err == nil {
+			t.Errorf("%s: expected the bundle to be rejected", name)
+		}
+		if tasks, _ := store.ListTasks(); len(tasks) != 0 {
+			t.Errorf("%s: expected no task to be created, got %d", name, len(tasks))
+		}
+	}
+}
+
+// failingUpdateStore fails the update of the task created last.
+type failingUpdateStore struct {
+	*InMemoryTaskStore
+	last string
+}
+
+func (s *failingUpdateStore) CreateTask(name string, systemPrompt string, inputMessages []Message, parentTaskID string) (*Task, error) {
+	task, err := s.InMemoryTaskStore.CreateTask(name, systemPrompt, inputMessages, parentTaskID)
+	if err == nil {
+		s.last = task.ID
+	}
+	return task, err
+}
+
+func (s *failingUpdateStore) UpdateTask(taskID string, updateFn func(*Task) error) (*Task, error) {
+	if taskID == s.last {
+		return nil, fmt.Errorf("disk full")
+	}
+	return s.InMemoryTaskStore.UpdateTask(taskID, updateFn)
+}
+
+func TestImportTaskBundle_RemovesCreatedTasksOnError(t *testing.T) {
+	store := &failingUpdateStore{InMemoryTaskStore: NewInMemoryTaskStore()}
+	bundle := &TaskBundle{Tasks: []*Task{{ID: "a"}, {ID: "b", ParentTaskID: "a"}}}
+
+	if _, err := ImportTaskBundle(store, bundle, ""); err == nil {
+		t.Fatal("expected the import to fail")
+	}
+	if tasks, _ := store.ListTasks(); len(tasks) != 0 {
+		t.Errorf("expected the created tasks to be removed, got %d", len(tasks))
+	}
+}
+
+func TestImportTaskBundle_CopiesEveryField(t *testing.T) {
+	bundle := &TaskBundle{Tasks: []*Task{{
+		ID:            "old",
+		Name:          "review",
+		State:         TaskStateInputRequired,
+		Project:       "billing",
+		Labels:        []string{"email"},
+		Notes:         []TaskNote{{Comment: "looks right"}},
+		Feedback:      []Feedback{{Rating: "up"}},
+		Mode:          "researcher",
+		SubTaskPolicy: &SubTaskPolicy{MaxAttempts: 3},
+		InputRequest:  &tools.InputForm{Question: "Which quarter?"},
+	}}}
+
+	store := NewInMemoryTaskStore()
+	result, err := ImportTaskBundle(store, bundle, "")
+	if err != nil {
+		t.Fatalf("ImportTaskBundle failed: %v", err)
+	}
+	imported, _ := store.GetTask(result.RootTaskID)
+	if imported.State != TaskStateInputRequired || imported.Project != "billing" || len(imported.Labels) != 1 || len(imported.Notes) != 1 ||
+		len(imported.Feedback) != 1 || imported.Mode != "researcher" || imported.SubTaskPolicy == nil || imported.SubTaskPolicy.MaxAttempts != 3 ||
+		imported.InputRequest == nil || imported.InputRequest.Question != "Which quarter?" {
+		t.Errorf("fields were not copied: %+v", imported)
+	}
+	if imported.SubTaskPolicy == bundle.Tasks[0].SubTaskPolicy {
+		t.Errorf("expected the imported task not to share the bundle's policy")
+	}
+
+	result, err = ImportTaskBundle(store, bundle, "sales")
+	if err != nil {
+		t.Fatalf("ImportTaskBundle failed: %v", err)
+	}
+	if imported, _ := store.GetTask(result.RootTaskID); imported.Project != "sales" {
+		t.Errorf("expected the caller's project, got %q", imported.Project)
+	}
+}
+
+func TestExportTaskBundle_InlinesStreamedArtifacts(t *testing.T) {
+	source, err := NewFileTaskStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileTaskStore: %v", err)
+	}
+	task, _ := source.CreateTask("long", "", nil, "")
+	for _, chunk := range []string{"first chunk, ", "second chunk"} {
+		if err := source.AppendArtifact(task.ID, Artifact{ID: "output-1", Type: "text/plain"}, []byte(chunk)); err != nil {
+			t.Fatalf("AppendArtifact: %v", err)
+		}
+	}
+
+	bundle, err := ExportTaskBundle(source, task.ID, nil)
+	if err != nil {
+		t.Fatalf("ExportTaskBundle failed: %v", err)
+	}
+	if artifact := bundle.Tasks[0].Artifacts["output-1"]; artifact.Streamed || string(artifact.Data) != "first chunk, second chunk" {
+		t.Fatalf("expected the streamed data in the bundle, got %+v", artifact)
+	}
+	if stored, _ := source.GetTask(task.ID); !stored.Artifacts["output-1"].Streamed {
+		t.Errorf("expected the stored artifact to stay streamed")
+	}
+
+	target := NewInMemoryTaskStore()
+	result, err := ImportTaskBundle(target, bundle, "")
+	if err != nil {
+		t.Fatalf("ImportTaskBundle failed: %v", err)
+	}
+	data, _, err := target.GetArtifactData(result.RootTaskID, result.ArtifactIDs["output-1"])
+	if err != nil || string(data) != "first chunk, second chunk" {
+		t.Errorf("expected the imported artifact to carry the data, got %q, %v", data, err)
+	}
+}
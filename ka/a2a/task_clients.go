@@ -0,0 +1,99 @@
+package a2a
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"ka/llm"
+)
+
+// ClientFactory creates the LLM client of a provider and model for tasks
+// that choose a provider other than the agent's. An empty model selects the
+// provider's default.
+type ClientFactory func(provider, model string) (llm.LLMClient, error)
+
+// clientFor returns the LLM client a task runs with: the agent's client,
+// unless the task chose another provider. Clients of other providers are
+// created with ClientFactory once per provider and model and then reused.
+// A model chosen without a provider is applied to the agent's client per
+// call, see modeContext.
+func (te *TaskExecutor) clientFor(task *Task) (llm.LLMClient, error) {
+	if task.Provider == "" || task.Provider == te.DefaultProvider {
+		return te.LLMClient, nil
+	}
+	if te.ClientFactory == nil {
+		return nil, fmt.Errorf("this agent cannot run tasks with provider '%s'", task.Provider)
+	}
+	key := task.Provider + "/" + task.Model
+	te.mu.Lock()
+	client, ok := te.clients[key]
+	te.mu.Unlock()
+	if ok {
+		return client, nil
+	}
+
+	// Created outside the lock: loading a tokenizer may take a while
+	client, err := te.ClientFactory(task.Provider, task.Model)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the %s client: %w", task.Provider, err)
+	}
+	te.mu.Lock()
+	defer te.mu.Unlock()
+	if existing, ok := te.clients[key]; ok {
+		return existing, nil
+	}
+	if te.clients == nil {
+		te.clients = make(map[string]llm.LLMClient)
+	}
+	te.clients[key] = client
+	log.Printf("[TaskExecutor] Created LLM client for provider %s, model %q.", task.Provider, task.Model)
+	return client, nil
+}
+
+// clientErrorDetail is the failure of a task whose LLM client cannot be created.
+func clientErrorDetail(task *Task, err error) *ErrorDetail {
+	return &ErrorDetail{
+		Code:     ErrorCodeLLMFailed,
+		Message:  err.Error(),
+		Hint:     "Check the provider and model of the task and the agent's credentials for the provider.",
+		Provider: task.Provider,
+	}
+}
+
+// inheritClient copies the provider, model and queue priority of a parent
+// task to a new sub-task, so the sub-tasks of a task routed to a model run on
+// it too.
+func inheritClient(store TaskStore, taskID string, parent *Task) {
+	if parent.Provider == "" && parent.Model == "" && parent.Priority == 0 {
+		return
+	}
+	store.UpdateTask(taskID, func(task *Task) error {
+		task.Provider, task.Model, task.Priority = parent.Provider, parent.Model, parent.Priority
+		return nil
+	})
+}
+
+// providerBusy refuses a new task while the request queue of its provider
+// is full, so schedulers back off instead of piling up work. The error data
+// holds the provider, the waiting requests and retryAfterSeconds.
+func (te *TaskExecutor) providerBusy(provider string) *JSONRPCError {
+	if provider == "" {
+		provider = te.DefaultProvider
+	}
+	queue := llm.RequestQueueFor(provider)
+	if queue == nil {
+		return nil
+	}
+	err := queue.Busy()
+	busy, ok := err.(*llm.ProviderBusyError)
+	if !ok {
+		return nil
+	}
+	log.Printf("[TaskExecutor] Refused a task: %v", busy)
+	return &JSONRPCError{Code: -32005, Message: "Provider busy: " + busy.Error(), Data: map[string]interface{}{
+		"provider":          busy.Provider,
+		"waiting":           busy.Waiting,
+		"retryAfterSeconds": int(busy.RetryAfter.Round(time.Second).Seconds()),
+	}}
+}
@@ -0,0 +1,130 @@
+package a2a
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"ka/llm"
+)
+
+func TestTaskProviderOverrideUsesCachedClient(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	te := NewTaskExecutor(&staticLLMClient{reply: "from the agent's model"}, store, nil, "")
+	te.DefaultProvider = "lmstudio"
+	var created []string
+	te.ClientFactory = func(provider, model string) (llm.LLMClient, error) {
+		if provider != "google" {
+			return nil, fmt.Errorf("unsupported LLM provider type: %s", provider)
+		}
+		created = append(created, provider+"/"+model)
+		return &staticLLMClient{reply: "from " + model}, nil
+	}
+	msg := Message{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "hi"}}}
+
+	var tasks []*Task
+	for _, params := range []SendTaskParams{
+		{Message: msg, Provider: "Google", Model: "gemini-2.0-flash"},
+		{Message: msg, Provider: "google", Model: "gemini-2.0-flash"},
+		{Message: msg, Provider: "lmstudio"},
+	} {
+		task, rpcErr := te.createTask(context.Background(), "routed", params)
+		if rpcErr != nil {
+			t.Fatalf("createTask: %v", rpcErr)
+		}
+		te.ExecuteTask(context.Background(), task)
+		waitForState(t, store, task.ID, TaskStateCompleted)
+		tasks = append(tasks, task)
+	}
+
+	wantReplies := []string{"from gemini-2.0-flash", "from gemini-2.0-flash", "from the agent's model"}
+	for i, task := range tasks {
+		done, _ := store.GetTask(task.ID)
+		last := done.Messages[len(done.Messages)-1]
+		if text := last.Parts[0].(TextPart).Text; text != wantReplies[i] {
+			t.Errorf("task %d: expected %q, got %q", i, wantReplies[i], text)
+		}
+	}
+	if len(created) != 1 || created[0] != "google/gemini-2.0-flash" {
+		t.Errorf("expected one cached client for the provider and model, got %v", created)
+	}
+	if done, _ := store.GetTask(tasks[0].ID); done.Provider != "google" || done.Model != "gemini-2.0-flash" {
+		t.Errorf("expected the provider and model on the task, got %q %q", done.Provider, done.Model)
+	}
+
+	if _, rpcErr := te.createTask(context.Background(), "bad", SendTaskParams{Message: msg, Provider: "nope"}); rpcErr == nil || rpcErr.Code != -32602 {
+		t.Errorf("expected an unusable provider to be rejected, got %+v", rpcErr)
+	}
+	te.ClientFactory = nil
+	if _, rpcErr := te.createTask(context.Background(), "bad", SendTaskParams{Message: msg, Provider: "ollama"}); rpcErr == nil || rpcErr.Code != -32602 {
+		t.Errorf("expected other providers to be rejected without a client factory, got %+v", rpcErr)
+	}
+}
+
+func TestTaskModelOverrideOnAgentClient(t *testing.T) {
+	var mu sync.Mutex
+	var models []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req llm.Request
+		json.NewDecoder(r.Body).Decode(&req)
+		mu.Lock()
+		models = append(models, req.Model)
+		mu.Unlock()
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"ok\"},\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	store := NewInMemoryTaskStore()
+	te := NewTaskExecutor(&llm.LMStudioClient{APIURL: server.URL, Model: "default-model", MaxContextLength: 1000}, store, nil, "")
+	msg := Message{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "hi"}}}
+	task, rpcErr := te.createTask(context.Background(), "small", SendTaskParams{Message: msg, Model: "qwen2.5-7b"})
+	if rpcErr != nil {
+		t.Fatalf("createTask: %v", rpcErr)
+	}
+	te.ExecuteTask(context.Background(), task)
+	waitForState(t, store, task.ID, TaskStateCompleted)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(models) != 1 || models[0] != "qwen2.5-7b" {
+		t.Errorf("expected the task's model to be requested, got %v", models)
+	}
+}
+
+func TestBusyProviderRefusesNewTasks(t *testing.T) {
+	llm.SetRequestQueues(map[string]llm.QueueConfig{"lmstudio": {Concurrency: 1, MaxWaiting: 1}})
+	t.Cleanup(func() { llm.SetRequestQueues(nil) })
+	te := NewTaskExecutor(&staticLLMClient{reply: "done"}, NewInMemoryTaskStore(), nil, "")
+	te.DefaultProvider = "lmstudio"
+	msg := Message{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "hi"}}}
+
+	// One request runs and one waits
+	queue := llm.RequestQueueFor("lmstudio")
+	release, _, _ := queue.Acquire(context.Background(), 0)
+	waiting := make(chan func())
+	go func() {
+		next, _, _ := queue.Acquire(context.Background(), 0)
+		waiting <- next
+	}()
+	for queue.Stats().Waiting != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	_, rpcErr := te.createTask(context.Background(), "busy", SendTaskParams{Message: msg})
+	if rpcErr == nil || rpcErr.Code != -32005 || rpcErr.Data.(map[string]interface{})["retryAfterSeconds"].(int) < 1 {
+		t.Fatalf("expected the task to be refused as provider busy, got %+v", rpcErr)
+	}
+
+	release()
+	(<-waiting)()
+	task, rpcErr := te.createTask(context.Background(), "urgent", SendTaskParams{Message: msg, Priority: 3})
+	if rpcErr != nil || task.Priority != 3 {
+		t.Fatalf("expected the task to be accepted with its priority, got %+v, %+v", task, rpcErr)
+	}
+}
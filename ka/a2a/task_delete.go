@@ -0,0 +1,164 @@
+package a2a
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Sub-task handling of "tasks/delete".
+const (
+	SubTasksDelete = "delete" // Delete sub-tasks recursively
+	SubTasksOrphan = "orphan" // Keep sub-tasks as top-level tasks
+)
+
+// TaskDeleteParams defines the structure for parameters of "tasks/delete".
+// Without options only the task record, including its artifacts, is removed,
+// or moved to the trash when the agent keeps one.
+type TaskDeleteParams struct {
+	ID        string `json:"id"`
+	Permanent bool   `json:"permanent,omitempty"` // Skip the trash
+	SubTasks  string `json:"subTasks,omitempty"`  // SubTasksDelete or SubTasksOrphan; empty leaves sub-tasks pointing at the deleted parent
+	Workspace bool   `json:"workspace,omitempty"` // Also remove the workspace directory of each deleted task
+	DryRun    bool   `json:"dryRun,omitempty"`    // Report what would be removed without removing anything
+}
+
+// cascades reports whether the delete goes beyond the task record.
+func (p TaskDeleteParams) cascades() bool {
+	return p.SubTasks != "" || p.Workspace || p.DryRun
+}
+
+func (p TaskDeleteParams) validate() error {
+	if p.SubTasks != "" && p.SubTasks != SubTasksDelete && p.SubTasks != SubTasksOrphan {
+		return fmt.Errorf("subTasks must be '%s' or '%s'", SubTasksDelete, SubTasksOrphan)
+	}
+	return nil
+}
+
+// DeletedArtifact is an artifact removed together with its task.
+type DeletedArtifact struct {
+	TaskID   string `json:"taskId"`
+	ID       string `json:"id"`
+	Filename string `json:"filename,omitempty"`
+	Bytes    int    `json:"bytes"`
+}
+
+// TaskDeleteReport lists what a cascading "tasks/delete" removed, or would
+// remove in a dry run.
+type TaskDeleteReport struct {
+	DryRun     bool              `json:"dryRun,omitempty"`
+	Trashed    bool              `json:"trashed,omitempty"`    // The tasks were moved to the trash; workspaces are removed when they are purged
+	Deleted    []string          `json:"deleted"`              // Task IDs, sub-tasks before their parents
+	Orphaned   []string          `json:"orphaned,omitempty"`   // Sub-tasks detached from a deleted parent
+	Artifacts  []DeletedArtifact `json:"artifacts,omitempty"`  // Artifacts stored with the deleted tasks
+	Workspaces []string          `json:"workspaces,omitempty"` // Workspace directories removed
+}
+
+// taskWorkspaceDir returns the workspace directory of a task under root, or
+// "" when the task has none.
+func taskWorkspaceDir(root, taskID string) string {
+	if root == "" || taskID == "" || strings.ContainsAny(taskID, `/\`) || taskID == "." || taskID == ".." {
+		return ""
+	}
+	dir := filepath.Join(root, taskID)
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return ""
+	}
+	return dir
+}
+
+// DeleteTaskCascade deletes a task as described by params. Workspace
+// directories are looked up as <workspaceRoot>/<task ID>; an empty root
+// disables workspace cleanup. It returns ErrTaskNotFound when the task does
+// not exist.
+func DeleteTaskCascade(store TaskStore, workspaceRoot string, params TaskDeleteParams) (*TaskDeleteReport, error) {
+	report, err := planTaskDelete(store, workspaceRoot, params)
+	if err != nil || params.DryRun {
+		return report, err
+	}
+	if err := orphanSubTasks(store, report.Orphaned); err != nil {
+		return report, err
+	}
+	for _, dir := range report.Workspaces {
+		if err := os.RemoveAll(dir); err != nil {
+			return report, fmt.Errorf("failed to remove workspace %s: %w", dir, err)
+		}
+	}
+	for _, id := range report.Deleted {
+		if err := store.DeleteTask(id); err != nil && !errors.Is(err, ErrTaskNotFound) {
+			return report, fmt.Errorf("failed to delete task %s: %w", id, err)
+		}
+	}
+	return report, nil
+}
+
+// planTaskDelete lists the tasks, artifacts and workspaces a delete removes
+// and the sub-tasks it orphans.
+func planTaskDelete(store TaskStore, workspaceRoot string, params TaskDeleteParams) (*TaskDeleteReport, error) {
+	if err := params.validate(); err != nil {
+		return nil, err
+	}
+	if _, err := store.GetTask(params.ID); err != nil {
+		return nil, err
+	}
+
+	children := map[string][]string{}
+	if params.SubTasks != "" {
+		err := walkTasks(store, func(task *Task) error {
+			if task.ParentTaskID != "" {
+				children[task.ParentTaskID] = append(children[task.ParentTaskID], task.ID)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to find sub-tasks: %w", err)
+		}
+	}
+
+	report := &TaskDeleteReport{DryRun: params.DryRun, Deleted: []string{}}
+	visited := map[string]bool{}
+	var collect func(id string)
+	collect = func(id string) {
+		if visited[id] { // Guards against parent cycles in damaged stores
+			return
+		}
+		visited[id] = true
+		for _, child := range children[id] {
+			if params.SubTasks == SubTasksDelete {
+				collect(child)
+			} else {
+				report.Orphaned = append(report.Orphaned, child)
+			}
+		}
+		report.Deleted = append(report.Deleted, id)
+	}
+	collect(params.ID)
+
+	for _, id := range report.Deleted {
+		task, err := store.GetTask(id)
+		if err != nil {
+			continue
+		}
+		for _, artifact := range task.Artifacts {
+			report.Artifacts = append(report.Artifacts, DeletedArtifact{TaskID: id, ID: artifact.ID, Filename: artifact.Filename, Bytes: artifact.Len()})
+		}
+		if params.Workspace {
+			if dir := taskWorkspaceDir(workspaceRoot, id); dir != "" {
+				report.Workspaces = append(report.Workspaces, dir)
+			}
+		}
+	}
+	return report, nil
+}
+
+// orphanSubTasks detaches sub-tasks from their deleted parent.
+func orphanSubTasks(store TaskStore, ids []string) error {
+	for _, id := range ids {
+		if _, err := store.UpdateTask(id, func(task *Task) error { task.ParentTaskID = ""; return nil }); err != nil && !errors.Is(err, ErrTaskNotFound) {
+			return fmt.Errorf("failed to orphan sub-task %s: %w", id, err)
+		}
+	}
+	return nil
+}
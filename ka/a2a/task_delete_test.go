@@ -0,0 +1,84 @@
+package a2a
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// deleteTree creates a parent with a child and a grandchild.
+func deleteTree(t *testing.T) (TaskStore, *Task, *Task, *Task) {
+	t.Helper()
+	store := NewInMemoryTaskStore()
+	parent, _ := store.CreateTask("parent", "", nil, "")
+	child, _ := store.CreateTask("child", "", nil, parent.ID)
+	grandchild, _ := store.CreateTask("grandchild", "", nil, child.ID)
+	store.AddArtifact(child.ID, Artifact{ID: "a1", Type: "text/plain", Filename: "notes.txt", Data: []byte("hello")})
+	return store, parent, child, grandchild
+}
+
+func TestDeleteTaskCascadeDeletesSubTasks(t *testing.T) {
+	store, parent, child, grandchild := deleteTree(t)
+	root := t.TempDir()
+	os.Mkdir(filepath.Join(root, child.ID), 0755)
+
+	report, err := DeleteTaskCascade(store, root, TaskDeleteParams{ID: parent.ID, SubTasks: SubTasksDelete, Workspace: true})
+	if err != nil {
+		t.Fatalf("DeleteTaskCascade: %v", err)
+	}
+	want := []string{grandchild.ID, child.ID, parent.ID}
+	if len(report.Deleted) != 3 || report.Deleted[0] != want[0] || report.Deleted[2] != want[2] {
+		t.Errorf("expected %v deleted children first, got %v", want, report.Deleted)
+	}
+	if len(report.Artifacts) != 1 || report.Artifacts[0].Bytes != 5 || report.Artifacts[0].TaskID != child.ID {
+		t.Errorf("unexpected artifacts %+v", report.Artifacts)
+	}
+	if len(report.Workspaces) != 1 {
+		t.Errorf("expected the child's workspace, got %v", report.Workspaces)
+	}
+	if _, err := os.Stat(filepath.Join(root, child.ID)); !os.IsNotExist(err) {
+		t.Errorf("expected the workspace to be removed")
+	}
+	for _, id := range want {
+		if _, err := store.GetTask(id); err == nil {
+			t.Errorf("expected task %s to be deleted", id)
+		}
+	}
+}
+
+func TestDeleteTaskCascadeOrphansAndDryRun(t *testing.T) {
+	store, parent, child, grandchild := deleteTree(t)
+
+	report, err := DeleteTaskCascade(store, "", TaskDeleteParams{ID: parent.ID, SubTasks: SubTasksDelete, DryRun: true})
+	if err != nil {
+		t.Fatalf("DeleteTaskCascade: %v", err)
+	}
+	if !report.DryRun || len(report.Deleted) != 3 {
+		t.Errorf("expected a dry run listing 3 tasks, got %+v", report)
+	}
+	if _, err := store.GetTask(grandchild.ID); err != nil {
+		t.Errorf("expected a dry run to keep the tasks")
+	}
+
+	report, err = DeleteTaskCascade(store, "", TaskDeleteParams{ID: child.ID, SubTasks: SubTasksOrphan})
+	if err != nil {
+		t.Fatalf("DeleteTaskCascade: %v", err)
+	}
+	if len(report.Deleted) != 1 || len(report.Orphaned) != 1 || report.Orphaned[0] != grandchild.ID {
+		t.Errorf("expected the grandchild to be orphaned, got %+v", report)
+	}
+	orphan, err := store.GetTask(grandchild.ID)
+	if err != nil || orphan.ParentTaskID != "" {
+		t.Errorf("expected a top-level grandchild, got %+v / %v", orphan, err)
+	}
+	if _, err := store.GetTask(parent.ID); err != nil {
+		t.Errorf("expected the parent to be kept")
+	}
+}
+
+func TestDeleteTaskCascadeRejectsUnknownSubTaskMode(t *testing.T) {
+	store, parent, _, _ := deleteTree(t)
+	if _, err := DeleteTaskCascade(store, "", TaskDeleteParams{ID: parent.ID, SubTasks: "archive"}); err == nil {
+		t.Errorf("expected an unknown subTasks value to be rejected")
+	}
+}
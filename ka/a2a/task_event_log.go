@@ -0,0 +1,169 @@
+package a2a
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// taskEventLogValueBytes bounds the tool arguments and results kept in
+	// an event log entry; the full values stay in the task's messages.
+	taskEventLogValueBytes = 4000
+	// DefaultTaskEventsLimit and maxTaskEventsLimit bound a tasks/events page.
+	DefaultTaskEventsLimit = 100
+	maxTaskEventsLimit     = 1000
+)
+
+// TaskLogEntry is one event in the append-only log of a task: a state
+// change, an LLM call with its token counts or a tool call with its
+// arguments and result. Seq numbers the entries of a task from 1 and is the
+// cursor of tasks/events.
+type TaskLogEntry struct {
+	Seq           int64             `json:"seq"`
+	Type          TaskEventType     `json:"type"`
+	Time          time.Time         `json:"time"`
+	State         TaskState         `json:"state,omitempty"`         // created, state_changed
+	PreviousState TaskState         `json:"previousState,omitempty"` // state_changed
+	Tool          string            `json:"tool,omitempty"`          // tool_call
+	ToolCallID    string            `json:"toolCallId,omitempty"`
+	Args          string            `json:"args,omitempty"` // Tool call content, truncated
+	Attributes    map[string]string `json:"attributes,omitempty"`
+	Result        string            `json:"result,omitempty"`   // Tool result text, truncated
+	Provider      string            `json:"provider,omitempty"` // llm_call
+	Model         string            `json:"model,omitempty"`
+	InputTokens   int               `json:"inputTokens,omitempty"`
+	OutputTokens  int               `json:"outputTokens,omitempty"`
+	DurationMs    int64             `json:"durationMs,omitempty"` // llm_call
+	Error         string            `json:"error,omitempty"`
+	ErrorDetail   *ErrorDetail      `json:"errorDetail,omitempty"` // Of a task that failed
+}
+
+// TaskEventLog is implemented by task stores that keep the event log of
+// each task. AppendTaskEvent assigns the entry its Seq; ListTaskEvents
+// returns up to limit entries with a Seq above after, oldest first. The log
+// is deleted with its task.
+type TaskEventLog interface {
+	AppendTaskEvent(taskID string, entry *TaskLogEntry) error
+	ListTaskEvents(taskID string, after int64, limit int) ([]TaskLogEntry, error)
+}
+
+// recordTaskEvent appends a lifecycle event to the log of its task.
+// Appended messages are not logged, as they are stored in the task itself.
+func (te *TaskExecutor) recordTaskEvent(event TaskEvent) {
+	entry := &TaskLogEntry{Type: event.Type, Time: event.Timestamp}
+	switch event.Type {
+	case TaskEventCreated:
+		entry.State = event.State
+	case TaskEventStateChanged:
+		entry.State, entry.PreviousState = event.State, event.PreviousState
+		if event.State == TaskStateFailed {
+			if task, err := te.TaskStore.GetTask(event.TaskID); err == nil {
+				entry.Error, entry.ErrorDetail = task.Error, task.ErrorDetail
+			}
+		}
+	case TaskEventToolCall:
+		entry.Tool, entry.ToolCallID = event.ToolCall.Function.Name, event.ToolCall.ID
+		entry.Args = truncateOutput(event.ToolCall.Function.Content, taskEventLogValueBytes)
+		for key, value := range event.ToolCall.Function.Attributes {
+			if strings.HasPrefix(key, "__") {
+				continue
+			}
+			if entry.Attributes == nil {
+				entry.Attributes = make(map[string]string)
+			}
+			entry.Attributes[key] = value
+		}
+		entry.Result = truncateOutput(messageText(*event.ToolResult), taskEventLogValueBytes)
+		if event.ToolErr != nil {
+			entry.Error = event.ToolErr.Error()
+		}
+	case TaskEventLLMCall:
+		call := event.LLMCall
+		entry.Provider, entry.Model = call.Provider, call.Model
+		entry.InputTokens, entry.OutputTokens = call.InputTokens, call.OutputTokens
+		entry.DurationMs = call.Duration.Milliseconds()
+		if call.Err != nil {
+			entry.Error = call.Err.Error()
+		}
+	default:
+		return
+	}
+	if err := te.eventLog.AppendTaskEvent(event.TaskID, entry); err != nil && !errors.Is(err, ErrTaskNotFound) {
+		log.Printf("[Task %s] Failed to append %s to the event log: %v", event.TaskID, event.Type, err)
+	}
+}
+
+// TaskEventsParams defines the parameters of "tasks/events".
+type TaskEventsParams struct {
+	ID    string `json:"id"`
+	After int64  `json:"after,omitempty"` // Seq of the last entry already read; 0 starts at the beginning
+	Limit int    `json:"limit,omitempty"` // Page size, DefaultTaskEventsLimit by default
+}
+
+// TaskEventsResult is a page of the event log of a task. NextAfter is the
+// After of the next page.
+type TaskEventsResult struct {
+	Events    []TaskLogEntry `json:"events"`
+	NextAfter int64          `json:"nextAfter"`
+	HasMore   bool           `json:"hasMore"`
+}
+
+// TasksEventsHandler handles "tasks/events", which replays the event log of
+// a task page by page.
+func TasksEventsHandler(taskExecutor *TaskExecutor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params TaskEventsParams
+		rpcReq, ok := decodeJSONRPCRequest(w, r, &params)
+		if !ok {
+			return
+		}
+		if params.ID == "" {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: "Invalid Params: missing task ID"})
+			return
+		}
+		if params.After < 0 || params.Limit < 0 || params.Limit > maxTaskEventsLimit {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: fmt.Sprintf("Invalid Params: after must not be negative and limit must be between 0 and %d", maxTaskEventsLimit)})
+			return
+		}
+		if params.Limit == 0 {
+			params.Limit = DefaultTaskEventsLimit
+		}
+		if taskExecutor.eventLog == nil {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32000, Message: "Internal Server Error: The task store keeps no event log"})
+			return
+		}
+		if HiddenFromCaller(r.Context(), taskExecutor.TaskStore, params.ID) {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32001, Message: "Not Found: Task not found"})
+			return
+		}
+		if _, err := taskExecutor.TaskStore.GetTask(params.ID); err != nil {
+			if errors.Is(err, ErrTaskNotFound) {
+				sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32001, Message: "Not Found: Task not found"})
+			} else {
+				sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32000, Message: "Internal Server Error: Failed to load task", Data: err.Error()})
+			}
+			return
+		}
+
+		events, err := taskExecutor.eventLog.ListTaskEvents(params.ID, params.After, params.Limit+1)
+		if err != nil {
+			log.Printf("[TaskEvents %v] Error reading the event log of task %s: %v", rpcReq.ID, params.ID, err)
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32000, Message: "Internal Server Error: Failed to read the event log", Data: err.Error()})
+			return
+		}
+		result := TaskEventsResult{Events: events, NextAfter: params.After}
+		if len(events) > params.Limit {
+			result.Events, result.HasMore = events[:params.Limit], true
+		}
+		if len(result.Events) > 0 {
+			result.NextAfter = result.Events[len(result.Events)-1].Seq
+		} else {
+			result.Events = []TaskLogEntry{}
+		}
+		sendJSONRPCResponse(w, rpcReq.ID, result, nil)
+	}
+}
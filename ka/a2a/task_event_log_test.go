@@ -0,0 +1,106 @@
+package a2a
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"ka/llm"
+	"ka/tools"
+)
+
+// tokenReportingClient is a scriptedLLMClient reporting token counts.
+type tokenReportingClient struct {
+	scriptedLLMClient
+}
+
+func (c *tokenReportingClient) Chat(ctx context.Context, messages []llm.Message, stream bool, out io.Writer) (string, int, int, error) {
+	reply, _, _, err := c.scriptedLLMClient.Chat(ctx, messages, stream, out)
+	return reply, 12, 3, err
+}
+
+func TestTaskEventLogReplaysExecution(t *testing.T) {
+	fileStore, err := NewFileTaskStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, store := range map[string]TaskStore{
+		"memory": NewInMemoryTaskStore(),
+		"file":   fileStore,
+		"sqlite": newTestSQLiteStore(t),
+	} {
+		t.Run(name, func(t *testing.T) {
+			client := &tokenReportingClient{scriptedLLMClient{replies: []string{`<tool id="note">{"text": "a"}</tool>`, "Done."}}}
+			te := NewTaskExecutor(client, store, map[string]tools.Tool{"note": &countingTool{name: "note"}}, "")
+			task, _ := te.TaskStore.CreateTask("logged", "", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "take a note"}}}}, "")
+			te.ExecuteTask(context.Background(), task)
+
+			// Page through the log two entries at a time
+			var events []TaskLogEntry
+			after := int64(0)
+			for page := 0; page < 20; page++ {
+				resp := callRPC(t, TasksEventsHandler(te), "tasks/events", `{"id": "`+task.ID+`", "limit": 2, "after": `+jsonInt(after)+`}`)
+				if resp.Error != nil {
+					t.Fatalf("tasks/events: %+v", resp.Error)
+				}
+				var result TaskEventsResult
+				data, _ := json.Marshal(resp.Result)
+				json.Unmarshal(data, &result)
+				events = append(events, result.Events...)
+				after = result.NextAfter
+				if !result.HasMore {
+					break
+				}
+			}
+
+			var llmCalls, toolCalls int
+			for i, event := range events {
+				if event.Seq != int64(i+1) {
+					t.Fatalf("expected consecutive sequence numbers, got %d at %d", event.Seq, i)
+				}
+				switch event.Type {
+				case TaskEventLLMCall:
+					llmCalls++
+					if event.InputTokens != 12 || event.OutputTokens != 3 {
+						t.Errorf("expected the token counts of the LLM call, got %+v", event)
+					}
+				case TaskEventToolCall:
+					toolCalls++
+					if event.Tool != "note" || event.ToolCallID != "note-0" || event.Args != `{"text": "a"}` || !strings.Contains(event.Result, "note done") {
+						t.Errorf("expected the arguments and result of the tool call, got %+v", event)
+					}
+				}
+			}
+			if len(events) == 0 || events[0].Type != TaskEventCreated {
+				t.Fatalf("expected the log to start with the creation, got %+v", events)
+			}
+			if llmCalls != 2 || toolCalls != 1 {
+				t.Errorf("expected 2 LLM calls and 1 tool call, got %+v", events)
+			}
+			completed := false
+			for _, event := range events {
+				completed = completed || event.Type == TaskEventStateChanged && event.State == TaskStateCompleted
+			}
+			if !completed {
+				t.Errorf("expected the completion to be logged, got %+v", events)
+			}
+
+			if err := te.TaskStore.DeleteTask(task.ID); err != nil {
+				t.Fatal(err)
+			}
+			if remaining, _ := te.eventLog.ListTaskEvents(task.ID, 0, 10); len(remaining) != 0 {
+				t.Errorf("expected the log to be deleted with the task, got %+v", remaining)
+			}
+			if resp := callRPC(t, TasksEventsHandler(te), "tasks/events", `{"id": "`+task.ID+`"}`); resp.Error == nil || resp.Error.Code != -32001 {
+				t.Errorf("expected a deleted task to be not found, got %+v", resp)
+			}
+		})
+	}
+}
+
+func jsonInt(n int64) string {
+	data, _ := json.Marshal(n)
+	return string(data)
+}
@@ -0,0 +1,265 @@
+package a2a
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"ka/llm"
+)
+
+// TaskEventType names a task lifecycle event.
+type TaskEventType string
+
+const (
+	TaskEventCreated         TaskEventType = "created"
+	TaskEventStateChanged    TaskEventType = "state_changed"
+	TaskEventMessageAppended TaskEventType = "message_appended"
+	TaskEventToolCall        TaskEventType = "tool_call"
+	TaskEventLLMCall         TaskEventType = "llm_call"
+)
+
+// TaskEvent is a lifecycle event of a task, delivered to the subscribers of
+// a TaskExecutor. Only the fields of its Type are set.
+type TaskEvent struct {
+	Type          TaskEventType
+	TaskID        string
+	PreviousState TaskState // state_changed
+	State         TaskState // created, state_changed
+	Message       *Message  // message_appended
+	ToolCall      *ToolCall // tool_call
+	ToolResult    *Message  // tool_call
+	ToolErr       error     // tool_call
+	LLMCall       *LLMCall  // llm_call
+	Timestamp     time.Time
+}
+
+// LLMCall describes an LLM request of a task iteration, including the
+// continuations it needed.
+type LLMCall struct {
+	Provider     string
+	Model        string
+	InputTokens  int
+	OutputTokens int
+	Duration     time.Duration
+	Err          error
+}
+
+// taskEvents fans task events out to subscribers.
+type taskEvents struct {
+	mu          sync.RWMutex
+	nextID      int
+	subscribers map[int]func(TaskEvent)
+}
+
+func (e *taskEvents) subscribe(fn func(TaskEvent)) (unsubscribe func()) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.subscribers == nil {
+		e.subscribers = make(map[int]func(TaskEvent))
+	}
+	id := e.nextID
+	e.nextID++
+	e.subscribers[id] = fn
+	return func() {
+		e.mu.Lock()
+		delete(e.subscribers, id)
+		e.mu.Unlock()
+	}
+}
+
+func (e *taskEvents) active() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return len(e.subscribers) > 0
+}
+
+func (e *taskEvents) emit(event TaskEvent) {
+	event.Timestamp = time.Now().UTC()
+	e.mu.RLock()
+	subscribers := make([]func(TaskEvent), 0, len(e.subscribers))
+	for _, fn := range e.subscribers {
+		subscribers = append(subscribers, fn)
+	}
+	e.mu.RUnlock()
+	for _, fn := range subscribers {
+		fn(event)
+	}
+}
+
+// Subscribe calls fn for every lifecycle event of the executor's tasks until
+// the returned function is called. fn runs on the goroutine that caused the
+// event, so it must not block.
+func (te *TaskExecutor) Subscribe(fn func(TaskEvent)) (unsubscribe func()) {
+	return te.events.subscribe(fn)
+}
+
+// Events returns a channel receiving every lifecycle event until ctx is done.
+// Events are dropped while the buffer is full.
+func (te *TaskExecutor) Events(ctx context.Context, buffer int) <-chan TaskEvent {
+	ch := make(chan TaskEvent, buffer)
+	var mu sync.Mutex
+	closed := false
+	unsubscribe := te.Subscribe(func(event TaskEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		if closed {
+			return
+		}
+		select {
+		case ch <- event:
+		default:
+		}
+	})
+	go func() {
+		<-ctx.Done()
+		unsubscribe()
+		mu.Lock()
+		closed = true
+		close(ch)
+		mu.Unlock()
+	}()
+	return ch
+}
+
+// OnStateChange calls fn whenever a task changes state.
+func (te *TaskExecutor) OnStateChange(fn func(taskID string, from, to TaskState)) (unsubscribe func()) {
+	return te.Subscribe(func(event TaskEvent) {
+		if event.Type == TaskEventStateChanged {
+			fn(event.TaskID, event.PreviousState, event.State)
+		}
+	})
+}
+
+// OnMessageAppended calls fn for every message added to a task.
+func (te *TaskExecutor) OnMessageAppended(fn func(taskID string, message Message)) (unsubscribe func()) {
+	return te.Subscribe(func(event TaskEvent) {
+		if event.Type == TaskEventMessageAppended {
+			fn(event.TaskID, *event.Message)
+		}
+	})
+}
+
+// OnToolCall calls fn after every tool call the executor dispatched, with
+// its result message and error.
+func (te *TaskExecutor) OnToolCall(fn func(taskID string, call ToolCall, result Message, err error)) (unsubscribe func()) {
+	return te.Subscribe(func(event TaskEvent) {
+		if event.Type == TaskEventToolCall {
+			fn(event.TaskID, *event.ToolCall, *event.ToolResult, event.ToolErr)
+		}
+	})
+}
+
+// OnLLMCall calls fn after every LLM request of the executor's tasks.
+func (te *TaskExecutor) OnLLMCall(fn func(taskID string, call LLMCall)) (unsubscribe func()) {
+	return te.Subscribe(func(event TaskEvent) {
+		if event.Type == TaskEventLLMCall {
+			fn(event.TaskID, *event.LLMCall)
+		}
+	})
+}
+
+// emitLLMCall emits the llm_call event of a request made for task, taking
+// the provider and model from info where the client reported them.
+func (te *TaskExecutor) emitLLMCall(task *Task, info *llm.ResponseInfo, inputTokens, outputTokens int, duration time.Duration, err error) {
+	if !te.events.active() {
+		return
+	}
+	call := &LLMCall{Provider: task.Provider, Model: task.Model, InputTokens: inputTokens, OutputTokens: outputTokens, Duration: duration, Err: err}
+	if info != nil && info.Provider != "" {
+		call.Provider, call.Model = info.Provider, info.Model
+	}
+	te.events.emit(TaskEvent{Type: TaskEventLLMCall, TaskID: task.ID, LLMCall: call})
+}
+
+// observedTaskStore emits lifecycle events for the changes made through it.
+type observedTaskStore struct {
+	TaskStore
+	events *taskEvents
+}
+
+// WalkTasks implements TaskWalker with the wrapped store.
+func (s *observedTaskStore) WalkTasks(fn func(*Task) error) error {
+	return walkTasks(s.TaskStore, fn)
+}
+
+// ListTasksPage implements TaskPager with the wrapped store.
+func (s *observedTaskStore) ListTasksPage(query TaskPageQuery) ([]*Task, error) {
+	return listTasksPage(s.TaskStore, query)
+}
+
+func (s *observedTaskStore) AppendArtifact(taskID string, meta Artifact, chunk []byte) error {
+	return appendArtifact(s.TaskStore, taskID, meta, chunk)
+}
+
+func (s *observedTaskStore) CreateTask(name string, systemPrompt string, inputMessages []Message, parentTaskID string) (*Task, error) {
+	task, err := s.TaskStore.CreateTask(name, systemPrompt, inputMessages, parentTaskID)
+	if err == nil && s.events.active() {
+		s.events.emit(TaskEvent{Type: TaskEventCreated, TaskID: task.ID, State: task.State})
+	}
+	return task, err
+}
+
+func (s *observedTaskStore) SetState(taskID string, state TaskState) error {
+	if !s.events.active() {
+		return s.TaskStore.SetState(taskID, state)
+	}
+	var previous TaskState
+	if task, err := s.TaskStore.GetTask(taskID); err == nil {
+		previous = task.State
+	}
+	if err := s.TaskStore.SetState(taskID, state); err != nil {
+		return err
+	}
+	if previous != state {
+		s.events.emit(TaskEvent{Type: TaskEventStateChanged, TaskID: taskID, PreviousState: previous, State: state})
+	}
+	return nil
+}
+
+func (s *observedTaskStore) AddMessage(taskID string, message Message) error {
+	if err := s.TaskStore.AddMessage(taskID, message); err != nil {
+		return err
+	}
+	if s.events.active() {
+		s.events.emit(TaskEvent{Type: TaskEventMessageAppended, TaskID: taskID, Message: &message})
+	}
+	return nil
+}
+
+func (s *observedTaskStore) UpdateTask(taskID string, updateFn func(*Task) error) (*Task, error) {
+	if !s.events.active() {
+		return s.TaskStore.UpdateTask(taskID, updateFn)
+	}
+	var previous TaskState
+	var messageCount int
+	task, err := s.TaskStore.UpdateTask(taskID, func(t *Task) error {
+		previous, messageCount = t.State, len(t.Messages)
+		return updateFn(t)
+	})
+	if err != nil {
+		return task, err
+	}
+	if task.State != previous {
+		s.events.emit(TaskEvent{Type: TaskEventStateChanged, TaskID: taskID, PreviousState: previous, State: task.State})
+	}
+	for i := messageCount; i < len(task.Messages); i++ {
+		message := task.Messages[i]
+		s.events.emit(TaskEvent{Type: TaskEventMessageAppended, TaskID: taskID, Message: &message})
+	}
+	return task, nil
+}
+
+// observedDispatcher emits a tool_call event for every dispatched call.
+type observedDispatcher struct {
+	next   ToolDispatcher
+	events *taskEvents
+}
+
+func (d *observedDispatcher) DispatchToolCall(ctx context.Context, taskID string, toolCall ToolCall) (Message, error) {
+	result, err := d.next.DispatchToolCall(ctx, taskID, toolCall)
+	if d.events.active() {
+		d.events.emit(TaskEvent{Type: TaskEventToolCall, TaskID: taskID, ToolCall: &toolCall, ToolResult: &result, ToolErr: err})
+	}
+	return result, err
+}
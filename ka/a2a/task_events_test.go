@@ -0,0 +1,71 @@
+package a2a
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"ka/tools"
+)
+
+func TestExecutorReportsLifecycleEvents(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	client := &scriptedLLMClient{replies: []string{`<tool id="read_file">{"path": "a.txt"}</tool>`, "Done."}}
+	te := NewTaskExecutor(client, store, map[string]tools.Tool{"read_file": &failingTool{}}, "")
+
+	var mu sync.Mutex
+	var states []TaskState
+	var toolCalls []string
+	messages := 0
+	te.OnStateChange(func(taskID string, from, to TaskState) {
+		mu.Lock()
+		states = append(states, to)
+		mu.Unlock()
+	})
+	te.OnToolCall(func(taskID string, call ToolCall, result Message, err error) {
+		mu.Lock()
+		toolCalls = append(toolCalls, call.Function.Name)
+		mu.Unlock()
+	})
+	unsubscribe := te.OnMessageAppended(func(taskID string, message Message) {
+		mu.Lock()
+		messages++
+		mu.Unlock()
+	})
+	defer unsubscribe()
+
+	task, _ := te.TaskStore.CreateTask("observed", "", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "read a.txt"}}}}, "")
+	te.ExecuteTask(context.Background(), task)
+	waitForState(t, store, task.ID, TaskStateCompleted)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(states) == 0 || states[len(states)-1] != TaskStateCompleted {
+		t.Errorf("expected the last state change to be COMPLETED, got %v", states)
+	}
+	if len(toolCalls) != 1 || toolCalls[0] != "read_file" {
+		t.Errorf("expected one read_file tool call event, got %v", toolCalls)
+	}
+	if messages < 3 { // Assistant tool call, tool result, final answer
+		t.Errorf("expected at least 3 appended messages, got %d", messages)
+	}
+}
+
+func TestEventsChannelClosesWithContext(t *testing.T) {
+	te := NewTaskExecutor(&staticLLMClient{reply: "ok"}, NewInMemoryTaskStore(), nil, "")
+	ctx, cancel := context.WithCancel(context.Background())
+	events := te.Events(ctx, 10)
+
+	task, _ := te.TaskStore.CreateTask("evented", "", nil, "")
+	te.TaskStore.SetState(task.ID, TaskStateWorking)
+
+	if event := <-events; event.Type != TaskEventCreated || event.TaskID != task.ID {
+		t.Errorf("expected a created event, got %+v", event)
+	}
+	if event := <-events; event.Type != TaskEventStateChanged || event.State != TaskStateWorking {
+		t.Errorf("expected a state change to WORKING, got %+v", event)
+	}
+	cancel()
+	for range events {
+	}
+}
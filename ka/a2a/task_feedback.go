@@ -0,0 +1,204 @@
+package a2a
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"ka/llm"
+)
+
+// Feedback ratings.
+const (
+	FeedbackUp   = "up"
+	FeedbackDown = "down"
+)
+
+var errInvalidFeedback = errors.New("invalid feedback")
+
+// Feedback is a thumbs up/down signal on a task or one of its assistant messages.
+type Feedback struct {
+	Rating       string    `json:"rating"` // "up" or "down"
+	Comment      string    `json:"comment,omitempty"`
+	MessageIndex *int      `json:"message_index,omitempty"` // Index into Messages; nil rates the whole task
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// FeedbackParams defines the parameters of "tasks/feedback".
+type FeedbackParams struct {
+	ID           string `json:"id"` // Task ID
+	Rating       string `json:"rating"`
+	Comment      string `json:"comment,omitempty"`
+	MessageIndex *int   `json:"messageIndex,omitempty"`
+}
+
+// FeedbackStats aggregates feedback over tasks.
+type FeedbackStats struct {
+	Tasks     int     `json:"tasks"` // Tasks considered
+	Rated     int     `json:"rated"` // Tasks with at least one feedback entry
+	Up        int     `json:"up"`
+	Down      int     `json:"down"`
+	UpRatio   float64 `json:"up_ratio"`   // Up / (Up + Down); 0 without feedback
+	ByMessage int     `json:"by_message"` // Entries rating a single message
+}
+
+// TaskStats is returned by "tasks/stats".
+type TaskStats struct {
+	ByState  map[TaskState]int `json:"by_state"`
+	Feedback FeedbackStats     `json:"feedback"`
+}
+
+// FeedbackRecord is one rated conversation in the training-format export:
+// the chat messages up to the rated response, with the rating as the label.
+type FeedbackRecord struct {
+	TaskID   string        `json:"task_id"`
+	Rating   string        `json:"rating"`
+	Comment  string        `json:"comment,omitempty"`
+	Provider string        `json:"provider,omitempty"` // Of the last assistant message in Messages
+	Model    string        `json:"model,omitempty"`
+	Messages []llm.Message `json:"messages"`
+}
+
+// addFeedback validates and stores a feedback entry on the task.
+func addFeedback(store TaskStore, params FeedbackParams) (Feedback, error) {
+	if params.Rating != FeedbackUp && params.Rating != FeedbackDown {
+		return Feedback{}, fmt.Errorf("%w: rating must be '%s' or '%s'", errInvalidFeedback, FeedbackUp, FeedbackDown)
+	}
+	entry := Feedback{Rating: params.Rating, Comment: strings.TrimSpace(params.Comment), MessageIndex: params.MessageIndex, CreatedAt: time.Now().UTC()}
+	_, err := store.UpdateTask(params.ID, func(task *Task) error {
+		if i := params.MessageIndex; i != nil {
+			if *i < 0 || *i >= len(task.Messages) || task.Messages[*i].Role != RoleAssistant {
+				return fmt.Errorf("%w: message %d is not an assistant message of the task", errInvalidFeedback, *i)
+			}
+		}
+		task.Feedback = append(task.Feedback, entry)
+		return nil
+	})
+	return entry, err
+}
+
+// ComputeTaskStats counts tasks by state and aggregates their feedback.
+func ComputeTaskStats(tasks []*Task) TaskStats {
+	stats := TaskStats{ByState: make(map[TaskState]int)}
+	for _, task := range tasks {
+		stats.ByState[task.State]++
+		stats.Feedback.Tasks++
+		if len(task.Feedback) > 0 {
+			stats.Feedback.Rated++
+		}
+		for _, entry := range task.Feedback {
+			if entry.Rating == FeedbackUp {
+				stats.Feedback.Up++
+			} else {
+				stats.Feedback.Down++
+			}
+			if entry.MessageIndex != nil {
+				stats.Feedback.ByMessage++
+			}
+		}
+	}
+	if total := stats.Feedback.Up + stats.Feedback.Down; total > 0 {
+		stats.Feedback.UpRatio = float64(stats.Feedback.Up) / float64(total)
+	}
+	return stats
+}
+
+// ExportFeedback builds a training record for every feedback entry of the tasks.
+func ExportFeedback(tasks []*Task) []FeedbackRecord {
+	records := []FeedbackRecord{}
+	for _, task := range tasks {
+		for _, entry := range task.Feedback {
+			messages := task.Messages
+			if entry.MessageIndex != nil && *entry.MessageIndex < len(messages) {
+				messages = messages[:*entry.MessageIndex+1]
+			}
+			prompt, _, err := buildPromptFromInput(nil, task.ID, messages, task.SystemPrompt) // An export fetches nothing
+			if err != nil {
+				log.Printf("[FeedbackExport] Skipping feedback on task %s: %v", task.ID, err)
+				continue
+			}
+			record := FeedbackRecord{TaskID: task.ID, Rating: entry.Rating, Comment: entry.Comment, Messages: prompt}
+			for i := len(messages) - 1; i >= 0; i-- {
+				if messages[i].Role == RoleAssistant {
+					if p := messages[i].Provenance; p != nil {
+						record.Provider, record.Model = p.Provider, p.Model
+					}
+					break
+				}
+			}
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// TasksFeedbackHandler handles "tasks/feedback".
+func TasksFeedbackHandler(taskStore TaskStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params FeedbackParams
+		rpcReq, ok := decodeJSONRPCRequest(w, r, &params)
+		if !ok {
+			return
+		}
+		if params.ID == "" {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: "Invalid Params: missing task ID"})
+			return
+		}
+		if HiddenFromCaller(r.Context(), taskStore, params.ID) {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32001, Message: "Not Found: Task not found"})
+			return
+		}
+		entry, err := addFeedback(taskStore, params)
+		if err != nil {
+			switch {
+			case errors.Is(err, errInvalidFeedback):
+				sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: fmt.Sprintf("Invalid Params: %v", err)})
+			case errors.Is(err, ErrTaskNotFound):
+				sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32001, Message: "Not Found: Task not found"})
+			default:
+				sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32000, Message: "Internal Server Error: Failed to store feedback", Data: err.Error()})
+			}
+			return
+		}
+		log.Printf("[TaskFeedback %v] Recorded '%s' feedback on task %s.", rpcReq.ID, entry.Rating, params.ID)
+		sendJSONRPCResponse(w, rpcReq.ID, entry, nil)
+	}
+}
+
+// TasksStatsHandler handles "tasks/stats". Callers bound to a project get
+// the stats of its tasks.
+func TasksStatsHandler(taskStore TaskStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rpcReq, ok := decodeJSONRPCRequest(w, r, nil)
+		if !ok {
+			return
+		}
+		tasks, err := taskStore.ListTasks()
+		if err != nil {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32000, Message: "Internal Server Error: Failed to retrieve tasks", Data: err.Error()})
+			return
+		}
+		sendJSONRPCResponse(w, rpcReq.ID, ComputeTaskStats(tasksOfCaller(r.Context(), tasks)), nil)
+	}
+}
+
+// TasksExportFeedbackHandler handles "tasks/exportFeedback", the
+// training-format export of all rated conversations, of the caller's project
+// when the caller is bound to one.
+func TasksExportFeedbackHandler(taskStore TaskStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rpcReq, ok := decodeJSONRPCRequest(w, r, nil)
+		if !ok {
+			return
+		}
+		tasks, err := taskStore.ListTasks()
+		if err != nil {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32000, Message: "Internal Server Error: Failed to retrieve tasks", Data: err.Error()})
+			return
+		}
+		sendJSONRPCResponse(w, rpcReq.ID, map[string]interface{}{"records": ExportFeedback(tasksOfCaller(r.Context(), tasks))}, nil)
+	}
+}
@@ -0,0 +1,48 @@
+package a2a
+
+import (
+	"testing"
+)
+
+func TestTasksFeedbackStatsAndExport(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	task, _ := store.CreateTask("rated", "Be brief.", []Message{
+		{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "2+2?"}}},
+		{Role: RoleAssistant, Parts: []Part{TextPart{Type: "text", Text: "4"}}},
+		{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "and 3+3?"}}},
+		{Role: RoleAssistant, Parts: []Part{TextPart{Type: "text", Text: "5"}}},
+	}, "")
+	store.CreateTask("unrated", "", nil, "")
+
+	resp := callRPC(t, TasksFeedbackHandler(store), "tasks/feedback", `{"id": "`+task.ID+`", "rating": "meh"}`)
+	if resp.Error == nil || resp.Error.Code != -32602 {
+		t.Errorf("Expected an unknown rating to be rejected, got %+v", resp.Error)
+	}
+	resp = callRPC(t, TasksFeedbackHandler(store), "tasks/feedback", `{"id": "`+task.ID+`", "rating": "up", "messageIndex": 0}`)
+	if resp.Error == nil || resp.Error.Code != -32602 {
+		t.Errorf("Expected rating a user message to be rejected, got %+v", resp.Error)
+	}
+	callRPC(t, TasksFeedbackHandler(store), "tasks/feedback", `{"id": "`+task.ID+`", "rating": "up", "messageIndex": 1}`)
+	resp = callRPC(t, TasksFeedbackHandler(store), "tasks/feedback", `{"id": "`+task.ID+`", "rating": "down", "comment": "wrong sum"}`)
+	if resp.Error != nil {
+		t.Fatalf("feedback: %+v", resp.Error)
+	}
+
+	tasks, _ := store.ListTasks()
+	stats := ComputeTaskStats(tasks)
+	if stats.Feedback.Tasks != 2 || stats.Feedback.Rated != 1 || stats.Feedback.Up != 1 || stats.Feedback.Down != 1 || stats.Feedback.ByMessage != 1 || stats.Feedback.UpRatio != 0.5 {
+		t.Errorf("Unexpected feedback stats %+v", stats.Feedback)
+	}
+
+	records := ExportFeedback(tasks)
+	if len(records) != 2 {
+		t.Fatalf("Expected 2 records, got %d", len(records))
+	}
+	// System prompt, user, assistant: the export stops at the rated message.
+	if len(records[0].Messages) != 3 || records[0].Messages[2].Content != "4" {
+		t.Errorf("Unexpected message-level record %+v", records[0].Messages)
+	}
+	if len(records[1].Messages) != 5 || records[1].Rating != FeedbackDown || records[1].Comment != "wrong sum" {
+		t.Errorf("Unexpected task-level record %+v", records[1])
+	}
+}
@@ -0,0 +1,282 @@
+package a2a
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// Task list views selectable with the "view" param of "tasks/list".
+const (
+	TaskListViewSummary = "summary" // TaskSummary per task, the default
+	TaskListViewFull    = "full"    // Complete tasks with messages and artifacts
+)
+
+// taskListWorkers bounds the tasks marshalled concurrently, and with them the
+// tasks held in memory while a list is written.
+const taskListWorkers = 8
+
+// TaskWalker is implemented by task stores that can visit their tasks one at
+// a time, without loading all of them first. Walking stops at the first error
+// fn returns.
+type TaskWalker interface {
+	WalkTasks(fn func(*Task) error) error
+}
+
+// walkTasks visits every task of the store, loading them one at a time when
+// the store supports it.
+func walkTasks(store TaskStore, fn func(*Task) error) error {
+	if walker, ok := store.(TaskWalker); ok {
+		return walker.WalkTasks(fn)
+	}
+	tasks, err := store.ListTasks()
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		if err := fn(task); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TaskPageQuery selects one page of tasks, newest first.
+type TaskPageQuery struct {
+	State        TaskState
+	ParentTaskID *string // Nil for any parent, "" for top-level tasks only
+	After        string  // ID of the last task of the previous page
+	Limit        int     // 0 for no limit
+}
+
+// TaskPager is implemented by task stores that can answer a page query
+// without loading every task, such as SQLiteTaskStore.
+type TaskPager interface {
+	ListTasksPage(query TaskPageQuery) ([]*Task, error)
+}
+
+// taskPageBatch is the page size walkTaskPages loads from a TaskPager.
+const taskPageBatch = 100
+
+// listTasksPage answers a page query, with the store's indices when it is a
+// TaskPager and otherwise by filtering and sorting all of its tasks.
+func listTasksPage(store TaskStore, query TaskPageQuery) ([]*Task, error) {
+	if pager, ok := store.(TaskPager); ok {
+		return pager.ListTasksPage(query)
+	}
+	tasks, err := store.ListTasks()
+	if err != nil {
+		return nil, err
+	}
+	matching := tasks[:0]
+	for _, task := range tasks {
+		if (query.State == "" || task.State == query.State) && (query.ParentTaskID == nil || task.ParentTaskID == *query.ParentTaskID) {
+			matching = append(matching, task)
+		}
+	}
+	sort.Slice(matching, func(i, j int) bool { return newerTask(matching[i], matching[j]) })
+	if query.After != "" {
+		after := -1
+		for i, task := range matching {
+			if task.ID == query.After {
+				after = i
+				break
+			}
+		}
+		if after < 0 {
+			return nil, fmt.Errorf("%w: cursor task %s", ErrTaskNotFound, query.After)
+		}
+		matching = matching[after+1:]
+	}
+	if query.Limit > 0 && len(matching) > query.Limit {
+		matching = matching[:query.Limit]
+	}
+	return matching, nil
+}
+
+// newerTask orders tasks newest first, by ID among tasks created together.
+func newerTask(a, b *Task) bool {
+	if !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.After(b.CreatedAt)
+	}
+	return a.ID > b.ID
+}
+
+// walkTaskPages visits the tasks matching query newest first, loading them a
+// page at a time from a TaskPager. query.Limit is ignored.
+func walkTaskPages(store TaskStore, query TaskPageQuery, fn func(*Task) error) error {
+	query.Limit = 0
+	if _, ok := store.(TaskPager); ok {
+		query.Limit = taskPageBatch
+	}
+	for {
+		tasks, err := listTasksPage(store, query)
+		if err != nil {
+			return err
+		}
+		for _, task := range tasks {
+			if err := fn(task); err != nil {
+				return err
+			}
+		}
+		if query.Limit == 0 || len(tasks) < query.Limit {
+			return nil
+		}
+		query.After = tasks[len(tasks)-1].ID
+	}
+}
+
+// TaskSummary is the listing form of a task, without messages, artifacts and
+// other per-task detail. Fields keep the names they have on Task.
+type TaskSummary struct {
+	ID              string       `json:"id"`
+	Name            string       `json:"name,omitempty"`
+	State           TaskState    `json:"state"`
+	Error           string       `json:"error,omitempty"`
+	ErrorDetail     *ErrorDetail `json:"error_detail,omitempty"`
+	CreatedAt       time.Time    `json:"created_at"`
+	CreatedAtUnixMs int64        `json:"created_at_unix_ms"`
+	UpdatedAt       time.Time    `json:"updated_at"`
+	UpdatedAtUnixMs int64        `json:"updated_at_unix_ms"`
+	ParentTaskID    string       `json:"parent_task_id,omitempty"`
+	Mode            string       `json:"mode,omitempty"`
+	Project         string       `json:"project,omitempty"`
+	Labels          []string     `json:"labels,omitempty"`
+	MessageCount    int          `json:"message_count"`
+	ArtifactCount   int          `json:"artifact_count"`
+	Timing          *TaskTiming  `json:"timing,omitempty"`
+}
+
+// Summarize returns the summary of a task.
+func (t *Task) Summarize() TaskSummary {
+	return TaskSummary{
+		ID:              t.ID,
+		Name:            t.Name,
+		State:           t.State,
+		Error:           t.Error,
+		ErrorDetail:     t.ErrorDetail,
+		CreatedAt:       t.CreatedAt,
+		CreatedAtUnixMs: t.CreatedAtUnixMs,
+		UpdatedAt:       t.UpdatedAt,
+		UpdatedAtUnixMs: t.UpdatedAtUnixMs,
+		ParentTaskID:    t.ParentTaskID,
+		Mode:            t.Mode,
+		Project:         t.Project,
+		Labels:          t.Labels,
+		MessageCount:    len(t.Messages),
+		ArtifactCount:   len(t.Artifacts),
+	}
+}
+
+// errTaskListFull ends the walk of writeTaskList once a page is complete.
+var errTaskListFull = errors.New("task list page is full")
+
+// encodedTask is a task marshalled by a worker of writeTaskList.
+type encodedTask struct {
+	id   string
+	data []byte
+	err  error
+}
+
+// writeTaskList writes the JSON-RPC response of "tasks/list", streaming the
+// result array as tasks are walked instead of building it in memory. Tasks
+// are marshalled in parallel and written in walk order. Errors before the
+// first task is written are sent as a JSON-RPC error; later ones can only
+// end the array early.
+func writeTaskList(ctx context.Context, w http.ResponseWriter, id interface{}, store TaskStore, filter TaskListFilter) (int, error) {
+	full := filter.View == TaskListViewFull
+	loc, err := loadTimeZone(filter.TimeZone)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	walk := func(fn func(*Task) error) error { return walkTasks(store, fn) }
+	if filter.Limit > 0 || filter.Cursor != "" {
+		query := TaskPageQuery{State: filter.State, ParentTaskID: filter.ParentTaskID, After: filter.Cursor}
+		walk = func(fn func(*Task) error) error { return walkTaskPages(store, query, fn) }
+	}
+	slots := make(chan chan encodedTask, taskListWorkers)
+	walkErr := make(chan error, 1)
+	go func() {
+		defer close(slots)
+		listed := 0
+		err := walk(func(task *Task) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if !filter.Matches(task) {
+				return nil
+			}
+			if filter.Limit > 0 && listed == filter.Limit {
+				return errTaskListFull
+			}
+			listed++
+			slot := make(chan encodedTask, 1)
+			slots <- slot
+			go func() {
+				timing := task.Timing(now, loc)
+				var value interface{}
+				if full {
+					value = struct {
+						*Task
+						Timing *TaskTiming `json:"timing"`
+					}{task, timing}
+				} else {
+					summary := task.Summarize()
+					summary.Timing = timing
+					value = summary
+				}
+				data, err := json.Marshal(value)
+				slot <- encodedTask{id: task.ID, data: data, err: err}
+			}()
+			return nil
+		})
+		if errors.Is(err, errTaskListFull) {
+			err = nil
+		}
+		walkErr <- err
+	}()
+
+	idJSON, err := json.Marshal(id)
+	if err != nil {
+		return 0, err
+	}
+	out := bufio.NewWriter(w)
+	count := 0
+	started := false
+	start := func() {
+		if !started {
+			started = true
+			fmt.Fprintf(out, `{"jsonrpc":"2.0","id":%s,"result":[`, idJSON)
+		}
+	}
+	for slot := range slots {
+		encoded := <-slot
+		if encoded.err != nil {
+			log.Printf("[TaskList %v] Skipping task %s that cannot be encoded: %v", id, encoded.id, encoded.err)
+			continue
+		}
+		start()
+		if count > 0 {
+			out.WriteByte(',')
+		}
+		out.Write(encoded.data)
+		count++
+	}
+	if err := <-walkErr; err != nil && !started {
+		out.Flush()
+		sendJSONRPCResponse(w, id, nil, &JSONRPCError{Code: -32000, Message: "Internal Server Error: Failed to retrieve tasks", Data: err.Error()})
+		return 0, err
+	} else if err != nil {
+		log.Printf("[TaskList %v] Listing ended early after %d tasks: %v", id, count, err)
+	}
+	start()
+	out.WriteString("]}\n")
+	return count, out.Flush()
+}
@@ -0,0 +1,87 @@
+package a2a
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func listTasks(t *testing.T, store TaskStore, params string) (result []map[string]interface{}, rpcErr *JSONRPCError) {
+	t.Helper()
+	body := fmt.Sprintf(`{"jsonrpc": "2.0", "id": 7, "method": "tasks/list", "params": %s}`, params)
+	rec := httptest.NewRecorder()
+	TasksListHandler(store, nil)(rec, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+	var resp struct {
+		Result []map[string]interface{} `json:"result"`
+		Error  *JSONRPCError            `json:"error"`
+		ID     int                      `json:"id"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response %q: %v", rec.Body.String(), err)
+	}
+	if resp.ID != 7 {
+		t.Errorf("expected the request id in the response, got %d", resp.ID)
+	}
+	return resp.Result, resp.Error
+}
+
+func TestTasksListSummaryByDefault(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	task, _ := store.CreateTask("listed", "system", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "hi"}}}}, "")
+	store.AddArtifact(task.ID, Artifact{Type: "text/plain", Data: []byte("result")})
+
+	result, rpcErr := listTasks(t, store, `{}`)
+	if rpcErr != nil || len(result) != 1 {
+		t.Fatalf("expected one task, got %v / %+v", result, rpcErr)
+	}
+	summary := result[0]
+	if summary["id"] != task.ID || summary["message_count"] != float64(1) || summary["artifact_count"] != float64(1) {
+		t.Errorf("unexpected summary %v", summary)
+	}
+	if _, ok := summary["messages"]; ok {
+		t.Errorf("expected no messages in the summary view")
+	}
+
+	result, _ = listTasks(t, store, `{"view": "full"}`)
+	if len(result) != 1 || result[0]["messages"] == nil || result[0]["system_prompt"] != "system" {
+		t.Errorf("expected the complete task in the full view, got %v", result)
+	}
+
+	if _, rpcErr = listTasks(t, store, `{"view": "everything"}`); rpcErr == nil || rpcErr.Code != -32602 {
+		t.Errorf("expected an unknown view to be rejected, got %+v", rpcErr)
+	}
+}
+
+func TestTasksListStreamsFileStore(t *testing.T) {
+	store, err := NewFileTaskStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileTaskStore: %v", err)
+	}
+	ids := map[string]bool{}
+	for i := 0; i < 3*taskListWorkers; i++ {
+		task, err := store.CreateTask(fmt.Sprintf("task-%d", i), "", nil, "")
+		if err != nil {
+			t.Fatalf("CreateTask: %v", err)
+		}
+		ids[task.ID] = true
+	}
+
+	result, rpcErr := listTasks(t, store, `null`)
+	if rpcErr != nil || len(result) != len(ids) {
+		t.Fatalf("expected %d tasks, got %d / %+v", len(ids), len(result), rpcErr)
+	}
+	for _, summary := range result {
+		if !ids[summary["id"].(string)] {
+			t.Errorf("unexpected task %v", summary["id"])
+		}
+	}
+}
+
+func TestTasksListEmpty(t *testing.T) {
+	result, rpcErr := listTasks(t, NewInMemoryTaskStore(), `{}`)
+	if rpcErr != nil || result == nil || len(result) != 0 {
+		t.Errorf("expected an empty array, got %v / %+v", result, rpcErr)
+	}
+}
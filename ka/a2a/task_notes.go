@@ -0,0 +1,186 @@
+package a2a
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Note resolutions, used for triaging agent runs.
+const (
+	NoteResolutionOpen      = "open"
+	NoteResolutionResolved  = "resolved"
+	NoteResolutionDismissed = "dismissed"
+)
+
+// TaskNote is a human annotation on a task. Notes are never sent to the LLM.
+type TaskNote struct {
+	ID         string    `json:"id"`
+	Author     string    `json:"author,omitempty"`
+	Comment    string    `json:"comment,omitempty"`
+	Rating     *int      `json:"rating,omitempty"`     // 1 to 5
+	Resolution string    `json:"resolution,omitempty"` // open, resolved or dismissed
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// AddNoteParams defines the parameters of "tasks/addNote".
+type AddNoteParams struct {
+	ID         string `json:"id"` // Task ID
+	Author     string `json:"author,omitempty"`
+	Comment    string `json:"comment,omitempty"`
+	Rating     *int   `json:"rating,omitempty"`
+	Resolution string `json:"resolution,omitempty"`
+}
+
+func (p AddNoteParams) validate() error {
+	if p.ID == "" {
+		return fmt.Errorf("missing task ID")
+	}
+	if strings.TrimSpace(p.Comment) == "" && p.Rating == nil && p.Resolution == "" {
+		return fmt.Errorf("a note needs a comment, rating or resolution")
+	}
+	if p.Rating != nil && (*p.Rating < 1 || *p.Rating > 5) {
+		return fmt.Errorf("rating must be between 1 and 5")
+	}
+	switch p.Resolution {
+	case "", NoteResolutionOpen, NoteResolutionResolved, NoteResolutionDismissed:
+	default:
+		return fmt.Errorf("unknown resolution '%s'", p.Resolution)
+	}
+	return nil
+}
+
+// Resolution returns the most recent resolution set by a note, or "" if none was.
+func (t *Task) Resolution() string {
+	for i := len(t.Notes) - 1; i >= 0; i-- {
+		if t.Notes[i].Resolution != "" {
+			return t.Notes[i].Resolution
+		}
+	}
+	return ""
+}
+
+// Rating returns the most recent rating given in a note, or 0 if none was.
+func (t *Task) Rating() int {
+	for i := len(t.Notes) - 1; i >= 0; i-- {
+		if t.Notes[i].Rating != nil {
+			return *t.Notes[i].Rating
+		}
+	}
+	return 0
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// TasksAddNoteHandler handles "tasks/addNote". The note is stored on the task
+// without touching its messages or state.
+func TasksAddNoteHandler(taskStore TaskStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params AddNoteParams
+		rpcReq, ok := decodeJSONRPCRequest(w, r, &params)
+		if !ok {
+			return
+		}
+		if err := params.validate(); err != nil {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: fmt.Sprintf("Invalid Params: %v", err)})
+			return
+		}
+
+		if HiddenFromCaller(r.Context(), taskStore, params.ID) {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32001, Message: "Not Found: Task not found"})
+			return
+		}
+
+		var note TaskNote
+		_, err := taskStore.UpdateTask(params.ID, func(task *Task) error {
+			note = TaskNote{
+				ID:         fmt.Sprintf("note-%d", len(task.Notes)+1),
+				Author:     params.Author,
+				Comment:    strings.TrimSpace(params.Comment),
+				Rating:     params.Rating,
+				Resolution: params.Resolution,
+				CreatedAt:  time.Now().UTC(),
+			}
+			task.Notes = append(task.Notes, note)
+			return nil
+		})
+		if err != nil {
+			if errors.Is(err, ErrTaskNotFound) {
+				sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32001, Message: "Not Found: Task not found"})
+			} else {
+				sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32000, Message: "Internal Server Error: Failed to add note", Data: err.Error()})
+			}
+			return
+		}
+		log.Printf("[TaskAddNote %v] Added %s to task %s.", rpcReq.ID, note.ID, params.ID)
+		sendJSONRPCResponse(w, rpcReq.ID, note, nil)
+	}
+}
+
+// TaskListFilter narrows "tasks/list" down by project, label and the notes on each task.
+// View selects the form of the listed tasks.
+type TaskListFilter struct {
+	View       string `json:"view,omitempty"`     // TaskListViewSummary (default) or TaskListViewFull
+	Trash      bool   `json:"trash,omitempty"`    // List only the trashed tasks, which are hidden otherwise
+	TimeZone   string `json:"timeZone,omitempty"` // IANA zone of the timestamps in the timing of each task; UTC by default
+	Project    string `json:"project,omitempty"`  // Required when projects are configured, unless the API key implies it
+	Label      string `json:"label,omitempty"`
+	HasNotes   *bool  `json:"hasNotes,omitempty"`
+	Resolution string `json:"resolution,omitempty"` // Latest resolution; "open" also matches tasks without one
+	MinRating  int    `json:"minRating,omitempty"`  // Latest rating at least this
+	MaxRating  int    `json:"maxRating,omitempty"`  // Latest rating at most this; unrated tasks never match
+
+	State        TaskState `json:"state,omitempty"`
+	ParentTaskID *string   `json:"parentTaskId,omitempty"` // "" lists only top-level tasks
+	Limit        int       `json:"limit,omitempty"`        // Page size; paged listings are ordered newest first
+	Cursor       string    `json:"cursor,omitempty"`       // ID of the last task of the previous page
+}
+
+// Matches reports whether a task passes the filter.
+func (f TaskListFilter) Matches(task *Task) bool {
+	if f.Trash != (task.Trash != nil) {
+		return false
+	}
+	if f.Project != "" && task.Project != f.Project {
+		return false
+	}
+	if f.State != "" && task.State != f.State {
+		return false
+	}
+	if f.ParentTaskID != nil && task.ParentTaskID != *f.ParentTaskID {
+		return false
+	}
+	if f.Label != "" && !containsString(task.Labels, f.Label) {
+		return false
+	}
+	if f.HasNotes != nil && *f.HasNotes != (len(task.Notes) > 0) {
+		return false
+	}
+	if f.Resolution != "" {
+		resolution := task.Resolution()
+		if resolution == "" {
+			resolution = NoteResolutionOpen
+		}
+		if resolution != f.Resolution {
+			return false
+		}
+	}
+	rating := task.Rating()
+	if f.MinRating > 0 && rating < f.MinRating {
+		return false
+	}
+	if f.MaxRating > 0 && (rating == 0 || rating > f.MaxRating) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,76 @@
+package a2a
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// callRPC sends a JSON-RPC request with the given params to a handler.
+func callRPC(t *testing.T, handler http.HandlerFunc, method, params string) JSONRPCResponse {
+	t.Helper()
+	body := `{"jsonrpc": "2.0", "id": 1, "method": "` + method + `", "params": ` + params + `}`
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+	var resp JSONRPCResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON-RPC response %q: %v", rec.Body.String(), err)
+	}
+	return resp
+}
+
+func TestTasksAddNote(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	task, _ := store.CreateTask("noted", "", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "hi"}}}}, "")
+
+	resp := callRPC(t, TasksAddNoteHandler(store), "tasks/addNote", `{"id": "`+task.ID+`", "rating": 6}`)
+	if resp.Error == nil || resp.Error.Code != -32602 {
+		t.Errorf("Expected an out-of-range rating to be rejected, got %+v", resp.Error)
+	}
+	resp = callRPC(t, TasksAddNoteHandler(store), "tasks/addNote", `{"id": "missing", "comment": "x"}`)
+	if resp.Error == nil || resp.Error.Code != -32001 {
+		t.Errorf("Expected not found, got %+v", resp.Error)
+	}
+	resp = callRPC(t, TasksAddNoteHandler(store), "tasks/addNote", `{"id": "`+task.ID+`", "author": "ana", "comment": "wrong file", "rating": 2, "resolution": "open"}`)
+	if resp.Error != nil {
+		t.Fatalf("addNote: %+v", resp.Error)
+	}
+	callRPC(t, TasksAddNoteHandler(store), "tasks/addNote", `{"id": "`+task.ID+`", "resolution": "resolved"}`)
+
+	stored, _ := store.GetTask(task.ID)
+	if len(stored.Notes) != 2 || len(stored.Messages) != 1 {
+		t.Fatalf("Expected 2 notes and untouched messages, got %d notes, %d messages", len(stored.Notes), len(stored.Messages))
+	}
+	if stored.Resolution() != NoteResolutionResolved || stored.Rating() != 2 {
+		t.Errorf("Expected resolved with rating 2, got %s/%d", stored.Resolution(), stored.Rating())
+	}
+}
+
+func TestTaskListFilter(t *testing.T) {
+	rating := 4
+	rated := &Task{Notes: []TaskNote{{Rating: &rating, Resolution: NoteResolutionResolved}}}
+	plain := &Task{}
+	hasNotes := true
+
+	cases := []struct {
+		filter       TaskListFilter
+		rated, plain bool
+	}{
+		{TaskListFilter{}, true, true},
+		{TaskListFilter{HasNotes: &hasNotes}, true, false},
+		{TaskListFilter{Resolution: NoteResolutionOpen}, false, true},
+		{TaskListFilter{Resolution: NoteResolutionResolved}, true, false},
+		{TaskListFilter{MinRating: 5}, false, false},
+		{TaskListFilter{MaxRating: 4}, true, false},
+	}
+	for i, c := range cases {
+		if got := c.filter.Matches(rated); got != c.rated {
+			t.Errorf("case %d: rated task matched=%v, want %v", i, got, c.rated)
+		}
+		if got := c.filter.Matches(plain); got != c.plain {
+			t.Errorf("case %d: plain task matched=%v, want %v", i, got, c.plain)
+		}
+	}
+}
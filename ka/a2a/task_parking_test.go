@@ -0,0 +1,102 @@
+package a2a
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"ka/llm"
+)
+
+type staticLLMClient struct{ reply string }
+
+func (c *staticLLMClient) Chat(ctx context.Context, messages []llm.Message, stream bool, out io.Writer) (string, int, int, error) {
+	io.WriteString(out, c.reply)
+	return c.reply, 0, 0, nil
+}
+
+// taskSnapshot copies a task under the store lock. The in-memory store hands
+// out the task it keeps, which the executor goes on updating, so tests read
+// running tasks through a snapshot instead.
+func taskSnapshot(store TaskStore, taskID string) (*Task, error) {
+	if observed, ok := store.(*observedTaskStore); ok {
+		store = observed.TaskStore
+	}
+	if mem, ok := store.(*InMemoryTaskStore); ok {
+		mem.mu.RLock()
+		defer mem.mu.RUnlock()
+		task, ok := mem.tasks[taskID]
+		if !ok {
+			return nil, ErrTaskNotFound
+		}
+		return cloneTask(task)
+	}
+	return store.GetTask(taskID)
+}
+
+func waitForState(t *testing.T, store TaskStore, taskID string, want TaskState) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		task, err := taskSnapshot(store, taskID)
+		if err == nil && task.State == want {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	var last TaskState
+	if task, err := taskSnapshot(store, taskID); err == nil {
+		last = task.State
+	}
+	t.Fatalf("task %s did not reach state %s (last: %s)", taskID, want, last)
+}
+
+func TestResumeTaskRelaunchesParkedTask(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	te := NewTaskExecutor(&staticLLMClient{reply: "All done."}, store, nil, "")
+
+	task, err := store.CreateTask("parked", "", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "hi"}}}}, "")
+	if err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+	// A task parked before a restart only exists in the store.
+	if err := store.SetState(task.ID, TaskStateInputRequired); err != nil {
+		t.Fatalf("SetState: %v", err)
+	}
+
+	if err := te.ResumeTask(task.ID); err != nil {
+		t.Fatalf("ResumeTask: %v", err)
+	}
+	waitForState(t, store, task.ID, TaskStateCompleted)
+
+	deadline := time.Now().Add(time.Second)
+	for te.IsRunning(task.ID) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if te.IsRunning(task.ID) {
+		t.Errorf("expected the run to be released after completion")
+	}
+	if err := te.ResumeTask(task.ID); err == nil {
+		t.Errorf("expected an error resuming a task that is not waiting for input")
+	}
+}
+
+func TestResumeTaskRejectsActiveRun(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	te := NewTaskExecutor(&staticLLMClient{reply: "ok"}, store, nil, "")
+
+	task, _ := store.CreateTask("running", "", nil, "")
+	store.SetState(task.ID, TaskStateInputRequired)
+
+	if !te.claimRun(task.ID) {
+		t.Fatalf("expected to claim an idle task")
+	}
+	if err := te.ResumeTask(task.ID); err == nil {
+		t.Errorf("expected an error resuming a task with an active run")
+	}
+	te.releaseRun(task.ID)
+	if te.IsRunning(task.ID) {
+		t.Errorf("expected no active run after release")
+	}
+}
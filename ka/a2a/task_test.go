@@ -96,7 +96,7 @@ func TestMessageUnmarshalJSON(t *testing.T) {
 			expectError: false,
 		},
 		{
-			name: "Invalid Part Type Skipped",
+			name: "Unknown Part Type Kept",
 			jsonData: `{
 				"role": "user",
 				"parts": [
@@ -109,10 +109,11 @@ func TestMessageUnmarshalJSON(t *testing.T) {
 				Role: RoleUser,
 				Parts: []Part{
 					TextPart{Type: "text", Text: "Valid"},
+					CustomPart{Type: "invalid", Raw: json.RawMessage(`{"type": "invalid", "foo": "bar"}`)},
 					FilePart{Type: "file", MimeType: "text/plain", URI: "file:///doc.txt"},
 				},
 			},
-			expectError: false, // Should not error, just skip invalid part
+			expectError: false, // Unknown part types are kept as CustomPart
 		},
 		{
 			name: "Part Missing Type Skipped",
@@ -132,7 +133,7 @@ func TestMessageUnmarshalJSON(t *testing.T) {
 			expectError: false, // Should not error, just skip invalid part
 		},
 		{
-			name: "Malformed Part Skipped (Unknown Type)", // Renamed for clarity
+			name: "Unknown Type Kept As CustomPart",
 			jsonData: `{
 				"role": "user",
 				"parts": [
@@ -144,9 +145,10 @@ func TestMessageUnmarshalJSON(t *testing.T) {
 				Role: RoleUser,
 				Parts: []Part{
 					TextPart{Type: "text", Text: "Valid"},
+					CustomPart{Type: "unknown_type", Raw: json.RawMessage(`{"type": "unknown_type", "some_data": 123}`)},
 				},
 			},
-			expectError: false, // Should not error, just skip invalid part
+			expectError: false,
 		},
 		{
 			name:        "Malformed Message JSON",
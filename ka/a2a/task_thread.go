@@ -0,0 +1,225 @@
+package a2a
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Depth of remote tasks tasks/thread follows by default and at most. Each
+// level is a tasks/thread call to the agent the task was delegated to.
+const (
+	DefaultThreadDepth = 2
+	maxThreadDepth     = 5
+)
+
+// threadFetchTimeout bounds the fetch of the transcript of one remote task.
+const threadFetchTimeout = 30 * time.Second
+
+// RemoteTaskLink records a task created on another agent on behalf of a
+// task of this one.
+type RemoteTaskLink struct {
+	AgentURL string    `json:"agent_url"` // JSON-RPC endpoint of the remote agent
+	TaskID   string    `json:"task_id"`   // ID of the task on the remote agent
+	LinkedAt time.Time `json:"linked_at"`
+}
+
+// LinkRemoteTask records on a task that work was delegated to the task
+// remoteTaskID of the agent at agentURL, so tasks/thread can include it.
+// Linking the same remote task again is a no-op.
+func LinkRemoteTask(store TaskStore, taskID, agentURL, remoteTaskID string) error {
+	if agentURL == "" || remoteTaskID == "" {
+		return fmt.Errorf("remote task link needs an agent URL and a task ID")
+	}
+	_, err := store.UpdateTask(taskID, func(task *Task) error {
+		for _, link := range task.RemoteTasks {
+			if link.AgentURL == agentURL && link.TaskID == remoteTaskID {
+				return nil
+			}
+		}
+		task.RemoteTasks = append(task.RemoteTasks, RemoteTaskLink{AgentURL: agentURL, TaskID: remoteTaskID, LinkedAt: time.Now().UTC()})
+		return nil
+	})
+	return err
+}
+
+// ThreadParams defines the parameters of "tasks/thread".
+type ThreadParams struct {
+	ID    string `json:"id"`
+	Depth *int   `json:"depth,omitempty"` // Levels of remote tasks to fetch; 0 keeps the thread local
+}
+
+// ThreadEntry is one message of a thread.
+type ThreadEntry struct {
+	Timestamp    time.Time   `json:"timestamp"`
+	Agent        string      `json:"agent,omitempty"` // URL of the agent holding the task; empty for this agent
+	TaskID       string      `json:"task_id"`
+	ParentTaskID string      `json:"parent_task_id,omitempty"`
+	Role         MessageRole `json:"role"`
+	Parts        []Part      `json:"parts"`
+}
+
+// UnmarshalJSON decodes the parts of an entry like those of a message.
+func (e *ThreadEntry) UnmarshalJSON(data []byte) error {
+	type entryAlias ThreadEntry
+	tmp := struct {
+		entryAlias
+		Parts json.RawMessage `json:"parts"`
+	}{}
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return err
+	}
+	var msg Message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return err
+	}
+	*e = ThreadEntry(tmp.entryAlias)
+	e.Parts = msg.Parts
+	return nil
+}
+
+// ThreadError reports a remote task whose messages could not be fetched.
+type ThreadError struct {
+	Agent  string `json:"agent"`
+	TaskID string `json:"task_id"`
+	Error  string `json:"error"`
+}
+
+// ThreadResult is the result of "tasks/thread".
+type ThreadResult struct {
+	ID      string        `json:"id"`
+	Entries []ThreadEntry `json:"entries"`
+	Errors  []ThreadError `json:"errors,omitempty"` // The thread is returned without these tasks
+}
+
+// TasksThreadHandler handles "tasks/thread", which merges the messages of a
+// task, its sub-tasks and the tasks delegated to other agents into one
+// chronological transcript for debugging multi-agent workflows. Remote tasks
+// are fetched with tasks/thread from their agent, signed when the executor
+// has a signer; an agent that cannot be reached is reported in errors.
+func TasksThreadHandler(taskExecutor *TaskExecutor) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params ThreadParams
+		rpcReq, ok := decodeJSONRPCRequest(w, r, &params)
+		if !ok {
+			return
+		}
+		if params.ID == "" {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: "Invalid Params: missing task ID"})
+			return
+		}
+		depth := DefaultThreadDepth
+		if params.Depth != nil {
+			depth = *params.Depth
+		}
+		if depth < 0 || depth > maxThreadDepth {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: fmt.Sprintf("Invalid Params: depth must be between 0 and %d", maxThreadDepth)})
+			return
+		}
+
+		if HiddenFromCaller(r.Context(), taskExecutor.TaskStore, params.ID) {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32001, Message: "Not Found: Task not found"})
+			return
+		}
+		result, err := buildThread(r.Context(), taskExecutor, params.ID, depth)
+		if err != nil {
+			if errors.Is(err, ErrTaskNotFound) {
+				sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32001, Message: "Not Found: Task not found"})
+				return
+			}
+			log.Printf("[TaskThread %v] Error building thread of task %s: %v", rpcReq.ID, params.ID, err)
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32000, Message: "Internal Server Error: Failed to build thread", Data: err.Error()})
+			return
+		}
+		sendJSONRPCResponse(w, rpcReq.ID, result, nil)
+	}
+}
+
+// buildThread collects the messages of the task tree rooted at taskID and of
+// the remote tasks linked from it, up to depth levels of remote agents.
+func buildThread(ctx context.Context, te *TaskExecutor, taskID string, depth int) (*ThreadResult, error) {
+	bundle, err := ExportTaskBundle(te.TaskStore, taskID, nil)
+	if err != nil {
+		return nil, err
+	}
+	result := &ThreadResult{ID: taskID, Entries: []ThreadEntry{}}
+	var links []RemoteTaskLink
+	for _, task := range bundle.Tasks {
+		for _, msg := range task.Messages {
+			result.Entries = append(result.Entries, ThreadEntry{Timestamp: msg.Timestamp, TaskID: task.ID, ParentTaskID: task.ParentTaskID, Role: msg.Role, Parts: msg.Parts})
+		}
+		links = append(links, task.RemoteTasks...)
+	}
+
+	if depth > 0 && len(links) > 0 {
+		client := te.Signer.Client(threadFetchTimeout, te.AgentTransport)
+		for _, link := range links {
+			remote, err := fetchRemoteThread(ctx, client, link, depth-1)
+			if err != nil {
+				log.Printf("[TaskThread] Failed to fetch remote task %s from %s: %v", link.TaskID, link.AgentURL, err)
+				result.Errors = append(result.Errors, ThreadError{Agent: link.AgentURL, TaskID: link.TaskID, Error: err.Error()})
+				continue
+			}
+			for _, entry := range remote.Entries {
+				if entry.Agent == "" {
+					entry.Agent = link.AgentURL
+				}
+				result.Entries = append(result.Entries, entry)
+			}
+			result.Errors = append(result.Errors, remote.Errors...)
+		}
+	}
+
+	sort.SliceStable(result.Entries, func(i, j int) bool {
+		return result.Entries[i].Timestamp.Before(result.Entries[j].Timestamp)
+	})
+	return result, nil
+}
+
+// fetchRemoteThread calls tasks/thread for a linked task on its agent.
+func fetchRemoteThread(ctx context.Context, client *http.Client, link RemoteTaskLink, depth int) (*ThreadResult, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "tasks/thread",
+		"params":  ThreadParams{ID: link.TaskID, Depth: &depth},
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, link.AgentURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("agent returned %s", resp.Status)
+	}
+
+	var rpcResp struct {
+		Result *ThreadResult `json:"result"`
+		Error  *JSONRPCError `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	if rpcResp.Error != nil {
+		return nil, fmt.Errorf("agent returned error %d: %s", rpcResp.Error.Code, strings.TrimSpace(rpcResp.Error.Message))
+	}
+	if rpcResp.Result == nil {
+		return nil, fmt.Errorf("agent returned no thread")
+	}
+	return rpcResp.Result, nil
+}
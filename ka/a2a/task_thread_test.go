@@ -0,0 +1,109 @@
+package a2a
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// setThreadMessages replaces the messages of a task with one text message
+// per offset from base.
+func setThreadMessages(t *testing.T, store TaskStore, id string, base time.Time, texts map[time.Duration]string) {
+	t.Helper()
+	_, err := store.UpdateTask(id, func(task *Task) error {
+		task.Messages = nil
+		for offset, text := range texts {
+			task.Messages = append(task.Messages, Message{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: text}}, Timestamp: base.Add(offset)})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTasksThreadStitchesLocalAndRemoteMessages(t *testing.T) {
+	base := time.Now().UTC().Truncate(time.Second)
+
+	remoteStore := NewInMemoryTaskStore()
+	remoteExecutor := NewTaskExecutor(&staticLLMClient{reply: "ok"}, remoteStore, nil, "")
+	remoteTask, _ := remoteStore.CreateTask("remote", "", nil, "")
+	setThreadMessages(t, remoteStore, remoteTask.ID, base, map[time.Duration]string{2 * time.Second: "remote working"})
+	var agentHeader string
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agentHeader = r.Header.Get(HeaderAgentID)
+		TasksThreadHandler(remoteExecutor)(w, r)
+	}))
+	defer remote.Close()
+
+	store := NewInMemoryTaskStore()
+	te := NewTaskExecutor(&staticLLMClient{reply: "ok"}, store, nil, "")
+	signer, err := NewRequestSigner("local-agent", map[string]*SigningKey{"k": {ID: "k", Algorithm: SigningHMACSHA256, Secret: "s"}}, "k")
+	if err != nil {
+		t.Fatal(err)
+	}
+	te.Signer = signer
+	root, _ := store.CreateTask("root", "", nil, "")
+	child, _ := store.CreateTask("child", "", nil, root.ID)
+	setThreadMessages(t, store, root.ID, base, map[time.Duration]string{0: "start", 4 * time.Second: "done"})
+	setThreadMessages(t, store, child.ID, base, map[time.Duration]string{time.Second: "child delegates"})
+	if err := LinkRemoteTask(store, child.ID, remote.URL, remoteTask.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := LinkRemoteTask(store, child.ID, remote.URL, remoteTask.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := LinkRemoteTask(store, child.ID, "http://127.0.0.1:1", "gone"); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := callRPC(t, TasksThreadHandler(te), "tasks/thread", `{"id": "`+root.ID+`"}`)
+	if resp.Error != nil {
+		t.Fatalf("Unexpected error: %+v", resp.Error)
+	}
+	raw, _ := json.Marshal(resp.Result)
+	var result ThreadResult
+	if err := json.Unmarshal(raw, &result); err != nil {
+		t.Fatal(err)
+	}
+
+	var texts, agents []string
+	for _, entry := range result.Entries {
+		texts = append(texts, entry.Parts[0].(TextPart).Text)
+		agents = append(agents, entry.Agent)
+	}
+	want := []string{"start", "child delegates", "remote working", "done"}
+	if len(texts) != len(want) {
+		t.Fatalf("Expected entries %v, got %v", want, texts)
+	}
+	for i := range want {
+		if texts[i] != want[i] {
+			t.Fatalf("Expected entries %v, got %v", want, texts)
+		}
+	}
+	if agents[2] != remote.URL || agents[0] != "" {
+		t.Errorf("Expected only the remote entry to carry the agent URL, got %v", agents)
+	}
+	if result.Entries[2].TaskID != remoteTask.ID || result.Entries[1].ParentTaskID != root.ID {
+		t.Errorf("Unexpected task linkage: %+v", result.Entries)
+	}
+	if agentHeader != "local-agent" {
+		t.Errorf("Expected the remote fetch to be signed, got agent header %q", agentHeader)
+	}
+	if len(result.Errors) != 1 || result.Errors[0].TaskID != "gone" {
+		t.Errorf("Expected the unreachable agent to be reported, got %+v", result.Errors)
+	}
+
+	resp = callRPC(t, TasksThreadHandler(te), "tasks/thread", `{"id": "`+root.ID+`", "depth": 0}`)
+	raw, _ = json.Marshal(resp.Result)
+	result = ThreadResult{}
+	json.Unmarshal(raw, &result)
+	if len(result.Entries) != 3 || len(result.Errors) != 0 {
+		t.Errorf("Expected depth 0 to keep the thread local, got %+v", result)
+	}
+	if resp := callRPC(t, TasksThreadHandler(te), "tasks/thread", `{"id": "missing"}`); resp.Error == nil || resp.Error.Code != -32001 {
+		t.Errorf("Expected not found, got %+v", resp.Error)
+	}
+}
@@ -0,0 +1,76 @@
+package a2a
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"ka/tools"
+)
+
+// withTaskDeadline bounds ctx by the task's execution timeout. The deadline
+// is fixed when the task first runs and kept across later runs.
+func (te *TaskExecutor) withTaskDeadline(ctx context.Context, taskID string) (context.Context, context.CancelFunc) {
+	task, err := te.TaskStore.GetTask(taskID)
+	if err != nil || task.TimeoutSeconds <= 0 {
+		return ctx, func() {}
+	}
+	deadline := task.Deadline
+	if deadline.IsZero() {
+		deadline = time.Now().UTC().Add(time.Duration(task.TimeoutSeconds) * time.Second)
+		if _, err := te.TaskStore.UpdateTask(taskID, func(t *Task) error {
+			t.Deadline = deadline
+			return nil
+		}); err != nil {
+			log.Printf("[Task %s] Failed to store deadline: %v", taskID, err)
+		}
+	}
+	return context.WithDeadline(ctx, deadline)
+}
+
+// failIfTimedOut fails the task when its run was stopped by the deadline in
+// ctx, stopping its follow-mode commands. It reports whether it did.
+func (te *TaskExecutor) failIfTimedOut(ctx context.Context, taskID string) bool {
+	if !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return false
+	}
+	failed := false
+	_, err := te.TaskStore.UpdateTask(taskID, func(t *Task) error {
+		if t.State == TaskStateCompleted || t.State == TaskStateInputRequired {
+			return nil // Finished before the deadline was noticed
+		}
+		t.State = TaskStateFailed
+		setTaskError(t, &ErrorDetail{
+			Code:      ErrorCodeTaskTimeout,
+			Message:   fmt.Sprintf("task timed out after %ds", t.TimeoutSeconds),
+			Hint:      "Split the work into smaller tasks or send it again with a larger timeoutSeconds.",
+			Retryable: true,
+		})
+		failed = true
+		return nil
+	})
+	if err != nil {
+		log.Printf("[Task %s] Failed to mark task as timed out: %v", taskID, err)
+		return false
+	}
+	if failed {
+		tools.TerminateCommands(taskID, "")
+		log.Printf("[Task %s] Execution timed out.", taskID)
+	}
+	return failed
+}
+
+// RemainingSeconds returns the time left until the task's deadline, or nil
+// if the task has no timeout or has not started yet.
+func (t *Task) RemainingSeconds() *float64 {
+	if t.Deadline.IsZero() {
+		return nil
+	}
+	remaining := time.Until(t.Deadline).Seconds()
+	if remaining < 0 {
+		remaining = 0
+	}
+	return &remaining
+}
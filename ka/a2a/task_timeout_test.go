@@ -0,0 +1,69 @@
+package a2a
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"ka/llm"
+)
+
+// blockingLLMClient never answers and returns once its context is done.
+type blockingLLMClient struct{}
+
+func (c *blockingLLMClient) Chat(ctx context.Context, messages []llm.Message, stream bool, out io.Writer) (string, int, int, error) {
+	<-ctx.Done()
+	return "", 0, 0, ctx.Err()
+}
+
+func TestTaskTimeoutFailsTask(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	te := NewTaskExecutor(&blockingLLMClient{}, store, nil, "")
+
+	task, err := store.CreateTask("slow", "", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "hi"}}}}, "")
+	if err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+	task, _ = store.UpdateTask(task.ID, func(t *Task) error {
+		t.TimeoutSeconds = 1
+		return nil
+	})
+
+	te.ExecuteTask(context.Background(), task)
+	waitForState(t, store, task.ID, TaskStateFailed)
+
+	failed, _ := store.GetTask(task.ID)
+	if !strings.Contains(failed.Error, "timed out") {
+		t.Errorf("expected a timeout error, got %q", failed.Error)
+	}
+	if remaining := failed.RemainingSeconds(); remaining == nil || *remaining != 0 {
+		t.Errorf("expected no remaining time, got %v", remaining)
+	}
+}
+
+func TestCreateTaskResolvesTimeout(t *testing.T) {
+	te := NewTaskExecutor(&staticLLMClient{reply: "ok"}, NewInMemoryTaskStore(), nil, "")
+	te.DefaultTimeoutSeconds = 60
+	msg := Message{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "hi"}}}
+
+	task, rpcErr := te.createTask(context.Background(), "default", SendTaskParams{Message: msg})
+	if rpcErr != nil {
+		t.Fatalf("createTask: %v", rpcErr)
+	}
+	if task.TimeoutSeconds != 60 {
+		t.Errorf("expected the agent default of 60s, got %d", task.TimeoutSeconds)
+	}
+
+	task, rpcErr = te.createTask(context.Background(), "override", SendTaskParams{Message: msg, TimeoutSeconds: 5})
+	if rpcErr != nil {
+		t.Fatalf("createTask: %v", rpcErr)
+	}
+	if task.TimeoutSeconds != 5 {
+		t.Errorf("expected the requested 5s, got %d", task.TimeoutSeconds)
+	}
+
+	if _, rpcErr = te.createTask(context.Background(), "negative", SendTaskParams{Message: msg, TimeoutSeconds: -1}); rpcErr == nil {
+		t.Errorf("expected a negative timeout to be rejected")
+	}
+}
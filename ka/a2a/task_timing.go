@@ -0,0 +1,100 @@
+package a2a
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// isoMillis formats timestamps as ISO-8601 with milliseconds and an explicit
+// zone, "Z" for UTC or an offset such as "+02:00".
+const isoMillis = "2006-01-02T15:04:05.000Z07:00"
+
+// TaskTiming is the timing of a task derived for API responses, so clients
+// do not have to reconstruct it from the raw timestamps. Durations are in
+// milliseconds; QueuedMs and DurationMs are omitted until the task first runs.
+type TaskTiming struct {
+	CreatedAt  string `json:"createdAt"`
+	StartedAt  string `json:"startedAt,omitempty"`  // Start of the first run
+	FinishedAt string `json:"finishedAt,omitempty"` // End of the latest run; omitted while the task runs
+	UpdatedAt  string `json:"updatedAt"`
+	TimeZone   string `json:"timeZone"`
+	QueuedMs   *int64 `json:"queuedMs,omitempty"`   // From creation to the first run
+	DurationMs *int64 `json:"durationMs,omitempty"` // From the first run to the end of the latest one, or to now while running
+	LLMTimeMs  int64  `json:"llmTimeMs"`            // Spent waiting for LLM responses
+	ToolTimeMs int64  `json:"toolTimeMs"`           // Spent executing tool calls
+}
+
+// Timing derives the timing of a task as of now, with timestamps in loc.
+func (t *Task) Timing(now time.Time, loc *time.Location) *TaskTiming {
+	timing := &TaskTiming{
+		CreatedAt:  t.CreatedAt.In(loc).Format(isoMillis),
+		UpdatedAt:  t.UpdatedAt.In(loc).Format(isoMillis),
+		TimeZone:   loc.String(),
+		LLMTimeMs:  t.LLMTimeMs,
+		ToolTimeMs: t.ToolTimeMs,
+	}
+	if t.StartedAt.IsZero() {
+		return timing
+	}
+	timing.StartedAt = t.StartedAt.In(loc).Format(isoMillis)
+	queued := t.StartedAt.Sub(t.CreatedAt).Milliseconds()
+	timing.QueuedMs = &queued
+
+	end := now
+	if t.State != TaskStateWorking && !t.FinishedAt.IsZero() {
+		end = t.FinishedAt
+		timing.FinishedAt = t.FinishedAt.In(loc).Format(isoMillis)
+	}
+	duration := end.Sub(t.StartedAt).Milliseconds()
+	timing.DurationMs = &duration
+	return timing
+}
+
+// loadTimeZone resolves the IANA time zone a client asked for, UTC by default.
+func loadTimeZone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", name)
+	}
+	return loc, nil
+}
+
+// recordRunStart marks the start of a run; only the first run sets StartedAt.
+func (te *TaskExecutor) recordRunStart(taskID string) {
+	_, err := te.TaskStore.UpdateTask(taskID, func(task *Task) error {
+		if task.StartedAt.IsZero() {
+			task.StartedAt = time.Now().UTC()
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("[Task %s] Failed to record the run start: %v", taskID, err)
+	}
+}
+
+// recordRunEnd marks the end of a run, whether the task finished or parked.
+func (te *TaskExecutor) recordRunEnd(taskID string) {
+	_, err := te.TaskStore.UpdateTask(taskID, func(task *Task) error {
+		task.FinishedAt = time.Now().UTC()
+		return nil
+	})
+	if err != nil {
+		log.Printf("[Task %s] Failed to record the run end: %v", taskID, err)
+	}
+}
+
+// addExecutionTime adds time spent in the LLM and in tools to a task.
+func (te *TaskExecutor) addExecutionTime(taskID string, llmTime, toolTime time.Duration) {
+	_, err := te.TaskStore.UpdateTask(taskID, func(task *Task) error {
+		task.LLMTimeMs += llmTime.Milliseconds()
+		task.ToolTimeMs += toolTime.Milliseconds()
+		return nil
+	})
+	if err != nil {
+		log.Printf("[Task %s] Failed to record execution time: %v", taskID, err)
+	}
+}
@@ -0,0 +1,78 @@
+package a2a
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTaskTimingDerivesDurations(t *testing.T) {
+	created := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	task := &Task{
+		State:      TaskStateCompleted,
+		CreatedAt:  created,
+		UpdatedAt:  created.Add(5 * time.Second),
+		StartedAt:  created.Add(1500 * time.Millisecond),
+		FinishedAt: created.Add(4 * time.Second),
+		LLMTimeMs:  2000,
+		ToolTimeMs: 300,
+	}
+	berlin, err := loadTimeZone("Europe/Berlin")
+	if err != nil {
+		t.Skipf("time zone database unavailable: %v", err)
+	}
+
+	timing := task.Timing(created.Add(time.Hour), berlin)
+	if timing.CreatedAt != "2026-03-01T11:00:00.000+01:00" || timing.FinishedAt != "2026-03-01T11:00:04.000+01:00" {
+		t.Errorf("expected timestamps in the Berlin zone, got %s and %s", timing.CreatedAt, timing.FinishedAt)
+	}
+	if timing.QueuedMs == nil || *timing.QueuedMs != 1500 {
+		t.Errorf("expected 1500ms queued, got %v", timing.QueuedMs)
+	}
+	if timing.DurationMs == nil || *timing.DurationMs != 2500 {
+		t.Errorf("expected a duration of 2500ms, got %v", timing.DurationMs)
+	}
+	if timing.LLMTimeMs != 2000 || timing.ToolTimeMs != 300 {
+		t.Errorf("expected the recorded LLM and tool time, got %+v", timing)
+	}
+
+	task.State = TaskStateWorking
+	timing = task.Timing(created.Add(10*time.Second), time.UTC)
+	if timing.FinishedAt != "" || *timing.DurationMs != 8500 {
+		t.Errorf("expected a running task to count up to now, got %+v", timing)
+	}
+
+	task.StartedAt = time.Time{}
+	if timing := task.Timing(created, time.UTC); timing.QueuedMs != nil || timing.DurationMs != nil {
+		t.Errorf("expected no durations before the first run, got %+v", timing)
+	}
+}
+
+func TestExecutionRecordsRunTiming(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	te := NewTaskExecutor(&staticLLMClient{reply: "done"}, store, nil, "")
+	task, err := store.CreateTask("timed", "", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "hi"}}}}, "")
+	if err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+
+	te.ExecuteTask(context.Background(), task)
+	waitForState(t, store, task.ID, TaskStateCompleted)
+
+	finished, _ := store.GetTask(task.ID)
+	if finished.StartedAt.IsZero() || finished.FinishedAt.Before(finished.StartedAt) {
+		t.Errorf("expected the run start and end to be recorded, got %v and %v", finished.StartedAt, finished.FinishedAt)
+	}
+	if timing := finished.Timing(time.Now(), time.UTC); timing.DurationMs == nil || timing.StartedAt == "" {
+		t.Errorf("expected a derived duration, got %+v", timing)
+	}
+}
+
+func TestLoadTimeZoneRejectsUnknownZones(t *testing.T) {
+	if loc, err := loadTimeZone(""); err != nil || loc != time.UTC {
+		t.Errorf("expected UTC by default, got %v, %v", loc, err)
+	}
+	if _, err := loadTimeZone("Mars/Olympus"); err == nil {
+		t.Error("expected an error for an unknown zone")
+	}
+}
@@ -0,0 +1,196 @@
+package a2a
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+)
+
+// TaskTrash marks a soft-deleted task. Trashed tasks are hidden from
+// "tasks/list" until they are restored with "tasks/restore" or purged.
+type TaskTrash struct {
+	DeletedAt   time.Time `json:"deleted_at"`
+	DeletedWith string    `json:"deleted_with,omitempty"` // Task whose delete trashed this sub-task; restoring it restores this one too
+	Workspace   bool      `json:"workspace,omitempty"`    // Remove the task's workspace when it is purged
+}
+
+// TrashTaskCascade moves a task, and its sub-tasks when params.SubTasks is
+// SubTasksDelete, to the trash. Sub-tasks are orphaned right away; workspaces
+// stay until the tasks are purged. The report lists what a purge will remove.
+func TrashTaskCascade(store TaskStore, workspaceRoot string, params TaskDeleteParams) (*TaskDeleteReport, error) {
+	report, err := planTaskDelete(store, workspaceRoot, params)
+	if err != nil {
+		return report, err
+	}
+	report.Trashed = true
+	if params.DryRun {
+		return report, nil
+	}
+	if err := orphanSubTasks(store, report.Orphaned); err != nil {
+		return report, err
+	}
+	now := time.Now().UTC()
+	for _, id := range report.Deleted {
+		trash := &TaskTrash{DeletedAt: now, Workspace: params.Workspace}
+		if id != params.ID {
+			trash.DeletedWith = params.ID
+		}
+		_, err := store.UpdateTask(id, func(task *Task) error {
+			if task.Trash == nil { // Sub-tasks trashed before keep their own entry
+				task.Trash = trash
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, ErrTaskNotFound) {
+			return report, fmt.Errorf("failed to move task %s to the trash: %w", id, err)
+		}
+	}
+	return report, nil
+}
+
+// RestoreTask takes a task out of the trash, together with the sub-tasks
+// trashed with it. It returns the IDs of the restored tasks.
+func RestoreTask(store TaskStore, taskID string) ([]string, error) {
+	task, err := store.GetTask(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if task.Trash == nil {
+		return nil, fmt.Errorf("task %s is not in the trash", taskID)
+	}
+	ids := []string{taskID}
+	err = walkTasks(store, func(t *Task) error {
+		if t.Trash != nil && t.Trash.DeletedWith == taskID {
+			ids = append(ids, t.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if _, err := store.UpdateTask(id, func(t *Task) error { t.Trash = nil; return nil }); err != nil {
+			return nil, fmt.Errorf("failed to restore task %s: %w", id, err)
+		}
+	}
+	return ids, nil
+}
+
+// PurgeTrash permanently deletes the tasks trashed before cutoff, with the
+// workspaces their delete asked to remove. It returns the purged task IDs.
+func PurgeTrash(store TaskStore, workspaceRoot string, cutoff time.Time) ([]string, error) {
+	var expired []*Task
+	err := walkTasks(store, func(task *Task) error {
+		if task.Trash != nil && task.Trash.DeletedAt.Before(cutoff) {
+			expired = append(expired, task)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	purged := []string{}
+	for _, task := range expired {
+		if task.Trash.Workspace {
+			if dir := taskWorkspaceDir(workspaceRoot, task.ID); dir != "" {
+				if err := os.RemoveAll(dir); err != nil {
+					return purged, fmt.Errorf("failed to remove workspace %s: %w", dir, err)
+				}
+			}
+		}
+		if err := store.DeleteTask(task.ID); err != nil && !errors.Is(err, ErrTaskNotFound) {
+			return purged, fmt.Errorf("failed to purge task %s: %w", task.ID, err)
+		}
+		purged = append(purged, task.ID)
+	}
+	return purged, nil
+}
+
+// RunTrashPurge purges tasks older than te.TrashRetention from the trash
+// every interval until ctx is done.
+func (te *TaskExecutor) RunTrashPurge(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			purged, err := PurgeTrash(te.TaskStore, te.TaskWorkspaceRoot, time.Now().Add(-te.TrashRetention))
+			if err != nil {
+				log.Printf("[Trash] Purge failed: %v", err)
+			} else if len(purged) > 0 {
+				log.Printf("[Trash] Purged %d tasks older than %s.", len(purged), te.TrashRetention)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// TaskRestoreParams defines the parameters of "tasks/restore".
+type TaskRestoreParams struct {
+	ID string `json:"id"`
+}
+
+// TasksRestoreHandler handles "tasks/restore", which takes a task out of the trash.
+func TasksRestoreHandler(taskStore TaskStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params TaskRestoreParams
+		rpcReq, ok := decodeJSONRPCRequest(w, r, &params)
+		if !ok {
+			return
+		}
+		if params.ID == "" {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: "Invalid Params: missing task ID"})
+			return
+		}
+		if HiddenFromCaller(r.Context(), taskStore, params.ID) {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32001, Message: "Not Found: Task not found"})
+			return
+		}
+		restored, err := RestoreTask(taskStore, params.ID)
+		if errors.Is(err, ErrTaskNotFound) {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32001, Message: "Not Found: Task not found"})
+			return
+		}
+		if err != nil {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: fmt.Sprintf("Invalid Params: %v", err)})
+			return
+		}
+		log.Printf("[TaskRestore %v] Restored %d tasks from the trash.", rpcReq.ID, len(restored))
+		sendJSONRPCResponse(w, rpcReq.ID, map[string]interface{}{"restored": restored}, nil)
+	}
+}
+
+// PurgeTrashParams defines the parameters of "admin/purgeTrash". Without
+// OlderThanSeconds the whole trash is purged.
+type PurgeTrashParams struct {
+	OlderThanSeconds int `json:"olderThanSeconds,omitempty"`
+}
+
+// AdminPurgeTrashHandler handles "admin/purgeTrash", which permanently
+// deletes trashed tasks ahead of the retention window.
+func AdminPurgeTrashHandler(taskStore TaskStore, workspaceRoot string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params PurgeTrashParams
+		rpcReq, ok := decodeJSONRPCRequest(w, r, &params)
+		if !ok {
+			return
+		}
+		if params.OlderThanSeconds < 0 {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: "Invalid Params: olderThanSeconds must not be negative"})
+			return
+		}
+		cutoff := time.Now().Add(-time.Duration(params.OlderThanSeconds) * time.Second)
+		purged, err := PurgeTrash(taskStore, workspaceRoot, cutoff)
+		if err != nil {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32000, Message: "Internal Server Error: Failed to purge the trash", Data: err.Error()})
+			return
+		}
+		log.Printf("[PurgeTrash %v] Purged %d tasks.", rpcReq.ID, len(purged))
+		sendJSONRPCResponse(w, rpcReq.ID, map[string]interface{}{"purged": purged}, nil)
+	}
+}
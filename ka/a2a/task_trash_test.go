@@ -0,0 +1,89 @@
+package a2a
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func deleteRequest(t *testing.T, te *TaskExecutor, params string) string {
+	t.Helper()
+	body := fmt.Sprintf(`{"jsonrpc": "2.0", "id": 1, "method": "tasks/delete", "params": %s}`, params)
+	rec := httptest.NewRecorder()
+	TasksDeleteHandler(te)(rec, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+	return rec.Body.String()
+}
+
+func TestDeleteMovesTaskToTrash(t *testing.T) {
+	store, parent, child, grandchild := deleteTree(t)
+	te := NewTaskExecutor(&staticLLMClient{reply: "ok"}, store, nil, "")
+	te.TrashRetention = time.Hour
+
+	resp := deleteRequest(t, te, fmt.Sprintf(`{"id": %q, "subTasks": "delete"}`, parent.ID))
+	if !strings.Contains(resp, `"trashed":true`) {
+		t.Fatalf("expected a trash report, got %s", resp)
+	}
+	for _, id := range []string{parent.ID, child.ID, grandchild.ID} {
+		task, err := store.GetTask(id)
+		if err != nil || task.Trash == nil {
+			t.Fatalf("expected task %s in the trash, got %+v / %v", id, task, err)
+		}
+	}
+	if result, _ := listTasks(t, store, `{}`); len(result) != 0 {
+		t.Errorf("expected trashed tasks to be hidden, got %v", result)
+	}
+	if result, _ := listTasks(t, store, `{"trash": true}`); len(result) != 3 {
+		t.Errorf("expected 3 tasks in the trash, got %d", len(result))
+	}
+
+	restored, err := RestoreTask(store, parent.ID)
+	if err != nil || len(restored) != 3 {
+		t.Fatalf("expected the parent and its sub-tasks to be restored, got %v / %v", restored, err)
+	}
+	if result, _ := listTasks(t, store, `{}`); len(result) != 3 {
+		t.Errorf("expected the restored tasks to be listed, got %d", len(result))
+	}
+	if _, err := RestoreTask(store, parent.ID); err == nil {
+		t.Errorf("expected restoring a task outside the trash to fail")
+	}
+}
+
+func TestDeleteFromTrashIsPermanent(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	task, _ := store.CreateTask("doomed", "", nil, "")
+	te := NewTaskExecutor(&staticLLMClient{reply: "ok"}, store, nil, "")
+	te.TrashRetention = time.Hour
+
+	deleteRequest(t, te, fmt.Sprintf(`{"id": %q}`, task.ID))
+	if trashed, err := store.GetTask(task.ID); err != nil || trashed.Trash == nil {
+		t.Fatalf("expected the task in the trash, got %v", err)
+	}
+	deleteRequest(t, te, fmt.Sprintf(`{"id": %q}`, task.ID))
+	if _, err := store.GetTask(task.ID); err == nil {
+		t.Errorf("expected the second delete to remove the task")
+	}
+}
+
+func TestPurgeTrashHonorsCutoff(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	old, _ := store.CreateTask("old", "", nil, "")
+	recent, _ := store.CreateTask("recent", "", nil, "")
+	store.UpdateTask(old.ID, func(task *Task) error {
+		task.Trash = &TaskTrash{DeletedAt: time.Now().Add(-48 * time.Hour)}
+		return nil
+	})
+	store.UpdateTask(recent.ID, func(task *Task) error {
+		task.Trash = &TaskTrash{DeletedAt: time.Now()}
+		return nil
+	})
+
+	purged, err := PurgeTrash(store, "", time.Now().Add(-24*time.Hour))
+	if err != nil || len(purged) != 1 || purged[0] != old.ID {
+		t.Fatalf("expected only the old task to be purged, got %v / %v", purged, err)
+	}
+	if _, err := store.GetTask(recent.ID); err != nil {
+		t.Errorf("expected the recent task to stay in the trash")
+	}
+}
@@ -0,0 +1,113 @@
+package a2a
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// errTaskWorking refuses system prompt changes while the task runs.
+var errTaskWorking = errors.New("the system prompt of a WORKING task cannot be changed; wait for it to stop")
+
+// SystemPromptVersion is a system prompt a task ran with before it was
+// replaced with tasks/update.
+type SystemPromptVersion struct {
+	Version      int       `json:"version"` // 1 is the prompt the task was created with
+	SystemPrompt string    `json:"system_prompt"`
+	ReplacedAt   time.Time `json:"replaced_at"`
+	ReplacedBy   string    `json:"replaced_by,omitempty"`
+}
+
+// SystemPromptVersion returns the version of the current system prompt.
+func (t *Task) SystemPromptVersion() int {
+	return len(t.SystemPromptHistory) + 1
+}
+
+// UpdateTaskParams defines the parameters of "tasks/update". Fields left out
+// keep their value.
+type UpdateTaskParams struct {
+	ID           string  `json:"id"`
+	Name         *string `json:"name,omitempty"`
+	SystemPrompt *string `json:"systemPrompt,omitempty"` // Refused while the task is WORKING
+	Author       string  `json:"author,omitempty"`       // Recorded in the system prompt history
+}
+
+func (p UpdateTaskParams) validate() error {
+	if p.ID == "" {
+		return fmt.Errorf("missing task ID")
+	}
+	if p.Name == nil && p.SystemPrompt == nil {
+		return fmt.Errorf("nothing to update; pass name or systemPrompt")
+	}
+	if p.Name != nil && strings.TrimSpace(*p.Name) == "" {
+		return fmt.Errorf("name must not be empty")
+	}
+	if p.SystemPrompt != nil && strings.TrimSpace(*p.SystemPrompt) == "" {
+		return fmt.Errorf("systemPrompt must not be empty")
+	}
+	return nil
+}
+
+// UpdateTaskResult is the result of "tasks/update".
+type UpdateTaskResult struct {
+	ID                  string    `json:"id"`
+	Name                string    `json:"name"`
+	State               TaskState `json:"state"`
+	SystemPromptVersion int       `json:"systemPromptVersion"`
+}
+
+// TasksUpdateHandler handles "tasks/update", which renames a task or
+// replaces its system prompt, keeping the replaced prompt in the task's
+// system prompt history. The new prompt applies from the next run.
+func TasksUpdateHandler(taskStore TaskStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var params UpdateTaskParams
+		rpcReq, ok := decodeJSONRPCRequest(w, r, &params)
+		if !ok {
+			return
+		}
+		if err := params.validate(); err != nil {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32602, Message: fmt.Sprintf("Invalid Params: %v", err)})
+			return
+		}
+
+		if HiddenFromCaller(r.Context(), taskStore, params.ID) {
+			sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32001, Message: "Not Found: Task not found"})
+			return
+		}
+		task, err := taskStore.UpdateTask(params.ID, func(task *Task) error {
+			if params.SystemPrompt != nil && *params.SystemPrompt != task.SystemPrompt {
+				if task.State == TaskStateWorking {
+					return errTaskWorking
+				}
+				task.SystemPromptHistory = append(task.SystemPromptHistory, SystemPromptVersion{
+					Version:      task.SystemPromptVersion(),
+					SystemPrompt: task.SystemPrompt,
+					ReplacedAt:   time.Now().UTC(),
+					ReplacedBy:   params.Author,
+				})
+				task.SystemPrompt = *params.SystemPrompt
+			}
+			if params.Name != nil {
+				task.Name = strings.TrimSpace(*params.Name)
+			}
+			return nil
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, ErrTaskNotFound):
+				sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32001, Message: "Not Found: Task not found"})
+			case errors.Is(err, errTaskWorking):
+				sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32002, Message: fmt.Sprintf("Conflict: %v", errTaskWorking)})
+			default:
+				sendJSONRPCResponse(w, rpcReq.ID, nil, &JSONRPCError{Code: -32000, Message: "Internal Server Error: Failed to update task", Data: err.Error()})
+			}
+			return
+		}
+		log.Printf("[TaskUpdate %v] Updated task %s (system prompt version %d).", rpcReq.ID, task.ID, task.SystemPromptVersion())
+		sendJSONRPCResponse(w, rpcReq.ID, UpdateTaskResult{ID: task.ID, Name: task.Name, State: task.State, SystemPromptVersion: task.SystemPromptVersion()}, nil)
+	}
+}
@@ -0,0 +1,47 @@
+package a2a
+
+import "testing"
+
+func TestTasksUpdate(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	task, _ := store.CreateTask("typo", "first prompt", nil, "")
+
+	resp := callRPC(t, TasksUpdateHandler(store), "tasks/update", `{"id": "`+task.ID+`"}`)
+	if resp.Error == nil || resp.Error.Code != -32602 {
+		t.Errorf("Expected an empty update to be rejected, got %+v", resp.Error)
+	}
+	resp = callRPC(t, TasksUpdateHandler(store), "tasks/update", `{"id": "missing", "name": "x"}`)
+	if resp.Error == nil || resp.Error.Code != -32001 {
+		t.Errorf("Expected not found, got %+v", resp.Error)
+	}
+
+	resp = callRPC(t, TasksUpdateHandler(store), "tasks/update", `{"id": "`+task.ID+`", "name": " fixed ", "systemPrompt": "second prompt", "author": "ana"}`)
+	if resp.Error != nil {
+		t.Fatalf("update: %+v", resp.Error)
+	}
+	if result := resp.Result.(map[string]interface{}); result["name"] != "fixed" || result["systemPromptVersion"] != float64(2) {
+		t.Errorf("unexpected result %v", result)
+	}
+	stored, _ := store.GetTask(task.ID)
+	if stored.SystemPrompt != "second prompt" || len(stored.SystemPromptHistory) != 1 {
+		t.Fatalf("expected the new prompt with one replaced version, got %q / %+v", stored.SystemPrompt, stored.SystemPromptHistory)
+	}
+	if old := stored.SystemPromptHistory[0]; old.Version != 1 || old.SystemPrompt != "first prompt" || old.ReplacedBy != "ana" {
+		t.Errorf("unexpected history entry %+v", old)
+	}
+
+	// Running tasks can be renamed, but keep their prompt
+	store.SetState(task.ID, TaskStateWorking)
+	resp = callRPC(t, TasksUpdateHandler(store), "tasks/update", `{"id": "`+task.ID+`", "systemPrompt": "third prompt"}`)
+	if resp.Error == nil || resp.Error.Code != -32002 {
+		t.Errorf("Expected a conflict for a WORKING task, got %+v", resp.Error)
+	}
+	resp = callRPC(t, TasksUpdateHandler(store), "tasks/update", `{"id": "`+task.ID+`", "name": "renamed"}`)
+	if resp.Error != nil {
+		t.Errorf("Expected renaming a WORKING task to succeed, got %+v", resp.Error)
+	}
+	stored, _ = store.GetTask(task.ID)
+	if stored.Name != "renamed" || stored.SystemPrompt != "second prompt" {
+		t.Errorf("unexpected task after the refused update: %q / %q", stored.Name, stored.SystemPrompt)
+	}
+}
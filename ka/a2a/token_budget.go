@@ -0,0 +1,55 @@
+package a2a
+
+import (
+	"fmt"
+	"log"
+)
+
+// ErrorCodeTokenBudget is the ErrorDetail code of tasks that used more
+// tokens than their maxTokens budget.
+const ErrorCodeTokenBudget = "token_budget_exceeded"
+
+// TotalTokens returns the prompt and completion tokens the task used.
+func (t *Task) TotalTokens() int {
+	return t.PromptTokens + t.CompletionTokens
+}
+
+// RemainingTokens returns the tokens left in the task's budget, or nil if it
+// has none.
+func (t *Task) RemainingTokens() *int {
+	if t.MaxTokens <= 0 {
+		return nil
+	}
+	remaining := max(t.MaxTokens-t.TotalTokens(), 0)
+	return &remaining
+}
+
+// addTokenUsage adds the tokens of an LLM call to the task. When they exceed
+// its budget and the task would continue, it fails the task and returns the
+// error; a task the call completed keeps its result.
+func (te *TaskExecutor) addTokenUsage(taskID string, promptTokens, completionTokens int) *ErrorDetail {
+	var exceeded *ErrorDetail
+	_, err := te.TaskStore.UpdateTask(taskID, func(task *Task) error {
+		task.PromptTokens += promptTokens
+		task.CompletionTokens += completionTokens
+		if task.MaxTokens <= 0 || task.TotalTokens() <= task.MaxTokens || task.State == TaskStateCompleted || task.State == TaskStateFailed || task.State == TaskStateCanceled {
+			return nil
+		}
+		exceeded = &ErrorDetail{
+			Code:    ErrorCodeTokenBudget,
+			Message: fmt.Sprintf("the task used %d tokens (%d prompt, %d completion), more than its budget of %d", task.TotalTokens(), task.PromptTokens, task.CompletionTokens, task.MaxTokens),
+			Hint:    "Send the task again with a larger maxTokens, or split it into smaller tasks.",
+		}
+		setTaskError(task, exceeded)
+		task.State = TaskStateFailed
+		return nil
+	})
+	if err != nil {
+		log.Printf("[Task %s] Failed to record token usage: %v", taskID, err)
+		return nil
+	}
+	if exceeded != nil {
+		log.Printf("[Task %s] %s", taskID, exceeded.Message)
+	}
+	return exceeded
+}
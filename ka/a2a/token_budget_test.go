@@ -0,0 +1,47 @@
+package a2a
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"ka/tools"
+)
+
+func TestTokenUsageIsCountedAndBudgetEnforced(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	replies := []string{`<tool id="note">{}</tool>`, `<tool id="note">{}</tool>`, "Done."}
+	te := NewTaskExecutor(&tokenReportingClient{scriptedLLMClient{replies: replies}}, store, map[string]tools.Tool{"note": &countingTool{name: "note"}}, "")
+
+	unlimited, _ := te.TaskStore.CreateTask("unlimited", "", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "go"}}}}, "")
+	te.ExecuteTask(context.Background(), unlimited)
+	unlimited, _ = store.GetTask(unlimited.ID)
+	if unlimited.State != TaskStateCompleted || unlimited.PromptTokens != 36 || unlimited.CompletionTokens != 9 {
+		t.Fatalf("expected 3 calls of 12+3 tokens to be counted, got %s %d+%d", unlimited.State, unlimited.PromptTokens, unlimited.CompletionTokens)
+	}
+
+	te.LLMClient = &tokenReportingClient{scriptedLLMClient{replies: replies}}
+	limited, _ := te.TaskStore.CreateTask("limited", "", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "go"}}}}, "")
+	store.UpdateTask(limited.ID, func(t *Task) error {
+		t.MaxTokens = 20
+		return nil
+	})
+	te.ExecuteTask(context.Background(), limited)
+	limited, _ = store.GetTask(limited.ID)
+	if limited.State != TaskStateFailed || limited.ErrorDetail == nil || limited.ErrorDetail.Code != ErrorCodeTokenBudget || limited.TotalTokens() != 30 {
+		t.Fatalf("expected the task to fail on the second call, got %s %+v with %d tokens", limited.State, limited.ErrorDetail, limited.TotalTokens())
+	}
+
+	rec := httptest.NewRecorder()
+	TasksStatusHandler(store)(rec, httptest.NewRequest("GET", "/tasks/status?id="+limited.ID, nil))
+	var status struct {
+		MaxTokens       int  `json:"max_tokens"`
+		TotalTokens     int  `json:"total_tokens"`
+		RemainingTokens *int `json:"remaining_tokens"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &status)
+	if status.MaxTokens != 20 || status.TotalTokens != 30 || status.RemainingTokens == nil || *status.RemainingTokens != 0 {
+		t.Errorf("expected the usage in tasks/status, got %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,81 @@
+package a2a
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"ka/tools"
+)
+
+// ToolAuditRecord is the content of a tool audit artifact: everything needed to
+// understand and reproduce one tool call.
+type ToolAuditRecord struct {
+	TaskID      string                 `json:"task_id"`
+	ToolCallID  string                 `json:"tool_call_id,omitempty"`
+	ToolName    string                 `json:"tool_name"`
+	Arguments   string                 `json:"arguments,omitempty"` // Raw tool call content, byte for byte
+	Attributes  map[string]string      `json:"attributes,omitempty"`
+	WorkingDir  string                 `json:"working_dir,omitempty"`
+	Environment map[string]string      `json:"environment"` // Secret-looking values are redacted
+	Result      string                 `json:"result,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+	Execution   *tools.ExecutionRecord `json:"execution,omitempty"` // stdout/stderr/exit status for process tools
+	StartedAt   time.Time              `json:"started_at"`
+	DurationMs  int64                  `json:"duration_ms"`
+}
+
+// saveToolAudit stores the audit record of a tool call as a JSON artifact and
+// returns the artifact ID, or "" if it could not be stored.
+func saveToolAudit(store TaskStore, taskID string, toolCall ToolCall, result string, toolErr error, execution *tools.ExecutionRecord, startedAt time.Time) string {
+	record := ToolAuditRecord{
+		TaskID:      taskID,
+		ToolCallID:  toolCall.ID,
+		ToolName:    toolCall.Function.Name,
+		Attributes:  make(map[string]string),
+		Environment: tools.EnvironmentSnapshot(),
+		Arguments:   toolCall.Function.Content,
+		Result:      result,
+		StartedAt:   startedAt.UTC(),
+		DurationMs:  time.Since(startedAt).Milliseconds(),
+	}
+	for key, value := range toolCall.Function.Attributes {
+		if !strings.HasPrefix(key, "__") {
+			record.Attributes[key] = value
+		}
+	}
+	if wd, err := os.Getwd(); err == nil {
+		record.WorkingDir = wd
+	}
+	if toolErr != nil {
+		record.Error = toolErr.Error()
+	}
+	if execution != nil && (execution.Stdout != "" || execution.Stderr != "" || execution.ExitCode != nil) {
+		record.Execution = execution
+	}
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		log.Printf("[Task %s] Failed to marshal tool audit record: %v", taskID, err)
+		return ""
+	}
+	artifactID := fmt.Sprintf("tool-audit-%d", time.Now().UnixNano())
+	callRef := toolCall.ID
+	if callRef == "" {
+		callRef = artifactID
+	}
+	artifact := Artifact{
+		ID:       artifactID,
+		Type:     "application/json",
+		Filename: fmt.Sprintf("tool-audit-%s-%s.json", toolCall.Function.Name, callRef),
+		Data:     data,
+	}
+	if err := store.AddArtifact(taskID, artifact); err != nil {
+		log.Printf("[Task %s] Failed to store tool audit artifact: %v", taskID, err)
+		return ""
+	}
+	return artifactID
+}
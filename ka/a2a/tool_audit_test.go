@@ -0,0 +1,49 @@
+package a2a
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"ka/tools"
+)
+
+func TestDispatchToolCall_StoresAuditArtifact(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	task, _ := store.CreateTask("t", "", nil, "")
+	dispatcher := NewToolDispatcher(store, map[string]tools.Tool{"read_file": &failingTool{}})
+	dispatcher.audit = true
+	call := ToolCall{ID: "call-1", Function: tools.FunctionCall{Name: "read_file", Content: `{"path": "missing.txt"}`}}
+
+	msg, _ := dispatcher.DispatchToolCall(context.Background(), task.ID, call)
+
+	var result map[string]interface{}
+	if err := json.Unmarshal([]byte(msg.Parts[0].(TextPart).Text), &result); err != nil {
+		t.Fatalf("Tool result is not JSON: %v", err)
+	}
+	artifactID, _ := result["audit_artifact_id"].(string)
+	if artifactID == "" {
+		t.Fatalf("Expected the tool result to reference an audit artifact, got %v", result)
+	}
+
+	data, artifact, err := store.GetArtifactData(task.ID, artifactID)
+	if err != nil {
+		t.Fatalf("GetArtifactData: %v", err)
+	}
+	if artifact.Type != "application/json" {
+		t.Errorf("Expected a JSON artifact, got %s", artifact.Type)
+	}
+	var record ToolAuditRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		t.Fatalf("Audit artifact is not a ToolAuditRecord: %v", err)
+	}
+	if record.ToolName != "read_file" || record.ToolCallID != "call-1" || record.Error == "" {
+		t.Errorf("Unexpected audit record: %+v", record)
+	}
+	if record.Arguments != `{"path": "missing.txt"}` {
+		t.Errorf("Expected exact arguments, got %s", record.Arguments)
+	}
+	if _, ok := record.Attributes["__task_id"]; ok {
+		t.Errorf("Expected internal attributes to be left out of the audit record")
+	}
+}
@@ -3,30 +3,89 @@ package a2a
 import (
 	"context"
 	"encoding/json" // Import the json package
+	"errors"
 	"fmt"
 	"log"
+	"sync"
+	"time"
 
 	"ka/tools" // Import the tools package
 )
 
-// ToolDispatcher handles routing tool calls to the appropriate tool implementations.
-type ToolDispatcher struct {
+// ToolDispatcher executes the tool calls of a task. The executor uses
+// TaskExecutor.Dispatcher when set, so policies, approval, caching or mocks
+// can wrap or replace the DefaultToolDispatcher.
+type ToolDispatcher interface {
+	// DispatchToolCall runs one tool call and returns the RoleTool message
+	// with its result. The message is valid even when an error is returned.
+	DispatchToolCall(ctx context.Context, taskID string, toolCall ToolCall) (Message, error)
+}
+
+// DefaultToolDispatcher handles routing tool calls to the appropriate tool implementations.
+type DefaultToolDispatcher struct {
 	taskStore      TaskStore
 	availableTools map[string]tools.Tool // Map of available tools
+	policy         *ToolPolicy
+	audit          bool // Store a ToolAuditRecord artifact for each executed tool call
+	modes          *ModeRegistry
+	readOnly       *ReadOnlyMode
+	quotas         ToolQuotas // Global per-tool limits; tasks may set their own
+	outputFilters  ToolOutputFilters
+	guardrails     *Guardrails
+	workspaceRoot  string           // Holds a workspace per task, used as the working directory of its commands
+	projects       *ProjectRegistry // Project workspaces are the working directory of tasks without their own
 }
 
-// NewToolDispatcher creates a new ToolDispatcher.
+// NewToolDispatcher creates a new DefaultToolDispatcher.
 // It now accepts the map of available tools.
-func NewToolDispatcher(store TaskStore, availableTools map[string]tools.Tool) *ToolDispatcher {
-	return &ToolDispatcher{
+func NewToolDispatcher(store TaskStore, availableTools map[string]tools.Tool) *DefaultToolDispatcher {
+	return &DefaultToolDispatcher{
 		taskStore:      store,
 		availableTools: availableTools,
 	}
 }
 
+// dispatchListeners receive live notifications of running tools.
+type dispatchListeners struct {
+	// onProgress, if set, receives progress notifications of running tools (e.g. to forward them over SSE).
+	onProgress func(taskID string, progress tools.ToolProgress)
+	// onOutput, if set, receives live output of commands running in follow mode.
+	onOutput func(taskID string, output tools.ToolOutput)
+}
+
+type dispatchListenersKey struct{}
+
+// withDispatchListeners returns a context whose tool calls forward their
+// progress and live output to the listeners, whichever dispatcher runs them.
+func withDispatchListeners(ctx context.Context, listeners dispatchListeners) context.Context {
+	return context.WithValue(ctx, dispatchListenersKey{}, listeners)
+}
+
+// workingDir returns the directory the tools of a task run programs in: the
+// task's own workspace, else the workspace of its project, else "".
+func (td *DefaultToolDispatcher) workingDir(taskID string) string {
+	if dir := taskWorkspaceDir(td.workspaceRoot, taskID); dir != "" {
+		return dir
+	}
+	if td.projects == nil {
+		return ""
+	}
+	task, err := td.taskStore.GetTask(taskID)
+	if err != nil || task.Project == "" {
+		return ""
+	}
+	project, _ := td.projects.Get(task.Project)
+	return project.Workspace
+}
+
+func dispatchListenersFrom(ctx context.Context) dispatchListeners {
+	listeners, _ := ctx.Value(dispatchListenersKey{}).(dispatchListeners)
+	return listeners
+}
+
 // DispatchToolCall takes a ToolCall and executes the corresponding tool function.
 // It returns a Message with RoleTool containing the result, or an error.
-func (td *ToolDispatcher) DispatchToolCall(ctx context.Context, taskID string, toolCall ToolCall) (Message, error) {
+func (td *DefaultToolDispatcher) DispatchToolCall(ctx context.Context, taskID string, toolCall ToolCall) (Message, error) {
 	log.Printf("[Task %s] Dispatching tool call: %s (ID: %s)", taskID, toolCall.Function.Name, toolCall.ID)
 
 	// Find the tool implementation by name
@@ -43,9 +102,52 @@ func (td *ToolDispatcher) DispatchToolCall(ctx context.Context, taskID string, t
 				Text: fmt.Sprintf("Error: Tool '%s' not found.", toolCall.Function.Name),
 			}},
 		}
+		recordToolFailure(td.taskStore, taskID, toolCall, toolErr)
 		return toolMessage, toolErr // Return the message and the error
 	}
 
+	policyErr := td.policy.CheckTool(toolCall.Function.Name)
+	if policyErr == nil {
+		policyErr = td.readOnly.CheckTool(toolCall.Function.Name)
+	}
+	if policyErr == nil {
+		policyErr = checkToolMode(td.taskStore, td.modes, taskID, toolCall.Function.Name)
+	}
+	if policyErr == nil {
+		policyErr = checkToolGuardrails(td.taskStore, td.guardrails, taskID, toolCall)
+		if errors.Is(policyErr, errApprovalRequired) {
+			log.Printf("[Task %s] %v", taskID, policyErr)
+			return Message{
+				Role:       RoleTool,
+				ToolCallID: toolCall.ID,
+				Parts:      []Part{TextPart{Type: "text", Text: approvalMessage(policyErr)}},
+			}, policyErr
+		}
+	}
+	if policyErr != nil {
+		log.Printf("[Task %s] %v", taskID, policyErr)
+		toolMessage := Message{
+			Role:       RoleTool,
+			ToolCallID: toolCall.ID,
+			Parts: []Part{TextPart{
+				Type: "text",
+				Text: fmt.Sprintf("Error: %v", policyErr),
+			}},
+		}
+		recordToolFailure(td.taskStore, taskID, toolCall, policyErr)
+		return toolMessage, policyErr
+	}
+
+	remainingBytes, budgetErr := reserveToolCall(td.taskStore, td.quotas, taskID, toolCall.Function.Name)
+	if budgetErr != nil {
+		log.Printf("[Task %s] %v", taskID, budgetErr)
+		return Message{
+			Role:       RoleTool,
+			ToolCallID: toolCall.ID,
+			Parts:      []Part{TextPart{Type: "text", Text: budgetExceededMessage(budgetErr)}},
+		}, budgetErr
+	}
+
 	// The toolCall.Function (which is an a2a.FunctionCall struct) now contains
 	// Name, Attributes, and Content.
 	// Each tool's Execute method is responsible for interpreting these as needed.
@@ -57,9 +159,73 @@ func (td *ToolDispatcher) DispatchToolCall(ctx context.Context, taskID string, t
 		toolCall.Function.Attributes = make(map[string]string)
 	}
 	toolCall.Function.Attributes["__task_id"] = taskID // Use a distinct key
+	if td.readOnly.Enabled() {
+		ctx = tools.WithReadOnly(ctx)
+	}
+	if toolCall.ID != "" {
+		toolCall.Function.Attributes["__tool_call_id"] = toolCall.ID
+	}
+
+	// Collect progress notifications for the tool result and forward them while the tool runs
+	listeners := dispatchListenersFrom(ctx)
+	var progressMu sync.Mutex
+	var progressTrace []tools.ToolProgress
+	ctx = tools.WithProgressReporter(ctx, func(progress tools.ToolProgress) {
+		progressMu.Lock()
+		progressTrace = append(progressTrace, progress)
+		progressMu.Unlock()
+		if listeners.onProgress != nil {
+			listeners.onProgress(taskID, progress)
+		}
+	})
+
+	ctx = tools.WithArtifactReader(ctx, func(artifactID string) ([]byte, string, error) {
+		data, artifact, err := td.taskStore.GetArtifactData(taskID, artifactID)
+		if err != nil {
+			return nil, "", err
+		}
+		return data, artifact.Filename, nil
+	})
+	ctx = tools.WithArtifactWriter(ctx, func(name, mimeType string, data []byte) (string, error) {
+		id := fmt.Sprintf("artifact-%d", time.Now().UnixNano())
+		return id, td.taskStore.AddArtifact(taskID, Artifact{ID: id, Type: mimeType, Filename: name, Data: data})
+	})
+
+	ctx = tools.WithRemoteTaskLinker(ctx, func(agentURL, remoteTaskID string) error {
+		return LinkRemoteTask(td.taskStore, taskID, agentURL, remoteTaskID)
+	})
+
+	if dir := td.workingDir(taskID); dir != "" {
+		ctx = tools.WithWorkingDir(ctx, dir)
+	}
+
+	if listeners.onOutput != nil {
+		ctx = tools.WithOutputReporter(ctx, func(output tools.ToolOutput) {
+			listeners.onOutput(taskID, output)
+		})
+	}
+
+	var execution *tools.ExecutionRecord
+	if td.audit {
+		execution = &tools.ExecutionRecord{}
+		ctx = tools.WithExecutionRecord(ctx, execution)
+	}
+	startedAt := time.Now()
 
 	// Execute the tool's Execute method, passing the entire FunctionCall detail
 	toolResultString, toolErr := tool.Execute(ctx, toolCall.Function)
+	if toolErr == nil {
+		var filterErr error
+		if toolResultString, filterErr = filterToolOutput(td.outputFilters, toolCall.Function.Name, toolResultString); filterErr != nil {
+			log.Printf("[Task %s] %v. Passing the unfiltered output.", taskID, filterErr)
+		}
+	}
+	if remainingBytes >= 0 {
+		if int64(len(toolResultString)) > remainingBytes {
+			toolResultString = truncateOutput(toolResultString, int(remainingBytes)) + " The byte budget of this tool is now used up."
+		}
+		recordToolBytes(td.taskStore, taskID, toolCall.Function.Name, len(toolResultString))
+	}
 
 	// Construct the tool message response
 	toolMessage := Message{
@@ -75,6 +241,11 @@ func (td *ToolDispatcher) DispatchToolCall(ctx context.Context, taskID string, t
 		"result":    toolResultString,
 		"error":     nil, // Initialize error to nil
 	}
+	progressMu.Lock()
+	if len(progressTrace) > 0 {
+		toolResultData["progress"] = progressTrace
+	}
+	progressMu.Unlock()
 
 	if toolErr != nil {
 		// If there was an error executing the tool, include the error message
@@ -82,10 +253,17 @@ func (td *ToolDispatcher) DispatchToolCall(ctx context.Context, taskID string, t
 		toolResultData["error"] = fmt.Sprintf("Error executing tool %s (ID: %s): %v", toolCall.Function.Name, toolCall.ID, toolErr)
 		// Also set the result to an empty string or a specific error indicator if needed
 		toolResultData["result"] = "" // Clear result on error
+		recordToolFailure(td.taskStore, taskID, toolCall, toolErr)
 	} else {
 		log.Printf("[Task %s] Tool %s (ID: %s) executed successfully. Result: %s", taskID, toolCall.Function.Name, toolCall.ID, toolResultString)
 	}
 
+	if td.audit {
+		if artifactID := saveToolAudit(td.taskStore, taskID, toolCall, toolResultString, toolErr, execution, startedAt); artifactID != "" {
+			toolResultData["audit_artifact_id"] = artifactID
+		}
+	}
+
 	// Marshal the tool result data into a JSON string
 	toolResultJSON, marshalErr := json.Marshal(toolResultData)
 	if marshalErr != nil {
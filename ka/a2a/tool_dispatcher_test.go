@@ -0,0 +1,70 @@
+package a2a
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"ka/tools"
+)
+
+// recordingDispatcher answers every tool call without running a tool.
+type recordingDispatcher struct {
+	calls []string
+}
+
+func (d *recordingDispatcher) DispatchToolCall(ctx context.Context, taskID string, toolCall ToolCall) (Message, error) {
+	d.calls = append(d.calls, toolCall.Function.Name)
+	return Message{Role: RoleTool, ToolCallID: toolCall.ID, Parts: []Part{TextPart{Type: "text", Text: "mocked"}}}, nil
+}
+
+func TestExecutorUsesInjectedDispatcher(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	client := &scriptedLLMClient{replies: []string{
+		`<tool id="read_file">{"path": "a.txt"}</tool>`,
+		"Done.",
+	}}
+	te := NewTaskExecutor(client, store, map[string]tools.Tool{"read_file": &failingTool{}}, "")
+	dispatcher := &recordingDispatcher{}
+	te.Dispatcher = dispatcher
+
+	task, _ := store.CreateTask("mocked", "", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "read a.txt"}}}}, "")
+	te.ExecuteTask(context.Background(), task)
+	waitForState(t, store, task.ID, TaskStateCompleted)
+
+	if len(dispatcher.calls) != 1 || dispatcher.calls[0] != "read_file" {
+		t.Errorf("expected the injected dispatcher to run read_file once, got %v", dispatcher.calls)
+	}
+	done, _ := store.GetTask(task.ID)
+	if len(done.ToolFailures) != 0 {
+		t.Errorf("expected the real tool not to run, got failures %v", done.ToolFailures)
+	}
+}
+
+func TestDispatcherRunsCommandsInTaskWorkspace(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	te := NewTaskExecutor(nil, store, map[string]tools.Tool{"execute_command": &tools.ExecuteCommandTool{}}, "")
+	te.TaskWorkspaceRoot = t.TempDir()
+	projectDir := t.TempDir()
+	te.Projects, _ = NewProjectRegistry([]Project{{Name: "site", Workspace: projectDir}})
+
+	own, _ := store.CreateTask("own", "", nil, "")
+	os.Mkdir(filepath.Join(te.TaskWorkspaceRoot, own.ID), 0755)
+	inProject, _ := store.CreateTask("project", "", nil, "")
+	store.UpdateTask(inProject.ID, func(task *Task) error {
+		task.Project = "site"
+		return nil
+	})
+
+	for taskID, expected := range map[string]string{own.ID: filepath.Join(te.TaskWorkspaceRoot, own.ID), inProject.ID: projectDir} {
+		message, err := te.NewDefaultToolDispatcher().DispatchToolCall(context.Background(), taskID, ToolCall{ID: "call", Function: tools.FunctionCall{Name: "execute_command", Content: `{"command": "pwd"}`}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if text := message.Parts[0].(TextPart).Text; !strings.Contains(text, `"result":"`+expected+`\n"`) {
+			t.Errorf("expected the command of task %s to run in %s, got %q", taskID, expected, text)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package a2a
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"ka/llm"
+)
+
+// Limits that keep the failure note compact.
+const (
+	maxToolFailuresNoted   = 10
+	maxToolFailureErrorLen = 200
+)
+
+// Tool failure categories.
+const (
+	ToolFailureUnknownTool      = "unknown_tool"
+	ToolFailurePolicy           = "policy"
+	ToolFailureInvalidArguments = "invalid_arguments"
+	ToolFailureNotFound         = "not_found"
+	ToolFailurePermission       = "permission"
+	ToolFailureTimeout          = "timeout"
+	ToolFailureOther            = "other"
+)
+
+// ToolFailure records a failed tool invocation within a task. Identical calls
+// (same tool and arguments) are collapsed into one entry with a count.
+type ToolFailure struct {
+	ToolName  string    `json:"tool_name"`
+	ArgsHash  string    `json:"args_hash"`
+	Arguments string    `json:"arguments,omitempty"`
+	Category  string    `json:"category"`
+	Error     string    `json:"error"`
+	Count     int       `json:"count"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// hashToolArguments returns a short stable hash of the raw tool arguments.
+func hashToolArguments(content string) string {
+	sum := sha256.Sum256([]byte(strings.TrimSpace(content)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// categorizeToolError maps a tool error to a coarse category.
+func categorizeToolError(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ToolFailureTimeout
+	}
+	msg := strings.ToLower(err.Error())
+	switch {
+	case strings.HasPrefix(msg, "unknown tool"):
+		return ToolFailureUnknownTool
+	case strings.Contains(msg, "by policy") || strings.Contains(msg, "not allowed"):
+		return ToolFailurePolicy
+	case strings.Contains(msg, "failed to parse") || strings.Contains(msg, "missing") || strings.Contains(msg, "invalid"):
+		return ToolFailureInvalidArguments
+	case strings.Contains(msg, "no such file") || strings.Contains(msg, "not found") || strings.Contains(msg, "does not exist"):
+		return ToolFailureNotFound
+	case strings.Contains(msg, "permission denied"):
+		return ToolFailurePermission
+	case strings.Contains(msg, "timeout") || strings.Contains(msg, "timed out"):
+		return ToolFailureTimeout
+	default:
+		return ToolFailureOther
+	}
+}
+
+// recordToolFailure adds or updates the failure entry for toolCall on the task.
+func recordToolFailure(store TaskStore, taskID string, toolCall ToolCall, toolErr error) {
+	argsHash := hashToolArguments(toolCall.Function.Content)
+	errText := toolErr.Error()
+	if len(errText) > maxToolFailureErrorLen {
+		errText = errText[:maxToolFailureErrorLen] + "..."
+	}
+	args := toolCall.Function.Content
+	if len(args) > maxToolFailureErrorLen {
+		args = args[:maxToolFailureErrorLen] + "..."
+	}
+
+	_, err := store.UpdateTask(taskID, func(task *Task) error {
+		for i := range task.ToolFailures {
+			failure := &task.ToolFailures[i]
+			if failure.ToolName == toolCall.Function.Name && failure.ArgsHash == argsHash {
+				failure.Count++
+				failure.Error = errText
+				failure.Category = categorizeToolError(toolErr)
+				failure.LastSeen = time.Now().UTC()
+				return nil
+			}
+		}
+		task.ToolFailures = append(task.ToolFailures, ToolFailure{
+			ToolName:  toolCall.Function.Name,
+			ArgsHash:  argsHash,
+			Arguments: args,
+			Category:  categorizeToolError(toolErr),
+			Error:     errText,
+			Count:     1,
+			LastSeen:  time.Now().UTC(),
+		})
+		return nil
+	})
+	if err != nil {
+		// Not fatal: the tool result message still carries the error.
+		fmt.Printf("[Task %s] Warning: failed to record tool failure: %v\n", taskID, err)
+	}
+}
+
+// toolFailureNote renders the most recent failures as a compact note for the LLM.
+func toolFailureNote(failures []ToolFailure) string {
+	if len(failures) == 0 {
+		return ""
+	}
+	if len(failures) > maxToolFailuresNoted {
+		failures = failures[len(failures)-maxToolFailuresNoted:]
+	}
+	var b strings.Builder
+	b.WriteString("PREVIOUSLY FAILED TOOL CALLS (do not repeat them unchanged; fix the arguments or try a different approach):\n")
+	for _, failure := range failures {
+		fmt.Fprintf(&b, "- %s %s -> %s (x%d): %s\n", failure.ToolName, failure.Arguments, failure.Category, failure.Count, failure.Error)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// appendToolFailureNote adds the failure note as a trailing system message so
+// the conversation history itself stays unchanged.
+func appendToolFailureNote(llmMessages []llm.Message, failures []ToolFailure) []llm.Message {
+	note := toolFailureNote(failures)
+	if note == "" {
+		return llmMessages
+	}
+	return append(llmMessages, llm.Message{Role: string(RoleSystem), Content: note})
+}
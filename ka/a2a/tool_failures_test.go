@@ -0,0 +1,51 @@
+package a2a
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"ka/tools"
+)
+
+type failingTool struct{}
+
+func (f *failingTool) GetName() string        { return "read_file" }
+func (f *failingTool) GetDescription() string { return "" }
+func (f *failingTool) GetXMLDefinition() string {
+	return ""
+}
+func (f *failingTool) Execute(ctx context.Context, callDetails tools.FunctionCall) (string, error) {
+	return "", errors.New("failed to open file: no such file or directory")
+}
+
+func TestDispatchToolCall_RecordsRepeatedFailureOnce(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	task, _ := store.CreateTask("t", "", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "Hi"}}}}, "")
+	dispatcher := NewToolDispatcher(store, map[string]tools.Tool{"read_file": &failingTool{}})
+	call := ToolCall{ID: "1", Function: tools.FunctionCall{Name: "read_file", Content: `{"path": "missing.txt"}`}}
+
+	dispatcher.DispatchToolCall(context.Background(), task.ID, call)
+	dispatcher.DispatchToolCall(context.Background(), task.ID, call)
+
+	updated, _ := store.GetTask(task.ID)
+	if len(updated.ToolFailures) != 1 {
+		t.Fatalf("Expected 1 failure entry, got %d", len(updated.ToolFailures))
+	}
+	failure := updated.ToolFailures[0]
+	if failure.Count != 2 || failure.Category != ToolFailureNotFound {
+		t.Errorf("Expected count 2 and category %s, got %+v", ToolFailureNotFound, failure)
+	}
+}
+
+func TestAppendToolFailureNote_AddsSystemMessage(t *testing.T) {
+	failures := []ToolFailure{{ToolName: "read_file", Arguments: `{"path": "x"}`, Category: ToolFailureNotFound, Error: "no such file", Count: 1}}
+	messages := appendToolFailureNote(nil, failures)
+	if len(messages) != 1 || messages[0].Role != string(RoleSystem) {
+		t.Fatalf("Expected one system message, got %+v", messages)
+	}
+	if !strings.Contains(messages[0].Content, "read_file") {
+		t.Errorf("Expected note to mention the tool, got %q", messages[0].Content)
+	}
+}
@@ -0,0 +1,414 @@
+package a2a
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// ToolOutputFilter reduces the output of a tool before it is handed to the
+// LLM. Exactly one of JQ and Template is set.
+type ToolOutputFilter struct {
+	// JQ is a jq-like expression applied to each JSON value of the output
+	// (a whole JSON document or JSON lines). Supported are paths (.a.b, .[],
+	// .[0]), pipes, select(path == literal) with == and !=, and object
+	// construction ({path: .data.path.text}). Strings are written raw, other
+	// results as compact JSON, one per line.
+	JQ string `json:"jq,omitempty"`
+	// Template is a Go text/template executed with the parsed JSON output
+	// (JSON lines become an array) or, if the output is not JSON, the raw text.
+	Template string `json:"template,omitempty"`
+
+	jq   jqFilter
+	tmpl *template.Template
+}
+
+// ToolOutputFilters maps tool names to their output filter.
+type ToolOutputFilters map[string]*ToolOutputFilter
+
+// LoadToolOutputFilters reads the filters from a JSON object, given inline or
+// as a file path, and compiles them.
+func LoadToolOutputFilters(config string) (ToolOutputFilters, error) {
+	data := []byte(config)
+	if !strings.HasPrefix(strings.TrimSpace(config), "{") {
+		fileData, err := os.ReadFile(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tool output filters file %s: %w", config, err)
+		}
+		data = fileData
+	}
+	var filters ToolOutputFilters
+	if err := json.Unmarshal(data, &filters); err != nil {
+		return nil, fmt.Errorf("failed to parse tool output filters: %w", err)
+	}
+	for name, filter := range filters {
+		if err := filter.compile(); err != nil {
+			return nil, fmt.Errorf("tool '%s': %w", name, err)
+		}
+	}
+	return filters, nil
+}
+
+func (f *ToolOutputFilter) compile() error {
+	if f == nil || (f.JQ == "") == (f.Template == "") {
+		return fmt.Errorf("a filter needs either 'jq' or 'template'")
+	}
+	if f.JQ != "" {
+		jq, err := parseJQ(f.JQ)
+		if err != nil {
+			return fmt.Errorf("invalid jq filter: %w", err)
+		}
+		f.jq = jq
+		return nil
+	}
+	tmpl, err := template.New("filter").Funcs(template.FuncMap{
+		"json": func(v interface{}) (string, error) {
+			out, err := json.Marshal(v)
+			return string(out), err
+		},
+	}).Parse(f.Template)
+	if err != nil {
+		return fmt.Errorf("invalid template: %w", err)
+	}
+	f.tmpl = tmpl
+	return nil
+}
+
+// Apply filters a tool's output.
+func (f *ToolOutputFilter) Apply(output string) (string, error) {
+	values, isJSON := parseJSONOutput(output)
+	if f.tmpl != nil {
+		var data interface{} = output
+		if isJSON {
+			data = values
+			if len(values) == 1 {
+				data = values[0]
+			}
+		}
+		var buf bytes.Buffer
+		if err := f.tmpl.Execute(&buf, data); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	}
+	if !isJSON {
+		return "", fmt.Errorf("output is not JSON")
+	}
+	var lines []string
+	for _, value := range values {
+		results, err := f.jq(value)
+		if err != nil {
+			return "", err
+		}
+		for _, result := range results {
+			if s, ok := result.(string); ok {
+				lines = append(lines, s)
+				continue
+			}
+			out, _ := json.Marshal(result)
+			lines = append(lines, string(out))
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// parseJSONOutput parses output as one JSON document or as JSON lines.
+func parseJSONOutput(output string) ([]interface{}, bool) {
+	var value interface{}
+	if err := json.Unmarshal([]byte(output), &value); err == nil {
+		return []interface{}{value}, true
+	}
+	var values []interface{}
+	for _, line := range strings.Split(output, "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if err := json.Unmarshal([]byte(line), &value); err != nil {
+			return nil, false
+		}
+		values = append(values, value)
+	}
+	return values, len(values) > 0
+}
+
+// filterToolOutput applies the filter configured for a tool, if any. On
+// failure the original output is kept, so a filter never hides a result.
+func filterToolOutput(filters ToolOutputFilters, toolName, output string) (string, error) {
+	filter, ok := filters[toolName]
+	if !ok || output == "" {
+		return output, nil
+	}
+	filtered, err := filter.Apply(output)
+	if err != nil {
+		return output, fmt.Errorf("output filter of tool %s failed: %w", toolName, err)
+	}
+	return filtered, nil
+}
+
+// jqFilter maps one input value to zero or more output values.
+type jqFilter func(value interface{}) ([]interface{}, error)
+
+type jqParser struct {
+	src string
+	pos int
+}
+
+func parseJQ(src string) (jqFilter, error) {
+	p := &jqParser{src: src}
+	filter, err := p.pipeline()
+	if err != nil {
+		return nil, err
+	}
+	if p.skipSpace(); p.pos < len(p.src) {
+		return nil, fmt.Errorf("unexpected '%s' at offset %d", p.src[p.pos:], p.pos)
+	}
+	return filter, nil
+}
+
+func (p *jqParser) skipSpace() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *jqParser) consume(token string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.src[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+func (p *jqParser) pipeline() (jqFilter, error) {
+	first, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	stages := []jqFilter{first}
+	for p.consume("|") {
+		stage, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, stage)
+	}
+	return func(value interface{}) ([]interface{}, error) {
+		values := []interface{}{value}
+		for _, stage := range stages {
+			var next []interface{}
+			for _, v := range values {
+				out, err := stage(v)
+				if err != nil {
+					return nil, err
+				}
+				next = append(next, out...)
+			}
+			values = next
+		}
+		return values, nil
+	}, nil
+}
+
+func (p *jqParser) term() (jqFilter, error) {
+	switch {
+	case p.consume("select("):
+		return p.selectTerm()
+	case p.consume("{"):
+		return p.objectTerm()
+	}
+	return p.path()
+}
+
+func (p *jqParser) selectTerm() (jqFilter, error) {
+	left, err := p.path()
+	if err != nil {
+		return nil, err
+	}
+	var equal bool
+	switch {
+	case p.consume("=="):
+		equal = true
+	case p.consume("!="):
+	default:
+		return nil, fmt.Errorf("expected == or != at offset %d", p.pos)
+	}
+	p.skipSpace()
+	decoder := json.NewDecoder(strings.NewReader(p.src[p.pos:]))
+	var literal interface{}
+	if err := decoder.Decode(&literal); err != nil {
+		return nil, fmt.Errorf("invalid literal at offset %d: %w", p.pos, err)
+	}
+	p.pos += int(decoder.InputOffset())
+	if !p.consume(")") {
+		return nil, fmt.Errorf("expected ')' at offset %d", p.pos)
+	}
+	want, _ := json.Marshal(literal)
+	return func(value interface{}) ([]interface{}, error) {
+		results, err := left(value)
+		if err != nil {
+			return nil, err
+		}
+		for _, result := range results {
+			got, _ := json.Marshal(result)
+			if bytes.Equal(got, want) == equal {
+				return []interface{}{value}, nil
+			}
+		}
+		return nil, nil
+	}, nil
+}
+
+func (p *jqParser) objectTerm() (jqFilter, error) {
+	type field struct {
+		key   string
+		value jqFilter
+	}
+	var fields []field
+	for !p.consume("}") {
+		if len(fields) > 0 && !p.consume(",") {
+			return nil, fmt.Errorf("expected ',' or '}' at offset %d", p.pos)
+		}
+		p.skipSpace()
+		start := p.pos
+		key := p.ident()
+		if key == "" || !p.consume(":") {
+			return nil, fmt.Errorf("expected 'key:' at offset %d", start)
+		}
+		value, err := p.path()
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, field{key, value})
+	}
+	return func(value interface{}) ([]interface{}, error) {
+		object := make(map[string]interface{}, len(fields))
+		for _, f := range fields {
+			results, err := f.value(value)
+			if err != nil {
+				return nil, err
+			}
+			if len(results) > 0 {
+				object[f.key] = results[0]
+			} else {
+				object[f.key] = nil
+			}
+		}
+		return []interface{}{object}, nil
+	}, nil
+}
+
+// path parses a path expression such as ., .a.b, .[] or .items[0].name.
+func (p *jqParser) path() (jqFilter, error) {
+	if !p.consume(".") {
+		return nil, fmt.Errorf("expected a path starting with '.' at offset %d", p.pos)
+	}
+	var steps []jqFilter
+	if name := p.ident(); name != "" {
+		steps = append(steps, jqField(name))
+	}
+	for {
+		rest := p.src[p.pos:]
+		switch {
+		case strings.HasPrefix(rest, "[]"):
+			p.pos += 2
+			steps = append(steps, jqIterate)
+		case strings.HasPrefix(rest, "["):
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated '[' at offset %d", p.pos)
+			}
+			index, err := strconv.Atoi(strings.TrimSpace(rest[1:end]))
+			if err != nil {
+				return nil, fmt.Errorf("invalid index at offset %d", p.pos)
+			}
+			p.pos += end + 1
+			steps = append(steps, jqIndex(index))
+		case strings.HasPrefix(rest, "."):
+			p.pos++
+			name := p.ident()
+			if name == "" {
+				return nil, fmt.Errorf("expected a field name at offset %d", p.pos)
+			}
+			steps = append(steps, jqField(name))
+		default:
+			return jqChain(steps), nil
+		}
+	}
+}
+
+func (p *jqParser) ident() string {
+	start := p.pos
+	for p.pos < len(p.src) && (unicode.IsLetter(rune(p.src[p.pos])) || unicode.IsDigit(rune(p.src[p.pos])) || p.src[p.pos] == '_') {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+func jqChain(steps []jqFilter) jqFilter {
+	return func(value interface{}) ([]interface{}, error) {
+		values := []interface{}{value}
+		for _, step := range steps {
+			var next []interface{}
+			for _, v := range values {
+				out, err := step(v)
+				if err != nil {
+					return nil, err
+				}
+				next = append(next, out...)
+			}
+			values = next
+		}
+		return values, nil
+	}
+}
+
+func jqField(name string) jqFilter {
+	return func(value interface{}) ([]interface{}, error) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			return []interface{}{v[name]}, nil
+		case nil:
+			return []interface{}{nil}, nil
+		}
+		return nil, fmt.Errorf("cannot index %T with '%s'", value, name)
+	}
+}
+
+func jqIndex(index int) jqFilter {
+	return func(value interface{}) ([]interface{}, error) {
+		switch v := value.(type) {
+		case []interface{}:
+			i := index
+			if i < 0 {
+				i += len(v)
+			}
+			if i < 0 || i >= len(v) {
+				return []interface{}{nil}, nil
+			}
+			return []interface{}{v[i]}, nil
+		case nil:
+			return []interface{}{nil}, nil
+		}
+		return nil, fmt.Errorf("cannot index %T with a number", value)
+	}
+}
+
+func jqIterate(value interface{}) ([]interface{}, error) {
+	switch v := value.(type) {
+	case []interface{}:
+		return v, nil
+	case map[string]interface{}:
+		values := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			values = append(values, item)
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("cannot iterate over %T", value)
+}
@@ -0,0 +1,95 @@
+package a2a
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"ka/tools"
+)
+
+const rgJSONOutput = `{"type":"begin","data":{"path":{"text":"main.go"}}}
+{"type":"match","data":{"path":{"text":"main.go"},"lines":{"text":"func main() {\n"},"line_number":3}}
+{"type":"match","data":{"path":{"text":"main.go"},"lines":{"text":"\tmain()\n"},"line_number":9}}
+{"type":"end","data":{"path":{"text":"main.go"}}}`
+
+func TestToolOutputFilterJQ(t *testing.T) {
+	filters, err := LoadToolOutputFilters(`{"search": {"jq": "select(.type == \"match\") | {path: .data.path.text, line: .data.line_number}"}}`)
+	if err != nil {
+		t.Fatalf("LoadToolOutputFilters: %v", err)
+	}
+	got, err := filters["search"].Apply(rgJSONOutput)
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	want := `{"line":3,"path":"main.go"}` + "\n" + `{"line":9,"path":"main.go"}`
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	cases := map[string]string{
+		`.items[].name`:                         "a\nb",
+		`.items[-1]`:                            `{"name":"b","size":2}`,
+		`.items[] | select(.size != 1) | .name`: "b",
+		`.count`:                                "2",
+	}
+	for expr, want := range cases {
+		filter := &ToolOutputFilter{JQ: expr}
+		if err := filter.compile(); err != nil {
+			t.Fatalf("%s: %v", expr, err)
+		}
+		got, err := filter.Apply(`{"count": 2, "items": [{"name": "a", "size": 1}, {"name": "b", "size": 2}]}`)
+		if err != nil || got != want {
+			t.Errorf("%s: got %q (%v), want %q", expr, got, err, want)
+		}
+	}
+}
+
+func TestToolOutputFilterTemplate(t *testing.T) {
+	filter := &ToolOutputFilter{Template: `{{range .}}{{if eq .type "match"}}{{.data.path.text}}:{{.data.line_number}}
+{{end}}{{end}}`}
+	if err := filter.compile(); err != nil {
+		t.Fatal(err)
+	}
+	got, err := filter.Apply(rgJSONOutput)
+	if err != nil || got != "main.go:3\nmain.go:9\n" {
+		t.Errorf("got %q (%v)", got, err)
+	}
+}
+
+func TestLoadToolOutputFiltersRejectsInvalidFilters(t *testing.T) {
+	for _, config := range []string{
+		`{"search": {}}`,
+		`{"search": {"jq": ".a", "template": "x"}}`,
+		`{"search": {"jq": "a.b"}}`,
+		`{"search": {"jq": "select(.a ~ 1)"}}`,
+		`{"search": {"template": "{{.a"}}`,
+	} {
+		if _, err := LoadToolOutputFilters(config); err == nil {
+			t.Errorf("expected %s to be rejected", config)
+		}
+	}
+}
+
+func TestDispatcherFiltersToolOutput(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	task, _ := store.CreateTask("t", "", nil, "")
+	dispatcher := NewToolDispatcher(store, map[string]tools.Tool{"fetch_url": &echoTool{output: `{"title": "Home", "body": "long page"}`}})
+	dispatcher.outputFilters, _ = LoadToolOutputFilters(`{"fetch_url": {"jq": ".title"}}`)
+	call := ToolCall{ID: "1", Function: tools.FunctionCall{Name: "fetch_url", Content: `{}`}}
+
+	msg, err := dispatcher.DispatchToolCall(context.Background(), task.ID, call)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := msg.Parts[0].(TextPart).Text; !strings.Contains(text, `"result":"Home"`) {
+		t.Errorf("expected the filtered output, got %s", text)
+	}
+
+	// Output the filter cannot handle is passed through unchanged.
+	dispatcher.availableTools["fetch_url"] = &echoTool{output: "plain text"}
+	msg, _ = dispatcher.DispatchToolCall(context.Background(), task.ID, call)
+	if text := msg.Parts[0].(TextPart).Text; !strings.Contains(text, `"result":"plain text"`) {
+		t.Errorf("expected the unfiltered output, got %s", text)
+	}
+}
@@ -0,0 +1,91 @@
+package a2a
+
+import (
+	"context"
+	"fmt"
+)
+
+// taskBoundTools only make sense inside a running task (they rely on the
+// executor loop or the injected __task_id), so they can never be invoked
+// directly through tools/execute.
+var taskBoundTools = map[string]bool{
+	"ask_followup_question": true,
+	"add_task":              true,
+}
+
+// ToolPolicy decides which tools may run, both inside tasks and when invoked
+// directly over the API.
+type ToolPolicy struct {
+	// DeniedTools are never executed.
+	DeniedTools map[string]bool
+	// DirectExecutionAllowed restricts tools/execute to the listed tools.
+	// A nil map allows every tool that is not denied or task-bound.
+	DirectExecutionAllowed map[string]bool
+	// AllowUnauthenticated lets tools/execute run for callers that did not
+	// pass API authentication, i.e. on agents without -api-keys, -jwt-secret
+	// or -signing-keys. Off by default.
+	AllowUnauthenticated bool
+}
+
+// NewToolPolicy builds a policy from lists of tool names. An empty
+// directAllowed list means no extra restriction on direct execution.
+func NewToolPolicy(denied []string, directAllowed []string) *ToolPolicy {
+	policy := &ToolPolicy{DeniedTools: make(map[string]bool)}
+	for _, name := range denied {
+		policy.DeniedTools[name] = true
+	}
+	if len(directAllowed) > 0 {
+		policy.DirectExecutionAllowed = make(map[string]bool)
+		for _, name := range directAllowed {
+			policy.DirectExecutionAllowed[name] = true
+		}
+	}
+	return policy
+}
+
+// CheckTool returns an error if the tool must not be executed at all.
+func (p *ToolPolicy) CheckTool(toolName string) error {
+	if p != nil && p.DeniedTools[toolName] {
+		return fmt.Errorf("tool '%s' is disabled by policy", toolName)
+	}
+	return nil
+}
+
+// CheckDirectExecution returns an error if the tool must not be executed
+// outside of a task.
+func (p *ToolPolicy) CheckDirectExecution(toolName string) error {
+	if err := p.CheckTool(toolName); err != nil {
+		return err
+	}
+	if taskBoundTools[toolName] {
+		return fmt.Errorf("tool '%s' can only be used inside a task", toolName)
+	}
+	if p != nil && p.DirectExecutionAllowed != nil && !p.DirectExecutionAllowed[toolName] {
+		return fmt.Errorf("tool '%s' is not allowed for direct execution", toolName)
+	}
+	return nil
+}
+
+// CheckDirectCaller returns an error if the caller of tools/execute may not
+// run tools directly: only authenticated callers may, unless the policy
+// allows unauthenticated ones.
+func (p *ToolPolicy) CheckDirectCaller(ctx context.Context) error {
+	if authenticatedCaller(ctx) || (p != nil && p.AllowUnauthenticated) {
+		return nil
+	}
+	return fmt.Errorf("tools/execute requires an authenticated caller; configure API authentication or allow unauthenticated direct execution")
+}
+
+type authenticatedCallerKey struct{}
+
+// WithAuthenticatedCaller returns a context marking a request that passed the
+// agent's API authentication.
+func WithAuthenticatedCaller(ctx context.Context) context.Context {
+	return context.WithValue(ctx, authenticatedCallerKey{}, true)
+}
+
+// authenticatedCaller reports whether the request passed API authentication.
+func authenticatedCaller(ctx context.Context) bool {
+	authenticated, _ := ctx.Value(authenticatedCallerKey{}).(bool)
+	return authenticated
+}
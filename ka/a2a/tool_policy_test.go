@@ -0,0 +1,47 @@
+package a2a
+
+import (
+	"context"
+	"testing"
+)
+
+func TestToolPolicy_DeniedToolIsRejected(t *testing.T) {
+	policy := NewToolPolicy([]string{"execute_command"}, nil)
+	if err := policy.CheckTool("execute_command"); err == nil {
+		t.Errorf("Expected denied tool to be rejected")
+	}
+	if err := policy.CheckTool("read_file"); err != nil {
+		t.Errorf("Expected read_file to be allowed, got %v", err)
+	}
+}
+
+func TestToolPolicy_TaskBoundToolNotDirectlyExecutable(t *testing.T) {
+	var policy *ToolPolicy
+	if err := policy.CheckDirectExecution("add_task"); err == nil {
+		t.Errorf("Expected add_task to be rejected for direct execution")
+	}
+}
+
+func TestToolPolicy_DirectExecutionAllowList(t *testing.T) {
+	policy := NewToolPolicy(nil, []string{"read_file"})
+	if err := policy.CheckDirectExecution("read_file"); err != nil {
+		t.Errorf("Expected read_file to be allowed, got %v", err)
+	}
+	if err := policy.CheckDirectExecution("list_files"); err == nil {
+		t.Errorf("Expected list_files to be rejected by allow list")
+	}
+}
+
+func TestToolPolicy_DirectExecutionNeedsAuthenticatedCaller(t *testing.T) {
+	policy := NewToolPolicy(nil, nil)
+	if err := policy.CheckDirectCaller(context.Background()); err == nil {
+		t.Errorf("Expected an unauthenticated caller to be rejected")
+	}
+	if err := policy.CheckDirectCaller(WithAuthenticatedCaller(context.Background())); err != nil {
+		t.Errorf("Expected an authenticated caller to be allowed, got %v", err)
+	}
+	policy.AllowUnauthenticated = true
+	if err := policy.CheckDirectCaller(context.Background()); err != nil {
+		t.Errorf("Expected the opt-in to allow unauthenticated callers, got %v", err)
+	}
+}
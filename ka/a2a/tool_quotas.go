@@ -0,0 +1,115 @@
+package a2a
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ToolQuota limits how much a task may use one tool. Zero fields are unlimited.
+type ToolQuota struct {
+	MaxCalls int   `json:"maxCalls,omitempty"`
+	MaxBytes int64 `json:"maxBytes,omitempty"` // Total size of the tool's results
+}
+
+// ToolUsage is what a task has used of a tool so far.
+type ToolUsage struct {
+	Calls int   `json:"calls"`
+	Bytes int64 `json:"bytes"`
+}
+
+// ToolQuotas maps tool names to their quota.
+type ToolQuotas map[string]ToolQuota
+
+// ParseToolQuotas parses entries of the form "tool:calls=N" or
+// "tool:bytes=N". Several entries for one tool are combined.
+func ParseToolQuotas(entries []string) (ToolQuotas, error) {
+	quotas := ToolQuotas{}
+	for _, entry := range entries {
+		name, limit, ok := strings.Cut(entry, ":")
+		kind, value, ok2 := strings.Cut(limit, "=")
+		n, err := strconv.ParseInt(value, 10, 64)
+		if !ok || !ok2 || name == "" || err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid tool quota '%s' (expected tool:calls=N or tool:bytes=N)", entry)
+		}
+		quota := quotas[name]
+		switch kind {
+		case "calls":
+			quota.MaxCalls = int(n)
+		case "bytes":
+			quota.MaxBytes = n
+		default:
+			return nil, fmt.Errorf("invalid tool quota '%s': unknown limit '%s'", entry, kind)
+		}
+		quotas[name] = quota
+	}
+	return quotas, nil
+}
+
+// quotaFor returns the quota of a tool for a task, where the task's own
+// quota takes precedence over the global one.
+func quotaFor(global ToolQuotas, task *Task, toolName string) (ToolQuota, bool) {
+	if quota, ok := task.ToolQuotas[toolName]; ok {
+		return quota, true
+	}
+	quota, ok := global[toolName]
+	return quota, ok
+}
+
+// errBudgetExceeded is returned for tool calls beyond a quota.
+var errBudgetExceeded = errors.New("budget exceeded")
+
+// reserveToolCall counts a call against the task's quota of the tool. It
+// returns the bytes the call may still return (-1 for no limit), or an error
+// wrapping errBudgetExceeded when the quota is used up.
+func reserveToolCall(store TaskStore, global ToolQuotas, taskID, toolName string) (int64, error) {
+	remaining := int64(-1)
+	var budgetErr error
+	_, err := store.UpdateTask(taskID, func(task *Task) error {
+		quota, limited := quotaFor(global, task, toolName)
+		usage := task.ToolUsage[toolName]
+		if limited {
+			if quota.MaxCalls > 0 && usage.Calls >= quota.MaxCalls {
+				budgetErr = fmt.Errorf("%w for tool '%s': all %d allowed calls are used", errBudgetExceeded, toolName, quota.MaxCalls)
+				return budgetErr
+			}
+			if quota.MaxBytes > 0 {
+				if usage.Bytes >= quota.MaxBytes {
+					budgetErr = fmt.Errorf("%w for tool '%s': all %d allowed bytes are used", errBudgetExceeded, toolName, quota.MaxBytes)
+					return budgetErr
+				}
+				remaining = quota.MaxBytes - usage.Bytes
+			}
+		}
+		usage.Calls++
+		if task.ToolUsage == nil {
+			task.ToolUsage = make(map[string]ToolUsage)
+		}
+		task.ToolUsage[toolName] = usage
+		return nil
+	})
+	if budgetErr != nil {
+		return 0, budgetErr
+	}
+	if errors.Is(err, ErrTaskNotFound) {
+		return -1, nil // Direct executions outside a task are not metered
+	}
+	return remaining, err
+}
+
+// recordToolBytes adds the size of a tool result to the task's usage.
+func recordToolBytes(store TaskStore, taskID, toolName string, n int) {
+	store.UpdateTask(taskID, func(task *Task) error {
+		if usage, ok := task.ToolUsage[toolName]; ok {
+			usage.Bytes += int64(n)
+			task.ToolUsage[toolName] = usage
+		}
+		return nil
+	})
+}
+
+// budgetExceededMessage is the tool result telling the model to stop using a tool.
+func budgetExceededMessage(err error) string {
+	return fmt.Sprintf("Error: %v. Do not call this tool again in this task; conclude with the information already available.", err)
+}
@@ -0,0 +1,73 @@
+package a2a
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"ka/tools"
+)
+
+// echoTool returns a fixed output.
+type echoTool struct{ output string }
+
+func (e *echoTool) GetName() string          { return "fetch_url" }
+func (e *echoTool) GetDescription() string   { return "" }
+func (e *echoTool) GetXMLDefinition() string { return "" }
+func (e *echoTool) Execute(ctx context.Context, callDetails tools.FunctionCall) (string, error) {
+	return e.output, nil
+}
+
+func TestParseToolQuotas(t *testing.T) {
+	quotas, err := ParseToolQuotas([]string{"fetch_url:calls=20", "read_file:bytes=1000", "read_file:calls=5"})
+	if err != nil {
+		t.Fatalf("ParseToolQuotas: %v", err)
+	}
+	if quotas["fetch_url"].MaxCalls != 20 || quotas["read_file"] != (ToolQuota{MaxCalls: 5, MaxBytes: 1000}) {
+		t.Errorf("unexpected quotas %+v", quotas)
+	}
+	for _, bad := range []string{"fetch_url", "fetch_url:calls=x", "fetch_url:pages=2", ":calls=1"} {
+		if _, err := ParseToolQuotas([]string{bad}); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+}
+
+func TestDispatcherEnforcesToolQuotas(t *testing.T) {
+	store := NewInMemoryTaskStore()
+	task, _ := store.CreateTask("t", "", []Message{{Role: RoleUser, Parts: []Part{TextPart{Type: "text", Text: "Hi"}}}}, "")
+	dispatcher := NewToolDispatcher(store, map[string]tools.Tool{"fetch_url": &echoTool{output: "0123456789"}})
+	dispatcher.quotas = ToolQuotas{"fetch_url": {MaxCalls: 2}}
+	call := ToolCall{ID: "1", Function: tools.FunctionCall{Name: "fetch_url", Content: `{}`}}
+
+	for i := 0; i < 2; i++ {
+		if _, err := dispatcher.DispatchToolCall(context.Background(), task.ID, call); err != nil {
+			t.Fatalf("call %d: %v", i+1, err)
+		}
+	}
+	msg, err := dispatcher.DispatchToolCall(context.Background(), task.ID, call)
+	if !errors.Is(err, errBudgetExceeded) {
+		t.Fatalf("expected the third call to exceed the budget, got %v", err)
+	}
+	if text := msg.Parts[0].(TextPart).Text; !strings.Contains(text, "conclude") {
+		t.Errorf("expected the tool result to tell the model to conclude, got %q", text)
+	}
+
+	// The task's own quota overrides the global one.
+	store.UpdateTask(task.ID, func(t *Task) error {
+		t.ToolQuotas = ToolQuotas{"fetch_url": {MaxBytes: 4}}
+		return nil
+	})
+	msg, err = dispatcher.DispatchToolCall(context.Background(), task.ID, call)
+	if err != nil || !strings.Contains(msg.Parts[0].(TextPart).Text, "0123") || strings.Contains(msg.Parts[0].(TextPart).Text, "456") {
+		t.Errorf("expected the output to be cut to the byte budget, got %v / %+v", err, msg.Parts)
+	}
+	if _, err := dispatcher.DispatchToolCall(context.Background(), task.ID, call); !errors.Is(err, errBudgetExceeded) {
+		t.Errorf("expected the byte budget to be used up, got %v", err)
+	}
+	updated, _ := store.GetTask(task.ID)
+	if usage := updated.ToolUsage["fetch_url"]; usage.Calls != 3 {
+		t.Errorf("expected 3 metered calls, got %+v", usage)
+	}
+}
@@ -0,0 +1,202 @@
+// Package a2aclient sends tasks to other A2A agents and follows them: it
+// creates tasks with tasks/send or tasks/sendSubscribe, answers their
+// questions with tasks/input and tracks them with tasks/status.
+package a2aclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"ka/a2a"
+)
+
+// DefaultPollInterval is the interval at which Wait checks the status of a
+// task.
+const DefaultPollInterval = 2 * time.Second
+
+// Client calls the JSON-RPC endpoint of one agent.
+type Client struct {
+	URL          string        // JSON-RPC endpoint of the agent
+	APIKey       string        // Sent as X-API-Key, if set
+	HTTPClient   *http.Client  // http.DefaultClient when nil; set no Timeout on it, streams last as long as the task
+	PollInterval time.Duration // DefaultPollInterval when 0
+
+	requestID atomic.Int64
+}
+
+// RequestError is a JSON-RPC error the agent answered a request with.
+type RequestError struct {
+	Method string
+	a2a.JSONRPCError
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("%s failed: %s (code %d)", e.Method, e.Message, e.Code)
+}
+
+// SendResult is the answer to tasks/send.
+type SendResult struct {
+	ID     string `json:"id"`
+	Status struct {
+		State     a2a.TaskState `json:"state"`
+		Timestamp string        `json:"timestamp"`
+	} `json:"status"`
+}
+
+// Send creates a task with tasks/send. The agent runs it in the background;
+// follow it with Status or Wait.
+func (c *Client) Send(ctx context.Context, params a2a.SendTaskParams) (*SendResult, error) {
+	var result SendResult
+	if err := c.call(ctx, "tasks/send", params, &result); err != nil {
+		return nil, err
+	}
+	if result.ID == "" {
+		return nil, fmt.Errorf("tasks/send: agent %s returned no task id", c.URL)
+	}
+	return &result, nil
+}
+
+// Subscribe creates a task with tasks/sendSubscribe and returns its event
+// stream, which is resumed when the connection breaks.
+func (c *Client) Subscribe(ctx context.Context, params a2a.SendTaskParams) (*a2a.EventStream, error) {
+	return c.streamClient().SendSubscribe(ctx, params)
+}
+
+// Resubscribe reattaches to the event stream of a task, see
+// a2a.StreamClient.Resubscribe.
+func (c *Client) Resubscribe(ctx context.Context, taskID, lastEventID string) (*a2a.EventStream, error) {
+	return c.streamClient().Resubscribe(ctx, taskID, lastEventID)
+}
+
+// Status returns a task with tasks/status.
+func (c *Client) Status(ctx context.Context, taskID string) (*a2a.Task, error) {
+	var task a2a.Task
+	if err := c.call(ctx, "tasks/status", a2a.TaskStatusParams{ID: taskID}, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// Input answers a task waiting for input with tasks/input and returns the
+// resumed task.
+func (c *Client) Input(ctx context.Context, taskID string, message a2a.Message) (*a2a.Task, error) {
+	var task a2a.Task
+	if err := c.call(ctx, "tasks/input", a2a.ProvideInputParams{TaskID: taskID, Input: message}, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// Wait polls the status of a task until it no longer runs: it completed,
+// failed, was canceled or interrupted, or waits for input. A task waiting for
+// an external event still counts as running.
+func (c *Client) Wait(ctx context.Context, taskID string) (*a2a.Task, error) {
+	interval := c.PollInterval
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	for {
+		task, err := c.Status(ctx, taskID)
+		if err != nil {
+			return nil, err
+		}
+		if Settled(task.State) {
+			return task, nil
+		}
+		select {
+		case <-ctx.Done():
+			return task, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Settled reports whether a task in state needs nothing more from the agent
+// to go on: it ended, or it waits for the caller.
+func Settled(state a2a.TaskState) bool {
+	switch state {
+	case a2a.TaskStateCompleted, a2a.TaskStateFailed, a2a.TaskStateCanceled, a2a.TaskStateInputRequired, a2a.TaskStateInterrupted:
+		return true
+	}
+	return false
+}
+
+// Answer returns the text of the last agent message of a task.
+func Answer(task *a2a.Task) string {
+	for i := len(task.Messages) - 1; i >= 0; i-- {
+		if task.Messages[i].Role != a2a.RoleAssistant {
+			continue
+		}
+		var texts []string
+		for _, part := range task.Messages[i].Parts {
+			if text, ok := part.(a2a.TextPart); ok {
+				texts = append(texts, text.Text)
+			}
+		}
+		return strings.Join(texts, "\n")
+	}
+	return ""
+}
+
+func (c *Client) streamClient() *a2a.StreamClient {
+	return &a2a.StreamClient{URL: c.URL, APIKey: c.APIKey, HTTPClient: c.HTTPClient}
+}
+
+// call sends a JSON-RPC request and decodes its result into result.
+func (c *Client) call(ctx context.Context, method string, params, result interface{}) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      c.requestID.Add(1),
+		"method":  method,
+		"params":  params,
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.APIKey != "" {
+		req.Header.Set("X-API-Key", c.APIKey)
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("%s: %w", method, err)
+	}
+
+	var rpcResp struct {
+		Result json.RawMessage   `json:"result"`
+		Error  *a2a.JSONRPCError `json:"error"`
+	}
+	if err := json.Unmarshal(data, &rpcResp); err != nil {
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("%s: agent returned %s", method, resp.Status)
+		}
+		return fmt.Errorf("%s: invalid response: %w", method, err)
+	}
+	if rpcResp.Error != nil {
+		return &RequestError{Method: method, JSONRPCError: *rpcResp.Error}
+	}
+	if err := json.Unmarshal(rpcResp.Result, result); err != nil {
+		return fmt.Errorf("%s: invalid result: %w", method, err)
+	}
+	return nil
+}
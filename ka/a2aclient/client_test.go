@@ -0,0 +1,138 @@
+package a2aclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ka/a2a"
+	"ka/llm"
+	"ka/tools"
+)
+
+// replyClient answers every request with reply.
+type replyClient struct{ reply string }
+
+func (c *replyClient) Chat(ctx context.Context, messages []llm.Message, stream bool, out io.Writer) (string, int, int, error) {
+	io.WriteString(out, c.reply)
+	return c.reply, 0, 0, nil
+}
+
+// newRemoteAgent serves the JSON-RPC methods of an agent answering reply.
+func newRemoteAgent(t *testing.T, reply string) (*httptest.Server, a2a.TaskStore) {
+	t.Helper()
+	store := a2a.NewInMemoryTaskStore()
+	te := a2a.NewTaskExecutor(&replyClient{reply: reply}, store, nil, "")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var req a2a.JSONRPCRequest
+		json.Unmarshal(body, &req)
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		switch req.Method {
+		case "tasks/send":
+			a2a.TasksSendHandler(te)(w, r)
+		case "tasks/sendSubscribe":
+			a2a.TasksSendSubscribeHandler(te)(w, r)
+		case "tasks/status":
+			a2a.TasksStatusHandler(store)(w, r)
+		case "tasks/input":
+			a2a.TasksInputHandler(te)(w, r)
+		default:
+			t.Errorf("unexpected method %s", req.Method)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, store
+}
+
+func TestDelegateTaskStreamsRemoteResult(t *testing.T) {
+	server, _ := newRemoteAgent(t, "The build is green.")
+	tool := NewDelegateTaskTool([]Agent{{Name: "ci", URL: server.URL, Description: "runs builds"}}, nil)
+
+	var mu sync.Mutex
+	var links []string
+	var output strings.Builder
+	ctx := tools.WithRemoteTaskLinker(context.Background(), func(agentURL, remoteTaskID string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		links = append(links, agentURL+" "+remoteTaskID)
+		return nil
+	})
+	ctx = tools.WithOutputReporter(ctx, func(out tools.ToolOutput) {
+		mu.Lock()
+		defer mu.Unlock()
+		output.WriteString(out.Data)
+	})
+
+	result, err := tool.Execute(ctx, tools.FunctionCall{Name: "delegate_task", Content: `{"agent": "ci", "message": "Is the build green?"}`})
+	if err != nil || !strings.Contains(result, "completed task") || !strings.HasSuffix(result, "The build is green.") {
+		t.Fatalf("expected the remote answer, got %q, %v", result, err)
+	}
+	if len(links) != 1 || !strings.HasPrefix(links[0], server.URL+" ") {
+		t.Errorf("expected the remote task to be linked once, got %v", links)
+	}
+	if output.String() != "The build is green." {
+		t.Errorf("expected the remote output as live output, got %q", output.String())
+	}
+
+	if _, err := tool.Execute(ctx, tools.FunctionCall{Content: `{"agent": "deploy", "message": "ship it"}`}); err == nil {
+		t.Errorf("expected an unknown agent to fail")
+	}
+}
+
+func TestClientAnswersTaskWaitingForInput(t *testing.T) {
+	server, store := newRemoteAgent(t, "Deployed to staging.")
+	client := &Client{URL: server.URL, PollInterval: 10 * time.Millisecond}
+	ctx := context.Background()
+
+	sent, err := client.Send(ctx, a2a.SendTaskParams{Message: a2a.Message{Role: a2a.RoleUser, Parts: []a2a.Part{a2a.TextPart{Type: "text", Text: "deploy"}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	task, err := client.Wait(ctx, sent.ID)
+	if err != nil || task.State != a2a.TaskStateCompleted || Answer(task) != "Deployed to staging." {
+		t.Fatalf("expected the task to complete, got %+v, %v", task, err)
+	}
+
+	// The agent asks which environment to use. The task is parked in the
+	// store only, so no run of the agent touches it concurrently.
+	parked, err := store.CreateTask("deploy", "", []a2a.Message{{Role: a2a.RoleUser, Parts: []a2a.Part{a2a.TextPart{Type: "text", Text: "deploy"}}}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.SetState(parked.ID, a2a.TaskStateInputRequired)
+	tool := NewDelegateTaskTool([]Agent{{Name: "deployer", URL: server.URL}}, nil)
+	tool.PollInterval = 10 * time.Millisecond
+	result, err := tool.Execute(ctx, tools.FunctionCall{Content: `{"agent": "deployer", "taskId": "` + parked.ID + `"}`})
+	if err != nil || !strings.Contains(result, "needs input") {
+		t.Fatalf("expected the question of the remote task, got %q, %v", result, err)
+	}
+	result, err = tool.Execute(ctx, tools.FunctionCall{Content: `{"agent": "deployer", "taskId": "` + parked.ID + `", "message": "staging"}`})
+	if err != nil || !strings.Contains(result, "completed task "+parked.ID) {
+		t.Fatalf("expected the answered task to complete, got %q, %v", result, err)
+	}
+
+	var rejected *RequestError
+	if _, err := client.Status(ctx, "missing"); !errors.As(err, &rejected) || rejected.Code != -32001 {
+		t.Errorf("expected the JSON-RPC error of the agent, got %v", err)
+	}
+}
+
+func TestLoadAgents(t *testing.T) {
+	t.Setenv("CI_AGENT_KEY", "secret")
+	agents, err := LoadAgents(`[{"name": "ci", "url": "http://ci:8080", "apiKey": "$CI_AGENT_KEY"}]`)
+	if err != nil || len(agents) != 1 || agents[0].APIKey != "secret" {
+		t.Errorf("expected the agent with its key from the environment, got %+v, %v", agents, err)
+	}
+	if _, err := LoadAgents(`[{"name": "ci", "url": "ci:8080"}]`); err == nil {
+		t.Errorf("expected an agent without http(s) URL to fail")
+	}
+}
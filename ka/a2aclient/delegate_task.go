@@ -0,0 +1,205 @@
+package a2aclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"ka/a2a"
+	"ka/tools"
+)
+
+// defaultDelegateTimeout bounds how long delegate_task waits for a result.
+const defaultDelegateTimeout = 10 * time.Minute
+
+// Agent is an agent the delegate_task tool may send tasks to.
+type Agent struct {
+	Name           string `json:"name"`
+	URL            string `json:"url"`                   // JSON-RPC endpoint of the agent
+	APIKey         string `json:"apiKey,omitempty"`      // $VAR and ${VAR} are read from the environment
+	Description    string `json:"description,omitempty"` // What the agent is for, shown to the model
+	TimeoutSeconds int    `json:"timeoutSeconds,omitempty"`
+}
+
+// LoadAgents reads the agents delegate_task may use from a JSON array, given
+// inline or as a file path, and validates them.
+func LoadAgents(config string) ([]Agent, error) {
+	data := []byte(config)
+	if !strings.HasPrefix(strings.TrimSpace(config), "[") {
+		fileData, err := os.ReadFile(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read agents file %s: %w", config, err)
+		}
+		data = fileData
+	}
+	var agents []Agent
+	if err := json.Unmarshal(data, &agents); err != nil {
+		return nil, fmt.Errorf("failed to parse agents: %w", err)
+	}
+	seen := make(map[string]bool)
+	for i := range agents {
+		a := &agents[i]
+		if a.Name == "" {
+			return nil, fmt.Errorf("agent %d has no name", i)
+		}
+		if seen[a.Name] {
+			return nil, fmt.Errorf("duplicate agent '%s'", a.Name)
+		}
+		seen[a.Name] = true
+		if !strings.HasPrefix(a.URL, "http://") && !strings.HasPrefix(a.URL, "https://") {
+			return nil, fmt.Errorf("agent '%s': url must be an http(s) URL, got '%s'", a.Name, a.URL)
+		}
+		a.APIKey = os.ExpandEnv(a.APIKey)
+	}
+	return agents, nil
+}
+
+// DelegateTaskArgs are the arguments of the delegate_task tool.
+type DelegateTaskArgs struct {
+	Agent   string `json:"agent"`
+	Message string `json:"message,omitempty"` // The task, or the answer to a remote task waiting for input
+	TaskID  string `json:"taskId,omitempty"`  // Remote task to answer or check on
+	Wait    *bool  `json:"wait,omitempty"`    // Wait for the result; defaults to true
+}
+
+// DelegateTaskTool sends tasks to other agents and returns their results.
+// The remote tasks are linked to the delegating task, so tasks/thread shows
+// them.
+type DelegateTaskTool struct {
+	HTTPClient   *http.Client  // Carries the requests, e.g. signed and through the egress policy
+	PollInterval time.Duration // DefaultPollInterval when 0
+	agents       map[string]Agent
+}
+
+// NewDelegateTaskTool creates the tool for the given agents.
+func NewDelegateTaskTool(agents []Agent, httpClient *http.Client) *DelegateTaskTool {
+	t := &DelegateTaskTool{HTTPClient: httpClient, agents: make(map[string]Agent)}
+	for _, a := range agents {
+		t.agents[a.Name] = a
+	}
+	return t
+}
+
+func (t *DelegateTaskTool) GetName() string {
+	return "delegate_task"
+}
+
+func (t *DelegateTaskTool) GetDescription() string {
+	names := make([]string, 0, len(t.agents))
+	for name, a := range t.agents {
+		if a.Description != "" {
+			name += " (" + a.Description + ")"
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return "Sends a task to another agent and returns its result. If the agent asks a question, answer it with the same agent, the taskId and your answer as message. With wait false the tool returns the taskId at once; call it later with the taskId and no message to check on the task. Available agents: " + strings.Join(names, "; ") + "."
+}
+
+func (t *DelegateTaskTool) GetXMLDefinition() string {
+	return `<tool id="delegate_task">{"agent": "agent name", "message": "The task for the agent, with all context it needs", "taskId": "remote task to answer or check on (optional)", "wait": true (optional)}</tool>`
+}
+
+func (t *DelegateTaskTool) Execute(ctx context.Context, callDetails tools.FunctionCall) (string, error) {
+	var args DelegateTaskArgs
+	if err := json.Unmarshal([]byte(callDetails.Content), &args); err != nil {
+		return "", fmt.Errorf("failed to parse arguments JSON for delegate_task: %w. Content: %s", err, callDetails.Content)
+	}
+	agent, ok := t.agents[args.Agent]
+	if !ok {
+		return "", fmt.Errorf("unknown agent '%s'", args.Agent)
+	}
+	if args.TaskID == "" && strings.TrimSpace(args.Message) == "" {
+		return "", fmt.Errorf("delegate_task needs a message for a new task, or the taskId of a remote task")
+	}
+	wait := args.Wait == nil || *args.Wait
+	client := &Client{URL: agent.URL, APIKey: agent.APIKey, HTTPClient: t.HTTPClient, PollInterval: t.PollInterval}
+
+	timeout := defaultDelegateTimeout
+	if agent.TimeoutSeconds > 0 {
+		timeout = time.Duration(agent.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	message := a2a.Message{Role: a2a.RoleUser, Parts: []a2a.Part{a2a.TextPart{Type: "text", Text: args.Message}}, Timestamp: time.Now().UTC()}
+	var task *a2a.Task
+	var err error
+	switch {
+	case args.TaskID == "" && !wait:
+		sent, sendErr := client.Send(ctx, a2a.SendTaskParams{Message: message})
+		if sendErr != nil {
+			return "", fmt.Errorf("agent '%s': %w", agent.Name, sendErr)
+		}
+		t.link(ctx, agent, sent.ID)
+		return fmt.Sprintf("Delegated to agent %s as task %s (%s). Check on it with delegate_task and this taskId.", agent.Name, sent.ID, sent.Status.State), nil
+	case args.TaskID == "":
+		task, err = t.stream(ctx, client, agent, message, callDetails.Attributes["__tool_call_id"])
+	case strings.TrimSpace(args.Message) != "":
+		task, err = client.Input(ctx, args.TaskID, message)
+		if err == nil && wait {
+			task, err = client.Wait(ctx, args.TaskID)
+		}
+	case wait:
+		task, err = client.Wait(ctx, args.TaskID)
+	default:
+		task, err = client.Status(ctx, args.TaskID)
+	}
+	if err != nil && (task == nil || ctx.Err() == nil) {
+		return "", fmt.Errorf("agent '%s': %w", agent.Name, err)
+	}
+	return report(agent, task)
+}
+
+// stream creates the remote task with tasks/sendSubscribe, forwarding its
+// output as the tool's live output, and returns the task once it settled.
+func (t *DelegateTaskTool) stream(ctx context.Context, client *Client, agent Agent, message a2a.Message, toolCallID string) (*a2a.Task, error) {
+	stream, err := client.Subscribe(ctx, a2a.SendTaskParams{Message: message})
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+	t.link(ctx, agent, stream.TaskID)
+	for {
+		event, err := stream.Next()
+		if err != nil {
+			break // Ended with the final state, or broke: the status tells which
+		}
+		if delta, ok := event.(*a2a.DeltaEvent); ok {
+			tools.ReportOutput(ctx, tools.ToolOutput{Tool: t.GetName(), ToolCallID: toolCallID, Stream: "stdout", Data: delta.Chunk})
+		}
+	}
+	return client.Wait(ctx, stream.TaskID)
+}
+
+// link links a remote task to the delegating task.
+func (t *DelegateTaskTool) link(ctx context.Context, agent Agent, remoteTaskID string) {
+	if err := tools.LinkRemoteTask(ctx, agent.URL, remoteTaskID); err != nil {
+		// The result still names the task, only tasks/thread misses it
+		log.Printf("[delegate_task] Failed to link remote task %s of agent %s: %v", remoteTaskID, agent.Name, err)
+	}
+}
+
+// report renders the state of a remote task as the tool result.
+func report(agent Agent, task *a2a.Task) (string, error) {
+	switch task.State {
+	case a2a.TaskStateCompleted:
+		return fmt.Sprintf("Agent %s completed task %s:\n%s", agent.Name, task.ID, Answer(task)), nil
+	case a2a.TaskStateInputRequired:
+		return fmt.Sprintf("Agent %s needs input for task %s:\n%s\nAnswer with delegate_task, taskId %q and your answer as message.", agent.Name, task.ID, Answer(task), task.ID), nil
+	case a2a.TaskStateFailed, a2a.TaskStateCanceled, a2a.TaskStateInterrupted:
+		reason := task.Error
+		if reason == "" {
+			reason = "no error reported"
+		}
+		return "", fmt.Errorf("task %s of agent %s ended %s: %s", task.ID, agent.Name, task.State, reason)
+	default:
+		return fmt.Sprintf("Task %s of agent %s is %s. Check on it later with delegate_task and this taskId.", task.ID, agent.Name, task.State), nil
+	}
+}
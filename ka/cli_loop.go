@@ -0,0 +1,208 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"ka/a2a"
+	"ka/llm"
+	"ka/tools"
+	"os"
+	"strings"
+)
+
+// cliResult is the outcome of one CLI prompt, printed with -output=json.
+type cliResult struct {
+	Answer           string        `json:"answer"`
+	Iterations       int           `json:"iterations"`
+	ToolCalls        []cliToolCall `json:"toolCalls,omitempty"`
+	InputTokens      int           `json:"inputTokens"`
+	CompletionTokens int           `json:"completionTokens"`
+	Error            string        `json:"error,omitempty"`
+}
+
+// cliToolCall records one tool call made while answering a CLI prompt.
+type cliToolCall struct {
+	Name  string `json:"name"`
+	Error string `json:"error,omitempty"`
+}
+
+// cliToolLoop runs the executor loop in the terminal: it sends the
+// conversation to the LLM, executes the tool calls of the response locally
+// and feeds their results back until the LLM answers without tool calls.
+type cliToolLoop struct {
+	client        llm.LLMClient
+	systemMessage string
+	history       []llm.Message // Earlier turns of the session, without the system message
+	tools         map[string]tools.Tool
+	policy        *a2a.ToolPolicy
+	stream        bool
+	maxIterations int
+	autoApprove   bool // Run tools with side effects without asking
+	input         *bufio.Reader
+	interactive   bool      // Stdin is a terminal, so the user can confirm tools and answer questions
+	out           io.Writer // Receives the LLM output and progress; stderr with -output=json
+}
+
+func newCLIToolLoop(client llm.LLMClient, systemMessage string, availableTools map[string]tools.Tool, flags FlagOptions, stream bool, out io.Writer) *cliToolLoop {
+	return &cliToolLoop{
+		client:        client,
+		systemMessage: systemMessage,
+		tools:         availableTools,
+		policy:        a2a.NewToolPolicy(splitCommaList(flags.toolDenyFlag), nil),
+		stream:        stream,
+		maxIterations: flags.cliMaxIterationsFlag,
+		autoApprove:   flags.yesFlag,
+		input:         bufio.NewReader(os.Stdin),
+		interactive:   isTerminal(os.Stdin),
+		out:           out,
+	}
+}
+
+// Run executes the loop for one prompt, continuing the conversation of the
+// earlier turns. The result carries the final answer, or the error that
+// ended the loop; only completed turns are added to the history.
+func (l *cliToolLoop) Run(ctx context.Context, userPrompt string) cliResult {
+	messages := append([]llm.Message{{Role: "system", Content: l.systemMessage}}, l.history...)
+	messages = append(messages, llm.Message{Role: "user", Content: userPrompt})
+	var result cliResult
+	defer func() {
+		fmt.Fprintf(os.Stderr, "\n[CLI Mode] Input Tokens: %d, Completion Tokens: %d\n", result.InputTokens, result.CompletionTokens)
+	}()
+
+	for l.maxIterations <= 0 || result.Iterations < l.maxIterations {
+		result.Iterations++
+		fmt.Fprintln(l.out, "[main] Sending prompt to LLM...")
+		completion, inputTokens, completionTokens, err := l.client.Chat(ctx, messages, l.stream, l.out)
+		if err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		result.InputTokens += inputTokens
+		result.CompletionTokens += completionTokens
+		if l.stream {
+			fmt.Fprintln(l.out)
+		} else if completion != "" {
+			fmt.Fprintln(l.out, completion)
+		}
+
+		assistant := a2a.Message{Role: a2a.RoleAssistant, RawToolCallsXML: completion}
+		if err := assistant.ParseToolCallsFromXML(); err != nil || len(assistant.ParsedToolCalls) == 0 {
+			result.Answer = completion
+			l.history = append(messages[1:], llm.Message{Role: string(a2a.RoleAssistant), Content: completion})
+			return result
+		}
+		messages = append(messages, llm.Message{Role: string(a2a.RoleAssistant), Content: completion})
+		for _, toolCall := range assistant.ParsedToolCalls {
+			content, err := l.execute(ctx, toolCall)
+			call := cliToolCall{Name: toolCall.Function.Name}
+			if err != nil {
+				call.Error = err.Error()
+			}
+			result.ToolCalls = append(result.ToolCalls, call)
+			messages = append(messages, llm.Message{Role: string(a2a.RoleTool), Content: content})
+		}
+	}
+	result.Error = fmt.Sprintf("stopped after %d iterations without a final answer (raise -cli-max-iterations)", l.maxIterations)
+	return result
+}
+
+// History returns the turns of the session so far.
+func (l *cliToolLoop) History() []llm.Message {
+	return l.history
+}
+
+// execute runs one tool call and returns the tool message content, in the
+// same JSON shape the server's dispatcher feeds back to the LLM, and the tool error.
+func (l *cliToolLoop) execute(ctx context.Context, toolCall a2a.ToolCall) (string, error) {
+	name := toolCall.Function.Name
+	result, err := l.run(ctx, toolCall)
+	var arguments interface{} = json.RawMessage(toolCall.Function.Content)
+	if !json.Valid([]byte(toolCall.Function.Content)) {
+		arguments = toolCall.Function.Content
+	}
+	resultData := map[string]interface{}{
+		"tool_name": name,
+		"arguments": arguments,
+		"result":    result,
+		"error":     nil,
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[CLI Mode] Tool %s failed: %v\n", name, err)
+		resultData["error"] = fmt.Sprintf("Error executing tool %s (ID: %s): %v", name, toolCall.ID, err)
+	} else {
+		fmt.Fprintf(os.Stderr, "[CLI Mode] Tool %s done (%d bytes).\n", name, len(result))
+	}
+	data, _ := json.Marshal(resultData)
+	return string(data), err
+}
+
+func (l *cliToolLoop) run(ctx context.Context, toolCall a2a.ToolCall) (string, error) {
+	name := toolCall.Function.Name
+	if name == "ask_followup_question" {
+		return l.askUser(toolCall.Function)
+	}
+	if err := l.policy.CheckDirectExecution(name); err != nil {
+		return "", err
+	}
+	tool, ok := l.tools[name]
+	if !ok {
+		return "", fmt.Errorf("tool '%s' not found", name)
+	}
+	if a2a.HasSideEffects(name) && !l.autoApprove {
+		approved, err := l.confirm(toolCall.Function)
+		if err != nil {
+			return "", err
+		}
+		if !approved {
+			return "", fmt.Errorf("the user declined to run %s", name)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "[CLI Mode] Running tool %s...\n", name)
+	return tool.Execute(ctx, toolCall.Function)
+}
+
+// confirm asks the user whether a tool with side effects may run. "a" approves
+// every further call for the rest of the session.
+func (l *cliToolLoop) confirm(call tools.FunctionCall) (bool, error) {
+	if !l.interactive {
+		return false, fmt.Errorf("tool '%s' needs confirmation, but stdin is not a terminal (pass -yes to run it)", call.Name)
+	}
+	fmt.Fprintf(os.Stderr, "\nThe agent wants to run %s with:\n%s\nAllow? [y]es / [N]o / [a]lways: ", call.Name, call.Content)
+	answer, err := l.input.ReadString('\n')
+	if err != nil && answer == "" {
+		return false, fmt.Errorf("failed to read confirmation: %w", err)
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	case "a", "always":
+		l.autoApprove = true
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+// askUser answers ask_followup_question with a line read from the terminal.
+func (l *cliToolLoop) askUser(call tools.FunctionCall) (string, error) {
+	if !l.interactive {
+		return "", fmt.Errorf("cannot ask the user: stdin is not a terminal")
+	}
+	var args tools.AskFollowupQuestionArgs
+	if err := json.Unmarshal([]byte(call.Content), &args); err != nil || strings.TrimSpace(args.Question) == "" {
+		return "", fmt.Errorf("invalid ask_followup_question arguments: %s", call.Content)
+	}
+	fmt.Fprintf(os.Stderr, "\n%s\n", args.Question)
+	for i, option := range args.Options {
+		fmt.Fprintf(os.Stderr, "  %d. %s\n", i+1, option)
+	}
+	fmt.Fprint(os.Stderr, "> ")
+	answer, err := l.input.ReadString('\n')
+	if err != nil && answer == "" {
+		return "", fmt.Errorf("failed to read the answer: %w", err)
+	}
+	return strings.TrimSpace(answer), nil
+}
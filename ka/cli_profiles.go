@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"ka/llm"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// cliProfile bundles the settings of a CLI setup, selected with -profile.
+// Flags given on the command line take precedence over the profile.
+type cliProfile struct {
+	Provider     string   `json:"provider,omitempty"`
+	Model        string   `json:"model,omitempty"`
+	AgentURL     string   `json:"agentUrl,omitempty"`     // Send prompts to this A2A agent instead of a local LLM
+	APIKey       string   `json:"apiKey,omitempty"`       // X-API-Key for AgentURL
+	SystemPrompt string   `json:"systemPrompt,omitempty"` // Prepended to the composed system prompt
+	Tools        []string `json:"tools,omitempty"`        // Tools offered to the LLM; empty offers all
+}
+
+// cliSession is a conversation stored between CLI runs.
+type cliSession struct {
+	Name      string        `json:"name"`
+	Profile   string        `json:"profile,omitempty"`
+	Messages  []llm.Message `json:"messages"` // Without the system message, which is composed on every run
+	UpdatedAt time.Time     `json:"updatedAt"`
+}
+
+var sessionNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// kaConfigDir returns the directory holding profiles.json and the sessions.
+// KA_CONFIG_DIR overrides the per-user default.
+func kaConfigDir() (string, error) {
+	if dir := os.Getenv("KA_CONFIG_DIR"); dir != "" {
+		return dir, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the user config directory: %w", err)
+	}
+	return filepath.Join(dir, "ka"), nil
+}
+
+// loadProfile reads the named profile from profiles.json, a JSON object
+// mapping profile names to profiles.
+func loadProfile(name string) (cliProfile, error) {
+	dir, err := kaConfigDir()
+	if err != nil {
+		return cliProfile{}, err
+	}
+	path := filepath.Join(dir, "profiles.json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cliProfile{}, fmt.Errorf("failed to read profiles file %s: %w", path, err)
+	}
+	var profiles map[string]cliProfile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return cliProfile{}, fmt.Errorf("failed to parse profiles file %s: %w", path, err)
+	}
+	profile, ok := profiles[name]
+	if !ok {
+		return cliProfile{}, fmt.Errorf("profile '%s' is not defined in %s", name, path)
+	}
+	return profile, nil
+}
+
+// applyProfile copies the profile's settings into flags that were not given
+// on the command line.
+func applyProfile(flags *FlagOptions, profile cliProfile) {
+	explicit := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+	if profile.Provider != "" && !explicit["provider"] {
+		flags.providerFlag = profile.Provider
+	}
+	if profile.Model != "" && !explicit["model"] {
+		flags.modelFlag = profile.Model
+	}
+	if profile.AgentURL != "" && !explicit["agent-url"] {
+		flags.agentURLFlag = profile.AgentURL
+	}
+}
+
+func sessionPath(name string) (string, error) {
+	if !sessionNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid session name '%s' (use letters, digits, '.', '_' and '-')", name)
+	}
+	dir, err := kaConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "sessions", name+".json"), nil
+}
+
+// loadSession reads the named session, or returns an empty one if it does not exist yet.
+func loadSession(name string) (*cliSession, error) {
+	path, err := sessionPath(name)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &cliSession{Name: name}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session %s: %w", path, err)
+	}
+	var session cliSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to parse session %s: %w", path, err)
+	}
+	session.Name = name
+	return &session, nil
+}
+
+// save writes the session atomically, so an interrupted run keeps the previous state.
+func (s *cliSession) save() error {
+	path, err := sessionPath(s.Name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create session directory: %w", err)
+	}
+	s.UpdatedAt = time.Now().UTC()
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write session %s: %w", path, err)
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"ka/a2a"
+	"ka/llm"
+	"net/http"
+	"strings"
+)
+
+// cliRemoteAgent answers CLI prompts with a remote A2A agent, streaming each
+// turn through tasks/sendSubscribe.
+type cliRemoteAgent struct {
+	url     string
+	apiKey  string
+	client  *http.Client
+	history []llm.Message
+	out     io.Writer
+}
+
+// Run sends one prompt as a new task. The agent keeps no conversation across
+// tasks, so earlier turns of the session are included as a transcript.
+func (a *cliRemoteAgent) Run(ctx context.Context, userPrompt string) cliResult {
+	result := cliResult{Iterations: 1}
+	text := userPrompt
+	if len(a.history) > 0 {
+		text = remoteTranscript(a.history) + "\n\n" + userPrompt
+	}
+	client := &a2a.StreamClient{URL: a.url, APIKey: a.apiKey, HTTPClient: a.client}
+	stream, err := client.SendSubscribe(ctx, a2a.SendTaskParams{Message: a2a.Message{Role: a2a.RoleUser, Parts: []a2a.Part{a2a.TextPart{Type: "text", Text: text}}}})
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer stream.Close()
+
+	var answer strings.Builder
+	for {
+		event, err := stream.Next()
+		if err == io.EOF {
+			result.Error = fmt.Sprintf("stream from agent %s ended before the task finished", a.url)
+			return result
+		}
+		if err != nil {
+			result.Error = fmt.Sprintf("stream from agent %s broke: %v", a.url, err)
+			return result
+		}
+		switch event := event.(type) {
+		case *a2a.DeltaEvent:
+			fmt.Fprint(a.out, event.Chunk)
+			answer.WriteString(event.Chunk)
+		case *a2a.StateEvent:
+			switch a2a.TaskState(event.Status) {
+			case a2a.TaskStateWorking:
+				answer.Reset() // Only the text of the last iteration is the answer
+			case a2a.TaskStateCompleted, a2a.TaskStateInputRequired:
+				fmt.Fprintln(a.out)
+				result.Answer = strings.TrimSpace(answer.String())
+				a.history = append(a.history, llm.Message{Role: "user", Content: userPrompt}, llm.Message{Role: "assistant", Content: result.Answer})
+				return result
+			case a2a.TaskStateFailed:
+				result.Error = "task failed: " + event.Error
+				return result
+			}
+		}
+	}
+}
+
+// History returns the turns of the session so far.
+func (a *cliRemoteAgent) History() []llm.Message {
+	return a.history
+}
+
+// remoteTranscript renders earlier turns for the prompt of a remote task.
+func remoteTranscript(history []llm.Message) string {
+	var b strings.Builder
+	b.WriteString("Conversation so far:")
+	for _, msg := range history {
+		if msg.Role != "user" && msg.Role != "assistant" {
+			continue
+		}
+		fmt.Fprintf(&b, "\n\n%s: %s", strings.ToUpper(msg.Role[:1])+msg.Role[1:], msg.Content)
+	}
+	return b.String()
+}
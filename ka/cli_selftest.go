@@ -0,0 +1,426 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"ka/a2a"
+	"ka/llm"
+	"ka/tools"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// selftestFixture is the content of the file the scripted task reads.
+const selftestFixture = "ka selftest fixture 7f3a"
+
+// selftestStreamReply is the answer streamed by the scripted task, in chunks.
+var selftestStreamReply = []string{"Streaming ", "works ", "end ", "to ", "end."}
+
+// selftestLLMClient is the mock provider of ka selftest. It answers from the
+// last message of the conversation, so each step gets a predictable reply.
+type selftestLLMClient struct {
+	fixturePath string
+}
+
+func (c *selftestLLMClient) Chat(ctx context.Context, messages []llm.Message, stream bool, out io.Writer) (string, int, int, error) {
+	last := messages[len(messages)-1]
+	var reply string
+	switch {
+	case last.Role == "tool":
+		reply = "The fixture reads: " + last.Content
+	case strings.Contains(last.Content, "selftest: read the fixture"):
+		reply = fmt.Sprintf(`<tool id="read_file">{"path": %q}</tool>`, c.fixturePath)
+	case strings.Contains(last.Content, "selftest: ask me"):
+		reply = `<tool id="ask_followup_question">{"question": "Which colour?", "options": ["blue", "green"]}</tool>`
+	case strings.Contains(last.Content, "selftest: stream"):
+		for _, chunk := range selftestStreamReply {
+			io.WriteString(out, chunk)
+		}
+		return strings.Join(selftestStreamReply, ""), len(messages), len(selftestStreamReply), nil
+	default:
+		reply = "You answered: " + last.Content
+	}
+	io.WriteString(out, reply)
+	return reply, len(messages), 1, nil
+}
+
+// selftestClient talks to the in-process agent over HTTP, like a real client.
+type selftestClient struct {
+	baseURL string
+	http    *http.Client
+	timeout time.Duration // How long a step waits for a task to reach a state
+	nextID  int
+}
+
+// selftestRPCError is the JSON-RPC error a call was answered with.
+type selftestRPCError struct {
+	*a2a.JSONRPCError
+}
+
+func (e selftestRPCError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// call sends a JSON-RPC request and decodes its result into result.
+func (c *selftestClient) call(method string, params interface{}, result interface{}) error {
+	c.nextID++
+	body, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": c.nextID, "method": method, "params": params})
+	resp, err := c.http.Post(c.baseURL+"/", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var rpcResp struct {
+		Result json.RawMessage   `json:"result"`
+		Error  *a2a.JSONRPCError `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&rpcResp); err != nil {
+		return fmt.Errorf("%s: invalid response (HTTP %d): %w", method, resp.StatusCode, err)
+	}
+	if rpcResp.Error != nil {
+		return selftestRPCError{rpcResp.Error}
+	}
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(rpcResp.Result, result)
+}
+
+// send creates a task with a text message and returns its ID.
+func (c *selftestClient) send(text string) (string, error) {
+	var result struct {
+		ID string `json:"id"`
+	}
+	if err := c.call("tasks/send", map[string]interface{}{"message": selftestMessage(text)}, &result); err != nil {
+		return "", err
+	}
+	return result.ID, nil
+}
+
+// task reads a task, with its messages and artifacts, through tasks/export.
+func (c *selftestClient) task(id string) (*a2a.Task, error) {
+	var bundle a2a.TaskBundle
+	if err := c.call("tasks/export", a2a.TaskExportParams{ID: id}, &bundle); err != nil {
+		return nil, err
+	}
+	for _, task := range bundle.Tasks {
+		if task.ID == id {
+			return task, nil
+		}
+	}
+	return nil, fmt.Errorf("task %s is missing from its export", id)
+}
+
+// waitFor polls a task until it reaches state.
+func (c *selftestClient) waitFor(id string, state a2a.TaskState) (*a2a.Task, error) {
+	deadline := time.Now().Add(c.timeout)
+	for {
+		task, err := c.task(id)
+		if err != nil {
+			return nil, err
+		}
+		if task.State == state {
+			return task, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("task %s is %s after %s, expected %s", id, task.State, c.timeout, state)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func selftestMessage(text string) a2a.Message {
+	return a2a.Message{Role: a2a.RoleUser, Parts: []a2a.Part{a2a.TextPart{Type: "text", Text: text}}}
+}
+
+// lastText returns the text of the last message of a task with the given role.
+func lastText(task *a2a.Task, role a2a.MessageRole) string {
+	for i := len(task.Messages) - 1; i >= 0; i-- {
+		if task.Messages[i].Role != role {
+			continue
+		}
+		var text strings.Builder
+		for _, part := range task.Messages[i].Parts {
+			if p, ok := part.(a2a.TextPart); ok {
+				text.WriteString(p.Text)
+			}
+		}
+		return text.String()
+	}
+	return ""
+}
+
+// selftestStep is one check of the scripted task lifecycle.
+type selftestStep struct {
+	name string
+	run  func() error
+}
+
+// runSelftest runs `ka selftest`: it starts the agent in-process on a
+// loopback port with a mock provider and a temporary task store, drives a
+// scripted task lifecycle through the HTTP API and prints a pass/fail report.
+// It exits with status 1 when a step fails.
+func runSelftest(args []string) {
+	fs := flag.NewFlagSet("selftest", flag.ExitOnError)
+	timeout := fs.Duration("timeout", 10*time.Second, "How long each step waits for the task to reach the expected state")
+	verbose := fs.Bool("v", false, "Print the agent output along with the report")
+	fs.Parse(args)
+	report := os.Stdout
+	if !*verbose {
+		// The agent logs and prints as it runs; only the report is wanted
+		log.SetOutput(io.Discard)
+		if devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0); err == nil {
+			os.Stdout = devNull
+			defer devNull.Close()
+		}
+	}
+
+	if err := selftest(report, *timeout); err != nil {
+		fmt.Fprintln(os.Stderr, "selftest:", err)
+		os.Exit(1)
+	}
+}
+
+// selftest sets up the agent and runs the steps; it returns an error when
+// the agent cannot start or a step fails.
+func selftest(report io.Writer, timeout time.Duration) error {
+	dir, err := os.MkdirTemp("", "ka-selftest-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	fixturePath := filepath.Join(dir, "fixture.txt")
+	if err := os.WriteFile(fixturePath, []byte(selftestFixture+"\n"), 0644); err != nil {
+		return err
+	}
+	store, err := a2a.NewFileTaskStore(filepath.Join(dir, "tasks"))
+	if err != nil {
+		return err
+	}
+
+	selftestTools := map[string]tools.Tool{}
+	for _, tool := range tools.GetAllTools() {
+		if name := tool.GetName(); name == "read_file" || name == "ask_followup_question" {
+			selftestTools[name] = tool
+		}
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return err
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	client := &selftestLLMClient{fixturePath: fixturePath}
+	taskExecutor := a2a.NewTaskExecutor(client, store, selftestTools, "")
+	registerHTTPHandlers(taskExecutor, client, port, "ka-selftest", "In-process agent of ka selftest", "mock", "", nil, selftestTools, nil, map[string]tools.ToolAvailability{}, 10<<20, nil, nil, nil)
+	server := &http.Server{}
+	go server.Serve(listener)
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		taskExecutor.Shutdown(ctx)
+		server.Shutdown(ctx)
+	}()
+
+	c := &selftestClient{baseURL: fmt.Sprintf("http://127.0.0.1:%d", port), http: &http.Client{Timeout: 2 * timeout}, timeout: timeout}
+	var streamedTaskID string
+	steps := []selftestStep{
+		{"tool call", func() error { return selftestToolCall(c) }},
+		{"input required", func() error { return selftestInputRequired(c) }},
+		{"streaming", func() (err error) {
+			streamedTaskID, err = selftestStreaming(c)
+			return err
+		}},
+		{"artifacts", func() error { return selftestArtifacts(c, streamedTaskID) }},
+		{"deletion", func() error { return selftestDeletion(c, streamedTaskID) }},
+	}
+
+	failed := 0
+	for _, step := range steps {
+		if err := step.run(); err != nil {
+			failed++
+			fmt.Fprintf(report, "FAIL  %-15s %v\n", step.name, err)
+		} else {
+			fmt.Fprintf(report, "PASS  %s\n", step.name)
+		}
+	}
+	fmt.Fprintf(report, "%d passed, %d failed\n", len(steps)-failed, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d steps failed", failed, len(steps))
+	}
+	return nil
+}
+
+// selftestToolCall checks that a tool call is dispatched and its result fed
+// back to the LLM.
+func selftestToolCall(c *selftestClient) error {
+	id, err := c.send("selftest: read the fixture")
+	if err != nil {
+		return err
+	}
+	task, err := c.waitFor(id, a2a.TaskStateCompleted)
+	if err != nil {
+		return err
+	}
+	if result := lastText(task, a2a.RoleTool); !strings.Contains(result, selftestFixture) {
+		return fmt.Errorf("expected the read_file result to contain the fixture, got %q", result)
+	}
+	if answer := lastText(task, a2a.RoleAssistant); !strings.Contains(answer, selftestFixture) {
+		return fmt.Errorf("expected the answer to quote the fixture, got %q", answer)
+	}
+	return nil
+}
+
+// selftestInputRequired checks that a question parks the task until
+// tasks/input answers it.
+func selftestInputRequired(c *selftestClient) error {
+	id, err := c.send("selftest: ask me")
+	if err != nil {
+		return err
+	}
+	if _, err := c.waitFor(id, a2a.TaskStateInputRequired); err != nil {
+		return err
+	}
+	if err := c.call("tasks/input", a2a.ProvideInputParams{TaskID: id, Input: selftestMessage("blue")}, nil); err != nil {
+		return err
+	}
+	task, err := c.waitFor(id, a2a.TaskStateCompleted)
+	if err != nil {
+		return err
+	}
+	if answer := lastText(task, a2a.RoleAssistant); answer != "You answered: blue" {
+		return fmt.Errorf("expected the answer to use the input, got %q", answer)
+	}
+	return nil
+}
+
+// selftestStreaming runs a task through tasks/sendSubscribe and checks the
+// streamed chunks and the final state. It returns the ID of the task.
+func selftestStreaming(c *selftestClient) (string, error) {
+	body, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": "stream", "method": "tasks/sendSubscribe", "params": map[string]interface{}{"message": selftestMessage("selftest: stream")}})
+	resp, err := c.http.Post(c.baseURL+"/", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		return "", fmt.Errorf("expected an event stream, got %s (HTTP %d)", ct, resp.StatusCode)
+	}
+
+	var taskID, finalState string
+	var chunks strings.Builder
+	event := ""
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() && finalState == "" {
+		line := scanner.Text()
+		if name, ok := strings.CutPrefix(line, "event: "); ok {
+			event = name
+			continue
+		}
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		switch event {
+		case "rpc-response":
+			var rpcResp struct {
+				Result struct {
+					ID string `json:"id"`
+				} `json:"result"`
+			}
+			json.Unmarshal([]byte(data), &rpcResp)
+			taskID = rpcResp.Result.ID
+		case "message":
+			var message struct {
+				Chunk string `json:"chunk"`
+			}
+			json.Unmarshal([]byte(data), &message)
+			chunks.WriteString(message.Chunk)
+		case "state":
+			var state struct {
+				Status string `json:"status"`
+			}
+			json.Unmarshal([]byte(data), &state)
+			if state.Status != string(a2a.TaskStateSubmitted) && state.Status != string(a2a.TaskStateWorking) {
+				finalState = state.Status
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return taskID, err
+	}
+	if taskID == "" {
+		return "", errors.New("the stream did not return the task ID")
+	}
+	if finalState != string(a2a.TaskStateCompleted) {
+		return taskID, fmt.Errorf("expected the stream to end with %s, got %q", a2a.TaskStateCompleted, finalState)
+	}
+	if expected := strings.Join(selftestStreamReply, ""); chunks.String() != expected {
+		return taskID, fmt.Errorf("expected the streamed chunks to spell %q, got %q", expected, chunks.String())
+	}
+	return taskID, nil
+}
+
+// selftestArtifacts checks that the streamed answer was saved as an artifact
+// and can be downloaded.
+func selftestArtifacts(c *selftestClient, taskID string) error {
+	if taskID == "" {
+		return errors.New("skipped: no streamed task")
+	}
+	task, err := c.waitFor(taskID, a2a.TaskStateCompleted)
+	if err != nil {
+		return err
+	}
+	var artifact *a2a.Artifact
+	for _, a := range task.Artifacts {
+		if a.Filename == "llm_streamed_response.txt" {
+			artifact = a
+		}
+	}
+	if artifact == nil {
+		return fmt.Errorf("expected an llm_streamed_response.txt artifact, got %d artifact(s)", len(task.Artifacts))
+	}
+	resp, err := c.http.Get(c.baseURL + a2a.ArtifactRefPath + "?" + url.Values{"id": {taskID}, "artifact_id": {artifact.ID}}.Encode())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("artifact download failed with HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if expected := strings.Join(selftestStreamReply, ""); string(data) != expected {
+		return fmt.Errorf("expected the artifact to hold %q, got %q", expected, data)
+	}
+	return nil
+}
+
+// selftestDeletion checks that a deleted task is gone.
+func selftestDeletion(c *selftestClient, taskID string) error {
+	if taskID == "" {
+		return errors.New("skipped: no streamed task")
+	}
+	if err := c.call("tasks/delete", a2a.TaskDeleteParams{ID: taskID, Permanent: true}, nil); err != nil {
+		return err
+	}
+	_, err := c.task(taskID)
+	var rpcErr selftestRPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != -32001 {
+		return fmt.Errorf("expected the deleted task to be not found, got %v", err)
+	}
+	return nil
+}
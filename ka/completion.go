@@ -0,0 +1,154 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// subcommands are the non-flag first arguments ka understands.
+var subcommands = []string{"server", "completion", "selftest"}
+
+// completionShells are the shells runCompletion can generate a script for.
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// flagValueCompletions lists the accepted values of flags with a fixed set.
+var flagValueCompletions = map[string][]string{
+	"provider": {"lmstudio", "google"},
+	"mode":     {"coder", "researcher", "orchestrator"},
+	"output":   {"text", "json"},
+}
+
+// runCompletion prints the completion script for the shell named in args,
+// e.g. `ka completion bash`.
+func runCompletion(args []string) {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "Usage: ka completion <%s>\n", strings.Join(completionShells, "|"))
+		os.Exit(1)
+	}
+	if err := writeCompletion(os.Stdout, args[0], completionFlags()); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
+
+// completionFlag describes one command line flag for completion scripts.
+type completionFlag struct {
+	Name   string
+	Usage  string
+	IsBool bool
+	Values []string
+}
+
+// completionFlags returns the registered flags sorted by name.
+func completionFlags() []completionFlag {
+	var flags []completionFlag
+	flag.VisitAll(func(f *flag.Flag) {
+		isBool := false
+		if bf, ok := f.Value.(interface{ IsBoolFlag() bool }); ok {
+			isBool = bf.IsBoolFlag()
+		}
+		flags = append(flags, completionFlag{Name: f.Name, Usage: f.Usage, IsBool: isBool, Values: flagValueCompletions[f.Name]})
+	})
+	sort.Slice(flags, func(i, j int) bool { return flags[i].Name < flags[j].Name })
+	return flags
+}
+
+// writeCompletion writes the completion script for shell to w.
+func writeCompletion(w io.Writer, shell string, flags []completionFlag) error {
+	switch shell {
+	case "bash":
+		writeBashCompletion(w, flags)
+	case "zsh":
+		writeZshCompletion(w, flags)
+	case "fish":
+		writeFishCompletion(w, flags)
+	default:
+		return fmt.Errorf("unsupported shell '%s' (expected one of %s)", shell, strings.Join(completionShells, ", "))
+	}
+	return nil
+}
+
+func writeBashCompletion(w io.Writer, flags []completionFlag) {
+	var names []string
+	for _, f := range flags {
+		names = append(names, "-"+f.Name)
+	}
+	fmt.Fprintln(w, "# bash completion for ka. Load with: source <(ka completion bash)")
+	fmt.Fprintln(w, "_ka() {")
+	fmt.Fprintln(w, `	local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}"`)
+	fmt.Fprintln(w, `	case "$prev" in`)
+	fmt.Fprintf(w, "	completion) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", strings.Join(completionShells, " "))
+	for _, f := range flags {
+		if len(f.Values) > 0 {
+			fmt.Fprintf(w, "	-%s|--%s) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", f.Name, f.Name, strings.Join(f.Values, " "))
+		} else if !f.IsBool {
+			fmt.Fprintf(w, "	-%s|--%s) COMPREPLY=($(compgen -f -- \"$cur\")); return ;;\n", f.Name, f.Name)
+		}
+	}
+	fmt.Fprintln(w, "	esac")
+	fmt.Fprintln(w, `	if [[ "$cur" == -* ]]; then`)
+	fmt.Fprintf(w, "		COMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(names, " "))
+	fmt.Fprintln(w, "	elif [[ $COMP_CWORD -eq 1 ]]; then")
+	fmt.Fprintf(w, "		COMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(subcommands, " "))
+	fmt.Fprintln(w, "	fi")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, "complete -F _ka ka")
+}
+
+func writeZshCompletion(w io.Writer, flags []completionFlag) {
+	fmt.Fprintln(w, "#compdef ka")
+	fmt.Fprintln(w, "# zsh completion for ka. Load with: source <(ka completion zsh)")
+	fmt.Fprintln(w, "_ka() {")
+	fmt.Fprintln(w, "	_arguments \\")
+	for _, f := range flags {
+		spec := fmt.Sprintf("-%s[%s]", f.Name, zshEscape(firstSentence(f.Usage)))
+		if len(f.Values) > 0 {
+			spec += fmt.Sprintf(":%s:(%s)", f.Name, strings.Join(f.Values, " "))
+		} else if !f.IsBool {
+			spec += fmt.Sprintf(":%s:_files", f.Name)
+		}
+		fmt.Fprintf(w, "		'%s' \\\n", spec)
+	}
+	fmt.Fprintf(w, "		'1::command:(%s)' \\\n", strings.Join(subcommands, " "))
+	fmt.Fprintln(w, "		'*::prompt:_default'")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, "compdef _ka ka")
+}
+
+func writeFishCompletion(w io.Writer, flags []completionFlag) {
+	fmt.Fprintln(w, "# fish completion for ka. Load with: ka completion fish | source")
+	fmt.Fprintln(w, "complete -c ka -f")
+	fmt.Fprintf(w, "complete -c ka -n '__fish_use_subcommand' -a '%s'\n", strings.Join(subcommands, " "))
+	fmt.Fprintf(w, "complete -c ka -n '__fish_seen_subcommand_from completion' -a '%s'\n", strings.Join(completionShells, " "))
+	for _, f := range flags {
+		line := fmt.Sprintf("complete -c ka -o %s -d '%s'", f.Name, fishEscape(firstSentence(f.Usage)))
+		if len(f.Values) > 0 {
+			line += fmt.Sprintf(" -x -a '%s'", strings.Join(f.Values, " "))
+		} else if !f.IsBool {
+			line += " -r -F"
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+// firstSentence shortens a flag usage to fit on a completion menu line.
+func firstSentence(usage string) string {
+	for _, sep := range []string{". ", " (", "; "} {
+		if i := strings.Index(usage, sep); i > 0 {
+			usage = usage[:i]
+		}
+	}
+	return usage
+}
+
+func zshEscape(s string) string {
+	return strings.NewReplacer("'", `'\''`, "[", `\[`, "]", `\]`, ":", `\:`).Replace(s)
+}
+
+func fishEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s)
+}
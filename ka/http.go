@@ -2,15 +2,21 @@ package main
 
 import (
 	"bytes" // Added for request body buffering
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io" // Added for io.ReadAll
 	"log"
 	"net/http"
-	// "os"      // No longer needed here
+	"os"
+	"os/signal"
+	"sort"
 	// "os/user" // No longer needed here
 	// "runtime" // No longer needed here
 	"strings" // Added for string manipulation
+	"sync"
+	"syscall"
 	"time"    // Added import for time package
 
 	"ka/a2a" // Keep one a2a import
@@ -48,6 +54,7 @@ const (
 	jsonRPCInvalidParamsCode   = -32602
 	jsonRPCInternalErrorCode   = -32603
 	jsonRPCServerErrorBaseCode = -32000
+	jsonRPCForbiddenCode       = -32003
 )
 
 // --- Middleware Definitions (will be instantiated with config) ---
@@ -106,13 +113,149 @@ func jwtAuthMiddleware(jwtSecret []byte) func(http.HandlerFunc) http.HandlerFunc
 	}
 }
 
+// signatureAuthMiddleware authenticates requests signed by known agents and
+// passes the signing agent in the request context. Unsigned requests go to
+// unsigned, the handler with the other configured authentication, or are
+// rejected when it is nil. Rejected signatures and replays are audited.
+func signatureAuthMiddleware(verifier *a2a.SignatureVerifier, audit *a2a.AuditLog, unsigned http.HandlerFunc) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if !a2a.Signed(r) {
+				if unsigned == nil {
+					http.Error(w, "Signed request required", http.StatusUnauthorized)
+					return
+				}
+				unsigned(w, r)
+				return
+			}
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				http.Error(w, "Failed to read request body", http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+			agent, err := verifier.Verify(r, body)
+			if err != nil {
+				log.Printf("Rejecting signed request: %v", err)
+				audit.RecordRequest(r, "signature", err.Error())
+				http.Error(w, "Invalid signature", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(a2a.WithSigningAgent(r.Context(), agent)))
+		}
+	}
+}
+
 // --- Handlers ---
 
 // Health check handler
-func healthHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+func healthHandler(llmClient llm.LLMClient, modelWarmer *llm.ModelWarmer, deliveries *a2a.Deliverer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		health := map[string]interface{}{"status": "ok", "llmConnections": llm.ConnectionStats(), "tokenCounting": llm.TokenCountingOf(llmClient)}
+		if queues := llm.RequestQueueStats(); len(queues) > 0 {
+			health["llmQueues"] = queues
+		}
+		if deliveries != nil {
+			health["deadLetters"] = deliveries.DeadLetters.Stats()
+		}
+		if modelWarmer != nil {
+			health["modelWarmup"] = modelWarmer.Status()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(health)
+	}
+}
+
+// llmInfo describes the model of the agent for its agent card. Providers
+// that can list their models, such as Ollama, report the model actually
+// used when none was configured and the models available.
+func llmInfo(llmClient llm.LLMClient, configuredModel string) map[string]interface{} {
+	info := map[string]interface{}{"model": configuredModel}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	models, ok, err := llm.ListModels(ctx, llmClient)
+	if !ok {
+		return info
+	}
+	if err != nil {
+		log.Printf("[llmInfo] Failed to list the models of the LLM backend: %v", err)
+		return info
+	}
+	names := make([]string, 0, len(models))
+	for _, m := range models {
+		names = append(names, m.Name)
+	}
+	info["available_models"] = names
+	if model, err := llm.CurrentModel(ctx, llmClient); err == nil && model != "" {
+		info["model"] = model
+	}
+	return info
+}
+
+// readyHandler reports whether the agent can take tasks without a cold
+// start: 503 until the model warm-up finished once, when it is enabled.
+func readyHandler(modelWarmer *llm.ModelWarmer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ready := map[string]interface{}{"ready": true}
+		status := http.StatusOK
+		if modelWarmer != nil {
+			ready["modelWarmup"] = modelWarmer.Status()
+			if !modelWarmer.Ready() {
+				ready["ready"] = false
+				status = http.StatusServiceUnavailable
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(ready)
+	}
+}
+
+// supportedRPCMethods lists the JSON-RPC methods dispatched by the root handler.
+// Keep it in sync with the switch in jsonRPCHandler; it is published in the agent card.
+var supportedRPCMethods = []string{
+	"tasks/send",
+	"tasks/status",
+	"tasks/sendSubscribe",
+	"tasks/resubscribe",
+	"tasks/input",
+	"tasks/pushNotification/set",
+	"tasks/artifact",
+	"tasks/list",
+	"tasks/delete",
+	"tasks/restore",
+	"tasks/addMessage",
+	"tasks/export",
+	"tasks/import",
+	"tasks/importChat",
+	"tasks/terminateCommand",
+	"tasks/step",
+	"tasks/addNote",
+	"tasks/update",
+	"tasks/thread",
+	"tasks/feedback",
+	"tasks/stats",
+	"tasks/exportFeedback",
+	"tasks/previewPrompt",
+	"tasks/approve",
+	"tasks/events",
+	"events/deliver",
+	"events/list",
+	"modes/list",
+	"modes/define",
+	"tools/execute",
+	"agent/negotiate",
+	"admin/corruptTasks",
+	"admin/readOnly",
+	"admin/sse",
+	"admin/checkPrompt",
+	"admin/purgeTrash",
+	"admin/deadLetters",
+	"admin/redriveDeadLetters",
+	"admin/dropDeadLetters",
+	"admin/auditLog",
+	"projects/list",
 }
 
 // agentCardHandler now accepts the agent card map directly
@@ -150,9 +293,10 @@ func writeJSONRPCError(w http.ResponseWriter, id interface{}, code int, message
 	}
 }
 
-// startHTTPServer sets up and starts the HTTP server for the agent.
-// It now accepts the TaskExecutor, the LLMClient interface, and the map of available tools.
-func startHTTPServer(
+// registerHTTPHandlers sets up the HTTP endpoints of the agent on the default
+// mux; serveHTTP serves them. It accepts the TaskExecutor, the LLMClient
+// interface, and the map of available tools.
+func registerHTTPHandlers(
 		taskExecutor *a2a.TaskExecutor,
 		llmClient llm.LLMClient,
 		port int,
@@ -163,15 +307,21 @@ func startHTTPServer(
 		apiKeys []string,
 		availableTools map[string]tools.Tool,
 		mcpToolInstance *tools.McpTool, // Add mcpToolInstance
+		toolReport map[string]tools.ToolAvailability,
+		maxRequestBytes int64,
+		signatureVerifier *a2a.SignatureVerifier, // Nil disables signed requests
+		modelWarmer *llm.ModelWarmer, // Nil when the model is not warmed up
+		startup *startupReport, // Nil leaves /debug/startup unregistered
 	) {
 	// --- Process Auth Configuration ---
 	jwtAuthEnabled := jwtSecretString != ""
 	apiKeyAuthEnabled := len(apiKeys) > 0
 
+	var startupAuth []string
 	var actualJwtSecret []byte
 	if jwtAuthEnabled {
 		actualJwtSecret = []byte(jwtSecretString)
-		fmt.Println("[auth] JWT Authentication Enabled")
+		startupAuth = append(startupAuth, "JWT")
 	}
 
 	actualValidAPIKeys := make(map[string]bool)
@@ -184,12 +334,22 @@ func startHTTPServer(
 		// Re-check enablement in case only empty keys were passed
 		apiKeyAuthEnabled = len(actualValidAPIKeys) > 0
 		if apiKeyAuthEnabled {
-			fmt.Printf("[auth] API Key Authentication Enabled (%d keys)\n", len(actualValidAPIKeys))
+			startupAuth = append(startupAuth, fmt.Sprintf("API keys (%d keys)", len(actualValidAPIKeys)))
 		}
 	}
 
-	if !jwtAuthEnabled && !apiKeyAuthEnabled {
-		fmt.Println("[auth] No authentication configured.")
+	if signatureVerifier != nil {
+		startupAuth = append(startupAuth, fmt.Sprintf("request signatures (%d keys)", len(signatureVerifier.Keys)))
+	}
+	if startup != nil {
+		startup.setAuth(startupAuth)
+	}
+	if len(startupAuth) == 0 {
+		if taskExecutor.ToolPolicy != nil && taskExecutor.ToolPolicy.AllowUnauthenticated {
+			log.Printf("[http] WARNING: no authentication configured and -tools-execute-unauthenticated is set: any caller can run tools with tools/execute.")
+		} else {
+			log.Printf("[http] No authentication configured: tools/execute and admin/readOnly are refused. Configure -api-keys, -jwt-secret or -signing-keys, or set -tools-execute-unauthenticated to open tools/execute.")
+		}
 	}
 
 	// --- Create Agent Card ---
@@ -201,6 +361,9 @@ func startHTTPServer(
 	if apiKeyAuthEnabled {
 		authMethods = append(authMethods, "apiKey") // New format
 	}
+	if signatureVerifier != nil {
+		authMethods = append(authMethods, "signature")
+	}
 	if len(authMethods) == 0 {
 		authMethods = append(authMethods, "none")
 	}
@@ -216,6 +379,20 @@ func startHTTPServer(
 		"tasks_input": "/",
 	}
 
+	// Capabilities reflect this instance's configuration, so clients can adapt
+	// without probing. Push notifications and websockets are not implemented yet.
+	capabilities := a2a.AgentCapabilities{
+		Streaming:              true,
+		PushNotifications:      false,
+		StateTransitionHistory: true, // Full message history is kept per task
+		WebSocket:              false,
+		AuthModes:              authMethods,
+		MaxMessageBytes:        maxRequestBytes,
+		InputModes:             []string{"text", "file", "data"},
+		OutputModes:            []string{"text", "file"},
+		Methods:                supportedRPCMethods,
+	}
+
 	dynamicAgentCard := map[string]interface{}{
 		"name":             agentName,
 		"description":      agentDescription,
@@ -224,10 +401,9 @@ func startHTTPServer(
 		"protocol_version": "a2a-draft-0.1", // Add missing field
 		"url":              agentURL,        // Use dynamic URL with trailing slash
 		"endpoints":        endpoints,       // Add the endpoints map
-		"capabilities": map[string]interface{}{
-			"streaming":         true,
-			"pushNotifications": false, // TODO: Implement push notifications
-		},
+		"capabilities":       capabilities,
+		"defaultInputModes":  capabilities.InputModes,
+		"defaultOutputModes": capabilities.OutputModes,
 		"skills": []map[string]interface{}{
 			{
 				"id":          "llm_chat",
@@ -237,9 +413,7 @@ func startHTTPServer(
 				"outputModes": []string{"text"},
 			},
 		},
-		"llm_info": map[string]string{
-			"model": agentModel,
-		},
+		"llm_info": llmInfo(llmClient, agentModel),
 		"authentication": authMethods, // Use corrected auth methods format (array of strings)
 	}
 
@@ -288,7 +462,16 @@ func startHTTPServer(
 			log.Printf("[Root Handler] Accepted POST request for Path: %s", r.URL.Path) // Log acceptance
 
 			// Read the body
+			if maxRequestBytes > 0 {
+				r.Body = http.MaxBytesReader(w, r.Body, maxRequestBytes)
+			}
 			bodyBytes, err := io.ReadAll(r.Body)
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				log.Printf("Rejecting request body larger than %d bytes", maxRequestBytes)
+				writeJSONRPCError(w, nil, jsonRPCInvalidRequestCode, "Invalid Request: message too large", map[string]int64{"maxMessageBytes": maxRequestBytes})
+				return
+			}
 			if err != nil {
 				log.Printf("Error reading request body: %v", err)
 				writeJSONRPCError(w, nil, jsonRPCInternalErrorCode, "Internal server error reading request body", nil)
@@ -341,9 +524,27 @@ func startHTTPServer(
 				// This is somewhat inefficient but avoids rewriting all handlers immediately.
 
 				// Create a new request context with the original body bytes for the target handler
-				handlerReq := r.Clone(r.Context())
+				handlerCtx := r.Context()
+				if apiKeyAuthEnabled || jwtAuthEnabled || signatureVerifier != nil {
+					// The middleware let the request through, so its caller is authenticated
+					handlerCtx = a2a.WithAuthenticatedCaller(handlerCtx)
+				}
+				if apiKeyAuthEnabled {
+					// Requests made with a project-bound key are scoped to that project
+					handlerCtx = a2a.WithProject(handlerCtx, taskExecutor.Projects.ForAPIKey(r.Header.Get("X-API-Key")))
+				}
+				handlerReq := r.Clone(handlerCtx)
 				handlerReq.Body = io.NopCloser(bytes.NewBuffer(finalBodyBytes)) // Use the final bytes read
 
+				if err := taskExecutor.ReadOnly.CheckMethod(req.Method); err != nil {
+					writeJSONRPCError(w, req.ID, jsonRPCForbiddenCode, "Forbidden: agent is read-only", err.Error())
+					return
+				}
+				if err := a2a.CheckAdminCaller(handlerCtx, req.Method); err != nil {
+					writeJSONRPCError(w, req.ID, jsonRPCForbiddenCode, "Forbidden: admin method", err.Error())
+					return
+				}
+
 				switch req.Method {
 				case "tasks/send":
 					a2a.TasksSendHandler(taskExecutor)(w, handlerReq)
@@ -352,6 +553,8 @@ func startHTTPServer(
 				case "tasks/sendSubscribe":
 					// Note: sendSubscribe might need special handling if it expects direct streaming response setup
 					a2a.TasksSendSubscribeHandler(taskExecutor)(w, handlerReq)
+				case "tasks/resubscribe":
+					a2a.TasksResubscribeHandler(taskExecutor)(w, handlerReq)
 				case "tasks/input":
 					a2a.TasksInputHandler(taskExecutor)(w, handlerReq)
 				case "tasks/pushNotification/set":
@@ -359,11 +562,73 @@ func startHTTPServer(
 				case "tasks/artifact":
 					a2a.TasksArtifactHandler(taskStore)(w, handlerReq)
 				case "tasks/list": // Handle the list method
-					a2a.TasksListHandler(taskStore)(w, handlerReq)
+					a2a.TasksListHandler(taskStore, taskExecutor.Projects)(w, handlerReq)
 				case "tasks/delete": // Handle the delete method
-					a2a.TasksDeleteHandler(taskStore)(w, handlerReq)
+					a2a.TasksDeleteHandler(taskExecutor)(w, handlerReq)
+				case "tasks/restore":
+					a2a.TasksRestoreHandler(taskStore)(w, handlerReq)
 				case "tasks/addMessage": // Handle the addMessage method
 					TasksAddMessageHandler(taskExecutor)(w, handlerReq) // Call the new handler
+				case "tasks/export":
+					a2a.TasksExportHandler(taskStore)(w, handlerReq)
+				case "tasks/import":
+					a2a.TasksImportHandler(taskExecutor)(w, handlerReq)
+				case "tasks/importChat":
+					a2a.TasksImportChatHandler(taskExecutor)(w, handlerReq)
+				case "modes/list":
+					a2a.ModesListHandler(taskExecutor.Modes)(w, handlerReq)
+				case "modes/define":
+					a2a.ModesDefineHandler(taskExecutor.Modes)(w, handlerReq)
+				case "tasks/terminateCommand":
+					a2a.TasksTerminateCommandHandler(taskExecutor)(w, handlerReq)
+				case "tasks/addNote":
+					a2a.TasksAddNoteHandler(taskStore)(w, handlerReq)
+				case "tasks/update":
+					a2a.TasksUpdateHandler(taskStore)(w, handlerReq)
+				case "tasks/thread":
+					a2a.TasksThreadHandler(taskExecutor)(w, handlerReq)
+				case "tasks/feedback":
+					a2a.TasksFeedbackHandler(taskStore)(w, handlerReq)
+				case "tasks/stats":
+					a2a.TasksStatsHandler(taskStore)(w, handlerReq)
+				case "tasks/exportFeedback":
+					a2a.TasksExportFeedbackHandler(taskStore)(w, handlerReq)
+				case "tasks/step":
+					a2a.TasksStepHandler(taskExecutor)(w, handlerReq)
+				case "tasks/previewPrompt":
+					a2a.TasksPreviewPromptHandler(taskExecutor)(w, handlerReq)
+				case "tasks/approve":
+					a2a.TasksApproveHandler(taskExecutor)(w, handlerReq)
+				case "tasks/events":
+					a2a.TasksEventsHandler(taskExecutor)(w, handlerReq)
+				case "events/deliver":
+					a2a.EventsDeliverHandler(taskExecutor)(w, handlerReq)
+				case "events/list":
+					a2a.EventsListHandler(taskExecutor)(w, handlerReq)
+				case "tools/execute":
+					a2a.TasksToolsExecuteHandler(taskExecutor)(w, handlerReq)
+				case "projects/list":
+					a2a.ProjectsListHandler(taskExecutor.Projects)(w, handlerReq)
+				case "admin/readOnly":
+					a2a.AdminReadOnlyHandler(&taskExecutor.ReadOnly)(w, handlerReq)
+				case "admin/sse":
+					a2a.AdminSSEHandler(&taskExecutor.SSE)(w, handlerReq)
+				case "admin/checkPrompt":
+					a2a.AdminCheckPromptHandler(taskExecutor)(w, handlerReq)
+				case "admin/purgeTrash":
+					a2a.AdminPurgeTrashHandler(taskStore, taskExecutor.TaskWorkspaceRoot)(w, handlerReq)
+				case "admin/deadLetters":
+					a2a.AdminDeadLettersHandler(taskExecutor.Deliveries)(w, handlerReq)
+				case "admin/redriveDeadLetters":
+					a2a.AdminRedriveDeadLettersHandler(taskExecutor.Deliveries)(w, handlerReq)
+				case "admin/dropDeadLetters":
+					a2a.AdminDropDeadLettersHandler(taskExecutor.Deliveries)(w, handlerReq)
+				case "admin/auditLog":
+					a2a.AdminAuditLogHandler(taskExecutor.Audit)(w, handlerReq)
+				case "admin/corruptTasks":
+					a2a.AdminCorruptTasksHandler(taskStore)(w, handlerReq)
+				case "agent/negotiate":
+					a2a.AgentNegotiateHandler(capabilities)(w, handlerReq)
 				default:
 					log.Printf("Method not found: %s", req.Method)
 					writeJSONRPCError(w, req.ID, jsonRPCMethodNotFoundCode, "Method not found", req.Method)
@@ -378,6 +643,14 @@ func startHTTPServer(
 			if jwtAuthEnabled {
 				handlerWithAuth = jwtMiddleware(handlerWithAuth)
 			}
+			if signatureVerifier != nil {
+				// Signed requests from known agents need no token or key
+				var unsigned http.HandlerFunc
+				if apiKeyAuthEnabled || jwtAuthEnabled {
+					unsigned = handlerWithAuth
+				}
+				handlerWithAuth = signatureAuthMiddleware(signatureVerifier, taskExecutor.Audit, unsigned)(coreLogic)
+			}
 
 			// Execute the handler chain
 			handlerWithAuth(w, r)
@@ -386,47 +659,62 @@ func startHTTPServer(
 
 	// --- Handlers for new endpoints ---
 
-	// toolsHandler lists available tools
-	toolsHandler := func(availableTools map[string]tools.Tool) http.HandlerFunc {
+	// toolsHandler lists all known tools with their startup availability.
+	// Disabled tools are listed too, so clients can see why they are missing.
+	// The list only changes with the MCP configuration, so it is encoded once
+	// and served with an ETag until then.
+	var toolListMu sync.Mutex
+	var toolListBody []byte
+	var toolListETag string
+	toolsHandler := func(toolReport map[string]tools.ToolAvailability) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
 			if r.Method != http.MethodGet {
 				http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
 				return
 			}
-			w.Header().Set("Content-Type", "application/json")
-
-			// Prepare a list of tool definitions suitable for JSON output
-			type ToolDefinition struct {
-				Name        string `json:"name"`
-				Description string `json:"description"`
-				XMLDefinition string `json:"xml_definition"` // Add XMLDefinition field
-			}
-			var toolList []ToolDefinition
-			for _, tool := range availableTools {
-				toolList = append(toolList, ToolDefinition{
-					Name:        tool.GetName(),
-					Description: tool.GetDescription(),
-					XMLDefinition: tool.GetXMLDefinition(), // Include the XML definition
-				})
-			}
 
-			// ADDED: Log the toolList before encoding
-			log.Printf("[toolsHandler] Tool list before encoding: %+v", toolList)
-
-			encoder := json.NewEncoder(w)
-			encoder.SetIndent("", "  ") // Optional: make output readable for debugging
+			toolListMu.Lock()
+			if toolListBody == nil {
+				// Prepare a list of tool definitions suitable for JSON output
+				type ToolDefinition struct {
+					Name          string                 `json:"name"`
+					Description   string                 `json:"description"`
+					XMLDefinition string                 `json:"xml_definition"`
+					Availability  tools.ToolAvailability `json:"availability"`
+				}
+				var toolList []ToolDefinition
+				for _, report := range toolReport {
+					toolList = append(toolList, ToolDefinition{
+						Name:          report.Tool.GetName(),
+						Description:   report.Tool.GetDescription(),
+						XMLDefinition: report.Tool.GetXMLDefinition(),
+						Availability:  report,
+					})
+				}
+				sort.Slice(toolList, func(i, j int) bool { return toolList[i].Name < toolList[j].Name })
 
-			if err := encoder.Encode(toolList); err != nil {
-				// ADDED: Log the encoding error explicitly
-				log.Printf("[toolsHandler] Error encoding tools list: %v", err)
-				http.Error(w, "Internal server error during tool list encoding", http.StatusInternalServerError)
-				return // Ensure we stop processing after sending error
+				body, err := json.MarshalIndent(toolList, "", "  ")
+				if err != nil {
+					toolListMu.Unlock()
+					log.Printf("[toolsHandler] Error encoding tools list: %v", err)
+					http.Error(w, "Internal server error during tool list encoding", http.StatusInternalServerError)
+					return
+				}
+				toolListBody = append(body, '\n')
+				toolListETag = tools.ContentETag(toolListBody)
+				log.Printf("[toolsHandler] Encoded tool list of %d tools.", len(toolList))
 			}
+			body, etag := toolListBody, toolListETag
+			toolListMu.Unlock()
 
-			// ADDED: Log successful encoding
-			log.Printf("[toolsHandler] Successfully encoded and sent tool list.")
+			writeWithETag(w, r, body, etag)
 		}
 	}
+	invalidateToolList := func() {
+		toolListMu.Lock()
+		toolListBody = nil
+		toolListMu.Unlock()
+	}
 
 	// updateSystemPromptHandler updates the agent's system prompt stored in the TaskExecutor.
 	updateSystemPromptHandler := func(taskExecutor *a2a.TaskExecutor) http.HandlerFunc {
@@ -435,10 +723,15 @@ func startHTTPServer(
 				http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
 				return
 			}
+			if taskExecutor.ReadOnly.Enabled() {
+				http.Error(w, "Forbidden: agent is read-only", http.StatusForbidden)
+				return
+			}
 			w.Header().Set("Content-Type", "application/json")
 
 			var requestBody struct {
 				SystemPrompt string `json:"systemPrompt"`
+				Force        bool   `json:"force"` // Apply even if the smoke suite scores worse
 			}
 
 			if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
@@ -452,42 +745,58 @@ func startHTTPServer(
 				return
 			}
 
+			// Run the smoke suite before the change applies, so regressions are not silent
+			var report *a2a.PromptRegressionReport
+			if taskExecutor.SmokeSuite != nil {
+				report = taskExecutor.CheckPrompt(r.Context(), requestBody.SystemPrompt)
+				if report.Regressed && !requestBody.Force {
+					log.Printf("System prompt update rejected: smoke suite score dropped by %.2f", -report.Delta)
+					w.WriteHeader(http.StatusConflict)
+					json.NewEncoder(w).Encode(map[string]interface{}{"status": "rejected", "message": "Smoke suite regressed; resend with force to apply anyway", "smokeTest": report})
+					return
+				}
+			}
+
 			taskExecutor.SystemMessage = requestBody.SystemPrompt // Update the system message in TaskExecutor
 			log.Printf("System prompt updated successfully to: %s", taskExecutor.SystemMessage)
 
 			w.WriteHeader(http.StatusOK)
-			json.NewEncoder(w).Encode(map[string]string{"status": "success", "message": "System prompt updated"})
+			response := map[string]interface{}{"status": "success", "message": "System prompt updated"}
+			if report != nil {
+				response["smokeTest"] = report
+			}
+			json.NewEncoder(w).Encode(response)
 		}
 	}
 
 	// composePromptHandler composes the system prompt based on selected tools and MCP servers.
+	// Composed prompts are cached and carry an ETag. Besides POST with a JSON
+	// body, GET with comma-separated toolNames and mcpServerNames query
+	// parameters is accepted, so polling clients can use plain HTTP caching.
+	promptCache := tools.NewPromptCache()
 	composePromptHandler := func(availableTools map[string]tools.Tool, mcpToolInstance *tools.McpTool) http.HandlerFunc { // Add mcpToolInstance
 		return func(w http.ResponseWriter, r *http.Request) {
-			if r.Method != http.MethodPost {
-				http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
-				return
-			}
-			w.Header().Set("Content-Type", "application/json")
-
 			var requestBody struct {
 				ToolNames      []string `json:"toolNames"`
 				McpServerNames []string `json:"mcpServerNames"`
 			}
 
-			if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
-				log.Printf("Error decoding compose prompt request body: %v", err)
-				writeJSONRPCError(w, nil, jsonRPCInvalidParamsCode, "Invalid Request Body", "Expected JSON object with toolNames and mcpServerNames arrays")
+			switch r.Method {
+			case http.MethodPost:
+				if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+					log.Printf("Error decoding compose prompt request body: %v", err)
+					w.Header().Set("Content-Type", "application/json")
+					writeJSONRPCError(w, nil, jsonRPCInvalidParamsCode, "Invalid Request Body", "Expected JSON object with toolNames and mcpServerNames arrays")
+					return
+				}
+			case http.MethodGet:
+				requestBody.ToolNames = splitCommaList(r.URL.Query().Get("toolNames"))
+				requestBody.McpServerNames = splitCommaList(r.URL.Query().Get("mcpServerNames"))
+			default:
+				http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
 				return
 			}
 
-			// Use the configurations from the McpTool instance
-			if mcpToolInstance == nil || mcpToolInstance.Configs == nil { // Use public Configs field
-				log.Printf("Warning: McpTool instance or configurations not available for composing prompt.")
-				// Proceed with only regular tools if MCP configs are not available
-			}
-
-			log.Printf("[composePromptHandler] Received MCP server names: %v", requestBody.McpServerNames)
-
 			var selectedMcpConfigs []tools.McpServerConfig
 			if mcpToolInstance != nil && mcpToolInstance.Configs != nil { // Use public Configs field
 				for _, serverName := range requestBody.McpServerNames {
@@ -499,16 +808,17 @@ func startHTTPServer(
 				}
 			}
 
-
-			// Compose the system prompt
-			composedPrompt := tools.ComposeSystemPrompt(requestBody.ToolNames, selectedMcpConfigs, availableTools)
+			// Compose the system prompt, or reuse the one composed for the same selection
+			composed := promptCache.Compose(requestBody.ToolNames, selectedMcpConfigs, availableTools)
 
 			// Return the composed prompt as a JSON string
-			response := map[string]string{"systemPrompt": composedPrompt}
-			if err := json.NewEncoder(w).Encode(response); err != nil {
+			body, err := json.Marshal(map[string]string{"systemPrompt": composed.Prompt})
+			if err != nil {
 				log.Printf("Error encoding composed prompt response: %v", err)
 				http.Error(w, "Internal server error", http.StatusInternalServerError)
+				return
 			}
+			writeWithETag(w, r, append(body, '\n'), composed.ETag)
 		}
 	}
 
@@ -539,6 +849,8 @@ func startHTTPServer(
 			log.Printf("[updateMcpConfigHandler] Calling mcpToolInstance.SetConfigs with configMap: %+v", configMap)
 
 			mcpToolInstance.SetConfigs(configMap) // Call the SetConfigs method on McpTool
+			promptCache.Invalidate()
+			invalidateToolList()
 
 			log.Printf("[updateMcpConfigHandler] MCP server configurations updated successfully. Loaded %d servers.", len(configMap))
 
@@ -551,15 +863,42 @@ func startHTTPServer(
 
 	// Public endpoints remain the same
 	http.HandleFunc("/.well-known/agent.json", agentCardHandler(dynamicAgentCard))
-	http.HandleFunc("/health", healthHandler)
+	http.HandleFunc("/health", healthHandler(llmClient, modelWarmer, taskExecutor.Deliveries))
+	http.HandleFunc("/ready", readyHandler(modelWarmer))
 
 	// New endpoints for tool management, prompt composition, prompt update, and MCP config update
 	// Register these specific paths BEFORE the root handler
-	http.HandleFunc("/tools", toolsHandler(availableTools))
+	http.HandleFunc("/tools", toolsHandler(toolReport))
 	http.HandleFunc("/compose-prompt", composePromptHandler(availableTools, mcpToolInstance)) // Pass mcpToolInstance
 	http.HandleFunc("/system-prompt", updateSystemPromptHandler(taskExecutor)) // Register the system prompt handler, pass taskExecutor
 	http.HandleFunc("/set-mcp-config", updateMcpConfigHandler(mcpToolInstance)) // Register the new MCP config handler
 
+	// Artifact downloads, referenced from the final SSE event; protected like the JSON-RPC endpoint
+	artifactHandler := a2a.TasksArtifactHandler(taskExecutor.TaskStore)
+	if apiKeyAuthEnabled {
+		serveArtifact := artifactHandler
+		artifactHandler = apiKeyMiddleware(func(w http.ResponseWriter, r *http.Request) {
+			// Scoped to the project of the key, like the JSON-RPC methods
+			serveArtifact(w, r.WithContext(a2a.WithProject(r.Context(), taskExecutor.Projects.ForAPIKey(r.Header.Get("X-API-Key")))))
+		})
+	}
+	if jwtAuthEnabled {
+		artifactHandler = jwtMiddleware(artifactHandler)
+	}
+	http.HandleFunc(a2a.ArtifactRefPath, artifactHandler)
+
+	// The startup report shows the configuration, so it is protected too
+	if startup != nil {
+		startupHandler := startup.ServeHTTP
+		if apiKeyAuthEnabled {
+			startupHandler = apiKeyMiddleware(startupHandler)
+		}
+		if jwtAuthEnabled {
+			startupHandler = jwtMiddleware(startupHandler)
+		}
+		http.HandleFunc("/debug/startup", startupHandler)
+	}
+
 
 	// Root handler for all JSON-RPC requests (should be registered last)
 	http.HandleFunc("/", jsonRPCHandler(
@@ -572,12 +911,42 @@ func startHTTPServer(
 	))
 
 	// Specific /tasks/* handlers are now removed as they are handled by the root handler
+}
 
-	// --- Start Server ---
+// serveHTTP serves the registered endpoints on port until SIGINT or SIGTERM,
+// then shuts down gracefully.
+func serveHTTP(port int, taskExecutor *a2a.TaskExecutor, shutdownTimeout time.Duration) {
 	listenAddr := fmt.Sprintf(":%d", port)
 	fmt.Printf("[http] Agent server running at http://localhost:%d/\n", port)
-	fmt.Println("[http] Registered Handlers: /.well-known/agent.json, /health, /tools, /compose-prompt, /system-prompt, /set-mcp-config, /") // Updated log message order
-	log.Fatal(http.ListenAndServe(listenAddr, nil))
+	fmt.Println("[http] Registered Handlers: /.well-known/agent.json, /health, /ready, /tools, /compose-prompt, /system-prompt, /set-mcp-config, /artifact, /debug/startup, /") // Updated log message order
+	server := &http.Server{Addr: listenAddr}
+	signalCtx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- server.ListenAndServe() }()
+	select {
+	case err := <-serveErr:
+		log.Fatal(err)
+	case <-signalCtx.Done():
+	}
+	stopSignals() // A second signal kills the agent right away
+	shutdownServer(server, taskExecutor, shutdownTimeout)
+}
+
+// shutdownServer stops accepting requests, interrupts the running tasks so
+// their state is persisted and their streams end with a final event, and
+// waits for open requests until timeout.
+func shutdownServer(server *http.Server, taskExecutor *a2a.TaskExecutor, timeout time.Duration) {
+	log.Printf("[http] Shutting down, waiting up to %s for running tasks...", timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	serverDone := make(chan error, 1)
+	go func() { serverDone <- server.Shutdown(ctx) }() // Closes the listeners right away
+	interrupted := taskExecutor.Shutdown(ctx)
+	if err := <-serverDone; err != nil {
+		log.Printf("[http] Open requests did not finish in time: %v", err)
+	}
+	log.Printf("[http] Shutdown complete. %d running task(s) interrupted.", len(interrupted))
 }
 
 // TasksAddMessageHandler handles the JSON-RPC method "tasks/addMessage".
@@ -618,6 +987,11 @@ func TasksAddMessageHandler(taskExecutor *a2a.TaskExecutor) http.HandlerFunc {
 
 		log.Printf("[TasksAddMessageHandler] Received request for task ID: %s, message role: %s", params.ID, params.Message.Role)
 
+		if a2a.HiddenFromCaller(r.Context(), taskExecutor.TaskStore, params.ID) {
+			writeJSONRPCError(w, req.ID, jsonRPCMethodNotFoundCode, "Task not found", params.ID)
+			return
+		}
+
 		// Call the new method on TaskExecutor to handle adding the message and processing
 		err = taskExecutor.AddTaskMessageAndProcess(params.ID, params.Message)
 		if err != nil {
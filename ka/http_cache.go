@@ -0,0 +1,31 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// writeWithETag sends a JSON body tagged with etag, or 304 Not Modified when
+// the client already has it. Clients must revalidate, so they never use a
+// stale copy after the configuration changed.
+func writeWithETag(w http.ResponseWriter, r *http.Request, body []byte, etag string) {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// etagMatches reports whether an If-None-Match header lists etag.
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -1,25 +1,27 @@
 package main
 
 import (
+	"bufio"
 	"context" // Import the context package
+	"encoding/json"
 	"flag"
 	"fmt"
 	"ka/a2a"
+	"ka/a2aclient"
 	"ka/llm"
 	"ka/tools" // Import the tools package
 	"log"      // Manually added back
+	"net/http"
 	"os"
 	// "os/user" // No longer needed here
 	// "runtime" // No longer needed here
 	"strconv" // Added for port conversion
 	"strings" // Added for API key splitting
-	// "time" // Removed unused import
+	"time"
 )
 
 const (
-	model                   = "" // Consider making this configurable via flags/env
-	apiURL                  = "http://localhost:1234/v1/chat/completions"
-	defaultMaxContextLength = 8192 // Increased default context length to accommodate long system prompt
+	model = "" // Consider making this configurable via flags/env
 )
 
 var availableToolsMap map[string]tools.Tool
@@ -31,8 +33,115 @@ func main() {
 	flags := parseFlags()
 	log.Printf("[main] Flags parsed.")
 
+	if flags.repairTasksFlag {
+		runRepairTasks()
+		return
+	}
+	if args := flag.Args(); len(args) > 0 && args[0] == "completion" {
+		runCompletion(args[1:])
+		return
+	}
+	if args := flag.Args(); len(args) > 0 && args[0] == "selftest" {
+		runSelftest(args[1:])
+		return
+	}
+
+	if flags.tokenizersFlag != "" {
+		rules, err := llm.LoadTokenizerOverrides(flags.tokenizersFlag)
+		if err == nil {
+			err = llm.Tokenizers.SetOverrides(rules)
+		}
+		if err != nil {
+			log.Fatalf("Invalid -tokenizers: %v", err)
+		}
+	}
+	if flags.tokenizerCacheFlag != "" {
+		llm.SetTokenizerCacheDir(flags.tokenizerCacheFlag)
+	}
+	if flags.networkFlag != "" {
+		network, err := llm.LoadNetworkConfigs(flags.networkFlag)
+		if err != nil {
+			log.Fatalf("Invalid -network: %v", err)
+		}
+		llm.SetNetworkConfigs(network)
+		flags.network = network
+	}
+	queueConfigs, err := llm.ParseQueueConfigs(splitCommaList(flags.llmConcurrencyFlag), flags.llmQueueLimitFlag)
+	if err != nil {
+		log.Fatalf("Invalid -llm-concurrency: %v", err)
+	}
+	llm.SetRequestQueues(queueConfigs)
+	flags.egress = &llm.EgressPolicy{}
+	if flags.egressFlag != "" {
+		egress, err := llm.LoadEgressPolicy(flags.egressFlag)
+		if err != nil {
+			log.Fatalf("Invalid -egress: %v", err)
+		}
+		flags.egress = egress
+	}
+
 	// Load available tools and get the McpTool instance
-	availableToolsMap, mcpToolInstance := loadTools()
+	availableToolsMap, mcpToolInstance, toolReport := loadTools()
+	if flags.mcpConfigFlag != "" && mcpToolInstance != nil {
+		configs, err := loadMcpConfigs(flags.mcpConfigFlag)
+		if err != nil {
+			log.Fatalf("Invalid -mcp-config: %v", err)
+		}
+		mcpToolInstance.SetConfigs(configs)
+	}
+	if flags.cloudBucketsFlag != "" {
+		buckets, err := tools.LoadCloudBuckets(flags.cloudBucketsFlag)
+		if err != nil {
+			log.Fatalf("Invalid -cloud-buckets: %v", err)
+		}
+		cloudTool := tools.NewCloudObjectTool(buckets)
+		availableToolsMap[cloudTool.GetName()] = cloudTool
+		toolReport[cloudTool.GetName()] = tools.ProbeTool(cloudTool)
+	}
+	if flags.sqlConnectionsFlag != "" {
+		connections, err := tools.LoadSQLConnections(flags.sqlConnectionsFlag)
+		if err != nil {
+			log.Fatalf("Invalid -sql-connections: %v", err)
+		}
+		sqlTool := tools.NewSQLQueryTool(connections)
+		availableToolsMap[sqlTool.GetName()] = sqlTool
+		toolReport[sqlTool.GetName()] = tools.ProbeTool(sqlTool)
+	}
+	if flags.delegateAgentsFlag != "" {
+		agents, err := a2aclient.LoadAgents(flags.delegateAgentsFlag)
+		if err != nil {
+			log.Fatalf("Invalid -delegate-agents: %v", err)
+		}
+		delegateTool := a2aclient.NewDelegateTaskTool(agents, &http.Client{Transport: flags.egress.Wrap(flags.network.For(llm.NetworkAgents).Transport())})
+		availableToolsMap[delegateTool.GetName()] = delegateTool
+		toolReport[delegateTool.GetName()] = tools.ProbeTool(delegateTool)
+	}
+	if flags.writeRootFlag != "" {
+		if info, err := os.Stat(flags.writeRootFlag); err != nil || !info.IsDir() {
+			log.Fatalf("Invalid -write-root: %s is not a directory", flags.writeRootFlag)
+		}
+		availableToolsMap["write_to_file"] = &tools.WriteToFileTool{Root: flags.writeRootFlag}
+		availableToolsMap["apply_diff"] = &tools.ApplyDiffTool{Root: flags.writeRootFlag}
+	}
+	if flags.commandAllowFlag != "" || flags.commandDenyFlag != "" || flags.commandTimeoutFlag > 0 || flags.commandMaxOutputFlag > 0 {
+		availableToolsMap["execute_command"] = &tools.ExecuteCommandTool{
+			Allow:          splitCommaList(flags.commandAllowFlag),
+			Deny:           splitCommaList(flags.commandDenyFlag),
+			Timeout:        flags.commandTimeoutFlag,
+			MaxOutputBytes: flags.commandMaxOutputFlag,
+		}
+	}
+	availableToolsMap["fetch_url"] = &tools.FetchURLTool{
+		Client:   &http.Client{Transport: flags.egress.Wrap(flags.network.For("fetch_url").Transport())},
+		Timeout:  flags.fetchTimeoutFlag,
+		MaxBytes: flags.fetchMaxBytesFlag,
+	}
+	if flags.mermaidCommandFlag != "" || flags.dotCommandFlag != "" {
+		availableToolsMap["render_diagram"] = &tools.RenderDiagramTool{MermaidCommand: flags.mermaidCommandFlag, DotCommand: flags.dotCommandFlag}
+	}
+	describeTool := tools.NewDescribeTool(availableToolsMap)
+	availableToolsMap[describeTool.GetName()] = describeTool
+	toolReport[describeTool.GetName()] = tools.ProbeTool(describeTool)
 
 	// Determine port from flags and environment
 	port := determinePort(flags.portFlag)
@@ -44,7 +153,7 @@ func main() {
 	currentDir := getCurrentWorkingDirectory()
 
 	if flags.serveFlag {
-		runServerMode(flags, port, availableToolsMap, mcpToolInstance, currentDir) // Pass mcpToolInstance
+		runServerMode(flags, port, availableToolsMap, mcpToolInstance, toolReport, currentDir) // Pass mcpToolInstance
 	} else {
 		runCLIMode(flags, availableToolsMap) // Pass flags struct
 	}
@@ -63,6 +172,82 @@ type FlagOptions struct {
 	apiKeysFlag          string
 	mcpConfigFlag string // Add flag for MCP server configuration
 	providerFlag  string // Add flag for LLM provider type
+	ollamaKeepAliveFlag  string
+	toolDenyFlag         string
+	toolsExecuteAllowFlag string
+	toolsExecuteUnauthFlag bool
+	toolAuditFlag        bool
+	modeFlag             string
+	outputPostprocessFlag string
+	outputMaxLengthFlag  int
+	sseInlineArtifactBytesFlag int
+	repairTasksFlag      bool
+	readOnlyFlag         bool
+	projectsFlag         string
+	heartbeatIntervalFlag time.Duration
+	stallMonitor         a2a.StallMonitor
+	stallAlertWebhookFlag string
+	deliveryAttemptsFlag  int
+	auditLogFlag          string
+	taskTimeoutFlag      time.Duration
+	maxContinuationsFlag int
+	taskWorkspacesFlag   string
+	trashRetentionFlag   time.Duration
+	sse                  a2a.SSEOptions
+	resubscribeGraceFlag time.Duration
+	shutdownTimeoutFlag  time.Duration
+	resumeInterruptedFlag bool
+	reconcileTasksFlag   bool
+	checkpointEveryFlag  int
+	instanceFlag         string
+	instancesFlag        string
+	contextStrategyFlag  string
+	contextWindowFlag    int
+	contextKeepRecentFlag int
+	toolQuotasFlag       string
+	toolOutputFiltersFlag string
+	signingKeysFlag      string
+	guardrailsFlag       string
+	promptSmokeSuiteFlag string
+	pruneToolsFlag       int
+	emailGatewayFlag     string
+	cloudBucketsFlag     string
+	sqlConnectionsFlag   string
+	delegateAgentsFlag   string
+	tokenizersFlag       string
+	tokenizerCacheFlag   string
+	networkFlag          string
+	writeRootFlag        string
+	commandAllowFlag     string
+	commandDenyFlag      string
+	commandTimeoutFlag   time.Duration
+	commandMaxOutputFlag int
+	fetchTimeoutFlag     time.Duration
+	fetchMaxBytesFlag    int64
+	mermaidCommandFlag   string
+	dotCommandFlag       string
+	network              llm.NetworkConfigs // Loaded from -network
+	egressFlag           string
+	egress               *llm.EgressPolicy // Loaded from -egress
+	githubFlag           string
+	signingKeyIDFlag     string
+	llmWarmupFlag        bool
+	modelWarmupFlag      bool
+	modelWarmupIdleFlag  time.Duration
+	subTaskPolicy        a2a.SubTaskPolicy
+	llmTimeouts          llm.Timeouts
+	llmMaxAttemptsFlag   int
+	llmConcurrencyFlag   string
+	llmQueueLimitFlag    int
+	maxRequestBytesFlag  int64
+	cliMaxIterationsFlag int
+	outputFlag           string
+	profileFlag          string
+	sessionFlag          string
+	newSessionFlag       bool
+	interactiveFlag      bool
+	agentURLFlag         string
+	yesFlag              bool
 	userPrompt    string // Add field for user prompt
 }
 
@@ -71,7 +256,28 @@ func parseFlags() FlagOptions {
 
 	flag.BoolVar(&flags.serveFlag, "serve", false, "Run the agent as an A2A HTTP server")
 	flag.BoolVar(&flags.streamFlag, "stream", false, "Enable streaming output for CLI chat")
-	flag.IntVar(&flags.maxContextLengthFlag, "max_context_length", defaultMaxContextLength, "Maximum context length for the LLM")
+	flag.IntVar(&flags.cliMaxIterationsFlag, "cli-max-iterations", 25, "Maximum LLM calls per prompt in CLI mode before giving up (0 disables the limit)")
+	flag.StringVar(&flags.outputFlag, "output", "text", "Output format of client commands: text, or json for a single machine-readable result object on stdout")
+	flag.StringVar(&flags.profileFlag, "profile", "", "Named CLI profile from profiles.json in the ka config directory (provider, model, agentUrl, apiKey, systemPrompt, tools); its session is resumed")
+	flag.StringVar(&flags.sessionFlag, "session", "", "Name of the CLI session to resume and save (default: the profile name, or 'default' with -i)")
+	flag.BoolVar(&flags.newSessionFlag, "new-session", false, "Discard the saved history of the CLI session and start over")
+	flag.BoolVar(&flags.interactiveFlag, "i", false, "Interactive CLI chat: read prompts from the terminal until 'exit'")
+	flag.StringVar(&flags.agentURLFlag, "agent-url", "", "Send CLI prompts to the A2A agent at this URL instead of the local LLM")
+	flag.BoolVar(&flags.yesFlag, "yes", false, "In CLI mode, run tools with side effects (write_to_file, apply_diff, execute_command, ...) without asking for confirmation")
+	flag.IntVar(&flags.maxContextLengthFlag, "max_context_length", llm.DefaultMaxContextLength, "Maximum context length for the LLM")
+	flag.StringVar(&flags.tokenizersFlag, "tokenizers", "", "Path to a JSON file or JSON object mapping model name patterns to tokenizers (cl100k_base, o200k_base, tiktoken:<file>, sentencepiece:<tokenizer.model>, chars, optionally *<scale>); checked before the built-in model families")
+	flag.StringVar(&flags.writeRootFlag, "write-root", "", "Directory write_to_file and apply_diff may write in; paths outside it are refused (default: the working directory)")
+	flag.StringVar(&flags.commandAllowFlag, "command-allow", "", "Comma-separated command patterns execute_command may run: program names (go, git), run as found in PATH, absolute paths or patterns with * (\"npm run *\"); each command of a chain must match, and variable assignments before a command are refused; this is the boundary on what runs (default: any command not denied)")
+	flag.StringVar(&flags.commandDenyFlag, "command-deny", "", "Comma-separated command patterns execute_command refuses to run, in the format of -command-allow; best-effort only, as the shell can spell a program in many ways: use -command-allow to bound what runs")
+	flag.DurationVar(&flags.commandTimeoutFlag, "command-timeout", 0, "Stop execute_command commands after this long, also caps the timeout_seconds of follow mode (0 disables)")
+	flag.IntVar(&flags.commandMaxOutputFlag, "command-max-output", 0, "Maximum bytes of command output returned to the LLM; longer output keeps its start and end (0 disables)")
+	flag.DurationVar(&flags.fetchTimeoutFlag, "fetch-timeout", 30*time.Second, "Timeout of a fetch_url request, including reading the response")
+	flag.Int64Var(&flags.fetchMaxBytesFlag, "fetch-max-bytes", 2<<20, "Maximum bytes of a response fetch_url reads")
+	flag.StringVar(&flags.mermaidCommandFlag, "mermaid-command", "", "Mermaid CLI render_diagram renders Mermaid diagrams with (default: mmdc from PATH)")
+	flag.StringVar(&flags.dotCommandFlag, "dot-command", "", "Graphviz binary render_diagram renders DOT diagrams with (default: dot from PATH)")
+	flag.StringVar(&flags.egressFlag, "egress", "", "Path to a JSON file or JSON object of the egress policy of fetch_url, file part downloads, webhook deliveries and requests to other agents ({allowDomains, denyDomains, allowCidrs, denyCidrs, allowLinkLocal}); link-local and cloud metadata addresses are blocked by default")
+	flag.StringVar(&flags.networkFlag, "network", "", "Path to a JSON file or JSON object of outbound network settings ({\"google\": {proxy, caBundle, insecureSkipVerify}, ...}) keyed by provider, \"webhooks\" for deliveries, \"agents\" for other agents, \"fetch_url\", or \"default\"")
+	flag.StringVar(&flags.tokenizerCacheFlag, "tokenizer-cache", "", "Directory downloaded tokenizer files are cached in (default: TIKTOKEN_CACHE_DIR or the user cache directory); put the files there in advance to run offline")
 	flag.StringVar(&flags.modelFlag, "model", model, "LLM model to use")
 	flag.IntVar(&flags.portFlag, "port", 8080, "Port for the A2A HTTP server")
 	flag.StringVar(&flags.nameFlag, "name", "Default ka agent", "Name of the agent")
@@ -79,7 +285,68 @@ func parseFlags() FlagOptions {
 	flag.StringVar(&flags.jwtSecretFlag, "jwt-secret", "", "JWT secret key for securing endpoints (if provided, JWT auth is enabled)")
 	flag.StringVar(&flags.apiKeysFlag, "api-keys", "", "Comma-separated list of valid API keys (if provided, API key auth is enabled)")
 	flag.StringVar(&flags.mcpConfigFlag, "mcp-config", "", "Path to MCP server configuration file or JSON string") // Define the new flag
-	flag.StringVar(&flags.providerFlag, "provider", "lmstudio", "LLM provider to use (e.g., 'lmstudio', 'google')") // Define the new provider flag
+	flag.StringVar(&flags.providerFlag, "provider", "lmstudio", "LLM provider to use ('lmstudio', 'google' or 'ollama')") // Define the new provider flag
+	flag.StringVar(&flags.ollamaKeepAliveFlag, "ollama-keep-alive", "", "With -provider=ollama, how long Ollama keeps the model loaded after a request (e.g. 10m, -1 for forever; empty keeps Ollama's default)")
+	flag.DurationVar(&flags.llmTimeouts.Connect, "llm-connect-timeout", llm.DefaultConnectTimeout, "Timeout for connecting to the LLM backend (0 disables)")
+	flag.DurationVar(&flags.llmTimeouts.FirstToken, "llm-first-token-timeout", llm.DefaultFirstTokenTimeout, "Timeout until the first streamed token; responses that are not streamed are bounded by the task timeout only (0 disables)")
+	flag.DurationVar(&flags.llmTimeouts.Stall, "llm-stall-timeout", llm.DefaultStallTimeout, "Maximum gap between streamed tokens before the LLM call is aborted (0 disables)")
+	flag.IntVar(&flags.llmMaxAttemptsFlag, "llm-max-attempts", 3, "Attempts per LLM call when it fails with a retryable error such as a timeout")
+	flag.StringVar(&flags.llmConcurrencyFlag, "llm-concurrency", "", "Comma-separated limits of concurrent LLM requests per provider, e.g. 'lmstudio=1,google=8'; further requests wait in a queue ordered by task priority")
+	flag.IntVar(&flags.llmQueueLimitFlag, "llm-queue-limit", 0, "Waiting LLM requests per provider queue at which new tasks are refused as 'provider busy' (0 never refuses)")
+	flag.Int64Var(&flags.maxRequestBytesFlag, "max-request-bytes", 10<<20, "Maximum size of a JSON-RPC request body in bytes (0 disables the limit)")
+	flag.StringVar(&flags.toolDenyFlag, "tool-deny", "", "Comma-separated list of tools that must never be executed")
+	flag.BoolVar(&flags.toolsExecuteUnauthFlag, "tools-execute-unauthenticated", false, "Allow tools/execute without authentication; by default it is refused unless -api-keys, -jwt-secret or -signing-keys is set")
+	flag.StringVar(&flags.toolsExecuteAllowFlag, "tools-execute-allow", "", "Comma-separated list of tools allowed via tools/execute (default: all non-denied tools)")
+	flag.IntVar(&flags.subTaskPolicy.MaxAttempts, "subtask-max-attempts", 1, "Default number of runs for a failing sub-task created via add_task (1 disables retry)")
+	flag.IntVar(&flags.subTaskPolicy.BackoffSeconds, "subtask-retry-backoff", 5, "Seconds to wait before retrying a failed sub-task, multiplied by the attempt number")
+	flag.BoolVar(&flags.subTaskPolicy.Escalate, "subtask-escalate", false, "Notify the parent task when a sub-task fails for good")
+	flag.StringVar(&flags.modeFlag, "mode", "", "Mode preset bundling system prompt, tools and model parameters (built in: coder, researcher, orchestrator)")
+	flag.StringVar(&flags.outputPostprocessFlag, "output-postprocess", "", "Comma-separated post-processing steps for final answers: sanitize, link-artifacts, strip-tool-xml, provenance")
+	flag.IntVar(&flags.outputMaxLengthFlag, "output-max-length", 0, "Truncate final answers longer than this many bytes with a notice (0 disables)")
+	flag.IntVar(&flags.sseInlineArtifactBytesFlag, "sse-inline-artifact-bytes", 0, "List artifacts in the final SSE event, inlining those up to this many bytes as base64 and linking larger ones (0 disables)")
+	flag.BoolVar(&flags.repairTasksFlag, "repair-tasks", false, "Quarantine corrupt task files in TASK_STORE_DIR, recover what can be recovered, print a report and exit")
+	flag.BoolVar(&flags.readOnlyFlag, "read-only", false, "Serve existing tasks only: refuse task creation, deletion and tools with side effects (toggle at runtime with admin/readOnly)")
+	flag.StringVar(&flags.signingKeysFlag, "signing-keys", "", "Path to a JSON file or JSON array of request signing keys ({id, agent, algorithm: hmac-sha256|ed25519, secret | publicKey, privateKey}); inbound requests signed with them are accepted")
+	flag.StringVar(&flags.signingKeyIDFlag, "signing-key", "", "ID of the key in -signing-keys used to sign this agent's outbound requests")
+	flag.StringVar(&flags.guardrailsFlag, "guardrails", "", "Path to a JSON file or JSON array of guardrail rules ({name, stage, when, action, message, model}) checked before LLM calls, tool calls and completion")
+	flag.StringVar(&flags.promptSmokeSuiteFlag, "prompt-smoke-suite", "", "Path to a JSON file or JSON object ({cases: [{name, input, expect, reject}], maxDrop, timeoutSeconds}) of a smoke suite run against system prompt changes before they apply")
+	flag.IntVar(&flags.pruneToolsFlag, "prune-tools", 0, "Keep only this many tool definitions in the system prompt of new tasks, picked by keyword relevance to the request; the other tools are listed by name. 0 keeps all")
+	flag.StringVar(&flags.emailGatewayFlag, "email-gateway", "", "Path to a JSON file or JSON object configuring the inbound email gateway ({listen, allowedSenders, template, smtpServer, from, ...}); each email becomes a task and gets the result as reply")
+	flag.StringVar(&flags.githubFlag, "github", "", "Path to a JSON file or JSON object configuring the GitHub webhook at /integrations/github ({webhookSecret, token, triggers: [{event, actions, command, prompt, includeDiff, mode, authorAssociations}]}); triggers fire for OWNER, MEMBER and COLLABORATOR authors unless authorAssociations lists others, and should use a restricted mode such as researcher")
+	flag.StringVar(&flags.cloudBucketsFlag, "cloud-buckets", "", "Path to a JSON file or JSON array of S3/GCS buckets ({name, provider: s3|gcs, bucket, region, endpoint, accessKeyId, secretAccessKey, prefixes, readOnly, maxReadBytes}) enabling the cloud_object tool")
+	flag.StringVar(&flags.delegateAgentsFlag, "delegate-agents", "", "Path to a JSON file or JSON array of A2A agents ({name, url, apiKey, description, timeoutSeconds}) enabling the delegate_task tool, which sends tasks to them and returns their results")
+	flag.StringVar(&flags.sqlConnectionsFlag, "sql-connections", "", "Path to a JSON file or JSON array of databases ({name, driver: postgres|mysql|sqlite, dsn, schemas, readWrite, maxRows, maxBytes}) enabling the sql_query tool; connections are read-only unless readWrite is set")
+	flag.StringVar(&flags.projectsFlag, "projects", "", "Path to a JSON file or JSON array of projects ({name, workspace, labels, apiKeys}) scoping tasks; tasks/list then requires a project")
+	flag.DurationVar(&flags.heartbeatIntervalFlag, "heartbeat-interval", 10*time.Second, "How often running tasks record a heartbeat (0 disables)")
+	flag.DurationVar(&flags.stallMonitor.Threshold, "stall-threshold", 0, "Mark WORKING tasks without a heartbeat for this long as STALLED (0 disables the monitor)")
+	flag.BoolVar(&flags.stallMonitor.AutoRestart, "stall-auto-restart", false, "Restart stalled tasks that have no live run")
+	flag.IntVar(&flags.stallMonitor.MaxRestarts, "stall-max-restarts", 1, "Automatic restarts per stalled task")
+	flag.StringVar(&flags.stallAlertWebhookFlag, "stall-alert-webhook", "", "URL receiving a JSON POST for every stalled task")
+	flag.StringVar(&flags.auditLogFlag, "audit-log", "", "File to append security events to as JSON lines, such as refused signatures and replayed webhook deliveries (see admin/auditLog)")
+	flag.IntVar(&flags.deliveryAttemptsFlag, "delivery-attempts", a2a.DefaultDeliveryAttempts, "Attempts of webhook and integration deliveries before they are dead-lettered (see admin/deadLetters)")
+	flag.DurationVar(&flags.taskTimeoutFlag, "task-timeout", 0, "Default execution timeout of tasks whose mode and request set none (0 disables)")
+	flag.StringVar(&flags.taskWorkspacesFlag, "task-workspaces", "", "Directory of per-task workspaces named by task ID, removed by tasks/delete with workspace: true")
+	flag.DurationVar(&flags.trashRetentionFlag, "trash-retention", 7*24*time.Hour, "How long deleted tasks stay in the trash, restorable with tasks/restore, before they are purged (0 deletes them right away)")
+	flag.DurationVar(&flags.sse.KeepAlive, "sse-keepalive", a2a.DefaultSSEKeepAlive, "Interval of keepalive comments on task event streams (0 disables); subscribers override it with ?keepalive=")
+	flag.DurationVar(&flags.sse.Retry, "sse-retry", 0, "Reconnection delay sent to subscribers as the SSE retry: field (0 sends none); subscribers override it with ?retry=")
+	flag.IntVar(&flags.sse.EventBuffer, "sse-event-buffer", a2a.DefaultSSEEventBuffer, "Events queued per stream for slow subscribers before they are dropped; subscribers override it with ?buffer=")
+	flag.DurationVar(&flags.shutdownTimeoutFlag, "shutdown-timeout", 30*time.Second, "How long a shutdown on SIGINT or SIGTERM waits for running tasks to persist their state as INTERRUPTED and for open requests to finish")
+	flag.BoolVar(&flags.resumeInterruptedFlag, "resume-interrupted", false, "Resume tasks interrupted by the previous shutdown at startup")
+	flag.BoolVar(&flags.reconcileTasksFlag, "reconcile-tasks", true, "Relaunch tasks left WORKING by a previous agent process that exited without a graceful shutdown at startup")
+	flag.IntVar(&flags.checkpointEveryFlag, "checkpoint-every", 0, "Checkpoint running tasks every N iterations and on SIGTERM, including finished tool results, so an instance resuming them on a shared store loses at most one iteration (0 disables; for preemptible nodes)")
+	flag.StringVar(&flags.instanceFlag, "instance", "", "Name of this instance in -instances")
+	flag.StringVar(&flags.instancesFlag, "instances", "", "Comma-separated names of all instances sharing the task store. Tasks of a session, else of a project, else of a root task are assigned to one of them by consistent hashing, and only that instance relaunches, resumes or restarts them, so it reuses their workspace and caches (default: this instance owns every task)")
+	flag.StringVar(&flags.contextStrategyFlag, "context-strategy", "", "How the executor shortens prompts longer than -context-window: truncate drops the oldest turns, sliding_window keeps only the latest -context-keep-recent messages, summarize replaces the oldest turns with an LLM summary. The system prompt, the task's first message and the latest messages are always kept (default: leave it to the LLM client)")
+	flag.IntVar(&flags.contextWindowFlag, "context-window", 0, "Token budget of a prompt for -context-strategy (default: -max_context_length)")
+	flag.IntVar(&flags.contextKeepRecentFlag, "context-keep-recent", a2a.DefaultContextKeepRecent, "Latest messages -context-strategy keeps intact")
+	flag.DurationVar(&flags.resubscribeGraceFlag, "resubscribe-grace", a2a.DefaultResubscribeGrace, "How long a streamed task keeps running after its subscriber disconnected, waiting for tasks/resubscribe, before it is cancelled")
+	flag.IntVar(&flags.maxContinuationsFlag, "max-continuations", 0, "How often an answer cut off by the max output token limit is continued, for tasks whose mode and request set none")
+	flag.BoolVar(&flags.llmWarmupFlag, "llm-warmup", false, "Open a connection to the LLM backend at startup so the first task does not wait for it")
+	flag.BoolVar(&flags.modelWarmupFlag, "model-warmup", false, "Load the model of a local provider (LM Studio, Ollama) at startup with a one-token prompt; /ready reports 503 until it is loaded")
+	flag.DurationVar(&flags.modelWarmupIdleFlag, "model-warmup-idle", 0, "With -model-warmup, load the model again after this long without LLM requests, before the backend unloads it (0 warms only at startup)")
+	flag.StringVar(&flags.toolOutputFiltersFlag, "tool-output-filters", "", "Path to a JSON file or JSON object mapping tool names to output filters ({\"jq\": ...} or {\"template\": ...}) applied before the LLM sees the output")
+	flag.StringVar(&flags.toolQuotasFlag, "tool-quotas", "", "Comma-separated per-task tool limits, e.g. 'fetch_url:calls=20,read_file:bytes=1000000'")
+	flag.BoolVar(&flags.toolAuditFlag, "tool-audit", false, "Store an audit artifact (arguments, redacted environment, stdout/stderr, exit status) for every tool call")
 
 	flag.Parse() // The crash is happening here or immediately after
 
@@ -91,27 +358,42 @@ func parseFlags() FlagOptions {
 		flags.userPrompt = strings.Join(args, " ") // Join all arguments to handle multi-word prompts
 	}
 
-	// Redirect standard log output to stdout
-	log.SetOutput(os.Stdout)
+	if flags.outputFlag != "text" && flags.outputFlag != "json" {
+		log.Fatalf("Invalid -output '%s' (expected text or json)", flags.outputFlag)
+	}
+
+	// Redirect standard log output to stdout, unless stdout is reserved for machine-readable output
+	if flags.outputFlag == "json" || (len(args) > 0 && (args[0] == "completion" || args[0] == "selftest")) {
+		log.SetOutput(os.Stderr)
+	} else {
+		log.SetOutput(os.Stdout)
+	}
 
 	return flags
 }
 
-// loadTools loads all available tools and returns the map and the McpTool instance.
-func loadTools() (map[string]tools.Tool, *tools.McpTool) {
+// loadTools loads all available tools and returns the map, the McpTool instance
+// and the startup availability report. Tools whose dependencies are missing are
+// left out of the map so they never reach the prompt or the dispatcher.
+func loadTools() (map[string]tools.Tool, *tools.McpTool, map[string]tools.ToolAvailability) {
 	log.Printf("[loadTools] Entering loadTools function.")
+	tools.ReapOrphanedMcpProcesses() // Clean up MCP servers left behind by a crashed agent
 	availableToolsSlice := tools.GetAllTools()
+	toolReport := tools.ProbeTools(availableToolsSlice)
 	availableToolsMap := make(map[string]tools.Tool)
 	var mcpToolInstance *tools.McpTool // Declare a variable to hold the McpTool instance
 
 	for _, tool := range availableToolsSlice {
+		if !toolReport[tool.GetName()].Available {
+			continue
+		}
 		availableToolsMap[tool.GetName()] = tool
 		// Check if the tool is the McpTool and store its instance
 		if mcpTool, ok := tool.(*tools.McpTool); ok {
 			mcpToolInstance = mcpTool
 		}
 	}
-	return availableToolsMap, mcpToolInstance
+	return availableToolsMap, mcpToolInstance, toolReport
 }
 
 func determinePort(portFlag int) int {
@@ -145,49 +427,51 @@ func getCurrentWorkingDirectory() string {
 	return currentDir
 }
 
-func runServerMode(flags FlagOptions, port int, availableToolsMap map[string]tools.Tool, mcpToolInstance *tools.McpTool, currentDir string) { // Removed environmentVariables
+func runServerMode(flags FlagOptions, port int, availableToolsMap map[string]tools.Tool, mcpToolInstance *tools.McpTool, toolReport map[string]tools.ToolAvailability, currentDir string) { // Removed environmentVariables
 	log.Printf("[runServerMode] Entering server mode.")
-	fmt.Println("[main] Starting in server mode...")
+	startup := newStartupReport(toolReport)
 
 	log.Printf("[runServerMode] Initializing task store.")
 	// Initialize task store
-	taskStore := initializeTaskStore()
+	taskStore, taskStoreLocation, taskStoreSource := initializeTaskStore()
+	startup.setTaskStore(taskStore, taskStoreLocation, taskStoreSource)
 	log.Printf("[runServerMode] Task store initialized.")
+	if mcpToolInstance != nil {
+		startup.setMCPServers(mcpToolInstance.Configs)
+	}
 
 	log.Printf("[runServerMode] Creating LLM client for server mode.")
 
-	// Determine API URL based on provider and environment variable
-	currentAPIURL := apiURL // Default for LM Studio
-	if flags.providerFlag == "lmstudio" {
-		envAPIURL := os.Getenv("LLM_API_BASE")
-		if envAPIURL != "" {
-			currentAPIURL = envAPIURL
-			log.Printf("[runServerMode] Using LLM_API_BASE environment variable for LMStudio API URL: %s", currentAPIURL)
-		} else {
-			log.Printf("[runServerMode] LLM_API_BASE environment variable not set, using default LMStudio API URL: %s", currentAPIURL)
-		}
-	} else if flags.providerFlag == "google" {
-		// Google API key is handled within NewGoogleClient using GEMINI_API_KEY env var
-		// No specific API URL needs to be set here for Google provider
-		currentAPIURL = "" // Or some indicator that API URL is not configured via this field for Google
-	}
-
-	// Convert provider flag to lowercase for matching in NewClientFactory
+	// Convert provider flag to lowercase for matching the provider configs
 	providerTypeLower := strings.ToLower(flags.providerFlag)
 	log.Printf("[runServerMode] Using provider type: %s (originally %s)", providerTypeLower, flags.providerFlag)
 
-	// Create LLM client for server mode using the factory
-	llmConfig := llm.ClientConfig{
-		"apiURL":           currentAPIURL, // Use determined API URL
-		"model":            flags.modelFlag,
-		"systemMessage":    "", // Initial system message for server mode
-		"maxContextLength": flags.maxContextLengthFlag,
-		// Google API key is now only read from GEMINI_API_KEY env var in NewGoogleClient
+	// Create LLM client for server mode
+	llmConfig, err := buildLLMConfig(providerTypeLower, flags, "") // Empty initial system message for server mode
+	if err != nil {
+		log.Fatalf("Failed to configure LLM client for server mode: %v", err)
 	}
-	llmClient, err := llm.NewClientFactory(providerTypeLower, llmConfig, make(map[string]string)) // Pass lowercase provider type
+	llmClient, err := llm.NewClient(llmConfig)
 	if err != nil {
 		log.Fatalf("Failed to create LLM client for server mode: %v", err)
 	}
+	llmClient = llm.WithRetry(llm.WithQueue(llmClient, providerTypeLower), llm.RetryPolicy{MaxAttempts: flags.llmMaxAttemptsFlag, Backoff: 2 * time.Second})
+	logTokenCounting(llmClient)
+	go startup.checkProvider(llmClient, providerTypeLower, flags.modelFlag)
+	if flags.llmWarmupFlag {
+		go func() {
+			if err := llm.WarmUp(context.Background(), llmClient); err != nil {
+				log.Printf("[runServerMode] LLM connection warm-up failed: %v", err)
+			} else {
+				log.Printf("[runServerMode] LLM connection warmed up.")
+			}
+		}()
+	}
+	var modelWarmer *llm.ModelWarmer
+	if flags.modelWarmupFlag {
+		modelWarmer = &llm.ModelWarmer{Client: llmClient, IdleAfter: flags.modelWarmupIdleFlag}
+		go modelWarmer.Run(context.Background())
+	}
 
 	// Create TaskExecutor
 	// Compose a default system message for the TaskExecutor in server mode
@@ -195,14 +479,197 @@ func runServerMode(flags FlagOptions, port int, availableToolsMap map[string]too
 	// A separate endpoint exists to update the system prompt dynamically.
 	serverSystemMessage := composeCliSystemMessage(availableToolsMap) // Use the same composition logic as CLI mode
 	taskExecutor := a2a.NewTaskExecutor(llmClient, taskStore, availableToolsMap, serverSystemMessage)
-	fmt.Printf("[main] TaskExecutor initialized with %d available tools.\n", len(availableToolsMap))
+	taskExecutor.DefaultProvider = providerTypeLower
+	taskExecutor.ClientFactory = func(provider, model string) (llm.LLMClient, error) {
+		taskFlags := flags
+		taskFlags.modelFlag = model
+		config, err := buildLLMConfig(strings.ToLower(provider), taskFlags, "")
+		if err != nil {
+			return nil, err
+		}
+		client, err := llm.NewClient(config)
+		if err != nil {
+			return nil, err
+		}
+		return llm.WithRetry(llm.WithQueue(client, strings.ToLower(provider)), llm.RetryPolicy{MaxAttempts: flags.llmMaxAttemptsFlag, Backoff: 2 * time.Second}), nil
+	}
+	taskExecutor.ToolPolicy = a2a.NewToolPolicy(splitCommaList(flags.toolDenyFlag), splitCommaList(flags.toolsExecuteAllowFlag))
+	taskExecutor.ToolPolicy.AllowUnauthenticated = flags.toolsExecuteUnauthFlag
+	taskExecutor.ToolAudit = flags.toolAuditFlag
+	taskExecutor.Deliveries.MaxAttempts = flags.deliveryAttemptsFlag
+	taskExecutor.Deliveries.Client.Transport = flags.egress.Wrap(flags.network.For(llm.NetworkWebhooks).Transport())
+	taskExecutor.AgentTransport = flags.egress.Wrap(flags.network.For(llm.NetworkAgents).Transport())
+	taskExecutor.FileTransport = flags.egress.Wrap(flags.network.For("fetch_url").Transport())
+	audit, err := a2a.NewAuditLog(flags.auditLogFlag)
+	if err != nil {
+		log.Fatalf("Invalid -audit-log: %v", err)
+	}
+	taskExecutor.Audit = audit
+	toolQuotas, err := a2a.ParseToolQuotas(splitCommaList(flags.toolQuotasFlag))
+	if err != nil {
+		log.Fatalf("Invalid -tool-quotas: %v", err)
+	}
+	taskExecutor.ToolQuotas = toolQuotas
+	if flags.toolOutputFiltersFlag != "" {
+		filters, err := a2a.LoadToolOutputFilters(flags.toolOutputFiltersFlag)
+		if err != nil {
+			log.Fatalf("Invalid -tool-output-filters: %v", err)
+		}
+		taskExecutor.ToolOutputFilters = filters
+	}
+	var signatureVerifier *a2a.SignatureVerifier
+	if flags.signingKeysFlag != "" {
+		signingKeys, err := a2a.LoadSigningKeys(flags.signingKeysFlag)
+		if err != nil {
+			log.Fatalf("Invalid -signing-keys: %v", err)
+		}
+		signatureVerifier = a2a.NewSignatureVerifier(signingKeys)
+		if flags.signingKeyIDFlag != "" {
+			signer, err := a2a.NewRequestSigner(flags.nameFlag, signingKeys, flags.signingKeyIDFlag)
+			if err != nil {
+				log.Fatalf("Invalid -signing-key: %v", err)
+			}
+			taskExecutor.Signer = signer
+			taskExecutor.Deliveries.Signer = signer
+			if delegateTool, ok := availableToolsMap["delegate_task"].(*a2aclient.DelegateTaskTool); ok {
+				delegateTool.HTTPClient = signer.Client(0, taskExecutor.AgentTransport) // Streams last as long as the remote task
+			}
+		}
+	} else if flags.signingKeyIDFlag != "" {
+		log.Fatalf("-signing-key requires -signing-keys")
+	}
+	if flags.guardrailsFlag != "" {
+		guardrails, err := a2a.LoadGuardrails(flags.guardrailsFlag)
+		if err != nil {
+			log.Fatalf("Invalid -guardrails: %v", err)
+		}
+		taskExecutor.Guardrails = guardrails
+	}
+	if flags.promptSmokeSuiteFlag != "" {
+		suite, err := a2a.LoadSmokeSuite(flags.promptSmokeSuiteFlag)
+		if err != nil {
+			log.Fatalf("Invalid -prompt-smoke-suite: %v", err)
+		}
+		taskExecutor.SmokeSuite = suite
+	}
+	taskExecutor.ReadOnly.Set(flags.readOnlyFlag)
+	taskExecutor.HeartbeatInterval = flags.heartbeatIntervalFlag
+	taskExecutor.DefaultTimeoutSeconds = int(flags.taskTimeoutFlag.Seconds())
+	taskExecutor.MaxContinuations = flags.maxContinuationsFlag
+	taskExecutor.TaskWorkspaceRoot = flags.taskWorkspacesFlag
+	taskExecutor.TrashRetention = flags.trashRetentionFlag
+	taskExecutor.ToolPruneTopK = flags.pruneToolsFlag
+	taskExecutor.ResubscribeGrace = flags.resubscribeGraceFlag
+	taskExecutor.CheckpointEvery = flags.checkpointEveryFlag
+	if flags.instancesFlag != "" {
+		ring, err := a2a.NewAffinityRing(flags.instanceFlag, splitCommaList(flags.instancesFlag))
+		if err != nil {
+			log.Fatalf("Invalid -instance or -instances: %v", err)
+		}
+		taskExecutor.Affinity = ring
+	}
+	if flags.contextStrategyFlag != "" {
+		strategy, err := a2a.NewContextStrategy(flags.contextStrategyFlag, flags.contextKeepRecentFlag)
+		if err != nil {
+			log.Fatalf("Invalid -context-strategy: %v", err)
+		}
+		taskExecutor.ContextStrategy = strategy
+		taskExecutor.ContextWindow = flags.contextWindowFlag
+		if taskExecutor.ContextWindow == 0 {
+			taskExecutor.ContextWindow = flags.maxContextLengthFlag
+		}
+		taskExecutor.ContextKeepRecent = flags.contextKeepRecentFlag
+	}
+	if err := taskExecutor.SSE.Set(flags.sse); err != nil {
+		log.Fatalf("Invalid SSE options: %v", err)
+	}
+	if flags.trashRetentionFlag > 0 {
+		go taskExecutor.RunTrashPurge(context.Background(), min(flags.trashRetentionFlag, time.Hour))
+	}
+	if flags.stallMonitor.Threshold > 0 {
+		monitor := flags.stallMonitor
+		monitor.Executor = taskExecutor
+		monitor.Interval = flags.stallMonitor.Threshold / 2
+		if flags.heartbeatIntervalFlag <= 0 || flags.stallMonitor.Threshold < 2*flags.heartbeatIntervalFlag {
+			log.Printf("Warning: -stall-threshold %s should be at least twice -heartbeat-interval %s, or running tasks will be marked STALLED", flags.stallMonitor.Threshold, flags.heartbeatIntervalFlag)
+		}
+		if flags.stallAlertWebhookFlag != "" {
+			monitor.Alert = a2a.WebhookAlert(flags.stallAlertWebhookFlag, taskExecutor.Deliveries)
+		}
+		go monitor.Run(context.Background())
+	}
+	if flags.projectsFlag != "" {
+		projects, err := a2a.LoadProjects(flags.projectsFlag)
+		if err != nil {
+			log.Fatalf("Invalid projects configuration: %v", err)
+		}
+		taskExecutor.Projects = projects
+	}
+	if flags.modeFlag != "" {
+		mode, ok := taskExecutor.Modes.Get(flags.modeFlag)
+		if !ok {
+			log.Fatalf("Unknown mode '%s'", flags.modeFlag)
+		}
+		taskExecutor.SystemMessage = mode.SystemPrompt(availableToolsMap)
+		taskExecutor.DefaultMode = mode.Name
+		serverSystemMessage = taskExecutor.SystemMessage
+	}
+	if flags.emailGatewayFlag != "" {
+		cfg, err := a2a.LoadEmailGatewayConfig(flags.emailGatewayFlag)
+		if err != nil {
+			log.Fatalf("Invalid -email-gateway: %v", err)
+		}
+		gateway, err := a2a.NewEmailGateway(cfg, taskExecutor)
+		if err != nil {
+			log.Fatalf("Invalid -email-gateway: %v", err)
+		}
+		go func() {
+			if err := gateway.ListenAndServe(); err != nil {
+				log.Printf("[main] Email gateway stopped: %v", err)
+			}
+		}()
+	}
+	if flags.githubFlag != "" {
+		integration, err := a2a.LoadGitHubIntegration(flags.githubFlag, taskExecutor)
+		if err != nil {
+			log.Fatalf("Invalid -github: %v", err)
+		}
+		// Authenticated by the webhook signature, so registered outside the JSON-RPC auth
+		http.HandleFunc(a2a.GitHubWebhookPath, a2a.GitHubWebhookHandler(integration))
+	}
+	outputProcessing, err := a2a.ParseOutputProcessing(splitCommaList(flags.outputPostprocessFlag), flags.outputMaxLengthFlag)
+	if err != nil {
+		log.Fatalf("Invalid output post-processing: %v", err)
+	}
+	taskExecutor.OutputProcessing = outputProcessing
+	taskExecutor.InlineArtifactBytes = flags.sseInlineArtifactBytesFlag
+	if flags.subTaskPolicy.MaxAttempts > 1 || flags.subTaskPolicy.Escalate {
+		subTaskPolicy := flags.subTaskPolicy
+		taskExecutor.SubTaskPolicy = &subTaskPolicy
+	}
+	if flags.resumeInterruptedFlag {
+		resumed, err := taskExecutor.ResumeInterrupted()
+		if err != nil {
+			log.Printf("[main] Failed to resume interrupted tasks: %v", err)
+		} else if len(resumed) > 0 {
+			log.Printf("[main] Resumed %d task(s) interrupted by the previous shutdown.", len(resumed))
+		}
+	}
+	if flags.reconcileTasksFlag {
+		report, err := taskExecutor.Reconcile()
+		if err != nil {
+			log.Printf("[main] Failed to reconcile stored tasks: %v", err)
+		} else if len(report.Relaunched) > 0 || len(report.AwaitingInput) > 0 {
+			log.Printf("[main] Relaunched %d task(s) left running by the previous agent process; %d task(s) are waiting for input.", len(report.Relaunched), len(report.AwaitingInput))
+		}
+	}
 	log.Printf("[runServerMode] TaskExecutor initialized with system message:\n%s\n", serverSystemMessage) // Added logging
 
 	// Process API keys
-	apiKeys := processAPIKeys(flags.apiKeysFlag)
+	apiKeys := append(processAPIKeys(flags.apiKeysFlag), taskExecutor.Projects.APIKeys()...)
 
 	// Start HTTP server
-	startHTTPServer(
+	registerHTTPHandlers(
 		taskExecutor,
 		llmClient,
 		port,
@@ -213,16 +680,45 @@ func runServerMode(flags FlagOptions, port int, availableToolsMap map[string]too
 		apiKeys,
 		availableToolsMap,
 		mcpToolInstance, // Pass mcpToolInstance
+		toolReport,
+		flags.maxRequestBytesFlag,
+		signatureVerifier,
+		modelWarmer,
+		startup,
 		// Removed flags.providerFlag
 	)
+	startup.logSummary()
+	serveHTTP(port, taskExecutor, flags.shutdownTimeoutFlag)
+}
+
+// logTokenCounting reports at startup whether token counts, which drive
+// context truncation, are exact or estimated.
+func logTokenCounting(client llm.LLMClient) {
+	counting := llm.TokenCountingOf(client)
+	switch counting.Mode {
+	case llm.TokenCountEstimate:
+		log.Printf("[main] WARNING: no tokenizer available for model %s, token counts are estimated from characters with a safety margin: %s", counting.Model, counting.Error)
+	case llm.TokenCountApproximate:
+		log.Printf("[main] Counting tokens of model %s approximately with %s. %s", counting.Model, counting.Tokenizer, counting.Error)
+	default:
+		log.Printf("[main] Counting tokens of model %s with %s.", counting.Model, counting.Tokenizer)
+	}
 }
 
-func initializeTaskStore() a2a.TaskStore {
-	taskStoreDir := os.Getenv("TASK_STORE_DIR") // Consider making this configurable via flags
+// initializeTaskStore opens the task store configured by the environment
+// and returns it with its location and the variable that set it.
+func initializeTaskStore() (a2a.TaskStore, string, string) {
+	if sqlitePath := os.Getenv("TASK_STORE_SQLITE"); sqlitePath != "" {
+		taskStore, err := a2a.NewSQLiteTaskStore(sqlitePath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error initializing SQLite task store: %v\n", err)
+			os.Exit(1)
+		}
+		return taskStore, sqlitePath, "env TASK_STORE_SQLITE"
+	}
+	taskStoreDir, source := os.Getenv("TASK_STORE_DIR"), "env TASK_STORE_DIR" // Consider making this configurable via flags
 	if taskStoreDir == "" {
-		fmt.Println("[main] TASK_STORE_DIR not set, using default task directory.")
-	} else {
-		fmt.Printf("[main] Using task directory from TASK_STORE_DIR: %s\n", taskStoreDir)
+		taskStoreDir, source = "_tasks", "default"
 	}
 
 	taskStore, err := a2a.NewFileTaskStore(taskStoreDir)
@@ -230,28 +726,113 @@ func initializeTaskStore() a2a.TaskStore {
 		fmt.Fprintf(os.Stderr, "Error initializing file task store: %v\n", err)
 		os.Exit(1)
 	}
-	return taskStore
+	return taskStore, taskStoreDir, source
+}
+
+// runRepairTasks repairs the file task store and prints the report as JSON.
+func runRepairTasks() {
+	store, _, _ := initializeTaskStore()
+	taskStore, ok := store.(*a2a.FileTaskStore)
+	if !ok {
+		fmt.Fprintln(os.Stderr, "Error: the task store does not support repair")
+		os.Exit(1)
+	}
+	report, err := taskStore.Repair()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error repairing task store: %v\n", err)
+		os.Exit(1)
+	}
+	data, _ := json.MarshalIndent(report, "", "  ")
+	fmt.Println(string(data))
+	if len(report.Unrecoverable) > 0 {
+		os.Exit(2)
+	}
+}
+
+// buildLLMConfig creates the typed provider config from the command line flags.
+// For LM Studio the LLM_API_BASE environment variable overrides the default API URL,
+// for Ollama the OLLAMA_HOST environment variable.
+func buildLLMConfig(providerType string, flags FlagOptions, systemMessage string) (llm.ProviderConfig, error) {
+	switch providerType {
+	case "lmstudio":
+		apiURL := os.Getenv("LLM_API_BASE")
+		if apiURL != "" {
+			log.Printf("[buildLLMConfig] Using LLM_API_BASE environment variable for LMStudio API URL: %s", apiURL)
+		}
+		return &llm.LMStudioConfig{
+			APIURL:           apiURL, // Empty means llm.DefaultLMStudioAPIURL
+			Model:            flags.modelFlag,
+			SystemMessage:    systemMessage,
+			MaxContextLength: flags.maxContextLengthFlag,
+			Timeouts:         &flags.llmTimeouts,
+		}, nil
+	case "google":
+		// The API key is read from the GEMINI_API_KEY env var during validation
+		return &llm.GoogleConfig{Model: flags.modelFlag, Timeouts: &flags.llmTimeouts}, nil
+	case "ollama":
+		// OLLAMA_HOST is the variable the ollama CLI uses, often without a scheme
+		baseURL := os.Getenv("OLLAMA_HOST")
+		if baseURL != "" && !strings.Contains(baseURL, "://") {
+			baseURL = "http://" + baseURL
+		}
+		return &llm.OllamaConfig{
+			BaseURL:   baseURL, // Empty means llm.DefaultOllamaURL
+			Model:     flags.modelFlag,
+			NumCtx:    flags.maxContextLengthFlag,
+			KeepAlive: flags.ollamaKeepAliveFlag,
+			Timeouts:  &flags.llmTimeouts,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported LLM provider type: %s", providerType)
+	}
 }
 
 func processAPIKeys(apiKeysFlag string) []string {
-	apiKeys := []string{}
-	if apiKeysFlag != "" {
-		keys := strings.Split(apiKeysFlag, ",")
-		for _, key := range keys {
-			trimmedKey := strings.TrimSpace(key)
-			if trimmedKey != "" {
-				apiKeys = append(apiKeys, trimmedKey)
+	return splitCommaList(apiKeysFlag)
+}
+
+// splitCommaList splits a comma-separated flag value, dropping empty entries.
+func splitCommaList(value string) []string {
+	items := []string{}
+	if value != "" {
+		for _, item := range strings.Split(value, ",") {
+			trimmed := strings.TrimSpace(item)
+			if trimmed != "" {
+				items = append(items, trimmed)
 			}
 		}
 	}
-	return apiKeys
+	return items
 }
 
 func runCLIMode(flags FlagOptions, availableToolsMap map[string]tools.Tool) { // Accept FlagOptions struct
 	// Warn about auth flags in CLI mode
 	warnAboutAuthFlags(flags.jwtSecretFlag, flags.apiKeysFlag)
 
-	fmt.Println("[main] Starting in CLI chat mode...")
+	// With -output=json only the result object goes to stdout
+	status := os.Stdout
+	if flags.outputFlag == "json" {
+		status = os.Stderr
+	}
+	fmt.Fprintln(status, "[main] Starting in CLI chat mode...")
+
+	var profile cliProfile
+	if flags.profileFlag != "" {
+		var err error
+		if profile, err = loadProfile(flags.profileFlag); err != nil {
+			log.Fatalf("Invalid -profile: %v", err)
+		}
+		applyProfile(&flags, profile)
+		if len(profile.Tools) > 0 {
+			selected := make(map[string]tools.Tool)
+			for _, name := range profile.Tools {
+				if tool, ok := availableToolsMap[name]; ok {
+					selected[name] = tool
+				}
+			}
+			availableToolsMap = selected
+		}
+	}
 
 	// Determine if streaming should be enabled
 	stream := determineStreamFlag(flags.streamFlag)
@@ -260,28 +841,118 @@ func runCLIMode(flags FlagOptions, availableToolsMap map[string]tools.Tool) { //
 	userPrompt := flags.userPrompt
 
 	// Check if user prompt is empty and print usage if so
-	if userPrompt == "" {
+	if userPrompt == "" && !flags.interactiveFlag {
 		printUsageAndExit()
 	}
 
-	// Compose system prompt with all available tools
-	cliSystemMessage := composeCliSystemMessage(availableToolsMap)
+	// Named sessions keep the conversation between runs: -session, else the
+	// profile name, else "default" in interactive mode
+	sessionName := flags.sessionFlag
+	if sessionName == "" && flags.profileFlag != "" {
+		sessionName = flags.profileFlag
+	}
+	if sessionName == "" && flags.interactiveFlag {
+		sessionName = "default"
+	}
+	var session *cliSession
+	if sessionName != "" {
+		var err error
+		if session, err = loadSession(sessionName); err != nil {
+			log.Fatalf("Invalid -session: %v", err)
+		}
+		if flags.newSessionFlag {
+			session.Messages = nil
+		}
+		session.Profile = flags.profileFlag
+		if len(session.Messages) > 0 {
+			fmt.Fprintf(os.Stderr, "[CLI Mode] Resuming session '%s' with %d earlier messages.\n", session.Name, len(session.Messages))
+		}
+	}
 
-	// Create LLM client for CLI mode using the factory
-	cliLLMConfig := llm.ClientConfig{
-		"apiURL":           apiURL, // Default API URL for LM Studio
-		"model":            flags.modelFlag,
-		"systemMessage":    cliSystemMessage, // System message for CLI mode
-		"maxContextLength": flags.maxContextLengthFlag,
-		// Google API key is now only read from GEMINI_API_KEY env var in NewGoogleClient
+	var history []llm.Message
+	if session != nil {
+		history = session.Messages
 	}
-	cliLLMClient, err := llm.NewClientFactory(flags.providerFlag, cliLLMConfig, make(map[string]string)) // Pass an empty map for env vars
-	if err != nil {
-		log.Fatalf("Failed to create LLM client for CLI mode: %v", err)
+	ctx := context.Background()
+	var runner interface {
+		Run(ctx context.Context, userPrompt string) cliResult
+		History() []llm.Message
+	}
+	if flags.agentURLFlag != "" {
+		runner = &cliRemoteAgent{url: flags.agentURLFlag, apiKey: profile.APIKey, client: &http.Client{}, history: history, out: status}
+	} else {
+		// Compose system prompt with all available tools, or the tools of the selected mode
+		cliSystemMessage := composeCliSystemMessage(availableToolsMap)
+		if flags.modeFlag != "" {
+			mode, ok := a2a.NewModeRegistry().Get(flags.modeFlag)
+			if !ok {
+				log.Fatalf("Unknown mode '%s'", flags.modeFlag)
+			}
+			cliSystemMessage = mode.SystemPrompt(availableToolsMap)
+			ctx = llm.WithGenerationOptions(ctx, mode.Generation)
+		}
+		if profile.SystemPrompt != "" {
+			cliSystemMessage = profile.SystemPrompt + "\n\n" + cliSystemMessage
+		}
+
+		// Create LLM client for CLI mode
+		cliLLMConfig, err := buildLLMConfig(strings.ToLower(flags.providerFlag), flags, cliSystemMessage)
+		if err != nil {
+			log.Fatalf("Failed to configure LLM client for CLI mode: %v", err)
+		}
+		cliLLMClient, err := llm.NewClient(cliLLMConfig)
+		if err != nil {
+			log.Fatalf("Failed to create LLM client for CLI mode: %v", err)
+		}
+		loop := newCLIToolLoop(cliLLMClient, cliSystemMessage, availableToolsMap, flags, stream, status)
+		loop.history = history
+		runner = loop
+	}
+
+	// runTurn answers one prompt and stores the session after it
+	runTurn := func(prompt string) cliResult {
+		result := runner.Run(ctx, prompt)
+		if flags.outputFlag == "json" {
+			data, _ := json.MarshalIndent(result, "", "  ")
+			fmt.Println(string(data))
+		} else if result.Error != "" {
+			fmt.Fprintln(os.Stderr, "LLM error:", result.Error)
+		}
+		if session != nil && result.Error == "" {
+			session.Messages = runner.History()
+			if err := session.save(); err != nil {
+				fmt.Fprintln(os.Stderr, "Warning: failed to save the session:", err)
+			}
+		}
+		return result
 	}
 
-	// Send prompt to LLM and handle response
-	sendPromptToLLM(cliLLMClient, cliSystemMessage, userPrompt, stream)
+	if !flags.interactiveFlag {
+		if result := runTurn(userPrompt); result.Error != "" {
+			os.Exit(1)
+		}
+		return
+	}
+	fmt.Fprintln(os.Stderr, "[CLI Mode] Interactive session. Type 'exit' or press Ctrl-D to quit.")
+	if userPrompt != "" {
+		runTurn(userPrompt)
+	}
+	input := bufio.NewReader(os.Stdin)
+	if loop, ok := runner.(*cliToolLoop); ok {
+		input = loop.input // Share the reader with tool confirmations
+	}
+	for {
+		fmt.Fprint(os.Stderr, "\n> ")
+		line, err := input.ReadString('\n')
+		prompt := strings.TrimSpace(line)
+		if prompt == "exit" || prompt == "quit" || (err != nil && prompt == "") {
+			fmt.Fprintln(os.Stderr)
+			return
+		}
+		if prompt != "" {
+			runTurn(prompt)
+		}
+	}
 }
 
 func warnAboutAuthFlags(jwtSecretFlag, apiKeysFlag string) {
@@ -318,32 +989,9 @@ func composeCliSystemMessage(availableToolsMap map[string]tools.Tool) string {
 	// In CLI mode, no MCP servers are selected, so pass an empty slice of McpServerConfig.
 	cliSystemMessage := tools.ComposeSystemPrompt(allToolNames, []tools.McpServerConfig{}, availableToolsMap)
 	log.Printf("[main] Composed CLI system message: %s", cliSystemMessage) // Added logging
-	fmt.Printf("[main] Using CLI system message:\n%s\n", cliSystemMessage)
 	return cliSystemMessage
 }
 
-func sendPromptToLLM(cliLLMClient llm.LLMClient, cliSystemMessage, userPrompt string, stream bool) {
-	messages := []llm.Message{
-		{Role: "system", Content: cliSystemMessage},
-		{Role: "user", Content: userPrompt},
-	}
-
-	fmt.Println("[main] Sending prompt to LLM...")
-	completion, inputTokens, completionTokens, err := cliLLMClient.Chat(context.Background(), messages, stream, os.Stdout)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, "LLM error:", err)
-		os.Exit(1)
-	}
-
-	// Log token usage
-	fmt.Fprintf(os.Stderr, "\n[CLI Mode] Input Tokens: %d, Completion Tokens: %d\n", inputTokens, completionTokens)
-
-	// Print final completion if not streaming
-	if !stream && completion != "" {
-		fmt.Println(completion)
-	}
-}
-
 func isTerminal(f *os.File) bool {
 	fileInfo, err := f.Stat()
 	if err != nil {
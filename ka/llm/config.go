@@ -0,0 +1,231 @@
+package llm
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Defaults shared by every place that builds an LLM client.
+const (
+	DefaultLMStudioAPIURL   = "http://localhost:1234/v1/chat/completions"
+	DefaultMaxContextLength = 8192 // Large enough to fit the long system prompt
+	DefaultGoogleModel      = "gemini-2.5-pro-preview-05-06"
+	DefaultOllamaURL        = "http://localhost:11434"
+)
+
+// ProviderConfig is the typed configuration of a single LLM provider.
+type ProviderConfig interface {
+	// Provider returns the provider type, e.g. "lmstudio".
+	Provider() string
+	// Validate fills in defaults and reports invalid settings.
+	Validate() error
+}
+
+// LMStudioConfig configures an OpenAI-compatible endpoint such as LM Studio.
+type LMStudioConfig struct {
+	APIURL           string
+	Model            string // May be empty; LM Studio then uses the loaded model
+	SystemMessage    string
+	MaxContextLength int
+	Timeouts         *Timeouts // nil uses DefaultTimeouts
+}
+
+func (c *LMStudioConfig) Provider() string { return "lmstudio" }
+
+func (c *LMStudioConfig) Validate() error {
+	if c.APIURL == "" {
+		c.APIURL = DefaultLMStudioAPIURL
+	}
+	if c.MaxContextLength == 0 {
+		c.MaxContextLength = DefaultMaxContextLength
+	}
+	parsed, err := url.Parse(c.APIURL)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return fmt.Errorf("lmstudio config: invalid APIURL %q (expected e.g. %s)", c.APIURL, DefaultLMStudioAPIURL)
+	}
+	if c.MaxContextLength < 0 {
+		return fmt.Errorf("lmstudio config: MaxContextLength must be positive, got %d", c.MaxContextLength)
+	}
+	return nil
+}
+
+// GoogleConfig configures the Google Gemini API.
+type GoogleConfig struct {
+	APIKey   string // Falls back to the GEMINI_API_KEY environment variable
+	Model    string
+	Timeouts *Timeouts // nil uses DefaultTimeouts
+}
+
+func (c *GoogleConfig) Provider() string { return "google" }
+
+func (c *GoogleConfig) Validate() error {
+	if c.APIKey == "" {
+		c.APIKey = os.Getenv("GEMINI_API_KEY")
+	}
+	if c.APIKey == "" {
+		return fmt.Errorf("google config: missing APIKey and GEMINI_API_KEY environment variable not set")
+	}
+	if c.Model == "" {
+		c.Model = DefaultGoogleModel
+	}
+	return nil
+}
+
+// OllamaConfig configures Ollama's native API.
+type OllamaConfig struct {
+	BaseURL   string
+	Model     string    // May be empty; the first locally available model is used
+	NumCtx    int       // 0 keeps Ollama's default context window
+	KeepAlive string    // e.g. "10m", or "-1" to keep the model loaded
+	Timeouts  *Timeouts // nil uses DefaultTimeouts
+}
+
+func (c *OllamaConfig) Provider() string { return "ollama" }
+
+func (c *OllamaConfig) Validate() error {
+	if c.BaseURL == "" {
+		c.BaseURL = DefaultOllamaURL
+	}
+	parsed, err := url.Parse(c.BaseURL)
+	if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+		return fmt.Errorf("ollama config: invalid BaseURL %q (expected e.g. %s)", c.BaseURL, DefaultOllamaURL)
+	}
+	if c.NumCtx < 0 {
+		return fmt.Errorf("ollama config: NumCtx must be positive, got %d", c.NumCtx)
+	}
+	return nil
+}
+
+// NewClient validates the typed configuration and creates the matching LLMClient.
+func NewClient(config ProviderConfig) (LLMClient, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	switch c := config.(type) {
+	case *LMStudioConfig:
+		client, err := NewLMStudioClient(c.APIURL, c.Model, c.SystemMessage, c.MaxContextLength)
+		if err != nil {
+			return nil, err
+		}
+		if c.Timeouts != nil {
+			client.Timeouts = *c.Timeouts
+		}
+		return client, nil
+	case *GoogleConfig:
+		client, err := NewGoogleClient(c.APIKey, c.Model)
+		if err != nil {
+			return nil, err
+		}
+		if c.Timeouts != nil {
+			client.Timeouts = *c.Timeouts
+		}
+		return client, nil
+	case *OllamaConfig:
+		client := NewOllamaClient(c.BaseURL, c.Model, c.NumCtx, c.KeepAlive)
+		if c.Timeouts != nil {
+			client.Timeouts = *c.Timeouts
+		}
+		return client, nil
+	default:
+		return nil, fmt.Errorf("unsupported LLM provider type: %s", config.Provider())
+	}
+}
+
+// configKeys lists the keys accepted in the map form of each provider's config.
+var configKeys = map[string]map[string]bool{
+	"lmstudio": {"apiURL": true, "model": true, "systemMessage": true, "maxContextLength": true},
+	"google":   {"apiKey": true, "model": true},
+	"ollama":   {"baseURL": true, "model": true, "numCtx": true, "keepAlive": true},
+}
+
+// ToProviderConfig converts the legacy map form into a typed config.
+// Unknown keys and values of the wrong type are reported instead of being ignored.
+func (c ClientConfig) ToProviderConfig(providerType string, envVars map[string]string) (ProviderConfig, error) {
+	allowed, ok := configKeys[providerType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported LLM provider type: %s", providerType)
+	}
+	for key := range c {
+		if !allowed[key] {
+			return nil, fmt.Errorf("%s config: unknown key %q (valid keys: %s)", providerType, key, strings.Join(sortedKeys(allowed), ", "))
+		}
+	}
+
+	switch providerType {
+	case "lmstudio":
+		config := &LMStudioConfig{}
+		if err := c.readString(providerType, "apiURL", &config.APIURL); err != nil {
+			return nil, err
+		}
+		if err := c.readString(providerType, "model", &config.Model); err != nil {
+			return nil, err
+		}
+		if err := c.readString(providerType, "systemMessage", &config.SystemMessage); err != nil {
+			return nil, err
+		}
+		if value, ok := c["maxContextLength"]; ok {
+			length, ok := value.(int)
+			if !ok {
+				return nil, fmt.Errorf("%s config: key \"maxContextLength\" must be an int, got %T", providerType, value)
+			}
+			config.MaxContextLength = length
+		}
+		return config, nil
+	case "ollama":
+		config := &OllamaConfig{}
+		if err := c.readString(providerType, "baseURL", &config.BaseURL); err != nil {
+			return nil, err
+		}
+		if err := c.readString(providerType, "model", &config.Model); err != nil {
+			return nil, err
+		}
+		if err := c.readString(providerType, "keepAlive", &config.KeepAlive); err != nil {
+			return nil, err
+		}
+		if value, ok := c["numCtx"]; ok {
+			numCtx, ok := value.(int)
+			if !ok {
+				return nil, fmt.Errorf("%s config: key \"numCtx\" must be an int, got %T", providerType, value)
+			}
+			config.NumCtx = numCtx
+		}
+		return config, nil
+	default: // "google"
+		config := &GoogleConfig{}
+		if err := c.readString(providerType, "apiKey", &config.APIKey); err != nil {
+			return nil, err
+		}
+		if err := c.readString(providerType, "model", &config.Model); err != nil {
+			return nil, err
+		}
+		if config.APIKey == "" {
+			config.APIKey = envVars["GEMINI_API_KEY"]
+		}
+		return config, nil
+	}
+}
+
+func (c ClientConfig) readString(providerType, key string, target *string) error {
+	value, ok := c[key]
+	if !ok {
+		return nil
+	}
+	s, ok := value.(string)
+	if !ok {
+		return fmt.Errorf("%s config: key %q must be a string, got %T", providerType, key, value)
+	}
+	*target = s
+	return nil
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,41 @@
+package llm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClientConfig_UnknownKeyIsRejected(t *testing.T) {
+	config := ClientConfig{"apiUrl": "http://localhost:1234/v1/chat/completions"}
+	_, err := config.ToProviderConfig("lmstudio", nil)
+	if err == nil || !strings.Contains(err.Error(), "apiUrl") {
+		t.Fatalf("Expected unknown key error mentioning apiUrl, got %v", err)
+	}
+}
+
+func TestClientConfig_WrongTypeIsRejected(t *testing.T) {
+	config := ClientConfig{"maxContextLength": "8192"}
+	if _, err := config.ToProviderConfig("lmstudio", nil); err == nil {
+		t.Fatalf("Expected error for string maxContextLength")
+	}
+}
+
+func TestLMStudioConfig_ValidateAppliesDefaults(t *testing.T) {
+	config := &LMStudioConfig{}
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+	if config.APIURL != DefaultLMStudioAPIURL {
+		t.Errorf("Expected default API URL, got %s", config.APIURL)
+	}
+	if config.MaxContextLength != DefaultMaxContextLength {
+		t.Errorf("Expected default max context length, got %d", config.MaxContextLength)
+	}
+}
+
+func TestLMStudioConfig_InvalidURL(t *testing.T) {
+	config := &LMStudioConfig{APIURL: "localhost:1234"}
+	if err := config.Validate(); err == nil {
+		t.Fatalf("Expected error for URL without scheme")
+	}
+}
@@ -0,0 +1,119 @@
+package llm
+
+import (
+	"context"
+	"io"
+	"strings"
+	"time"
+)
+
+// FinishReasonLength is the normalized finish reason of completions cut off
+// by the max output token limit.
+const FinishReasonLength = "length"
+
+// ContinuationPrompt asks the model to resume a completion that was cut off.
+const ContinuationPrompt = "Your previous response was cut off by the output token limit. Continue exactly where it stopped, without repeating anything or adding a preamble."
+
+// ResponseInfo describes how a completion ended. Clients fill in the
+// ResponseInfo found in the context of a Chat call, see WithResponseInfo.
+type ResponseInfo struct {
+	FinishReason  string // Normalized finish reason, e.g. "stop" or "length"; empty when unknown
+	Continuations int    // Continuation requests made by ChatWithContinuation
+
+	// The provider and the model and sampling parameters actually sent
+	Provider    string
+	Model       string
+	Temperature *float64      // Nil when the provider's default applied
+	MaxTokens   int           // 0 when unlimited
+	Latency     time.Duration // Of all requests of ChatWithContinuation
+}
+
+// Truncated reports whether the completion was cut off by the token limit.
+func (i *ResponseInfo) Truncated() bool {
+	return i != nil && i.FinishReason == FinishReasonLength
+}
+
+type responseInfoKey struct{}
+
+// WithResponseInfo returns a context whose Chat calls record how the
+// completion ended in info.
+func WithResponseInfo(ctx context.Context, info *ResponseInfo) context.Context {
+	return context.WithValue(ctx, responseInfoKey{}, info)
+}
+
+// ResponseInfoFrom returns the ResponseInfo of ctx, or nil.
+func ResponseInfoFrom(ctx context.Context) *ResponseInfo {
+	info, _ := ctx.Value(responseInfoKey{}).(*ResponseInfo)
+	return info
+}
+
+// recordFinishReason stores the finish reason of a completion in the
+// ResponseInfo of ctx, if any.
+func recordFinishReason(ctx context.Context, reason string) {
+	if info := ResponseInfoFrom(ctx); info != nil {
+		info.FinishReason = reason
+	}
+}
+
+// recordRequest stores the provider, model and sampling parameters of a
+// request in the ResponseInfo of ctx, if any.
+func recordRequest(ctx context.Context, provider, model string, temperature *float64, maxTokens int) {
+	if info := ResponseInfoFrom(ctx); info != nil {
+		info.Provider, info.Model, info.Temperature, info.MaxTokens = provider, model, temperature, max(maxTokens, 0)
+	}
+}
+
+// normalizeFinishReason maps provider specific finish reasons to the
+// OpenAI names.
+func normalizeFinishReason(reason string) string {
+	switch reason {
+	case "MAX_TOKENS":
+		return FinishReasonLength
+	case "STOP":
+		return "stop"
+	}
+	return strings.ToLower(reason)
+}
+
+// ChatWithContinuation calls client.Chat and, while the completion is cut off
+// by the token limit, asks the model to continue up to MaxContinuations
+// times (see GenerationOptions). The pieces are stitched into one completion
+// and streamed to out as they arrive; token counts are summed over all
+// requests. The ResponseInfo of ctx, if any, receives the finish reason of
+// the last piece, so Truncated reports whether the answer is still incomplete.
+func ChatWithContinuation(ctx context.Context, client LLMClient, messages []Message, stream bool, out io.Writer) (string, int, int, error) {
+	maxContinuations := generationOptionsFrom(ctx).MaxContinuations
+	var stitched strings.Builder
+	var inputTokens, completionTokens int
+	info := &ResponseInfo{}
+	start := time.Now()
+	for {
+		request := messages
+		if info.Continuations > 0 {
+			request = append(append([]Message{}, messages...),
+				Message{Role: "assistant", Content: stitched.String()},
+				Message{Role: "user", Content: ContinuationPrompt})
+		}
+		piece := &ResponseInfo{}
+		text, in, completion, err := client.Chat(WithResponseInfo(ctx, piece), request, stream, out)
+		inputTokens += in
+		completionTokens += completion
+		if err != nil {
+			return stitched.String() + text, inputTokens, completionTokens, err
+		}
+		stitched.WriteString(text)
+		continuations := info.Continuations
+		*info = *piece
+		info.Continuations = continuations
+		// An empty piece makes no progress; continuing again would loop
+		if !piece.Truncated() || text == "" || info.Continuations >= maxContinuations {
+			break
+		}
+		info.Continuations++
+	}
+	info.Latency = time.Since(start)
+	if outer := ResponseInfoFrom(ctx); outer != nil {
+		*outer = *info
+	}
+	return stitched.String(), inputTokens, completionTokens, nil
+}
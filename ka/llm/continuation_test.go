@@ -0,0 +1,95 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// pieceServer streams one piece per request, cut off by the token limit
+// until the last one.
+func pieceServer(t *testing.T, pieces []string, requests *[]Request) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		json.NewDecoder(r.Body).Decode(&req)
+		*requests = append(*requests, req)
+		i := len(*requests) - 1
+		if i >= len(pieces) {
+			t.Errorf("unexpected request %d", i+1)
+			return
+		}
+		reason := "length"
+		if i == len(pieces)-1 {
+			reason = "stop"
+		}
+		fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{\"content\":%q}}]}\n\n", pieces[i])
+		fmt.Fprintf(w, "data: {\"choices\":[{\"delta\":{},\"finish_reason\":%q}],\"usage\":{\"prompt_tokens\":10,\"completion_tokens\":4}}\n\n", reason)
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+}
+
+func TestChatWithContinuationStitchesPieces(t *testing.T) {
+	var requests []Request
+	server := pieceServer(t, []string{"The quick brown", " fox jumps"}, &requests)
+	defer server.Close()
+
+	client := &LMStudioClient{APIURL: server.URL, Model: "test", MaxContextLength: 1000}
+	info := &ResponseInfo{}
+	ctx := WithResponseInfo(WithGenerationOptions(context.Background(), GenerationOptions{MaxTokens: 4, MaxContinuations: 2}), info)
+	var out strings.Builder
+	text, inputTokens, completionTokens, err := ChatWithContinuation(ctx, client, []Message{{Role: "user", Content: "hi"}}, true, &out)
+	if err != nil {
+		t.Fatalf("ChatWithContinuation: %v", err)
+	}
+	if text != "The quick brown fox jumps" || !strings.Contains(out.String(), " fox jumps") {
+		t.Errorf("unexpected completion %q, streamed %q", text, out.String())
+	}
+	if inputTokens != 20 || completionTokens != 8 {
+		t.Errorf("expected tokens summed over both requests, got %d/%d", inputTokens, completionTokens)
+	}
+	if info.Truncated() || info.Continuations != 1 {
+		t.Errorf("expected one continuation and a complete answer, got %+v", info)
+	}
+	if info.Provider != "lmstudio" || info.Model != "test" || info.MaxTokens != 4 {
+		t.Errorf("expected the request parameters to be recorded, got %+v", info)
+	}
+	if len(requests) != 2 || requests[0].MaxTokens != 4 {
+		t.Fatalf("expected two requests limited to 4 tokens, got %+v", requests)
+	}
+	continued := requests[1].Messages
+	if len(continued) != 3 || continued[1].Role != "assistant" || continued[1].Content != "The quick brown" || continued[2].Content != ContinuationPrompt {
+		t.Errorf("unexpected continuation messages %+v", continued)
+	}
+}
+
+func TestChatWithContinuationReportsTruncation(t *testing.T) {
+	var requests []Request
+	server := pieceServer(t, []string{"cut", "never sent"}, &requests)
+	defer server.Close()
+
+	client := &LMStudioClient{APIURL: server.URL, Model: "test", MaxContextLength: 1000}
+	info := &ResponseInfo{}
+	text, _, _, err := ChatWithContinuation(WithResponseInfo(context.Background(), info), client, []Message{{Role: "user", Content: "hi"}}, true, &strings.Builder{})
+	if err != nil {
+		t.Fatalf("ChatWithContinuation: %v", err)
+	}
+	if text != "cut" || len(requests) != 1 {
+		t.Errorf("expected no continuation without MaxContinuations, got %q after %d requests", text, len(requests))
+	}
+	if !info.Truncated() {
+		t.Errorf("expected the answer to be reported as truncated, got %+v", info)
+	}
+}
+
+func TestNormalizeFinishReason(t *testing.T) {
+	if got := normalizeFinishReason("MAX_TOKENS"); got != FinishReasonLength {
+		t.Errorf("expected Gemini's MAX_TOKENS to map to %q, got %q", FinishReasonLength, got)
+	}
+	if got := normalizeFinishReason("STOP"); got != "stop" {
+		t.Errorf("expected stop, got %q", got)
+	}
+}
@@ -0,0 +1,271 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// NetworkAgents is the key of the NetworkConfigs entry of requests to other
+// A2A agents, e.g. fetching the threads of linked remote tasks.
+const NetworkAgents = "agents"
+
+// ErrEgressDenied is returned for requests the egress policy refuses.
+var ErrEgressDenied = errors.New("blocked by the egress policy")
+
+// metadataHosts are cloud metadata endpoints reachable by name.
+var metadataHosts = map[string]bool{"metadata.google.internal": true, "metadata.goog": true, "metadata": true}
+
+// metadataNets are cloud metadata addresses outside the link-local ranges.
+var metadataNets = mustParseCIDRs("fd00:ec2::254/128", "100.100.100.200/32")
+
+// EgressPolicy restricts the destinations of outbound requests made on
+// behalf of the LLM: fetch_url, webhook deliveries and requests to other
+// agents. URLs in prompts cannot be trusted, so the policy keeps tools from
+// reaching internal services (SSRF). The zero value blocks link-local and
+// cloud metadata addresses and allows everything else; policies with
+// ranges are created with LoadEgressPolicy.
+type EgressPolicy struct {
+	AllowDomains   []string `json:"allowDomains,omitempty"`   // When set with allowCidrs, a destination must match one of the two lists
+	DenyDomains    []string `json:"denyDomains,omitempty"`    // A domain matches itself and its subdomains
+	AllowCIDRs     []string `json:"allowCidrs,omitempty"`     // Ranges, or single addresses, destinations may resolve to
+	DenyCIDRs      []string `json:"denyCidrs,omitempty"`      // Ranges never connected to, e.g. 10.0.0.0/8
+	AllowLinkLocal bool     `json:"allowLinkLocal,omitempty"` // Permit link-local and cloud metadata addresses, blocked by default
+
+	allowNets, denyNets []*net.IPNet
+}
+
+// LoadEgressPolicy reads the policy from a JSON object, given inline or as
+// a file path.
+func LoadEgressPolicy(config string) (*EgressPolicy, error) {
+	data := []byte(config)
+	if !strings.HasPrefix(strings.TrimSpace(config), "{") {
+		fileData, err := os.ReadFile(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read egress policy file %s: %w", config, err)
+		}
+		data = fileData
+	}
+	var policy EgressPolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse egress policy: %w", err)
+	}
+	if err := policy.load(); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// load parses the CIDRs and normalizes the domains.
+func (p *EgressPolicy) load() error {
+	var err error
+	if p.allowNets, err = parseCIDRs(p.AllowCIDRs); err != nil {
+		return fmt.Errorf("invalid allowCidrs: %w", err)
+	}
+	if p.denyNets, err = parseCIDRs(p.DenyCIDRs); err != nil {
+		return fmt.Errorf("invalid denyCidrs: %w", err)
+	}
+	for _, domains := range []*[]string{&p.AllowDomains, &p.DenyDomains} {
+		for i, domain := range *domains {
+			(*domains)[i] = normalizeHost(strings.TrimPrefix(strings.TrimPrefix(domain, "*"), "."))
+		}
+	}
+	return nil
+}
+
+// restricted reports whether destinations must be on an allowlist.
+func (p *EgressPolicy) restricted() bool {
+	return len(p.AllowDomains) > 0 || len(p.allowNets) > 0
+}
+
+// checkHost applies the domain rules to the host of a URL and reports
+// whether the host is on the domain allowlist. IP literals are checked too.
+func (p *EgressPolicy) checkHost(host string) (bool, error) {
+	host = normalizeHost(host)
+	if !p.AllowLinkLocal && metadataHosts[host] {
+		return false, fmt.Errorf("%w: %s is a cloud metadata endpoint", ErrEgressDenied, host)
+	}
+	for _, domain := range p.DenyDomains {
+		if domainMatches(host, domain) {
+			return false, fmt.Errorf("%w: %s is denied (%s)", ErrEgressDenied, host, domain)
+		}
+	}
+	allowed := false
+	for _, domain := range p.AllowDomains {
+		if domainMatches(host, domain) {
+			allowed = true
+			break
+		}
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return allowed, p.checkIP(ip, host, allowed)
+	}
+	if p.restricted() && !allowed && len(p.allowNets) == 0 {
+		return false, fmt.Errorf("%w: %s is not in the allowed domains", ErrEgressDenied, host)
+	}
+	return allowed, nil
+}
+
+// checkIP applies the address rules to an address host resolved to.
+// Link-local, metadata and denied addresses are refused even for allowed
+// domains, so a name cannot be pointed at them.
+func (p *EgressPolicy) checkIP(ip net.IP, host string, domainAllowed bool) error {
+	if ip.IsUnspecified() {
+		// Connecting to 0.0.0.0 or :: reaches the local host
+		if ip.To4() != nil {
+			ip = net.IPv4(127, 0, 0, 1)
+		} else {
+			ip = net.IPv6loopback
+		}
+	}
+	if !p.AllowLinkLocal && (ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || containsIP(metadataNets, ip)) {
+		return fmt.Errorf("%w: %s resolves to the link-local or cloud metadata address %s", ErrEgressDenied, host, ip)
+	}
+	if containsIP(p.denyNets, ip) {
+		return fmt.Errorf("%w: %s resolves to the denied address %s", ErrEgressDenied, host, ip)
+	}
+	if p.restricted() && !domainAllowed && !containsIP(p.allowNets, ip) {
+		return fmt.Errorf("%w: %s (%s) is not in the allowed domains or ranges", ErrEgressDenied, host, ip)
+	}
+	return nil
+}
+
+// Check reports whether a request to host may be made, resolving the host
+// to check its addresses. Requests through Wrap are checked anyway; tools
+// can call it to refuse a destination before doing any work.
+func (p *EgressPolicy) Check(ctx context.Context, host string) error {
+	domainAllowed, err := p.checkHost(host)
+	if err != nil || net.ParseIP(host) != nil {
+		return err
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil // Unresolvable here; the request fails on its own or goes through a proxy
+	}
+	for _, addr := range addrs {
+		if err := p.checkIP(addr.IP, host, domainAllowed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type egressContextKey struct{}
+
+// egressDestination is the checked destination of a request, passed to the
+// dialer so it can check the address it actually connects to.
+type egressDestination struct {
+	host          string
+	domainAllowed bool
+}
+
+// Wrap enforces the policy on the requests sent through transport. The
+// addresses are checked when connecting, after name resolution, so a name
+// cannot resolve differently between the check and the connection. With a
+// proxy, the proxy connects to the destination: its name is resolved and
+// checked beforehand instead.
+func (p *EgressPolicy) Wrap(transport *http.Transport) http.RoundTripper {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	dial := transport.DialContext
+	if dial == nil {
+		dial = dialer.DialContext
+	}
+	transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		destination, ok := ctx.Value(egressContextKey{}).(egressDestination)
+		if !ok {
+			return dial(ctx, network, address) // A proxy connection
+		}
+		checked := *dialer
+		checked.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			return p.checkIP(net.ParseIP(host), destination.host, destination.domainAllowed)
+		}
+		return checked.DialContext(ctx, network, address)
+	}
+	return &egressTransport{policy: p, transport: transport}
+}
+
+type egressTransport struct {
+	policy    *EgressPolicy
+	transport *http.Transport
+}
+
+func (t *egressTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := req.URL.Hostname()
+	if t.transport.Proxy != nil {
+		if proxyURL, err := t.transport.Proxy(req); err == nil && proxyURL != nil {
+			if err := t.policy.Check(req.Context(), host); err != nil {
+				return nil, err
+			}
+			return t.transport.RoundTrip(req)
+		}
+	}
+	domainAllowed, err := t.policy.checkHost(host)
+	if err != nil {
+		return nil, err
+	}
+	ctx := context.WithValue(req.Context(), egressContextKey{}, egressDestination{host: host, domainAllowed: domainAllowed})
+	return t.transport.RoundTrip(req.WithContext(ctx))
+}
+
+func normalizeHost(host string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(host), "."))
+}
+
+// domainMatches reports whether host is domain or one of its subdomains.
+func domainMatches(host, domain string) bool {
+	return domain != "" && (host == domain || strings.HasSuffix(host, "."+domain))
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCIDRs parses ranges; single addresses are taken as ranges of one.
+func parseCIDRs(values []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("%q is neither a CIDR range nor an IP address", value)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+func mustParseCIDRs(values ...string) []*net.IPNet {
+	nets, err := parseCIDRs(values)
+	if err != nil {
+		panic(err)
+	}
+	return nets
+}
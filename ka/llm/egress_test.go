@@ -0,0 +1,83 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestEgressPolicyChecksConnectedAddress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	port := server.URL[strings.LastIndex(server.URL, ":"):]
+
+	for _, tc := range []struct {
+		policy  string
+		host    string
+		allowed bool
+	}{
+		{`{}`, "127.0.0.1", true},
+		{`{"denyCidrs": ["127.0.0.0/8"]}`, "127.0.0.1", false},
+		{`{"denyCidrs": ["127.0.0.0/8"]}`, "localhost", false}, // Checked after name resolution
+		{`{"denyCidrs": ["127.0.0.0/8"]}`, "0.0.0.0", false},
+		{`{"allowDomains": ["example.com"]}`, "127.0.0.1", false},
+		{`{"allowDomains": ["example.com"], "allowCidrs": ["127.0.0.1"]}`, "127.0.0.1", true},
+		{`{"allowDomains": ["localhost"]}`, "localhost", true},
+		{`{"denyDomains": ["localhost"]}`, "localhost", false},
+	} {
+		policy, err := LoadEgressPolicy(tc.policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		client := &http.Client{Transport: policy.Wrap(NetworkConfig{}.Transport())}
+		resp, err := client.Get("http://" + tc.host + port)
+		if err == nil {
+			resp.Body.Close()
+		}
+		if allowed := err == nil; allowed != tc.allowed {
+			t.Errorf("%s with %s: expected allowed=%v, got %v", tc.host, tc.policy, tc.allowed, err)
+		}
+		if err != nil && !errors.Is(err, ErrEgressDenied) {
+			t.Errorf("%s with %s: expected ErrEgressDenied, got %v", tc.host, tc.policy, err)
+		}
+	}
+}
+
+func TestEgressPolicyBlocksMetadataByDefault(t *testing.T) {
+	policy := &EgressPolicy{}
+	for _, host := range []string{"169.254.169.254", "metadata.google.internal", "fe80::1", "fd00:ec2::254"} {
+		if err := policy.Check(context.Background(), host); !errors.Is(err, ErrEgressDenied) {
+			t.Errorf("expected %s to be blocked, got %v", host, err)
+		}
+	}
+	if err := (&EgressPolicy{AllowLinkLocal: true}).Check(context.Background(), "169.254.169.254"); err != nil {
+		t.Errorf("expected allowLinkLocal to permit link-local addresses: %v", err)
+	}
+	if _, err := LoadEgressPolicy(`{"denyCidrs": ["10.0.0.0/33"]}`); err == nil {
+		t.Error("expected an invalid range to be rejected")
+	}
+}
+
+func TestEgressPolicyChecksDestinationBehindProxy(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+	transport := NetworkConfig{}.Transport()
+	transport.Proxy = http.ProxyURL(proxyURL)
+	// The proxy itself is on a denied range; only the destination is checked
+	policy, _ := LoadEgressPolicy(`{"denyCidrs": ["127.0.0.0/8"], "denyDomains": ["internal.test"]}`)
+	client := &http.Client{Transport: policy.Wrap(transport)}
+
+	if resp, err := client.Get("http://public.test/"); err != nil {
+		t.Errorf("expected the request through the proxy to pass: %v", err)
+	} else {
+		resp.Body.Close()
+	}
+	if _, err := client.Get("http://internal.test/"); !errors.Is(err, ErrEgressDenied) {
+		t.Errorf("expected the denied domain to be blocked, got %v", err)
+	}
+}
@@ -11,10 +11,14 @@ import (
 	"os" // Import os to get API key from environment variable
 )
 
+// googleAPIBase is the origin of the Gemini API.
+const googleAPIBase = "https://generativelanguage.googleapis.com"
+
 // GoogleClient implements the LLMClient interface for the Google Gemini API.
 type GoogleClient struct {
-	APIKey string
-	Model  string
+	APIKey   string
+	Model    string
+	Timeouts Timeouts
 	// Google API handles system instructions differently, often as a separate field in the request
 	// or as the first message with a specific role. We'll need to check their docs.
 	// For now, we won't include a SystemMessage field here, and will handle it in the Chat method.
@@ -34,12 +38,13 @@ func NewGoogleClient(apiKey, model string) (*GoogleClient, error) {
 	}
 
 	if model == "" {
-		model = "gemini-2.5-pro-preview-05-06" // Default model if not provided
+		model = DefaultGoogleModel // Default model if not provided
 	}
 
 	return &GoogleClient{
-		APIKey: apiKey,
-		Model:  model,
+		APIKey:   apiKey,
+		Model:    model,
+		Timeouts: DefaultTimeouts(),
 	}, nil
 }
 
@@ -74,9 +79,16 @@ func (c *GoogleClient) Chat(ctx context.Context, messages []Message, stream bool
 	// Construct the request body
 	requestBody := map[string]interface{}{
 		"contents": googleContents,
-		// Add other parameters like temperature, max_tokens if needed and supported by the interface
-		// "temperature": 0.6, // Example
-		// "max_tokens": 100, // Example
+	}
+	if opts := generationOptionsFrom(ctx); opts.Temperature != nil || opts.MaxTokens > 0 {
+		generationConfig := map[string]interface{}{}
+		if opts.Temperature != nil {
+			generationConfig["temperature"] = *opts.Temperature
+		}
+		if opts.MaxTokens > 0 {
+			generationConfig["maxOutputTokens"] = opts.MaxTokens
+		}
+		requestBody["generationConfig"] = generationConfig
 	}
 
 	payload, err := json.Marshal(requestBody)
@@ -86,21 +98,30 @@ func (c *GoogleClient) Chat(ctx context.Context, messages []Message, stream bool
 
 	// Construct the API URL with the model and API key
 	// The model is part of the URL path for Google API
-	apiURL := fmt.Sprintf("https://generativelanguage.googleapis.com/v1beta/models/%s:generateContent?key=%s", c.Model, c.APIKey)
+	model := c.Model
+	if opts := generationOptionsFrom(ctx); opts.Model != "" {
+		model = opts.Model
+	}
+	opts := generationOptionsFrom(ctx)
+	recordRequest(ctx, "google", model, opts.Temperature, opts.MaxTokens)
+	apiURL := fmt.Sprintf("%s/v1beta/models/%s:generateContent?key=%s", googleAPIBase, model, c.APIKey)
 
 	fmt.Printf("Sending request to Google API (%s) with payload: %s\n", apiURL, string(payload))
 
-	// Create and send HTTP request
-	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(payload))
+	// Create and send HTTP request. The response is not streamed, so only
+	// the deadline of ctx bounds the generation.
+	reqCtx, watchdog := newTokenWatchdog(ctx, c.Timeouts, false)
+	defer watchdog.Stop()
+	req, err := http.NewRequestWithContext(reqCtx, "POST", apiURL, bytes.NewBuffer(payload))
 	if err != nil {
 		return "", 0, 0, fmt.Errorf("failed to create HTTP request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
+	client := newHTTPClient("google", c.Timeouts)
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", 0, 0, fmt.Errorf("failed to send HTTP request: %w", err)
+		return "", 0, 0, fmt.Errorf("failed to send HTTP request: %w", watchdog.Err(err))
 	}
 	defer resp.Body.Close()
 
@@ -143,12 +164,14 @@ func (c *GoogleClient) Chat(ctx context.Context, messages []Message, stream bool
 					Text string `json:"text"`
 				} `json:"parts"`
 			} `json:"content"`
+			FinishReason string `json:"finishReason"`
 		} `json:"candidates"`
+		UsageMetadata *geminiUsage `json:"usageMetadata"`
 	}
 
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", 0, 0, fmt.Errorf("failed to read response body: %w", err)
+		return "", 0, 0, fmt.Errorf("failed to read response body: %w", watchdog.Err(err))
 	}
 
 	fmt.Printf("Google API Response Body: %s\n", string(bodyBytes))
@@ -161,16 +184,27 @@ func (c *GoogleClient) Chat(ctx context.Context, messages []Message, stream bool
 	if len(googleResponse.Candidates) > 0 && len(googleResponse.Candidates[0].Content.Parts) > 0 {
 		completionText = googleResponse.Candidates[0].Content.Parts[0].Text
 	}
+	if len(googleResponse.Candidates) > 0 {
+		recordFinishReason(ctx, normalizeFinishReason(googleResponse.Candidates[0].FinishReason)) // MAX_TOKENS when cut off
+	}
 
 	// Write the completion text to the output writer
 	if _, writeErr := out.Write([]byte(completionText)); writeErr != nil {
 		log.Printf("Error writing Google API completion to output: %v", writeErr)
 	}
 
-	// Token counting for Google API requires calling a separate countTokens endpoint.
-	// For now, we will return 0 for token counts. This needs to be implemented later.
-	inputTokens := 0
-	completionTokens := 0
+	// Gemini reports usage in usageMetadata. Without it the counts are
+	// estimated with the tokenizer the registry picks for the model.
+	var inputTokens, completionTokens int
+	if usage := googleResponse.UsageMetadata.toUsage(); usage != nil {
+		inputTokens = usage.PromptTokens
+		completionTokens = usage.CompletionTokens
+	} else if tokenizer := c.Tokenizer(); tokenizer != nil {
+		for _, msg := range messages {
+			inputTokens += tokenizer.CountTokens(msg.Content)
+		}
+		completionTokens = tokenizer.CountTokens(completionText)
+	}
 
 	return completionText, inputTokens, completionTokens, nil
 }
@@ -2,9 +2,8 @@ package llm
 
 import (
 	"context"
-	"fmt"
 	"io"
-	"os" // Import os package
+	"time"
 )
 
 type Message struct {
@@ -18,6 +17,8 @@ type Request struct {
 	Temperature float32   `json:"temperature"`
 	MaxTokens   int       `json:"max_tokens"`
 	Stream      bool      `json:"stream"`
+	// StreamOptions is only sent with streaming requests.
+	StreamOptions *StreamOptions `json:"stream_options,omitempty"`
 }
 
 // LLMClient interface
@@ -26,50 +27,56 @@ type LLMClient interface {
 	// Add other common methods here if needed universally
 }
 
-// ClientConfig holds configuration parameters for creating an LLMClient.
-// Use a map for flexibility to support provider-specific parameters.
+// ClientConfig is the legacy map form of the client configuration.
+// Prefer the typed configs (LMStudioConfig, GoogleConfig) with NewClient.
 type ClientConfig map[string]interface{}
 
 // NewClientFactory creates a new LLMClient based on the provider type, configuration, and environment variables.
+// It is kept for compatibility and converts the map into a typed config first.
 func NewClientFactory(providerType string, config ClientConfig, envVars map[string]string) (LLMClient, error) {
-	switch providerType {
-	case "lmstudio":
-		// Extract parameters for LMStudioClient from the config map
-		apiURL, ok := config["apiURL"].(string)
-		if !ok {
-			return nil, fmt.Errorf("lmstudio config missing or invalid apiURL")
-		}
-		model, ok := config["model"].(string)
-		if !ok {
-			return nil, fmt.Errorf("lmstudio config missing or invalid model")
-		}
-		systemMessage, _ := config["systemMessage"].(string) // SystemMessage is optional
-		maxContextLength, _ := config["maxContextLength"].(int) // maxContextLength is optional
+	providerConfig, err := config.ToProviderConfig(providerType, envVars)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(providerConfig)
+}
 
-		return NewLMStudioClient(apiURL, model, systemMessage, maxContextLength)
+// ModelInfo describes a model available on a provider's backend.
+type ModelInfo struct {
+	Name          string    `json:"name"`
+	Family        string    `json:"family,omitempty"`
+	ParameterSize string    `json:"parameterSize,omitempty"`
+	Quantization  string    `json:"quantization,omitempty"`
+	SizeBytes     int64     `json:"sizeBytes,omitempty"`
+	ModifiedAt    time.Time `json:"modifiedAt,omitempty"`
+}
 
-	case "google":
-		// Extract parameters for GoogleClient from the config map
-		apiKey, ok := config["apiKey"].(string)
-		if !ok {
-			// Check provided environment variables first
-			apiKey, ok = envVars["GEMINI_API_KEY"]
-			if !ok {
-				// Fallback to process environment variables
-				apiKey = os.Getenv("GEMINI_API_KEY")
-				if apiKey == "" {
-					return nil, fmt.Errorf("google config missing apiKey and GEMINI_API_KEY environment variable not set")
-				}
-			}
-		}
-		model, ok := config["model"].(string)
-		if !ok {
-			return nil, fmt.Errorf("google config missing or invalid model")
-		}
+// ModelLister is implemented by clients that can list the models available
+// on their backend, such as OllamaClient.
+type ModelLister interface {
+	ListModels(ctx context.Context) ([]ModelInfo, error)
+}
 
-		return NewGoogleClient(apiKey, model)
+// ListModels lists the models of the client's backend, unwrapping clients
+// such as the retrying one. ok is false when the provider cannot list them.
+func ListModels(ctx context.Context, client LLMClient) (models []ModelInfo, ok bool, err error) {
+	lister, ok := innermostClient(client).(ModelLister)
+	if !ok {
+		return nil, false, nil
+	}
+	models, err = lister.ListModels(ctx)
+	return models, true, err
+}
 
-	default:
-		return nil, fmt.Errorf("unsupported LLM provider type: %s", providerType)
+// CurrentModel returns the model the client's requests go to, for clients
+// that resolve it from their backend when none is configured. It returns ""
+// for other clients.
+func CurrentModel(ctx context.Context, client LLMClient) (string, error) {
+	resolver, ok := innermostClient(client).(interface {
+		CurrentModel(ctx context.Context) (string, error)
+	})
+	if !ok {
+		return "", nil
 	}
+	return resolver.CurrentModel(ctx)
 }
@@ -10,8 +10,6 @@ import (
 	"log" // Added for logging warnings/errors
 	"net/http"
 	"strings"
-
-	"github.com/pkoukk/tiktoken-go"
 )
 
 type LMStudioClient struct {
@@ -19,27 +17,23 @@ type LMStudioClient struct {
 	Model            string
 	SystemMessage    string // Added SystemMessage field
 	MaxContextLength int
-	tokenizer        *tiktoken.Tiktoken
+	Timeouts         Timeouts
+	tokenizer        Tokenizer
+	tokenizerErr     error // Why the model's own tokenizer is not used, if it is not
 }
 
 func NewLMStudioClient(apiURL, model, systemMessage string, maxContextLength int) (*LMStudioClient, error) { // Added systemMessage parameter
-	// Attempt to get the encoding for the specific model.
-	tkm, err := tiktoken.EncodingForModel(model)
-	if err != nil {
-		fmt.Printf("Warning: Could not get encoding for model '%s', falling back to 'cl100k_base'. Error: %v\n", model, err)
-		// Fallback to a default encoding if the specific model is not found.
-		tkm, err = tiktoken.GetEncoding("cl100k_base")
-		if err != nil {
-			// If even the fallback fails, panic is appropriate as token counting is critical.
-			return nil, fmt.Errorf("failed to get fallback tiktoken encoding 'cl100k_base': %v", err)
-		}
-	}
+	// Pick the tokenizer of the model's family from the registry. Without
+	// any tokenizer the client still works, estimating token counts.
+	tokenizer, tokenizerErr := loadClientTokenizer(model)
 	return &LMStudioClient{
 		APIURL:           apiURL,
 		Model:            model,
 		SystemMessage:    systemMessage, // Store the system message
 		MaxContextLength: maxContextLength,
-		tokenizer:        tkm,
+		Timeouts:         DefaultTimeouts(),
+		tokenizer:        tokenizer,
+		tokenizerErr:     tokenizerErr,
 	}, nil
 }
 
@@ -49,17 +43,13 @@ func (c *LMStudioClient) UpdateSystemMessage(newSystemMessage string) {
 	fmt.Printf("LMStudioClient system message updated to: %s\n", newSystemMessage)
 }
 
-// getTokenLength uses the client's specific tiktoken tokenizer to count tokens.
+// getTokenLength counts tokens with the client's tokenizer.
 func (c *LMStudioClient) getTokenLength(text string) int {
 	if c.tokenizer == nil {
-		// Should not happen due to logic in NewLMStudioClient, but good practice.
-		fmt.Println("Error: Tokenizer not initialized for LMStudioClient.")
-		// Returning a high number might be safer than 0 if this somehow occurs,
-		// to prevent accidentally exceeding context limits.
-		return len(text) // Fallback to character count as a rough upper bound.
+		// Clients not created by NewLMStudioClient have no tokenizer
+		return estimateTokenizer{}.CountTokens(text)
 	}
-	tokens := c.tokenizer.Encode(text, nil, nil)
-	return len(tokens)
+	return c.tokenizer.CountTokens(text)
 }
 
 // Chat sends the provided messages to the LLM and returns the completion, input tokens, completion tokens, and error.
@@ -71,7 +61,10 @@ func (c *LMStudioClient) Chat(ctx context.Context, messages []Message, stream bo
 	finalInputTokens, truncatedMessages := c.handleContextLimits(messagesWithSystem)
 
 	// Create and send the request
-	completion, completionTokens, err := c.sendRequest(ctx, truncatedMessages, stream, out)
+	completion, completionTokens, usage, err := c.sendRequest(ctx, truncatedMessages, stream, out)
+	if usage != nil && usage.PromptTokens > 0 {
+		finalInputTokens = usage.PromptTokens // The backend's count beats the local estimate
+	}
 
 	return completion, finalInputTokens, completionTokens, err
 }
@@ -135,15 +128,33 @@ func (c *LMStudioClient) truncateMessages(messages []Message, inputTokenLength i
 	return messages
 }
 
-// sendRequest creates and sends the API request, handling both streaming and non-streaming responses
-func (c *LMStudioClient) sendRequest(ctx context.Context, messages []Message, stream bool, out io.Writer) (string, int, error) {
+// sendRequest creates and sends the API request, handling both streaming and non-streaming responses.
+// The returned usage is nil when the backend reported none.
+func (c *LMStudioClient) sendRequest(ctx context.Context, messages []Message, stream bool, out io.Writer) (string, int, *Usage, error) {
+	temperature := 0.6
 	request := Request{
 		Model:       c.Model,
 		Messages:    messages,
-		Temperature: 0.6,
+		Temperature: float32(temperature),
 		MaxTokens:   -1,
 		Stream:      stream,
 	}
+	if stream {
+		request.StreamOptions = &StreamOptions{IncludeUsage: true}
+	}
+	if opts := generationOptionsFrom(ctx); opts.Temperature != nil || opts.MaxTokens > 0 {
+		if opts.Temperature != nil {
+			temperature = *opts.Temperature
+			request.Temperature = float32(temperature)
+		}
+		if opts.MaxTokens > 0 {
+			request.MaxTokens = opts.MaxTokens
+		}
+	}
+	if opts := generationOptionsFrom(ctx); opts.Model != "" {
+		request.Model = opts.Model
+	}
+	recordRequest(ctx, "lmstudio", request.Model, &temperature, request.MaxTokens)
 
 	// Add logging to show the messages slice before marshaling
 	messagesJSON, _ := json.Marshal(messages)
@@ -151,37 +162,39 @@ func (c *LMStudioClient) sendRequest(ctx context.Context, messages []Message, st
 
 	payload, err := json.Marshal(request)
 	if err != nil {
-		return "", 0, err
+		return "", 0, nil, err
 	}
 
 	fmt.Printf("LMStudioClient System Message before sending request: %s\n", c.SystemMessage) // Added logging
 	fmt.Printf("Sending request to %s with payload: %s\n", c.APIURL, string(payload))
 
-	// Create and send HTTP request
-	req, err := http.NewRequestWithContext(ctx, "POST", c.APIURL, bytes.NewBuffer(payload))
+	// Create and send HTTP request. The watchdog cancels it if the backend hangs.
+	reqCtx, watchdog := newTokenWatchdog(ctx, c.Timeouts, stream)
+	defer watchdog.Stop()
+	req, err := http.NewRequestWithContext(reqCtx, "POST", c.APIURL, bytes.NewBuffer(payload))
 	if err != nil {
-		return "", 0, err
+		return "", 0, nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
+	client := newHTTPClient("lmstudio", c.Timeouts)
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", 0, err
+		return "", 0, nil, watchdog.Err(err)
 	}
 	defer resp.Body.Close()
 
-	fmt.Fprintln(out, "Received response header.")
+	// Status lines stay off out: written output keeps WithRetry from retrying a later timeout.
 	fmt.Printf("Received response with status code: %d\n", resp.StatusCode)
 
 	if resp.StatusCode != 200 {
-		return "", 0, c.handleErrorResponse(resp)
+		return "", 0, nil, c.handleErrorResponse(resp)
 	}
 
 	if stream {
-		return c.handleStreamingResponse(resp, out)
+		return c.handleStreamingResponse(ctx, resp, out, watchdog)
 	} else {
-		return c.handleNonStreamingResponse(resp, out)
+		return c.handleNonStreamingResponse(ctx, resp, out, watchdog)
 	}
 }
 
@@ -195,9 +208,11 @@ func (c *LMStudioClient) handleErrorResponse(resp *http.Response) error {
 	return fmt.Errorf(errorMsg)
 }
 
-// handleStreamingResponse processes streaming responses
-func (c *LMStudioClient) handleStreamingResponse(resp *http.Response, out io.Writer) (string, int, error) {
+// handleStreamingResponse processes streaming responses. Completion tokens
+// come from the backend's usage chunk and are only counted locally without one.
+func (c *LMStudioClient) handleStreamingResponse(ctx context.Context, resp *http.Response, out io.Writer, watchdog *tokenWatchdog) (string, int, *Usage, error) {
 	var completionBuilder strings.Builder
+	var usage *Usage
 	reader := bufio.NewReader(resp.Body)
 	var currentEventData strings.Builder
 
@@ -205,7 +220,10 @@ func (c *LMStudioClient) handleStreamingResponse(resp *http.Response, out io.Wri
 		line, readErr := reader.ReadString('\n')
 
 		if readErr != nil && readErr != io.EOF {
-			return "", 0, fmt.Errorf("reading streaming response line: %w", readErr)
+			return "", 0, nil, fmt.Errorf("reading streaming response line: %w", watchdog.Err(readErr))
+		}
+		if strings.HasPrefix(line, "data: ") {
+			watchdog.Touch()
 		}
 
 		line = strings.TrimRight(line, "\r\n")
@@ -222,10 +240,13 @@ func (c *LMStudioClient) handleStreamingResponse(resp *http.Response, out io.Wri
 			}
 
 			// Process the event data
-			content := c.processEventData(eventData, out)
+			content, chunkUsage := c.processEventData(ctx, eventData, out)
 			if content != "" {
 				completionBuilder.WriteString(content)
 			}
+			if chunkUsage != nil {
+				usage = chunkUsage
+			}
 		}
 
 		if readErr == io.EOF {
@@ -234,42 +255,67 @@ func (c *LMStudioClient) handleStreamingResponse(resp *http.Response, out io.Wri
 	}
 
 	completionText := completionBuilder.String()
+	if usage != nil && usage.CompletionTokens > 0 {
+		fmt.Printf("Streaming completion tokens (reported by backend): %d\n", usage.CompletionTokens)
+		return completionText, usage.CompletionTokens, usage, nil
+	}
 	completionTokens := c.getTokenLength(completionText)
 	fmt.Printf("Streaming completion tokens (estimated from parsed content): %d\n", completionTokens)
 
-	return completionText, completionTokens, nil
+	return completionText, completionTokens, usage, nil
 }
 
-// processEventData extracts content from a streaming event
-func (c *LMStudioClient) processEventData(eventData string, out io.Writer) string {
+// processEventData extracts content and, from the final chunk, token usage from a streaming event.
+// The finish reason of the last choice chunk is recorded in the ResponseInfo of ctx.
+func (c *LMStudioClient) processEventData(ctx context.Context, eventData string, out io.Writer) (string, *Usage) {
 	// Parse the JSON data
 	var chunk map[string]interface{}
 	if jsonErr := json.Unmarshal([]byte(eventData), &chunk); jsonErr != nil {
 		log.Printf("Warning: Failed to parse stream chunk JSON: %v, data: %s", jsonErr, eventData)
-		return ""
+		return "", nil
 	}
+	usage := parseChunkUsage(chunk)
 
 	// Extract content delta
 	if choices, ok := chunk["choices"].([]interface{}); ok && len(choices) > 0 {
 		if choice, ok := choices[0].(map[string]interface{}); ok {
+			if reason, ok := choice["finish_reason"].(string); ok && reason != "" {
+				recordFinishReason(ctx, normalizeFinishReason(reason))
+			}
 			if delta, ok := choice["delta"].(map[string]interface{}); ok {
 				if content, ok := delta["content"].(string); ok && content != "" {
 					// Write to output and return for accumulation
 					if _, writeErr := out.Write([]byte(content)); writeErr != nil {
 						log.Printf("Error writing content delta to output: %v", writeErr)
 					}
-					return content
+					return content, usage
 				}
 			}
 		}
 	}
 
-	return ""
+	return "", usage
+}
+
+// parseChunkUsage reads the "usage" object OpenAI and LM Studio attach to
+// the last chunk of a stream, or Gemini's "usageMetadata".
+func parseChunkUsage(chunk map[string]interface{}) *Usage {
+	intField := func(m map[string]interface{}, key string) int {
+		n, _ := m[key].(float64)
+		return int(n)
+	}
+	if u, ok := chunk["usage"].(map[string]interface{}); ok {
+		return &Usage{PromptTokens: intField(u, "prompt_tokens"), CompletionTokens: intField(u, "completion_tokens")}
+	}
+	if u, ok := chunk["usageMetadata"].(map[string]interface{}); ok {
+		return &Usage{PromptTokens: intField(u, "promptTokenCount"), CompletionTokens: intField(u, "candidatesTokenCount")}
+	}
+	return nil
 }
 
 // handleNonStreamingResponse processes non-streaming responses
-func (c *LMStudioClient) handleNonStreamingResponse(resp *http.Response, out io.Writer) (string, int, error) {
-	fmt.Fprintln(out, "Attempting to read response body...")
+func (c *LMStudioClient) handleNonStreamingResponse(ctx context.Context, resp *http.Response, out io.Writer, watchdog *tokenWatchdog) (string, int, *Usage, error) {
+	fmt.Println("Attempting to read response body...")
 
 	// Read the entire response
 	buffer := bytes.Buffer{}
@@ -284,7 +330,7 @@ func (c *LMStudioClient) handleNonStreamingResponse(resp *http.Response, out io.
 			if err == io.EOF {
 				break
 			}
-			return "", 0, fmt.Errorf("reading non-streaming response body: %w", err)
+			return "", 0, nil, fmt.Errorf("reading non-streaming response body: %w", watchdog.Err(err))
 		}
 	}
 	respBody := buffer.Bytes()
@@ -296,8 +342,10 @@ func (c *LMStudioClient) handleNonStreamingResponse(resp *http.Response, out io.
 	// Try to parse the response
 	var parsed struct {
 		Choices []struct {
-			Message Message `json:"message"`
+			Message      Message `json:"message"`
+			FinishReason string  `json:"finish_reason"`
 		} `json:"choices"`
+		Usage *Usage `json:"usage"`
 	}
 
 	fmt.Printf("Response Body: %s\n", string(respBody))
@@ -306,7 +354,11 @@ func (c *LMStudioClient) handleNonStreamingResponse(resp *http.Response, out io.
 
 	if err := json.Unmarshal(respBody, &parsed); err == nil && len(parsed.Choices) > 0 {
 		completionText = parsed.Choices[0].Message.Content
+		recordFinishReason(ctx, normalizeFinishReason(parsed.Choices[0].FinishReason))
 		completionTokens = c.getTokenLength(completionText)
+		if parsed.Usage != nil && parsed.Usage.CompletionTokens > 0 {
+			completionTokens = parsed.Usage.CompletionTokens
+		}
 
 		fmt.Fprintln(out, "Parsed Response Content:")
 		fmt.Fprintln(out, completionText)
@@ -318,5 +370,5 @@ func (c *LMStudioClient) handleNonStreamingResponse(resp *http.Response, out io.
 		fmt.Printf("Non-streaming completion tokens (unparsed body): %d\n", completionTokens)
 	}
 
-	return completionText, completionTokens, nil
+	return completionText, completionTokens, parsed.Usage, nil
 }
@@ -0,0 +1,159 @@
+package llm
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// NetworkDefault is the key of the NetworkConfigs entry used for every
+// destination without an entry of its own.
+const NetworkDefault = "default"
+
+// NetworkWebhooks is the key of the NetworkConfigs entry of outbound
+// deliveries: webhooks, stall alerts and the GitHub integration.
+const NetworkWebhooks = "webhooks"
+
+// NetworkConfig configures the outbound connections to one destination,
+// e.g. a provider behind a corporate proxy with a private CA.
+type NetworkConfig struct {
+	Proxy              string `json:"proxy,omitempty"`              // URL of the HTTP proxy; empty uses HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+	CABundle           string `json:"caBundle,omitempty"`           // PEM file of CAs trusted in addition to the system ones
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"` // Accept any server certificate. Never use outside of testing
+
+	proxyURL *url.URL
+	roots    *x509.CertPool
+}
+
+// load parses the proxy URL and reads the CA bundle.
+func (c *NetworkConfig) load() error {
+	if c.Proxy != "" {
+		proxyURL, err := url.Parse(c.Proxy)
+		if err != nil || proxyURL.Scheme == "" || proxyURL.Host == "" {
+			return fmt.Errorf("invalid proxy %q (expected e.g. http://proxy.example.com:3128)", c.Proxy)
+		}
+		c.proxyURL = proxyURL
+	}
+	if c.CABundle != "" {
+		pem, err := os.ReadFile(c.CABundle)
+		if err != nil {
+			return fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		roots, err := x509.SystemCertPool()
+		if err != nil || roots == nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("CA bundle %s contains no PEM certificates", c.CABundle)
+		}
+		c.roots = roots
+	}
+	return nil
+}
+
+// Apply sets the proxy and TLS settings of the config on transport.
+func (c NetworkConfig) Apply(transport *http.Transport) {
+	if c.proxyURL != nil {
+		transport.Proxy = http.ProxyURL(c.proxyURL)
+	}
+	if c.roots == nil && !c.InsecureSkipVerify {
+		return
+	}
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	} else {
+		transport.TLSClientConfig = transport.TLSClientConfig.Clone()
+	}
+	if c.roots != nil {
+		transport.TLSClientConfig.RootCAs = c.roots
+	}
+	transport.TLSClientConfig.InsecureSkipVerify = c.InsecureSkipVerify
+}
+
+// Transport returns a copy of http.DefaultTransport with the config applied.
+func (c NetworkConfig) Transport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	c.Apply(transport)
+	return transport
+}
+
+// NetworkConfigs maps a destination, a provider type such as "google" or
+// NetworkWebhooks, to its network config.
+type NetworkConfigs map[string]NetworkConfig
+
+// LoadNetworkConfigs reads the network configs from a JSON object, given
+// inline or as a file path, and loads their proxies and CA bundles.
+func LoadNetworkConfigs(config string) (NetworkConfigs, error) {
+	data := []byte(config)
+	if !strings.HasPrefix(strings.TrimSpace(config), "{") {
+		fileData, err := os.ReadFile(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read network file %s: %w", config, err)
+		}
+		data = fileData
+	}
+	var configs NetworkConfigs
+	if err := json.Unmarshal(data, &configs); err != nil {
+		return nil, fmt.Errorf("failed to parse network configs: %w", err)
+	}
+	for name, c := range configs {
+		if err := c.load(); err != nil {
+			return nil, fmt.Errorf("network config %q: %w", name, err)
+		}
+		configs[name] = c
+	}
+	return configs, nil
+}
+
+// For returns the config of a destination, falling back to NetworkDefault.
+func (n NetworkConfigs) For(name string) NetworkConfig {
+	if c, ok := n[name]; ok {
+		return c
+	}
+	return n[NetworkDefault]
+}
+
+// Insecure returns the destinations that skip TLS verification, sorted.
+func (n NetworkConfigs) Insecure() []string {
+	var names []string
+	for name, c := range n {
+		if c.InsecureSkipVerify {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+var (
+	networkMu      sync.Mutex
+	networkConfigs NetworkConfigs
+)
+
+// SetNetworkConfigs sets the network configs of the provider clients. It
+// applies to the clients created afterwards, so call it at startup. Turning
+// off TLS verification is logged loudly, since it exposes the API keys and
+// the prompts to anyone on the path.
+func SetNetworkConfigs(configs NetworkConfigs) {
+	for _, name := range configs.Insecure() {
+		log.Printf("WARNING: TLS certificate verification is DISABLED for %q connections. Credentials and prompts can be intercepted; use caBundle instead.", name)
+	}
+	networkMu.Lock()
+	defer networkMu.Unlock()
+	networkConfigs = configs
+}
+
+// networkConfigFor returns the network config of a provider.
+func networkConfigFor(provider string) NetworkConfig {
+	networkMu.Lock()
+	defer networkMu.Unlock()
+	return networkConfigs.For(provider)
+}
@@ -0,0 +1,66 @@
+package llm
+
+import (
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNetworkConfigTrustsCABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	bundle := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600)
+
+	configs, err := LoadNetworkConfigs(fmt.Sprintf(`{"google": {"caBundle": %q}, "default": {"insecureSkipVerify": true}}`, bundle))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&http.Client{Transport: NetworkConfig{}.Transport()}).Get(server.URL); err == nil {
+		t.Fatal("expected the test certificate to be rejected without the bundle")
+	}
+	if _, err := (&http.Client{Transport: configs.For("google").Transport()}).Get(server.URL); err != nil {
+		t.Errorf("expected the bundle to be trusted: %v", err)
+	}
+	if _, err := (&http.Client{Transport: configs.For("ollama").Transport()}).Get(server.URL); err != nil {
+		t.Errorf("expected the default config to skip verification: %v", err)
+	}
+	if insecure := configs.Insecure(); len(insecure) != 1 || insecure[0] != NetworkDefault {
+		t.Errorf("expected only the default config to be insecure, got %v", insecure)
+	}
+}
+
+func TestNetworkConfigRoutesThroughProxy(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+	}))
+	defer proxy.Close()
+
+	configs, err := LoadNetworkConfigs(fmt.Sprintf(`{"lmstudio": {"proxy": %q}}`, proxy.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := (&http.Client{Transport: configs.For("lmstudio").Transport()}).Get("http://llm.internal:1234/v1/models"); err != nil {
+		t.Fatal(err)
+	}
+	if proxied != "http://llm.internal:1234/v1/models" {
+		t.Errorf("expected the request to go through the proxy, got %q", proxied)
+	}
+}
+
+func TestLoadNetworkConfigsRejectsInvalidSettings(t *testing.T) {
+	for _, config := range []string{
+		`{"google": {"proxy": "proxy:3128"}}`,
+		`{"google": {"caBundle": "/nonexistent/ca.pem"}}`,
+		`{"google": []}`,
+	} {
+		if _, err := LoadNetworkConfigs(config); err == nil {
+			t.Errorf("expected %s to be rejected", config)
+		}
+	}
+}
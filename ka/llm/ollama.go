@@ -0,0 +1,260 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OllamaClient talks to Ollama's native API (/api/chat), which, unlike the
+// OpenAI-compatible endpoint, accepts Ollama options such as num_ctx and
+// keep_alive.
+type OllamaClient struct {
+	BaseURL   string // e.g. http://localhost:11434
+	Model     string // May be empty; the first locally available model is used
+	NumCtx    int    // Context window the model is loaded with; 0 keeps Ollama's default
+	KeepAlive string // How long Ollama keeps the model loaded, e.g. "10m" or "-1"; empty keeps Ollama's default
+	Timeouts  Timeouts
+
+	mu           sync.Mutex
+	tokenizer    Tokenizer
+	tokenizerErr error
+}
+
+// NewOllamaClient creates a client for the Ollama server at baseURL.
+func NewOllamaClient(baseURL, model string, numCtx int, keepAlive string) *OllamaClient {
+	tokenizer, tokenizerErr := loadClientTokenizer(model)
+	return &OllamaClient{
+		BaseURL:      strings.TrimRight(baseURL, "/"),
+		Model:        model,
+		NumCtx:       numCtx,
+		KeepAlive:    keepAlive,
+		Timeouts:     DefaultTimeouts(),
+		tokenizer:    tokenizer,
+		tokenizerErr: tokenizerErr,
+	}
+}
+
+// ollamaChatRequest is the body of POST /api/chat.
+type ollamaChatRequest struct {
+	Model     string                 `json:"model"`
+	Messages  []Message              `json:"messages"`
+	Stream    bool                   `json:"stream"`
+	Options   map[string]interface{} `json:"options,omitempty"`
+	KeepAlive string                 `json:"keep_alive,omitempty"`
+}
+
+// ollamaChatChunk is a line of a streamed /api/chat response, or the whole
+// response when not streaming. The last one has Done set and the counts.
+type ollamaChatChunk struct {
+	Message         Message `json:"message"`
+	Done            bool    `json:"done"`
+	DoneReason      string  `json:"done_reason"`
+	PromptEvalCount int     `json:"prompt_eval_count"`
+	EvalCount       int     `json:"eval_count"`
+	Error           string  `json:"error"`
+}
+
+// Tokenizer implements TokenizerProvider.
+func (c *OllamaClient) Tokenizer() Tokenizer {
+	return c.tokenizer
+}
+
+// CountTokens implements TokenCounter.
+func (c *OllamaClient) CountTokens(text string) int {
+	if c.tokenizer == nil {
+		return estimateTokenizer{}.CountTokens(text)
+	}
+	return c.tokenizer.CountTokens(text)
+}
+
+// Chat sends the messages to /api/chat and returns the completion, input
+// tokens and completion tokens. Ollama truncates the context itself to
+// NumCtx, so messages are sent as they are.
+func (c *OllamaClient) Chat(ctx context.Context, messages []Message, stream bool, out io.Writer) (string, int, int, error) {
+	opts := generationOptionsFrom(ctx)
+	model := opts.Model
+	if model == "" {
+		var err error
+		if model, err = c.resolveModel(ctx); err != nil {
+			return "", 0, 0, err
+		}
+	}
+
+	temperature := 0.6
+	if opts.Temperature != nil {
+		temperature = *opts.Temperature
+	}
+	request := ollamaChatRequest{
+		Model:     model,
+		Messages:  messages,
+		Stream:    stream,
+		Options:   map[string]interface{}{"temperature": temperature},
+		KeepAlive: c.KeepAlive,
+	}
+	if opts.MaxTokens > 0 {
+		request.Options["num_predict"] = opts.MaxTokens
+	}
+	if c.NumCtx > 0 {
+		request.Options["num_ctx"] = c.NumCtx
+	}
+	recordRequest(ctx, "ollama", model, &temperature, opts.MaxTokens)
+
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return "", 0, 0, err
+	}
+	reqCtx, watchdog := newTokenWatchdog(ctx, c.Timeouts, stream)
+	defer watchdog.Stop()
+	req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, c.BaseURL+"/api/chat", bytes.NewReader(payload))
+	if err != nil {
+		return "", 0, 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := newHTTPClient("ollama", c.Timeouts).Do(req)
+	if err != nil {
+		return "", 0, 0, watchdog.Err(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return "", 0, 0, fmt.Errorf("LLM API returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	// Streamed responses are one JSON object per line; a non-streamed one is
+	// a single object with Done set.
+	var completion strings.Builder
+	var last ollamaChatChunk
+	reader := bufio.NewReader(resp.Body)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if readErr != nil && readErr != io.EOF {
+			return "", 0, 0, fmt.Errorf("reading ollama response: %w", watchdog.Err(readErr))
+		}
+		watchdog.Touch()
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			var chunk ollamaChatChunk
+			if err := json.Unmarshal(line, &chunk); err != nil {
+				log.Printf("Warning: Failed to parse ollama chunk JSON: %v, data: %s", err, line)
+			} else if chunk.Error != "" {
+				return "", 0, 0, fmt.Errorf("ollama: %s", chunk.Error)
+			} else {
+				if chunk.Message.Content != "" {
+					completion.WriteString(chunk.Message.Content)
+					if stream {
+						if _, err := io.WriteString(out, chunk.Message.Content); err != nil {
+							log.Printf("Error writing content delta to output: %v", err)
+						}
+					}
+				}
+				if chunk.Done {
+					last = chunk
+					break
+				}
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+	}
+
+	text := completion.String()
+	if !stream {
+		fmt.Fprintln(out, text)
+	}
+	recordFinishReason(ctx, normalizeFinishReason(last.DoneReason))
+	inputTokens, completionTokens := last.PromptEvalCount, last.EvalCount
+	if inputTokens == 0 {
+		for _, message := range messages {
+			inputTokens += c.CountTokens(message.Content)
+		}
+	}
+	if completionTokens == 0 {
+		completionTokens = c.CountTokens(text)
+	}
+	return text, inputTokens, completionTokens, nil
+}
+
+// resolveModel returns the configured model or, without one, the first
+// model available on the server.
+func (c *OllamaClient) resolveModel(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	model := c.Model
+	c.mu.Unlock()
+	if model != "" {
+		return model, nil
+	}
+	models, err := c.ListModels(ctx)
+	if err != nil {
+		return "", fmt.Errorf("no model configured and listing the ollama models failed: %w", err)
+	}
+	if len(models) == 0 {
+		return "", fmt.Errorf("no model configured and ollama has no models; pull one with 'ollama pull <model>'")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Model == "" {
+		c.Model = models[0].Name
+		log.Printf("[OllamaClient] No model configured, using %s.", c.Model)
+	}
+	return c.Model, nil
+}
+
+// CurrentModel returns the model requests are sent to, resolving it from
+// the available models if none was configured.
+func (c *OllamaClient) CurrentModel(ctx context.Context) (string, error) {
+	return c.resolveModel(ctx)
+}
+
+// ListModels implements ModelLister with GET /api/tags.
+func (c *OllamaClient) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.BaseURL+"/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := newHTTPClient("ollama", c.Timeouts).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ollama returned status %d listing models", resp.StatusCode)
+	}
+	var tags struct {
+		Models []struct {
+			Name       string    `json:"name"`
+			Size       int64     `json:"size"`
+			ModifiedAt time.Time `json:"modified_at"`
+			Details    struct {
+				Family            string `json:"family"`
+				ParameterSize     string `json:"parameter_size"`
+				QuantizationLevel string `json:"quantization_level"`
+			} `json:"details"`
+		} `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, fmt.Errorf("invalid ollama model list: %w", err)
+	}
+	models := make([]ModelInfo, 0, len(tags.Models))
+	for _, m := range tags.Models {
+		models = append(models, ModelInfo{
+			Name:          m.Name,
+			Family:        m.Details.Family,
+			ParameterSize: m.Details.ParameterSize,
+			Quantization:  m.Details.QuantizationLevel,
+			SizeBytes:     m.Size,
+			ModifiedAt:    m.ModifiedAt,
+		})
+	}
+	return models, nil
+}
@@ -0,0 +1,101 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newOllamaServer(t *testing.T, requests *[]ollamaChatRequest) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/tags":
+			io.WriteString(w, `{"models":[{"name":"llama3.2:3b","size":2019393189,"details":{"family":"llama","parameter_size":"3.2B","quantization_level":"Q4_K_M"}},{"name":"qwen2.5:7b"}]}`)
+		case "/api/chat":
+			var request ollamaChatRequest
+			json.NewDecoder(r.Body).Decode(&request)
+			*requests = append(*requests, request)
+			if request.Stream {
+				io.WriteString(w, `{"message":{"role":"assistant","content":"Hel"},"done":false}`+"\n")
+				io.WriteString(w, `{"message":{"role":"assistant","content":"lo"},"done":false}`+"\n")
+				io.WriteString(w, `{"message":{"role":"assistant","content":""},"done":true,"done_reason":"length","prompt_eval_count":12,"eval_count":2}`+"\n")
+				return
+			}
+			io.WriteString(w, `{"message":{"role":"assistant","content":"Hello"},"done":true,"done_reason":"stop","prompt_eval_count":12,"eval_count":2}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func TestOllamaClientStreamsNativeChat(t *testing.T) {
+	var requests []ollamaChatRequest
+	server := newOllamaServer(t, &requests)
+	defer server.Close()
+
+	client := NewOllamaClient(server.URL+"/", "", 16384, "10m")
+	info := &ResponseInfo{}
+	ctx := WithResponseInfo(WithGenerationOptions(context.Background(), GenerationOptions{MaxTokens: 64}), info)
+	var out strings.Builder
+	text, in, completion, err := client.Chat(ctx, []Message{{Role: "user", Content: "Hi"}}, true, &out)
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if text != "Hello" || out.String() != "Hello" || in != 12 || completion != 2 {
+		t.Errorf("unexpected completion %q (streamed %q), tokens %d/%d", text, out.String(), in, completion)
+	}
+	if info.FinishReason != FinishReasonLength || info.Provider != "ollama" || info.Model != "llama3.2:3b" {
+		t.Errorf("unexpected response info %+v", info)
+	}
+	request := requests[0]
+	if request.Model != "llama3.2:3b" || request.KeepAlive != "10m" {
+		t.Errorf("expected the first local model and keep_alive, got %+v", request)
+	}
+	if request.Options["num_ctx"] != float64(16384) || request.Options["num_predict"] != float64(64) {
+		t.Errorf("expected num_ctx and num_predict options, got %v", request.Options)
+	}
+
+	text, _, _, err = client.Chat(context.Background(), []Message{{Role: "user", Content: "Hi"}}, false, io.Discard)
+	if err != nil || text != "Hello" {
+		t.Errorf("expected the non-streamed completion, got %q, %v", text, err)
+	}
+}
+
+func TestOllamaClientListsModels(t *testing.T) {
+	var requests []ollamaChatRequest
+	server := newOllamaServer(t, &requests)
+	defer server.Close()
+
+	client := WithRetry(NewOllamaClient(server.URL, "qwen2.5:7b", 0, ""), RetryPolicy{MaxAttempts: 1})
+	models, ok, err := ListModels(context.Background(), client)
+	if !ok || err != nil || len(models) != 2 {
+		t.Fatalf("expected two models, got %v, %v, %v", models, ok, err)
+	}
+	if models[0].Family != "llama" || models[0].ParameterSize != "3.2B" || models[0].Quantization != "Q4_K_M" {
+		t.Errorf("unexpected model details %+v", models[0])
+	}
+	if model, _ := CurrentModel(context.Background(), client); model != "qwen2.5:7b" {
+		t.Errorf("expected the configured model, got %q", model)
+	}
+	if _, ok, _ := ListModels(context.Background(), &LMStudioClient{}); ok {
+		t.Errorf("expected LM Studio clients not to list models")
+	}
+}
+
+func TestOllamaClientReportsErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		io.WriteString(w, `{"error":"model \"missing\" not found, try pulling it first"}`)
+	}))
+	defer server.Close()
+
+	_, _, _, err := NewOllamaClient(server.URL, "missing", 0, "").Chat(context.Background(), []Message{{Role: "user", Content: "Hi"}}, false, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "returned status 404") {
+		t.Errorf("expected the status in the error, got %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+package llm
+
+import "context"
+
+// GenerationOptions are per-call model parameters. Zero values keep the
+// client's defaults.
+type GenerationOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	MaxTokens   int      `json:"maxTokens,omitempty"`
+	Model       string   `json:"model,omitempty"` // Overrides the client's model
+	// MaxContinuations is how often ChatWithContinuation asks the model to
+	// continue a completion cut off by MaxTokens.
+	MaxContinuations int `json:"maxContinuations,omitempty"`
+}
+
+type generationOptionsKey struct{}
+
+// WithGenerationOptions returns a context whose Chat calls use opts.
+func WithGenerationOptions(ctx context.Context, opts GenerationOptions) context.Context {
+	return context.WithValue(ctx, generationOptionsKey{}, opts)
+}
+
+// WithModel returns a context whose Chat calls use model, keeping the other
+// options already in ctx.
+func WithModel(ctx context.Context, model string) context.Context {
+	opts := generationOptionsFrom(ctx)
+	opts.Model = model
+	return WithGenerationOptions(ctx, opts)
+}
+
+// generationOptionsFrom returns the options in ctx, or the zero value.
+func generationOptionsFrom(ctx context.Context) GenerationOptions {
+	opts, _ := ctx.Value(generationOptionsKey{}).(GenerationOptions)
+	return opts
+}
@@ -0,0 +1,200 @@
+package llm
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PoolStats are the connection metrics of one provider's shared HTTP client.
+type PoolStats struct {
+	Provider       string  `json:"provider"`
+	Requests       int64   `json:"requests"`
+	NewConns       int64   `json:"newConns"`
+	ReusedConns    int64   `json:"reusedConns"`
+	AvgConnectMs   float64 `json:"avgConnectMs"`   // Dial and TLS handshake of new connections
+	AvgFirstByteMs float64 `json:"avgFirstByteMs"` // From sending the request to the first response byte
+	WarmedUp       bool    `json:"warmedUp"`
+}
+
+// connPool is the shared client of a provider. Reusing it keeps connections
+// to the backend alive between requests.
+type connPool struct {
+	provider     string
+	client       *http.Client
+	requests     atomic.Int64
+	newConns     atomic.Int64
+	reusedConns  atomic.Int64
+	connectNanos atomic.Int64
+	firstByteNs  atomic.Int64
+	firstBytes   atomic.Int64
+	warmedUp     atomic.Bool
+	lastRequest  atomic.Int64 // Unix nanoseconds
+}
+
+var (
+	poolsMu sync.Mutex
+	pools   = make(map[string]*connPool)
+)
+
+// sharedPool returns the pool of a provider, keyed by the connect timeout
+// because it is part of the transport. The proxy and TLS settings come from
+// the provider's network config.
+func sharedPool(provider string, timeouts Timeouts) *connPool {
+	key := fmt.Sprintf("%s/%s", provider, timeouts.Connect)
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+	if pool, ok := pools[key]; ok {
+		return pool
+	}
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if timeouts.Connect > 0 {
+		dialer.Timeout = timeouts.Connect
+	}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   16, // Parallel tasks against one local backend
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+		TLSClientConfig:       &tls.Config{MinVersion: tls.VersionTLS12},
+	}
+	if timeouts.Connect > 0 {
+		transport.TLSHandshakeTimeout = timeouts.Connect
+	}
+	networkConfigFor(provider).Apply(transport)
+	pool := &connPool{provider: provider}
+	pool.client = &http.Client{Transport: &tracingTransport{next: transport, pool: pool}}
+	pools[key] = pool
+	return pool
+}
+
+// newHTTPClient returns the shared HTTP client of a provider, honouring the connect timeout.
+func newHTTPClient(provider string, timeouts Timeouts) *http.Client {
+	return sharedPool(provider, timeouts).client
+}
+
+// tracingTransport records connection reuse and latency of each request.
+type tracingTransport struct {
+	next http.RoundTripper
+	pool *connPool
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	pool := t.pool
+	pool.requests.Add(1)
+	pool.lastRequest.Store(time.Now().UnixNano())
+	var connectStart, wroteAt time.Time
+	trace := &httptrace.ClientTrace{
+		ConnectStart: func(string, string) { connectStart = time.Now() },
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				pool.reusedConns.Add(1)
+				return
+			}
+			pool.newConns.Add(1)
+			if !connectStart.IsZero() {
+				pool.connectNanos.Add(int64(time.Since(connectStart)))
+			}
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) { wroteAt = time.Now() },
+		GotFirstResponseByte: func() {
+			if !wroteAt.IsZero() {
+				pool.firstByteNs.Add(int64(time.Since(wroteAt)))
+				pool.firstBytes.Add(1)
+			}
+		},
+	}
+	return t.next.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+}
+
+func (p *connPool) stats() PoolStats {
+	stats := PoolStats{
+		Provider:    p.provider,
+		Requests:    p.requests.Load(),
+		NewConns:    p.newConns.Load(),
+		ReusedConns: p.reusedConns.Load(),
+		WarmedUp:    p.warmedUp.Load(),
+	}
+	if stats.NewConns > 0 {
+		stats.AvgConnectMs = float64(p.connectNanos.Load()) / float64(stats.NewConns) / 1e6
+	}
+	if n := p.firstBytes.Load(); n > 0 {
+		stats.AvgFirstByteMs = float64(p.firstByteNs.Load()) / float64(n) / 1e6
+	}
+	return stats
+}
+
+// lastRequestAt returns when a request was last sent to a provider, or the
+// zero time if none was.
+func lastRequestAt(provider string) time.Time {
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+	var last int64
+	for _, pool := range pools {
+		if pool.provider == provider {
+			last = max(last, pool.lastRequest.Load())
+		}
+	}
+	if last == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, last)
+}
+
+// ConnectionStats returns the metrics of every provider pool in use.
+func ConnectionStats() []PoolStats {
+	poolsMu.Lock()
+	defer poolsMu.Unlock()
+	stats := make([]PoolStats, 0, len(pools))
+	for _, pool := range pools {
+		stats = append(stats, pool.stats())
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Provider < stats[j].Provider })
+	return stats
+}
+
+// WarmUp opens a connection to the client's backend ahead of the first task,
+// so the first request does not pay for the dial and TLS handshake. Any HTTP
+// response counts as success.
+func WarmUp(ctx context.Context, client LLMClient) error {
+	var provider, target string
+	var timeouts Timeouts
+	switch c := innermostClient(client).(type) {
+	case *LMStudioClient:
+		provider, target, timeouts = "lmstudio", c.APIURL, c.Timeouts
+	case *GoogleClient:
+		provider, target, timeouts = "google", googleAPIBase, c.Timeouts
+	case *OllamaClient:
+		provider, target, timeouts = "ollama", c.BaseURL, c.Timeouts
+	default:
+		return fmt.Errorf("warm-up is not supported for %T", client)
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return fmt.Errorf("invalid backend URL %s: %w", target, err)
+	}
+	pool := sharedPool(provider, timeouts)
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u.Scheme+"://"+u.Host+"/", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := pool.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("warm-up of %s failed: %w", provider, err)
+	}
+	resp.Body.Close()
+	pool.warmedUp.Store(true)
+	return nil
+}
@@ -0,0 +1,55 @@
+package llm
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSharedPoolReusesConnections(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	client := newHTTPClient("pool-test", Timeouts{})
+	if client != newHTTPClient("pool-test", Timeouts{}) {
+		t.Fatalf("expected one shared client per provider")
+	}
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+
+	var stats PoolStats
+	for _, s := range ConnectionStats() {
+		if s.Provider == "pool-test" {
+			stats = s
+		}
+	}
+	if stats.Requests != 3 || stats.NewConns != 1 || stats.ReusedConns != 2 {
+		t.Errorf("expected 3 requests over 1 connection, got %+v", stats)
+	}
+}
+
+func TestWarmUpMarksPool(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound) // Any response proves the backend is reachable
+	}))
+	defer server.Close()
+
+	client := &LMStudioClient{APIURL: server.URL + "/v1/chat/completions", Timeouts: Timeouts{Connect: 5 * time.Second}}
+	if err := WarmUp(context.Background(), WithRetry(client, RetryPolicy{MaxAttempts: 2})); err != nil {
+		t.Fatalf("WarmUp: %v", err)
+	}
+	if !sharedPool("lmstudio", client.Timeouts).warmedUp.Load() {
+		t.Errorf("expected the lmstudio pool to be marked warmed up")
+	}
+}
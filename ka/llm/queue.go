@@ -0,0 +1,310 @@
+package llm
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QueueConfig limits the requests sent to one provider at a time, e.g. to 1
+// for a local backend that serves one request at a time anyway.
+type QueueConfig struct {
+	Concurrency int // Requests in flight; 0 disables the queue
+	MaxWaiting  int // Waiting requests at which the provider reports busy; 0 never does
+}
+
+// QueueStats are the metrics of one provider's request queue.
+type QueueStats struct {
+	Provider    string  `json:"provider"`
+	Concurrency int     `json:"concurrency"`
+	MaxWaiting  int     `json:"maxWaiting,omitempty"`
+	Active      int     `json:"active"`
+	Waiting     int     `json:"waiting"`
+	Requests    int64   `json:"requests"`
+	Rejected    int64   `json:"rejected"` // Tasks refused with ProviderBusyError
+	AvgWaitMs   float64 `json:"avgWaitMs"`
+	MaxWaitMs   float64 `json:"maxWaitMs"`
+	AvgRunMs    float64 `json:"avgRunMs"` // Of the requests, from leaving the queue to their response
+}
+
+// ProviderBusyError reports a provider whose queue is full, so schedulers
+// can hold back work and retry later.
+type ProviderBusyError struct {
+	Provider   string
+	Waiting    int
+	RetryAfter time.Duration // Estimated from the average request duration
+}
+
+func (e *ProviderBusyError) Error() string {
+	return fmt.Sprintf("provider %s is busy: %d requests are waiting (retry in %s)", e.Provider, e.Waiting, e.RetryAfter.Round(time.Second))
+}
+
+// queuedRequest is a request waiting for a slot.
+type queuedRequest struct {
+	priority int
+	seq      uint64
+	index    int // In the heap; -1 once granted or withdrawn
+	ready    chan struct{}
+}
+
+// requestHeap orders waiting requests by priority, highest first, and by
+// arrival within a priority.
+type requestHeap []*queuedRequest
+
+func (h requestHeap) Len() int { return len(h) }
+func (h requestHeap) Less(i, j int) bool {
+	if h[i].priority != h[j].priority {
+		return h[i].priority > h[j].priority
+	}
+	return h[i].seq < h[j].seq
+}
+func (h requestHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+func (h *requestHeap) Push(x any) {
+	request := x.(*queuedRequest)
+	request.index = len(*h)
+	*h = append(*h, request)
+}
+func (h *requestHeap) Pop() any {
+	old := *h
+	request := old[len(old)-1]
+	*h = old[:len(old)-1]
+	request.index = -1
+	return request
+}
+
+// RequestQueue admits the requests to one provider in priority order, at
+// most Concurrency at a time.
+type RequestQueue struct {
+	provider string
+	config   QueueConfig
+
+	mu        sync.Mutex
+	active    int
+	waiting   requestHeap
+	seq       uint64
+	requests  int64
+	rejected  int64
+	waitNanos int64
+	maxWait   time.Duration
+	runNanos  int64
+	runs      int64
+}
+
+func newRequestQueue(provider string, config QueueConfig) *RequestQueue {
+	return &RequestQueue{provider: provider, config: config}
+}
+
+// Acquire waits for a slot. The returned release must be called when the
+// request is done; wait is the time spent in the queue.
+func (q *RequestQueue) Acquire(ctx context.Context, priority int) (release func(), wait time.Duration, err error) {
+	start := time.Now()
+	q.mu.Lock()
+	if q.active < q.config.Concurrency && len(q.waiting) == 0 {
+		q.active++
+		q.requests++
+		q.mu.Unlock()
+		return q.releaser(time.Now()), 0, nil
+	}
+	q.seq++
+	request := &queuedRequest{priority: priority, seq: q.seq, ready: make(chan struct{})}
+	heap.Push(&q.waiting, request)
+	q.mu.Unlock()
+
+	select {
+	case <-request.ready:
+	case <-ctx.Done():
+		q.mu.Lock()
+		if request.index >= 0 {
+			heap.Remove(&q.waiting, request.index)
+		} else {
+			q.freeSlotLocked() // Granted meanwhile: pass the slot on
+		}
+		q.mu.Unlock()
+		return nil, time.Since(start), ctx.Err()
+	}
+
+	wait = time.Since(start)
+	q.mu.Lock()
+	q.requests++
+	q.waitNanos += int64(wait)
+	q.maxWait = max(q.maxWait, wait)
+	q.mu.Unlock()
+	return q.releaser(time.Now()), wait, nil
+}
+
+// releaser returns the function that frees the slot of a request that left
+// the queue at started, granting it to the next waiting request.
+func (q *RequestQueue) releaser(started time.Time) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			q.runNanos += int64(time.Since(started))
+			q.runs++
+			q.freeSlotLocked()
+		})
+	}
+}
+
+// freeSlotLocked frees a slot and grants it to the next waiting request.
+func (q *RequestQueue) freeSlotLocked() {
+	q.active--
+	for q.active < q.config.Concurrency && len(q.waiting) > 0 {
+		next := heap.Pop(&q.waiting).(*queuedRequest)
+		q.active++
+		close(next.ready)
+	}
+}
+
+// Busy returns a ProviderBusyError when MaxWaiting requests are waiting,
+// and nil otherwise. Each busy answer counts as a rejection.
+func (q *RequestQueue) Busy() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.config.MaxWaiting <= 0 || len(q.waiting) < q.config.MaxWaiting {
+		return nil
+	}
+	q.rejected++
+	retryAfter := time.Second
+	if q.runs > 0 {
+		perRequest := time.Duration(q.runNanos / q.runs)
+		retryAfter = max(retryAfter, perRequest*time.Duration(len(q.waiting)+1)/time.Duration(q.config.Concurrency))
+	}
+	return &ProviderBusyError{Provider: q.provider, Waiting: len(q.waiting), RetryAfter: retryAfter}
+}
+
+// Stats returns the metrics of the queue.
+func (q *RequestQueue) Stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	stats := QueueStats{
+		Provider:    q.provider,
+		Concurrency: q.config.Concurrency,
+		MaxWaiting:  q.config.MaxWaiting,
+		Active:      q.active,
+		Waiting:     len(q.waiting),
+		Requests:    q.requests,
+		Rejected:    q.rejected,
+		MaxWaitMs:   float64(q.maxWait) / 1e6,
+	}
+	if q.requests > 0 {
+		stats.AvgWaitMs = float64(q.waitNanos) / float64(q.requests) / 1e6
+	}
+	if q.runs > 0 {
+		stats.AvgRunMs = float64(q.runNanos) / float64(q.runs) / 1e6
+	}
+	return stats
+}
+
+var (
+	queuesMu sync.Mutex
+	queues   = make(map[string]*RequestQueue)
+)
+
+// SetRequestQueues sets the queue of each provider, replacing the previous
+// queues. It applies to the clients wrapped with WithQueue afterwards, so
+// call it at startup.
+func SetRequestQueues(configs map[string]QueueConfig) {
+	queuesMu.Lock()
+	defer queuesMu.Unlock()
+	queues = make(map[string]*RequestQueue, len(configs))
+	for provider, config := range configs {
+		if config.Concurrency > 0 {
+			queues[provider] = newRequestQueue(provider, config)
+		}
+	}
+}
+
+// RequestQueueFor returns the queue of a provider, or nil if its requests
+// are not queued.
+func RequestQueueFor(provider string) *RequestQueue {
+	queuesMu.Lock()
+	defer queuesMu.Unlock()
+	return queues[provider]
+}
+
+// RequestQueueStats returns the metrics of every provider queue.
+func RequestQueueStats() []QueueStats {
+	queuesMu.Lock()
+	defer queuesMu.Unlock()
+	stats := make([]QueueStats, 0, len(queues))
+	for _, queue := range queues {
+		stats = append(stats, queue.Stats())
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Provider < stats[j].Provider })
+	return stats
+}
+
+// ParseQueueConfigs parses -llm-concurrency entries of the form
+// provider=N, giving each queue maxWaiting.
+func ParseQueueConfigs(entries []string, maxWaiting int) (map[string]QueueConfig, error) {
+	configs := make(map[string]QueueConfig, len(entries))
+	for _, entry := range entries {
+		provider, value, ok := strings.Cut(entry, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		provider = strings.ToLower(strings.TrimSpace(provider))
+		if !ok || provider == "" || err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid request queue '%s' (expected provider=N, e.g. lmstudio=1)", entry)
+		}
+		configs[provider] = QueueConfig{Concurrency: n, MaxWaiting: maxWaiting}
+	}
+	return configs, nil
+}
+
+type priorityKey struct{}
+
+// WithPriority sets the queue priority of the LLM requests made with ctx.
+// Higher priorities are sent first; the default is 0.
+func WithPriority(ctx context.Context, priority int) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// PriorityFrom returns the queue priority set with WithPriority.
+func PriorityFrom(ctx context.Context) int {
+	priority, _ := ctx.Value(priorityKey{}).(int)
+	return priority
+}
+
+// queuedClient sends the requests of a client through its provider's queue.
+type queuedClient struct {
+	client LLMClient
+	queue  *RequestQueue
+}
+
+// WithQueue wraps client so its requests wait in the queue of provider. The
+// client is returned unchanged if the provider has no queue.
+func WithQueue(client LLMClient, provider string) LLMClient {
+	queue := RequestQueueFor(provider)
+	if queue == nil {
+		return client
+	}
+	return &queuedClient{client: client, queue: queue}
+}
+
+func (q *queuedClient) Chat(ctx context.Context, messages []Message, stream bool, out io.Writer) (string, int, int, error) {
+	release, wait, err := q.queue.Acquire(ctx, PriorityFrom(ctx))
+	if err != nil {
+		return "", 0, 0, fmt.Errorf("gave up waiting for %s after %s in the request queue: %w", q.queue.provider, wait.Round(time.Millisecond), err)
+	}
+	defer release()
+	if wait >= time.Second {
+		log.Printf("[llm] Request waited %s in the %s queue.", wait.Round(time.Millisecond), q.queue.provider)
+	}
+	return q.client.Chat(ctx, messages, stream, out)
+}
+
+// Unwrap returns the wrapped client.
+func (q *queuedClient) Unwrap() LLMClient {
+	return q.client
+}
@@ -0,0 +1,120 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gateClient blocks each request until release is closed and records the
+// order in which requests were sent.
+type gateClient struct {
+	release chan struct{}
+	mu      sync.Mutex
+	order   []string
+}
+
+func (c *gateClient) Chat(ctx context.Context, messages []Message, stream bool, out io.Writer) (string, int, int, error) {
+	c.mu.Lock()
+	c.order = append(c.order, messages[0].Content)
+	c.mu.Unlock()
+	<-c.release
+	return "ok", 0, 0, nil
+}
+
+func waitForWaiting(t *testing.T, queue *RequestQueue, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for queue.Stats().Waiting != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d waiting requests, got %+v", n, queue.Stats())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestQueueSendsByPriorityWithinConcurrency(t *testing.T) {
+	SetRequestQueues(map[string]QueueConfig{"lmstudio": {Concurrency: 1, MaxWaiting: 3}})
+	t.Cleanup(func() { SetRequestQueues(nil) })
+	inner := &gateClient{release: make(chan struct{})}
+	client := WithQueue(inner, "lmstudio")
+	queue := RequestQueueFor("lmstudio")
+
+	var wg sync.WaitGroup
+	send := func(name string, priority int) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client.Chat(WithPriority(context.Background(), priority), []Message{{Role: "user", Content: name}}, false, io.Discard)
+		}()
+	}
+	send("first", 0)
+	waitForWaiting(t, queue, 0)
+	for queue.Stats().Active != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	send("low", -1)
+	waitForWaiting(t, queue, 1)
+	send("normal", 0)
+	waitForWaiting(t, queue, 2)
+	send("urgent", 5)
+	waitForWaiting(t, queue, 3)
+
+	var busy *ProviderBusyError
+	if err := queue.Busy(); !errors.As(err, &busy) || busy.Waiting != 3 || busy.RetryAfter < time.Second {
+		t.Errorf("expected a full queue to report busy, got %v", err)
+	}
+
+	close(inner.release)
+	wg.Wait()
+	want := []string{"first", "urgent", "normal", "low"}
+	for i, name := range want {
+		if inner.order[i] != name {
+			t.Fatalf("expected requests in priority order %v, got %v", want, inner.order)
+		}
+	}
+	stats := queue.Stats()
+	if stats.Requests != 4 || stats.Rejected != 1 || stats.Active != 0 || stats.MaxWaitMs <= 0 {
+		t.Errorf("expected the queue metrics of 4 requests, got %+v", stats)
+	}
+	if queue.Busy() != nil {
+		t.Errorf("expected an empty queue not to be busy")
+	}
+}
+
+func TestQueueCancelledRequestLeavesQueue(t *testing.T) {
+	queue := newRequestQueue("ollama", QueueConfig{Concurrency: 1})
+	release, _, _ := queue.Acquire(context.Background(), 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		_, _, err := queue.Acquire(ctx, 0)
+		done <- err
+	}()
+	waitForWaiting(t, queue, 1)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the cancelled request to give up, got %v", err)
+	}
+	release()
+	if stats := queue.Stats(); stats.Active != 0 || stats.Waiting != 0 {
+		t.Errorf("expected no request left, got %+v", stats)
+	}
+	if _, wait, err := queue.Acquire(context.Background(), 0); err != nil || wait != 0 {
+		t.Errorf("expected the free slot to be taken at once, got %s, %v", wait, err)
+	}
+}
+
+func TestParseQueueConfigs(t *testing.T) {
+	configs, err := ParseQueueConfigs([]string{"LMStudio=1", "google=8"}, 4)
+	if err != nil || configs["lmstudio"] != (QueueConfig{Concurrency: 1, MaxWaiting: 4}) || configs["google"].Concurrency != 8 {
+		t.Errorf("expected the queues of both providers, got %+v, %v", configs, err)
+	}
+	if _, err := ParseQueueConfigs([]string{"lmstudio"}, 0); err == nil {
+		t.Errorf("expected an entry without limit to fail")
+	}
+}
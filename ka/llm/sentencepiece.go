@@ -0,0 +1,283 @@
+package llm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Piece and model types from sentencepiece_model.proto. Control, unknown,
+// unused and byte pieces are not matched against text.
+const (
+	spPieceNormal      = 1
+	spPieceUserDefined = 4
+	spModelBPE         = 2
+)
+
+// sentencePieceTokenizer counts tokens with a SentencePiece model, as used by
+// Llama 2, Mistral and Gemma. It reproduces the segmentation for counting
+// only: pieces are scored, never mapped to IDs.
+type sentencePieceTokenizer struct {
+	spec          string
+	pieces        map[string]float32
+	maxPieceBytes int
+	bpe           bool
+	byteFallback  bool
+	unknownScore  float32
+
+	addDummyPrefix         bool
+	removeExtraWhitespaces bool
+
+	mu    sync.Mutex
+	cache map[string]int // Token counts of words seen before
+}
+
+func (t *sentencePieceTokenizer) Name() string      { return t.spec }
+func (t *sentencePieceTokenizer) Approximate() bool { return false }
+
+// loadSentencePiece reads a tokenizer.model file, a serialized ModelProto.
+func loadSentencePiece(spec, path string) (Tokenizer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tokenizer file %s: %w", path, err)
+	}
+	t := &sentencePieceTokenizer{
+		spec:                   spec,
+		pieces:                 make(map[string]float32),
+		addDummyPrefix:         true,
+		removeExtraWhitespaces: true,
+		cache:                  make(map[string]int),
+	}
+	minScore := float32(0)
+	err = walkProto(data, func(field int, value protoValue) error {
+		switch field {
+		case 1: // pieces
+			var piece string
+			var score float32
+			pieceType := uint64(spPieceNormal)
+			if err := walkProto(value.bytes, func(field int, value protoValue) error {
+				switch field {
+				case 1:
+					piece = string(value.bytes)
+				case 2:
+					score = math.Float32frombits(uint32(value.fixed))
+				case 3:
+					pieceType = value.varint
+				}
+				return nil
+			}); err != nil {
+				return err
+			}
+			if pieceType == spPieceNormal || pieceType == spPieceUserDefined {
+				t.pieces[piece] = score
+				if len(piece) > t.maxPieceBytes {
+					t.maxPieceBytes = len(piece)
+				}
+				if score < minScore {
+					minScore = score
+				}
+			}
+		case 2: // trainer_spec
+			return walkProto(value.bytes, func(field int, value protoValue) error {
+				switch field {
+				case 3:
+					t.bpe = value.varint == spModelBPE
+				case 35:
+					t.byteFallback = value.varint != 0
+				}
+				return nil
+			})
+		case 3: // normalizer_spec
+			return walkProto(value.bytes, func(field int, value protoValue) error {
+				switch field {
+				case 3:
+					t.addDummyPrefix = value.varint != 0
+				case 4:
+					t.removeExtraWhitespaces = value.varint != 0
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse SentencePiece model %s: %w", path, err)
+	}
+	if len(t.pieces) == 0 {
+		return nil, fmt.Errorf("SentencePiece model %s has no pieces", path)
+	}
+	t.unknownScore = minScore - 10
+	return t, nil
+}
+
+// CountTokens normalizes text like SentencePiece, with spaces as "▁", and
+// segments it word by word; pieces never span a word boundary.
+func (t *sentencePieceTokenizer) CountTokens(text string) int {
+	if t.removeExtraWhitespaces {
+		text = strings.Join(strings.Fields(text), " ")
+	}
+	if text == "" {
+		return 0
+	}
+	if t.addDummyPrefix {
+		text = " " + text
+	}
+	text = strings.ReplaceAll(text, " ", "▁")
+
+	count := 0
+	for len(text) > 0 {
+		end := strings.Index(text[1:], "▁") + 1
+		if end <= 0 {
+			end = len(text)
+		}
+		count += t.countWord(text[:end])
+		text = text[end:]
+	}
+	return count
+}
+
+func (t *sentencePieceTokenizer) countWord(word string) int {
+	t.mu.Lock()
+	n, ok := t.cache[word]
+	t.mu.Unlock()
+	if ok {
+		return n
+	}
+	if t.bpe {
+		n = t.countBPE(word)
+	} else {
+		n = t.countUnigram(word)
+	}
+	t.mu.Lock()
+	if len(t.cache) > 100000 {
+		t.cache = make(map[string]int)
+	}
+	t.cache[word] = n
+	t.mu.Unlock()
+	return n
+}
+
+// unknownTokens is the number of tokens of a rune not in the vocabulary.
+func (t *sentencePieceTokenizer) unknownTokens(r string) int {
+	if t.byteFallback {
+		return len(r)
+	}
+	return 1
+}
+
+// countBPE merges the adjacent pair with the highest scoring piece until no
+// pair forms a piece.
+func (t *sentencePieceTokenizer) countBPE(word string) int {
+	var symbols []string
+	for i, r := range word {
+		symbols = append(symbols, word[i:i+utf8.RuneLen(r)])
+	}
+	for len(symbols) > 1 {
+		best := -1
+		var bestScore float32
+		for i := 0; i+1 < len(symbols); i++ {
+			if score, ok := t.pieces[symbols[i]+symbols[i+1]]; ok && (best < 0 || score > bestScore) {
+				best, bestScore = i, score
+			}
+		}
+		if best < 0 {
+			break
+		}
+		symbols[best] += symbols[best+1]
+		symbols = append(symbols[:best+1], symbols[best+2:]...)
+	}
+	count := 0
+	for _, symbol := range symbols {
+		if _, ok := t.pieces[symbol]; ok {
+			count++
+		} else {
+			count += t.unknownTokens(symbol)
+		}
+	}
+	return count
+}
+
+// countUnigram finds the segmentation with the highest total score.
+func (t *sentencePieceTokenizer) countUnigram(word string) int {
+	type node struct {
+		score  float32
+		tokens int
+		set    bool
+	}
+	best := make([]node, len(word)+1)
+	best[0].set = true
+	for start := 0; start < len(word); {
+		_, size := utf8.DecodeRuneInString(word[start:])
+		if best[start].set {
+			for end := start + size; end <= len(word) && end-start <= t.maxPieceBytes; end++ {
+				if score, ok := t.pieces[word[start:end]]; ok {
+					candidate := node{score: best[start].score + score, tokens: best[start].tokens + 1, set: true}
+					if !best[end].set || candidate.score > best[end].score {
+						best[end] = candidate
+					}
+				}
+			}
+			unknown := node{score: best[start].score + t.unknownScore, tokens: best[start].tokens + t.unknownTokens(word[start:start+size]), set: true}
+			if !best[start+size].set || unknown.score > best[start+size].score {
+				best[start+size] = unknown
+			}
+		}
+		start += size
+	}
+	return best[len(word)].tokens
+}
+
+// protoValue is a decoded protobuf field value.
+type protoValue struct {
+	varint uint64
+	fixed  uint64
+	bytes  []byte
+}
+
+// walkProto calls fn for each field of a serialized protobuf message.
+func walkProto(data []byte, fn func(field int, value protoValue) error) error {
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		if n <= 0 {
+			return fmt.Errorf("invalid field key")
+		}
+		data = data[n:]
+		var value protoValue
+		switch key & 7 {
+		case 0:
+			if value.varint, n = binary.Uvarint(data); n <= 0 {
+				return fmt.Errorf("invalid varint")
+			}
+			data = data[n:]
+		case 1:
+			if len(data) < 8 {
+				return fmt.Errorf("truncated fixed64")
+			}
+			value.fixed = binary.LittleEndian.Uint64(data)
+			data = data[8:]
+		case 2:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < length {
+				return fmt.Errorf("truncated field")
+			}
+			value.bytes = data[n : n+int(length)]
+			data = data[n+int(length):]
+		case 5:
+			if len(data) < 4 {
+				return fmt.Errorf("truncated fixed32")
+			}
+			value.fixed = uint64(binary.LittleEndian.Uint32(data))
+			data = data[4:]
+		default:
+			return fmt.Errorf("unsupported wire type %d", key&7)
+		}
+		if err := fn(int(key>>3), value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// Default LLM timeouts. Generation can legitimately take minutes, so only the
+// phases where a healthy backend responds quickly are bounded tightly.
+const (
+	DefaultConnectTimeout    = 10 * time.Second
+	DefaultFirstTokenTimeout = 120 * time.Second
+	DefaultStallTimeout      = 60 * time.Second
+)
+
+// Timeouts bounds the phases of an LLM request. A zero value disables that bound.
+type Timeouts struct {
+	Connect    time.Duration // Establishing the TCP/TLS connection
+	FirstToken time.Duration // From sending the request until the first streamed chunk
+	Stall      time.Duration // Maximum gap between streamed chunks
+	// A response that is not streamed arrives at once after the whole
+	// generation, so only the deadline of the request's context bounds it.
+}
+
+// DefaultTimeouts returns the timeouts used when none are configured.
+func DefaultTimeouts() Timeouts {
+	return Timeouts{
+		Connect:    DefaultConnectTimeout,
+		FirstToken: DefaultFirstTokenTimeout,
+		Stall:      DefaultStallTimeout,
+	}
+}
+
+// Timeout phases reported in TimeoutError.
+const (
+	TimeoutPhaseFirstToken = "first_token"
+	TimeoutPhaseStall      = "stall"
+)
+
+// TimeoutError is returned when the backend does not respond within one of
+// the configured Timeouts. It is retryable: the request itself was valid.
+type TimeoutError struct {
+	Phase string
+	After time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("LLM %s timeout after %s", e.Phase, e.After)
+}
+
+// Retryable marks the error as safe to retry.
+func (e *TimeoutError) Retryable() bool { return true }
+
+// IsRetryable reports whether err (or an error it wraps) is marked retryable.
+func IsRetryable(err error) bool {
+	var retryable interface{ Retryable() bool }
+	if errors.As(err, &retryable) {
+		return retryable.Retryable()
+	}
+	var netErr net.Error
+	// Dial timeouts surface as net errors; the context was not canceled by the caller.
+	return errors.As(err, &netErr) && netErr.Timeout() && !errors.Is(err, context.Canceled)
+}
+
+// tokenWatchdog cancels a request when the first chunk or a following chunk
+// takes too long. Call Touch whenever data arrives.
+type tokenWatchdog struct {
+	mu         sync.Mutex
+	timeouts   Timeouts
+	timer      *time.Timer
+	generation int // Of the armed timer; a timer that fired after it was replaced does nothing
+	cancel     context.CancelFunc
+	started    bool
+	fired      *TimeoutError
+}
+
+// newTokenWatchdog derives a cancellable context from ctx and arms the
+// first-token timer. A request that is not streamed is not watched, see
+// Timeouts.
+func newTokenWatchdog(ctx context.Context, timeouts Timeouts, stream bool) (context.Context, *tokenWatchdog) {
+	watchCtx, cancel := context.WithCancel(ctx)
+	if !stream {
+		timeouts = Timeouts{}
+	}
+	w := &tokenWatchdog{timeouts: timeouts, cancel: cancel}
+	w.arm(timeouts.FirstToken, TimeoutPhaseFirstToken)
+	return watchCtx, w
+}
+
+// arm replaces the timer; the caller holds mu, unless no timer runs yet.
+func (w *tokenWatchdog) arm(after time.Duration, phase string) {
+	w.generation++
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+	if after <= 0 {
+		return
+	}
+	generation := w.generation
+	w.timer = time.AfterFunc(after, func() {
+		w.mu.Lock()
+		if generation != w.generation || w.fired != nil {
+			w.mu.Unlock()
+			return // Data arrived while the timer fired
+		}
+		w.fired = &TimeoutError{Phase: phase, After: after}
+		w.mu.Unlock()
+		log.Printf("[llm] %v, cancelling request", w.fired)
+		w.cancel()
+	})
+}
+
+// Touch records that data arrived and re-arms the timer with the stall timeout.
+func (w *tokenWatchdog) Touch() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.fired != nil {
+		return
+	}
+	w.started = true
+	w.arm(w.timeouts.Stall, TimeoutPhaseStall)
+}
+
+// Stop disarms the watchdog and releases its context.
+func (w *tokenWatchdog) Stop() {
+	w.mu.Lock()
+	w.arm(0, "")
+	w.mu.Unlock()
+	w.cancel()
+}
+
+// Err converts err into a TimeoutError if the watchdog caused it.
+func (w *tokenWatchdog) Err(err error) error {
+	if err == nil {
+		return nil
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.fired != nil {
+		return w.fired
+	}
+	return err
+}
+
+// RetryPolicy controls how often retryable LLM errors are retried.
+type RetryPolicy struct {
+	MaxAttempts int           // Total attempts including the first; values below 2 disable retries
+	Backoff     time.Duration // Delay before the first retry, doubled for each following retry
+}
+
+// retryingClient retries retryable errors as long as nothing was written to
+// the output yet, so streamed content is never duplicated.
+type retryingClient struct {
+	client LLMClient
+	policy RetryPolicy
+}
+
+// WithRetry wraps client so retryable errors are retried according to policy.
+func WithRetry(client LLMClient, policy RetryPolicy) LLMClient {
+	if policy.MaxAttempts < 2 {
+		return client
+	}
+	return &retryingClient{client: client, policy: policy}
+}
+
+func (r *retryingClient) Chat(ctx context.Context, messages []Message, stream bool, out io.Writer) (string, int, int, error) {
+	backoff := r.policy.Backoff
+	for attempt := 1; ; attempt++ {
+		counter := &countingWriter{w: out}
+		completion, inputTokens, completionTokens, err := r.client.Chat(ctx, messages, stream, counter)
+		if err == nil || attempt >= r.policy.MaxAttempts || !IsRetryable(err) || counter.n > 0 {
+			return completion, inputTokens, completionTokens, err
+		}
+		log.Printf("[llm] Attempt %d/%d failed with retryable error: %v. Retrying in %s.", attempt, r.policy.MaxAttempts, err, backoff)
+		select {
+		case <-ctx.Done():
+			return "", inputTokens, 0, err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += n
+	return n, err
+}
@@ -0,0 +1,124 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenWatchdog_FirstTokenTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	defer server.Close()
+
+	ctx, watchdog := newTokenWatchdog(context.Background(), Timeouts{FirstToken: 50 * time.Millisecond}, true)
+	defer watchdog.Stop()
+	req, _ := http.NewRequestWithContext(ctx, "POST", server.URL, nil)
+	_, err := newHTTPClient("test", Timeouts{}).Do(req)
+	err = watchdog.Err(err)
+
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &timeoutErr) || timeoutErr.Phase != TimeoutPhaseFirstToken {
+		t.Fatalf("Expected first token timeout, got %v", err)
+	}
+	if !IsRetryable(err) {
+		t.Errorf("Expected timeout error to be retryable")
+	}
+}
+
+type flakyClient struct {
+	calls int
+}
+
+func (f *flakyClient) Chat(ctx context.Context, messages []Message, stream bool, out io.Writer) (string, int, int, error) {
+	f.calls++
+	if f.calls == 1 {
+		return "", 0, 0, &TimeoutError{Phase: TimeoutPhaseFirstToken, After: time.Second}
+	}
+	return "ok", 1, 1, nil
+}
+
+func TestWithRetry_RetriesTimeout(t *testing.T) {
+	fake := &flakyClient{}
+	client := WithRetry(fake, RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond})
+
+	completion, _, _, err := client.Chat(context.Background(), nil, true, io.Discard)
+	if err != nil {
+		t.Fatalf("Expected retry to succeed, got %v", err)
+	}
+	if completion != "ok" || fake.calls != 2 {
+		t.Errorf("Expected 2 calls and completion 'ok', got %d calls and %q", fake.calls, completion)
+	}
+}
+
+func TestWithRetry_RetriesFirstTokenTimeoutAfterHeaders(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		if calls == 1 {
+			select { // Headers arrive, the first token never does
+			case <-r.Context().Done():
+			case <-time.After(2 * time.Second):
+			}
+			return
+		}
+		fmt.Fprint(w, "data: {\"choices\":[{\"delta\":{\"content\":\"ok\"},\"finish_reason\":\"stop\"}]}\n\n")
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	lmstudio := &LMStudioClient{APIURL: server.URL, Model: "test", MaxContextLength: 1000, Timeouts: Timeouts{FirstToken: 100 * time.Millisecond}}
+	client := WithRetry(lmstudio, RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond})
+	var out strings.Builder
+	completion, _, _, err := client.Chat(context.Background(), []Message{{Role: "user", Content: "hi"}}, true, &out)
+	if err != nil {
+		t.Fatalf("Expected the timeout after the headers to be retried, got %v", err)
+	}
+	if completion != "ok" || calls != 2 || out.String() != "ok" {
+		t.Errorf("Expected 2 calls streaming only 'ok', got %d calls, completion %q, output %q", calls, completion, out.String())
+	}
+}
+
+func TestTokenWatchdog_TimerReplacedWhileFiringDoesNothing(t *testing.T) {
+	ctx, watchdog := newTokenWatchdog(context.Background(), Timeouts{FirstToken: 10 * time.Millisecond, Stall: time.Hour}, true)
+	defer watchdog.Stop()
+
+	// Hold the lock as Touch does while the first-token timer fires, so its
+	// callback waits until the timer has been replaced.
+	watchdog.mu.Lock()
+	time.Sleep(50 * time.Millisecond)
+	watchdog.started = true
+	watchdog.arm(watchdog.timeouts.Stall, TimeoutPhaseStall)
+	watchdog.mu.Unlock()
+	time.Sleep(50 * time.Millisecond)
+
+	if err := ctx.Err(); err != nil {
+		t.Fatalf("Expected the request to continue after data arrived, got %v", err)
+	}
+	if err := watchdog.Err(context.Canceled); err != context.Canceled {
+		t.Errorf("Expected no timeout to be recorded, got %v", err)
+	}
+}
+
+func TestTokenWatchdog_NotStreamedIsNotBoundedByFirstToken(t *testing.T) {
+	ctx, watchdog := newTokenWatchdog(context.Background(), Timeouts{FirstToken: 10 * time.Millisecond}, false)
+	defer watchdog.Stop()
+
+	time.Sleep(50 * time.Millisecond)
+	if err := ctx.Err(); err != nil {
+		t.Fatalf("Expected a response that is not streamed to be bounded by ctx only, got %v", err)
+	}
+}
@@ -0,0 +1,253 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+)
+
+// Tokenizer counts the tokens of text the way a model family does.
+type Tokenizer interface {
+	// Name returns the tokenizer spec, e.g. "cl100k_base".
+	Name() string
+	CountTokens(text string) int
+	// Approximate reports whether counts are estimates rather than the
+	// model's own tokenization.
+	Approximate() bool
+}
+
+// cl100kPattern splits text into pieces before BPE for cl100k_base and the
+// tokenizers derived from it, such as the one of Llama 3.
+const cl100kPattern = `(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+(?!\S)|\s+`
+
+type tiktokenTokenizer struct {
+	name     string
+	encoding *tiktoken.Tiktoken
+}
+
+func (t *tiktokenTokenizer) Name() string                { return t.name }
+func (t *tiktokenTokenizer) CountTokens(text string) int { return len(t.encoding.EncodeOrdinary(text)) }
+func (t *tiktokenTokenizer) Approximate() bool           { return false }
+
+// loadTiktokenFile reads a BPE rank file in the tiktoken format ("<base64
+// token> <rank>" per line), which Llama 3 ships as tokenizer.model.
+func loadTiktokenFile(spec, path string) (Tokenizer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tokenizer file %s: %w", path, err)
+	}
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		token, err := base64.StdEncoding.DecodeString(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("tokenizer file %s: invalid token %q: %w", path, fields[0], err)
+		}
+		rank, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("tokenizer file %s: invalid rank %q", path, fields[1])
+		}
+		ranks[string(token)] = rank
+	}
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("tokenizer file %s has no tokens", path)
+	}
+	bpe, err := tiktoken.NewCoreBPE(ranks, map[string]int{}, cl100kPattern)
+	if err != nil {
+		return nil, err
+	}
+	encoding := &tiktoken.Encoding{Name: spec, PatStr: cl100kPattern, MergeableRanks: ranks, SpecialTokens: map[string]int{}}
+	return &tiktokenTokenizer{name: spec, encoding: tiktoken.NewTiktoken(bpe, encoding, map[string]any{})}, nil
+}
+
+// charTokenizer estimates four characters per token.
+type charTokenizer struct{}
+
+func (charTokenizer) Name() string                { return "chars" }
+func (charTokenizer) CountTokens(text string) int { return (len(text) + 3) / 4 }
+func (charTokenizer) Approximate() bool           { return true }
+
+// scaledTokenizer approximates a model family by scaling the counts of a
+// related tokenizer.
+type scaledTokenizer struct {
+	spec   string
+	base   Tokenizer
+	factor float64
+}
+
+func (t *scaledTokenizer) Name() string { return t.spec }
+func (t *scaledTokenizer) CountTokens(text string) int {
+	return int(math.Ceil(float64(t.base.CountTokens(text)) * t.factor))
+}
+func (t *scaledTokenizer) Approximate() bool { return true }
+
+// TokenizerRule maps model names matching Pattern, a case-insensitive
+// regular expression, to a tokenizer spec.
+type TokenizerRule struct {
+	Pattern   string
+	Tokenizer string
+}
+
+// DefaultTokenizerRules select a tokenizer by model family. Families whose
+// tokenizer is not bundled use the closest available one; configure a
+// sentencepiece: or tiktoken: model file for exact counts.
+var DefaultTokenizerRules = []TokenizerRule{
+	{Pattern: `gpt-4o|gpt-4\.1|gpt-5|(^|/)o[1-4]\b`, Tokenizer: "o200k_base"},
+	{Pattern: `gpt-4|gpt-3\.5|text-embedding`, Tokenizer: "cl100k_base"},
+	{Pattern: `llama-?3|qwen|deepseek`, Tokenizer: "cl100k_base"},                 // Byte-level BPE vocabularies close to cl100k
+	{Pattern: `gemini|gemma`, Tokenizer: "o200k_base"},                            // 256k SentencePiece vocabulary
+	{Pattern: `llama-?2|mistral|mixtral|codellama`, Tokenizer: "cl100k_base*1.2"}, // 32k SentencePiece vocabulary
+}
+
+// DefaultTokenizer is used for models no rule matches.
+const DefaultTokenizer = "cl100k_base"
+
+// TokenizerRegistry resolves model names to tokenizers. Overrides are checked
+// before the default rules.
+type TokenizerRegistry struct {
+	mu        sync.Mutex
+	overrides []TokenizerRule
+	loaded    map[string]Tokenizer
+}
+
+// NewTokenizerRegistry creates a registry with only the default rules.
+func NewTokenizerRegistry() *TokenizerRegistry {
+	return &TokenizerRegistry{loaded: make(map[string]Tokenizer)}
+}
+
+// Tokenizers is the registry the LLM clients use.
+var Tokenizers = NewTokenizerRegistry()
+
+// SetOverrides replaces the override rules. Patterns are validated here, so
+// a bad configuration fails at startup.
+func (r *TokenizerRegistry) SetOverrides(rules []TokenizerRule) error {
+	for _, rule := range rules {
+		if _, err := regexp.Compile("(?i)" + rule.Pattern); err != nil {
+			return fmt.Errorf("invalid tokenizer pattern %q: %w", rule.Pattern, err)
+		}
+		if rule.Tokenizer == "" {
+			return fmt.Errorf("tokenizer pattern %q has no tokenizer", rule.Pattern)
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.overrides = rules
+	return nil
+}
+
+// LoadTokenizerOverrides parses override rules from a JSON object mapping
+// model patterns to tokenizer specs, given inline or as a file path.
+func LoadTokenizerOverrides(config string) ([]TokenizerRule, error) {
+	data := []byte(config)
+	if !strings.HasPrefix(strings.TrimSpace(config), "{") {
+		fileData, err := os.ReadFile(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tokenizers file %s: %w", config, err)
+		}
+		data = fileData
+	}
+	var mapping map[string]string
+	if err := json.Unmarshal(data, &mapping); err != nil {
+		return nil, fmt.Errorf("failed to parse tokenizers: %w", err)
+	}
+	rules := make([]TokenizerRule, 0, len(mapping))
+	for pattern, spec := range mapping {
+		rules = append(rules, TokenizerRule{Pattern: pattern, Tokenizer: spec})
+	}
+	// Longer patterns are more specific; check them first
+	sort.Slice(rules, func(i, j int) bool {
+		if len(rules[i].Pattern) != len(rules[j].Pattern) {
+			return len(rules[i].Pattern) > len(rules[j].Pattern)
+		}
+		return rules[i].Pattern < rules[j].Pattern
+	})
+	return rules, nil
+}
+
+// SpecFor returns the tokenizer spec for a model.
+func (r *TokenizerRegistry) SpecFor(model string) string {
+	r.mu.Lock()
+	overrides := r.overrides
+	r.mu.Unlock()
+	for _, rules := range [][]TokenizerRule{overrides, DefaultTokenizerRules} {
+		for _, rule := range rules {
+			if matched, _ := regexp.MatchString("(?i)"+rule.Pattern, model); matched {
+				return rule.Tokenizer
+			}
+		}
+	}
+	return DefaultTokenizer
+}
+
+// ForModel returns the tokenizer for a model.
+func (r *TokenizerRegistry) ForModel(model string) (Tokenizer, error) {
+	return r.Load(r.SpecFor(model))
+}
+
+// Load returns the tokenizer for a spec, loading it once. Specs are
+//
+//	cl100k_base, o200k_base, p50k_base, r50k_base  tiktoken encodings
+//	tiktoken:<path>                                a tiktoken-format BPE rank file
+//	sentencepiece:<path>                           a SentencePiece tokenizer.model
+//	chars                                          four characters per token
+//
+// optionally followed by *<factor> to scale the counts.
+func (r *TokenizerRegistry) Load(spec string) (Tokenizer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if tokenizer, ok := r.loaded[spec]; ok {
+		return tokenizer, nil
+	}
+	tokenizer, err := loadTokenizer(spec)
+	if err != nil {
+		return nil, err
+	}
+	r.loaded[spec] = tokenizer
+	return tokenizer, nil
+}
+
+func loadTokenizer(spec string) (Tokenizer, error) {
+	if i := strings.LastIndex(spec, "*"); i > 0 {
+		factor, err := strconv.ParseFloat(spec[i+1:], 64)
+		if err != nil || factor <= 0 {
+			return nil, fmt.Errorf("tokenizer %q: invalid scale factor", spec)
+		}
+		base, err := loadTokenizer(spec[:i])
+		if err != nil {
+			return nil, err
+		}
+		return &scaledTokenizer{spec: spec, base: base, factor: factor}, nil
+	}
+	kind, path, _ := strings.Cut(spec, ":")
+	switch kind {
+	case "chars":
+		return charTokenizer{}, nil
+	case "tiktoken":
+		return loadTiktokenFile(spec, path)
+	case "sentencepiece":
+		return loadSentencePiece(spec, path)
+	case "cl100k_base", "o200k_base", "p50k_base", "r50k_base", "p50k_edit":
+		encoding, err := tiktoken.GetEncoding(kind)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load tiktoken encoding %s: %w", kind, err)
+		}
+		return &tiktokenTokenizer{name: spec, encoding: encoding}, nil
+	default:
+		return nil, fmt.Errorf("unknown tokenizer %q (expected a tiktoken encoding, tiktoken:<path>, sentencepiece:<path> or chars)", spec)
+	}
+}
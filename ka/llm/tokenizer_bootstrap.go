@@ -0,0 +1,281 @@
+package llm
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkoukk/tiktoken-go"
+)
+
+// Token counting modes, from the most to the least accurate.
+const (
+	TokenCountExact       = "exact"       // The model's own tokenizer
+	TokenCountApproximate = "approximate" // A related tokenizer, possibly scaled
+	TokenCountEstimate    = "estimate"    // Characters with a safety margin; no tokenizer could be loaded
+)
+
+// EstimateSafetyMargin scales the character-based estimate up, so prompts
+// are truncated early rather than sent over the context limit: code and
+// non-English text have fewer than four characters per token.
+const EstimateSafetyMargin = 1.25
+
+// estimateTokenizer is used when no tokenizer can be loaded. It counts bytes
+// rather than characters, which overcounts multi-byte text on purpose.
+type estimateTokenizer struct{}
+
+func (estimateTokenizer) Name() string { return TokenCountEstimate }
+func (estimateTokenizer) CountTokens(text string) int {
+	return int(math.Ceil(float64(len(text)) / 4 * EstimateSafetyMargin))
+}
+func (estimateTokenizer) Approximate() bool { return true }
+
+// bpeDownloadAttempts and bpeDownloadTimeout bound the download of a BPE rank
+// file, so an offline start degrades to estimation instead of hanging. After
+// a failure, loads fail fast for bpeRetryAfter instead of downloading again.
+const (
+	bpeDownloadAttempts = 3
+	bpeDownloadTimeout  = 30 * time.Second
+	bpeRetryAfter       = 5 * time.Minute
+)
+
+// bpeRetryBackoff is the wait before the second download attempt, growing linearly.
+var bpeRetryBackoff = time.Second
+
+type bpeFailure struct {
+	at  time.Time
+	err error
+}
+
+// cachingBpeLoader replaces the loader of tiktoken-go, which caches in the
+// temporary directory and caches whatever a download returned, error pages
+// included. Files are validated before they are cached and again when read.
+type cachingBpeLoader struct {
+	mu     sync.Mutex
+	dir    string
+	client *http.Client
+	failed map[string]bpeFailure // By URL
+}
+
+var bpeLoader = &cachingBpeLoader{dir: defaultTokenizerCacheDir(), client: &http.Client{Timeout: bpeDownloadTimeout}, failed: make(map[string]bpeFailure)}
+
+func init() {
+	tiktoken.SetBpeLoader(bpeLoader)
+}
+
+// defaultTokenizerCacheDir honours TIKTOKEN_CACHE_DIR like tiktoken-go, and
+// otherwise keeps downloads in the user cache, which survives reboots.
+func defaultTokenizerCacheDir() string {
+	if dir := os.Getenv("TIKTOKEN_CACHE_DIR"); dir != "" {
+		return dir
+	}
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, "ka", "tiktoken")
+	}
+	return filepath.Join(os.TempDir(), "data-gym-cache")
+}
+
+// SetTokenizerCacheDir sets the directory downloaded tokenizer files are
+// cached in. Put the files there in advance to run without network access.
+func SetTokenizerCacheDir(dir string) {
+	bpeLoader.mu.Lock()
+	defer bpeLoader.mu.Unlock()
+	bpeLoader.dir = dir
+	bpeLoader.failed = make(map[string]bpeFailure) // The files may be there
+}
+
+// TokenizerCacheDir returns the directory of downloaded tokenizer files.
+func TokenizerCacheDir() string {
+	bpeLoader.mu.Lock()
+	defer bpeLoader.mu.Unlock()
+	return bpeLoader.dir
+}
+
+// LoadTiktokenBpe implements tiktoken.BpeLoader.
+func (l *cachingBpeLoader) LoadTiktokenBpe(url string) (map[string]int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// The file name is the one tiktoken-go uses, so its caches are reused
+	cachePath := filepath.Join(l.dir, fmt.Sprintf("%x", sha1.Sum([]byte(url))))
+	if data, err := os.ReadFile(cachePath); err == nil {
+		ranks, err := parseBpeRanks(data)
+		if err == nil {
+			return ranks, nil
+		}
+		log.Printf("[Tokenizer] Ignoring invalid cached tokenizer file %s: %v", cachePath, err)
+	}
+	if failure, ok := l.failed[url]; ok && time.Since(failure.at) < bpeRetryAfter {
+		return nil, failure.err
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= bpeDownloadAttempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(time.Duration(attempt-1) * bpeRetryBackoff)
+		}
+		data, err := l.download(url)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		ranks, err := parseBpeRanks(data)
+		if err != nil {
+			lastErr = fmt.Errorf("invalid tokenizer file from %s: %w", url, err)
+			continue
+		}
+		if err := writeFileAtomic(cachePath, data); err != nil {
+			log.Printf("[Tokenizer] Could not cache tokenizer file %s: %v", cachePath, err)
+		}
+		delete(l.failed, url)
+		return ranks, nil
+	}
+	err := fmt.Errorf("failed to download tokenizer file %s after %d attempts (place it in %s to run offline): %w", url, bpeDownloadAttempts, l.dir, lastErr)
+	l.failed[url] = bpeFailure{at: time.Now(), err: err}
+	return nil, err
+}
+
+func (l *cachingBpeLoader) download(url string) ([]byte, error) {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return os.ReadFile(url)
+	}
+	resp, err := l.client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("downloading %s: HTTP status %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// parseBpeRanks parses a tiktoken BPE rank file, "<base64 token> <rank>" per line.
+func parseBpeRanks(data []byte) (map[string]int, error) {
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		encoded, rankText, ok := strings.Cut(line, " ")
+		if !ok {
+			return nil, fmt.Errorf("malformed line %q", line)
+		}
+		token, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid token %q: %w", encoded, err)
+		}
+		rank, err := strconv.Atoi(rankText)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rank %q", rankText)
+		}
+		ranks[string(token)] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("no tokens")
+	}
+	return ranks, nil
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// loadClientTokenizer picks the tokenizer of a model: the one of its family,
+// else the default one, else the estimate. The error explains a fallback.
+func loadClientTokenizer(model string) (Tokenizer, error) {
+	tokenizer, err := Tokenizers.ForModel(model)
+	if err == nil {
+		return tokenizer, nil
+	}
+	log.Printf("[Tokenizer] Could not load the tokenizer of model '%s', falling back to '%s': %v", model, DefaultTokenizer, err)
+	fallback, fallbackErr := Tokenizers.Load(DefaultTokenizer)
+	if fallbackErr == nil {
+		return fallback, err
+	}
+	err = fmt.Errorf("%v; fallback %s: %w", err, DefaultTokenizer, fallbackErr)
+	log.Printf("[Tokenizer] No tokenizer available, estimating token counts from characters with a %.0f%% safety margin: %v", (EstimateSafetyMargin-1)*100, err)
+	return estimateTokenizer{}, err
+}
+
+// TokenCounting describes how the tokens of a client's model are counted.
+type TokenCounting struct {
+	Mode      string `json:"mode"` // TokenCountExact, TokenCountApproximate or TokenCountEstimate
+	Model     string `json:"model,omitempty"`
+	Tokenizer string `json:"tokenizer"`
+	CacheDir  string `json:"cacheDir"`
+	Error     string `json:"error,omitempty"` // Why the model's tokenizer could not be loaded
+}
+
+// TokenCountingOf reports the token counting mode in effect for a client.
+func TokenCountingOf(client LLMClient) TokenCounting {
+	counting := TokenCounting{Mode: TokenCountEstimate, Tokenizer: estimateTokenizer{}.Name(), CacheDir: TokenizerCacheDir()}
+	inner := innermostClient(client)
+	var tokenizer Tokenizer
+	switch c := inner.(type) {
+	case *LMStudioClient:
+		counting.Model, tokenizer = c.Model, c.tokenizer
+		if c.tokenizerErr != nil {
+			counting.Error = c.tokenizerErr.Error()
+		}
+	case *OllamaClient:
+		counting.Model, tokenizer = c.Model, c.tokenizer
+		if c.tokenizerErr != nil {
+			counting.Error = c.tokenizerErr.Error()
+		}
+	case *GoogleClient:
+		counting.Model = c.Model
+		var err error
+		if tokenizer, err = Tokenizers.ForModel(c.Model); err != nil {
+			counting.Error = err.Error()
+		}
+	default:
+		if provider, ok := inner.(TokenizerProvider); ok {
+			tokenizer = provider.Tokenizer()
+		}
+	}
+	if tokenizer == nil {
+		return counting
+	}
+	counting.Tokenizer = tokenizer.Name()
+	switch {
+	case tokenizer.Name() == TokenCountEstimate:
+	case tokenizer.Approximate() || counting.Error != "":
+		counting.Mode = TokenCountApproximate
+	default:
+		counting.Mode = TokenCountExact
+	}
+	return counting
+}
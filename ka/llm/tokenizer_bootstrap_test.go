@@ -0,0 +1,85 @@
+package llm
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachingBpeLoader(t *testing.T) {
+	defer func(backoff time.Duration) { bpeRetryBackoff = backoff }(bpeRetryBackoff)
+	bpeRetryBackoff = 0
+
+	ranks := fmt.Sprintf("%s 0\n%s 1\n", base64.StdEncoding.EncodeToString([]byte("a")), base64.StdEncoding.EncodeToString([]byte("b")))
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch requests {
+		case 1:
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		case 2:
+			w.Write([]byte("<html>not a rank file</html>"))
+		default:
+			w.Write([]byte(ranks))
+		}
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	loader := &cachingBpeLoader{dir: dir, client: server.Client(), failed: make(map[string]bpeFailure)}
+	got, err := loader.LoadTiktokenBpe(server.URL + "/cl100k_base.tiktoken")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"a": 0, "b": 1}, got)
+	assert.Equal(t, 3, requests, "expected the error status and the invalid file to be retried")
+
+	// The valid file was cached, the invalid one was not
+	entries, _ := os.ReadDir(dir)
+	require.Len(t, entries, 1)
+	cached, _ := os.ReadFile(filepath.Join(dir, entries[0].Name()))
+	assert.Equal(t, ranks, string(cached))
+	_, err = loader.LoadTiktokenBpe(server.URL + "/cl100k_base.tiktoken")
+	require.NoError(t, err)
+	assert.Equal(t, 3, requests, "expected the cached file to be used")
+}
+
+func TestCachingBpeLoaderFailsFastAfterFailure(t *testing.T) {
+	defer func(backoff time.Duration) { bpeRetryBackoff = backoff }(bpeRetryBackoff)
+	bpeRetryBackoff = 0
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	loader := &cachingBpeLoader{dir: t.TempDir(), client: server.Client(), failed: make(map[string]bpeFailure)}
+	_, err := loader.LoadTiktokenBpe(server.URL)
+	require.Error(t, err)
+	assert.Equal(t, bpeDownloadAttempts, requests)
+	_, again := loader.LoadTiktokenBpe(server.URL)
+	assert.Equal(t, err, again)
+	assert.Equal(t, bpeDownloadAttempts, requests, "expected no download right after a failure")
+}
+
+func TestTokenCountingOf(t *testing.T) {
+	estimating := &LMStudioClient{Model: "local", tokenizer: estimateTokenizer{}, tokenizerErr: errors.New("offline")}
+	counting := TokenCountingOf(WithRetry(estimating, RetryPolicy{MaxAttempts: 1}))
+	assert.Equal(t, TokenCountEstimate, counting.Mode)
+	assert.Equal(t, "local", counting.Model)
+	assert.Equal(t, "offline", counting.Error)
+	assert.Equal(t, 13, estimating.CountTokens("forty characters of text, more or less.."))
+
+	scaled := &LMStudioClient{Model: "mistral", tokenizer: &scaledTokenizer{spec: "chars*1.2", base: charTokenizer{}, factor: 1.2}}
+	assert.Equal(t, TokenCountApproximate, TokenCountingOf(scaled).Mode)
+
+	assert.Equal(t, TokenCountEstimate, TokenCountingOf(&LMStudioClient{}).Mode, "expected a client without tokenizer to estimate")
+}
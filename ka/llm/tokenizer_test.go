@@ -0,0 +1,153 @@
+package llm
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenizerRegistry_SpecFor(t *testing.T) {
+	registry := NewTokenizerRegistry()
+	for model, want := range map[string]string{
+		"gpt-4o-mini": "o200k_base",
+		"gpt-4-turbo": "cl100k_base",
+		"lmstudio-community/Meta-Llama-3-8B-Instruct": "cl100k_base",
+		"qwen2.5-coder-7b-instruct":                   "cl100k_base",
+		"gemini-2.5-pro-preview-05-06":                "o200k_base",
+		"google/gemma-2-9b":                           "o200k_base",
+		"mistral-7b-instruct-v0.2":                    "cl100k_base*1.2",
+		"TheBloke/Llama-2-13B-chat-GGUF":              "cl100k_base*1.2",
+		"":                                            DefaultTokenizer,
+		"some-unknown-model":                          DefaultTokenizer,
+	} {
+		assert.Equal(t, want, registry.SpecFor(model), model)
+	}
+
+	rules, err := LoadTokenizerOverrides(`{"qwen": "chars", "qwen2\\.5-coder": "chars*2"}`)
+	require.NoError(t, err)
+	require.NoError(t, registry.SetOverrides(rules))
+	assert.Equal(t, "chars*2", registry.SpecFor("qwen2.5-coder-7b-instruct"))
+	assert.Equal(t, "chars", registry.SpecFor("qwen2-7b"))
+	assert.Equal(t, "o200k_base", registry.SpecFor("gpt-4o"))
+
+	assert.Error(t, registry.SetOverrides([]TokenizerRule{{Pattern: "(", Tokenizer: "chars"}}))
+}
+
+func TestTokenizerRegistry_Load(t *testing.T) {
+	registry := NewTokenizerRegistry()
+
+	chars, err := registry.Load("chars")
+	require.NoError(t, err)
+	assert.Equal(t, 3, chars.CountTokens("0123456789"))
+	assert.True(t, chars.Approximate())
+
+	scaled, err := registry.Load("chars*1.5")
+	require.NoError(t, err)
+	assert.Equal(t, 5, scaled.CountTokens("0123456789"))
+	assert.Equal(t, "chars*1.5", scaled.Name())
+
+	again, err := registry.Load("chars*1.5")
+	require.NoError(t, err)
+	assert.Same(t, scaled, again)
+
+	for _, spec := range []string{"unknown", "chars*0", "sentencepiece:/does/not/exist"} {
+		_, err := registry.Load(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestTiktokenFileTokenizer(t *testing.T) {
+	var lines []string
+	for rank, token := range []string{"a", "b", "c", " ", "ab", "abc", " abc"} {
+		lines = append(lines, base64.StdEncoding.EncodeToString([]byte(token))+" "+string(rune('0'+rank)))
+	}
+	path := filepath.Join(t.TempDir(), "tokenizer.model")
+	require.NoError(t, os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0644))
+
+	tokenizer, err := NewTokenizerRegistry().Load("tiktoken:" + path)
+	require.NoError(t, err)
+	assert.False(t, tokenizer.Approximate())
+	assert.Equal(t, 2, tokenizer.CountTokens("abc abc"))
+	assert.Equal(t, 3, tokenizer.CountTokens("abcba"))
+}
+
+// spModel serializes a minimal SentencePiece ModelProto.
+func spModel(modelType uint64, byteFallback bool, pieces map[string]float32) []byte {
+	var model []byte
+	for piece, score := range pieces {
+		var p []byte
+		p = appendProtoBytes(p, 1, []byte(piece))
+		p = binary.AppendUvarint(p, 2<<3|5)
+		p = binary.LittleEndian.AppendUint32(p, math.Float32bits(score))
+		model = appendProtoBytes(model, 1, p)
+	}
+	var trainer []byte
+	trainer = binary.AppendUvarint(trainer, 3<<3)
+	trainer = binary.AppendUvarint(trainer, modelType)
+	if byteFallback {
+		trainer = binary.AppendUvarint(trainer, 35<<3)
+		trainer = binary.AppendUvarint(trainer, 1)
+	}
+	return appendProtoBytes(model, 2, trainer)
+}
+
+func appendProtoBytes(b []byte, field uint64, value []byte) []byte {
+	b = binary.AppendUvarint(b, field<<3|2)
+	b = binary.AppendUvarint(b, uint64(len(value)))
+	return append(b, value...)
+}
+
+func loadTestSentencePiece(t *testing.T, model []byte) Tokenizer {
+	path := filepath.Join(t.TempDir(), "tokenizer.model")
+	require.NoError(t, os.WriteFile(path, model, 0644))
+	tokenizer, err := NewTokenizerRegistry().Load("sentencepiece:" + path)
+	require.NoError(t, err)
+	return tokenizer
+}
+
+func TestSentencePieceTokenizer_BPE(t *testing.T) {
+	tokenizer := loadTestSentencePiece(t, spModel(2, true, map[string]float32{
+		"▁": -1, "h": -2, "e": -2, "l": -2, "o": -2, "w": -2, "r": -2, "d": -2,
+		"he": -3, "ll": -3, "hell": -4, "▁hell": -5, "▁hello": -6, "or": -3, "▁w": -4,
+	}))
+	assert.False(t, tokenizer.Approximate())
+
+	// ▁hello | ▁w or l d
+	assert.Equal(t, 5, tokenizer.CountTokens("hello world"))
+	// Extra whitespace is removed before segmenting
+	assert.Equal(t, 5, tokenizer.CountTokens("  hello   world "))
+	// Unknown characters fall back to one token per byte
+	assert.Equal(t, 1+len("é"), tokenizer.CountTokens("é"))
+	assert.Equal(t, 0, tokenizer.CountTokens(""))
+}
+
+func TestSentencePieceTokenizer_Unigram(t *testing.T) {
+	tokenizer := loadTestSentencePiece(t, spModel(1, false, map[string]float32{
+		"▁": -3, "a": -4, "b": -4, "▁ab": -2, "▁a": -3, "bb": -1,
+	}))
+
+	// ▁ab scores better than ▁a + b
+	assert.Equal(t, 1, tokenizer.CountTokens("ab"))
+	// ▁a + bb (-4) beats ▁ab + b (-6)
+	assert.Equal(t, 2, tokenizer.CountTokens("abb"))
+	// Unknown characters count as one token without byte fallback
+	assert.Equal(t, 3, tokenizer.CountTokens("ab ü"))
+}
+
+func TestCountTokens(t *testing.T) {
+	client := &LMStudioClient{tokenizer: charTokenizer{}}
+	n, exact := CountTokens(client, "0123456789")
+	assert.Equal(t, 3, n)
+	assert.False(t, exact)
+
+	n, exact = CountTokens(nil, "0123")
+	assert.Equal(t, 2, n) // With the safety margin of the estimate
+	assert.False(t, exact)
+}
@@ -0,0 +1,64 @@
+package llm
+
+import "log"
+
+// TokenCounter is implemented by clients that count tokens with the
+// tokenizer of their model.
+type TokenCounter interface {
+	CountTokens(text string) int
+}
+
+// TokenizerProvider is implemented by clients that know the tokenizer of
+// their model.
+type TokenizerProvider interface {
+	// Tokenizer returns the tokenizer, or nil if it is not available.
+	Tokenizer() Tokenizer
+}
+
+// CountTokens implements TokenCounter.
+func (c *LMStudioClient) CountTokens(text string) int {
+	return c.getTokenLength(text)
+}
+
+// Tokenizer implements TokenizerProvider.
+func (c *LMStudioClient) Tokenizer() Tokenizer {
+	return c.tokenizer
+}
+
+// Tokenizer implements TokenizerProvider. The tokenizer is loaded on first use.
+func (c *GoogleClient) Tokenizer() Tokenizer {
+	tokenizer, err := Tokenizers.ForModel(c.Model)
+	if err != nil {
+		log.Printf("[GoogleClient] No tokenizer for model %s: %v", c.Model, err)
+		return nil
+	}
+	return tokenizer
+}
+
+// Unwrap returns the wrapped client.
+func (r *retryingClient) Unwrap() LLMClient {
+	return r.client
+}
+
+// CountTokens counts the tokens of text with the tokenizer of the client's
+// model. Clients without one get an estimate of four characters per token,
+// scaled by EstimateSafetyMargin; exact reports whether the count is the
+// model's own tokenization rather than an estimate.
+func CountTokens(client LLMClient, text string) (n int, exact bool) {
+	for client != nil {
+		if provider, ok := client.(TokenizerProvider); ok {
+			if tokenizer := provider.Tokenizer(); tokenizer != nil {
+				return tokenizer.CountTokens(text), !tokenizer.Approximate()
+			}
+		}
+		if counter, ok := client.(TokenCounter); ok {
+			return counter.CountTokens(text), true
+		}
+		wrapper, ok := client.(interface{ Unwrap() LLMClient })
+		if !ok {
+			break
+		}
+		client = wrapper.Unwrap()
+	}
+	return estimateTokenizer{}.CountTokens(text), false
+}
@@ -0,0 +1,26 @@
+package llm
+
+// Usage is the token usage a provider reports for one completion.
+type Usage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+}
+
+// StreamOptions asks OpenAI-compatible backends for a final usage chunk in
+// streaming responses.
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
+}
+
+// geminiUsage is the usageMetadata object of Gemini responses.
+type geminiUsage struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+}
+
+func (u *geminiUsage) toUsage() *Usage {
+	if u == nil {
+		return nil
+	}
+	return &Usage{PromptTokens: u.PromptTokenCount, CompletionTokens: u.CandidatesTokenCount}
+}
@@ -0,0 +1,69 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func streamServer(t *testing.T, chunks []string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req Request
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.StreamOptions == nil || !req.StreamOptions.IncludeUsage {
+			t.Errorf("expected stream_options.include_usage in a streaming request")
+		}
+		for _, chunk := range chunks {
+			fmt.Fprintf(w, "data: %s\n\n", chunk)
+		}
+		fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+}
+
+func TestStreamingUsesReportedUsage(t *testing.T) {
+	server := streamServer(t, []string{
+		`{"choices":[{"delta":{"content":"Hello"}}]}`,
+		`{"choices":[{"delta":{"content":" world"}}]}`,
+		`{"choices":[],"usage":{"prompt_tokens":42,"completion_tokens":7}}`,
+	})
+	defer server.Close()
+
+	client := &LMStudioClient{APIURL: server.URL, Model: "test", MaxContextLength: 1000}
+	completion, inputTokens, completionTokens, err := client.Chat(context.Background(), []Message{{Role: "user", Content: "hi"}}, true, io.Discard)
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if completion != "Hello world" {
+		t.Errorf("unexpected completion %q", completion)
+	}
+	if inputTokens != 42 || completionTokens != 7 {
+		t.Errorf("expected the reported 42/7 tokens, got %d/%d", inputTokens, completionTokens)
+	}
+}
+
+func TestStreamingFallsBackToLocalCount(t *testing.T) {
+	server := streamServer(t, []string{`{"choices":[{"delta":{"content":"Hello"}}]}`})
+	defer server.Close()
+
+	client := &LMStudioClient{APIURL: server.URL, Model: "test", MaxContextLength: 1000}
+	_, _, completionTokens, err := client.Chat(context.Background(), []Message{{Role: "user", Content: "hi"}}, true, io.Discard)
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+	if completionTokens != 2 { // No tokenizer: 5 characters / 4 with the safety margin
+		t.Errorf("expected the local estimate of 2, got %d", completionTokens)
+	}
+}
+
+func TestParseChunkUsageGemini(t *testing.T) {
+	var chunk map[string]interface{}
+	json.Unmarshal([]byte(`{"usageMetadata":{"promptTokenCount":3,"candidatesTokenCount":9}}`), &chunk)
+	usage := parseChunkUsage(chunk)
+	if usage == nil || usage.PromptTokens != 3 || usage.CompletionTokens != 9 {
+		t.Errorf("unexpected usage %+v", usage)
+	}
+}
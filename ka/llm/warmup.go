@@ -0,0 +1,156 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+// Model warm-up states.
+const (
+	WarmupPending = "pending" // Not attempted yet
+	WarmupRunning = "warming"
+	WarmupReady   = "ready"
+	WarmupFailed  = "failed"
+)
+
+// warmupPrompt is the prompt that makes a local backend load its model.
+const warmupPrompt = "Hi"
+
+// WarmupStatus reports the model warm-up of a ModelWarmer.
+type WarmupStatus struct {
+	State      string    `json:"state"`
+	Model      string    `json:"model,omitempty"`
+	WarmedAt   time.Time `json:"warmedAt,omitempty"`   // End of the last successful warm-up
+	DurationMs int64     `json:"durationMs,omitempty"` // Of the last warm-up, mostly the model load
+	Warmups    int       `json:"warmups"`
+	Error      string    `json:"error,omitempty"` // Of the last failed warm-up
+}
+
+// ModelWarmer loads the model of a local provider (LM Studio or Ollama) with a one-token prompt, so the first
+// real task does not wait for the model to load. With IdleAfter set it warms
+// the model again after idle periods, before the backend unloads it.
+type ModelWarmer struct {
+	Client    LLMClient
+	IdleAfter time.Duration // Warm again after this long without LLM requests; 0 warms only once
+	Timeout   time.Duration // Per warm-up; 0 waits as long as the backend needs
+
+	mu     sync.Mutex
+	status WarmupStatus
+}
+
+// innermostClient unwraps clients such as the retrying one down to the
+// provider client.
+func innermostClient(client LLMClient) LLMClient {
+	for {
+		wrapper, ok := client.(interface{ Unwrap() LLMClient })
+		if !ok {
+			return client
+		}
+		client = wrapper.Unwrap()
+	}
+}
+
+// localModel returns the provider and model of a client served by a local
+// backend.
+func localModel(client LLMClient) (provider, model string, err error) {
+	switch c := innermostClient(client).(type) {
+	case *LMStudioClient:
+		return "lmstudio", c.Model, nil
+	case *OllamaClient:
+		return "ollama", c.Model, nil
+	default:
+		return "", "", fmt.Errorf("model warm-up is only supported for local providers, not %T", c)
+	}
+}
+
+// Status returns the current warm-up status.
+func (w *ModelWarmer) Status() WarmupStatus {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	status := w.status
+	if status.State == "" {
+		status.State = WarmupPending
+	}
+	return status
+}
+
+// Ready reports whether the model was warmed up at least once. A later
+// failure keeps it ready: the backend may still have the model loaded.
+func (w *ModelWarmer) Ready() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return !w.status.WarmedAt.IsZero()
+}
+
+// WarmUp sends the warm-up prompt and waits for the answer.
+func (w *ModelWarmer) WarmUp(ctx context.Context) error {
+	_, model, err := localModel(w.Client)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	w.status.State, w.status.Model = WarmupRunning, model
+	w.mu.Unlock()
+
+	if w.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, w.Timeout)
+		defer cancel()
+	}
+	ctx = WithGenerationOptions(ctx, GenerationOptions{MaxTokens: 1})
+	start := time.Now()
+	_, _, _, err = innermostClient(w.Client).Chat(ctx, []Message{{Role: "user", Content: warmupPrompt}}, false, io.Discard)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.status.Warmups++
+	w.status.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		w.status.State, w.status.Error = WarmupFailed, err.Error()
+		return fmt.Errorf("warm-up of model %s failed: %w", model, err)
+	}
+	w.status.State, w.status.Error, w.status.WarmedAt = WarmupReady, "", time.Now()
+	return nil
+}
+
+// Run warms the model up and, with IdleAfter set, again whenever the backend
+// received no request for that long, until ctx is done.
+func (w *ModelWarmer) Run(ctx context.Context) {
+	provider, _, err := localModel(w.Client)
+	if err != nil {
+		log.Printf("[ModelWarmer] %v", err)
+		w.mu.Lock()
+		w.status.State, w.status.Error = WarmupFailed, err.Error()
+		w.mu.Unlock()
+		return
+	}
+	w.warmUpLogged(ctx)
+	if w.IdleAfter <= 0 {
+		return
+	}
+	ticker := time.NewTicker(max(w.IdleAfter/4, time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if time.Since(lastRequestAt(provider)) >= w.IdleAfter {
+				w.warmUpLogged(ctx)
+			}
+		}
+	}
+}
+
+func (w *ModelWarmer) warmUpLogged(ctx context.Context) {
+	if err := w.WarmUp(ctx); err != nil {
+		log.Printf("[ModelWarmer] %v", err)
+		return
+	}
+	status := w.Status()
+	log.Printf("[ModelWarmer] Model %s warmed up in %dms.", status.Model, status.DurationMs)
+}
@@ -0,0 +1,58 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestModelWarmerLoadsLocalModel(t *testing.T) {
+	var maxTokens int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			MaxTokens int `json:"max_tokens"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		maxTokens = request.MaxTokens
+		io.WriteString(w, `{"choices":[{"message":{"role":"assistant","content":"Hi"}}]}`)
+	}))
+	defer server.Close()
+
+	warmer := &ModelWarmer{Client: WithRetry(&LMStudioClient{APIURL: server.URL, Model: "qwen"}, RetryPolicy{MaxAttempts: 1})}
+	if warmer.Ready() || warmer.Status().State != WarmupPending {
+		t.Fatalf("expected a pending warmer, got %+v", warmer.Status())
+	}
+	if err := warmer.WarmUp(context.Background()); err != nil {
+		t.Fatalf("WarmUp: %v", err)
+	}
+	status := warmer.Status()
+	if !warmer.Ready() || status.State != WarmupReady || status.Model != "qwen" || status.Warmups != 1 {
+		t.Errorf("expected the model to be ready, got %+v", status)
+	}
+	if maxTokens != 1 {
+		t.Errorf("expected a one-token prompt, got max_tokens %d", maxTokens)
+	}
+}
+
+func TestModelWarmerReportsFailures(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "model not found", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	warmer := &ModelWarmer{Client: &LMStudioClient{APIURL: server.URL}}
+	if err := warmer.WarmUp(context.Background()); err == nil {
+		t.Fatal("expected the warm-up to fail")
+	}
+	if status := warmer.Status(); warmer.Ready() || status.State != WarmupFailed || status.Error == "" {
+		t.Errorf("expected a failed warm-up, got %+v", status)
+	}
+
+	remote := &ModelWarmer{Client: &GoogleClient{}}
+	if err := remote.WarmUp(context.Background()); err == nil {
+		t.Error("expected warm-up of a remote provider to be rejected")
+	}
+}
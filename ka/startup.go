@@ -0,0 +1,332 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"ka/a2a"
+	"ka/llm"
+	"ka/tools"
+)
+
+// startupProviderTimeout bounds the connectivity check of the LLM backend.
+const startupProviderTimeout = 10 * time.Second
+
+// secretFlags are reported as set or not set, never with their value.
+var secretFlags = map[string]bool{"jwt-secret": true, "api-keys": true}
+
+// startupEnv are the environment variables the agent reads, reported with
+// the configuration. Secret ones only report whether they are set.
+var startupEnv = []struct {
+	name   string
+	secret bool
+}{
+	{"PORT", false},
+	{"TASK_STORE_SQLITE", false},
+	{"TASK_STORE_DIR", false},
+	{"LLM_API_BASE", false},
+	{"OLLAMA_HOST", false},
+	{"GEMINI_API_KEY", true},
+	{"KA_CONFIG_DIR", false},
+	{"LLM_STREAM", false},
+}
+
+// configEntry is one resolved setting and where its value came from: "flag",
+// "default" or "env".
+type configEntry struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+// providerCheck is the outcome of the connectivity check of the LLM backend.
+type providerCheck struct {
+	Provider  string   `json:"provider"`
+	Model     string   `json:"model,omitempty"`
+	Status    string   `json:"status"` // pending, ok, unreachable or unsupported
+	LatencyMS int64    `json:"latencyMs,omitempty"`
+	Models    []string `json:"models,omitempty"` // Models the backend offers, when it can list them
+	Error     string   `json:"error,omitempty"`
+}
+
+// mcpServerProbe is the startup check of a configured MCP server.
+type mcpServerProbe struct {
+	Name      string `json:"name"`
+	Command   string `json:"command"`
+	Available bool   `json:"available"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// taskStoreHealth describes the task store the agent opened.
+type taskStoreHealth struct {
+	Type     string         `json:"type"` // file or sqlite
+	Location string         `json:"location"`
+	Source   string         `json:"source"`
+	Healthy  bool           `json:"healthy"`
+	Tasks    int            `json:"tasks"`
+	States   map[string]int `json:"states,omitempty"`
+	Error    string         `json:"error,omitempty"`
+}
+
+// startupReport describes how the agent came up: the resolved configuration,
+// the LLM backend, tools, MCP servers, task store and migrations. It is
+// logged at startup and served on /debug/startup.
+type startupReport struct {
+	mu         sync.Mutex
+	StartedAt  time.Time                `json:"startedAt"`
+	Config     []configEntry            `json:"config"`
+	Auth       []string                 `json:"auth"`
+	Provider   providerCheck            `json:"provider"`
+	Tools      []tools.ToolAvailability `json:"tools"`
+	MCPServers []mcpServerProbe         `json:"mcpServers"`
+	TaskStore  taskStoreHealth          `json:"taskStore"`
+	Migrations []string                 `json:"migrations"`
+	Warnings   []string                 `json:"warnings"`
+}
+
+// newStartupReport collects the configuration and the tool report.
+func newStartupReport(toolReport map[string]tools.ToolAvailability) *startupReport {
+	report := &startupReport{StartedAt: time.Now().UTC(), Config: resolvedConfig(), Auth: []string{}, MCPServers: []mcpServerProbe{}, Migrations: []string{}, Warnings: []string{}}
+	for _, availability := range toolReport {
+		report.Tools = append(report.Tools, availability)
+		if !availability.Available {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("tool %s is unavailable: %s", availability.Name, availability.Reason))
+		}
+	}
+	sort.Slice(report.Tools, func(i, j int) bool { return report.Tools[i].Name < report.Tools[j].Name })
+	sort.Strings(report.Warnings)
+	return report
+}
+
+// resolvedConfig lists every flag with its value and source, followed by
+// the environment variables the agent reads.
+func resolvedConfig() []configEntry {
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	var entries []configEntry
+	flag.VisitAll(func(f *flag.Flag) {
+		entry := configEntry{Name: f.Name, Value: f.Value.String(), Source: "default"}
+		if set[f.Name] {
+			entry.Source = "flag"
+		}
+		switch trimmed := strings.TrimSpace(entry.Value); {
+		case secretFlags[f.Name] && entry.Value != "":
+			entry.Value = "[REDACTED]"
+		case strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "["):
+			// Inline configurations may carry credentials
+			entry.Value = fmt.Sprintf("(inline JSON, %d bytes)", len(entry.Value))
+		}
+		entries = append(entries, entry)
+	})
+	for _, env := range startupEnv {
+		value, ok := os.LookupEnv(env.name)
+		if !ok {
+			continue
+		}
+		if env.secret {
+			value = "[REDACTED]"
+		}
+		entries = append(entries, configEntry{Name: env.name, Value: value, Source: "env"})
+	}
+	return entries
+}
+
+// setTaskStore records the task store and checks that its tasks load.
+func (r *startupReport) setTaskStore(store a2a.TaskStore, location, source string) {
+	health := taskStoreHealth{Type: "file", Location: location, Source: source}
+	if _, ok := store.(*a2a.SQLiteTaskStore); ok {
+		health.Type = "sqlite"
+	}
+	tasks, err := store.ListTasks()
+	if err != nil {
+		health.Error = err.Error()
+	} else {
+		health.Healthy = true
+		health.Tasks = len(tasks)
+		health.States = make(map[string]int)
+		for _, task := range tasks {
+			health.States[string(task.State)]++
+		}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.TaskStore = health
+	if err != nil {
+		r.Warnings = append(r.Warnings, "task store: "+err.Error())
+	}
+	if migrator, ok := store.(interface{ Migrations() []string }); ok {
+		r.Migrations = append(r.Migrations, migrator.Migrations()...)
+	}
+}
+
+// setMCPServers probes the configured MCP servers by looking up their
+// commands; the servers themselves are started on first use.
+func (r *startupReport) setMCPServers(configs map[string]tools.McpServerConfig) {
+	probes := []mcpServerProbe{}
+	for name, config := range configs {
+		probe := mcpServerProbe{Name: name, Command: config.Command, Available: true}
+		if config.TransportType != "stdio" {
+			probe.Available, probe.Reason = false, fmt.Sprintf("unsupported transport type %q (only stdio is supported)", config.TransportType)
+		} else if _, err := exec.LookPath(config.Command); err != nil {
+			probe.Available, probe.Reason = false, fmt.Sprintf("command %q not found", config.Command)
+		}
+		probes = append(probes, probe)
+	}
+	sort.Slice(probes, func(i, j int) bool { return probes[i].Name < probes[j].Name })
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.MCPServers = probes
+	for _, probe := range probes {
+		if !probe.Available {
+			r.Warnings = append(r.Warnings, fmt.Sprintf("MCP server %s: %s", probe.Name, probe.Reason))
+		}
+	}
+}
+
+func (r *startupReport) setAuth(methods []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Auth = methods
+}
+
+// checkProvider connects to the LLM backend and, if it lists its models,
+// checks that the configured model is among them. It logs the outcome.
+func (r *startupReport) checkProvider(client llm.LLMClient, provider, model string) {
+	check := providerCheck{Provider: provider, Model: model, Status: "pending"}
+	r.mu.Lock()
+	r.Provider = check
+	r.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), startupProviderTimeout)
+	defer cancel()
+	started := time.Now()
+	var warning string
+	if err := llm.WarmUp(ctx, client); err != nil {
+		check.Status, check.Error = "unreachable", err.Error()
+		if strings.Contains(err.Error(), "not supported") {
+			check.Status = "unsupported"
+		} else {
+			warning = fmt.Sprintf("LLM provider %s is unreachable: %v", provider, err)
+		}
+	} else {
+		check.Status = "ok"
+		check.LatencyMS = time.Since(started).Milliseconds()
+		if models, ok, err := llm.ListModels(ctx, client); ok && err == nil {
+			found := model == ""
+			for _, m := range models {
+				check.Models = append(check.Models, m.Name)
+				found = found || m.Name == model
+			}
+			if !found {
+				warning = fmt.Sprintf("model %s is not offered by %s (available: %s)", model, provider, strings.Join(check.Models, ", "))
+			}
+		}
+	}
+
+	r.mu.Lock()
+	r.Provider = check
+	if warning != "" {
+		r.Warnings = append(r.Warnings, warning)
+	}
+	r.mu.Unlock()
+	if warning != "" {
+		log.Printf("[startup] WARNING: %s", warning)
+	} else if check.Status == "ok" {
+		log.Printf("[startup] LLM provider %s reachable in %dms.", provider, check.LatencyMS)
+	}
+}
+
+// logSummary logs the settings that differ from the defaults and the
+// health of each component, one line each.
+func (r *startupReport) logSummary() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var configured []string
+	for _, entry := range r.Config {
+		if entry.Source != "default" {
+			configured = append(configured, fmt.Sprintf("%s=%s (%s)", entry.Name, entry.Value, entry.Source))
+		}
+	}
+	if len(configured) == 0 {
+		configured = []string{"all defaults"}
+	}
+	log.Printf("[startup] Configuration: %s", strings.Join(configured, ", "))
+	if len(r.Auth) == 0 {
+		log.Printf("[startup] Authentication: none configured")
+	} else {
+		log.Printf("[startup] Authentication: %s", strings.Join(r.Auth, ", "))
+	}
+	store := r.TaskStore
+	if store.Healthy {
+		log.Printf("[startup] Task store: %s at %s (%s), %d tasks", store.Type, store.Location, store.Source, store.Tasks)
+	} else {
+		log.Printf("[startup] Task store: %s at %s (%s) is unhealthy: %s", store.Type, store.Location, store.Source, store.Error)
+	}
+	available := 0
+	for _, tool := range r.Tools {
+		if tool.Available {
+			available++
+		}
+	}
+	log.Printf("[startup] Tools: %d of %d available", available, len(r.Tools))
+	log.Printf("[startup] MCP servers: %d configured", len(r.MCPServers))
+	if len(r.Migrations) > 0 {
+		log.Printf("[startup] Migrations applied: %s", strings.Join(r.Migrations, "; "))
+	}
+	for _, warning := range r.Warnings {
+		log.Printf("[startup] WARNING: %s", warning)
+	}
+	log.Printf("[startup] Full report: GET /debug/startup")
+}
+
+// ServeHTTP serves the report as JSON.
+func (r *startupReport) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	r.mu.Lock()
+	data, err := json.MarshalIndent(r, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// loadMcpConfigs reads MCP server configurations from a JSON array, given
+// inline or as a file path, as accepted by /set-mcp-config.
+func loadMcpConfigs(config string) (map[string]tools.McpServerConfig, error) {
+	data := []byte(config)
+	if !strings.HasPrefix(strings.TrimSpace(config), "[") {
+		fileData, err := os.ReadFile(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read MCP configuration file %s: %w", config, err)
+		}
+		data = fileData
+	}
+	var servers []tools.McpServerConfig
+	if err := json.Unmarshal(data, &servers); err != nil {
+		return nil, fmt.Errorf("failed to parse MCP configuration: %w", err)
+	}
+	configs := make(map[string]tools.McpServerConfig)
+	for i, server := range servers {
+		if server.Name == "" {
+			return nil, fmt.Errorf("MCP server %d has no name", i)
+		}
+		configs[server.Name] = server
+	}
+	return configs, nil
+}
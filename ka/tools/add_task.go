@@ -34,7 +34,7 @@ func (t *AddTaskTool) GetName() string {
 
 // GetDescription returns a description of the tool.
 func (t *AddTaskTool) GetDescription() string {
-	return "Creates a new, independent task that will be processed separately. It must not conflict (affect same files) with or depend on the completion of the current task, as it may be started immediately by another process. New task speeds up overall workflow by allowing parallel processing of tasks, but is tricky to predict potential conflicts, so use with caution."
+	return "Creates a new, independent task that will be processed separately. It must not conflict (affect same files) with or depend on the completion of the current task, as it is started immediately and runs in parallel. New task speeds up overall workflow by allowing parallel processing of tasks, but is tricky to predict potential conflicts, so use with caution."
 }
 
 // GetXMLDefinition returns the XML structure for the LLM to use.
@@ -0,0 +1,197 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ApplyDiffTool edits a file by applying a unified diff to it.
+type ApplyDiffTool struct {
+	Root string // Workspace root the tool may write in; empty is the working directory
+}
+
+func (t *ApplyDiffTool) GetName() string {
+	return "apply_diff"
+}
+
+func (t *ApplyDiffTool) GetDescription() string {
+	return "Edits a file at the specified path, relative to the workspace root, by applying a unified diff: one or more hunks starting with '@@ -oldStart,oldCount +newStart,newCount @@', whose lines start with ' ' (context), '-' (removed) or '+' (added). Context and removed lines must match the file; a hunk is also found if its line numbers are off. Hunks with only added lines create a new file. Returns a diff of the change."
+}
+
+func (t *ApplyDiffTool) GetXMLDefinition() string {
+	return `<tool id="apply_diff" path="path/to/your/file.go">@@ -10,3 +10,3 @@
+ unchanged line
+-old line
++new line
+ unchanged line</tool>`
+}
+
+func (t *ApplyDiffTool) Execute(ctx context.Context, callDetails FunctionCall) (string, error) {
+	filePath := callDetails.Attributes["path"]
+	if filePath == "" {
+		return "", fmt.Errorf("missing or empty 'path' attribute for apply_diff tool")
+	}
+	resolved, err := resolveInRoot(t.Root, filePath)
+	if err != nil {
+		return "", err
+	}
+	hunks, err := parseUnifiedDiff(callDetails.Content)
+	if err != nil {
+		return "", err
+	}
+	before, existed, err := readExisting(resolved, filePath)
+	if err != nil {
+		return "", err
+	}
+	if !existed {
+		for _, hunk := range hunks {
+			if len(hunk.old()) > 0 {
+				return "", fmt.Errorf("file %q does not exist; a diff creating it may only add lines", filePath)
+			}
+		}
+	}
+	after, err := applyHunks(before, hunks)
+	if err != nil {
+		return "", fmt.Errorf("failed to apply the diff to %q: %w", filePath, err)
+	}
+	return writeWorkspaceFile(resolved, filePath, existed, before, after)
+}
+
+// diffHunk is one hunk of a unified diff. Its lines keep their ' ', '-' or
+// '+' prefix.
+type diffHunk struct {
+	oldStart int // 1-based; 0 for a hunk inserting at the top of an empty file
+	lines    []string
+}
+
+// old returns the context and removed lines the file must contain.
+func (h diffHunk) old() []string {
+	var lines []string
+	for _, line := range h.lines {
+		if line[0] != '+' {
+			lines = append(lines, line[1:])
+		}
+	}
+	return lines
+}
+
+// new returns the context and added lines that replace them.
+func (h diffHunk) new() []string {
+	var lines []string
+	for _, line := range h.lines {
+		if line[0] != '-' {
+			lines = append(lines, line[1:])
+		}
+	}
+	return lines
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+\d+(?:,\d+)? @@`)
+
+// parseUnifiedDiff parses the hunks of a unified diff of one file. Lines
+// before the first hunk, such as the file headers, are skipped. An empty
+// line inside a hunk is taken as an empty context line, since trailing
+// spaces often get lost.
+func parseUnifiedDiff(diff string) ([]diffHunk, error) {
+	var hunks []diffHunk
+	var current *diffHunk
+	for _, line := range strings.Split(strings.ReplaceAll(diff, "\r\n", "\n"), "\n") {
+		if match := hunkHeader.FindStringSubmatch(line); match != nil {
+			start, _ := strconv.Atoi(match[1])
+			hunks = append(hunks, diffHunk{oldStart: start})
+			current = &hunks[len(hunks)-1]
+			continue
+		}
+		if current == nil {
+			continue // File headers (---, +++, diff, index) before the first hunk
+		}
+		switch {
+		case line == "":
+			current.lines = append(current.lines, " ")
+		case line[0] == ' ', line[0] == '-', line[0] == '+':
+			current.lines = append(current.lines, line)
+		case line[0] == '\\': // "\ No newline at end of file"
+		default:
+			return nil, fmt.Errorf("invalid diff line %q: lines of a hunk start with ' ', '-' or '+'", line)
+		}
+	}
+	if len(hunks) == 0 {
+		return nil, fmt.Errorf("the diff has no hunks (expected lines starting with '@@ -oldStart,oldCount +newStart,newCount @@')")
+	}
+	for i := range hunks {
+		// Trailing empty lines come from the end of the diff, not from the file
+		for len(hunks[i].lines) > 0 && hunks[i].lines[len(hunks[i].lines)-1] == " " {
+			hunks[i].lines = hunks[i].lines[:len(hunks[i].lines)-1]
+		}
+		if len(hunks[i].lines) == 0 {
+			return nil, fmt.Errorf("hunk %d is empty", i+1)
+		}
+	}
+	return hunks, nil
+}
+
+// applyHunks applies the hunks in order. Each hunk is looked up at its line
+// number, shifted by the lines earlier hunks added or removed, and failing
+// that at the nearest place after the previous hunk where it matches.
+func applyHunks(content string, hunks []diffHunk) (string, error) {
+	trailingNewline := content == "" || strings.HasSuffix(content, "\n")
+	var lines []string
+	if content != "" {
+		lines = strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	}
+	offset, minPos := 0, 0
+	for i, hunk := range hunks {
+		old, replacement := hunk.old(), hunk.new()
+		expected := max(hunk.oldStart-1, 0) + offset
+		if len(old) == 0 && hunk.oldStart > 0 {
+			expected = hunk.oldStart + offset // Pure insertions are numbered by the line they follow
+		}
+		pos := findLines(lines, old, expected, minPos)
+		if pos < 0 {
+			first := ""
+			if len(old) > 0 {
+				first = old[0]
+			}
+			return "", fmt.Errorf("hunk %d does not match the file (expected at line %d, starting with %q); read the file again and resend the diff", i+1, hunk.oldStart, first)
+		}
+		lines = append(lines[:pos], append(append([]string{}, replacement...), lines[pos+len(old):]...)...)
+		offset += len(replacement) - len(old)
+		minPos = pos + len(replacement)
+	}
+	result := strings.Join(lines, "\n")
+	if trailingNewline && len(lines) > 0 {
+		result += "\n"
+	}
+	return result, nil
+}
+
+// findLines returns the position at or after minPos where want occurs in
+// lines, preferring the one closest to expected, or -1.
+func findLines(lines, want []string, expected, minPos int) int {
+	matches := func(pos int) bool {
+		if pos < minPos || pos+len(want) > len(lines) {
+			return false
+		}
+		for i, line := range want {
+			if lines[pos+i] != line {
+				return false
+			}
+		}
+		return true
+	}
+	if len(want) == 0 {
+		return min(max(expected, minPos), len(lines))
+	}
+	for distance := 0; distance <= len(lines); distance++ {
+		if matches(expected + distance) {
+			return expected + distance
+		}
+		if distance > 0 && matches(expected-distance) {
+			return expected - distance
+		}
+	}
+	return -1
+}
@@ -0,0 +1,67 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApplyDiffEditsFile(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "main.go")
+	os.WriteFile(path, []byte("package main\n\nfunc a() {}\n\nfunc b() {}\n\nfunc c() {}\n"), 0644)
+	tool := &ApplyDiffTool{Root: root}
+
+	// The second hunk is numbered as if the first added no line
+	diff := `--- a/main.go
++++ b/main.go
+@@ -1,3 +1,4 @@
+ package main
+
++// a does nothing.
+ func a() {}
+@@ -6,2 +6,2 @@
+
+-func c() {}
++func c() { a() }
+`
+	result, err := tool.Execute(context.Background(), FunctionCall{Attributes: map[string]string{"path": "main.go"}, Content: diff})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(result, "Updated main.go: +2 -1 lines.") {
+		t.Errorf("unexpected summary:\n%s", result)
+	}
+	expected := "package main\n\n// a does nothing.\nfunc a() {}\n\nfunc b() {}\n\nfunc c() { a() }\n"
+	if data, _ := os.ReadFile(path); string(data) != expected {
+		t.Errorf("unexpected content:\n%s", data)
+	}
+
+	stale := "@@ -3,1 +3,1 @@\n-func missing() {}\n+func z() {}"
+	if _, err := tool.Execute(context.Background(), FunctionCall{Attributes: map[string]string{"path": "main.go"}, Content: stale}); err == nil || !strings.Contains(err.Error(), "hunk 1 does not match") {
+		t.Errorf("expected a mismatching hunk to be reported, got %v", err)
+	}
+	if data, _ := os.ReadFile(path); string(data) != expected {
+		t.Errorf("expected a failed diff to leave the file alone")
+	}
+}
+
+func TestApplyDiffCreatesFileAndRespectsRoot(t *testing.T) {
+	root := t.TempDir()
+	tool := &ApplyDiffTool{Root: root}
+
+	if _, err := tool.Execute(context.Background(), FunctionCall{Attributes: map[string]string{"path": "new.txt"}, Content: "@@ -0,0 +1,2 @@\n+hello\n+world"}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "new.txt")); string(data) != "hello\nworld\n" {
+		t.Errorf("unexpected content %q", data)
+	}
+	if _, err := tool.Execute(context.Background(), FunctionCall{Attributes: map[string]string{"path": "missing.txt"}, Content: "@@ -1 +1 @@\n-a\n+b"}); err == nil {
+		t.Error("expected removing lines from a missing file to fail")
+	}
+	if _, err := tool.Execute(context.Background(), FunctionCall{Attributes: map[string]string{"path": "../x.txt"}, Content: "@@ -0,0 +1 @@\n+x"}); err == nil {
+		t.Error("expected a path outside the root to be refused")
+	}
+}
@@ -0,0 +1,33 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ArtifactReader returns the data and name of an artifact of the task a tool
+// runs for.
+type ArtifactReader func(artifactID string) (data []byte, name string, err error)
+
+type artifactReaderKey struct{}
+
+// WithArtifactReader returns a context in which tools can read the artifacts
+// of the current task through reader.
+func WithArtifactReader(ctx context.Context, reader ArtifactReader) context.Context {
+	return context.WithValue(ctx, artifactReaderKey{}, reader)
+}
+
+// readArtifactRef reads an "artifact://<id>" reference through the reader in ctx.
+func readArtifactRef(ctx context.Context, ref string) ([]byte, string, error) {
+	id := strings.TrimPrefix(ref, "artifact://")
+	reader, ok := ctx.Value(artifactReaderKey{}).(ArtifactReader)
+	if !ok || reader == nil {
+		return nil, "", fmt.Errorf("artifacts are not available outside of a task")
+	}
+	data, name, err := reader(id)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read artifact %s: %w", id, err)
+	}
+	return data, name, nil
+}
@@ -13,8 +13,9 @@ type AskFollowupQuestionTool struct{}
 
 // AskFollowupQuestionArgs defines the structure for the JSON arguments.
 type AskFollowupQuestionArgs struct {
-	Question string   `json:"question"`
-	Options  []string `json:"options,omitempty"`
+	Question string       `json:"question"`
+	Options  []string     `json:"options,omitempty"`
+	Fields   []InputField `json:"fields,omitempty"` // Structured answers to collect in one ask
 }
 
 // GetName returns the name of the tool.
@@ -30,12 +31,16 @@ func (t *AskFollowupQuestionTool) GetDescription() string {
 // GetXMLDefinition returns the XML structure for the LLM to use.
 // The LLM should place a JSON string as the content of the <tool> tag.
 // The JSON string should represent an object with a "question" field (string)
-// and an optional "options" field (array of strings).
+// and an optional "options" field (array of strings) or "fields" (a form).
 func (t *AskFollowupQuestionTool) GetXMLDefinition() string {
 	return `<tool id="ask_followup_question">{
   "question": "The question to ask the user. This should be a clear, specific question that addresses the information you need.",
-  "options": ["Optional: Option 1", "Optional: Option 2"]
-}</tool>`
+  "options": ["Optional: Option 1", "Optional: Option 2"],
+  "fields": [
+    {"name": "Optional: field_name", "label": "Shown to the user", "type": "text|number|boolean|select", "required": true, "options": ["for select"], "pattern": "regex for text", "min": 0, "max": 100}
+  ]
+}</tool>
+Use "fields" to collect several answers at once; the user's answers are validated against them.`
 }
 
 // Execute asks the user a question and formats the output to include the [INPUT_REQUIRED] marker.
@@ -55,6 +60,11 @@ func (t *AskFollowupQuestionTool) Execute(ctx context.Context, callDetails Funct
 		return "", fmt.Errorf("missing required 'question' field in JSON arguments. Raw content: %s", callDetails.Content)
 	}
 
+	form := &InputForm{Question: args.Question, Options: args.Options, Fields: args.Fields}
+	if err := form.validateSchema(); err != nil {
+		return "", fmt.Errorf("invalid 'fields' in JSON arguments: %w", err)
+	}
+
 	var output strings.Builder
 	output.WriteString(fmt.Sprintf("Question: %s\n", args.Question))
 
@@ -65,6 +75,25 @@ func (t *AskFollowupQuestionTool) Execute(ctx context.Context, callDetails Funct
 		}
 	}
 
+	if len(args.Fields) > 0 {
+		output.WriteString("Fields:\n")
+		for _, field := range args.Fields {
+			fieldType := field.Type
+			if fieldType == "" {
+				fieldType = InputFieldText
+			}
+			label := field.Label
+			if label == "" {
+				label = field.Name
+			}
+			required := ""
+			if field.Required {
+				required = ", required"
+			}
+			output.WriteString(fmt.Sprintf("- %s (%s%s): %s\n", field.Name, fieldType, required, label))
+		}
+	}
+
 	output.WriteString("\n[INPUT_REQUIRED]") // Add a newline for better formatting before the marker
 	return output.String(), nil
 }
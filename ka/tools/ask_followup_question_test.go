@@ -115,6 +115,29 @@ Options:
 [INPUT_REQUIRED]`,
 			expectError: false,
 		},
+		{
+			name: "Question with form fields",
+			callDetails: FunctionCall{
+				Name:    "ask_followup_question",
+				Content: `{"question": "Deployment details?", "fields": [{"name": "env", "type": "select", "options": ["dev", "prod"], "required": true}, {"name": "replicas", "label": "Replica count", "type": "number"}]}`,
+			},
+			expected: `Question: Deployment details?
+Fields:
+- env (select, required): env
+- replicas (number): Replica count
+
+[INPUT_REQUIRED]`,
+			expectError: false,
+		},
+		{
+			name: "Select field without options",
+			callDetails: FunctionCall{
+				Name:    "ask_followup_question",
+				Content: `{"question": "Pick", "fields": [{"name": "env", "type": "select"}]}`,
+			},
+			expectError:   true,
+			errorContains: "has no options",
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,107 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const dependencyProbeTimeout = 5 * time.Second
+
+// ToolDependency describes an external binary a tool needs in order to run.
+type ToolDependency struct {
+	Binary      string   // Executable name looked up in PATH (or an absolute path)
+	VersionArgs []string // Arguments that print the version, e.g. ["--version"]; empty to skip
+}
+
+// DependentTool is implemented by tools that rely on external binaries.
+// Tools that do not implement it are always considered available.
+type DependentTool interface {
+	GetDependencies() []ToolDependency
+}
+
+// DependencyStatus is the probe result for a single dependency.
+type DependencyStatus struct {
+	Binary  string `json:"binary"`
+	Found   bool   `json:"found"`
+	Path    string `json:"path,omitempty"`
+	Version string `json:"version,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ToolAvailability is the startup probe report for a tool.
+type ToolAvailability struct {
+	Tool         Tool               `json:"-"`
+	Name         string             `json:"name"`
+	Available    bool               `json:"available"`
+	Reason       string             `json:"reason,omitempty"`
+	Dependencies []DependencyStatus `json:"dependencies,omitempty"`
+}
+
+// lookPath is a variable so tests can simulate missing binaries.
+var lookPath = exec.LookPath
+
+// ProbeTool checks the dependencies of a tool and reports whether it can run.
+func ProbeTool(tool Tool) ToolAvailability {
+	report := ToolAvailability{Tool: tool, Name: tool.GetName(), Available: true}
+	dependent, ok := tool.(DependentTool)
+	if !ok {
+		return report
+	}
+
+	var missing []string
+	for _, dep := range dependent.GetDependencies() {
+		status := probeDependency(dep)
+		if !status.Found {
+			missing = append(missing, fmt.Sprintf("%s (%s)", dep.Binary, status.Error))
+		}
+		report.Dependencies = append(report.Dependencies, status)
+	}
+	if len(missing) > 0 {
+		report.Available = false
+		report.Reason = "missing dependencies: " + strings.Join(missing, ", ")
+	}
+	return report
+}
+
+// ProbeTools probes all tools and returns the reports keyed by tool name.
+func ProbeTools(allTools []Tool) map[string]ToolAvailability {
+	reports := make(map[string]ToolAvailability, len(allTools))
+	for _, tool := range allTools {
+		report := ProbeTool(tool)
+		if !report.Available {
+			log.Printf("[tools] Tool %s disabled: %s", report.Name, report.Reason)
+		}
+		reports[report.Name] = report
+	}
+	return reports
+}
+
+func probeDependency(dep ToolDependency) DependencyStatus {
+	status := DependencyStatus{Binary: dep.Binary}
+	path, err := lookPath(dep.Binary)
+	if err != nil {
+		// LookPath also fails when the file exists but is not executable.
+		status.Error = err.Error()
+		return status
+	}
+	status.Found = true
+	status.Path = path
+
+	if len(dep.VersionArgs) == 0 {
+		return status
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), dependencyProbeTimeout)
+	defer cancel()
+	output, err := exec.CommandContext(ctx, path, dep.VersionArgs...).CombinedOutput()
+	if err != nil {
+		// The binary is present, so a failing version command does not disable the tool.
+		status.Error = fmt.Sprintf("version check failed: %v", err)
+		return status
+	}
+	status.Version = strings.TrimSpace(strings.SplitN(string(output), "\n", 2)[0])
+	return status
+}
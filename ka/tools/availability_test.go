@@ -0,0 +1,32 @@
+package tools
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestProbeTool_WithoutDependenciesIsAvailable(t *testing.T) {
+	report := ProbeTool(&GetTimeTool{})
+	if !report.Available {
+		t.Errorf("Expected get_current_time to be available, got reason %q", report.Reason)
+	}
+}
+
+func TestProbeTool_MissingBinaryDisablesTool(t *testing.T) {
+	originalLookPath := lookPath
+	defer func() { lookPath = originalLookPath }()
+	lookPath = func(file string) (string, error) {
+		return "", errors.New("executable file not found in $PATH")
+	}
+
+	report := ProbeTool(&SearchFilesTool{})
+	if report.Available {
+		t.Fatalf("Expected search_files to be unavailable without rg")
+	}
+	if report.Reason == "" {
+		t.Errorf("Expected a reason for the disabled tool")
+	}
+	if len(report.Dependencies) != 1 || report.Dependencies[0].Found {
+		t.Errorf("Expected one missing dependency, got %+v", report.Dependencies)
+	}
+}
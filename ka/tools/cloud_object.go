@@ -0,0 +1,456 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	defaultCloudMaxReadBytes = 1 << 20
+	defaultCloudListLimit    = 200
+)
+
+// CloudBucket is a bucket the cloud_object tool may access. S3 buckets and
+// S3-compatible stores are signed with AWS Signature V4; GCS buckets are
+// accessed through the interoperability API with HMAC keys.
+type CloudBucket struct {
+	Name            string   `json:"name"`               // Name the LLM refers to the bucket by
+	Provider        string   `json:"provider"`           // "s3" or "gcs"
+	Bucket          string   `json:"bucket,omitempty"`   // Bucket name at the provider; defaults to Name
+	Region          string   `json:"region,omitempty"`   // Defaults to us-east-1 for S3 and auto for GCS
+	Endpoint        string   `json:"endpoint,omitempty"` // Overrides the provider endpoint, e.g. for MinIO
+	AccessKeyID     string   `json:"accessKeyId"`        // "$VAR" reads the value from the environment
+	SecretAccessKey string   `json:"secretAccessKey"`
+	SessionToken    string   `json:"sessionToken,omitempty"`
+	Prefixes        []string `json:"prefixes,omitempty"` // Keys must start with one of these; empty allows the whole bucket
+	ReadOnly        bool     `json:"readOnly,omitempty"`
+	MaxReadBytes    int64    `json:"maxReadBytes,omitempty"` // Defaults to 1 MB
+}
+
+// LoadCloudBuckets reads the bucket configuration from a JSON array, given
+// inline or as a file path, and validates it.
+func LoadCloudBuckets(config string) ([]CloudBucket, error) {
+	data := []byte(config)
+	if !strings.HasPrefix(strings.TrimSpace(config), "[") {
+		fileData, err := os.ReadFile(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read cloud buckets file %s: %w", config, err)
+		}
+		data = fileData
+	}
+	var buckets []CloudBucket
+	if err := json.Unmarshal(data, &buckets); err != nil {
+		return nil, fmt.Errorf("failed to parse cloud buckets: %w", err)
+	}
+	seen := make(map[string]bool)
+	for i := range buckets {
+		b := &buckets[i]
+		if b.Name == "" {
+			return nil, fmt.Errorf("cloud bucket %d has no name", i)
+		}
+		if seen[b.Name] {
+			return nil, fmt.Errorf("duplicate cloud bucket '%s'", b.Name)
+		}
+		seen[b.Name] = true
+		if b.Bucket == "" {
+			b.Bucket = b.Name
+		}
+		switch b.Provider {
+		case "s3":
+			if b.Region == "" {
+				b.Region = "us-east-1"
+			}
+			if b.Endpoint == "" {
+				b.Endpoint = "https://s3." + b.Region + ".amazonaws.com"
+			}
+		case "gcs":
+			if b.Region == "" {
+				b.Region = "auto"
+			}
+			if b.Endpoint == "" {
+				b.Endpoint = "https://storage.googleapis.com"
+			}
+		default:
+			return nil, fmt.Errorf("cloud bucket '%s': unsupported provider '%s' (expected s3 or gcs)", b.Name, b.Provider)
+		}
+		b.Endpoint = strings.TrimRight(b.Endpoint, "/")
+		b.AccessKeyID = expandEnvValue(b.AccessKeyID)
+		b.SecretAccessKey = expandEnvValue(b.SecretAccessKey)
+		b.SessionToken = expandEnvValue(b.SessionToken)
+		if b.AccessKeyID == "" || b.SecretAccessKey == "" {
+			return nil, fmt.Errorf("cloud bucket '%s' needs accessKeyId and secretAccessKey", b.Name)
+		}
+		if b.MaxReadBytes <= 0 {
+			b.MaxReadBytes = defaultCloudMaxReadBytes
+		}
+	}
+	return buckets, nil
+}
+
+// expandEnvValue resolves "$VAR" and "${VAR}" references, so credentials
+// need not be written into the configuration.
+func expandEnvValue(value string) string {
+	if strings.HasPrefix(value, "$") {
+		return os.ExpandEnv(value)
+	}
+	return value
+}
+
+// CloudObjectArgs are the arguments of the cloud_object tool.
+type CloudObjectArgs struct {
+	Action      string `json:"action"` // list, read or write
+	Bucket      string `json:"bucket"`
+	Key         string `json:"key,omitempty"`
+	Prefix      string `json:"prefix,omitempty"`
+	Delimiter   string `json:"delimiter,omitempty"`
+	Content     string `json:"content,omitempty"`
+	Encoding    string `json:"encoding,omitempty"` // "base64" for binary content to write
+	ContentType string `json:"content_type,omitempty"`
+	MaxBytes    int64  `json:"max_bytes,omitempty"`
+}
+
+// CloudObjectTool lists, reads and writes objects in configured S3 and GCS buckets.
+type CloudObjectTool struct {
+	buckets map[string]CloudBucket
+	client  *http.Client
+	now     func() time.Time
+}
+
+// NewCloudObjectTool creates the tool for the given buckets.
+func NewCloudObjectTool(buckets []CloudBucket) *CloudObjectTool {
+	t := &CloudObjectTool{buckets: make(map[string]CloudBucket), client: &http.Client{Timeout: 60 * time.Second}, now: time.Now}
+	for _, b := range buckets {
+		t.buckets[b.Name] = b
+	}
+	return t
+}
+
+func (t *CloudObjectTool) GetName() string {
+	return "cloud_object"
+}
+
+func (t *CloudObjectTool) GetDescription() string {
+	names := make([]string, 0, len(t.buckets))
+	for name, b := range t.buckets {
+		desc := name
+		if len(b.Prefixes) > 0 {
+			desc += " (prefixes: " + strings.Join(b.Prefixes, ", ") + ")"
+		}
+		if b.ReadOnly {
+			desc += " (read-only)"
+		}
+		names = append(names, desc)
+	}
+	sort.Strings(names)
+	return "Lists, reads (size-capped) and writes objects in cloud storage buckets. Available buckets: " + strings.Join(names, "; ") + "."
+}
+
+func (t *CloudObjectTool) GetXMLDefinition() string {
+	return `<tool id="cloud_object">{"action": "list|read|write", "bucket": "bucket name", "prefix": "for list (optional)", "delimiter": "/ (optional, for list)", "key": "object key for read/write", "content": "for write", "encoding": "base64 (optional, for binary writes)", "content_type": "optional, for write", "max_bytes": 1048576 (optional, for read)}</tool>`
+}
+
+func (t *CloudObjectTool) Execute(ctx context.Context, callDetails FunctionCall) (string, error) {
+	var args CloudObjectArgs
+	if err := json.Unmarshal([]byte(callDetails.Content), &args); err != nil {
+		return "", fmt.Errorf("failed to parse arguments JSON for cloud_object: %w. Content: %s", err, callDetails.Content)
+	}
+	bucket, ok := t.buckets[args.Bucket]
+	if !ok {
+		return "", fmt.Errorf("unknown bucket '%s'", args.Bucket)
+	}
+	switch args.Action {
+	case "list":
+		if err := bucket.checkPrefix(args.Prefix, true); err != nil {
+			return "", err
+		}
+		return t.list(ctx, bucket, args)
+	case "read":
+		if err := bucket.checkPrefix(args.Key, false); err != nil {
+			return "", err
+		}
+		return t.read(ctx, bucket, args)
+	case "write":
+		if bucket.ReadOnly {
+			return "", fmt.Errorf("bucket '%s' is read-only", bucket.Name)
+		}
+		if err := bucket.checkPrefix(args.Key, false); err != nil {
+			return "", err
+		}
+		return t.write(ctx, bucket, args)
+	default:
+		return "", fmt.Errorf("unknown action '%s' (expected list, read or write)", args.Action)
+	}
+}
+
+// checkPrefix returns an error if key is outside the allowed prefixes. For
+// listings, a prefix that is itself a parent of an allowed prefix is fine.
+func (b CloudBucket) checkPrefix(key string, listing bool) error {
+	if !listing && key == "" {
+		return fmt.Errorf("missing 'key'")
+	}
+	if len(b.Prefixes) == 0 {
+		return nil
+	}
+	for _, prefix := range b.Prefixes {
+		if strings.HasPrefix(key, prefix) || (listing && strings.HasPrefix(prefix, key)) {
+			return nil
+		}
+	}
+	return fmt.Errorf("'%s' is outside the allowed prefixes of bucket '%s' (%s)", key, b.Name, strings.Join(b.Prefixes, ", "))
+}
+
+type listBucketResult struct {
+	Contents []struct {
+		Key          string `xml:"Key"`
+		Size         int64  `xml:"Size"`
+		LastModified string `xml:"LastModified"`
+	} `xml:"Contents"`
+	CommonPrefixes []struct {
+		Prefix string `xml:"Prefix"`
+	} `xml:"CommonPrefixes"`
+	IsTruncated bool `xml:"IsTruncated"`
+}
+
+func (t *CloudObjectTool) list(ctx context.Context, b CloudBucket, args CloudObjectArgs) (string, error) {
+	query := url.Values{"list-type": {"2"}, "max-keys": {strconv.Itoa(defaultCloudListLimit)}}
+	if args.Prefix != "" {
+		query.Set("prefix", args.Prefix)
+	}
+	if args.Delimiter != "" {
+		query.Set("delimiter", args.Delimiter)
+	}
+	resp, err := t.do(ctx, b, http.MethodGet, "", query, nil, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var result listBucketResult
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to parse the object listing: %w", err)
+	}
+
+	type object struct {
+		Key          string `json:"key"`
+		Size         int64  `json:"size"`
+		LastModified string `json:"last_modified,omitempty"`
+	}
+	out := struct {
+		Objects   []object `json:"objects"`
+		Prefixes  []string `json:"prefixes,omitempty"`
+		Truncated bool     `json:"truncated,omitempty"`
+	}{Objects: []object{}, Truncated: result.IsTruncated}
+	for _, c := range result.Contents {
+		if b.checkPrefix(c.Key, false) == nil {
+			out.Objects = append(out.Objects, object{Key: c.Key, Size: c.Size, LastModified: c.LastModified})
+		}
+	}
+	for _, p := range result.CommonPrefixes {
+		out.Prefixes = append(out.Prefixes, p.Prefix)
+	}
+	data, _ := json.Marshal(out)
+	return string(data), nil
+}
+
+func (t *CloudObjectTool) read(ctx context.Context, b CloudBucket, args CloudObjectArgs) (string, error) {
+	limit := b.MaxReadBytes
+	if args.MaxBytes > 0 && args.MaxBytes < limit {
+		limit = args.MaxBytes
+	}
+	headers := http.Header{"Range": {fmt.Sprintf("bytes=0-%d", limit-1)}}
+	resp, err := t.do(ctx, b, http.MethodGet, args.Key, nil, headers, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit))
+	if err != nil {
+		return "", fmt.Errorf("failed to read object '%s': %w", args.Key, err)
+	}
+
+	total := int64(len(data))
+	if contentRange := resp.Header.Get("Content-Range"); contentRange != "" {
+		if i := strings.LastIndex(contentRange, "/"); i >= 0 {
+			if size, err := strconv.ParseInt(contentRange[i+1:], 10, 64); err == nil {
+				total = size
+			}
+		}
+	}
+	out := map[string]interface{}{"key": args.Key, "size": total, "content_type": resp.Header.Get("Content-Type")}
+	if total > int64(len(data)) {
+		out["truncated"] = true
+		out["returned_bytes"] = len(data)
+	}
+	if utf8.Valid(data) {
+		out["content"] = string(data)
+	} else {
+		out["content"] = base64.StdEncoding.EncodeToString(data)
+		out["encoding"] = "base64"
+	}
+	result, _ := json.Marshal(out)
+	return string(result), nil
+}
+
+func (t *CloudObjectTool) write(ctx context.Context, b CloudBucket, args CloudObjectArgs) (string, error) {
+	body := []byte(args.Content)
+	if args.Encoding == "base64" {
+		decoded, err := base64.StdEncoding.DecodeString(args.Content)
+		if err != nil {
+			return "", fmt.Errorf("content is not valid base64: %w", err)
+		}
+		body = decoded
+	}
+	contentType := args.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(args.Key))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	resp, err := t.do(ctx, b, http.MethodPut, args.Key, nil, http.Header{"Content-Type": {contentType}}, body)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return fmt.Sprintf("Wrote %d bytes to %s/%s (%s).", len(body), b.Name, args.Key, contentType), nil
+}
+
+// do sends a signed request for an object key, or for the bucket when key is
+// empty, and returns the response if it succeeded.
+func (t *CloudObjectTool) do(ctx context.Context, b CloudBucket, method, key string, query url.Values, headers http.Header, body []byte) (*http.Response, error) {
+	escapedPath := "/" + awsURIEscape(b.Bucket, false)
+	if key != "" {
+		escapedPath += "/" + awsURIEscape(key, true)
+	}
+	u, err := url.Parse(b.Endpoint + escapedPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid endpoint of bucket '%s': %w", b.Name, err)
+	}
+	u.RawQuery = canonicalQuery(query)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+	signV4(req, escapedPath, body, b, t.now())
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request to bucket '%s' failed: %w", b.Name, err)
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		var apiErr struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&apiErr)
+		if resp.StatusCode == http.StatusNotFound && key != "" {
+			return nil, fmt.Errorf("object '%s' not found in bucket '%s'", key, b.Name)
+		}
+		return nil, fmt.Errorf("bucket '%s' returned %s: %s %s", b.Name, resp.Status, apiErr.Code, apiErr.Message)
+	}
+	return resp, nil
+}
+
+// signV4 adds an AWS Signature Version 4 Authorization header to req.
+func signV4(req *http.Request, escapedPath string, body []byte, b CloudBucket, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if b.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", b.SessionToken)
+	}
+
+	signed := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-") || lower == "content-type" || lower == "range" {
+			signed[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(signed))
+	for name := range signed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + signed[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{req.Method, escapedPath, req.URL.RawQuery, canonicalHeaders.String(), signedHeaders, payloadHash}, "\n")
+	scope := date + "/" + b.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+b.SecretAccessKey), date)
+	for _, part := range []string{b.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", b.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// awsURIEscape percent-encodes everything but unreserved characters, and
+// slashes when keepSlash is set, as Signature V4 requires.
+func awsURIEscape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') || c == '-' || c == '_' || c == '.' || c == '~' || (keepSlash && c == '/') {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// canonicalQuery encodes query parameters sorted by name, as Signature V4 requires.
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var parts []string
+	for _, name := range names {
+		for _, value := range query[name] {
+			parts = append(parts, awsURIEscape(name, false)+"="+awsURIEscape(value, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
@@ -0,0 +1,111 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestCloudObjectTool(t *testing.T, objects map[string]string) *CloudObjectTool {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		key := strings.TrimPrefix(r.URL.Path, "/data-bucket/")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/data-bucket":
+			w.Write([]byte(`<ListBucketResult><Contents><Key>in/a.csv</Key><Size>5</Size></Contents><Contents><Key>secret/b.txt</Key><Size>3</Size></Contents></ListBucketResult>`))
+		case r.Method == http.MethodGet:
+			content, ok := objects[key]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`<Error><Code>NoSuchKey</Code></Error>`))
+				return
+			}
+			w.Header().Set("Content-Range", "bytes 0-3/"+strconv.Itoa(len(content)))
+			if len(content) > 4 {
+				content = content[:4]
+			}
+			w.Write([]byte(content))
+		case r.Method == http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			objects[key] = string(body)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	buckets, err := LoadCloudBuckets(`[{"name": "data", "provider": "s3", "bucket": "data-bucket", "endpoint": "` + server.URL + `",
+		"accessKeyId": "AKID", "secretAccessKey": "secret", "prefixes": ["in/", "out/"]},
+		{"name": "archive", "provider": "gcs", "endpoint": "` + server.URL + `", "accessKeyId": "AKID", "secretAccessKey": "secret", "readOnly": true}]`)
+	require.NoError(t, err)
+	return NewCloudObjectTool(buckets)
+}
+
+func runCloudObject(tool *CloudObjectTool, args string) (string, error) {
+	return tool.Execute(context.Background(), FunctionCall{Name: "cloud_object", Content: args})
+}
+
+func TestCloudObjectTool_ListFiltersToAllowedPrefixes(t *testing.T) {
+	tool := newTestCloudObjectTool(t, map[string]string{})
+
+	result, err := runCloudObject(tool, `{"action": "list", "bucket": "data"}`)
+	require.NoError(t, err)
+	assert.Contains(t, result, "in/a.csv")
+	assert.NotContains(t, result, "secret/b.txt")
+}
+
+func TestCloudObjectTool_ReadIsSizeCapped(t *testing.T) {
+	tool := newTestCloudObjectTool(t, map[string]string{"in/a.csv": "a,b\n1,2\n"})
+
+	result, err := runCloudObject(tool, `{"action": "read", "bucket": "data", "key": "in/a.csv", "max_bytes": 4}`)
+	require.NoError(t, err)
+	var out map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(result), &out))
+	assert.Equal(t, "a,b\n", out["content"])
+	assert.Equal(t, true, out["truncated"])
+	assert.Equal(t, float64(8), out["size"])
+
+	_, err = runCloudObject(tool, `{"action": "read", "bucket": "data", "key": "in/missing.csv"}`)
+	assert.ErrorContains(t, err, "not found")
+}
+
+func TestCloudObjectTool_Write(t *testing.T) {
+	objects := map[string]string{}
+	tool := newTestCloudObjectTool(t, objects)
+
+	result, err := runCloudObject(tool, `{"action": "write", "bucket": "data", "key": "out/result.json", "content": "{}"}`)
+	require.NoError(t, err)
+	assert.Contains(t, result, "application/json")
+	assert.Equal(t, "{}", objects["out/result.json"])
+}
+
+func TestCloudObjectTool_Restrictions(t *testing.T) {
+	tool := newTestCloudObjectTool(t, map[string]string{})
+
+	_, err := runCloudObject(tool, `{"action": "read", "bucket": "data", "key": "secret/b.txt"}`)
+	assert.ErrorContains(t, err, "outside the allowed prefixes")
+
+	_, err = runCloudObject(tool, `{"action": "write", "bucket": "archive", "key": "x.txt", "content": "x"}`)
+	assert.ErrorContains(t, err, "read-only")
+
+	_, err = runCloudObject(tool, `{"action": "list", "bucket": "unknown"}`)
+	assert.ErrorContains(t, err, "unknown bucket")
+}
+
+func TestLoadCloudBuckets_Invalid(t *testing.T) {
+	_, err := LoadCloudBuckets(`[{"name": "x", "provider": "azure", "accessKeyId": "a", "secretAccessKey": "b"}]`)
+	assert.ErrorContains(t, err, "unsupported provider")
+
+	t.Setenv("TEST_CLOUD_SECRET", "")
+	_, err = LoadCloudBuckets(`[{"name": "x", "provider": "s3", "accessKeyId": "a", "secretAccessKey": "$TEST_CLOUD_SECRET"}]`)
+	assert.ErrorContains(t, err, "needs accessKeyId")
+}
@@ -0,0 +1,136 @@
+package tools
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// syntaxContextRadius is the number of lines shown around each match.
+const syntaxContextRadius = 2
+
+// languageByExtension maps file extensions to language names.
+var languageByExtension = map[string]string{
+	".go":    "go",
+	".py":    "python",
+	".js":    "javascript",
+	".jsx":   "javascript",
+	".mjs":   "javascript",
+	".ts":    "typescript",
+	".tsx":   "typescript",
+	".rs":    "rust",
+	".java":  "java",
+	".kt":    "kotlin",
+	".c":     "c",
+	".h":     "c",
+	".cc":    "cpp",
+	".cpp":   "cpp",
+	".hpp":   "cpp",
+	".cs":    "csharp",
+	".rb":    "ruby",
+	".php":   "php",
+	".sh":    "shell",
+	".bash":  "shell",
+	".swift": "swift",
+}
+
+// declarationPatterns recognise lines that open a function, type or class.
+// They are heuristics rather than a real parser, which keeps the tool dependency-free.
+var declarationPatterns = map[string]*regexp.Regexp{
+	"go":         regexp.MustCompile(`^(func|type)\s`),
+	"python":     regexp.MustCompile(`^\s*(async\s+def|def|class)\s`),
+	"javascript": regexp.MustCompile(`^\s*(export\s+)?(default\s+)?(async\s+)?(function\*?|class)\s|^\s*(export\s+)?(const|let)\s+\w+\s*=\s*(async\s*)?(\(|function)`),
+	"typescript": regexp.MustCompile(`^\s*(export\s+)?(default\s+)?(abstract\s+)?(async\s+)?(function\*?|class|interface|type|enum)\s|^\s*(export\s+)?(const|let)\s+\w+\s*(:[^=]+)?=\s*(async\s*)?(\(|function)`),
+	"rust":       regexp.MustCompile(`^\s*(pub(\([^)]*\))?\s+)?(async\s+)?(fn|struct|enum|trait|impl|mod)\b`),
+	"java":       regexp.MustCompile(`^\s*(public|private|protected|static|final|abstract|\s)*\s*(class|interface|enum|record|[\w<>\[\],\s]+\s+\w+\s*\([^;]*$)`),
+	"kotlin":     regexp.MustCompile(`^\s*(\w+\s+)*(fun|class|object|interface)\s`),
+	"c":          regexp.MustCompile(`^(struct|enum|union|typedef|[A-Za-z_][\w\s\*]*\s\**\w+\s*\([^;]*$)`),
+	"cpp":        regexp.MustCompile(`^\s*(class|struct|namespace|enum|template|[A-Za-z_][\w:<>\s\*&]*\s[\*&]*[\w:~]+\s*\([^;]*$)`),
+	"csharp":     regexp.MustCompile(`^\s*(public|private|protected|internal|static|async|override|virtual|\s)*\s*(class|interface|struct|enum|record|[\w<>\[\],]+\s+\w+\s*\()`),
+	"ruby":       regexp.MustCompile(`^\s*(def|class|module)\s`),
+	"php":        regexp.MustCompile(`^\s*(abstract\s+|final\s+)?(public\s+|private\s+|protected\s+)?(static\s+)?(function|class|interface|trait)\s`),
+	"shell":      regexp.MustCompile(`^\s*(function\s+\w+|\w+\s*\(\)\s*\{)`),
+	"swift":      regexp.MustCompile(`^\s*(\w+\s+)*(func|class|struct|enum|protocol|extension)\s`),
+}
+
+// DetectLanguage guesses the programming language of a file from its
+// extension or shebang line. It returns "" when the language is unknown.
+func DetectLanguage(path string, firstLine string) string {
+	if lang, ok := languageByExtension[strings.ToLower(filepath.Ext(path))]; ok {
+		return lang
+	}
+	if strings.HasPrefix(firstLine, "#!") {
+		switch {
+		case strings.Contains(firstLine, "python"):
+			return "python"
+		case strings.Contains(firstLine, "node"):
+			return "javascript"
+		case strings.Contains(firstLine, "sh"):
+			return "shell"
+		}
+	}
+	return ""
+}
+
+// enclosingDeclaration returns the index of the nearest declaration line at or
+// above lineIdx, or -1 if none is found.
+func enclosingDeclaration(lines []string, lang string, lineIdx int) int {
+	pattern, ok := declarationPatterns[lang]
+	if !ok {
+		return -1
+	}
+	for i := lineIdx; i >= 0; i-- {
+		if pattern.MatchString(lines[i]) {
+			return i
+		}
+	}
+	return -1
+}
+
+// SyntaxSnippets renders the given 0-based match lines of a file with a few
+// lines of context and the header of the enclosing function or type. Nearby
+// matches are merged so each line is printed once. Output lines are prefixed
+// with their 1-based line number.
+func SyntaxSnippets(lines []string, lang string, matchLines []int) string {
+	if len(matchLines) == 0 {
+		return ""
+	}
+	sorted := append([]int(nil), matchLines...)
+	sort.Ints(sorted)
+
+	type lineRange struct{ start, end, decl int }
+	var ranges []lineRange
+	for _, idx := range sorted {
+		if idx < 0 || idx >= len(lines) {
+			continue
+		}
+		start := max(idx-syntaxContextRadius, 0)
+		end := min(idx+syntaxContextRadius, len(lines)-1)
+		decl := enclosingDeclaration(lines, lang, idx)
+		if n := len(ranges); n > 0 && start <= ranges[n-1].end+1 {
+			ranges[n-1].end = max(ranges[n-1].end, end)
+			continue
+		}
+		ranges = append(ranges, lineRange{start: start, end: end, decl: decl})
+	}
+
+	var b strings.Builder
+	lastDecl := -1
+	for _, r := range ranges {
+		b.WriteString("--\n")
+		// Show the enclosing declaration once, unless it is already in the range.
+		if r.decl >= 0 && r.decl < r.start && r.decl != lastDecl {
+			fmt.Fprintf(&b, "%d: %s\n", r.decl+1, strings.TrimRight(lines[r.decl], "\r\n"))
+			if r.decl+1 < r.start {
+				b.WriteString("   ...\n")
+			}
+		}
+		lastDecl = r.decl
+		for i := r.start; i <= r.end; i++ {
+			fmt.Fprintf(&b, "%d: %s\n", i+1, strings.TrimRight(lines[i], "\r\n"))
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,49 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectLanguage_ByExtension(t *testing.T) {
+	if lang := DetectLanguage("main.go", ""); lang != "go" {
+		t.Errorf("Expected go, got %q", lang)
+	}
+}
+
+func TestDetectLanguage_ByShebang(t *testing.T) {
+	if lang := DetectLanguage("script", "#!/usr/bin/env python3"); lang != "python" {
+		t.Errorf("Expected python, got %q", lang)
+	}
+}
+
+func TestSyntaxSnippets_IncludesEnclosingFunction(t *testing.T) {
+	lines := strings.Split(`package main
+
+func handle() {
+	a := 1
+	b := 2
+	c := 3
+	d := 4
+	target()
+}`, "\n")
+
+	snippet := SyntaxSnippets(lines, "go", []int{7})
+	if !strings.Contains(snippet, "3: func handle() {") {
+		t.Errorf("Expected enclosing function header, got:\n%s", snippet)
+	}
+	if !strings.Contains(snippet, "8: \ttarget()") {
+		t.Errorf("Expected matched line, got:\n%s", snippet)
+	}
+	if strings.Contains(snippet, "1: package main") {
+		t.Errorf("Expected lines outside the context to be omitted, got:\n%s", snippet)
+	}
+}
+
+func TestSyntaxSnippets_MergesNearbyMatches(t *testing.T) {
+	lines := []string{"a", "b", "c", "d", "e", "f"}
+	snippet := SyntaxSnippets(lines, "text", []int{1, 3})
+	if strings.Count(snippet, "--\n") != 1 {
+		t.Errorf("Expected a single merged range, got:\n%s", snippet)
+	}
+}
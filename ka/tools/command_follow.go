@@ -0,0 +1,173 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// followWaitDelay is how long Wait waits for the output pipes after the
+// process group was killed.
+const followWaitDelay = 2 * time.Second
+
+// ToolOutput is a chunk of live output from a command running in follow mode.
+type ToolOutput struct {
+	Tool       string    `json:"tool"`
+	ToolCallID string    `json:"toolCallId,omitempty"`
+	Stream     string    `json:"stream"` // "stdout" or "stderr"
+	Data       string    `json:"data"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// OutputReporter receives live output. It is called from the goroutines
+// copying the process output, so it must be safe for concurrent use.
+type OutputReporter func(ToolOutput)
+
+type outputReporterKey struct{}
+
+// WithOutputReporter returns a context that delivers live command output to reporter.
+func WithOutputReporter(ctx context.Context, reporter OutputReporter) context.Context {
+	return context.WithValue(ctx, outputReporterKey{}, reporter)
+}
+
+// ReportOutput delivers output to the reporter in ctx, if there is one.
+func ReportOutput(ctx context.Context, output ToolOutput) {
+	reporter, ok := ctx.Value(outputReporterKey{}).(OutputReporter)
+	if !ok || reporter == nil {
+		return
+	}
+	if output.Timestamp.IsZero() {
+		output.Timestamp = time.Now().UTC()
+	}
+	reporter(output)
+}
+
+// runningCommand is a follow-mode command that can be terminated from outside.
+type runningCommand struct {
+	toolCallID string
+	terminate  func(reason string)
+}
+
+// commandRegistry tracks follow-mode commands per task.
+type commandRegistry struct {
+	mu       sync.Mutex
+	commands map[string][]*runningCommand
+}
+
+var followedCommands = &commandRegistry{commands: make(map[string][]*runningCommand)}
+
+func (r *commandRegistry) add(taskID string, cmd *runningCommand) func() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.commands[taskID] = append(r.commands[taskID], cmd)
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		list := r.commands[taskID]
+		for i, c := range list {
+			if c == cmd {
+				r.commands[taskID] = append(list[:i], list[i+1:]...)
+				break
+			}
+		}
+		if len(r.commands[taskID]) == 0 {
+			delete(r.commands, taskID)
+		}
+	}
+}
+
+// TerminateCommands stops the follow-mode commands of a task. An empty
+// toolCallID stops all of them. It returns the number of commands stopped.
+func TerminateCommands(taskID, toolCallID string) int {
+	followedCommands.mu.Lock()
+	var matched []*runningCommand
+	for _, cmd := range followedCommands.commands[taskID] {
+		if toolCallID == "" || cmd.toolCallID == toolCallID {
+			matched = append(matched, cmd)
+		}
+	}
+	followedCommands.mu.Unlock()
+
+	for _, cmd := range matched {
+		cmd.terminate("terminated by user")
+	}
+	return len(matched)
+}
+
+// lineStreamer forwards complete lines of a stream to the output reporter and
+// stops the command when a line matches the until pattern.
+type lineStreamer struct {
+	ctx     context.Context
+	stream  string
+	base    ToolOutput
+	until   *regexp.Regexp
+	onMatch func(line string)
+	partial bytes.Buffer
+}
+
+func (l *lineStreamer) Write(p []byte) (int, error) {
+	l.partial.Write(p)
+	for {
+		idx := bytes.IndexByte(l.partial.Bytes(), '\n')
+		if idx < 0 {
+			break
+		}
+		line := string(l.partial.Next(idx + 1))
+		l.emit(line)
+	}
+	return len(p), nil
+}
+
+// flush emits a trailing line without a newline.
+func (l *lineStreamer) flush() {
+	if l.partial.Len() > 0 {
+		l.emit(l.partial.String())
+		l.partial.Reset()
+	}
+}
+
+func (l *lineStreamer) emit(line string) {
+	output := l.base
+	output.Stream, output.Data = l.stream, line
+	ReportOutput(l.ctx, output)
+	if l.until != nil && l.until.MatchString(line) {
+		l.onMatch(line)
+	}
+}
+
+// followTermination records why a follow-mode command was stopped early.
+type followTermination struct {
+	mu     sync.Mutex
+	reason string
+}
+
+func (f *followTermination) set(reason string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.reason != "" {
+		return false
+	}
+	f.reason = reason
+	return true
+}
+
+func (f *followTermination) get() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.reason
+}
+
+// untilPattern compiles the optional stop pattern of a follow-mode command.
+func untilPattern(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid 'until' pattern for execute_command: %w", err)
+	}
+	return re, nil
+}
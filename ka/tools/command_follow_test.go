@@ -0,0 +1,104 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestExecuteCommandFollow_StreamsAndStopsOnMatch(t *testing.T) {
+	var mu sync.Mutex
+	var lines []ToolOutput
+	ctx := WithOutputReporter(context.Background(), func(output ToolOutput) {
+		mu.Lock()
+		lines = append(lines, output)
+		mu.Unlock()
+	})
+
+	start := time.Now()
+	result, err := (&ExecuteCommandTool{}).Execute(ctx, FunctionCall{
+		Content:    `{"command": "echo booting; echo ready; sleep 30", "follow": true, "until": "^ready"}`,
+		Attributes: map[string]string{"__tool_call_id": "call-1"},
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if time.Since(start) > 10*time.Second {
+		t.Errorf("Expected the command to be stopped early")
+	}
+	if !strings.Contains(result, "booting\nready\n") || !strings.Contains(result, `[Command stopped after output matched "^ready"]`) {
+		t.Errorf("Unexpected result: %q", result)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(lines) != 2 || lines[0].Data != "booting\n" || lines[0].Stream != "stdout" || lines[0].ToolCallID != "call-1" {
+		t.Errorf("Unexpected streamed output: %+v", lines)
+	}
+}
+
+func TestExecuteCommandFollow_TerminatedByUser(t *testing.T) {
+	started := make(chan struct{})
+	var once sync.Once
+	ctx := WithOutputReporter(context.Background(), func(ToolOutput) { once.Do(func() { close(started) }) })
+
+	done := make(chan string)
+	go func() {
+		result, _ := (&ExecuteCommandTool{}).Execute(ctx, FunctionCall{
+			Content:    `{"command": "echo started; sleep 30", "follow": true}`,
+			Attributes: map[string]string{"__task_id": "task-follow"},
+		})
+		done <- result
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Command produced no output")
+	}
+	if n := TerminateCommands("task-follow", ""); n != 1 {
+		t.Fatalf("Expected to terminate 1 command, got %d", n)
+	}
+	select {
+	case result := <-done:
+		if !strings.Contains(result, "[Command terminated by user]") {
+			t.Errorf("Unexpected result: %q", result)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatalf("Command was not terminated")
+	}
+	if n := TerminateCommands("task-follow", ""); n != 0 {
+		t.Errorf("Expected the command to be unregistered, got %d", n)
+	}
+}
+
+func TestExecuteCommandFollow_Timeout(t *testing.T) {
+	result, err := (&ExecuteCommandTool{}).Execute(context.Background(), FunctionCall{
+		Content: `{"command": "sleep 30", "follow": true, "timeout_seconds": 1}`,
+	})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if !strings.Contains(result, "[Command stopped after the 1s timeout]") {
+		t.Errorf("Unexpected result: %q", result)
+	}
+}
+
+func TestExecuteCommandFollow_ConfiguredTimeoutCapsRequested(t *testing.T) {
+	tool := &ExecuteCommandTool{Timeout: 300 * time.Millisecond}
+	for _, content := range []string{
+		`{"command": "sleep 30", "follow": true}`,
+		`{"command": "sleep 30", "follow": true, "timeout_seconds": 60}`,
+	} {
+		started := time.Now()
+		result, err := tool.Execute(context.Background(), FunctionCall{Content: content})
+		if err != nil {
+			t.Fatalf("Execute failed: %v", err)
+		}
+		if !strings.Contains(result, "[Command stopped after the 300ms timeout]") || time.Since(started) > 5*time.Second {
+			t.Errorf("Expected %s to stop after the configured timeout, got %q after %s", content, result, time.Since(started))
+		}
+	}
+}
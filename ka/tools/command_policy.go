@@ -0,0 +1,106 @@
+package tools
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// commandSeparators split a shell command line into the simple commands
+// checked against the allow and deny lists.
+var commandSeparators = regexp.MustCompile(`&&|\|\||[;|&\n]`)
+
+// checkCommand reports why a command line may not run, or nil. Every simple
+// command of the line must match an allow pattern, when there are any, and
+// none may match a deny pattern. A pattern containing a space or '*' is
+// matched against the whole simple command, with '*' standing for any text;
+// otherwise it names a program. An allow pattern naming a program only
+// matches the bare name, which the shell looks up in PATH, or the exact
+// absolute path it gives: ./ls or /tmp/x/ls do not match ls. Command
+// substitutions and leading variable assignments (PATH=... ls) cannot be
+// checked, so they are refused when an allowlist is set.
+//
+// The denylist is best-effort: a shell offers many ways to spell a program
+// (eval, sh -c, variables), so only the allowlist bounds what may run.
+func checkCommand(command string, allow, deny []string) error {
+	if len(allow) > 0 && (strings.Contains(command, "$(") || strings.Contains(command, "`") || strings.Contains(command, "<(") || strings.Contains(command, ">(")) {
+		return fmt.Errorf("command %q uses command substitution, which is not allowed when commands are restricted to an allowlist", command)
+	}
+	for _, simple := range commandSeparators.Split(command, -1) {
+		simple = normalizeCommand(simple)
+		if simple == "" {
+			continue
+		}
+		for _, pattern := range deny {
+			if commandMatches(simple, pattern) {
+				return fmt.Errorf("command %q is denied by the pattern %q", simple, pattern)
+			}
+		}
+		if len(allow) == 0 {
+			continue
+		}
+		if fields := strings.Fields(simple); envAssignment.MatchString(fields[0]) {
+			return fmt.Errorf("command %q sets environment variables, which is not allowed when commands are restricted to an allowlist", simple)
+		}
+		allowed := false
+		for _, pattern := range allow {
+			if commandAllowed(simple, pattern) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("command %q is not in the allowlist (%s)", simple, strings.Join(allow, ", "))
+		}
+	}
+	return nil
+}
+
+// commandMatches reports whether a simple command matches a deny pattern. A
+// program name matches the program under any path.
+func commandMatches(simple, pattern string) bool {
+	if strings.ContainsAny(pattern, " *") {
+		expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*") + "$"
+		matched, _ := regexp.MatchString(expr, simple)
+		return matched
+	}
+	return filepath.Base(commandProgram(simple)) == pattern
+}
+
+// commandAllowed reports whether a simple command matches an allow pattern.
+// Unlike deny patterns, a program name only matches the program exactly.
+func commandAllowed(simple, pattern string) bool {
+	if strings.ContainsAny(pattern, " *") {
+		return commandMatches(simple, pattern)
+	}
+	return strings.Fields(simple)[0] == pattern
+}
+
+// envAssignment matches a shell variable assignment word, as in FOO=1 cmd.
+var envAssignment = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+
+// commandProgram returns the program a simple command runs, skipping leading
+// environment variable assignments.
+func commandProgram(simple string) string {
+	for _, word := range strings.Fields(simple) {
+		if !strings.Contains(word, "=") {
+			return word
+		}
+	}
+	return ""
+}
+
+// commandQuoting is removed from simple commands before matching, so that
+// 'rm', "rm" and \rm all match a pattern naming rm.
+var commandQuoting = strings.NewReplacer(`'`, "", `"`, "", `\`, "")
+
+// normalizeCommand returns a simple command as matched against patterns:
+// without quotes and backslashes, and without the parentheses and braces of
+// subshells and groups around it, as in (rm x) and { rm x; }.
+func normalizeCommand(simple string) string {
+	simple = commandQuoting.Replace(simple)
+	simple = strings.TrimLeft(simple, "({ \t")
+	simple = strings.TrimRight(simple, ")} \t")
+	return strings.TrimSpace(simple)
+}
@@ -0,0 +1,72 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DescribeToolName is the name of the describe_tool meta-tool.
+const DescribeToolName = "describe_tool"
+
+// DescribeTool returns the full definition of any available tool, so the
+// system prompt can leave definitions out (see PruneToolDefinitions) while
+// the agent keeps access to the whole toolbox.
+type DescribeTool struct {
+	Tools map[string]Tool // The agent's available tools, shared with the dispatcher
+}
+
+// DescribeToolArgs defines the JSON arguments of describe_tool.
+type DescribeToolArgs struct {
+	Name string `json:"name"`
+}
+
+// NewDescribeTool creates the tool over the agent's tool map.
+func NewDescribeTool(tools map[string]Tool) *DescribeTool {
+	return &DescribeTool{Tools: tools}
+}
+
+func (t *DescribeTool) GetName() string {
+	return DescribeToolName
+}
+
+func (t *DescribeTool) GetDescription() string {
+	return "Returns the full definition and invocation format of an available tool, or of a tool of a connected MCP server, by name. Use it before calling a tool whose definition is not in your instructions."
+}
+
+func (t *DescribeTool) GetXMLDefinition() string {
+	return `<tool id="describe_tool">{"name": "tool name"}</tool>`
+}
+
+func (t *DescribeTool) Execute(ctx context.Context, callDetails FunctionCall) (string, error) {
+	var args DescribeToolArgs
+	if err := json.Unmarshal([]byte(callDetails.Content), &args); err != nil {
+		return "", fmt.Errorf("failed to parse arguments JSON for describe_tool: %w. Content: %s", err, callDetails.Content)
+	}
+	name := strings.TrimSpace(args.Name)
+	if name == "" {
+		return "", fmt.Errorf("missing required 'name' field, e.g. {\"name\": \"read_file\"}")
+	}
+
+	if tool, ok := t.Tools[name]; ok {
+		return fmt.Sprintf("## Tool \"%s\"\n%s\n%s", tool.GetName(), tool.GetDescription(), tool.GetXMLDefinition()), nil
+	}
+	if mcp, ok := t.Tools["mcp"].(*McpTool); ok {
+		for _, server := range mcp.Configs {
+			for _, def := range server.Tools {
+				if def.Name == name {
+					return fmt.Sprintf("## Tool \"%s\" of MCP server \"%s\"\n%s\nInvoke it through the mcp tool:\n<tool id=\"mcp\" server=\"%s\" tool=\"%s\">{arguments as JSON}</tool>", def.Name, server.Name, def.Description, server.Name, def.Name), nil
+				}
+			}
+		}
+	}
+
+	names := make([]string, 0, len(t.Tools))
+	for toolName := range t.Tools {
+		names = append(names, toolName)
+	}
+	sort.Strings(names)
+	return "", fmt.Errorf("unknown tool '%s'; available tools: %s", name, strings.Join(names, ", "))
+}
@@ -0,0 +1,36 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestDescribeToolReturnsDefinitions(t *testing.T) {
+	mcp := &McpTool{Configs: map[string]McpServerConfig{
+		"github": {Name: "github", Tools: []ToolDefinition{{Name: "create_issue", Description: "Opens an issue."}}},
+	}}
+	availableTools := map[string]Tool{
+		"read_file": &ReadFileTool{},
+		"mcp":       mcp,
+	}
+	describe := NewDescribeTool(availableTools)
+
+	out, err := describe.Execute(context.Background(), FunctionCall{Content: `{"name": "read_file"}`})
+	if err != nil {
+		t.Fatalf("Execute: %v", err)
+	}
+	if !strings.Contains(out, `<tool id="read_file">`) {
+		t.Errorf("expected the XML definition of read_file, got %q", out)
+	}
+
+	out, err = describe.Execute(context.Background(), FunctionCall{Content: `{"name": "create_issue"}`})
+	if err != nil || !strings.Contains(out, `server="github" tool="create_issue"`) {
+		t.Errorf("expected the invocation of the MCP server tool, got %q, %v", out, err)
+	}
+
+	_, err = describe.Execute(context.Background(), FunctionCall{Content: `{"name": "fly"}`})
+	if err == nil || !strings.Contains(err.Error(), "mcp, read_file") {
+		t.Errorf("expected an error listing the available tools, got %v", err)
+	}
+}
@@ -1,31 +1,53 @@
 package tools
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"time"
+	"unicode/utf8"
 )
 
 // ExecuteCommandParams defines the parameters for the ExecuteCommandTool.
 type ExecuteCommandParams struct {
 	Command string `json:"command"`
+	// Follow streams output live while the command runs and allows stopping it early.
+	Follow         bool   `json:"follow,omitempty"`
+	Until          string `json:"until,omitempty"`           // Follow mode: stop once an output line matches this regex
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"` // Follow mode: stop after this many seconds, at most the tool's Timeout
 }
 
 // ExecuteCommandTool implements the Tool interface for executing CLI commands.
-type ExecuteCommandTool struct{}
+// The zero value runs any command in the agent's working directory without a
+// time or output limit.
+type ExecuteCommandTool struct {
+	Allow          []string      // Command patterns that may run; empty allows every command not denied (see checkCommand)
+	Deny           []string      // Command patterns that never run
+	Timeout        time.Duration // Stops every command after this long, in follow mode too; 0 disables
+	MaxOutputBytes int           // Output returned to the LLM is cut to this many bytes, keeping its start and end; 0 disables
+	Dir            string        // Working directory when the context names none; empty is the agent's
+}
 
 func (t *ExecuteCommandTool) GetName() string {
 	return "execute_command"
 }
 
 func (t *ExecuteCommandTool) GetDescription() string {
-	return "Request to execute a CLI command on the system. Use this when you need to perform system operations or run specific commands to accomplish any step in the user's task if other tools cannot solve the issue. You must tailor your command to the user's system and provide a clear explanation of what the command does. For command chaining, use the appropriate chaining syntax for the user's shell. Prefer to execute complex CLI commands over creating executable scripts, as they are more flexible and easier to run. Commands will be executed in the current working directory. Avoid running commands that result in interactive mode."
+	return "Request to execute a CLI command on the system. Use this when you need to perform system operations or run specific commands to accomplish any step in the user's task if other tools cannot solve the issue. You must tailor your command to the user's system and provide a clear explanation of what the command does. For command chaining, use the appropriate chaining syntax for the user's shell. Prefer to execute complex CLI commands over creating executable scripts, as they are more flexible and easier to run. Commands will be executed in the task's working directory. Avoid running commands that result in interactive mode. A command exiting with a non-zero code is reported with its code and output."
 }
 
 func (t *ExecuteCommandTool) GetXMLDefinition() string {
-	return `<tool id="execute_command">{"command": "your command here"}</tool>`
+	return `<tool id="execute_command">{"command": "your command here", "follow": false (optional, stream output live for long-running commands), "until": "regex" (optional, follow mode: stop the command once an output line matches), "timeout_seconds": 0 (optional, follow mode: stop the command after this many seconds)}</tool>`
+}
+
+// GetDependencies reports the shell used to run commands.
+func (t *ExecuteCommandTool) GetDependencies() []ToolDependency {
+	return []ToolDependency{{Binary: commandShell()}}
 }
 
 func (t *ExecuteCommandTool) Execute(ctx context.Context, callDetails FunctionCall) (string, error) {
@@ -38,23 +60,145 @@ func (t *ExecuteCommandTool) Execute(ctx context.Context, callDetails FunctionCa
 		return "", fmt.Errorf("missing or invalid 'command' argument for execute_command")
 	}
 
-	// Get the user's default shell from the SHELL environment variable.
-	shell := os.Getenv("SHELL")
-	if shell == "" {
-		// Fallback to a common shell if SHELL is not set
-		shell = "/bin/sh"
+	if err := checkCommand(params.Command, t.Allow, t.Deny); err != nil {
+		return "", err
+	}
+
+	until, err := untilPattern(params.Until)
+	if err != nil {
+		return "", err
+	}
+
+	shell := commandShell()
+
+	var stopped followTermination
+	var terminate func(reason string)
+	// The model may shorten the configured timeout in follow mode, never extend it.
+	timeout := t.Timeout
+	if requested := time.Duration(params.TimeoutSeconds) * time.Second; params.Follow && requested > 0 && (timeout <= 0 || requested < timeout) {
+		timeout = requested
+	}
+	runCtx := ctx
+	if params.Follow {
+		var cancel context.CancelFunc
+		if timeout > 0 {
+			runCtx, cancel = context.WithTimeout(ctx, timeout)
+		} else {
+			runCtx, cancel = context.WithCancel(ctx)
+		}
+		defer cancel()
+		terminate = func(reason string) {
+			if stopped.set(reason) {
+				cancel()
+			}
+		}
+		if taskID := callDetails.Attributes["__task_id"]; taskID != "" {
+			unregister := followedCommands.add(taskID, &runningCommand{toolCallID: callDetails.Attributes["__tool_call_id"], terminate: terminate})
+			defer unregister()
+		}
+	} else if timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
 	}
 
 	// Execute the command and capture combined output (stdout and stderr).
 	// The user's feedback overrides the .clinerules regarding piping to a log file.
-	cmd := exec.CommandContext(ctx, shell, "-c", params.Command)
+	cmd := exec.CommandContext(runCtx, shell, "-c", params.Command)
+	cmd.Dir = t.Dir
+	if dir := workingDirFrom(ctx); dir != "" {
+		cmd.Dir = dir
+	}
 
-	output, err := cmd.CombinedOutput()
+	// Keep the interleaved output for the LLM and the separate streams for the audit record.
+	var combined lockedBuffer
+	var stdout, stderr bytes.Buffer
+	stdoutWriter, stderrWriter := io.Writer(&stdout), io.Writer(&stderr)
+	var streamers []*lineStreamer
+	if params.Follow {
+		// Kill the whole process group on termination, so servers started by the shell stop too.
+		configureProcessGroup(cmd)
+		cmd.WaitDelay = followWaitDelay
+		base := ToolOutput{Tool: t.GetName(), ToolCallID: callDetails.Attributes["__tool_call_id"]}
+		for _, stream := range []string{"stdout", "stderr"} {
+			streamers = append(streamers, &lineStreamer{ctx: ctx, stream: stream, base: base, until: until, onMatch: func(line string) {
+				terminate(fmt.Sprintf("stopped after output matched %q", params.Until))
+			}})
+		}
+		stdoutWriter = io.MultiWriter(&stdout, streamers[0])
+		stderrWriter = io.MultiWriter(&stderr, streamers[1])
+	}
+	cmd.Stdout = io.MultiWriter(&combined, stdoutWriter)
+	cmd.Stderr = io.MultiWriter(&combined, stderrWriter)
+	err = cmd.Run()
+	for _, streamer := range streamers {
+		streamer.flush()
+	}
+	output := truncateCommandOutput(combined.String(), t.MaxOutputBytes)
+
+	if record := executionRecordFrom(ctx); record != nil {
+		record.Stdout, record.Stderr = stdout.String(), stderr.String()
+		var exitErr *exec.ExitError
+		if err == nil {
+			code := 0
+			record.ExitCode = &code
+		} else if errors.As(err, &exitErr) {
+			code := exitErr.ExitCode()
+			record.ExitCode = &code
+		}
+	}
+
+	if params.Follow && err != nil {
+		reason := stopped.get()
+		if reason == "" && errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+			reason = fmt.Sprintf("stopped after the %s timeout", timeout)
+		}
+		if reason != "" {
+			// An intentional stop is not a failure; tell the model what happened instead.
+			return output + fmt.Sprintf("\n[Command %s]", reason), nil
+		}
+	}
+
+	if !params.Follow && err != nil && errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+		return "", fmt.Errorf("failed to execute command %q: stopped after the %s timeout\nOutput:\n%s", params.Command, timeout, output)
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return "", fmt.Errorf("failed to execute command %q: exited with code %d\nOutput:\n%s", params.Command, exitErr.ExitCode(), output)
+	}
 	if err != nil {
 		// Include the command and output in the error for better debugging
-		return "", fmt.Errorf("failed to execute command %q: %w\nOutput:\n%s", params.Command, err, string(output))
+		return "", fmt.Errorf("failed to execute command %q: %w\nOutput:\n%s", params.Command, err, output)
 	}
 
 	// Return the combined output as the result
-	return string(output), nil
+	return output, nil
+}
+
+// truncateCommandOutput cuts output longer than maxBytes, keeping its start
+// and end, where errors and summaries usually are.
+func truncateCommandOutput(output string, maxBytes int) string {
+	if maxBytes <= 0 || len(output) <= maxBytes {
+		return output
+	}
+	head, tail := maxBytes/2, maxBytes-maxBytes/2
+	for head > 0 && !utf8.RuneStart(output[head]) {
+		head--
+	}
+	start := len(output) - tail
+	for start < len(output) && !utf8.RuneStart(output[start]) {
+		start++
+	}
+	return output[:head] + fmt.Sprintf("\n[... %d bytes of output omitted ...]\n", start-head) + output[start:]
+}
+
+// commandShell returns the user's default shell from the SHELL environment variable.
+func commandShell() string {
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		// Fallback to a common shell if SHELL is not set
+		shell = "/bin/sh"
+	}
+	return shell
 }
@@ -0,0 +1,199 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	defaultFetchTimeout   = 30 * time.Second
+	defaultFetchMaxBytes  = 2 << 20
+	defaultFetchMaxLength = 50000
+	maxFetchRedirects     = 5
+)
+
+// FetchURLArgs are the arguments of the fetch_url tool.
+type FetchURLArgs struct {
+	URL       string            `json:"url"`
+	Method    string            `json:"method,omitempty"` // GET (default) or POST
+	Headers   map[string]string `json:"headers,omitempty"`
+	Body      string            `json:"body,omitempty"`       // POST body
+	Format    string            `json:"format,omitempty"`     // HTML conversion: markdown (default), text or raw
+	MaxLength int               `json:"max_length,omitempty"` // Lowers the length of the returned content
+}
+
+// FetchURLTool reads web pages and calls HTTP APIs. HTML is converted to
+// Markdown or plain text; the response size and the request time are
+// bounded. The zero value uses the defaults.
+type FetchURLTool struct {
+	Client    *http.Client      // Carries the transport, e.g. with a proxy; nil uses the default transport
+	Headers   map[string]string // Sent with every request unless the call sets them
+	Timeout   time.Duration     // Bounds a request including reading the body; 0 is 30s
+	MaxBytes  int64             // Response bodies are read up to this size; 0 is 2 MB
+	MaxLength int               // Returned content is cut to this many bytes; 0 is 50000
+}
+
+func (t *FetchURLTool) GetName() string {
+	return "fetch_url"
+}
+
+func (t *FetchURLTool) GetDescription() string {
+	return "Fetches a URL over HTTP(S) with GET or POST, e.g. to read documentation pages or call a web API. HTML pages are converted to Markdown (format 'markdown', default), plain text ('text') or returned unchanged ('raw'); text and JSON responses are returned as they are. Responses are size-limited and long content is truncated. Returns the final URL, status, content type and the content."
+}
+
+func (t *FetchURLTool) GetXMLDefinition() string {
+	return `<tool id="fetch_url">{"url": "https://example.com/docs", "method": "GET" (optional, or POST), "headers": {"Accept": "text/html"} (optional), "body": "request body" (optional, for POST), "format": "markdown" (optional, or text or raw), "max_length": 20000 (optional)}</tool>`
+}
+
+func (t *FetchURLTool) Execute(ctx context.Context, callDetails FunctionCall) (string, error) {
+	var args FetchURLArgs
+	if err := json.Unmarshal([]byte(callDetails.Content), &args); err != nil {
+		return "", fmt.Errorf("failed to parse arguments JSON for fetch_url: %w. Content: %s", err, callDetails.Content)
+	}
+	target, err := url.Parse(strings.TrimSpace(args.URL))
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return "", fmt.Errorf("invalid url %q: expected an absolute http or https URL", args.URL)
+	}
+	method := strings.ToUpper(args.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	if method != http.MethodGet && method != http.MethodPost {
+		return "", fmt.Errorf("unsupported method %q (expected GET or POST)", args.Method)
+	}
+	format := args.Format
+	if format == "" {
+		format = "markdown"
+	}
+	if format != "markdown" && format != "text" && format != "raw" {
+		return "", fmt.Errorf("unknown format %q (expected markdown, text or raw)", args.Format)
+	}
+
+	timeout := t.Timeout
+	if timeout <= 0 {
+		timeout = defaultFetchTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var body io.Reader
+	if method == http.MethodPost {
+		body = strings.NewReader(args.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), body)
+	if err != nil {
+		return "", fmt.Errorf("failed to create the request: %w", err)
+	}
+	req.Header.Set("User-Agent", "ka-agent fetch_url")
+	req.Header.Set("Accept", "text/html, text/plain, application/json;q=0.9, */*;q=0.5")
+	for name, value := range t.Headers {
+		req.Header.Set(name, value)
+	}
+	for name, value := range args.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := t.client().Do(req)
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("fetching %s timed out after %s", target, timeout)
+		}
+		return "", fmt.Errorf("failed to fetch %s: %w", target, err)
+	}
+	defer resp.Body.Close()
+	maxBytes := t.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultFetchMaxBytes
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read the response of %s: %w", target, err)
+	}
+	bodyTruncated := int64(len(data)) > maxBytes
+	if bodyTruncated {
+		data = data[:maxBytes]
+		// Drop a character cut in half by the limit
+		last := len(data) - 1
+		for last > 0 && !utf8.RuneStart(data[last]) {
+			last--
+		}
+		if last >= 0 && !utf8.FullRune(data[last:]) {
+			data = data[:last]
+		}
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	var title, content string
+	switch {
+	case (mediaType == "text/html" || mediaType == "application/xhtml+xml") && format != "raw":
+		title, content = htmlToText(string(data), resp.Request.URL, format == "markdown")
+	case utf8.Valid(data):
+		content = string(data)
+	default:
+		return "", fmt.Errorf("%s returned binary content (%s, %s) that fetch_url cannot show", target, resp.Status, contentType)
+	}
+
+	maxLength := t.MaxLength
+	if maxLength <= 0 {
+		maxLength = defaultFetchMaxLength
+	}
+	if args.MaxLength > 0 && args.MaxLength < maxLength {
+		maxLength = args.MaxLength
+	}
+	contentTruncated := len(content) > maxLength
+	if contentTruncated {
+		cut := maxLength
+		for cut > 0 && !utf8.RuneStart(content[cut]) {
+			cut--
+		}
+		content = content[:cut]
+	}
+
+	var result strings.Builder
+	fmt.Fprintf(&result, "URL: %s\nStatus: %s\n", resp.Request.URL, resp.Status)
+	if contentType != "" {
+		fmt.Fprintf(&result, "Content-Type: %s\n", contentType)
+	}
+	if title != "" {
+		fmt.Fprintf(&result, "Title: %s\n", title)
+	}
+	result.WriteString("\n")
+	result.WriteString(content)
+	switch {
+	case bodyTruncated:
+		fmt.Fprintf(&result, "\n\n[Response truncated: only the first %d bytes were read]", maxBytes)
+	case contentTruncated:
+		fmt.Fprintf(&result, "\n\n[Content truncated to %d bytes]", maxLength)
+	}
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("%s returned %s:\n%s", target, resp.Status, result.String())
+	}
+	return result.String(), nil
+}
+
+// client returns the HTTP client of the tool, limiting redirects.
+func (t *FetchURLTool) client() *http.Client {
+	client := http.Client{}
+	if t.Client != nil {
+		client = *t.Client
+	}
+	client.Timeout = 0 // The request context carries the timeout
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxFetchRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxFetchRedirects)
+		}
+		if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+			return fmt.Errorf("redirect to unsupported scheme %q", req.URL.Scheme)
+		}
+		return nil
+	}
+	return &client
+}
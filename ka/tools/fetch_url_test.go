@@ -0,0 +1,84 @@
+package tools
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestFetchURLConvertsHTML(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, "/docs/page", http.StatusFound)
+		case "/docs/page":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			io.WriteString(w, `<html><head><title>Guide &amp; Docs</title><style>p{}</style></head>
+<body><script>alert("x")</script><h1>Install</h1><p>Run <code>go get</code> or see <a href="../faq">the FAQ</a>.</p>
+<ul><li>One</li><li>Two</li></ul><pre>line 1
+line 2</pre></body></html>`)
+		case "/api":
+			w.Header().Set("Content-Type", "application/json")
+			body, _ := io.ReadAll(r.Body)
+			io.WriteString(w, `{"method":"`+r.Method+`","token":"`+r.Header.Get("X-Token")+`","body":`+string(body)+`}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	tool := &FetchURLTool{}
+
+	result, err := tool.Execute(context.Background(), FunctionCall{Content: `{"url": "` + server.URL + `/old"}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"URL: " + server.URL + "/docs/page\n", "Status: 200 OK", "Title: Guide & Docs", "# Install", "Run `go get` or see [the FAQ](" + server.URL + "/faq).", "- One\n- Two", "```\nline 1\nline 2\n```"} {
+		if !strings.Contains(result, expected) {
+			t.Errorf("expected %q in the result:\n%s", expected, result)
+		}
+	}
+	if strings.Contains(result, "alert") || strings.Contains(result, "p{}") {
+		t.Errorf("expected scripts and styles to be dropped:\n%s", result)
+	}
+
+	result, err = tool.Execute(context.Background(), FunctionCall{Content: `{"url": "` + server.URL + `/api", "method": "POST", "headers": {"X-Token": "abc"}, "body": "[1]"}`})
+	if err != nil || !strings.Contains(result, `{"method":"POST","token":"abc","body":[1]}`) {
+		t.Errorf("unexpected API result %q (%v)", result, err)
+	}
+
+	if _, err := tool.Execute(context.Background(), FunctionCall{Content: `{"url": "` + server.URL + `/missing"}`}); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("expected a 404 to be reported, got %v", err)
+	}
+	if _, err := tool.Execute(context.Background(), FunctionCall{Content: `{"url": "file:///etc/passwd"}`}); err == nil {
+		t.Error("expected a non-HTTP URL to be refused")
+	}
+}
+
+func TestFetchURLLimitsSize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, strings.Repeat("x", 1000))
+	}))
+	defer server.Close()
+
+	result, err := (&FetchURLTool{MaxBytes: 100}).Execute(context.Background(), FunctionCall{Content: `{"url": "` + server.URL + `"}`})
+	if err != nil || !strings.Contains(result, "\n"+strings.Repeat("x", 100)+"\n\n[Response truncated") {
+		t.Errorf("expected the body to be cut at 100 bytes, got %q (%v)", result, err)
+	}
+	result, err = (&FetchURLTool{}).Execute(context.Background(), FunctionCall{Content: `{"url": "` + server.URL + `", "max_length": 10}`})
+	if err != nil || !strings.HasSuffix(result, "\n"+strings.Repeat("x", 10)+"\n\n[Content truncated to 10 bytes]") {
+		t.Errorf("expected the content to be cut at 10 bytes, got %q (%v)", result, err)
+	}
+}
+
+func TestHTMLToPlainText(t *testing.T) {
+	base, _ := url.Parse("https://example.com/a/")
+	_, text := htmlToText(`<p>Hello <b>big</b>   <a href="x">world</a></p><!-- note --><ol start="3"><li>three</li><li>four</li></ol>`, base, false)
+	if text != "Hello big world\n\n3. three\n4. four" {
+		t.Errorf("unexpected text %q", text)
+	}
+}
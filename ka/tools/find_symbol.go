@@ -0,0 +1,370 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	findSymbolDefaultMaxResults = 100
+	findSymbolMaxFileBytes      = 1 << 20 // Larger files are skipped, they are rarely hand-written code
+)
+
+// findSymbolSkipDirs are directories never indexed.
+var findSymbolSkipDirs = map[string]bool{
+	".git": true, "node_modules": true, "vendor": true, "dist": true, "build": true,
+	"__pycache__": true, ".venv": true, "venv": true, ".next": true, "target": true,
+}
+
+// Symbol is a definition found in the workspace.
+type Symbol struct {
+	Name     string `json:"name"`
+	Kind     string `json:"kind"`               // func, method, type, struct, interface, class, const, var, enum
+	Receiver string `json:"receiver,omitempty"` // Owning type of a method, when known
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+}
+
+// FindSymbolArgs defines the arguments for the find_symbol tool.
+type FindSymbolArgs struct {
+	Name              string `json:"name"`
+	Path              string `json:"path,omitempty"`
+	Kind              string `json:"kind,omitempty"`
+	IncludeReferences bool   `json:"include_references,omitempty"`
+	MaxResults        int    `json:"max_results,omitempty"`
+}
+
+// FindSymbolTool looks up definitions and references of Go, TypeScript/JavaScript
+// and Python symbols. Go files are parsed with go/parser; the other languages use
+// line patterns, which is enough to find declarations without a language server.
+type FindSymbolTool struct{}
+
+func (t *FindSymbolTool) GetName() string {
+	return "find_symbol"
+}
+
+func (t *FindSymbolTool) GetDescription() string {
+	return "Finds where a function, method, type or class is defined in the workspace (Go, TypeScript/JavaScript, Python), and optionally where it is referenced, returning file:line locations. Cheaper and more precise than regex searches when looking for a symbol. Use 'Type.Method' to find a specific method."
+}
+
+func (t *FindSymbolTool) GetXMLDefinition() string {
+	return `<tool id="find_symbol">{"name": "SymbolName or Type.Method", "path": "." (optional), "kind": "func|method|type|class|..." (optional), "include_references": false (optional), "max_results": 100 (optional)}</tool>`
+}
+
+func (t *FindSymbolTool) Execute(ctx context.Context, callDetails FunctionCall) (string, error) {
+	var args FindSymbolArgs
+	if err := json.Unmarshal([]byte(callDetails.Content), &args); err != nil {
+		return "", fmt.Errorf("failed to parse arguments JSON from Content for find_symbol: %w. Content: %s", err, callDetails.Content)
+	}
+	args.Name = strings.TrimSpace(args.Name)
+	if args.Name == "" {
+		return "", fmt.Errorf("missing required 'name' argument for find_symbol")
+	}
+	if args.Path == "" {
+		args.Path = "."
+	}
+	if args.MaxResults <= 0 {
+		args.MaxResults = findSymbolDefaultMaxResults
+	}
+
+	receiver, name := "", args.Name
+	if i := strings.LastIndex(args.Name, "."); i > 0 {
+		receiver, name = args.Name[:i], args.Name[i+1:]
+	}
+
+	files, err := symbolSourceFiles(ctx, args.Path)
+	if err != nil {
+		return "", err
+	}
+
+	var definitions []Symbol
+	for _, file := range files {
+		for _, sym := range IndexSymbols(file.path, file.src) {
+			if sym.Name != name || (receiver != "" && sym.Receiver != receiver) || (args.Kind != "" && sym.Kind != args.Kind) {
+				continue
+			}
+			definitions = append(definitions, sym)
+		}
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Definitions of '%s' (%d):\n", args.Name, len(definitions))
+	for i, sym := range definitions {
+		if i >= args.MaxResults {
+			fmt.Fprintf(&b, "... %d more\n", len(definitions)-i)
+			break
+		}
+		label := sym.Name
+		if sym.Receiver != "" {
+			label = sym.Receiver + "." + sym.Name
+		}
+		fmt.Fprintf(&b, "%s %s  %s:%d\n", sym.Kind, label, sym.File, sym.Line)
+	}
+
+	if args.IncludeReferences {
+		refs := findSymbolReferences(files, name, definitions, args.MaxResults+1)
+		fmt.Fprintf(&b, "\nReferences:\n")
+		for i, ref := range refs {
+			if i >= args.MaxResults {
+				b.WriteString("... more references truncated\n")
+				break
+			}
+			b.WriteString(ref + "\n")
+		}
+		if len(refs) == 0 {
+			b.WriteString("(none)\n")
+		}
+	}
+	return b.String(), nil
+}
+
+type symbolSourceFile struct {
+	path string
+	src  []byte
+}
+
+// symbolSourceFiles reads the indexable files under root in a stable order.
+func symbolSourceFiles(ctx context.Context, root string) ([]symbolSourceFile, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, fmt.Errorf("cannot access path '%s': %w", root, err)
+	}
+	if !info.IsDir() {
+		src, err := os.ReadFile(root)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read '%s': %w", root, err)
+		}
+		return []symbolSourceFile{{path: root, src: src}}, nil
+	}
+
+	var files []symbolSourceFile
+	err = filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil // Skip unreadable entries
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if d.IsDir() {
+			if path != root && findSymbolSkipDirs[d.Name()] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if symbolLanguage(path) == "" {
+			return nil
+		}
+		if fi, err := d.Info(); err != nil || fi.Size() > findSymbolMaxFileBytes {
+			return nil
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil
+		}
+		files = append(files, symbolSourceFile{path: path, src: src})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error walking '%s': %w", root, err)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+	return files, nil
+}
+
+// symbolLanguage returns the indexable language of a file, or "".
+func symbolLanguage(path string) string {
+	switch lang := DetectLanguage(path, ""); lang {
+	case "go", "python", "javascript", "typescript":
+		return lang
+	}
+	return ""
+}
+
+// IndexSymbols returns the symbol definitions in a source file.
+func IndexSymbols(path string, src []byte) []Symbol {
+	switch symbolLanguage(path) {
+	case "go":
+		return indexGoSymbols(path, src)
+	case "python":
+		return indexPatternSymbols(path, src, pythonSymbolPatterns)
+	case "javascript", "typescript":
+		return indexPatternSymbols(path, src, scriptSymbolPatterns)
+	}
+	return nil
+}
+
+// indexGoSymbols parses a Go file and lists its top-level declarations.
+func indexGoSymbols(path string, src []byte) []Symbol {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, src, parser.SkipObjectResolution)
+	if file == nil {
+		return nil
+	}
+	_ = err // A partially parsed file still yields useful declarations
+
+	var symbols []Symbol
+	add := func(name, kind, receiver string, pos token.Pos) {
+		symbols = append(symbols, Symbol{Name: name, Kind: kind, Receiver: receiver, File: path, Line: fset.Position(pos).Line})
+	}
+	for _, decl := range file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				add(d.Name.Name, "method", goReceiverType(d.Recv.List[0].Type), d.Name.Pos())
+			} else {
+				add(d.Name.Name, "func", "", d.Name.Pos())
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					kind := "type"
+					switch s.Type.(type) {
+					case *ast.StructType:
+						kind = "struct"
+					case *ast.InterfaceType:
+						kind = "interface"
+					}
+					add(s.Name.Name, kind, "", s.Name.Pos())
+				case *ast.ValueSpec:
+					kind := "var"
+					if d.Tok == token.CONST {
+						kind = "const"
+					}
+					for _, ident := range s.Names {
+						if ident.Name != "_" {
+							add(ident.Name, kind, "", ident.Pos())
+						}
+					}
+				}
+			}
+		}
+	}
+	return symbols
+}
+
+// goReceiverType returns the type name of a method receiver, without pointer or type parameters.
+func goReceiverType(expr ast.Expr) string {
+	for {
+		switch e := expr.(type) {
+		case *ast.StarExpr:
+			expr = e.X
+		case *ast.IndexExpr:
+			expr = e.X
+		case *ast.IndexListExpr:
+			expr = e.X
+		case *ast.Ident:
+			return e.Name
+		default:
+			return ""
+		}
+	}
+}
+
+// symbolPattern matches a declaration line; the first capture group is the name.
+type symbolPattern struct {
+	kind string
+	re   *regexp.Regexp
+}
+
+var pythonSymbolPatterns = []symbolPattern{
+	{"class", regexp.MustCompile(`^\s*class\s+(\w+)`)},
+	{"func", regexp.MustCompile(`^\s*(?:async\s+)?def\s+(\w+)`)},
+}
+
+var scriptSymbolPatterns = []symbolPattern{
+	{"class", regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:abstract\s+)?class\s+(\w+)`)},
+	{"interface", regexp.MustCompile(`^\s*(?:export\s+)?interface\s+(\w+)`)},
+	{"type", regexp.MustCompile(`^\s*(?:export\s+)?type\s+(\w+)\s*(?:<[^=]*>)?\s*=`)},
+	{"enum", regexp.MustCompile(`^\s*(?:export\s+)?(?:const\s+)?enum\s+(\w+)`)},
+	{"func", regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:async\s+)?function\*?\s+(\w+)`)},
+	{"func", regexp.MustCompile(`^\s*(?:export\s+)?(?:const|let|var)\s+(\w+)\s*(?::[^=]+)?=\s*(?:async\s*)?(?:function\b|\([^)]*\)\s*(?::[^=]+)?=>|\w+\s*=>)`)},
+	{"method", regexp.MustCompile(`^\s+(?:(?:public|private|protected|static|async|readonly|override|get|set)\s+)*(\w+)\s*(?:<[^>]*>)?\s*\([^)]*\)\s*(?::\s*[^{;]+)?\{\s*$`)},
+}
+
+// scriptKeywords look like method declarations to the pattern above but are control flow.
+var scriptKeywords = map[string]bool{"if": true, "for": true, "while": true, "switch": true, "catch": true, "function": true, "return": true}
+
+// indentedClassPattern recognises the start of a class body, to attribute methods to it.
+var indentedClassPattern = regexp.MustCompile(`^(\s*)(?:export\s+)?(?:default\s+)?(?:abstract\s+)?class\s+(\w+)`)
+
+// indexPatternSymbols lists declarations matched line by line. Indented
+// functions inside a class are reported as methods of that class.
+func indexPatternSymbols(path string, src []byte, patterns []symbolPattern) []Symbol {
+	var symbols []Symbol
+	className, classIndent := "", -1
+	scanner := bufio.NewScanner(bytes.NewReader(src))
+	scanner.Buffer(make([]byte, 64*1024), findSymbolMaxFileBytes)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "//") {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " \t"))
+		if className != "" && indent <= classIndent && trimmed != "}" {
+			className, classIndent = "", -1
+		}
+		for _, p := range patterns {
+			m := p.re.FindStringSubmatch(line)
+			if m == nil || scriptKeywords[m[1]] {
+				continue
+			}
+			sym := Symbol{Name: m[1], Kind: p.kind, File: path, Line: lineNo}
+			if className != "" && indent > classIndent && (p.kind == "func" || p.kind == "method") {
+				sym.Kind, sym.Receiver = "method", className
+			} else if p.kind == "method" {
+				break // Method-like syntax outside a class is a call or control flow
+			}
+			symbols = append(symbols, sym)
+			break
+		}
+		if m := indentedClassPattern.FindStringSubmatch(line); m != nil {
+			className, classIndent = m[2], len(m[1])
+		}
+	}
+	return symbols
+}
+
+// findSymbolReferences lists "file:line: text" for whole-word uses of name that are not definitions.
+func findSymbolReferences(files []symbolSourceFile, name string, definitions []Symbol, limit int) []string {
+	defined := make(map[string]bool, len(definitions))
+	for _, sym := range definitions {
+		defined[fmt.Sprintf("%s:%d", sym.File, sym.Line)] = true
+	}
+	word := regexp.MustCompile(`\b` + regexp.QuoteMeta(name) + `\b`)
+
+	var refs []string
+	for _, file := range files {
+		if !bytes.Contains(file.src, []byte(name)) {
+			continue
+		}
+		for i, line := range strings.Split(string(file.src), "\n") {
+			if !word.MatchString(line) {
+				continue
+			}
+			location := fmt.Sprintf("%s:%d", file.path, i+1)
+			if defined[location] {
+				continue
+			}
+			refs = append(refs, fmt.Sprintf("%s: %s", location, strings.TrimSpace(line)))
+			if len(refs) >= limit {
+				return refs
+			}
+		}
+	}
+	return refs
+}
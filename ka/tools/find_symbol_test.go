@@ -0,0 +1,82 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIndexSymbols_Go(t *testing.T) {
+	src := `package demo
+
+type Store struct{}
+
+type Reader interface{ Read() }
+
+const Limit = 3
+
+func NewStore() *Store { return &Store{} }
+
+func (s *Store) Get(id string) string { return id }
+`
+	symbols := IndexSymbols("demo.go", []byte(src))
+	want := map[string]string{"Store": "struct", "Reader": "interface", "Limit": "const", "NewStore": "func", "Get": "method"}
+	for _, sym := range symbols {
+		if kind, ok := want[sym.Name]; ok && kind == sym.Kind {
+			delete(want, sym.Name)
+		}
+		if sym.Name == "Get" && (sym.Receiver != "Store" || sym.Line != 11) {
+			t.Errorf("Expected Store.Get on line 11, got %+v", sym)
+		}
+	}
+	if len(want) > 0 {
+		t.Errorf("Missing symbols %v in %+v", want, symbols)
+	}
+}
+
+func TestIndexSymbols_PythonAndTypeScript(t *testing.T) {
+	py := "class Greeter:\n    def greet(self):\n        pass\n\ndef main():\n    Greeter().greet()\n"
+	symbols := IndexSymbols("app.py", []byte(py))
+	if len(symbols) != 3 || symbols[1].Kind != "method" || symbols[1].Receiver != "Greeter" || symbols[2].Name != "main" || symbols[2].Kind != "func" {
+		t.Errorf("Unexpected Python symbols: %+v", symbols)
+	}
+
+	ts := "export interface Props {}\nexport class Widget {\n  render(): string {\n    if (x) {\n    }\n    return ''\n  }\n}\nexport const build = (p: Props) => new Widget()\n"
+	symbols = IndexSymbols("widget.ts", []byte(ts))
+	names := []string{}
+	for _, sym := range symbols {
+		names = append(names, sym.Kind+":"+sym.Name)
+	}
+	if got := strings.Join(names, ","); got != "interface:Props,class:Widget,method:render,func:build" {
+		t.Errorf("Unexpected TypeScript symbols: %s", got)
+	}
+}
+
+func TestFindSymbolTool_DefinitionsAndReferences(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.go"), []byte("package a\n\nfunc Build() {}\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "b.go"), []byte("package a\n\nfunc use() {\n\tBuild()\n}\n"), 0o644)
+	os.MkdirAll(filepath.Join(dir, "node_modules"), 0o755)
+	os.WriteFile(filepath.Join(dir, "node_modules", "c.js"), []byte("function Build() {}\n"), 0o644)
+
+	tool := &FindSymbolTool{}
+	out, err := tool.Execute(context.Background(), FunctionCall{Content: `{"name": "Build", "path": "` + dir + `", "include_references": true}`})
+	if err != nil {
+		t.Fatalf("Execute failed: %v", err)
+	}
+	if !strings.Contains(out, "Definitions of 'Build' (1):") || !strings.Contains(out, "func Build  "+filepath.Join(dir, "a.go")+":3") {
+		t.Errorf("Unexpected definitions:\n%s", out)
+	}
+	if !strings.Contains(out, filepath.Join(dir, "b.go")+":4: Build()") {
+		t.Errorf("Expected the call in b.go as a reference:\n%s", out)
+	}
+	if strings.Contains(out, "node_modules") {
+		t.Errorf("Expected node_modules to be skipped:\n%s", out)
+	}
+
+	if _, err := tool.Execute(context.Background(), FunctionCall{Content: `{"path": "."}`}); err == nil {
+		t.Errorf("Expected an error without a name")
+	}
+}
@@ -0,0 +1,333 @@
+package tools
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// htmlSkippedElements are dropped with their content.
+var htmlSkippedElements = map[string]bool{
+	"script": true, "style": true, "noscript": true, "template": true,
+	"svg": true, "iframe": true, "head": true, "object": true, "canvas": true,
+}
+
+// htmlBlockElements start and end a paragraph.
+var htmlBlockElements = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "main": true,
+	"header": true, "footer": true, "nav": true, "aside": true, "ul": true,
+	"ol": true, "table": true, "blockquote": true, "figure": true, "form": true,
+	"dl": true, "dt": true, "dd": true, "details": true, "summary": true,
+}
+
+// htmlInlineElements keep the whitespace before them.
+var htmlInlineElements = map[string]bool{
+	"a": true, "img": true, "strong": true, "b": true, "em": true, "i": true, "code": true,
+}
+
+var (
+	htmlAttrPattern      = regexp.MustCompile(`([a-zA-Z_:][-a-zA-Z0-9_:.]*)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+)))?`)
+	htmlTitlePattern     = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	blankLinesPattern    = regexp.MustCompile(`\n{3,}`)
+	trailingSpacePattern = regexp.MustCompile(`[ \t]+\n`)
+)
+
+// htmlToText converts an HTML document to Markdown, or to plain text without
+// markup. Scripts, styles and other non-content elements are dropped, and
+// links are resolved against base. It is a lenient converter for reading
+// pages, not a full HTML parser.
+func htmlToText(doc string, base *url.URL, markdown bool) (title, text string) {
+	if match := htmlTitlePattern.FindStringSubmatch(doc); match != nil {
+		title = strings.Join(strings.Fields(html.UnescapeString(match[1])), " ")
+	}
+	c := &htmlConverter{base: base, markdown: markdown}
+	for i := 0; i < len(doc); {
+		if doc[i] != '<' {
+			end := strings.IndexByte(doc[i:], '<')
+			if end < 0 {
+				end = len(doc) - i
+			}
+			c.text(doc[i : i+end])
+			i += end
+			continue
+		}
+		switch {
+		case strings.HasPrefix(doc[i:], "<!--"):
+			end := strings.Index(doc[i+4:], "-->")
+			if end < 0 {
+				return title, c.result()
+			}
+			i += 4 + end + 3
+			continue
+		case i+1 < len(doc) && (doc[i+1] == '!' || doc[i+1] == '?'):
+			end := strings.IndexByte(doc[i:], '>')
+			if end < 0 {
+				return title, c.result()
+			}
+			i += end + 1
+			continue
+		}
+		end := htmlTagEnd(doc, i)
+		if end < 0 {
+			c.text(doc[i:])
+			break
+		}
+		name, attrs, closing := parseHTMLTag(doc[i+1 : end])
+		i = end + 1
+		if name == "" {
+			c.text(doc[i-1 : i])
+			continue
+		}
+		if !closing && htmlSkippedElements[name] {
+			// Skip to the matching end tag; the content is not HTML in script and style
+			closeTag := "</" + name
+			next := strings.Index(strings.ToLower(doc[i:]), closeTag)
+			if next < 0 {
+				break
+			}
+			i += next
+			if gt := strings.IndexByte(doc[i:], '>'); gt >= 0 {
+				i += gt + 1
+			} else {
+				i = len(doc)
+			}
+			continue
+		}
+		c.tag(name, attrs, closing)
+	}
+	return title, c.result()
+}
+
+// htmlTagEnd returns the index of the '>' closing the tag starting at start,
+// skipping quoted attribute values, or -1.
+func htmlTagEnd(doc string, start int) int {
+	var quote byte
+	for i := start + 1; i < len(doc); i++ {
+		switch {
+		case quote != 0:
+			if doc[i] == quote {
+				quote = 0
+			}
+		case doc[i] == '"' || doc[i] == '\'':
+			quote = doc[i]
+		case doc[i] == '>':
+			return i
+		}
+	}
+	return -1
+}
+
+// parseHTMLTag parses the inside of a tag, e.g. `a href="/x"` or `/p`.
+func parseHTMLTag(inner string) (name string, attrs map[string]string, closing bool) {
+	inner = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(inner), "/"))
+	if strings.HasPrefix(inner, "/") {
+		closing = true
+		inner = inner[1:]
+	}
+	end := strings.IndexFunc(inner, func(r rune) bool { return r == ' ' || r == '\t' || r == '\n' || r == '\r' })
+	if end < 0 {
+		end = len(inner)
+	}
+	name = strings.ToLower(inner[:end])
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+			return "", nil, false
+		}
+	}
+	attrs = make(map[string]string)
+	for _, match := range htmlAttrPattern.FindAllStringSubmatch(inner[end:], -1) {
+		attrs[strings.ToLower(match[1])] = html.UnescapeString(match[2] + match[3] + match[4])
+	}
+	return name, attrs, closing
+}
+
+// htmlConverter accumulates the text of a document as its tags and text
+// are fed in order.
+type htmlConverter struct {
+	out      strings.Builder
+	base     *url.URL
+	markdown bool
+	pre      int
+	lists    []int // One entry per open list: -1 for ul, else the next ol number
+	href     string
+	space    bool // Whitespace seen since the last text, written before the next inline content
+}
+
+func (c *htmlConverter) text(raw string) {
+	text := html.UnescapeString(raw)
+	if c.pre > 0 {
+		c.out.WriteString(text)
+		return
+	}
+	if raw != "" && isHTMLSpace(raw[0]) {
+		c.space = true
+	}
+	text = strings.Join(strings.Fields(text), " ")
+	if text == "" {
+		return
+	}
+	c.flushSpace()
+	c.out.WriteString(text)
+	c.space = isHTMLSpace(raw[len(raw)-1])
+}
+
+// flushSpace writes pending whitespace unless a line just started.
+func (c *htmlConverter) flushSpace() {
+	if c.space && !c.atLineStart() {
+		c.out.WriteByte(' ')
+	}
+	c.space = false
+}
+
+func (c *htmlConverter) tag(name string, attrs map[string]string, closing bool) {
+	if htmlInlineElements[name] && !closing {
+		c.flushSpace()
+	}
+	switch {
+	case len(name) == 2 && name[0] == 'h' && name[1] >= '1' && name[1] <= '6':
+		c.blankLine()
+		if !closing && c.markdown {
+			c.out.WriteString(strings.Repeat("#", int(name[1]-'0')) + " ")
+		}
+	case name == "br":
+		c.out.WriteByte('\n')
+	case name == "hr":
+		c.blankLine()
+		if c.markdown {
+			c.out.WriteString("---")
+			c.blankLine()
+		}
+	case name == "pre":
+		if closing {
+			c.pre = max(c.pre-1, 0)
+			c.newLine()
+			if c.markdown {
+				c.out.WriteString("```")
+			}
+			c.blankLine()
+		} else {
+			c.blankLine()
+			if c.markdown {
+				c.out.WriteString("```\n")
+			}
+			c.pre++
+		}
+	case name == "ul" || name == "ol":
+		c.blankLine()
+		if closing {
+			if len(c.lists) > 0 {
+				c.lists = c.lists[:len(c.lists)-1]
+			}
+		} else if name == "ul" {
+			c.lists = append(c.lists, -1)
+		} else {
+			start, err := strconv.Atoi(attrs["start"])
+			if err != nil {
+				start = 1
+			}
+			c.lists = append(c.lists, start)
+		}
+	case name == "li":
+		if closing {
+			return
+		}
+		c.newLine()
+		depth := max(len(c.lists), 1)
+		c.out.WriteString(strings.Repeat("  ", depth-1))
+		if len(c.lists) > 0 && c.lists[depth-1] >= 0 {
+			c.out.WriteString(strconv.Itoa(c.lists[depth-1]) + ". ")
+			c.lists[depth-1]++
+		} else {
+			c.out.WriteString("- ")
+		}
+	case name == "tr":
+		c.newLine()
+	case name == "td" || name == "th":
+		if closing {
+			c.out.WriteString(" | ")
+		}
+	case name == "a":
+		if !c.markdown {
+			return
+		}
+		if !closing {
+			c.href = c.resolve(attrs["href"])
+			if c.href != "" {
+				c.out.WriteByte('[')
+			}
+		} else if c.href != "" {
+			c.out.WriteString("](" + c.href + ")")
+			c.href = ""
+		}
+	case name == "img":
+		alt := strings.TrimSpace(attrs["alt"])
+		if src := c.resolve(attrs["src"]); c.markdown && alt != "" && src != "" {
+			c.out.WriteString("![" + alt + "](" + src + ")")
+		} else if alt != "" {
+			c.out.WriteString(alt)
+		}
+	case name == "strong" || name == "b":
+		c.wrap("**")
+	case name == "em" || name == "i":
+		c.wrap("_")
+	case name == "code":
+		if c.pre == 0 {
+			c.wrap("`")
+		}
+	case htmlBlockElements[name]:
+		c.blankLine()
+	}
+}
+
+// wrap writes inline Markdown markup.
+func (c *htmlConverter) wrap(markup string) {
+	if c.markdown && c.pre == 0 {
+		c.out.WriteString(markup)
+	}
+}
+
+// resolve returns the absolute URL of a link, or "" for links that lead
+// nowhere useful, such as fragments and scripts.
+func (c *htmlConverter) resolve(ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || strings.HasPrefix(ref, "#") || strings.HasPrefix(strings.ToLower(ref), "javascript:") {
+		return ""
+	}
+	parsed, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	if c.base != nil {
+		parsed = c.base.ResolveReference(parsed)
+	}
+	return parsed.String()
+}
+
+func (c *htmlConverter) atLineStart() bool {
+	s := c.out.String()
+	return s == "" || strings.HasSuffix(s, "\n")
+}
+
+func (c *htmlConverter) newLine() {
+	c.space = false
+	if !c.atLineStart() {
+		c.out.WriteByte('\n')
+	}
+}
+
+func (c *htmlConverter) blankLine() {
+	c.space = false
+	c.out.WriteString("\n\n")
+}
+
+func (c *htmlConverter) result() string {
+	text := trailingSpacePattern.ReplaceAllString(c.out.String(), "\n")
+	text = blankLinesPattern.ReplaceAllString(text, "\n\n")
+	return strings.TrimSpace(text)
+}
+
+func isHTMLSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r' || b == '\n' || b == '\f'
+}
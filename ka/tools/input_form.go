@@ -0,0 +1,178 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Input field types supported in a form.
+const (
+	InputFieldText    = "text"
+	InputFieldNumber  = "number"
+	InputFieldBoolean = "boolean"
+	InputFieldSelect  = "select"
+)
+
+// InputField describes one structured answer the LLM asks the user for.
+type InputField struct {
+	Name     string   `json:"name"`
+	Label    string   `json:"label,omitempty"`
+	Type     string   `json:"type,omitempty"` // Defaults to "text"
+	Required bool     `json:"required,omitempty"`
+	Options  []string `json:"options,omitempty"` // Allowed values for "select"
+	Pattern  string   `json:"pattern,omitempty"` // Regular expression a "text" value must match
+	Min      *float64 `json:"min,omitempty"`     // Minimum value ("number") or length ("text")
+	Max      *float64 `json:"max,omitempty"`     // Maximum value ("number") or length ("text")
+}
+
+// InputForm is a question with optional structured fields, published in the
+// input-required state event and used to validate the answer.
+type InputForm struct {
+	Question string       `json:"question"`
+	Options  []string     `json:"options,omitempty"`
+	Fields   []InputField `json:"fields,omitempty"`
+}
+
+// ParseInputForm reads the form from ask_followup_question arguments.
+func ParseInputForm(content string) (*InputForm, error) {
+	var form InputForm
+	if err := json.Unmarshal([]byte(content), &form); err != nil {
+		return nil, fmt.Errorf("failed to parse question arguments: %w", err)
+	}
+	if err := form.validateSchema(); err != nil {
+		return nil, err
+	}
+	return &form, nil
+}
+
+// validateSchema checks that the fields are well-formed.
+func (f *InputForm) validateSchema() error {
+	seen := make(map[string]bool, len(f.Fields))
+	for i, field := range f.Fields {
+		if strings.TrimSpace(field.Name) == "" {
+			return fmt.Errorf("field %d has no name", i)
+		}
+		if seen[field.Name] {
+			return fmt.Errorf("duplicate field name '%s'", field.Name)
+		}
+		seen[field.Name] = true
+		switch field.Type {
+		case "", InputFieldText, InputFieldNumber, InputFieldBoolean:
+		case InputFieldSelect:
+			if len(field.Options) == 0 {
+				return fmt.Errorf("select field '%s' has no options", field.Name)
+			}
+		default:
+			return fmt.Errorf("field '%s' has unsupported type '%s'", field.Name, field.Type)
+		}
+		if field.Pattern != "" {
+			if _, err := regexp.Compile(field.Pattern); err != nil {
+				return fmt.Errorf("field '%s' has an invalid pattern: %w", field.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ValidateAnswers checks an answer object against the form fields and returns
+// one message per invalid field, keyed by field name. Unknown fields are rejected.
+func (f *InputForm) ValidateAnswers(answers map[string]interface{}) map[string]string {
+	problems := make(map[string]string)
+	known := make(map[string]bool, len(f.Fields))
+	for _, field := range f.Fields {
+		known[field.Name] = true
+		value, ok := answers[field.Name]
+		if !ok || value == nil || value == "" {
+			if field.Required {
+				problems[field.Name] = "is required"
+			}
+			continue
+		}
+		if msg := field.check(value); msg != "" {
+			problems[field.Name] = msg
+		}
+	}
+	for name := range answers {
+		if !known[name] {
+			problems[name] = "is not a field of this question"
+		}
+	}
+	return problems
+}
+
+// check validates a single non-empty value.
+func (field InputField) check(value interface{}) string {
+	switch field.Type {
+	case InputFieldNumber:
+		n, ok := value.(float64)
+		if !ok {
+			return "must be a number"
+		}
+		if field.Min != nil && n < *field.Min {
+			return fmt.Sprintf("must be at least %g", *field.Min)
+		}
+		if field.Max != nil && n > *field.Max {
+			return fmt.Sprintf("must be at most %g", *field.Max)
+		}
+	case InputFieldBoolean:
+		if _, ok := value.(bool); !ok {
+			return "must be true or false"
+		}
+	case InputFieldSelect:
+		s, ok := value.(string)
+		if !ok {
+			return "must be a string"
+		}
+		for _, opt := range field.Options {
+			if s == opt {
+				return ""
+			}
+		}
+		return fmt.Sprintf("must be one of: %s", strings.Join(field.Options, ", "))
+	default:
+		s, ok := value.(string)
+		if !ok {
+			return "must be a string"
+		}
+		if field.Min != nil && float64(len(s)) < *field.Min {
+			return fmt.Sprintf("must be at least %g characters", *field.Min)
+		}
+		if field.Max != nil && float64(len(s)) > *field.Max {
+			return fmt.Sprintf("must be at most %g characters", *field.Max)
+		}
+		if field.Pattern != "" && !regexp.MustCompile(field.Pattern).MatchString(s) {
+			return fmt.Sprintf("must match %s", field.Pattern)
+		}
+	}
+	return ""
+}
+
+// FormatAnswers renders answers as "Label: value" lines in field order, for the LLM.
+func (f *InputForm) FormatAnswers(answers map[string]interface{}) string {
+	var b strings.Builder
+	for _, field := range f.Fields {
+		value, ok := answers[field.Name]
+		if !ok {
+			continue
+		}
+		label := field.Label
+		if label == "" {
+			label = field.Name
+		}
+		fmt.Fprintf(&b, "%s: %v\n", label, value)
+	}
+	return b.String()
+}
+
+// SortedProblemFields returns the field names of a ValidateAnswers result in a stable order.
+func SortedProblemFields(problems map[string]string) []string {
+	names := make([]string, 0, len(problems))
+	for name := range problems {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
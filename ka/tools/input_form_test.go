@@ -0,0 +1,51 @@
+package tools
+
+import (
+	"testing"
+)
+
+func TestInputFormValidateAnswers(t *testing.T) {
+	form, err := ParseInputForm(`{"question": "Details?", "fields": [
+		{"name": "env", "type": "select", "options": ["dev", "prod"], "required": true},
+		{"name": "replicas", "type": "number", "min": 1, "max": 5},
+		{"name": "confirm", "type": "boolean"},
+		{"name": "ticket", "pattern": "^[A-Z]+-[0-9]+$"}
+	]}`)
+	if err != nil {
+		t.Fatalf("ParseInputForm: %v", err)
+	}
+
+	valid := map[string]interface{}{"env": "prod", "replicas": float64(3), "confirm": true, "ticket": "OPS-12"}
+	if problems := form.ValidateAnswers(valid); len(problems) != 0 {
+		t.Errorf("expected valid answers, got %v", problems)
+	}
+
+	invalid := map[string]interface{}{"replicas": float64(9), "confirm": "yes", "ticket": "ops", "extra": 1}
+	problems := form.ValidateAnswers(invalid)
+	for _, name := range []string{"env", "replicas", "confirm", "ticket", "extra"} {
+		if _, ok := problems[name]; !ok {
+			t.Errorf("expected a problem for field %q, got %v", name, problems)
+		}
+	}
+	if got := SortedProblemFields(problems); got[0] != "confirm" {
+		t.Errorf("expected sorted field names, got %v", got)
+	}
+}
+
+func TestParseInputFormRejectsBadSchema(t *testing.T) {
+	if _, err := ParseInputForm(`{"question": "q", "fields": [{"name": "a"}, {"name": "a"}]}`); err == nil {
+		t.Errorf("expected an error for duplicate field names")
+	}
+	if _, err := ParseInputForm(`{"question": "q", "fields": [{"name": "a", "type": "date"}]}`); err == nil {
+		t.Errorf("expected an error for an unsupported type")
+	}
+}
+
+func TestInputFormFormatAnswers(t *testing.T) {
+	form := &InputForm{Fields: []InputField{{Name: "env", Label: "Environment"}, {Name: "replicas"}}}
+	got := form.FormatAnswers(map[string]interface{}{"replicas": float64(2), "env": "dev"})
+	want := "Environment: dev\nreplicas: 2\n"
+	if got != want {
+		t.Errorf("FormatAnswers() = %q, want %q", got, want)
+	}
+}
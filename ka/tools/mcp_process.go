@@ -0,0 +1,196 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mcpProcessWaitDelay is how long Wait waits for the pipes to close after the
+// process group was killed.
+const mcpProcessWaitDelay = 2 * time.Second
+
+// mcpPidDir holds one file per running MCP server process. Each file records
+// the process group and the agent that started it, so a restarted agent can
+// find and kill children orphaned by a crash. The directory must be private
+// to the user: the records name processes to kill.
+var mcpPidDir = defaultMcpPidDir()
+
+// defaultMcpPidDir keeps the records in the user cache, falling back to a
+// per-user directory in the temp directory.
+func defaultMcpPidDir() string {
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, "ka", "mcp-pids")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("ka-mcp-pids-%d", os.Getuid()))
+}
+
+// mcpSemaphores limits concurrent invocations per MCP server.
+type mcpSemaphores struct {
+	mu    sync.Mutex
+	slots map[string]chan struct{}
+}
+
+// acquire blocks until a slot for the server is free or ctx is done.
+// It returns a release function. limit <= 0 means unlimited.
+func (s *mcpSemaphores) acquire(ctx context.Context, serverName string, limit int) (func(), error) {
+	if limit <= 0 {
+		return func() {}, nil
+	}
+	s.mu.Lock()
+	if s.slots == nil {
+		s.slots = make(map[string]chan struct{})
+	}
+	slot, ok := s.slots[serverName]
+	if !ok || cap(slot) != limit {
+		// A changed limit only applies to new invocations.
+		slot = make(chan struct{}, limit)
+		s.slots[serverName] = slot
+	}
+	s.mu.Unlock()
+
+	select {
+	case slot <- struct{}{}:
+		return func() { <-slot }, nil
+	case <-ctx.Done():
+		return nil, fmt.Errorf("waiting for a free slot on MCP server '%s': %w", serverName, ctx.Err())
+	}
+}
+
+// newMcpServerCommand builds the command for an MCP server with resource
+// limits applied and the process placed in its own process group, so that
+// cancelling ctx kills the server and everything it spawned.
+func newMcpServerCommand(ctx context.Context, config McpServerConfig) *exec.Cmd {
+	name, args := config.Command, config.Args
+	if limits := rlimitShellPrefix(config); limits != "" && runtime.GOOS != "windows" {
+		// exec keeps the PID, so the limits apply to the server itself.
+		name, args = "/bin/sh", append([]string{"-c", limits + `exec "$0" "$@"`, config.Command}, config.Args...)
+	}
+	cmd := exec.CommandContext(ctx, name, args...)
+	configureProcessGroup(cmd)
+	cmd.WaitDelay = mcpProcessWaitDelay
+	return cmd
+}
+
+// rlimitShellPrefix returns the ulimit commands for the configured limits.
+func rlimitShellPrefix(config McpServerConfig) string {
+	var b strings.Builder
+	if config.MaxMemoryMB > 0 {
+		fmt.Fprintf(&b, "ulimit -v %d; ", config.MaxMemoryMB*1024)
+	}
+	if config.MaxCPUSeconds > 0 {
+		fmt.Fprintf(&b, "ulimit -t %d; ", config.MaxCPUSeconds)
+	}
+	return b.String()
+}
+
+// mcpProcessRecord is the content of a pid file: the process group of the
+// server, the agent that started it, and the start times of both, so a PID
+// reused by an unrelated process is never mistaken for them.
+type mcpProcessRecord struct {
+	pgid, agentPid            int
+	pgidStarted, agentStarted string
+}
+
+func (r mcpProcessRecord) String() string {
+	return fmt.Sprintf("%d %d %s %s", r.pgid, r.agentPid, r.pgidStarted, r.agentStarted)
+}
+
+func parseMcpProcessRecord(data string) (mcpProcessRecord, error) {
+	var r mcpProcessRecord
+	if _, err := fmt.Sscanf(data, "%d %d %s %s", &r.pgid, &r.agentPid, &r.pgidStarted, &r.agentStarted); err != nil {
+		return r, err
+	}
+	return r, nil
+}
+
+// isProcess reports whether pid is running and is the process that started
+// at started.
+func isProcess(pid int, started string) bool {
+	if !processAlive(pid) {
+		return false
+	}
+	current, err := processStartTime(pid)
+	return err == nil && current == started
+}
+
+// privateMcpPidDir creates mcpPidDir if needed and checks that it is private
+// to the user.
+func privateMcpPidDir() error {
+	if err := os.MkdirAll(mcpPidDir, 0o700); err != nil {
+		return err
+	}
+	return checkPrivateDir(mcpPidDir)
+}
+
+// registerMcpProcess records a started MCP server process. The returned
+// function removes the record once the process has exited. Where the start
+// time of a process cannot be read nothing is recorded, as the record could
+// not be verified later.
+func registerMcpProcess(pid int) func() {
+	if err := privateMcpPidDir(); err != nil {
+		log.Printf("[McpTool] Warning: cannot use MCP pid directory %s: %v", mcpPidDir, err)
+		return func() {}
+	}
+	pgidStarted, err := processStartTime(pid)
+	if err != nil {
+		return func() {}
+	}
+	agentStarted, err := processStartTime(os.Getpid())
+	if err != nil {
+		return func() {}
+	}
+	record := mcpProcessRecord{pgid: pid, agentPid: os.Getpid(), pgidStarted: pgidStarted, agentStarted: agentStarted}
+	path := filepath.Join(mcpPidDir, strconv.Itoa(pid)+".pid")
+	if err := os.WriteFile(path, []byte(record.String()), 0o600); err != nil {
+		log.Printf("[McpTool] Warning: cannot write MCP pid file %s: %v", path, err)
+		return func() {}
+	}
+	return func() { os.Remove(path) }
+}
+
+// ReapOrphanedMcpProcesses kills MCP server process groups left behind by an
+// agent that is no longer running. It should be called once at startup.
+// Records in a directory not private to the user are ignored, and a process
+// group is only killed while its leader is still the recorded process.
+func ReapOrphanedMcpProcesses() {
+	if _, err := os.Stat(mcpPidDir); err != nil {
+		return // Nothing recorded yet
+	}
+	if err := checkPrivateDir(mcpPidDir); err != nil {
+		log.Printf("[McpTool] Warning: not reaping MCP processes recorded in %s: %v", mcpPidDir, err)
+		return
+	}
+	entries, err := os.ReadDir(mcpPidDir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		path := filepath.Join(mcpPidDir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		record, err := parseMcpProcessRecord(string(data))
+		if err != nil || record.pgid <= 1 {
+			os.Remove(path)
+			continue
+		}
+		if record.agentPid != os.Getpid() && isProcess(record.agentPid, record.agentStarted) {
+			continue // Owned by another running agent
+		}
+		if isProcess(record.pgid, record.pgidStarted) {
+			log.Printf("[McpTool] Killing orphaned MCP server process group %d (agent %d is gone)", record.pgid, record.agentPid)
+			killProcessGroup(record.pgid)
+		}
+		os.Remove(path)
+	}
+}
@@ -0,0 +1,43 @@
+//go:build !unix
+
+package tools
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+)
+
+// configureProcessGroup is a no-op where process groups are not available;
+// cancellation then only kills the server process itself.
+func configureProcessGroup(cmd *exec.Cmd) {}
+
+func killProcessGroup(pgid int) error {
+	process, err := os.FindProcess(pgid)
+	if err != nil {
+		return err
+	}
+	return process.Kill()
+}
+
+// processAlive conservatively assumes the process exists.
+func processAlive(pid int) bool {
+	return pid > 0
+}
+
+// processStartTime is not available, so no MCP process is recorded.
+func processStartTime(pid int) (string, error) {
+	return "", errors.New("process start times are not available")
+}
+
+// checkPrivateDir only checks that dir is a directory.
+func checkPrivateDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return errors.New(dir + " is not a directory")
+	}
+	return nil
+}
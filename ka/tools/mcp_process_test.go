@@ -0,0 +1,140 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMcpSemaphores_LimitsConcurrentInvocations(t *testing.T) {
+	var slots mcpSemaphores
+	release, err := slots.acquire(context.Background(), "server", 1)
+	if err != nil {
+		t.Fatalf("First acquire failed: %v", err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := slots.acquire(ctx, "server", 1); err == nil {
+		t.Errorf("Expected second acquire to block until the context expired")
+	}
+}
+
+func TestRlimitShellPrefix(t *testing.T) {
+	prefix := rlimitShellPrefix(McpServerConfig{MaxMemoryMB: 256, MaxCPUSeconds: 30})
+	if prefix != "ulimit -v 262144; ulimit -t 30; " {
+		t.Errorf("Unexpected rlimit prefix: %q", prefix)
+	}
+	if prefix := rlimitShellPrefix(McpServerConfig{}); prefix != "" {
+		t.Errorf("Expected no prefix without limits, got %q", prefix)
+	}
+}
+
+func TestReapOrphanedMcpProcesses_RemovesStaleRecords(t *testing.T) {
+	originalDir := mcpPidDir
+	defer func() { mcpPidDir = originalDir }()
+	mcpPidDir = privateTempDir(t)
+
+	// PIDs this large do not exist, so the record is stale.
+	stale := filepath.Join(mcpPidDir, "999999999.pid")
+	if err := os.WriteFile(stale, []byte("999999999 999999998 1 1"), 0o600); err != nil {
+		t.Fatalf("Failed to write pid file: %v", err)
+	}
+
+	ReapOrphanedMcpProcesses()
+
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("Expected stale pid file to be removed, got %v", err)
+	}
+}
+
+func TestReapOrphanedMcpProcesses_KillsOnlyTheRecordedProcess(t *testing.T) {
+	originalDir := mcpPidDir
+	defer func() { mcpPidDir = originalDir }()
+	mcpPidDir = privateTempDir(t)
+
+	cmd := exec.CommandContext(context.Background(), "sleep", "30")
+	configureProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		t.Skipf("cannot start sleep: %v", err)
+	}
+	defer cmd.Process.Kill()
+	started, err := processStartTime(cmd.Process.Pid)
+	if err != nil {
+		t.Skipf("process start times are not available: %v", err)
+	}
+	exited := make(chan struct{})
+	go func() { cmd.Wait(); close(exited) }()
+
+	writeRecord := func(record mcpProcessRecord) {
+		path := filepath.Join(mcpPidDir, "record.pid")
+		if err := os.WriteFile(path, []byte(record.String()), 0o600); err != nil {
+			t.Fatalf("Failed to write pid file: %v", err)
+		}
+	}
+	// A recycled PID: the process running now started at another time
+	writeRecord(mcpProcessRecord{pgid: cmd.Process.Pid, agentPid: 999999999, pgidStarted: "1", agentStarted: "1"})
+	ReapOrphanedMcpProcesses()
+	// Process groups 0 and 1 are never signalled
+	writeRecord(mcpProcessRecord{pgid: 1, agentPid: 999999999, pgidStarted: started, agentStarted: "1"})
+	ReapOrphanedMcpProcesses()
+	select {
+	case <-exited:
+		t.Fatal("Expected a process not matching its record to survive")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	writeRecord(mcpProcessRecord{pgid: cmd.Process.Pid, agentPid: 999999999, pgidStarted: started, agentStarted: "1"})
+	ReapOrphanedMcpProcesses()
+	select {
+	case <-exited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the orphaned process group to be killed")
+	}
+}
+
+func TestReapOrphanedMcpProcesses_IgnoresSharedDirectory(t *testing.T) {
+	originalDir := mcpPidDir
+	defer func() { mcpPidDir = originalDir }()
+	mcpPidDir = t.TempDir()
+	if err := os.Chmod(mcpPidDir, 0o755); err != nil {
+		t.Fatalf("Chmod failed: %v", err)
+	}
+	if checkPrivateDir(mcpPidDir) == nil {
+		t.Skip("directory permissions are not checked on this platform")
+	}
+
+	record := filepath.Join(mcpPidDir, "999999999.pid")
+	if err := os.WriteFile(record, []byte("999999999 999999998 1 1"), 0o644); err != nil {
+		t.Fatalf("Failed to write pid file: %v", err)
+	}
+	ReapOrphanedMcpProcesses()
+	if _, err := os.Stat(record); err != nil {
+		t.Errorf("Expected records in a shared directory to be left alone, got %v", err)
+	}
+	if registerMcpProcess(os.Getpid())(); len(mustReadDir(t, mcpPidDir)) != 1 {
+		t.Error("Expected no record to be written to a shared directory")
+	}
+}
+
+func privateTempDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.Chmod(dir, 0o700); err != nil {
+		t.Fatalf("Chmod failed: %v", err)
+	}
+	return dir
+}
+
+func mustReadDir(t *testing.T, dir string) []os.DirEntry {
+	t.Helper()
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir failed: %v", err)
+	}
+	return entries
+}
@@ -0,0 +1,70 @@
+//go:build unix
+
+package tools
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// configureProcessGroup starts the command in a new process group and makes
+// context cancellation kill the whole group instead of just the leader.
+func configureProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return killProcessGroup(cmd.Process.Pid)
+	}
+}
+
+func killProcessGroup(pgid int) error {
+	return syscall.Kill(-pgid, syscall.SIGKILL)
+}
+
+// processAlive reports whether a process with the given PID exists.
+func processAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// processStartTime returns the start time of a process, in clock ticks since
+// boot, from /proc. It fails where there is no /proc.
+func processStartTime(pid int) (string, error) {
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return "", err
+	}
+	// The command name in parentheses may contain spaces; the start time is
+	// the 20th field after it
+	stat := string(data)
+	fields := strings.Fields(stat[strings.LastIndexByte(stat, ')')+1:])
+	if len(fields) < 20 {
+		return "", fmt.Errorf("unexpected /proc/%d/stat", pid)
+	}
+	return fields[19], nil
+}
+
+// checkPrivateDir checks that dir is owned by the user and not accessible
+// to anyone else.
+func checkPrivateDir(dir string) error {
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && int(stat.Uid) != os.Getuid() {
+		return fmt.Errorf("%s is owned by user %d", dir, stat.Uid)
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		return fmt.Errorf("%s is accessible to other users (mode %s)", dir, info.Mode().Perm())
+	}
+	return nil
+}
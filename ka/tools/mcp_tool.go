@@ -1,6 +1,7 @@
 package tools
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -10,6 +11,7 @@ import (
 	"os/exec"
 	"strings"
 	"sync" // Import sync for WaitGroup
+	"time"
 )
 
 // Define the structure for ToolDefinition to match the schema
@@ -29,11 +31,16 @@ type McpServerConfig struct {
 	Env           map[string]string `json:"env"`
 	Tools         []ToolDefinition `json:"tools"`     // Added tools field
 	Resources     []string         `json:"resources"` // Added resources field
+	// Resource limits; zero means unlimited. Timeout above is in seconds.
+	MaxConcurrent int `json:"maxConcurrent,omitempty"` // Concurrent invocations of this server
+	MaxMemoryMB   int `json:"maxMemoryMB,omitempty"`   // Virtual memory limit (unix only)
+	MaxCPUSeconds int `json:"maxCpuSeconds,omitempty"` // CPU time limit (unix only)
 }
 
 // McpTool is a Tool implementation for interacting with MCP servers via stdio.
 type McpTool struct{
 	Configs map[string]McpServerConfig // Add field to store configurations (made public)
+	slots   mcpSemaphores              // Per-server concurrency limits
 }
 
 // SetConfigs updates the MCP server configurations for the McpTool.
@@ -114,6 +121,9 @@ func (t *McpTool) Execute(ctx context.Context, callDetails FunctionCall) (string
 	}
 
 	var jsonRPCRequestPayload []byte // The JSON-RPC request to send to the MCP server
+	var err error
+	// Ask the server to send progress notifications for this request.
+	meta := map[string]interface{}{"progressToken": "ka-" + serverName}
 
 	if toolNameRaw, ok := actionPayload["tool_name"]; ok {
 		// It's a tool usage request
@@ -129,12 +139,13 @@ func (t *McpTool) Execute(ctx context.Context, callDetails FunctionCall) (string
 		}
 
 		// Construct the JSON-RPC request for tool usage
-		jsonRPCRequestPayload, err := json.Marshal(map[string]interface{}{
+		jsonRPCRequestPayload, err = json.Marshal(map[string]interface{}{
 			"jsonrpc": "2.0",
 			"method":  "use_tool", // Standard MCP method for tool usage
 			"params": map[string]interface{}{
 				"tool_name": toolName,
 				"arguments": json.RawMessage(argumentsRaw), // Keep arguments as raw JSON
+				"_meta":     meta,
 			},
 			"id": "tool-call-id", // TODO: Generate a unique ID or use task/message ID
 		})
@@ -153,11 +164,12 @@ func (t *McpTool) Execute(ctx context.Context, callDetails FunctionCall) (string
 		}
 
 		// Construct the JSON-RPC request for resource access
-		jsonRPCRequestPayload, err := json.Marshal(map[string]interface{}{
+		jsonRPCRequestPayload, err = json.Marshal(map[string]interface{}{
 			"jsonrpc": "2.0",
 			"method":  "access_resource", // Standard MCP method for resource access
 			"params": map[string]interface{}{
-				"uri": resourceURI,
+				"uri":   resourceURI,
+				"_meta": meta,
 			},
 			"id": "resource-access-id", // TODO: Generate a unique ID or use task/message ID
 		})
@@ -174,7 +186,18 @@ func (t *McpTool) Execute(ctx context.Context, callDetails FunctionCall) (string
 
 
 	// --- 4. Execute the MCP Server Process and Communicate via Stdio ---
-	cmd := exec.CommandContext(ctx, serverConfig.Command, serverConfig.Args...)
+	release, err := t.slots.acquire(ctx, serverName, serverConfig.MaxConcurrent)
+	if err != nil {
+		return "", err
+	}
+	defer release()
+
+	if serverConfig.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(serverConfig.Timeout)*time.Second)
+		defer cancel()
+	}
+	cmd := newMcpServerCommand(ctx, serverConfig)
 
 	// Set environment variables
 	cmd.Env = os.Environ() // Inherit current environment
@@ -201,6 +224,8 @@ func (t *McpTool) Execute(ctx context.Context, callDetails FunctionCall) (string
 		return "", fmt.Errorf("failed to start MCP server process '%s': %w", serverConfig.Command, err)
 	}
 	log.Printf("[McpTool] MCP server process started successfully (PID: %d)", cmd.Process.Pid)
+	unregister := registerMcpProcess(cmd.Process.Pid)
+	defer unregister()
 
 
 	// Use a WaitGroup to wait for both writing to stdin and reading from stdout to finish
@@ -230,13 +255,27 @@ func (t *McpTool) Execute(ctx context.Context, callDetails FunctionCall) (string
 		defer stdoutPipe.Close() // Close stdout pipe when done reading
 
 		log.Printf("[McpTool] Reading from MCP server stdout...")
-		// Read until EOF or context cancellation
-		readBytes, readErr := io.ReadAll(stdoutPipe)
+		// Read until EOF or context cancellation. Notifications are forwarded
+		// as progress; everything else is the response.
+		scanner := bufio.NewScanner(stdoutPipe)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if progress, ok := parseMcpNotification(line); ok {
+				if progress.Kind != "" {
+					progress.Server = serverName
+					ReportProgress(ctx, progress)
+				}
+				continue
+			}
+			stdoutContent.WriteString(line)
+			stdoutContent.WriteString("\n")
+		}
+		readErr = scanner.Err()
 		if readErr != nil {
 			log.Printf("[McpTool] Error reading from MCP server stdout: %v", readErr)
 		} else {
-			stdoutContent.Write(readBytes)
-			log.Printf("[McpTool] Finished reading from MCP server stdout. Read %d bytes.", len(readBytes))
+			log.Printf("[McpTool] Finished reading from MCP server stdout. Read %d bytes.", stdoutContent.Len())
 		}
 	}()
 
@@ -272,3 +311,42 @@ func (t *McpTool) Execute(ctx context.Context, callDetails FunctionCall) (string
 	// Return the content read from stdout
 	return stdoutContent.String(), nil
 }
+
+// parseMcpNotification recognises JSON-RPC notifications (a method and no id)
+// on the server's stdout. Progress and logging notifications are converted to
+// ToolProgress; other notifications are returned with an empty Kind.
+func parseMcpNotification(line string) (ToolProgress, bool) {
+	var notification struct {
+		ID     json.RawMessage `json:"id"`
+		Method string          `json:"method"`
+		Params struct {
+			Progress float64         `json:"progress"`
+			Total    float64         `json:"total"`
+			Message  string          `json:"message"`
+			Level    string          `json:"level"`
+			Data     json.RawMessage `json:"data"`
+		} `json:"params"`
+	}
+	if err := json.Unmarshal([]byte(line), &notification); err != nil || notification.Method == "" || len(notification.ID) > 0 {
+		return ToolProgress{}, false
+	}
+	progress := ToolProgress{Tool: "mcp", Message: notification.Params.Message}
+	switch notification.Method {
+	case "notifications/progress":
+		progress.Kind = "progress"
+		progress.Progress = notification.Params.Progress
+		progress.Total = notification.Params.Total
+	case "notifications/message":
+		progress.Kind = "log"
+		progress.Level = notification.Params.Level
+		var text string
+		if json.Unmarshal(notification.Params.Data, &text) == nil {
+			progress.Message = text
+		} else {
+			progress.Message = string(notification.Params.Data)
+		}
+	default:
+		return ToolProgress{}, true
+	}
+	return progress, true
+}
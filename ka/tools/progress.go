@@ -0,0 +1,41 @@
+package tools
+
+import (
+	"context"
+	"time"
+)
+
+// ToolProgress is a progress or log notification emitted by a tool while it runs.
+type ToolProgress struct {
+	Tool      string    `json:"tool"`
+	Server    string    `json:"server,omitempty"` // MCP server name, if any
+	Kind      string    `json:"kind"`             // "progress" or "log"
+	Progress  float64   `json:"progress,omitempty"`
+	Total     float64   `json:"total,omitempty"`
+	Level     string    `json:"level,omitempty"`
+	Message   string    `json:"message,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// ProgressReporter receives progress notifications from a running tool.
+// It may be called from a different goroutine than the one running Execute.
+type ProgressReporter func(ToolProgress)
+
+type progressReporterKey struct{}
+
+// WithProgressReporter returns a context that delivers tool progress to reporter.
+func WithProgressReporter(ctx context.Context, reporter ProgressReporter) context.Context {
+	return context.WithValue(ctx, progressReporterKey{}, reporter)
+}
+
+// ReportProgress delivers progress to the reporter in ctx, if there is one.
+func ReportProgress(ctx context.Context, progress ToolProgress) {
+	reporter, ok := ctx.Value(progressReporterKey{}).(ProgressReporter)
+	if !ok || reporter == nil {
+		return
+	}
+	if progress.Timestamp.IsZero() {
+		progress.Timestamp = time.Now().UTC()
+	}
+	reporter(progress)
+}
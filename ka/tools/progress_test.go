@@ -0,0 +1,33 @@
+package tools
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParseMcpNotification_Progress(t *testing.T) {
+	progress, ok := parseMcpNotification(`{"jsonrpc":"2.0","method":"notifications/progress","params":{"progressToken":"ka-x","progress":3,"total":10,"message":"indexing"}}`)
+	if !ok || progress.Kind != "progress" {
+		t.Fatalf("Expected progress notification, got %+v (ok=%v)", progress, ok)
+	}
+	if progress.Progress != 3 || progress.Total != 10 || progress.Message != "indexing" {
+		t.Errorf("Unexpected progress fields: %+v", progress)
+	}
+}
+
+func TestParseMcpNotification_ResponseIsNotANotification(t *testing.T) {
+	if _, ok := parseMcpNotification(`{"jsonrpc":"2.0","id":"tool-call-id","result":{}}`); ok {
+		t.Errorf("Expected a response not to be treated as a notification")
+	}
+}
+
+func TestReportProgress_DeliversToReporter(t *testing.T) {
+	var received []ToolProgress
+	ctx := WithProgressReporter(context.Background(), func(p ToolProgress) { received = append(received, p) })
+	ReportProgress(ctx, ToolProgress{Tool: "mcp", Kind: "log", Message: "hello"})
+	ReportProgress(context.Background(), ToolProgress{Tool: "mcp", Kind: "log"}) // No reporter: ignored
+
+	if len(received) != 1 || received[0].Timestamp.IsZero() {
+		t.Errorf("Expected one timestamped progress event, got %+v", received)
+	}
+}
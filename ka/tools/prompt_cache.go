@@ -0,0 +1,69 @@
+package tools
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"sync"
+)
+
+// PromptTemplateVersion identifies the text ComposeSystemPrompt wraps around
+// tool definitions. Bump it when that text changes, so cached prompts are
+// composed again.
+const PromptTemplateVersion = "1"
+
+// ComposedPrompt is a system prompt from a PromptCache with the ETag of its text.
+type ComposedPrompt struct {
+	Prompt string
+	ETag   string
+}
+
+// PromptCache keeps composed system prompts keyed by tool set, MCP server
+// configurations and template version, so repeated requests for the same
+// selection skip collecting the tool definitions.
+type PromptCache struct {
+	mu      sync.Mutex
+	entries map[string]ComposedPrompt
+}
+
+// NewPromptCache creates an empty cache.
+func NewPromptCache() *PromptCache {
+	return &PromptCache{entries: make(map[string]ComposedPrompt)}
+}
+
+// Compose returns the cached prompt for the selection, composing it on a miss.
+func (c *PromptCache) Compose(selectedToolNames []string, selectedMcpServers []McpServerConfig, availableTools map[string]Tool) ComposedPrompt {
+	key := promptCacheKey(selectedToolNames, selectedMcpServers)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cached, ok := c.entries[key]; ok {
+		return cached
+	}
+	prompt := ComposeSystemPrompt(selectedToolNames, selectedMcpServers, availableTools)
+	composed := ComposedPrompt{Prompt: prompt, ETag: ContentETag([]byte(prompt))}
+	c.entries[key] = composed
+	return composed
+}
+
+// Invalidate drops all cached prompts, e.g. after the MCP configuration changed.
+func (c *PromptCache) Invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]ComposedPrompt)
+}
+
+// promptCacheKey fingerprints a selection. Tool order is kept, as it is the
+// order of the definitions in the prompt; MCP servers count with their whole
+// configuration, so changed tool lists miss the cache.
+func promptCacheKey(selectedToolNames []string, selectedMcpServers []McpServerConfig) string {
+	servers, _ := json.Marshal(selectedMcpServers)
+	sum := sha256.Sum256([]byte(PromptTemplateVersion + "\x00" + strings.Join(selectedToolNames, ",") + "\x00" + string(servers)))
+	return hex.EncodeToString(sum[:])
+}
+
+// ContentETag returns a strong ETag for content.
+func ContentETag(content []byte) string {
+	sum := sha256.Sum256(content)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
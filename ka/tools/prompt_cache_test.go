@@ -0,0 +1,30 @@
+package tools
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPromptCache(t *testing.T) {
+	availableTools := map[string]Tool{"read_file": &ReadFileTool{}, "list_files": &ListFilesTool{}, "mcp": &McpTool{}}
+	cache := NewPromptCache()
+
+	first := cache.Compose([]string{"read_file"}, nil, availableTools)
+	assert.Contains(t, first.Prompt, `<tool id="read_file">`)
+	assert.Equal(t, ContentETag([]byte(first.Prompt)), first.ETag)
+	assert.Equal(t, first, cache.Compose([]string{"read_file"}, nil, availableTools))
+
+	other := cache.Compose([]string{"read_file", "list_files"}, nil, availableTools)
+	assert.NotEqual(t, first.ETag, other.ETag)
+
+	server := McpServerConfig{Name: "docs", Tools: []ToolDefinition{{Name: "search"}}}
+	withServer := cache.Compose([]string{"mcp"}, []McpServerConfig{server}, availableTools)
+	server.Tools = append(server.Tools, ToolDefinition{Name: "fetch"})
+	changedServer := cache.Compose([]string{"mcp"}, []McpServerConfig{server}, availableTools)
+	assert.NotContains(t, withServer.Prompt, "- fetch")
+	assert.Contains(t, changedServer.Prompt, "- fetch")
+
+	cache.Invalidate()
+	assert.Empty(t, cache.entries)
+}
@@ -0,0 +1,157 @@
+package tools
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// toolSectionHeader matches the header ComposeSystemPrompt writes before
+// every tool definition.
+var toolSectionHeader = regexp.MustCompile(`(?m)^## Tool "([^"]+)"$`)
+
+// toolSectionsEnd is the block following the tool definitions.
+const toolSectionsEnd = "\n\nPLANNING\n===="
+
+// pruningStopWords are ignored when matching a request against tools.
+var pruningStopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "with": true, "this": true, "that": true, "from": true,
+	"into": true, "can": true, "are": true, "you": true, "your": true, "use": true, "all": true,
+	"tool": true, "not": true, "but": true, "its": true, "has": true, "have": true, "will": true, "please": true,
+}
+
+// PrunedPrompt is a system prompt reduced to the tools relevant to a request.
+type PrunedPrompt struct {
+	Prompt  string
+	Kept    []string // Tools whose definitions stayed, in prompt order
+	Omitted []string // Tools only listed by name
+}
+
+type toolSection struct {
+	name        string
+	start, end  int
+	description string
+	score       int
+}
+
+// PruneToolDefinitions keeps the definitions of the topK tools of a composed
+// system prompt that best match the request by keyword overlap with their
+// names and descriptions. The other tools are listed with their description
+// only, so the model still knows they exist; describe_tool, if present, is
+// always kept as the way to their definitions. Prompts with at most topK tool
+// definitions, or not composed by ComposeSystemPrompt, are returned unchanged.
+func PruneToolDefinitions(prompt, request string, topK int) PrunedPrompt {
+	sections := toolSections(prompt)
+	unchanged := func() PrunedPrompt {
+		pruned := PrunedPrompt{Prompt: prompt}
+		for _, s := range sections {
+			pruned.Kept = append(pruned.Kept, s.name)
+		}
+		return pruned
+	}
+	if topK <= 0 || len(sections) <= topK {
+		return unchanged()
+	}
+
+	words := keywords(request)
+	for i := range sections {
+		s := &sections[i]
+		if s.name == DescribeToolName {
+			s.score = -1 // Kept anyway, see below
+			continue
+		}
+		for word := range keywords(strings.ReplaceAll(s.name, "_", " ")) {
+			if words[word] {
+				s.score += 2 // A match on the name counts more than one on the description
+			}
+		}
+		for word := range keywords(prompt[s.start:s.end]) {
+			if words[word] {
+				s.score++
+			}
+		}
+	}
+	ranked := make([]int, len(sections))
+	for i := range ranked {
+		ranked[i] = i
+	}
+	sort.SliceStable(ranked, func(a, b int) bool { return sections[ranked[a]].score > sections[ranked[b]].score })
+	keep := make(map[int]bool, topK+1)
+	for _, i := range ranked[:topK] {
+		keep[i] = true
+	}
+	howToUse := "If you need one, invoke it in the same <tool id=\"name\">{...}</tool> format; a malformed call returns the arguments it expects."
+	for i, s := range sections {
+		if s.name == DescribeToolName {
+			keep[i] = true // The way to the other definitions
+			howToUse = "Call describe_tool with the name of one to get its full definition before using it."
+		}
+	}
+	if len(keep) == len(sections) {
+		return unchanged()
+	}
+
+	var pruned PrunedPrompt
+	var builder, omitted strings.Builder
+	builder.WriteString(prompt[:sections[0].start])
+	for i, s := range sections {
+		if keep[i] {
+			builder.WriteString(prompt[s.start:s.end])
+			pruned.Kept = append(pruned.Kept, s.name)
+			continue
+		}
+		fmt.Fprintf(&omitted, "\n- %s: %s", s.name, s.description)
+		pruned.Omitted = append(pruned.Omitted, s.name)
+	}
+	fmt.Fprintf(&builder, "\n\n## Other tools\nThese tools are available too, but their definitions were left out to save context. %s%s", howToUse, omitted.String())
+	builder.WriteString(prompt[sections[len(sections)-1].end:])
+	pruned.Prompt = builder.String()
+	return pruned
+}
+
+// toolSections locates the tool definitions of a composed prompt. A section
+// runs from the blank lines before its header to the next section.
+func toolSections(prompt string) []toolSection {
+	matches := toolSectionHeader.FindAllStringSubmatchIndex(prompt, -1)
+	if len(matches) == 0 {
+		return nil
+	}
+	end := strings.Index(prompt[matches[len(matches)-1][1]:], toolSectionsEnd)
+	if end < 0 {
+		return nil // Not a composed prompt; pruning could cut into other text
+	}
+	end += matches[len(matches)-1][1]
+
+	sections := make([]toolSection, len(matches))
+	for i, m := range matches {
+		start := m[0]
+		for start > 0 && prompt[start-1] == '\n' {
+			start--
+		}
+		sections[i] = toolSection{name: prompt[m[2]:m[3]], start: start}
+		if i > 0 {
+			sections[i-1].end = start
+		}
+		if body := strings.SplitN(prompt[m[1]:], "\n", 3); len(body) > 1 {
+			sections[i].description = strings.TrimSpace(body[1])
+		}
+	}
+	sections[len(sections)-1].end = end
+	return sections
+}
+
+// keywords returns the lower-cased words of text worth matching.
+func keywords(text string) map[string]bool {
+	words := make(map[string]bool)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		word = strings.TrimSuffix(word, "s") // Crude plural folding: "files" matches "file"
+		if len(word) >= 3 && !pruningStopWords[word] {
+			words[word] = true
+		}
+	}
+	return words
+}
@@ -0,0 +1,61 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPruneToolDefinitionsKeepsRelevantTools(t *testing.T) {
+	availableTools := map[string]Tool{
+		"read_file":       &MockTool{name: "read_file", description: "Reads the contents of a file.", xmlDefinition: "<tool id=\"read_file\">{\"path\": \"...\"}</tool>"},
+		"execute_command": &MockTool{name: "execute_command", description: "Runs a shell command.", xmlDefinition: "<tool id=\"execute_command\">{\"command\": \"...\"}</tool>"},
+		"get_time":        &MockTool{name: "get_time", description: "Returns the current time.", xmlDefinition: "<tool id=\"get_time\">{}</tool>"},
+	}
+	for _, tool := range availableTools {
+		tool.(*MockTool).xmlDefinition += strings.Repeat("\nAn example call with its arguments.", 20) // Real definitions are long
+	}
+	prompt := ComposeSystemPrompt([]string{"execute_command", "get_time", "read_file"}, nil, availableTools)
+
+	pruned := PruneToolDefinitions(prompt, "Please read the file README.md and summarize it", 1)
+	if len(pruned.Kept) != 1 || pruned.Kept[0] != "read_file" {
+		t.Fatalf("expected only read_file to be kept, got %v", pruned.Kept)
+	}
+	if strings.Contains(pruned.Prompt, `<tool id="execute_command">`) || !strings.Contains(pruned.Prompt, `<tool id="read_file">`) {
+		t.Errorf("expected only the read_file definition in the prompt")
+	}
+	if !strings.Contains(pruned.Prompt, "- execute_command: Runs a shell command.") || !strings.Contains(pruned.Prompt, "SYSTEM INFORMATION") {
+		t.Errorf("expected the other tools to be listed and the rest of the prompt kept, got %q", pruned.Prompt)
+	}
+	if len(pruned.Prompt) >= len(prompt) {
+		t.Errorf("expected a shorter prompt, got %d bytes from %d", len(pruned.Prompt), len(prompt))
+	}
+}
+
+func TestPruneToolDefinitionsLeavesOtherPromptsAlone(t *testing.T) {
+	custom := "You are a pirate.\n\n## Tool \"read_file\"\nReads files."
+	if pruned := PruneToolDefinitions(custom, "read a file", 1); pruned.Prompt != custom {
+		t.Errorf("expected a prompt not composed by ComposeSystemPrompt to stay unchanged, got %q", pruned.Prompt)
+	}
+	availableTools := map[string]Tool{"get_time": &MockTool{name: "get_time", description: "Returns the time."}}
+	prompt := ComposeSystemPrompt([]string{"get_time"}, nil, availableTools)
+	if pruned := PruneToolDefinitions(prompt, "anything", 3); pruned.Prompt != prompt || len(pruned.Kept) != 1 {
+		t.Errorf("expected no pruning with fewer tools than topK, got %+v", pruned.Kept)
+	}
+}
+
+func TestPruneToolDefinitionsKeepsDescribeTool(t *testing.T) {
+	availableTools := map[string]Tool{
+		"read_file": &MockTool{name: "read_file", description: "Reads the contents of a file."},
+		"get_time":  &MockTool{name: "get_time", description: "Returns the current time."},
+	}
+	availableTools[DescribeToolName] = NewDescribeTool(availableTools)
+	prompt := ComposeSystemPrompt([]string{DescribeToolName, "get_time", "read_file"}, nil, availableTools)
+
+	pruned := PruneToolDefinitions(prompt, "what time is it", 1)
+	if len(pruned.Kept) != 2 || pruned.Kept[0] != DescribeToolName || pruned.Kept[1] != "get_time" {
+		t.Fatalf("expected describe_tool and get_time to be kept, got %v", pruned.Kept)
+	}
+	if !strings.Contains(pruned.Prompt, "Call describe_tool") {
+		t.Errorf("expected the note to point to describe_tool, got %q", pruned.Prompt)
+	}
+}
@@ -15,6 +15,9 @@ type ReadFileParams struct {
 	Path     string `json:"path"`
 	FromLine int    `json:"from_line"`
 	ToLine   *int   `json:"to_line"`
+	// SyntaxContext prefixes the output with the detected language and, when the
+	// range starts inside a function or type, with its declaration line.
+	SyntaxContext bool `json:"syntax_context,omitempty"`
 }
 
 // ReadFileTool implements the Tool interface for reading files.
@@ -29,7 +32,7 @@ func (t *ReadFileTool) GetDescription() string {
 }
 
 func (t *ReadFileTool) GetXMLDefinition() string {
-	return `<tool id="read_file">{"path": "path/to/file", "from_line": 0, "to_line": 200 (optional, omit or null to read entire file from from_line)}</tool>`
+	return `<tool id="read_file">{"path": "path/to/file", "from_line": 0, "to_line": 200 (optional, omit or null to read entire file from from_line), "syntax_context": true (optional)}</tool>`
 }
 
 func (t *ReadFileTool) Execute(ctx context.Context, callDetails FunctionCall) (string, error) {
@@ -55,6 +58,9 @@ func (t *ReadFileTool) Execute(ctx context.Context, callDetails FunctionCall) (s
 	reader := bufio.NewReader(file)
 	var lines []string
 	currentLine := 0
+	lang := ""
+	lastDeclaration := -1
+	var lastDeclarationText string
 
 	for {
 		line, err := reader.ReadString('\n')
@@ -62,6 +68,18 @@ func (t *ReadFileTool) Execute(ctx context.Context, callDetails FunctionCall) (s
 			return "", fmt.Errorf("failed to read line from file %q: %w", params.Path, err)
 		}
 
+		if params.SyntaxContext {
+			if currentLine == 0 {
+				lang = DetectLanguage(params.Path, line)
+			}
+			if currentLine < params.FromLine {
+				if pattern, ok := declarationPatterns[lang]; ok && pattern.MatchString(line) {
+					lastDeclaration = currentLine
+					lastDeclarationText = strings.TrimRight(line, "\r\n")
+				}
+			}
+		}
+
 		if currentLine >= params.FromLine {
 			if params.ToLine == nil || currentLine < *params.ToLine {
 				lines = append(lines, line)
@@ -91,5 +109,12 @@ func (t *ReadFileTool) Execute(ctx context.Context, callDetails FunctionCall) (s
 		// This also correctly handles cases where fewer lines were read than expectedLinesCount (e.g. EOF).
 	}
 
+	if params.SyntaxContext && lang != "" {
+		header := fmt.Sprintf("[language: %s]\n", lang)
+		if lastDeclaration >= 0 {
+			header += fmt.Sprintf("[enclosing declaration at line %d: %s]\n", lastDeclaration, lastDeclarationText)
+		}
+		return header + strings.Join(lines, ""), nil
+	}
 	return strings.Join(lines, ""), nil
 }
@@ -0,0 +1,511 @@
+package tools
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	defaultLogMaxLines  = 200
+	defaultLogMaxBytes  = 32 << 10
+	maxLogChunkBytes    = 256 << 10
+	defaultLogScanBytes = 256 << 20
+	maxLogLineBytes     = 2000
+	logSeekWindow       = 64 << 10
+)
+
+var (
+	// Timestamps of common log formats: ISO 8601 and RFC 3339, Go's log
+	// package and the common log format of web servers.
+	isoLogTimePattern = regexp.MustCompile(`(\d{4}-\d{2}-\d{2})[T ](\d{2}:\d{2}:\d{2})(\.\d+)?(Z|[+-]\d{2}:?\d{2})?`)
+	goLogTimePattern  = regexp.MustCompile(`(\d{4}/\d{2}/\d{2}) (\d{2}:\d{2}:\d{2})(\.\d+)?`)
+	clfLogTimePattern = regexp.MustCompile(`(\d{2}/[A-Z][a-z]{2}/\d{4}):(\d{2}:\d{2}:\d{2}) ([+-]\d{4})`)
+
+	logLevelKeyPattern  = regexp.MustCompile(`(?i)\b(?:level|lvl|severity)["']?\s*[=:]\s*["']?([a-z]+)`)
+	logLevelWordPattern = regexp.MustCompile(`(?i)\b(trace|debug|info|notice|warn|warning|error|err|fatal|critical|crit|panic)\b`)
+)
+
+// logLevels maps the spellings of log levels to the names read_log reports.
+var logLevels = map[string]string{
+	"trace": "TRACE", "debug": "DEBUG", "info": "INFO", "notice": "INFO",
+	"warn": "WARN", "warning": "WARN", "error": "ERROR", "err": "ERROR",
+	"fatal": "FATAL", "critical": "FATAL", "crit": "FATAL", "panic": "FATAL",
+}
+
+// ReadLogArgs are the arguments of the read_log tool.
+type ReadLogArgs struct {
+	Path     string   `json:"path"`
+	Offset   int64    `json:"offset,omitempty"`    // Byte offset to continue at, e.g. the next offset of a previous call
+	Tail     int      `json:"tail,omitempty"`      // Read the last N lines instead
+	From     string   `json:"from,omitempty"`      // Only entries at or after this time
+	To       string   `json:"to,omitempty"`        // Only entries before this time
+	Levels   []string `json:"levels,omitempty"`    // Only entries of these levels, e.g. ["error", "warn"]
+	Pattern  string   `json:"pattern,omitempty"`   // Regular expression lines must match
+	MaxLines int      `json:"max_lines,omitempty"` // Lines returned per call; 200 by default
+	MaxBytes int      `json:"max_bytes,omitempty"` // Bytes of lines returned per call; 32 KB by default
+}
+
+// ReadLogTool reads large log files in chunks: it pages through them by
+// byte offset, filters by time range, level and pattern, and summarizes the
+// scanned range, so multi-GB logs can be investigated without loading them.
+// Entries are the lines with a timestamp; lines without one, such as stack
+// traces, belong to the entry before them.
+type ReadLogTool struct {
+	MaxScanBytes int64 // Bytes one call reads looking for matching lines; 0 is 256 MB
+}
+
+func (t *ReadLogTool) GetName() string {
+	return "read_log"
+}
+
+func (t *ReadLogTool) GetDescription() string {
+	return "Reads a large log file in chunks. Filters by time range (from, to), levels (e.g. error, warn) and a regular expression pattern; tail reads the last N lines. A time range is found by binary search, so it is fast in multi-GB files. Returns the matching lines, statistics of the scanned range (lines per level, time span, skipped binary lines) and the byte offset to continue at. Use it instead of read_file for logs."
+}
+
+func (t *ReadLogTool) GetXMLDefinition() string {
+	return `<tool id="read_log">{"path": "/var/log/app.log", "from": "2024-05-01T10:00:00Z" (optional), "to": "2024-05-01T11:00:00Z" (optional), "levels": ["error", "warn"] (optional), "pattern": "timeout|refused" (optional), "tail": 100 (optional), "offset": 0 (optional, the next offset of a previous call), "max_lines": 200 (optional)}</tool>`
+}
+
+// logScan is the state of one read_log call.
+type logScan struct {
+	args       ReadLogArgs
+	from, to   time.Time
+	levels     map[string]bool
+	pattern    *regexp.Regexp
+	out        strings.Builder
+	shown      int
+	scanned    int
+	binary     int
+	perLevel   map[string]int
+	first      time.Time
+	last       time.Time
+	entryTime  time.Time
+	entryLevel string
+}
+
+func (t *ReadLogTool) Execute(ctx context.Context, callDetails FunctionCall) (string, error) {
+	var args ReadLogArgs
+	if err := json.Unmarshal([]byte(callDetails.Content), &args); err != nil {
+		return "", fmt.Errorf("failed to parse arguments JSON for read_log: %w. Content: %s", err, callDetails.Content)
+	}
+	if args.Path == "" {
+		return "", fmt.Errorf("missing 'path' argument for read_log")
+	}
+	scan := &logScan{args: args, perLevel: make(map[string]int)}
+	var err error
+	if scan.from, err = parseLogTimeArg(args.From); err != nil {
+		return "", fmt.Errorf("invalid 'from' for read_log: %w", err)
+	}
+	if scan.to, err = parseLogTimeArg(args.To); err != nil {
+		return "", fmt.Errorf("invalid 'to' for read_log: %w", err)
+	}
+	if len(args.Levels) > 0 {
+		scan.levels = make(map[string]bool)
+		for _, level := range args.Levels {
+			name, ok := logLevels[strings.ToLower(level)]
+			if !ok {
+				return "", fmt.Errorf("unknown level %q for read_log (expected trace, debug, info, warn, error or fatal)", level)
+			}
+			scan.levels[name] = true
+		}
+	}
+	if args.Pattern != "" {
+		if scan.pattern, err = regexp.Compile(args.Pattern); err != nil {
+			return "", fmt.Errorf("invalid 'pattern' for read_log: %w", err)
+		}
+	}
+	maxLines := args.MaxLines
+	if maxLines <= 0 {
+		maxLines = defaultLogMaxLines
+	}
+	maxBytes := min(args.MaxBytes, maxLogChunkBytes)
+	if maxBytes <= 0 {
+		maxBytes = defaultLogMaxBytes
+	}
+	maxScan := t.MaxScanBytes
+	if maxScan <= 0 {
+		maxScan = defaultLogScanBytes
+	}
+
+	file, err := os.Open(args.Path)
+	if err != nil {
+		return "", fmt.Errorf("failed to open log %q: %w", args.Path, err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return "", fmt.Errorf("failed to stat log %q: %w", args.Path, err)
+	}
+	size := info.Size()
+	if args.Offset < 0 || args.Offset > size {
+		return "", fmt.Errorf("offset %d is outside of %q (%d bytes)", args.Offset, args.Path, size)
+	}
+
+	start := args.Offset
+	switch {
+	case args.Tail > 0:
+		start, err = tailLogOffset(file, size, args.Tail, maxScan)
+	case start == 0 && !scan.from.IsZero():
+		start, err = seekLogTime(file, size, scan.from)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read log %q: %w", args.Path, err)
+	}
+	if start, err = alignLogLine(file, start); err != nil {
+		return "", fmt.Errorf("failed to read log %q: %w", args.Path, err)
+	}
+	if _, err := file.Seek(start, io.SeekStart); err != nil {
+		return "", fmt.Errorf("failed to seek in log %q: %w", args.Path, err)
+	}
+
+	reader := bufio.NewReaderSize(file, 64<<10)
+	pos := start
+	next := int64(-1) // Offset to continue at; -1 when the range was read to its end
+	stop := ""
+	for {
+		if ctx.Err() != nil {
+			next, stop = pos, "the call was cancelled"
+			break
+		}
+		if pos-start >= maxScan {
+			next, stop = pos, fmt.Sprintf("scanned %s without filling the page", formatLogBytes(maxScan))
+			break
+		}
+		line, n, readErr := readLogLine(reader)
+		if n == 0 {
+			break
+		}
+		keep, done := scan.line(line, n)
+		if done {
+			stop = "reached the end of the time range"
+			break
+		}
+		if keep {
+			text := strings.TrimRight(string(line), "\r\n")
+			if n > maxLogLineBytes {
+				text += fmt.Sprintf(" [... line of %d bytes truncated]", n)
+			}
+			if scan.shown >= maxLines || (scan.shown > 0 && scan.out.Len()+len(text)+1 > maxBytes) {
+				next = pos
+				scan.scanned-- // The line is shown by the next call
+				scan.perLevel[scan.entryLevelName()]--
+				break
+			}
+			scan.out.WriteString(text)
+			scan.out.WriteByte('\n')
+			scan.shown++
+		}
+		pos += int64(n)
+		if readErr != nil {
+			break
+		}
+	}
+	return scan.report(args.Path, size, start, pos, next, stop), nil
+}
+
+// line records a line in the statistics and reports whether it passes the
+// filters, and whether the scan is past the time range.
+func (s *logScan) line(line []byte, n int) (keep, done bool) {
+	if !isLogText(line) {
+		s.binary++
+		return false, false
+	}
+	if ts, ok := parseLogTime(line); ok {
+		s.entryTime = ts
+		s.entryLevel = ""
+	}
+	if level := detectLogLevel(line); level != "" {
+		s.entryLevel = level
+	}
+	if !s.entryTime.IsZero() {
+		if !s.to.IsZero() && !s.entryTime.Before(s.to) {
+			return false, true
+		}
+		if !s.from.IsZero() && s.entryTime.Before(s.from) {
+			return false, false
+		}
+	}
+	if s.pattern != nil && !s.pattern.Match(line) {
+		return false, false
+	}
+	// Statistics cover the lines in the time range matching the pattern
+	s.scanned++
+	s.perLevel[s.entryLevelName()]++
+	if !s.entryTime.IsZero() {
+		if s.first.IsZero() {
+			s.first = s.entryTime
+		}
+		s.last = s.entryTime
+	}
+	if s.levels != nil && !s.levels[s.entryLevel] {
+		return false, false
+	}
+	return true, false
+}
+
+func (s *logScan) entryLevelName() string {
+	if s.entryLevel == "" {
+		return "no level"
+	}
+	return s.entryLevel
+}
+
+func (s *logScan) report(path string, size, start, end, next int64, stop string) string {
+	var result strings.Builder
+	fmt.Fprintf(&result, "Log: %s (%s)\n", path, formatLogBytes(size))
+	fmt.Fprintf(&result, "Scanned: bytes %d-%d\n", start, end)
+	var filters []string
+	if s.args.Tail > 0 {
+		filters = append(filters, fmt.Sprintf("last %d lines", s.args.Tail))
+	}
+	if !s.from.IsZero() {
+		filters = append(filters, "from "+s.from.Format(time.RFC3339))
+	}
+	if !s.to.IsZero() {
+		filters = append(filters, "to "+s.to.Format(time.RFC3339))
+	}
+	if len(s.args.Levels) > 0 {
+		filters = append(filters, "levels "+strings.Join(s.args.Levels, ", "))
+	}
+	if s.args.Pattern != "" {
+		filters = append(filters, fmt.Sprintf("pattern %q", s.args.Pattern))
+	}
+	if len(filters) > 0 {
+		fmt.Fprintf(&result, "Filters: %s\n", strings.Join(filters, "; "))
+	}
+
+	var levels []string
+	for level := range s.perLevel {
+		if s.perLevel[level] > 0 {
+			levels = append(levels, level)
+		}
+	}
+	sort.Slice(levels, func(i, j int) bool { return s.perLevel[levels[i]] > s.perLevel[levels[j]] })
+	stats := fmt.Sprintf("%d lines", s.scanned)
+	for _, level := range levels {
+		stats += fmt.Sprintf(", %s %d", level, s.perLevel[level])
+	}
+	if !s.first.IsZero() {
+		stats += fmt.Sprintf("; %s to %s", s.first.Format(time.RFC3339), s.last.Format(time.RFC3339))
+	}
+	if s.binary > 0 {
+		stats += fmt.Sprintf("; %d binary lines skipped", s.binary)
+	}
+	fmt.Fprintf(&result, "Statistics of the scanned range: %s\n", stats)
+	fmt.Fprintf(&result, "Showing %d matching lines\n\n", s.shown)
+	result.WriteString(s.out.String())
+	switch {
+	case next >= 0 && stop != "":
+		fmt.Fprintf(&result, "\n[Stopped: %s. Continue with \"offset\": %d]", stop, next)
+	case next >= 0:
+		fmt.Fprintf(&result, "\n[More lines follow. Continue with \"offset\": %d]", next)
+	case stop != "":
+		fmt.Fprintf(&result, "\n[End: %s]", stop)
+	default:
+		result.WriteString("\n[End of file]")
+	}
+	return result.String()
+}
+
+// readLogLine reads the next line, keeping at most maxLogLineBytes of it.
+// n is the full length of the line.
+func readLogLine(r *bufio.Reader) (line []byte, n int, err error) {
+	for {
+		chunk, err := r.ReadSlice('\n')
+		n += len(chunk)
+		if room := maxLogLineBytes - len(line); room > 0 {
+			line = append(line, chunk[:min(len(chunk), room)]...)
+		}
+		if err != bufio.ErrBufferFull {
+			return line, n, err
+		}
+	}
+}
+
+// isLogText reports whether a line is text, rather than binary data such as
+// a corrupted or compressed section of the file.
+func isLogText(line []byte) bool {
+	if bytes.IndexByte(line, 0) >= 0 {
+		return false
+	}
+	// Allow a character cut in half by the line length limit
+	for i := 0; i < utf8.UTFMax && len(line) > 0 && !utf8.Valid(line); i++ {
+		line = line[:len(line)-1]
+	}
+	return utf8.Valid(line)
+}
+
+// parseLogTime finds the timestamp of a line. Times without a zone are UTC.
+func parseLogTime(line []byte) (time.Time, bool) {
+	if match := isoLogTimePattern.FindSubmatch(line); match != nil {
+		zone := string(match[4])
+		if zone == "" {
+			zone = "Z"
+		} else if zone != "Z" && !strings.Contains(zone, ":") {
+			zone = zone[:3] + ":" + zone[3:]
+		}
+		ts, err := time.Parse(time.RFC3339Nano, fmt.Sprintf("%sT%s%s%s", match[1], match[2], match[3], zone))
+		return ts, err == nil
+	}
+	if match := goLogTimePattern.FindSubmatch(line); match != nil {
+		ts, err := time.Parse("2006/01/02 15:04:05.999999999", fmt.Sprintf("%s %s%s", match[1], match[2], match[3]))
+		return ts, err == nil
+	}
+	if match := clfLogTimePattern.FindSubmatch(line); match != nil {
+		ts, err := time.Parse("02/Jan/2006:15:04:05 -0700", fmt.Sprintf("%s:%s %s", match[1], match[2], match[3]))
+		return ts, err == nil
+	}
+	return time.Time{}, false
+}
+
+// detectLogLevel finds the level of a line: a level key such as level=error
+// anywhere, or else a level word near the start.
+func detectLogLevel(line []byte) string {
+	if match := logLevelKeyPattern.FindSubmatch(line); match != nil {
+		if level, ok := logLevels[strings.ToLower(string(match[1]))]; ok {
+			return level
+		}
+	}
+	if match := logLevelWordPattern.Find(line[:min(len(line), 120)]); match != nil {
+		return logLevels[strings.ToLower(string(match))]
+	}
+	return ""
+}
+
+// parseLogTimeArg parses a from or to argument.
+func parseLogTimeArg(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02T15:04", "2006-01-02 15:04", "2006-01-02"} {
+		if ts, err := time.Parse(layout, value); err == nil {
+			return ts, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a time such as 2024-05-01T10:00:00Z or 2024-05-01 10:00", value)
+}
+
+// seekLogTime finds an offset at or before the first entry at from, by
+// binary search over the timestamps of the file. Logs are written in time
+// order; windows without a timestamp keep the search on the safe side.
+func seekLogTime(file *os.File, size int64, from time.Time) (int64, error) {
+	lo, hi := int64(0), size
+	buf := make([]byte, logSeekWindow)
+	for hi-lo > logSeekWindow {
+		mid := lo + (hi-lo)/2
+		n, err := file.ReadAt(buf, mid)
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		window := buf[:n]
+		if newline := bytes.IndexByte(window, '\n'); newline >= 0 {
+			window = window[newline+1:] // Skip the partial line
+		}
+		found := false
+		for _, line := range bytes.Split(window, []byte("\n")) {
+			if ts, ok := parseLogTime(line); ok {
+				found = true
+				if ts.Before(from) {
+					lo = mid
+				} else {
+					hi = mid
+				}
+				break
+			}
+		}
+		if !found {
+			hi = mid
+		}
+	}
+	return lo, nil
+}
+
+// tailLogOffset returns the offset of the last lines of the file, reading
+// it backwards. It gives up after maxScan bytes.
+func tailLogOffset(file *os.File, size int64, lines int, maxScan int64) (int64, error) {
+	buf := make([]byte, logSeekWindow)
+	end := size
+	if end > 0 {
+		last := make([]byte, 1)
+		if _, err := file.ReadAt(last, end-1); err != nil {
+			return 0, err
+		}
+		if last[0] == '\n' {
+			end-- // The newline ends the last line and does not start another
+		}
+	}
+	count := 0
+	for pos := end; pos > 0 && size-pos < maxScan; {
+		n := int64(len(buf))
+		if pos < n {
+			n = pos
+		}
+		pos -= n
+		if _, err := file.ReadAt(buf[:n], pos); err != nil && err != io.EOF {
+			return 0, err
+		}
+		for i := n - 1; i >= 0; i-- {
+			if buf[i] == '\n' {
+				count++
+				if count == lines {
+					return pos + i + 1, nil
+				}
+			}
+		}
+	}
+	return max(size-maxScan, 0), nil // Fewer lines, or too long ones
+}
+
+// alignLogLine moves an offset inside a line to the start of the next one.
+func alignLogLine(file *os.File, offset int64) (int64, error) {
+	if offset == 0 {
+		return 0, nil
+	}
+	prev := make([]byte, 1)
+	if _, err := file.ReadAt(prev, offset-1); err != nil {
+		return 0, err
+	}
+	if prev[0] == '\n' {
+		return offset, nil
+	}
+	buf := make([]byte, 4096)
+	for pos := offset; ; {
+		n, err := file.ReadAt(buf, pos)
+		if i := bytes.IndexByte(buf[:n], '\n'); i >= 0 {
+			return pos + int64(i) + 1, nil
+		}
+		pos += int64(n)
+		if err == io.EOF {
+			return pos, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+}
+
+// formatLogBytes formats a size for reading, e.g. 1.5 GB.
+func formatLogBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d bytes", n)
+	}
+	value, suffix := float64(n), ""
+	for _, s := range []string{"KB", "MB", "GB", "TB"} {
+		value, suffix = value/unit, s
+		if value < unit {
+			break
+		}
+	}
+	return fmt.Sprintf("%.1f %s", value, suffix)
+}
@@ -0,0 +1,97 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeTestLog(t *testing.T, entries int) string {
+	t.Helper()
+	var b strings.Builder
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < entries; i++ {
+		level := "INFO"
+		if i%10 == 7 {
+			level = "ERROR"
+		}
+		fmt.Fprintf(&b, "%s %s request %d handled\n", start.Add(time.Duration(i)*time.Second).Format(time.RFC3339), level, i)
+		if level == "ERROR" {
+			b.WriteString("    at handler.go:42\n")
+		}
+		if i == 3 {
+			b.WriteString("\x00\x01\x02binary\n")
+		}
+	}
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func readTestLog(t *testing.T, args map[string]interface{}) string {
+	t.Helper()
+	content, _ := json.Marshal(args)
+	result, err := (&ReadLogTool{}).Execute(context.Background(), FunctionCall{Content: string(content)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestReadLogFiltersLevelsAndReportsStatistics(t *testing.T) {
+	path := writeTestLog(t, 20)
+	result := readTestLog(t, map[string]interface{}{"path": path, "levels": []string{"error"}})
+	if !strings.Contains(result, "request 7 handled\n    at handler.go:42\n") || !strings.Contains(result, "request 17 handled") || strings.Contains(result, "request 8 ") {
+		t.Errorf("expected the error entries with their continuation lines only:\n%s", result)
+	}
+	if !strings.Contains(result, "Statistics of the scanned range: 22 lines, INFO 18, ERROR 4") || !strings.Contains(result, "1 binary lines skipped") {
+		t.Errorf("unexpected statistics:\n%s", result)
+	}
+	if !strings.HasSuffix(result, "[End of file]") {
+		t.Errorf("expected the whole file to be read:\n%s", result)
+	}
+}
+
+func TestReadLogSeeksTimeRangeAndPaginates(t *testing.T) {
+	path := writeTestLog(t, 20000) // Large enough for the binary search
+	result := readTestLog(t, map[string]interface{}{"path": path, "from": "2024-05-01T04:00:00Z", "to": "2024-05-01T04:00:05Z", "max_lines": 3})
+	scanned := regexp.MustCompile(`Scanned: bytes (\d+)-`).FindStringSubmatch(result)
+	if scanned == nil {
+		t.Fatalf("missing scanned range:\n%s", result)
+	}
+	if start, _ := strconv.Atoi(scanned[1]); start == 0 {
+		t.Errorf("expected the start to be found by seeking, not by reading from the beginning")
+	}
+	if !strings.Contains(result, "request 14400 handled") || strings.Contains(result, "request 14399 ") || strings.Contains(result, "request 14403 ") {
+		t.Errorf("expected the first three entries of the range:\n%s", result)
+	}
+	next := regexp.MustCompile(`"offset": (\d+)`).FindStringSubmatch(result)
+	if next == nil {
+		t.Fatalf("expected an offset to continue at:\n%s", result)
+	}
+	offset, _ := strconv.Atoi(next[1])
+	rest := readTestLog(t, map[string]interface{}{"path": path, "offset": offset, "to": "2024-05-01T04:00:05Z"})
+	if !strings.HasPrefix(rest[strings.Index(rest, "\n\n")+2:], "2024-05-01T04:00:03Z INFO request 14403 handled") || strings.Contains(rest, "request 14405 ") || !strings.Contains(rest, "[End: reached the end of the time range]") {
+		t.Errorf("expected the second page to continue the range:\n%s", rest)
+	}
+}
+
+func TestReadLogTail(t *testing.T) {
+	path := writeTestLog(t, 50)
+	result := readTestLog(t, map[string]interface{}{"path": path, "tail": 3})
+	if !strings.Contains(result, "Showing 3 matching lines") || !strings.Contains(result, "\n\n    at handler.go:42\n") || !strings.Contains(result, "request 49 handled") {
+		t.Errorf("expected the last three lines:\n%s", result)
+	}
+	if strings.Contains(result, "request 47 ") {
+		t.Errorf("expected earlier lines to be left out:\n%s", result)
+	}
+}
@@ -0,0 +1,17 @@
+package tools
+
+import "context"
+
+type readOnlyKey struct{}
+
+// WithReadOnly returns a context in which tools that can write to external
+// systems, such as sql_query on a read-write connection, only read.
+func WithReadOnly(ctx context.Context) context.Context {
+	return context.WithValue(ctx, readOnlyKey{}, true)
+}
+
+// readOnlyFrom reports whether the context was marked with WithReadOnly.
+func readOnlyFrom(ctx context.Context) bool {
+	readOnly, _ := ctx.Value(readOnlyKey{}).(bool)
+	return readOnly
+}
@@ -7,10 +7,18 @@ func GetAllTools() []Tool {
 		&GetTimeTool{},
 		&ReadFileTool{},
 		&WriteToFileTool{},
+		&ApplyDiffTool{},
 		&SearchFilesTool{},
 		&AskFollowupQuestionTool{},
+		&WaitForEventTool{},
 		&AddTaskTool{},
 		&McpTool{},
 		&ExecuteCommandTool{},
+		&FindSymbolTool{},
+		&TabularAnalyzeTool{},
+		&FetchURLTool{},
+		&ReadLogTool{},
+		&SaveArtifactTool{},
+		&RenderDiagramTool{},
 	}
 }
@@ -0,0 +1,25 @@
+package tools
+
+import "context"
+
+// RemoteTaskLinker records that the task a tool runs for delegated work to
+// the task remoteTaskID of the agent at agentURL.
+type RemoteTaskLinker func(agentURL, remoteTaskID string) error
+
+type remoteTaskLinkerKey struct{}
+
+// WithRemoteTaskLinker returns a context in which tools that delegate work
+// to other agents can link the remote tasks to the current task.
+func WithRemoteTaskLinker(ctx context.Context, linker RemoteTaskLinker) context.Context {
+	return context.WithValue(ctx, remoteTaskLinkerKey{}, linker)
+}
+
+// LinkRemoteTask links a remote task to the task in ctx. Without a linker
+// in ctx, e.g. in CLI mode, it does nothing.
+func LinkRemoteTask(ctx context.Context, agentURL, remoteTaskID string) error {
+	linker, ok := ctx.Value(remoteTaskLinkerKey{}).(RemoteTaskLinker)
+	if !ok || linker == nil {
+		return nil
+	}
+	return linker(agentURL, remoteTaskID)
+}
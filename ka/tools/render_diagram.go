@@ -0,0 +1,275 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Diagram languages of render_diagram.
+const (
+	DiagramMermaid  = "mermaid"
+	DiagramGraphviz = "graphviz"
+)
+
+const (
+	defaultDiagramTimeout = 60 * time.Second
+	maxDiagramSourceBytes = 256 * 1024
+)
+
+// mermaidDiagramTypes are the keywords a Mermaid diagram starts with.
+var mermaidDiagramTypes = []string{
+	"graph", "flowchart", "sequenceDiagram", "classDiagram", "stateDiagram", "stateDiagram-v2",
+	"erDiagram", "journey", "gantt", "pie", "quadrantChart", "requirementDiagram", "gitGraph",
+	"mindmap", "timeline", "zenuml", "sankey-beta", "xychart-beta", "block-beta", "packet-beta",
+	"architecture-beta", "C4Context", "C4Container", "C4Component", "C4Dynamic", "C4Deployment",
+}
+
+var (
+	graphvizHeader  = regexp.MustCompile(`(?i)^(strict\s+)?(di)?graph\b[^{]*\{`)
+	dotBlockComment = regexp.MustCompile(`(?s)/\*.*?\*/`)
+)
+
+// RenderDiagramTool renders Mermaid or Graphviz source to an SVG or PNG
+// image with the mmdc and dot binaries, and saves both the source and the
+// image as artifacts of the task, e.g. for the figures of a report.
+type RenderDiagramTool struct {
+	MermaidCommand string        // Mermaid CLI; "mmdc" from PATH when empty
+	DotCommand     string        // Graphviz dot; "dot" from PATH when empty
+	Timeout        time.Duration // Per rendering; defaultDiagramTimeout when 0
+}
+
+func (t *RenderDiagramTool) GetName() string {
+	return "render_diagram"
+}
+
+func (t *RenderDiagramTool) GetDescription() string {
+	return "Renders a diagram from Mermaid or Graphviz (DOT) source and saves the source and the rendered image as artifacts of the task. Attributes: name (file name without extension), language (mermaid or graphviz; detected from the source when omitted) and format (svg, the default, or png). Syntax errors are reported with the renderer's message so the source can be fixed."
+}
+
+func (t *RenderDiagramTool) GetXMLDefinition() string {
+	return `<tool id="render_diagram" name="architecture" language="mermaid" format="svg">flowchart LR
+    client --> api --> db</tool>`
+}
+
+func (t *RenderDiagramTool) Execute(ctx context.Context, callDetails FunctionCall) (string, error) {
+	name := strings.TrimSpace(callDetails.Attributes["name"])
+	if name == "" {
+		name = "diagram"
+	}
+	if path.Base(name) != name || strings.HasPrefix(name, ".") {
+		return "", fmt.Errorf("invalid 'name' attribute for render_diagram: expected a file name such as architecture")
+	}
+	source := strings.TrimSpace(callDetails.Content)
+	if source == "" {
+		return "", fmt.Errorf("missing diagram source for render_diagram")
+	}
+	if len(source) > maxDiagramSourceBytes {
+		return "", fmt.Errorf("diagram source of %d bytes exceeds the limit of %d bytes", len(source), maxDiagramSourceBytes)
+	}
+	format := strings.ToLower(strings.TrimSpace(callDetails.Attributes["format"]))
+	if format == "" {
+		format = "svg"
+	}
+	if format != "svg" && format != "png" {
+		return "", fmt.Errorf("unsupported 'format' attribute %q for render_diagram: use svg or png", format)
+	}
+	language := strings.ToLower(strings.TrimSpace(callDetails.Attributes["language"]))
+	switch language {
+	case "":
+		language = detectDiagramLanguage(source)
+	case "dot":
+		language = DiagramGraphviz
+	case DiagramMermaid, DiagramGraphviz:
+	default:
+		return "", fmt.Errorf("unsupported 'language' attribute %q for render_diagram: use mermaid or graphviz", language)
+	}
+	if err := validateDiagram(language, source); err != nil {
+		return "", fmt.Errorf("invalid %s source: %w", language, err)
+	}
+	writer, ok := ctx.Value(artifactWriterKey{}).(ArtifactWriter)
+	if !ok || writer == nil {
+		return "", fmt.Errorf("diagrams cannot be saved outside of a task")
+	}
+
+	image, err := t.render(ctx, language, format, source)
+	if err != nil {
+		return "", err
+	}
+	sourceName, sourceType := name+".mmd", "text/vnd.mermaid"
+	if language == DiagramGraphviz {
+		sourceName, sourceType = name+".dot", "text/vnd.graphviz"
+	}
+	sourceID, err := writer(sourceName, sourceType, []byte(source))
+	if err != nil {
+		return "", fmt.Errorf("failed to save diagram source %s: %w", sourceName, err)
+	}
+	imageName, imageType := name+".svg", "image/svg+xml"
+	if format == "png" {
+		imageName, imageType = name+".png", "image/png"
+	}
+	imageID, err := writer(imageName, imageType, image)
+	if err != nil {
+		return "", fmt.Errorf("failed to save diagram image %s: %w", imageName, err)
+	}
+	return fmt.Sprintf("Rendered %s diagram %s (%d bytes) as %s and saved its source %s as %s.", language, imageName, len(image), imageID, sourceName, sourceID), nil
+}
+
+// detectDiagramLanguage tells Graphviz from Mermaid source by its header.
+func detectDiagramLanguage(source string) string {
+	if graphvizHeader.MatchString(stripDotComments(source)) {
+		return DiagramGraphviz
+	}
+	return DiagramMermaid
+}
+
+// validateDiagram checks the structure of the source before it is rendered,
+// so obvious mistakes are reported without running the renderer.
+func validateDiagram(language, source string) error {
+	if language == DiagramGraphviz {
+		body := stripDotComments(source)
+		if !graphvizHeader.MatchString(body) {
+			return fmt.Errorf("expected the source to start with 'graph' or 'digraph' followed by '{'")
+		}
+		depth, inString := 0, false
+		for i := 0; i < len(body); i++ {
+			switch c := body[i]; {
+			case inString && c == '\\':
+				i++
+			case c == '"':
+				inString = !inString
+			case inString:
+			case c == '{':
+				depth++
+			case c == '}':
+				depth--
+				if depth < 0 {
+					return fmt.Errorf("unbalanced '}'")
+				}
+			}
+		}
+		if inString {
+			return fmt.Errorf("unterminated string")
+		}
+		if depth != 0 {
+			return fmt.Errorf("unbalanced braces: %d '{' not closed", depth)
+		}
+		return nil
+	}
+
+	header := ""
+	inFrontMatter := false
+	for _, line := range strings.Split(source, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "---": // Front matter, e.g. the title
+			inFrontMatter = !inFrontMatter
+		case inFrontMatter, line == "", strings.HasPrefix(line, "%%"):
+		default:
+			header = line
+		}
+		if header != "" {
+			break
+		}
+	}
+	keyword := strings.FieldsFunc(header, func(r rune) bool { return r == ' ' || r == '\t' || r == ':' || r == ';' })
+	if len(keyword) > 0 {
+		for _, diagramType := range mermaidDiagramTypes {
+			if keyword[0] == diagramType {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("unknown diagram type %q: a Mermaid diagram starts with its type, e.g. flowchart, sequenceDiagram or classDiagram", header)
+}
+
+// stripDotComments removes the comments of DOT source.
+func stripDotComments(source string) string {
+	var b strings.Builder
+	for _, line := range strings.Split(source, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "//") || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return strings.TrimSpace(dotBlockComment.ReplaceAllString(b.String(), ""))
+}
+
+// render runs the renderer of the language and returns the image.
+func (t *RenderDiagramTool) render(ctx context.Context, language, format, source string) ([]byte, error) {
+	timeout := t.Timeout
+	if timeout <= 0 {
+		timeout = defaultDiagramTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	var outputPath string
+	switch language {
+	case DiagramGraphviz:
+		binary, err := t.binary(t.DotCommand, "dot", "-dot-command")
+		if err != nil {
+			return nil, err
+		}
+		cmd = exec.CommandContext(ctx, binary, "-T"+format)
+		cmd.Stdin = strings.NewReader(source)
+	default:
+		binary, err := t.binary(t.MermaidCommand, "mmdc", "-mermaid-command")
+		if err != nil {
+			return nil, err
+		}
+		// mmdc reads and writes files, and picks the format by extension
+		dir, err := os.MkdirTemp("", "ka-diagram-")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create a directory for rendering: %w", err)
+		}
+		defer os.RemoveAll(dir)
+		inputPath := filepath.Join(dir, "diagram.mmd")
+		outputPath = filepath.Join(dir, "diagram."+format)
+		if err := os.WriteFile(inputPath, []byte(source), 0600); err != nil {
+			return nil, fmt.Errorf("failed to write the diagram source: %w", err)
+		}
+		cmd = exec.CommandContext(ctx, binary, "--quiet", "-i", inputPath, "-o", outputPath)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("rendering the %s diagram timed out after %s", language, timeout)
+		}
+		return nil, fmt.Errorf("the %s source could not be rendered: %s", language, strings.TrimSpace(truncateCommandOutput(stderr.String()+stdout.String(), 4000)))
+	}
+	if outputPath == "" {
+		return stdout.Bytes(), nil
+	}
+	image, err := os.ReadFile(outputPath)
+	if err != nil {
+		return nil, fmt.Errorf("the %s renderer produced no image: %w", language, err)
+	}
+	return image, nil
+}
+
+// binary resolves the configured renderer, or the default one from PATH.
+func (t *RenderDiagramTool) binary(configured, fallback, flagName string) (string, error) {
+	name := configured
+	if name == "" {
+		name = fallback
+	}
+	resolved, err := lookPath(name)
+	if err != nil {
+		return "", fmt.Errorf("no renderer for this diagram language: %s was not found (install it or set %s)", name, flagName)
+	}
+	return resolved, nil
+}
@@ -0,0 +1,72 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeRenderer writes an executable script standing in for dot or mmdc.
+func fakeRenderer(t *testing.T, script string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "renderer")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRenderDiagramSavesSourceAndImage(t *testing.T) {
+	tool := &RenderDiagramTool{
+		DotCommand:     fakeRenderer(t, `cat >/dev/null; echo "<svg>$1</svg>"`),
+		MermaidCommand: fakeRenderer(t, `echo "PNG" > "$5"`), // --quiet -i <in> -o <out>
+	}
+	saved := map[string]string{}
+	ctx := WithArtifactWriter(context.Background(), func(name, mimeType string, data []byte) (string, error) {
+		saved[name] = mimeType + " " + strings.TrimSpace(string(data))
+		return "artifact-" + name, nil
+	})
+
+	call := FunctionCall{Attributes: map[string]string{"name": "deps"}, Content: "// modules\ndigraph deps { a -> b; label=\"{x}\" }"}
+	result, err := tool.Execute(ctx, call)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved["deps.svg"] != "image/svg+xml <svg>-Tsvg</svg>" || !strings.HasPrefix(saved["deps.dot"], "text/vnd.graphviz // modules") || !strings.Contains(result, "artifact-deps.svg") {
+		t.Errorf("expected the Graphviz source and SVG to be saved, got %v (%s)", saved, result)
+	}
+
+	call = FunctionCall{Attributes: map[string]string{"name": "flow", "format": "png"}, Content: "---\ntitle: Flow\n---\nflowchart LR\n  a --> b"}
+	if _, err := tool.Execute(ctx, call); err != nil {
+		t.Fatal(err)
+	}
+	if saved["flow.png"] != "image/png PNG" || !strings.HasPrefix(saved["flow.mmd"], "text/vnd.mermaid") {
+		t.Errorf("expected the Mermaid source and PNG to be saved, got %v", saved)
+	}
+}
+
+func TestRenderDiagramReportsInvalidSource(t *testing.T) {
+	tool := &RenderDiagramTool{DotCommand: fakeRenderer(t, `cat >/dev/null; echo "Error: syntax error in line 1" >&2; exit 1`)}
+	ctx := WithArtifactWriter(context.Background(), func(name, mimeType string, data []byte) (string, error) {
+		t.Errorf("expected nothing to be saved, got %s", name)
+		return "", nil
+	})
+	for source, want := range map[string]string{
+		"digraph { a -> b":        "not closed",
+		"digraph { a [label=\"x}": "unterminated string",
+		"flowchat LR\n a --> b":   "unknown diagram type",
+		"digraph { a -> -> b }":   "syntax error in line 1", // Reported by the renderer
+	} {
+		_, err := tool.Execute(ctx, FunctionCall{Content: source})
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("expected %q to fail with %q, got %v", source, want, err)
+		}
+	}
+
+	tool = &RenderDiagramTool{MermaidCommand: filepath.Join(t.TempDir(), "missing-mmdc")}
+	if _, err := tool.Execute(ctx, FunctionCall{Content: "pie\n \"a\": 1"}); err == nil || !strings.Contains(err.Error(), "-mermaid-command") {
+		t.Errorf("expected a missing renderer to name its flag, got %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"path"
+	"strings"
+)
+
+// ArtifactWriter adds an artifact to the task a tool runs for and returns
+// its ID.
+type ArtifactWriter func(name, mimeType string, data []byte) (artifactID string, err error)
+
+type artifactWriterKey struct{}
+
+// WithArtifactWriter returns a context in which tools can add artifacts to
+// the current task through writer.
+func WithArtifactWriter(ctx context.Context, writer ArtifactWriter) context.Context {
+	return context.WithValue(ctx, artifactWriterKey{}, writer)
+}
+
+// SaveArtifactTool stores content as a named artifact of the task, e.g. a
+// report or a data file a pipeline expects as output.
+type SaveArtifactTool struct{}
+
+func (t *SaveArtifactTool) GetName() string {
+	return "save_artifact"
+}
+
+func (t *SaveArtifactTool) GetDescription() string {
+	return "Saves the given content as a named artifact (output file) of the task, e.g. a report or a CSV file. The type is the MIME type of the content; it defaults to the one of the name's extension, or text/plain. Saving a name again adds another version. Returns the artifact ID."
+}
+
+func (t *SaveArtifactTool) GetXMLDefinition() string {
+	return `<tool id="save_artifact" name="report.md" type="text/markdown">The content of the artifact goes here.</tool>`
+}
+
+func (t *SaveArtifactTool) Execute(ctx context.Context, callDetails FunctionCall) (string, error) {
+	name := strings.TrimSpace(callDetails.Attributes["name"])
+	if name == "" || path.Base(name) != name {
+		return "", fmt.Errorf("missing or invalid 'name' attribute for save_artifact: expected a file name such as report.md")
+	}
+	mimeType := strings.TrimSpace(callDetails.Attributes["type"])
+	if mimeType == "" {
+		mimeType = mime.TypeByExtension(path.Ext(name))
+	}
+	if mimeType == "" {
+		mimeType = "text/plain"
+	}
+	if _, _, err := mime.ParseMediaType(mimeType); err != nil {
+		return "", fmt.Errorf("invalid 'type' attribute %q for save_artifact: %w", mimeType, err)
+	}
+	writer, ok := ctx.Value(artifactWriterKey{}).(ArtifactWriter)
+	if !ok || writer == nil {
+		return "", fmt.Errorf("artifacts cannot be saved outside of a task")
+	}
+	id, err := writer(name, mimeType, []byte(callDetails.Content))
+	if err != nil {
+		return "", fmt.Errorf("failed to save artifact %s: %w", name, err)
+	}
+	return fmt.Sprintf("Saved artifact %s (%s, %d bytes) as %s.", name, mimeType, len(callDetails.Content), id), nil
+}
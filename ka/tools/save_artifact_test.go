@@ -0,0 +1,31 @@
+package tools
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestSaveArtifactInfersTypeAndWritesThroughContext(t *testing.T) {
+	tool := &SaveArtifactTool{}
+	call := FunctionCall{Attributes: map[string]string{"name": "data.json"}, Content: `{"a": 1}`}
+	if _, err := tool.Execute(context.Background(), call); err == nil {
+		t.Error("expected saving outside of a task to fail")
+	}
+
+	var gotName, gotType, gotData string
+	ctx := WithArtifactWriter(context.Background(), func(name, mimeType string, data []byte) (string, error) {
+		gotName, gotType, gotData = name, mimeType, string(data)
+		return "artifact-1", nil
+	})
+	result, err := tool.Execute(ctx, call)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gotName != "data.json" || gotType != "application/json" || gotData != `{"a": 1}` || !strings.Contains(result, "artifact-1") {
+		t.Errorf("unexpected artifact %s %s %q, result %q", gotName, gotType, gotData, result)
+	}
+	if _, err := tool.Execute(ctx, FunctionCall{Attributes: map[string]string{"name": "../x.txt"}}); err == nil {
+		t.Error("expected a name with a path to be refused")
+	}
+}
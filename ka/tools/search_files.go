@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
 	"os/exec"
 	"sort"
 	"strings"
@@ -17,6 +18,8 @@ type SearchFilesArgs struct {
 	FilePattern *string `json:"file_pattern,omitempty"`
 	MaxFiles    *int    `json:"max_files,omitempty"`
 	FileOffset  *int    `json:"file_offset,omitempty"`
+	// SyntaxContext returns compact snippets with the enclosing function/type instead of raw rg JSON.
+	SyntaxContext bool `json:"syntax_context,omitempty"`
 }
 
 // SearchFilesTool implements the Tool interface for searching files using ripgrep.
@@ -31,7 +34,12 @@ func (t *SearchFilesTool) GetDescription() string {
 }
 
 func (t *SearchFilesTool) GetXMLDefinition() string {
-	return `<tool id="search_files">{"path": "path/to/directory", "regex": "your_regex_pattern (e.g., \\\\.log$ to find .log files)", "file_pattern": "*.go" (optional), "max_files": 100 (optional), "file_offset": 0 (optional)}</tool>`
+	return `<tool id="search_files">{"path": "path/to/directory", "regex": "your_regex_pattern (e.g., \\\\.log$ to find .log files)", "file_pattern": "*.go" (optional), "max_files": 100 (optional), "file_offset": 0 (optional), "syntax_context": true (optional, returns matches with their enclosing function/type instead of raw JSON)}</tool>`
+}
+
+// GetDependencies reports that search_files shells out to ripgrep.
+func (t *SearchFilesTool) GetDependencies() []ToolDependency {
+	return []ToolDependency{{Binary: "rg", VersionArgs: []string{"--version"}}}
 }
 
 func (t *SearchFilesTool) Execute(ctx context.Context, callDetails FunctionCall) (string, error) {
@@ -69,6 +77,10 @@ func (t *SearchFilesTool) Execute(ctx context.Context, callDetails FunctionCall)
 		}
 	}
 
+	if args.SyntaxContext {
+		return formatSyntaxSearchResults(stdout.Bytes(), args)
+	}
+
 	// If no pagination, return the full output
 	if args.MaxFiles == nil && args.FileOffset == nil {
 		return stdout.String(), nil
@@ -180,3 +192,67 @@ func (t *SearchFilesTool) Execute(ctx context.Context, callDetails FunctionCall)
 	}
 	return strings.Join(resultEntries, "\n"), nil
 }
+
+// maxSyntaxContextFileSize bounds the files read back for syntax-aware results.
+const maxSyntaxContextFileSize = 2 * 1024 * 1024
+
+// formatSyntaxSearchResults turns rg --json output into per-file snippets that
+// include the enclosing function or type of every match.
+func formatSyntaxSearchResults(rgOutput []byte, args SearchFilesArgs) (string, error) {
+	matchesByFile := make(map[string][]int)
+	decoder := json.NewDecoder(bytes.NewReader(rgOutput))
+	for decoder.More() {
+		var entry struct {
+			Type string `json:"type"`
+			Data struct {
+				Path struct {
+					Text string `json:"text"`
+				} `json:"path"`
+				LineNumber int `json:"line_number"`
+			} `json:"data"`
+		}
+		if err := decoder.Decode(&entry); err != nil {
+			return "", fmt.Errorf("failed to decode rg JSON output for syntax context: %w", err)
+		}
+		if entry.Type == "match" && entry.Data.LineNumber > 0 {
+			path := entry.Data.Path.Text
+			matchesByFile[path] = append(matchesByFile[path], entry.Data.LineNumber-1)
+		}
+	}
+
+	files := make([]string, 0, len(matchesByFile))
+	for path := range matchesByFile {
+		files = append(files, path)
+	}
+	sort.Strings(files)
+	if args.FileOffset != nil && *args.FileOffset > 0 {
+		if *args.FileOffset >= len(files) {
+			return "", nil
+		}
+		files = files[*args.FileOffset:]
+	}
+	if args.MaxFiles != nil && *args.MaxFiles >= 0 && *args.MaxFiles < len(files) {
+		files = files[:*args.MaxFiles]
+	}
+
+	var b strings.Builder
+	for _, path := range files {
+		info, err := os.Stat(path)
+		if err != nil || info.Size() > maxSyntaxContextFileSize {
+			fmt.Fprintf(&b, "## %s (%d matches, file too large or unreadable for context)\n", path, len(matchesByFile[path]))
+			continue
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %q for syntax context: %w", path, err)
+		}
+		lines := strings.Split(string(content), "\n")
+		lang := DetectLanguage(path, lines[0])
+		if lang == "" {
+			lang = "text"
+		}
+		fmt.Fprintf(&b, "## %s (%s)\n", path, lang)
+		b.WriteString(SyntaxSnippets(lines, lang, matchesByFile[path]))
+	}
+	return b.String(), nil
+}
@@ -326,7 +326,7 @@ func TestSearchFilesTool_Getters(t *testing.T) {
 		t.Errorf("Expected GetDescription() to be non-empty")
 	}
 	// Update expectedXML to match the actual definition in search_files.go
-	expectedXML := `<tool id="search_files">{"path": "path/to/directory", "regex": "your_regex_pattern (e.g., \\\\.log$ to find .log files)", "file_pattern": "*.go" (optional), "max_files": 100 (optional), "file_offset": 0 (optional)}</tool>`
+	expectedXML := `<tool id="search_files">{"path": "path/to/directory", "regex": "your_regex_pattern (e.g., \\\\.log$ to find .log files)", "file_pattern": "*.go" (optional), "max_files": 100 (optional), "file_offset": 0 (optional), "syntax_context": true (optional, returns matches with their enclosing function/type instead of raw JSON)}</tool>`
 	if xmlDef := tool.GetXMLDefinition(); xmlDef != expectedXML {
 		t.Errorf("Expected GetXMLDefinition() to be '%s', got '%s'", expectedXML, xmlDef)
 	}
@@ -0,0 +1,580 @@
+package tools
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-sql-driver/mysql"
+	_ "github.com/lib/pq"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	defaultSQLMaxRows  = 200
+	defaultSQLMaxBytes = 64 << 10
+	defaultSQLTimeout  = 30 * time.Second
+)
+
+// SQLConnection is a database the sql_query tool may query. Connections are
+// read-only unless ReadWrite is set: queries run in a read-only transaction
+// that is always rolled back.
+//
+// Schemas is checked against the names a query spells, which keeps the model
+// to the intended tables but is no security boundary: a database function can
+// run SQL text or read files. Grant the connection's user only what it may
+// read. On postgres, system tables and functions (pg_*, lo_import, ...) are
+// refused unless pg_catalog is allowed.
+type SQLConnection struct {
+	Name           string   `json:"name"`
+	Driver         string   `json:"driver"`            // postgres, mysql or sqlite
+	DSN            string   `json:"dsn"`               // $VAR and ${VAR} are read from the environment
+	Schemas        []string `json:"schemas,omitempty"` // Schemas queries may reference; empty allows all. On mysql the dsn must name one as its database
+	ReadWrite      bool     `json:"readWrite,omitempty"`
+	MaxRows        int      `json:"maxRows,omitempty"`  // Defaults to 200
+	MaxBytes       int      `json:"maxBytes,omitempty"` // Size cap of the JSON result; defaults to 64 KB
+	TimeoutSeconds int      `json:"timeoutSeconds,omitempty"`
+}
+
+// LoadSQLConnections reads the connection configuration from a JSON array,
+// given inline or as a file path, and validates it.
+func LoadSQLConnections(config string) ([]SQLConnection, error) {
+	data := []byte(config)
+	if !strings.HasPrefix(strings.TrimSpace(config), "[") {
+		fileData, err := os.ReadFile(config)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read SQL connections file %s: %w", config, err)
+		}
+		data = fileData
+	}
+	var connections []SQLConnection
+	if err := json.Unmarshal(data, &connections); err != nil {
+		return nil, fmt.Errorf("failed to parse SQL connections: %w", err)
+	}
+	seen := make(map[string]bool)
+	for i := range connections {
+		c := &connections[i]
+		if c.Name == "" {
+			return nil, fmt.Errorf("SQL connection %d has no name", i)
+		}
+		if seen[c.Name] {
+			return nil, fmt.Errorf("duplicate SQL connection '%s'", c.Name)
+		}
+		seen[c.Name] = true
+		switch c.Driver {
+		case "postgres", "mysql", "sqlite":
+		default:
+			return nil, fmt.Errorf("SQL connection '%s': unsupported driver '%s' (expected postgres, mysql or sqlite)", c.Name, c.Driver)
+		}
+		c.DSN = os.ExpandEnv(c.DSN)
+		if c.DSN == "" {
+			return nil, fmt.Errorf("SQL connection '%s' has no dsn", c.Name)
+		}
+		if c.Driver == "mysql" && len(c.Schemas) > 0 {
+			// Unqualified names refer to the database of the DSN, so it must be allowed.
+			cfg, err := mysql.ParseDSN(c.DSN)
+			if err != nil {
+				return nil, fmt.Errorf("SQL connection '%s': invalid dsn: %w", c.Name, err)
+			}
+			if !containsFold(c.Schemas, cfg.DBName) {
+				return nil, fmt.Errorf("SQL connection '%s': the dsn must name one of the schemas (%s) as its database", c.Name, strings.Join(c.Schemas, ", "))
+			}
+		}
+		if c.MaxRows <= 0 {
+			c.MaxRows = defaultSQLMaxRows
+		}
+		if c.MaxBytes <= 0 {
+			c.MaxBytes = defaultSQLMaxBytes
+		}
+	}
+	return connections, nil
+}
+
+// SQLQueryArgs are the arguments of the sql_query tool.
+type SQLQueryArgs struct {
+	Connection string        `json:"connection"`
+	Query      string        `json:"query"`
+	Params     []interface{} `json:"params,omitempty"`
+	MaxRows    int           `json:"max_rows,omitempty"`
+}
+
+// SQLQueryTool runs parameterized queries against configured databases.
+type SQLQueryTool struct {
+	connections map[string]SQLConnection
+	mu          sync.Mutex
+	dbs         map[string]*sql.DB
+}
+
+// NewSQLQueryTool creates the tool for the given connections. Databases are
+// opened on first use.
+func NewSQLQueryTool(connections []SQLConnection) *SQLQueryTool {
+	t := &SQLQueryTool{connections: make(map[string]SQLConnection), dbs: make(map[string]*sql.DB)}
+	for _, c := range connections {
+		t.connections[c.Name] = c
+	}
+	return t
+}
+
+func (t *SQLQueryTool) GetName() string {
+	return "sql_query"
+}
+
+func (t *SQLQueryTool) GetDescription() string {
+	names := make([]string, 0, len(t.connections))
+	for name, c := range t.connections {
+		desc := name + " (" + c.Driver + ", placeholders " + placeholderStyle(c.Driver)
+		if len(c.Schemas) > 0 {
+			desc += ", schemas: " + strings.Join(c.Schemas, ", ")
+		}
+		if !c.ReadWrite {
+			desc += ", read-only"
+		}
+		names = append(names, desc+")")
+	}
+	sort.Strings(names)
+	return "Runs one SQL statement with parameters against a configured database and returns the rows as JSON, capped in rows and size. Available connections: " + strings.Join(names, "; ") + "."
+}
+
+func (t *SQLQueryTool) GetXMLDefinition() string {
+	return `<tool id="sql_query">{"connection": "connection name", "query": "SELECT ... WHERE id = $1", "params": [42] (optional), "max_rows": 50 (optional)}</tool>`
+}
+
+func placeholderStyle(driver string) string {
+	if driver == "postgres" {
+		return "$1, $2"
+	}
+	return "?"
+}
+
+func (t *SQLQueryTool) Execute(ctx context.Context, callDetails FunctionCall) (string, error) {
+	var args SQLQueryArgs
+	if err := json.Unmarshal([]byte(callDetails.Content), &args); err != nil {
+		return "", fmt.Errorf("failed to parse arguments JSON for sql_query: %w. Content: %s", err, callDetails.Content)
+	}
+	conn, ok := t.connections[args.Connection]
+	if !ok {
+		return "", fmt.Errorf("unknown connection '%s'", args.Connection)
+	}
+	query, err := conn.checkQuery(args.Query)
+	if err != nil {
+		return "", err
+	}
+	db, err := t.open(conn)
+	if err != nil {
+		return "", err
+	}
+
+	timeout := defaultSQLTimeout
+	if conn.TimeoutSeconds > 0 {
+		timeout = time.Duration(conn.TimeoutSeconds) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// While the agent is read-only, read-write connections only read: writes
+	// are refused, and a query that writes anyway is never committed
+	readWrite := conn.ReadWrite && !readOnlyFrom(ctx)
+	if conn.ReadWrite && !readWrite && !returnsRows(query) {
+		return "", fmt.Errorf("writes on '%s' are disabled while the agent is read-only", conn.Name)
+	}
+
+	// SQLite has no read-only transactions; read-only connections are opened
+	// with query_only instead.
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: !readWrite && conn.Driver != "sqlite"})
+	if err != nil {
+		return "", fmt.Errorf("failed to start a transaction on '%s': %w", conn.Name, err)
+	}
+	defer tx.Rollback()
+	if conn.Driver == "postgres" && len(conn.Schemas) > 0 {
+		// pg_catalog is searched first unless it is listed, so it goes last
+		searchPath := quoteIdentifiers(conn.Schemas)
+		if !containsFold(conn.Schemas, "pg_catalog") {
+			searchPath += ", pg_catalog"
+		}
+		if _, err := tx.ExecContext(ctx, "SET LOCAL search_path TO "+searchPath); err != nil {
+			return "", fmt.Errorf("failed to restrict the search path on '%s': %w", conn.Name, err)
+		}
+	}
+
+	if readWrite && !returnsRows(query) {
+		res, err := tx.ExecContext(ctx, query, args.Params...)
+		if err != nil {
+			return "", fmt.Errorf("query failed: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return "", fmt.Errorf("failed to commit: %w", err)
+		}
+		affected, _ := res.RowsAffected()
+		return fmt.Sprintf(`{"rows_affected": %d}`, affected), nil
+	}
+
+	maxRows := conn.MaxRows
+	if args.MaxRows > 0 && args.MaxRows < maxRows {
+		maxRows = args.MaxRows
+	}
+	rows, err := tx.QueryContext(ctx, query, args.Params...)
+	if err != nil {
+		return "", fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+	result, err := collectRows(rows, maxRows, conn.MaxBytes)
+	if err != nil {
+		return "", err
+	}
+	if readWrite {
+		if err := tx.Commit(); err != nil {
+			return "", fmt.Errorf("failed to commit: %w", err)
+		}
+	}
+	return result, nil
+}
+
+func (t *SQLQueryTool) open(conn SQLConnection) (*sql.DB, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if db, ok := t.dbs[conn.Name]; ok {
+		return db, nil
+	}
+	dsn := conn.DSN
+	if conn.Driver == "sqlite" && !conn.ReadWrite {
+		separator := "?"
+		if strings.Contains(dsn, "?") {
+			separator = "&"
+		}
+		dsn += separator + "_pragma=query_only(1)"
+	}
+	db, err := sql.Open(conn.Driver, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open connection '%s': %w", conn.Name, err)
+	}
+	t.dbs[conn.Name] = db
+	return db, nil
+}
+
+// Close closes all opened databases.
+func (t *SQLQueryTool) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for name, db := range t.dbs {
+		db.Close()
+		delete(t.dbs, name)
+	}
+	return nil
+}
+
+// collectRows reads up to maxRows rows into a JSON result of at most
+// maxBytes, reporting whether rows were left out.
+func collectRows(rows *sql.Rows, maxRows, maxBytes int) (string, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return "", fmt.Errorf("failed to read columns: %w", err)
+	}
+	out := struct {
+		Columns   []string        `json:"columns"`
+		Rows      [][]interface{} `json:"rows"`
+		Truncated bool            `json:"truncated,omitempty"`
+	}{Columns: columns, Rows: [][]interface{}{}}
+
+	size := 0
+	for rows.Next() {
+		if len(out.Rows) >= maxRows {
+			out.Truncated = true
+			break
+		}
+		values := make([]interface{}, len(columns))
+		pointers := make([]interface{}, len(columns))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return "", fmt.Errorf("failed to read row: %w", err)
+		}
+		for i, v := range values {
+			values[i] = jsonValue(v)
+		}
+		encoded, _ := json.Marshal(values)
+		if size+len(encoded) > maxBytes {
+			out.Truncated = true
+			break
+		}
+		size += len(encoded)
+		out.Rows = append(out.Rows, values)
+	}
+	if err := rows.Err(); err != nil {
+		return "", fmt.Errorf("failed to read rows: %w", err)
+	}
+	data, _ := json.Marshal(out)
+	return string(data), nil
+}
+
+// jsonValue converts a scanned column value into something that encodes
+// readably: text stays text, binary data becomes base64.
+func jsonValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case []byte:
+		if utf8.Valid(value) {
+			return string(value)
+		}
+		return "base64:" + base64.StdEncoding.EncodeToString(value)
+	case time.Time:
+		return value.Format(time.RFC3339Nano)
+	default:
+		return value
+	}
+}
+
+var (
+	sqlLiteralOrComment = regexp.MustCompile(`(?s)'(?:[^']|'')*'|--[^\n]*|/\*.*?\*/`)
+	sqlToken            = regexp.MustCompile(`"(?:[^"]|"")*"|` + "`[^`]*`" + `|[A-Za-z_][\w$]*|\S`)
+	sqlLeadingKeyword   = regexp.MustCompile(`^\s*\(*\s*([A-Za-z]+)`)
+)
+
+// sqlClauseKeywords end the table list of a FROM clause.
+var sqlClauseKeywords = map[string]bool{
+	"where": true, "group": true, "order": true, "having": true, "limit": true, "offset": true, "fetch": true,
+	"union": true, "except": true, "intersect": true, "window": true, "set": true, "values": true, "returning": true, "for": true,
+}
+
+// sqlStatementKeywords start a statement or subquery whose FROM lists tables,
+// unlike the FROM of EXTRACT(... FROM ...) and the like.
+var sqlStatementKeywords = map[string]bool{"select": true, "delete": true, "update": true}
+
+// sqlSessionKeywords start statements that change which schema unqualified
+// names of later queries on the pooled connection refer to.
+var sqlSessionKeywords = map[string]bool{"use": true, "attach": true, "detach": true}
+
+// checkQuery rejects multiple statements and tables in schemas outside the
+// allowlist, and returns the query without a trailing semicolon.
+func (c SQLConnection) checkQuery(query string) (string, error) {
+	query = strings.TrimRight(strings.TrimSpace(query), "; \n\t")
+	if query == "" {
+		return "", fmt.Errorf("missing 'query'")
+	}
+	code := sqlLiteralOrComment.ReplaceAllString(query, "''")
+	if strings.Contains(code, ";") {
+		return "", fmt.Errorf("only one statement per query is allowed")
+	}
+	if len(c.Schemas) > 0 {
+		if err := c.checkSchemas(code); err != nil {
+			return "", err
+		}
+	}
+	return query, nil
+}
+
+// checkSchemas checks every schema a query without literals and comments
+// references against the allowlist. Each table in a FROM list, JOIN, INTO,
+// UPDATE or TABLE is checked, unqualified ones against the schema they
+// resolve to, and so are schema-qualified function calls. Qualifiers of
+// columns must name an allowed schema, or a table or alias of the query.
+func (c SQLConnection) checkSchemas(code string) error {
+	if match := sqlLeadingKeyword.FindStringSubmatch(code); match != nil && sqlSessionKeywords[strings.ToLower(match[1])] {
+		return fmt.Errorf("%s statements are not allowed on connection '%s', which is restricted to schemas", strings.ToUpper(match[1]), c.Name)
+	}
+	type scope struct{ statement, fromList, table bool }
+	tokens := sqlToken.FindAllString(code, -1)
+	scopes := []scope{{}}
+	declared := map[string]bool{"excluded": true} // The row proposed for insertion in an upsert
+	declareAlias := func(i int) {
+		if i < len(tokens) && strings.EqualFold(tokens[i], "as") {
+			i++
+		}
+		if parts, _ := sqlDottedName(tokens, i); len(parts) == 1 {
+			declared[strings.ToLower(parts[0])] = true
+		}
+	}
+	var qualifiers []string
+	expectTable := false
+	for i := 0; i < len(tokens); i++ {
+		top := &scopes[len(scopes)-1]
+		switch tokens[i] {
+		case "(":
+			scopes = append(scopes, scope{table: expectTable}) // A subquery or table function in a FROM list
+			expectTable = false
+			continue
+		case ")":
+			if len(scopes) > 1 {
+				if top.table {
+					declareAlias(i + 1)
+				}
+				scopes = scopes[:len(scopes)-1]
+			}
+			expectTable = false
+			continue
+		case ",":
+			expectTable = top.fromList
+			continue
+		}
+		parts, next := sqlDottedName(tokens, i)
+		if parts == nil {
+			expectTable = false
+			continue
+		}
+		if keyword := strings.ToLower(tokens[i]); len(parts) == 1 && !strings.ContainsAny(tokens[i], "\"`") {
+			switch {
+			case sqlStatementKeywords[keyword]:
+				top.statement = true
+				top.fromList = false
+				expectTable = keyword == "update"
+				continue
+			case keyword == "from" && top.statement:
+				top.fromList = true
+				expectTable = true
+				continue
+			case keyword == "join" || keyword == "into" || keyword == "table":
+				expectTable = true
+				continue
+			case sqlClauseKeywords[keyword]:
+				top.fromList = false
+				expectTable = false
+				continue
+			case expectTable && (keyword == "only" || keyword == "lateral"):
+				continue
+			}
+		}
+		i = next - 1
+		call := next < len(tokens) && tokens[next] == "("
+		if !expectTable && !call {
+			if len(parts) > 1 {
+				qualifiers = append(qualifiers, parts[0])
+			}
+			continue
+		}
+		schema := c.defaultSchema()
+		if len(parts) > 1 {
+			schema = parts[len(parts)-2]
+		} else if catalog := c.catalogSchema(parts[0]); catalog != "" {
+			schema = catalog
+		} else if !expectTable {
+			continue // An unqualified function
+		}
+		if schema != "" && !containsFold(c.Schemas, schema) {
+			return fmt.Errorf("schema '%s' is not allowed on connection '%s' (allowed: %s)", schema, c.Name, strings.Join(c.Schemas, ", "))
+		}
+		if expectTable && !call {
+			declared[strings.ToLower(parts[len(parts)-1])] = true
+			declareAlias(next)
+			expectTable = false
+		}
+		// A table function keeps expectTable, so its alias is declared after its arguments.
+	}
+	for _, qualifier := range qualifiers {
+		if !declared[strings.ToLower(qualifier)] && !containsFold(c.Schemas, qualifier) {
+			return fmt.Errorf("schema '%s' is not allowed on connection '%s' (allowed: %s)", qualifier, c.Name, strings.Join(c.Schemas, ", "))
+		}
+	}
+	return nil
+}
+
+// sqlDottedName returns the parts of the name starting at tokens[i], such as
+// schema.table or alias.*, with quotes removed, and the index of the token
+// after it. It returns nil if tokens[i] is no identifier.
+func sqlDottedName(tokens []string, i int) ([]string, int) {
+	if i >= len(tokens) {
+		return nil, i
+	}
+	part, ok := sqlIdentifier(tokens[i])
+	if !ok {
+		return nil, i
+	}
+	parts := []string{part}
+	i++
+	for i+1 < len(tokens) && tokens[i] == "." {
+		if tokens[i+1] == "*" {
+			return append(parts, "*"), i + 2
+		}
+		part, ok := sqlIdentifier(tokens[i+1])
+		if !ok {
+			break
+		}
+		parts = append(parts, part)
+		i += 2
+	}
+	return parts, i
+}
+
+// sqlIdentifier returns the name a token spells, without its quotes.
+func sqlIdentifier(token string) (string, bool) {
+	switch c := token[0]; {
+	case len(token) >= 2 && c == '"':
+		return strings.ReplaceAll(token[1:len(token)-1], `""`, `"`), true
+	case len(token) >= 2 && c == '`':
+		return token[1 : len(token)-1], true
+	case c == '_' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z':
+		return token, true
+	}
+	return "", false
+}
+
+// defaultSchema returns the schema unqualified table names resolve to, or ""
+// when the driver pins it otherwise (search_path on postgres).
+func (c SQLConnection) defaultSchema() string {
+	switch c.Driver {
+	case "sqlite":
+		return "main"
+	case "mysql":
+		if cfg, err := mysql.ParseDSN(c.DSN); err == nil {
+			return cfg.DBName
+		}
+	}
+	return ""
+}
+
+// postgresCatalogFunctions are pg_catalog functions without the pg_ prefix
+// that read files or run SQL given as text.
+var postgresCatalogFunctions = map[string]bool{
+	"lo_import": true, "lo_export": true, "lo_get": true, "lo_put": true,
+	"query_to_xml": true, "query_to_xmlschema": true, "query_to_xml_and_xmlschema": true,
+	"cursor_to_xml": true, "table_to_xml": true, "table_to_xmlschema": true, "table_to_xml_and_xmlschema": true,
+	"schema_to_xml": true, "database_to_xml": true, "current_setting": true, "set_config": true,
+}
+
+// catalogSchema returns the schema an unqualified name resolves to outside
+// the allowlist: on postgres, pg_catalog for system tables and functions,
+// which are found whatever the search path. Other names return "".
+func (c SQLConnection) catalogSchema(name string) string {
+	name = strings.ToLower(name)
+	if c.Driver == "postgres" && (strings.HasPrefix(name, "pg_") || postgresCatalogFunctions[name]) {
+		return "pg_catalog"
+	}
+	return ""
+}
+
+// returnsRows reports whether a statement produces a result set.
+func returnsRows(query string) bool {
+	match := sqlLeadingKeyword.FindStringSubmatch(query)
+	if match == nil {
+		return false
+	}
+	switch strings.ToLower(match[1]) {
+	case "select", "with", "show", "explain", "values", "pragma", "describe", "table":
+		return true
+	}
+	return strings.Contains(strings.ToLower(query), " returning ")
+}
+
+func containsFold(values []string, v string) bool {
+	for _, value := range values {
+		if strings.EqualFold(value, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func quoteIdentifiers(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -0,0 +1,161 @@
+package tools
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSQLQueryTool(t *testing.T, extra string) *SQLQueryTool {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := sql.Open("sqlite", dbPath)
+	require.NoError(t, err)
+	_, err = db.Exec(`CREATE TABLE orders (id INTEGER PRIMARY KEY, customer TEXT, total REAL);
+		INSERT INTO orders (customer, total) VALUES ('alice', 10.5), ('bob', 20), ('carol', 30)`)
+	require.NoError(t, err)
+	require.NoError(t, db.Close())
+
+	connections, err := LoadSQLConnections(`[{"name": "shop", "driver": "sqlite", "dsn": "` + dbPath + `", "schemas": ["main"]` + extra + `}]`)
+	require.NoError(t, err)
+	tool := NewSQLQueryTool(connections)
+	t.Cleanup(func() { tool.Close() })
+	return tool
+}
+
+func runSQLQuery(tool *SQLQueryTool, args string) (string, error) {
+	return tool.Execute(context.Background(), FunctionCall{Name: "sql_query", Content: args})
+}
+
+func TestSQLQueryTool_ParameterizedQuery(t *testing.T) {
+	tool := newTestSQLQueryTool(t, "")
+
+	result, err := runSQLQuery(tool, `{"connection": "shop", "query": "SELECT customer, total FROM main.orders WHERE total > ? ORDER BY id", "params": [15]}`)
+	require.NoError(t, err)
+	var out struct {
+		Columns   []string        `json:"columns"`
+		Rows      [][]interface{} `json:"rows"`
+		Truncated bool            `json:"truncated"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(result), &out))
+	assert.Equal(t, []string{"customer", "total"}, out.Columns)
+	assert.Equal(t, [][]interface{}{{"bob", float64(20)}, {"carol", float64(30)}}, out.Rows)
+	assert.False(t, out.Truncated)
+}
+
+func TestSQLQueryTool_RowLimit(t *testing.T) {
+	tool := newTestSQLQueryTool(t, `, "maxRows": 2`)
+
+	result, err := runSQLQuery(tool, `{"connection": "shop", "query": "SELECT id FROM orders"}`)
+	require.NoError(t, err)
+	assert.Equal(t, `{"columns":["id"],"rows":[[1],[2]],"truncated":true}`, result)
+
+	result, err = runSQLQuery(tool, `{"connection": "shop", "query": "SELECT id FROM orders", "max_rows": 1}`)
+	require.NoError(t, err)
+	assert.Equal(t, `{"columns":["id"],"rows":[[1]],"truncated":true}`, result)
+}
+
+func TestSQLQueryTool_ReadOnlyByDefault(t *testing.T) {
+	tool := newTestSQLQueryTool(t, "")
+
+	_, err := runSQLQuery(tool, `{"connection": "shop", "query": "DELETE FROM orders"}`)
+	require.Error(t, err)
+
+	result, err := runSQLQuery(tool, `{"connection": "shop", "query": "SELECT count(*) AS n FROM orders"}`)
+	require.NoError(t, err)
+	assert.Contains(t, result, `"rows":[[3]]`)
+}
+
+func TestSQLQueryTool_ReadWrite(t *testing.T) {
+	tool := newTestSQLQueryTool(t, `, "readWrite": true`)
+
+	result, err := runSQLQuery(tool, `{"connection": "shop", "query": "UPDATE orders SET total = total + 1 WHERE customer = ?", "params": ["alice"]}`)
+	require.NoError(t, err)
+	assert.Equal(t, `{"rows_affected": 1}`, result)
+}
+
+func TestSQLQueryTool_ReadWriteOnlyReadsWhileReadOnly(t *testing.T) {
+	tool := newTestSQLQueryTool(t, `, "readWrite": true`)
+	ctx := WithReadOnly(context.Background())
+
+	_, err := tool.Execute(ctx, FunctionCall{Name: "sql_query", Content: `{"connection": "shop", "query": "DELETE FROM orders"}`})
+	assert.ErrorContains(t, err, "disabled while the agent is read-only")
+	_, err = tool.Execute(ctx, FunctionCall{Name: "sql_query", Content: `{"connection": "shop", "query": "DELETE FROM orders RETURNING id"}`})
+	require.NoError(t, err)
+
+	result, err := runSQLQuery(tool, `{"connection": "shop", "query": "SELECT count(*) AS n FROM orders"}`)
+	require.NoError(t, err)
+	assert.Contains(t, result, `"rows":[[3]]`, "a write while read-only must not be committed")
+}
+
+func TestSQLQueryTool_Restrictions(t *testing.T) {
+	tool := newTestSQLQueryTool(t, "")
+
+	_, err := runSQLQuery(tool, `{"connection": "shop", "query": "SELECT 1; DROP TABLE orders"}`)
+	assert.ErrorContains(t, err, "one statement")
+
+	_, err = runSQLQuery(tool, `{"connection": "shop", "query": "SELECT * FROM other.orders"}`)
+	assert.ErrorContains(t, err, "schema 'other' is not allowed")
+
+	result, err := runSQLQuery(tool, `{"connection": "shop", "query": "SELECT 'a;b' AS v;"}`)
+	require.NoError(t, err)
+	assert.True(t, strings.Contains(result, `"a;b"`))
+
+	_, err = runSQLQuery(tool, `{"connection": "unknown", "query": "SELECT 1"}`)
+	assert.ErrorContains(t, err, "unknown connection")
+}
+
+func TestSQLConnection_CheckQuerySchemas(t *testing.T) {
+	postgres := SQLConnection{Name: "pg", Driver: "postgres", Schemas: []string{"app"}}
+	sqlite := SQLConnection{Name: "lite", Driver: "sqlite", Schemas: []string{"main"}}
+	for query, allowed := range map[string]bool{
+		"SELECT * FROM app.t, secret.u":                                                 false,
+		"SELECT * FROM app.t a JOIN app.u b ON a.id = b.id, secret.v":                   false,
+		"SELECT * FROM app.t WHERE id IN (SELECT id FROM secret.u)":                     false,
+		"SELECT * FROM app.t secret, secret.u":                                          false,
+		"SELECT secret.fn()":                                                            false,
+		"SELECT secret.u.id FROM app.t":                                                 false,
+		`SELECT * FROM "secret"."u"`:                                                    false,
+		"SELECT u.name, o.* FROM app.users u JOIN app.orders AS o ON o.uid = u.id":      true,
+		"SELECT EXTRACT(year FROM u.created) FROM app.users u":                          true,
+		"SELECT q.n FROM (SELECT 1 AS n) q, generate_series(1, 3) AS g(i)":              true,
+		"SELECT app.users.name FROM users WHERE name = 'secret.u'":                      true,
+		"INSERT INTO app.t (a) VALUES (1) ON CONFLICT (a) DO UPDATE SET a = excluded.a": true,
+		"SELECT pg_read_file('/etc/passwd')":                                            false,
+		"SELECT * FROM pg_stat_activity":                                                false,
+		"SELECT query_to_xml('select * from secret.u', true, true, '')":                 false,
+		"SELECT count(*), lower(name) FROM users":                                       true,
+	} {
+		_, err := postgres.checkQuery(query)
+		if allowed != (err == nil) {
+			t.Errorf("postgres %q: expected allowed=%v, got %v", query, allowed, err)
+		}
+	}
+
+	_, err := sqlite.checkQuery("ATTACH DATABASE 'other.db' AS other")
+	assert.ErrorContains(t, err, "ATTACH statements are not allowed")
+	_, err = SQLConnection{Name: "lite", Driver: "sqlite", Schemas: []string{"other"}}.checkQuery("SELECT * FROM orders")
+	assert.ErrorContains(t, err, "schema 'main' is not allowed")
+}
+
+func TestLoadSQLConnections_Invalid(t *testing.T) {
+	_, err := LoadSQLConnections(`[{"name": "x", "driver": "oracle", "dsn": "x"}]`)
+	assert.ErrorContains(t, err, "unsupported driver")
+
+	_, err = LoadSQLConnections(`[{"name": "x", "driver": "postgres", "dsn": "$UNSET_TEST_DSN"}]`)
+	assert.ErrorContains(t, err, "has no dsn")
+
+	_, err = LoadSQLConnections(`[{"name": "x", "driver": "mysql", "dsn": "u:p@tcp(localhost:3306)/", "schemas": ["shop"]}]`)
+	assert.ErrorContains(t, err, "must name one of the schemas")
+
+	_, err = LoadSQLConnections(`[{"name": "x", "driver": "mysql", "dsn": "u:p@tcp(localhost:3306)/secret", "schemas": ["shop"]}]`)
+	assert.ErrorContains(t, err, "must name one of the schemas")
+
+	_, err = LoadSQLConnections(`[{"name": "x", "driver": "mysql", "dsn": "u:p@tcp(localhost:3306)/shop", "schemas": ["shop"]}]`)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,558 @@
+package tools
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxTabularSourceBytes = 50 << 20
+	defaultTabularLimit   = 20
+	maxTabularLimit       = 200
+	tabularTopValues      = 5
+	tabularSampleRows     = 3
+)
+
+// TabularAnalyzeArgs are the arguments of the tabular_analyze tool.
+type TabularAnalyzeArgs struct {
+	Source    string   `json:"source"`              // Workspace path or artifact://<id>
+	Delimiter string   `json:"delimiter,omitempty"` // Defaults to tab for .tsv files and comma otherwise
+	Sheet     int      `json:"sheet,omitempty"`     // 1-based worksheet of .xlsx files
+	Filter    string   `json:"filter,omitempty"`
+	GroupBy   []string `json:"group_by,omitempty"`
+	Aggregate []string `json:"aggregate,omitempty"` // count, sum(col), avg(col), min(col), max(col), distinct(col)
+	Select    []string `json:"select,omitempty"`
+	Sort      string   `json:"sort,omitempty"` // Output column, "-" prefix for descending
+	Limit     int      `json:"limit,omitempty"`
+}
+
+// TabularAnalyzeTool loads CSV, TSV and XLSX data and answers filter and
+// aggregation queries with compact results, so whole datasets never have to
+// pass through the LLM context.
+type TabularAnalyzeTool struct{}
+
+func (t *TabularAnalyzeTool) GetName() string {
+	return "tabular_analyze"
+}
+
+func (t *TabularAnalyzeTool) GetDescription() string {
+	return "Analyzes a CSV, TSV or XLSX file or artifact (source \"artifact://<id>\") without reading it whole. Without group_by, aggregate or select it returns a summary: row count, column types and per-column statistics. " +
+		"Otherwise it filters rows (e.g. `region == \"EU\" and amount > 100`; operators ==, !=, >, >=, <, <=, contains, startswith; and, or, not, parentheses; backticks around column names with spaces), " +
+		"groups them and computes count, sum(col), avg(col), min(col), max(col) or distinct(col), or selects columns, returning at most limit rows."
+}
+
+func (t *TabularAnalyzeTool) GetXMLDefinition() string {
+	return `<tool id="tabular_analyze">{"source": "data/sales.csv or artifact://id", "filter": "amount > 100 (optional)", "group_by": ["region"] (optional), "aggregate": ["count", "sum(amount)"] (optional), "select": ["col"] (optional), "sort": "-sum(amount) (optional)", "limit": 20 (optional), "delimiter": ";" (optional), "sheet": 1 (optional, xlsx)}</tool>`
+}
+
+func (t *TabularAnalyzeTool) Execute(ctx context.Context, callDetails FunctionCall) (string, error) {
+	var args TabularAnalyzeArgs
+	if err := json.Unmarshal([]byte(callDetails.Content), &args); err != nil {
+		return "", fmt.Errorf("failed to parse arguments JSON for tabular_analyze: %w. Content: %s", err, callDetails.Content)
+	}
+	if args.Source == "" {
+		return "", fmt.Errorf("missing 'source' argument for tabular_analyze")
+	}
+	columns, rows, err := loadTable(ctx, args)
+	if err != nil {
+		return "", err
+	}
+
+	total := len(rows)
+	if args.Filter != "" {
+		filter, err := parseRowFilter(args.Filter, columns)
+		if err != nil {
+			return "", err
+		}
+		matched := rows[:0:0]
+		for _, row := range rows {
+			if filter(row) {
+				matched = append(matched, row)
+			}
+		}
+		rows = matched
+	}
+
+	var result interface{}
+	switch {
+	case len(args.GroupBy) > 0 || len(args.Aggregate) > 0:
+		result, err = aggregateTable(columns, rows, args)
+	case len(args.Select) > 0:
+		result, err = selectColumns(columns, rows, args)
+	default:
+		result = summarizeTable(columns, rows)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	out := map[string]interface{}{"rows_total": total, "result": result}
+	if args.Filter != "" {
+		out["rows_matched"] = len(rows)
+	}
+	data, _ := json.Marshal(out)
+	return string(data), nil
+}
+
+// loadTable reads the header and rows of the source.
+func loadTable(ctx context.Context, args TabularAnalyzeArgs) ([]string, [][]string, error) {
+	var data []byte
+	name := args.Source
+	if strings.HasPrefix(args.Source, "artifact://") {
+		var err error
+		var artifactName string
+		if data, artifactName, err = readArtifactRef(ctx, args.Source); err != nil {
+			return nil, nil, err
+		}
+		if artifactName != "" {
+			name = artifactName
+		}
+	} else {
+		info, err := os.Stat(args.Source)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open %q: %w", args.Source, err)
+		}
+		if info.Size() > maxTabularSourceBytes {
+			return nil, nil, fmt.Errorf("%q is larger than %d MB", args.Source, maxTabularSourceBytes>>20)
+		}
+		if data, err = os.ReadFile(args.Source); err != nil {
+			return nil, nil, fmt.Errorf("failed to read %q: %w", args.Source, err)
+		}
+	}
+	if len(data) > maxTabularSourceBytes {
+		return nil, nil, fmt.Errorf("%s is larger than %d MB", args.Source, maxTabularSourceBytes>>20)
+	}
+
+	var records [][]string
+	var err error
+	ext := strings.ToLower(filepath.Ext(name))
+	if ext == ".xlsx" || bytes.HasPrefix(data, []byte("PK\x03\x04")) {
+		records, err = readXLSX(data, args.Sheet)
+	} else {
+		delimiter := ','
+		if ext == ".tsv" {
+			delimiter = '\t'
+		}
+		if args.Delimiter != "" {
+			delimiter = []rune(args.Delimiter)[0]
+		}
+		reader := csv.NewReader(bytes.NewReader(data))
+		reader.Comma = delimiter
+		reader.FieldsPerRecord = -1
+		reader.LazyQuotes = true
+		records, err = reader.ReadAll()
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse %s: %w", args.Source, err)
+	}
+	if len(records) == 0 {
+		return nil, nil, fmt.Errorf("%s has no header row", args.Source)
+	}
+	return records[0], records[1:], nil
+}
+
+type columnSummary struct {
+	Name     string       `json:"name"`
+	Type     string       `json:"type"` // number, text or empty
+	NonEmpty int          `json:"non_empty"`
+	Distinct int          `json:"distinct"`
+	Min      *float64     `json:"min,omitempty"`
+	Max      *float64     `json:"max,omitempty"`
+	Mean     *float64     `json:"mean,omitempty"`
+	Top      []valueCount `json:"top,omitempty"`
+	counts   map[string]int
+}
+
+type valueCount struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// summarizeTable describes each column and includes a few sample rows.
+func summarizeTable(columns []string, rows [][]string) interface{} {
+	summaries := make([]columnSummary, len(columns))
+	for i, name := range columns {
+		s := columnSummary{Name: name, counts: make(map[string]int)}
+		numeric := true
+		var sum, min, max float64
+		for _, row := range rows {
+			cell := strings.TrimSpace(cellAt(row, i))
+			if cell == "" {
+				continue
+			}
+			s.NonEmpty++
+			s.counts[cell]++
+			if !numeric {
+				continue
+			}
+			n, err := strconv.ParseFloat(cell, 64)
+			if err != nil {
+				numeric = false
+				continue
+			}
+			if s.NonEmpty == 1 || n < min {
+				min = n
+			}
+			if s.NonEmpty == 1 || n > max {
+				max = n
+			}
+			sum += n
+		}
+		s.Distinct = len(s.counts)
+		switch {
+		case s.NonEmpty == 0:
+			s.Type = "empty"
+		case numeric:
+			s.Type = "number"
+			mean := roundStat(sum / float64(s.NonEmpty))
+			s.Min, s.Max, s.Mean = &min, &max, &mean
+		default:
+			s.Type = "text"
+			s.Top = topValues(s.counts, tabularTopValues)
+		}
+		summaries[i] = s
+	}
+	sample := rows
+	if len(sample) > tabularSampleRows {
+		sample = sample[:tabularSampleRows]
+	}
+	return map[string]interface{}{"columns": summaries, "sample": sample}
+}
+
+func topValues(counts map[string]int, n int) []valueCount {
+	values := make([]valueCount, 0, len(counts))
+	for value, count := range counts {
+		values = append(values, valueCount{Value: value, Count: count})
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if values[i].Count != values[j].Count {
+			return values[i].Count > values[j].Count
+		}
+		return values[i].Value < values[j].Value
+	})
+	if len(values) > n {
+		values = values[:n]
+	}
+	return values
+}
+
+var aggregateCall = regexp.MustCompile(`^(?i)(count|sum|avg|min|max|distinct)\s*(?:\(\s*(.*?)\s*\))?$`)
+
+type aggregation struct {
+	label  string
+	fn     string
+	column int
+}
+
+// aggregateTable groups rows by the group_by columns and computes the
+// aggregations for each group.
+func aggregateTable(columns []string, rows [][]string, args TabularAnalyzeArgs) (interface{}, error) {
+	groupColumns := make([]int, len(args.GroupBy))
+	for i, name := range args.GroupBy {
+		if groupColumns[i] = columnIndex(columns, name); groupColumns[i] < 0 {
+			return nil, fmt.Errorf("unknown group_by column '%s'", name)
+		}
+	}
+	specs := args.Aggregate
+	if len(specs) == 0 {
+		specs = []string{"count"}
+	}
+	aggregations := make([]aggregation, len(specs))
+	for i, spec := range specs {
+		match := aggregateCall.FindStringSubmatch(strings.TrimSpace(spec))
+		if match == nil {
+			return nil, fmt.Errorf("invalid aggregate '%s' (expected count, sum(col), avg(col), min(col), max(col) or distinct(col))", spec)
+		}
+		agg := aggregation{label: spec, fn: strings.ToLower(match[1]), column: -1}
+		if match[2] != "" && match[2] != "*" {
+			if agg.column = columnIndex(columns, strings.Trim(match[2], "`")); agg.column < 0 {
+				return nil, fmt.Errorf("unknown column '%s' in aggregate '%s'", match[2], spec)
+			}
+		} else if agg.fn != "count" {
+			return nil, fmt.Errorf("aggregate '%s' needs a column", spec)
+		}
+		aggregations[i] = agg
+	}
+
+	type group struct {
+		key  []string
+		rows [][]string
+	}
+	groups := make(map[string]*group)
+	var order []string
+	for _, row := range rows {
+		key := make([]string, len(groupColumns))
+		for i, column := range groupColumns {
+			key[i] = cellAt(row, column)
+		}
+		id := strings.Join(key, "\x00")
+		g, ok := groups[id]
+		if !ok {
+			g = &group{key: key}
+			groups[id] = g
+			order = append(order, id)
+		}
+		g.rows = append(g.rows, row)
+	}
+
+	header := append(append([]string{}, args.GroupBy...), specs...)
+	var table [][]interface{}
+	for _, id := range order {
+		g := groups[id]
+		line := make([]interface{}, 0, len(header))
+		for _, value := range g.key {
+			line = append(line, value)
+		}
+		for _, agg := range aggregations {
+			line = append(line, computeAggregate(agg, g.rows))
+		}
+		table = append(table, line)
+	}
+	return sortAndLimit(header, table, args)
+}
+
+func computeAggregate(agg aggregation, rows [][]string) interface{} {
+	if agg.fn == "count" {
+		if agg.column < 0 {
+			return len(rows)
+		}
+		count := 0
+		for _, row := range rows {
+			if strings.TrimSpace(cellAt(row, agg.column)) != "" {
+				count++
+			}
+		}
+		return count
+	}
+	if agg.fn == "distinct" {
+		seen := make(map[string]bool)
+		for _, row := range rows {
+			seen[cellAt(row, agg.column)] = true
+		}
+		return len(seen)
+	}
+
+	var sum, min, max float64
+	count := 0
+	for _, row := range rows {
+		n, err := strconv.ParseFloat(strings.TrimSpace(cellAt(row, agg.column)), 64)
+		if err != nil {
+			continue
+		}
+		if count == 0 || n < min {
+			min = n
+		}
+		if count == 0 || n > max {
+			max = n
+		}
+		sum += n
+		count++
+	}
+	if count == 0 {
+		return nil
+	}
+	switch agg.fn {
+	case "sum":
+		return roundStat(sum)
+	case "avg":
+		return roundStat(sum / float64(count))
+	case "min":
+		return min
+	default:
+		return max
+	}
+}
+
+// selectColumns returns the selected columns of the rows.
+func selectColumns(columns []string, rows [][]string, args TabularAnalyzeArgs) (interface{}, error) {
+	indexes := make([]int, len(args.Select))
+	for i, name := range args.Select {
+		if indexes[i] = columnIndex(columns, name); indexes[i] < 0 {
+			return nil, fmt.Errorf("unknown select column '%s'", name)
+		}
+	}
+	table := make([][]interface{}, len(rows))
+	for r, row := range rows {
+		line := make([]interface{}, len(indexes))
+		for i, column := range indexes {
+			line[i] = cellAt(row, column)
+		}
+		table[r] = line
+	}
+	return sortAndLimit(args.Select, table, args)
+}
+
+// sortAndLimit orders the result table by args.Sort and keeps at most
+// args.Limit rows.
+func sortAndLimit(header []string, table [][]interface{}, args TabularAnalyzeArgs) (interface{}, error) {
+	if args.Sort != "" {
+		desc := strings.HasPrefix(args.Sort, "-")
+		column := columnIndex(header, strings.TrimPrefix(args.Sort, "-"))
+		if column < 0 {
+			return nil, fmt.Errorf("unknown sort column '%s' (expected one of %s)", strings.TrimPrefix(args.Sort, "-"), strings.Join(header, ", "))
+		}
+		sort.SliceStable(table, func(i, j int) bool {
+			if desc {
+				return lessValue(table[j][column], table[i][column])
+			}
+			return lessValue(table[i][column], table[j][column])
+		})
+	}
+	limit := args.Limit
+	if limit <= 0 {
+		limit = defaultTabularLimit
+	}
+	if limit > maxTabularLimit {
+		limit = maxTabularLimit
+	}
+	out := map[string]interface{}{"columns": header, "rows": table}
+	if table == nil {
+		out["rows"] = [][]interface{}{}
+	}
+	if len(table) > limit {
+		out["rows"] = table[:limit]
+		out["rows_omitted"] = len(table) - limit
+	}
+	return out, nil
+}
+
+// lessValue orders numbers numerically, and anything else as text.
+func lessValue(a, b interface{}) bool {
+	an, aok := numericValue(a)
+	bn, bok := numericValue(b)
+	if aok && bok {
+		return an < bn
+	}
+	return fmt.Sprint(a) < fmt.Sprint(b)
+}
+
+func numericValue(v interface{}) (float64, bool) {
+	switch value := v.(type) {
+	case int:
+		return float64(value), true
+	case float64:
+		return value, true
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+func roundStat(v float64) float64 {
+	return math.Round(v*1e4) / 1e4
+}
+
+// readXLSX reads the cell values of a worksheet of an XLSX workbook.
+func readXLSX(data []byte, sheet int) ([][]string, error) {
+	if sheet <= 0 {
+		sheet = 1
+	}
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string]*zip.File)
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+
+	var shared []string
+	if f, ok := files["xl/sharedStrings.xml"]; ok {
+		var sst struct {
+			Items []struct {
+				Text string `xml:"t"`
+				Runs []struct {
+					Text string `xml:"t"`
+				} `xml:"r"`
+			} `xml:"si"`
+		}
+		if err := decodeZipXML(f, &sst); err != nil {
+			return nil, err
+		}
+		for _, item := range sst.Items {
+			text := item.Text
+			for _, run := range item.Runs {
+				text += run.Text
+			}
+			shared = append(shared, text)
+		}
+	}
+
+	f, ok := files[fmt.Sprintf("xl/worksheets/sheet%d.xml", sheet)]
+	if !ok {
+		return nil, fmt.Errorf("workbook has no sheet %d", sheet)
+	}
+	var ws struct {
+		Rows []struct {
+			Cells []struct {
+				Ref    string `xml:"r,attr"`
+				Type   string `xml:"t,attr"`
+				Value  string `xml:"v"`
+				Inline string `xml:"is>t"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := decodeZipXML(f, &ws); err != nil {
+		return nil, err
+	}
+	var records [][]string
+	for _, row := range ws.Rows {
+		var record []string
+		for i, cell := range row.Cells {
+			column := i
+			if cell.Ref != "" {
+				column = xlsxColumn(cell.Ref)
+			}
+			for len(record) <= column {
+				record = append(record, "")
+			}
+			value := cell.Value
+			switch cell.Type {
+			case "s":
+				if n, err := strconv.Atoi(value); err == nil && n < len(shared) {
+					value = shared[n]
+				}
+			case "inlineStr":
+				value = cell.Inline
+			}
+			record[column] = value
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func decodeZipXML(f *zip.File, v interface{}) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return xml.NewDecoder(io.LimitReader(rc, maxTabularSourceBytes)).Decode(v)
+}
+
+// xlsxColumn returns the 0-based column of a cell reference such as "AB12".
+func xlsxColumn(ref string) int {
+	column := 0
+	for _, c := range ref {
+		if c < 'A' || c > 'Z' {
+			break
+		}
+		column = column*26 + int(c-'A'+1)
+	}
+	return column - 1
+}
@@ -0,0 +1,134 @@
+package tools
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSalesCSV = `region,product,amount
+EU,apples,100
+EU,pears,50
+US,apples,200
+US,plums,
+APAC,apples,25.5
+`
+
+func runTabularAnalyze(t *testing.T, ctx context.Context, args string) map[string]interface{} {
+	t.Helper()
+	result, err := (&TabularAnalyzeTool{}).Execute(ctx, FunctionCall{Name: "tabular_analyze", Content: args})
+	require.NoError(t, err)
+	var out map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(result), &out))
+	return out
+}
+
+func writeTestCSV(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "sales.csv")
+	require.NoError(t, os.WriteFile(path, []byte(testSalesCSV), 0644))
+	return path
+}
+
+func TestTabularAnalyze_Summary(t *testing.T) {
+	path := writeTestCSV(t)
+
+	out := runTabularAnalyze(t, context.Background(), fmt.Sprintf(`{"source": %q}`, path))
+	assert.Equal(t, float64(5), out["rows_total"])
+	columns := out["result"].(map[string]interface{})["columns"].([]interface{})
+	require.Len(t, columns, 3)
+
+	region := columns[0].(map[string]interface{})
+	assert.Equal(t, "text", region["type"])
+	assert.Equal(t, float64(3), region["distinct"])
+	assert.Equal(t, map[string]interface{}{"value": "EU", "count": float64(2)}, region["top"].([]interface{})[0])
+
+	amount := columns[2].(map[string]interface{})
+	assert.Equal(t, "number", amount["type"])
+	assert.Equal(t, float64(4), amount["non_empty"])
+	assert.Equal(t, float64(25.5), amount["min"])
+	assert.Equal(t, float64(200), amount["max"])
+	assert.Equal(t, 93.875, amount["mean"])
+}
+
+func TestTabularAnalyze_FilterGroupAndSort(t *testing.T) {
+	path := writeTestCSV(t)
+
+	out := runTabularAnalyze(t, context.Background(), fmt.Sprintf(`{"source": %q, "filter": "product == \"apples\" or (region == 'EU' and amount >= 50)",
+		"group_by": ["region"], "aggregate": ["count", "sum(amount)"], "sort": "-sum(amount)"}`, path))
+	assert.Equal(t, float64(4), out["rows_matched"])
+	result := out["result"].(map[string]interface{})
+	assert.Equal(t, []interface{}{"region", "count", "sum(amount)"}, result["columns"])
+	assert.Equal(t, []interface{}{
+		[]interface{}{"US", float64(1), float64(200)},
+		[]interface{}{"EU", float64(2), float64(150)},
+		[]interface{}{"APAC", float64(1), 25.5},
+	}, result["rows"])
+}
+
+func TestTabularAnalyze_SelectWithLimit(t *testing.T) {
+	path := writeTestCSV(t)
+
+	out := runTabularAnalyze(t, context.Background(), fmt.Sprintf(`{"source": %q, "filter": "not product contains 'APP'", "select": ["product"], "limit": 1}`, path))
+	result := out["result"].(map[string]interface{})
+	assert.Equal(t, []interface{}{[]interface{}{"pears"}}, result["rows"])
+	assert.Equal(t, float64(1), result["rows_omitted"])
+}
+
+func TestTabularAnalyze_Artifact(t *testing.T) {
+	ctx := WithArtifactReader(context.Background(), func(id string) ([]byte, string, error) {
+		if id != "art-1" {
+			return nil, "", fmt.Errorf("artifact not found")
+		}
+		return []byte("a\tb\n1\t2\n3\t4\n"), "data.tsv", nil
+	})
+
+	out := runTabularAnalyze(t, ctx, `{"source": "artifact://art-1", "aggregate": ["sum(b)", "max(a)"]}`)
+	assert.Equal(t, []interface{}{[]interface{}{float64(6), float64(3)}}, out["result"].(map[string]interface{})["rows"])
+
+	_, err := (&TabularAnalyzeTool{}).Execute(context.Background(), FunctionCall{Content: `{"source": "artifact://art-1"}`})
+	assert.ErrorContains(t, err, "not available")
+}
+
+func TestTabularAnalyze_XLSX(t *testing.T) {
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	for name, content := range map[string]string{
+		"xl/sharedStrings.xml":     `<sst><si><t>name</t></si><si><t>score</t></si><si><r><t>al</t></r><r><t>ice</t></r></si></sst>`,
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c></row><row><c r="A2" t="s"><v>2</v></c><c r="B2"><v>7</v></c></row></sheetData></worksheet>`,
+	} {
+		w, err := archive.Create(name)
+		require.NoError(t, err)
+		w.Write([]byte(content))
+	}
+	require.NoError(t, archive.Close())
+	path := filepath.Join(t.TempDir(), "scores.xlsx")
+	require.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+
+	out := runTabularAnalyze(t, context.Background(), fmt.Sprintf(`{"source": %q, "select": ["name", "score"]}`, path))
+	assert.Equal(t, []interface{}{[]interface{}{"alice", "7"}}, out["result"].(map[string]interface{})["rows"])
+}
+
+func TestTabularAnalyze_InvalidFilter(t *testing.T) {
+	path := writeTestCSV(t)
+	tool := &TabularAnalyzeTool{}
+
+	for filter, want := range map[string]string{
+		`missing == 1`:          "unknown column",
+		`amount ~ 1`:            "unknown operator",
+		`(amount > 1`:           "missing ')'",
+		`amount > 1 region`:     "unexpected",
+		`region == "unfinished`: "unterminated",
+	} {
+		args, _ := json.Marshal(map[string]string{"source": path, "filter": filter})
+		_, err := tool.Execute(context.Background(), FunctionCall{Content: string(args)})
+		assert.ErrorContains(t, err, want, filter)
+	}
+}
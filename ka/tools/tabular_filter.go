@@ -0,0 +1,243 @@
+package tools
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// rowFilter is a compiled filter expression of the tabular_analyze tool.
+type rowFilter func(row []string) bool
+
+// parseRowFilter compiles an expression such as
+//
+//	region == "EU" and (amount > 100 or status contains "late")
+//
+// Comparisons take a column on the left and a number or quoted string on the
+// right. Operators are ==, !=, >, >=, <, <=, contains and startswith; they
+// compare numerically when both sides are numbers. Conditions combine with
+// and/&&, or/|| and not/!, and group with parentheses. Column names with
+// spaces are written in backticks.
+func parseRowFilter(expr string, columns []string) (rowFilter, error) {
+	tokens, err := tokenizeFilter(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{tokens: tokens, columns: columns}
+	filter, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected '%s' in filter", p.tokens[p.pos].text)
+	}
+	return filter, nil
+}
+
+type filterToken struct {
+	text   string
+	quoted bool // A string literal
+}
+
+func tokenizeFilter(expr string) ([]filterToken, error) {
+	var tokens []filterToken
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, filterToken{text: string(c)})
+			i++
+		case c == '"' || c == '\'' || c == '`':
+			end := strings.IndexByte(expr[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated %c in filter", c)
+			}
+			// Backticks quote column names, which are not string literals
+			tokens = append(tokens, filterToken{text: expr[i+1 : i+1+end], quoted: c != '`'})
+			i += end + 2
+		case strings.ContainsRune("=!<>&|", rune(c)):
+			j := i + 1
+			for j < len(expr) && strings.ContainsRune("=&|", rune(expr[j])) {
+				j++
+			}
+			tokens = append(tokens, filterToken{text: expr[i:j]})
+			i = j
+		default:
+			j := i
+			for j < len(expr) && !unicode.IsSpace(rune(expr[j])) && !strings.ContainsRune("()=!<>&|\"'`", rune(expr[j])) {
+				j++
+			}
+			tokens = append(tokens, filterToken{text: expr[i:j]})
+			i = j
+		}
+	}
+	return tokens, nil
+}
+
+type filterParser struct {
+	tokens  []filterToken
+	pos     int
+	columns []string
+}
+
+func (p *filterParser) peek() string {
+	if p.pos >= len(p.tokens) || p.tokens[p.pos].quoted {
+		return ""
+	}
+	return strings.ToLower(p.tokens[p.pos].text)
+}
+
+func (p *filterParser) next() (filterToken, error) {
+	if p.pos >= len(p.tokens) {
+		return filterToken{}, fmt.Errorf("filter ends unexpectedly")
+	}
+	p.pos++
+	return p.tokens[p.pos-1], nil
+}
+
+func (p *filterParser) parseOr() (rowFilter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "or" || p.peek() == "||" {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(row []string) bool { return l(row) || right(row) }
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (rowFilter, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "and" || p.peek() == "&&" {
+		p.pos++
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(row []string) bool { return l(row) && right(row) }
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseUnary() (rowFilter, error) {
+	switch p.peek() {
+	case "not", "!":
+		p.pos++
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(row []string) bool { return !inner(row) }, nil
+	case "(":
+		p.pos++
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, fmt.Errorf("missing ')' in filter")
+		}
+		p.pos++
+		return inner, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *filterParser) parseComparison() (rowFilter, error) {
+	columnToken, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	column := columnIndex(p.columns, columnToken.text)
+	if column < 0 {
+		return nil, fmt.Errorf("unknown column '%s' in filter", columnToken.text)
+	}
+	opToken, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	op := strings.ToLower(opToken.text)
+	valueToken, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	value := valueToken.text
+	number, numErr := strconv.ParseFloat(value, 64)
+	numeric := numErr == nil && !valueToken.quoted
+
+	compare := func(cell string) (int, bool) {
+		if numeric {
+			n, err := strconv.ParseFloat(strings.TrimSpace(cell), 64)
+			if err != nil {
+				return 0, false
+			}
+			switch {
+			case n < number:
+				return -1, true
+			case n > number:
+				return 1, true
+			}
+			return 0, true
+		}
+		return strings.Compare(cell, value), true
+	}
+
+	var match func(cell string) bool
+	switch op {
+	case "==", "=":
+		match = func(cell string) bool { c, ok := compare(cell); return ok && c == 0 }
+	case "!=":
+		match = func(cell string) bool { c, ok := compare(cell); return !ok || c != 0 }
+	case ">":
+		match = func(cell string) bool { c, ok := compare(cell); return ok && c > 0 }
+	case ">=":
+		match = func(cell string) bool { c, ok := compare(cell); return ok && c >= 0 }
+	case "<":
+		match = func(cell string) bool { c, ok := compare(cell); return ok && c < 0 }
+	case "<=":
+		match = func(cell string) bool { c, ok := compare(cell); return ok && c <= 0 }
+	case "contains":
+		lower := strings.ToLower(value)
+		match = func(cell string) bool { return strings.Contains(strings.ToLower(cell), lower) }
+	case "startswith":
+		match = func(cell string) bool { return strings.HasPrefix(cell, value) }
+	default:
+		return nil, fmt.Errorf("unknown operator '%s' in filter", opToken.text)
+	}
+	return func(row []string) bool { return match(cellAt(row, column)) }, nil
+}
+
+// columnIndex returns the index of a column, matched case-insensitively, or -1.
+func columnIndex(columns []string, name string) int {
+	for i, column := range columns {
+		if column == name {
+			return i
+		}
+	}
+	for i, column := range columns {
+		if strings.EqualFold(column, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+func cellAt(row []string, i int) string {
+	if i < len(row) {
+		return row[i]
+	}
+	return ""
+}
@@ -0,0 +1,55 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// WaitForEventTool suspends a task until an external system delivers a named
+// event, e.g. a CI run finishing or a ticket being resolved.
+type WaitForEventTool struct{}
+
+// WaitForEventArgs defines the structure for the JSON arguments.
+type WaitForEventArgs struct {
+	Event       string `json:"event"`
+	Description string `json:"description,omitempty"` // What the task is waiting for, shown to operators
+}
+
+// ParseWaitForEventArgs parses and validates the arguments of a wait_for_event call.
+func ParseWaitForEventArgs(content string) (*WaitForEventArgs, error) {
+	var args WaitForEventArgs
+	if err := json.Unmarshal([]byte(content), &args); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON arguments from content '%s': %w", content, err)
+	}
+	args.Event = strings.TrimSpace(args.Event)
+	if args.Event == "" {
+		return nil, fmt.Errorf("missing required 'event' field in JSON arguments")
+	}
+	return &args, nil
+}
+
+func (t *WaitForEventTool) GetName() string {
+	return "wait_for_event"
+}
+
+func (t *WaitForEventTool) GetDescription() string {
+	return "Suspends the task until an external system delivers the named event (e.g. a CI build result or a ticket update). The event's payload is returned as the tool result when it arrives. Use a specific event name agreed with the external system, such as 'ci:build-1234'. Wait for the event 'sub_task' to sleep until one of the sub-tasks you created with add_task finishes; its outcome is returned."
+}
+
+func (t *WaitForEventTool) GetXMLDefinition() string {
+	return `<tool id="wait_for_event">{
+  "event": "Name of the event to wait for, e.g. ci:build-1234",
+  "description": "Optional: what you are waiting for"
+}</tool>`
+}
+
+// Execute validates the arguments. The executor suspends the task after the call.
+func (t *WaitForEventTool) Execute(ctx context.Context, callDetails FunctionCall) (string, error) {
+	args, err := ParseWaitForEventArgs(callDetails.Content)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Waiting for event '%s'. The task resumes when it is delivered.", args.Event), nil
+}
@@ -0,0 +1,17 @@
+package tools
+
+import "context"
+
+type workingDirKey struct{}
+
+// WithWorkingDir returns a context in which tools that run programs use dir
+// as their working directory, typically the workspace of the current task.
+func WithWorkingDir(ctx context.Context, dir string) context.Context {
+	return context.WithValue(ctx, workingDirKey{}, dir)
+}
+
+// workingDirFrom returns the working directory set with WithWorkingDir, or "".
+func workingDirFrom(ctx context.Context) string {
+	dir, _ := ctx.Value(workingDirKey{}).(string)
+	return dir
+}
@@ -0,0 +1,131 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// maxDiffSummaryLines bounds the diff returned to the LLM after a write.
+const maxDiffSummaryLines = 200
+
+// resolveInRoot returns the absolute path of a file the LLM wants to write,
+// refusing paths outside root. Relative paths are resolved against root, an
+// empty root is the working directory. Symlinks are resolved first, so a
+// link inside the root cannot lead out of it.
+func resolveInRoot(root, path string) (string, error) {
+	if root == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return "", fmt.Errorf("failed to determine the workspace root: %w", err)
+		}
+		root = wd
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return "", fmt.Errorf("invalid workspace root %s: %w", root, err)
+	}
+
+	target := path
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(root, target)
+	}
+	target = filepath.Clean(target)
+	// The file and its parents may not exist yet: resolve the longest existing prefix
+	existing, missing := target, ""
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		missing = filepath.Join(filepath.Base(existing), missing)
+		existing = parent
+	}
+	realExisting, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %q: %w", path, err)
+	}
+	resolved := filepath.Join(realExisting, missing)
+
+	rel, err := filepath.Rel(realRoot, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %q is outside the workspace root %s", path, root)
+	}
+	if rel == "." {
+		return "", fmt.Errorf("path %q is the workspace root, not a file", path)
+	}
+	return resolved, nil
+}
+
+// writeWorkspaceFile writes content to the resolved path, creating missing
+// parent directories, and returns the diff summary of the change.
+func writeWorkspaceFile(path, displayPath string, existed bool, before, after string) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", fmt.Errorf("failed to create the directory of %q: %w", displayPath, err)
+	}
+	if err := os.WriteFile(path, []byte(after), 0644); err != nil {
+		return "", fmt.Errorf("failed to write to file %q: %w", displayPath, err)
+	}
+	return diffSummary(displayPath, existed, before, after), nil
+}
+
+// diffSummary describes a change to a file for the LLM: the counts of added
+// and removed lines followed by a unified diff, truncated when long.
+func diffSummary(path string, existed bool, before, after string) string {
+	if before == after {
+		return fmt.Sprintf("%s is unchanged.", path)
+	}
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(before),
+		B:        difflib.SplitLines(after),
+		FromFile: "a/" + path,
+		ToFile:   "b/" + path,
+		Context:  3,
+	})
+	lines := strings.Split(strings.TrimRight(diff, "\n"), "\n")
+	added, removed := 0, 0
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+		case strings.HasPrefix(line, "+"):
+			added++
+		case strings.HasPrefix(line, "-"):
+			removed++
+		}
+	}
+	var summary strings.Builder
+	if existed {
+		fmt.Fprintf(&summary, "Updated %s: +%d -%d lines.\n", path, added, removed)
+	} else {
+		fmt.Fprintf(&summary, "Created %s: +%d lines.\n", path, added)
+	}
+	if len(lines) > maxDiffSummaryLines {
+		lines = append(lines[:maxDiffSummaryLines], fmt.Sprintf("... (%d more diff lines)", len(lines)-maxDiffSummaryLines))
+	}
+	summary.WriteString("```diff\n")
+	summary.WriteString(strings.Join(lines, "\n"))
+	summary.WriteString("\n```")
+	return summary.String()
+}
+
+// readExisting returns the content of a file and whether it exists.
+func readExisting(path, displayPath string) (string, bool, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read file %q: %w", displayPath, err)
+	}
+	return string(data), true, nil
+}
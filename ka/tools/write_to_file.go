@@ -3,12 +3,13 @@ package tools
 import (
 	"context"
 	"fmt"
-	"os"
 	// "ka/a2a" // No longer needed for FunctionCall
 )
 
 // WriteToFileTool implements the Tool interface for writing content to a file.
-type WriteToFileTool struct{}
+type WriteToFileTool struct {
+	Root string // Workspace root the tool may write in; empty is the working directory
+}
 
 // GetName returns the unique name of the tool.
 func (t *WriteToFileTool) GetName() string {
@@ -17,7 +18,7 @@ func (t *WriteToFileTool) GetName() string {
 
 // GetDescription returns a brief description of the tool's purpose.
 func (t *WriteToFileTool) GetDescription() string {
-	return "Writes the given content to a file at the specified path. Overwrites the file if it exists, or creates it if it does not."
+	return "Writes the given content to a file at the specified path, relative to the workspace root. Overwrites the file if it exists, or creates it and its directories if it does not. Returns a diff of the change. Use apply_diff for small edits of large files."
 }
 
 // GetXMLDefinition returns the XML snippet describing how the LLM should call this tool.
@@ -28,20 +29,21 @@ func (t *WriteToFileTool) GetXMLDefinition() string {
 // Execute performs the tool's action: writing content to a file.
 // It expects the 'path' to be provided as an attribute in the tool call,
 // and the content to be written as the inner data of the tool tag.
+// Paths outside the workspace root are refused.
 func (t *WriteToFileTool) Execute(ctx context.Context, callDetails FunctionCall) (string, error) {
 	filePath, pathOk := callDetails.Attributes["path"]
 	if !pathOk || filePath == "" {
 		return "", fmt.Errorf("missing or empty 'path' attribute for write_to_file tool")
 	}
+	resolved, err := resolveInRoot(t.Root, filePath)
+	if err != nil {
+		return "", err
+	}
 
 	content := callDetails.Content // Content is the inner data of the XML tag
-
-	// Write the content to the file. os.WriteFile creates the file if it doesn't exist,
-	// and truncates it if it does. 0644 are standard file permissions.
-	err := os.WriteFile(filePath, []byte(content), 0644)
+	before, existed, err := readExisting(resolved, filePath)
 	if err != nil {
-		return "", fmt.Errorf("failed to write to file %q: %w", filePath, err)
+		return "", err
 	}
-
-	return fmt.Sprintf("Successfully wrote content to %s", filePath), nil
+	return writeWorkspaceFile(resolved, filePath, existed, before, content)
 }
@@ -0,0 +1,50 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteToFileReturnsDiff(t *testing.T) {
+	root := t.TempDir()
+	tool := &WriteToFileTool{Root: root}
+
+	result, err := tool.Execute(context.Background(), FunctionCall{Attributes: map[string]string{"path": "pkg/notes.txt"}, Content: "one\ntwo\n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(result, "Created pkg/notes.txt: +2 lines.") {
+		t.Errorf("unexpected summary for a new file:\n%s", result)
+	}
+
+	result, err = tool.Execute(context.Background(), FunctionCall{Attributes: map[string]string{"path": "pkg/notes.txt"}, Content: "one\n2\n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(result, "Updated pkg/notes.txt: +1 -1 lines.") || !strings.Contains(result, "-two\n+2") {
+		t.Errorf("unexpected summary for an update:\n%s", result)
+	}
+	if data, _ := os.ReadFile(filepath.Join(root, "pkg", "notes.txt")); string(data) != "one\n2\n" {
+		t.Errorf("unexpected file content %q", data)
+	}
+}
+
+func TestWriteToFileRefusesPathsOutsideRoot(t *testing.T) {
+	parent := t.TempDir()
+	root := filepath.Join(parent, "workspace")
+	os.Mkdir(root, 0755)
+	os.Symlink(parent, filepath.Join(root, "escape"))
+	tool := &WriteToFileTool{Root: root}
+
+	for _, path := range []string{"../outside.txt", filepath.Join(parent, "outside.txt"), "escape/outside.txt", "."} {
+		if _, err := tool.Execute(context.Background(), FunctionCall{Attributes: map[string]string{"path": path}, Content: "x"}); err == nil {
+			t.Errorf("expected %q to be refused", path)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(parent, "outside.txt")); !os.IsNotExist(err) {
+		t.Errorf("expected nothing to be written outside the root")
+	}
+}